@@ -26,6 +26,24 @@ func UniqueInts(a []int, sorted bool) []int {
 	return uniq
 }
 
+func UniqueStrings(a []string, sorted bool) []string {
+	tmp := make(map[string]bool, len(a))
+	uniq := make([]string, 0, len(a))
+
+	for _, s := range a {
+		if _, found := tmp[s]; !found {
+			tmp[s] = true
+			uniq = append(uniq, s)
+		}
+	}
+
+	if sorted {
+		sort.Strings(uniq)
+	}
+
+	return uniq
+}
+
 func HasBinary(executable string) bool {
 	if path, err := exec.LookPath(executable); err != nil || path == "" {
 		return false
@@ -2,6 +2,7 @@ package core
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/evilsocket/islazy/fs"
@@ -58,6 +59,24 @@ func TestCoreUniqueIntsUnsorted(t *testing.T) {
 	}
 }
 
+func TestCoreUniqueStringsSorted(t *testing.T) {
+	var units = []struct {
+		from []string
+		to   []string
+	}{
+		{[]string{}, []string{}},
+		{[]string{"a", "a", "a"}, []string{"a"}},
+		{[]string{"c", "b", "c", "a"}, []string{"a", "b", "c"}},
+	}
+
+	for _, u := range units {
+		got := UniqueStrings(u.from, true)
+		if strings.Join(got, ",") != strings.Join(u.to, ",") {
+			t.Fatalf("expected '%v', got '%v'", u.to, got)
+		}
+	}
+}
+
 func TestCoreUniqueIntsSorted(t *testing.T) {
 	var units = []struct {
 		from []int
@@ -21,6 +21,7 @@ type Options struct {
 	CapletsPath   *string
 	Script        *string
 	PcapBufSize   *int
+	Firewall      *string
 }
 
 func ParseOptions() (Options, error) {
@@ -41,6 +42,7 @@ func ParseOptions() (Options, error) {
 		CapletsPath:   flag.String("caplets-path", "", "Specify an alternative base path for caplets."),
 		Script:        flag.String("script", "", "Load a session script."),
 		PcapBufSize:   flag.Int("pcap-buf-size", -1, "PCAP buffer size, leave to 0 for the default value."),
+		Firewall:      flag.String("firewall", "auto", "Firewall backend used for the redirections on Linux, auto, iptables or nftables."),
 	}
 
 	flag.Parse()
@@ -1,5 +1,16 @@
 package firewall
 
+// Backends are the values of the -firewall option, the backend is picked
+// according to the tools available on the system if it's auto, only Linux
+// has more than one.
+const (
+	BackendAuto     = "auto"
+	BackendIPTables = "iptables"
+	BackendNFTables = "nftables"
+)
+
+var Backends = []string{BackendAuto, BackendIPTables, BackendNFTables}
+
 type FirewallManager interface {
 	IsForwardingEnabled() bool
 	EnableForwarding(enabled bool) error
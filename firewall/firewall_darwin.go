@@ -27,7 +27,7 @@ type PfFirewall struct {
 	enabled    bool
 }
 
-func Make(iface *network.Endpoint) FirewallManager {
+func Make(iface *network.Endpoint, backend string) FirewallManager {
 	firewall := &PfFirewall{
 		iface:      iface,
 		filename:   pfFilePath,
@@ -16,7 +16,10 @@ import (
 type LinuxFirewall struct {
 	iface        *network.Endpoint
 	forwarding   bool
+	backend      string
 	redirections map[string]*Redirection
+	nftHandles   map[string]string
+	nftTables    map[string]bool
 }
 
 const (
@@ -24,11 +27,23 @@ const (
 	IPV6ForwardingFile = "/proc/sys/net/ipv6/conf/all/forwarding"
 )
 
-func Make(iface *network.Endpoint) FirewallManager {
+func Make(iface *network.Endpoint, backend string) FirewallManager {
 	firewall := &LinuxFirewall{
 		iface:        iface,
 		forwarding:   false,
+		backend:      backend,
 		redirections: make(map[string]*Redirection),
+		nftHandles:   make(map[string]string),
+		nftTables:    make(map[string]bool),
+	}
+
+	if backend == BackendAuto || backend == "" {
+		// iptables-nft works with nftables kernels too, nft is only needed on
+		// systems without any of the iptables tools
+		firewall.backend = BackendIPTables
+		if !core.HasBinary("iptables") && core.HasBinary("nft") {
+			firewall.backend = BackendNFTables
+		}
 	}
 
 	firewall.forwarding = firewall.IsForwardingEnabled()
@@ -116,6 +131,10 @@ func (f *LinuxFirewall) getCommandLine(r *Redirection, enabled bool) (cmdLine []
 }
 
 func (f *LinuxFirewall) EnableRedirection(r *Redirection, enabled bool) error {
+	if f.backend == BackendNFTables {
+		return f.nftRedirection(r, enabled)
+	}
+
 	cmdLine := f.getCommandLine(r, enabled)
 	rkey := r.String()
 	_, found := f.redirections[rkey]
@@ -162,6 +181,10 @@ func (f LinuxFirewall) Restore() {
 		}
 	}
 
+	if f.backend == BackendNFTables {
+		f.nftCleanup()
+	}
+
 	if err := f.EnableForwarding(f.forwarding); err != nil {
 		fmt.Printf("%s", err)
 	}
@@ -0,0 +1,127 @@
+package firewall
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bettercap/bettercap/core"
+)
+
+// all the nftables rules live in their own table, so that they can be
+// removed at once without touching the ones of the system
+const nftTable = "bettercap"
+
+var nftHandleParser = regexp.MustCompile(`#\s*handle\s+(\d+)`)
+
+func nftFamily(r *Redirection) string {
+	if strings.Count(r.DstAddress, ":") < 2 {
+		return "ip"
+	}
+	return "ip6"
+}
+
+func nft(args ...string) (string, error) {
+	out, err := core.Exec("nft", args)
+	if err != nil && out != "" {
+		return out, fmt.Errorf("%v: %s", err, out)
+	}
+	return out, err
+}
+
+// nftSetup creates the table and the chains for the redirections of the
+// given family, unless it's been done already.
+func (f *LinuxFirewall) nftSetup(family string) error {
+	if f.nftTables[family] {
+		return nil
+	}
+
+	commands := [][]string{
+		{"add", "table", family, nftTable},
+		{"add", "chain", family, nftTable, "prerouting",
+			"{", "type", "nat", "hook", "prerouting", "priority", "-100", ";", "}"},
+		// same as the FORWARD ACCEPT policy set with iptables
+		{"add", "chain", family, nftTable, "forward",
+			"{", "type", "filter", "hook", "forward", "priority", "0", ";", "policy", "accept", ";", "}"},
+	}
+
+	for _, args := range commands {
+		// -- or the negative priority would be parsed as an option
+		if _, err := nft(append([]string{"--"}, args...)...); err != nil {
+			return err
+		}
+	}
+
+	f.nftTables[family] = true
+	return nil
+}
+
+func (f *LinuxFirewall) nftRule(r *Redirection) []string {
+	family := nftFamily(r)
+	destination := r.DstAddress
+	if family == "ip6" {
+		destination = fmt.Sprintf("[%s]", r.DstAddress)
+	}
+
+	rule := []string{
+		"iifname", fmt.Sprintf("\"%s\"", r.Interface),
+		strings.ToLower(r.Protocol), "dport", fmt.Sprintf("%d", r.SrcPort),
+	}
+	if r.SrcAddress != "" {
+		rule = append(rule, family, "daddr", r.SrcAddress)
+	}
+
+	return append(rule, "dnat", "to", fmt.Sprintf("%s:%d", destination, r.DstPort))
+}
+
+func (f *LinuxFirewall) nftRedirection(r *Redirection, enabled bool) error {
+	rkey := r.String()
+	handle, found := f.nftHandles[rkey]
+	family := nftFamily(r)
+
+	if enabled {
+		if found {
+			return fmt.Errorf("Redirection '%s' already enabled.", rkey)
+		} else if err := f.nftSetup(family); err != nil {
+			return err
+		}
+
+		args := append([]string{"--echo", "--handle", "--", "add", "rule", family, nftTable, "prerouting"}, f.nftRule(r)...)
+		out, err := nft(args...)
+		if err != nil {
+			return err
+		}
+
+		// the handle is needed to delete the rule later
+		m := nftHandleParser.FindStringSubmatch(out)
+		if m == nil {
+			return fmt.Errorf("can't find the handle of the nftables rule in '%s'", out)
+		}
+
+		f.redirections[rkey] = r
+		f.nftHandles[rkey] = m[1]
+	} else {
+		if !found {
+			return nil
+		}
+
+		delete(f.redirections, rkey)
+		delete(f.nftHandles, rkey)
+
+		if _, err := nft("delete", "rule", family, nftTable, "prerouting", "handle", handle); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nftCleanup removes the tables created by nftSetup.
+func (f *LinuxFirewall) nftCleanup() {
+	for family := range f.nftTables {
+		if _, err := nft("delete", "table", family, nftTable); err != nil {
+			fmt.Printf("%s", err)
+		}
+		delete(f.nftTables, family)
+	}
+}
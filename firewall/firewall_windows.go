@@ -14,7 +14,7 @@ type WindowsFirewall struct {
 	redirections map[string]*Redirection
 }
 
-func Make(iface *network.Endpoint) FirewallManager {
+func Make(iface *network.Endpoint, backend string) FirewallManager {
 	firewall := &WindowsFirewall{
 		iface:        iface,
 		forwarding:   false,
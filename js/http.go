@@ -8,11 +8,29 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
+	"github.com/evilsocket/islazy/log"
+	"github.com/evilsocket/islazy/plugin"
 	"github.com/robertkrimen/otto"
 )
 
 type httpPackage struct {
+	// held while running the callbacks of asynchronous requests, as the
+	// virtual machine can't be used by two goroutines at once
+	vmLock sync.Locker
+}
+
+// NewHTTPPackage returns the http object for a script whose virtual machine
+// is protected by vmLock, so that asynchronous requests can call it back.
+func NewHTTPPackage(vmLock sync.Locker) httpPackage {
+	return httpPackage{vmLock: vmLock}
+}
+
+// SetHTTPPackage defines the http object of plug, asynchronous requests
+// must lock its virtual machine before calling back.
+func SetHTTPPackage(plug *plugin.Plugin) error {
+	return plug.Set("http", NewHTTPPackage(plug))
 }
 
 type httpResponse struct {
@@ -94,6 +112,47 @@ func (c httpPackage) PostJSON(url string, headers map[string]string, json string
 	return c.Request("POST", url, headers, nil, json)
 }
 
+// RequestAsync performs the request in the background, so that the caller is
+// not stalled, and passes the response to callback if it's a function. It's
+// only available to scripts that can be called back safely.
+func (c httpPackage) RequestAsync(method string, uri string,
+	headers map[string]string,
+	form map[string]string,
+	json string,
+	callback otto.Value) error {
+	if c.vmLock == nil {
+		return fmt.Errorf("asynchronous requests are not supported by this script")
+	}
+
+	go func() {
+		res := c.Request(method, uri, headers, form, json)
+		if !callback.IsFunction() {
+			return
+		}
+
+		c.vmLock.Lock()
+		defer c.vmLock.Unlock()
+
+		if _, err := callback.Call(otto.NullValue(), res); err != nil {
+			log.Error("error running callback of %s %s: %v", method, uri, err)
+		}
+	}()
+
+	return nil
+}
+
+func (c httpPackage) GetAsync(url string, headers map[string]string, callback otto.Value) error {
+	return c.RequestAsync("GET", url, headers, nil, "", callback)
+}
+
+func (c httpPackage) PostFormAsync(url string, headers map[string]string, form map[string]string, callback otto.Value) error {
+	return c.RequestAsync("POST", url, headers, form, "", callback)
+}
+
+func (c httpPackage) PostJSONAsync(url string, headers map[string]string, json string, callback otto.Value) error {
+	return c.RequestAsync("POST", url, headers, nil, json, callback)
+}
+
 func httpRequest(call otto.FunctionCall) otto.Value {
 	argv := call.ArgumentList
 	argc := len(argv)
@@ -0,0 +1,188 @@
+package js
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/islazy/fs"
+	"github.com/evilsocket/islazy/log"
+)
+
+// changes are saved at most this often, so that scripts updating the
+// storage on every request don't rewrite the file every time
+const storageSaveDelay = 2 * time.Second
+
+var (
+	storages     = make(map[string]*Storage)
+	storagesLock = &sync.Mutex{}
+)
+
+// Storage is a key-value store scripts can use to keep their state across
+// callbacks and restarts, it's saved as JSON to its file shortly after it
+// changes.
+type Storage struct {
+	sync.Mutex
+	path     string
+	data     map[string]interface{}
+	dirty    bool
+	timer    *time.Timer
+	saveLock sync.Mutex
+}
+
+// OpenStorage returns the storage saved to path, loading it the first time,
+// so that all the scripts of the session using it share the same data. An
+// empty path returns a storage that is only kept in memory.
+func OpenStorage(path string) (*Storage, error) {
+	storagesLock.Lock()
+	defer storagesLock.Unlock()
+
+	if s, found := storages[path]; found {
+		return s, nil
+	}
+
+	s := &Storage{
+		path: path,
+		data: make(map[string]interface{}),
+	}
+
+	if path != "" && fs.Exists(path) {
+		if raw, err := ioutil.ReadFile(path); err != nil {
+			return nil, err
+		} else if err = json.Unmarshal(raw, &s.data); err != nil {
+			return nil, err
+		}
+	}
+
+	storages[path] = s
+	return s, nil
+}
+
+// changed schedules the save of the storage, it must be called with the
+// lock held.
+func (s *Storage) changed() {
+	if s.path == "" {
+		return
+	}
+
+	s.dirty = true
+	if s.timer == nil {
+		s.timer = time.AfterFunc(storageSaveDelay, s.Flush)
+	}
+}
+
+// Flush saves the pending changes of the storage right away.
+func (s *Storage) Flush() {
+	// serialize the writes so that an older copy can't overwrite a newer one
+	s.saveLock.Lock()
+	defer s.saveLock.Unlock()
+
+	s.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if !s.dirty {
+		s.Unlock()
+		return
+	}
+	s.dirty = false
+	raw, err := json.Marshal(s.data)
+	s.Unlock()
+
+	if err != nil {
+		log.Error("error serializing storage: %v", err)
+		return
+	}
+
+	// write to a temporary file first so that a crash can't truncate it
+	tmp := s.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, raw, 0600); err != nil {
+		log.Error("error writing storage to %s: %v", tmp, err)
+	} else if err = os.Rename(tmp, s.path); err != nil {
+		log.Error("error saving storage to %s: %v", s.path, err)
+	}
+}
+
+// Get returns the value of key or deflt if it's not set.
+func (s *Storage) Get(key string, deflt interface{}) interface{} {
+	s.Lock()
+	defer s.Unlock()
+
+	if v, found := s.data[key]; found {
+		return v
+	}
+	return deflt
+}
+
+func (s *Storage) Set(key string, value interface{}) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.data[key] = value
+	s.changed()
+}
+
+func (s *Storage) Has(key string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	_, found := s.data[key]
+	return found
+}
+
+func (s *Storage) Delete(key string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, found := s.data[key]; found {
+		delete(s.data, key)
+		s.changed()
+	}
+}
+
+func (s *Storage) Keys() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	keys := make([]string, 0, len(s.data))
+	for key := range s.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Incr adds delta to the numeric value of key, starting from 0 if it's not
+// set or not a number, and returns the result.
+func (s *Storage) Incr(key string, delta float64) float64 {
+	s.Lock()
+	defer s.Unlock()
+
+	value := delta
+	switch v := s.data[key].(type) {
+	case float64:
+		value += v
+	case int64:
+		value += float64(v)
+	case int:
+		value += float64(v)
+	}
+
+	s.data[key] = value
+	s.changed()
+
+	return value
+}
+
+func (s *Storage) Clear() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.data = make(map[string]interface{})
+	s.changed()
+}
@@ -2,11 +2,14 @@ package any_proxy
 
 import (
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/bettercap/bettercap/firewall"
 	"github.com/bettercap/bettercap/session"
 	"github.com/evilsocket/islazy/str"
-	"strconv"
-	"strings"
 )
 
 type AnyProxy struct {
@@ -14,6 +17,8 @@ type AnyProxy struct {
 	// not using map[int]*firewall.Redirection to preserve order
 	ports        []int
 	redirections []*firewall.Redirection
+	udp          *udpProxy
+	script       *AnyProxyScript
 }
 
 func NewAnyProxy(s *session.Session) *AnyProxy {
@@ -51,6 +56,24 @@ func NewAnyProxy(s *session.Session) *AnyProxy {
 		"8080",
 		"Port where the proxy is listening."))
 
+	mod.AddParam(session.NewBoolParameter("any.proxy.udp.listener",
+		"false",
+		"If true and the protocol is UDP, a UDP proxy will listen on dst_address and dst_port and forward the datagrams to any.proxy.udp.upstream."))
+
+	mod.AddParam(session.NewStringParameter("any.proxy.udp.upstream",
+		"",
+		"",
+		"Address and port the UDP proxy forwards datagrams to, if empty src_address and src_port are used, which then must be a single address and port."))
+
+	mod.AddParam(session.NewIntParameter("any.proxy.udp.timeout",
+		"60",
+		"Seconds after which idle UDP sessions are closed."))
+
+	mod.AddParam(session.NewStringParameter("any.proxy.script",
+		"",
+		"",
+		"Path of a JS script for the UDP proxy, its onData(from, to, data, drop) callback can change or drop the datagrams."))
+
 	mod.AddHandler(session.NewModuleHandler("any.proxy on", "",
 		"Start the custom proxy redirection.",
 		func(args []string) error {
@@ -154,6 +177,12 @@ func (mod *AnyProxy) Configure() error {
 		}
 	}
 
+	if strings.ToUpper(protocol) == "UDP" {
+		if err = mod.configureUDP(srcAddress, dstAddress, dstPort); err != nil {
+			return err
+		}
+	}
+
 	if !mod.Session.Firewall.IsForwardingEnabled() {
 		mod.Info("Enabling forwarding.")
 		mod.Session.Firewall.EnableForwarding(true)
@@ -161,6 +190,7 @@ func (mod *AnyProxy) Configure() error {
 
 	for _, redir := range mod.redirections {
 		if err := mod.Session.Firewall.EnableRedirection(redir, true); err != nil {
+			mod.closeUDP()
 			return err
 		}
 		mod.Info("applied redirection %s", redir.String())
@@ -169,12 +199,76 @@ func (mod *AnyProxy) Configure() error {
 	return nil
 }
 
+func (mod *AnyProxy) configureUDP(srcAddress string, dstAddress string, dstPort int) error {
+	var err error
+	var listen bool
+	var upstream string
+	var timeout int
+	var scriptPath string
+
+	if err, listen = mod.BoolParam("any.proxy.udp.listener"); err != nil {
+		return err
+	} else if !listen {
+		return nil
+	} else if err, upstream = mod.StringParam("any.proxy.udp.upstream"); err != nil {
+		return err
+	} else if err, timeout = mod.IntParam("any.proxy.udp.timeout"); err != nil {
+		return err
+	} else if timeout <= 0 {
+		return fmt.Errorf("any.proxy.udp.timeout must be greater than 0")
+	} else if err, scriptPath = mod.StringParam("any.proxy.script"); err != nil {
+		return err
+	}
+
+	if upstream == "" {
+		// the original destination is lost with the redirection
+		if srcAddress == "" || len(mod.ports) != 1 {
+			return fmt.Errorf("any.proxy.udp.upstream is required unless a single src_address and src_port are intercepted")
+		}
+		upstream = net.JoinHostPort(srcAddress, strconv.Itoa(mod.ports[0]))
+	}
+
+	local, err := net.ResolveUDPAddr("udp", net.JoinHostPort(dstAddress, strconv.Itoa(dstPort)))
+	if err != nil {
+		return err
+	}
+	remote, err := net.ResolveUDPAddr("udp", upstream)
+	if err != nil {
+		return err
+	}
+
+	mod.script = nil
+	if scriptPath != "" {
+		if err, mod.script = LoadAnyProxyScript(scriptPath, mod.Session); err != nil {
+			return err
+		}
+		mod.Debug("script %s loaded.", scriptPath)
+	}
+
+	if mod.udp, err = newUDPProxy(mod, local, remote, time.Duration(timeout)*time.Second); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (mod *AnyProxy) closeUDP() {
+	if mod.udp != nil {
+		mod.udp.close()
+		mod.udp = nil
+	}
+}
+
 func (mod *AnyProxy) Start() error {
 	if err := mod.Configure(); err != nil {
 		return err
 	}
 
-	return mod.SetRunning(true, func() {})
+	return mod.SetRunning(true, func() {
+		if mod.udp != nil {
+			mod.Info("udp proxy started ( x -> %s -> %s )", mod.udp.listener.LocalAddr(), mod.udp.remote)
+			mod.udp.serve()
+		}
+	})
 }
 
 func (mod *AnyProxy) Stop() error {
@@ -184,5 +278,7 @@ func (mod *AnyProxy) Stop() error {
 			return err
 		}
 	}
-	return mod.SetRunning(false, func() {})
+	return mod.SetRunning(false, func() {
+		mod.closeUDP()
+	})
 }
@@ -0,0 +1,76 @@
+package any_proxy
+
+import (
+	"net"
+
+	"github.com/bettercap/bettercap/js"
+	"github.com/bettercap/bettercap/log"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/plugin"
+
+	"github.com/robertkrimen/otto"
+)
+
+type AnyProxyScript struct {
+	*plugin.Plugin
+	doOnData bool
+}
+
+func LoadAnyProxyScript(path string, sess *session.Session) (err error, s *AnyProxyScript) {
+	log.Info("loading any proxy script %s ...", path)
+
+	plug, err := plugin.Load(path)
+	if err != nil {
+		return
+	}
+
+	// define session pointer
+	if err = plug.Set("env", sess.Env.Data); err != nil {
+		log.Error("error while defining environment: %+v", err)
+		return
+	}
+
+	if err = js.SetHTTPPackage(plug); err != nil {
+		log.Error("error while defining http: %+v", err)
+		return
+	}
+
+	// run onLoad if defined
+	if plug.HasFunc("onLoad") {
+		if _, err = plug.Call("onLoad"); err != nil {
+			log.Error("error while executing onLoad callback: %s", "\ntraceback:\n  "+err.(*otto.Error).String())
+			return
+		}
+	}
+
+	s = &AnyProxyScript{
+		Plugin:   plug,
+		doOnData: plug.HasFunc("onData"),
+	}
+	return
+}
+
+// OnData calls onData(from, to, data, drop) for every datagram, it returns
+// the data to send instead, if any, and whether drop() has been called.
+func (s *AnyProxyScript) OnData(from, to *net.UDPAddr, data []byte) (ret []byte, dropped bool) {
+	if !s.doOnData {
+		return nil, false
+	}
+
+	drop := func(call otto.FunctionCall) otto.Value {
+		dropped = true
+		return otto.Value{}
+	}
+
+	if v, err := s.Call("onData", from.IP.String(), to.IP.String(), data, drop); err != nil {
+		log.Error("error while executing onData callback: %s", err)
+	} else if v != nil {
+		array, ok := v.([]byte)
+		if !ok {
+			log.Error("error while casting exported value to array of byte: value = %+v", v)
+		}
+		ret = array
+	}
+	return
+}
@@ -0,0 +1,158 @@
+package any_proxy
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// datagrams can't be bigger than this
+const udpBufferSize = 0xffff
+
+// udpSession forwards the datagrams of a client through its own socket, so
+// that the answers of the upstream can be sent back to the right client.
+type udpSession struct {
+	client   *net.UDPAddr
+	upstream *net.UDPConn
+}
+
+type udpProxy struct {
+	mod      *AnyProxy
+	listener *net.UDPConn
+	remote   *net.UDPAddr
+	timeout  time.Duration
+	sessions map[string]*udpSession
+	lock     *sync.Mutex
+	wg       *sync.WaitGroup
+}
+
+func newUDPProxy(mod *AnyProxy, local *net.UDPAddr, remote *net.UDPAddr, timeout time.Duration) (*udpProxy, error) {
+	listener, err := net.ListenUDP("udp", local)
+	if err != nil {
+		return nil, err
+	}
+
+	return &udpProxy{
+		mod:      mod,
+		listener: listener,
+		remote:   remote,
+		timeout:  timeout,
+		sessions: make(map[string]*udpSession),
+		lock:     &sync.Mutex{},
+		wg:       &sync.WaitGroup{},
+	}, nil
+}
+
+// filter passes data through the script, if any, and returns what to send.
+func (p *udpProxy) filter(from, to *net.UDPAddr, data []byte) ([]byte, bool) {
+	if p.mod.script == nil {
+		return data, true
+	}
+
+	ret, dropped := p.mod.script.OnData(from, to, data)
+	if dropped {
+		p.mod.Debug("dropping %d bytes from %s to %s", len(data), from, to)
+		return nil, false
+	} else if ret != nil {
+		p.mod.Info("overriding %d bytes of data from %s to %s with %d bytes of new data.", len(data), from, to, len(ret))
+		return ret, true
+	}
+	return data, true
+}
+
+func (p *udpProxy) session(client *net.UDPAddr) (*udpSession, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if s, found := p.sessions[client.String()]; found {
+		return s, nil
+	}
+
+	upstream, err := net.DialUDP("udp", nil, p.remote)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &udpSession{
+		client:   client,
+		upstream: upstream,
+	}
+	p.sessions[client.String()] = s
+
+	p.mod.Debug("new udp session %s -> %s", client, p.remote)
+
+	p.wg.Add(1)
+	go p.replies(s)
+
+	return s, nil
+}
+
+// replies forwards the answers of the upstream to the client until the
+// session is idle for too long.
+func (p *udpProxy) replies(s *udpSession) {
+	defer p.wg.Done()
+	defer func() {
+		p.lock.Lock()
+		delete(p.sessions, s.client.String())
+		p.lock.Unlock()
+		s.upstream.Close()
+	}()
+
+	buff := make([]byte, udpBufferSize)
+	for {
+		s.upstream.SetReadDeadline(time.Now().Add(p.timeout))
+		n, err := s.upstream.Read(buff)
+		if err != nil {
+			return
+		}
+
+		if data, ok := p.filter(p.remote, s.client, buff[:n]); ok {
+			if _, err = p.listener.WriteToUDP(data, s.client); err != nil {
+				p.mod.Warning("write failed: %s", err)
+				return
+			}
+			p.mod.Debug("%s -> %s : %d bytes", p.remote, s.client, len(data))
+		}
+	}
+}
+
+func (p *udpProxy) serve() {
+	buff := make([]byte, udpBufferSize)
+	for {
+		n, client, err := p.listener.ReadFromUDP(buff)
+		if err != nil {
+			if !p.mod.Running() {
+				return
+			}
+			p.mod.Warning("read failed: %s", err)
+			continue
+		}
+
+		data, ok := p.filter(client, p.remote, buff[:n])
+		if !ok {
+			continue
+		}
+
+		s, err := p.session(client)
+		if err != nil {
+			p.mod.Warning("error while connecting to remote %s: %s", p.remote, err)
+			continue
+		} else if _, err = s.upstream.Write(data); err != nil {
+			p.mod.Warning("write failed: %s", err)
+			continue
+		}
+		p.mod.Debug("%s -> %s : %d bytes", client, p.remote, len(data))
+	}
+}
+
+func (p *udpProxy) close() {
+	p.listener.Close()
+
+	p.lock.Lock()
+	for _, s := range p.sessions {
+		s.upstream.Close()
+	}
+	p.lock.Unlock()
+
+	p.wg.Wait()
+}
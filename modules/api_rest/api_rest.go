@@ -25,10 +25,13 @@ type RestAPI struct {
 	password     string
 	certFile     string
 	keyFile      string
+	clientCA     string
 	allowOrigin  string
 	useWebsocket bool
 	upgrader     websocket.Upgrader
 	quit         chan bool
+	tokens       *tokenStore
+	tokensLock   sync.Mutex
 
 	recClock       int
 	recording      bool
@@ -63,6 +66,8 @@ func NewRestAPI(s *session.Session) *RestAPI {
 		record:         nil,
 	}
 
+	mod.SetInterfaceIndependent(true)
+
 	mod.State.Store("recording", &mod.recording)
 	mod.State.Store("rec_clock", &mod.recClock)
 	mod.State.Store("replaying", &mod.replaying)
@@ -99,6 +104,29 @@ func NewRestAPI(s *session.Session) *RestAPI {
 		"",
 		"API authentication password."))
 
+	mod.AddParam(session.NewStringParameter("api.rest.tokens.file",
+		"~/bettercap-api-tokens.json",
+		"",
+		"File where the tokens issued with api.rest.token.new and the secret they're signed with are saved, if empty they only last for the session."))
+
+	mod.AddHandler(session.NewModuleHandler("api.rest.token.new NAME SCOPES DURATION?", `api\.rest\.token\.new\s+(\S+)\s+(\S+)\s*(\S*)`,
+		"Issue a token for NAME with the comma separated SCOPES among read, exec, files or all, valid for DURATION like 24h or forever if not specified.",
+		func(args []string) error {
+			return mod.newToken(args[0], args[1], args[2])
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("api.rest.token.revoke ID", `api\.rest\.token\.revoke\s+(\S+)`,
+		"Revoke the token with the given ID.",
+		func(args []string) error {
+			return mod.revokeToken(args[0])
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("api.rest.tokens", "",
+		"Show the tokens issued so far.",
+		func(args []string) error {
+			return mod.showTokens()
+		}))
+
 	mod.AddParam(session.NewStringParameter("api.rest.certificate",
 		"",
 		"",
@@ -111,9 +139,14 @@ func NewRestAPI(s *session.Session) *RestAPI {
 		"",
 		"API TLS key"))
 
+	mod.AddParam(session.NewStringParameter("api.rest.client_ca",
+		"",
+		"",
+		"If set, the PEM file with the certification authorities the clients must present a certificate signed by, requires api.rest.certificate and api.rest.key."))
+
 	mod.AddParam(session.NewBoolParameter("api.rest.websocket",
 		"false",
-		"If true the /api/events route will be available as a websocket endpoint instead of HTTPS."))
+		"If true the /api/events route will be available as a websocket endpoint instead of HTTPS, clients can pick the events they receive with the tags and level query parameters or by sending a {\"tags\": [...], \"level\": \"...\"} message."))
 
 	mod.AddHandler(session.NewModuleHandler("api.rest on", "",
 		"Start REST API server.",
@@ -203,6 +236,10 @@ func (mod *RestAPI) Configure() error {
 		return err
 	} else if mod.keyFile, err = fs.Expand(mod.keyFile); err != nil {
 		return err
+	} else if err, mod.clientCA = mod.StringParam("api.rest.client_ca"); err != nil {
+		return err
+	} else if mod.clientCA, err = fs.Expand(mod.clientCA); err != nil {
+		return err
 	} else if err, mod.username = mod.StringParam("api.rest.username"); err != nil {
 		return err
 	} else if err, mod.password = mod.StringParam("api.rest.password"); err != nil {
@@ -230,6 +267,16 @@ func (mod *RestAPI) Configure() error {
 		}
 	}
 
+	mod.server.TLSConfig = nil
+	if mod.clientCA != "" {
+		if !mod.isTLS() {
+			return fmt.Errorf("api.rest.client_ca requires api.rest.certificate and api.rest.key to be set")
+		} else if mod.server.TLSConfig, err = tls.ClientAuthConfig(mod.clientCA); err != nil {
+			return fmt.Errorf("error loading %s: %v", mod.clientCA, err)
+		}
+		mod.Info("requiring client certificates signed by %s", mod.clientCA)
+	}
+
 	mod.server.Addr = fmt.Sprintf("%s:%d", ip, port)
 
 	router := mux.NewRouter()
@@ -240,6 +287,10 @@ func (mod *RestAPI) Configure() error {
 
 	router.HandleFunc("/api/events", mod.eventsRoute)
 
+	router.HandleFunc("/api/loot", mod.lootRoute)
+	router.HandleFunc("/api/loot/{kind}", mod.lootRoute)
+	router.HandleFunc("/api/loot/{kind}/{name}", mod.lootRoute)
+
 	router.HandleFunc("/api/session", mod.sessionRoute)
 	router.HandleFunc("/api/session/ble", mod.sessionRoute)
 	router.HandleFunc("/api/session/ble/{mac}", mod.sessionRoute)
@@ -257,10 +308,15 @@ func (mod *RestAPI) Configure() error {
 	router.HandleFunc("/api/session/wifi", mod.sessionRoute)
 	router.HandleFunc("/api/session/wifi/{mac}", mod.sessionRoute)
 
+	router.HandleFunc("/api/tags", mod.tagsRoute)
+	router.HandleFunc("/api/tags/{address}", mod.tagsRoute)
+
 	mod.server.Handler = router
 
-	if mod.username == "" || mod.password == "" {
-		mod.Warning("api.rest.username and/or api.rest.password parameters are empty, authentication is disabled.")
+	if tokens, err := mod.getTokens(); err != nil {
+		return err
+	} else if !tokens.Issued() && (mod.username == "" || mod.password == "") && mod.clientCA == "" {
+		mod.Warning("api.rest.username and/or api.rest.password parameters are empty and no tokens have been issued, authentication is disabled.")
 	}
 
 	return nil
@@ -12,21 +12,32 @@ import (
 	"strings"
 
 	"github.com/bettercap/bettercap/session"
+	"github.com/bettercap/bettercap/tls"
 
 	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
 )
 
 type CommandRequest struct {
 	Command string `json:"cmd"`
 }
 
+type TagRequest struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
 type APIResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"msg"`
 }
 
 func (mod *RestAPI) setAuthFailed(w http.ResponseWriter, r *http.Request) {
-	mod.Warning("Unauthorized authentication attempt from %s to %s", r.RemoteAddr, r.URL.String())
+	client := r.RemoteAddr
+	if name := tls.ClientName(r.TLS); name != "" {
+		client += fmt.Sprintf(" (%s)", name)
+	}
+	mod.Warning("Unauthorized authentication attempt from %s to %s", client, r.URL.String())
 
 	w.Header().Set("WWW-Authenticate", `Basic realm="auth"`)
 	w.WriteHeader(401)
@@ -51,7 +62,39 @@ func (mod *RestAPI) setSecurityHeaders(w http.ResponseWriter) {
 	w.Header().Add("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
 }
 
-func (mod *RestAPI) checkAuth(r *http.Request) bool {
+// bearerToken returns the token of the Authorization header, or of the token
+// query parameter for websockets since browsers can't set their headers.
+func bearerToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	} else if websocket.IsWebSocketUpgrade(r) {
+		return r.URL.Query().Get("token")
+	}
+	return ""
+}
+
+// checkAuth returns true if the request is authorized for scope, either with
+// a token issued for it or with the username and password, which grant every
+// scope.
+func (mod *RestAPI) checkAuth(r *http.Request, scope string) bool {
+	tokens, err := mod.getTokens()
+	if err != nil {
+		mod.Error("error loading api tokens: %v", err)
+		return false
+	}
+
+	if signed := bearerToken(r); signed != "" {
+		token, err := tokens.Verify(signed)
+		if err != nil {
+			mod.Debug("invalid token from %s: %v", r.RemoteAddr, err)
+			return false
+		} else if !token.HasScope(scope) {
+			mod.Warning("token %s (%s) lacks the %s scope for %s", token.ID, token.Name, scope, r.URL.Path)
+			return false
+		}
+		return true
+	}
+
 	if mod.username != "" && mod.password != "" {
 		user, pass, _ := r.BasicAuth()
 		// timing attack my ass
@@ -60,8 +103,19 @@ func (mod *RestAPI) checkAuth(r *http.Request) bool {
 		} else if subtle.ConstantTimeCompare([]byte(pass), []byte(mod.password)) != 1 {
 			return false
 		}
+		return true
+	}
+
+	// no username and password, open unless tokens have ever been issued
+	return !tokens.Issued()
+}
+
+// methodScope returns read for GET requests and write for anything else.
+func methodScope(r *http.Request, read string, write string) string {
+	if r.Method == "GET" {
+		return read
 	}
-	return true
+	return write
 }
 
 func (mod *RestAPI) patchFrame(buf []byte) (frame map[string]interface{}, err error) {
@@ -230,10 +284,10 @@ func (mod *RestAPI) runSessionCommand(w http.ResponseWriter, r *http.Request) {
 	mod.toJSON(w, APIResponse{Success: true})
 }
 
-func (mod *RestAPI) getEvents(limit int) []session.Event {
+func (mod *RestAPI) getEvents(limit int, filter *session.EventFilter) []session.Event {
 	events := make([]session.Event, 0)
 	for _, e := range mod.Session.Events.Sorted() {
-		if mod.Session.EventsIgnoreList.Ignored(e) == false {
+		if mod.Session.EventsIgnoreList.Ignored(e) == false && (filter == nil || filter.Matches(e)) {
 			events = append(events, e)
 		}
 	}
@@ -290,7 +344,13 @@ func (mod *RestAPI) showEvents(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		mod.toJSON(w, mod.getEvents(limit))
+		filter, err := filterFromQuery(q)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		mod.toJSON(w, mod.getEvents(limit, filter))
 	}
 }
 
@@ -306,7 +366,7 @@ func (mod *RestAPI) corsRoute(w http.ResponseWriter, r *http.Request) {
 func (mod *RestAPI) sessionRoute(w http.ResponseWriter, r *http.Request) {
 	mod.setSecurityHeaders(w)
 
-	if !mod.checkAuth(r) {
+	if !mod.checkAuth(r, methodScope(r, ScopeRead, ScopeExec)) {
 		mod.setAuthFailed(w, r)
 		return
 	} else if r.Method == "POST" {
@@ -404,7 +464,7 @@ func (mod *RestAPI) writeFile(fileName string, w http.ResponseWriter, r *http.Re
 func (mod *RestAPI) eventsRoute(w http.ResponseWriter, r *http.Request) {
 	mod.setSecurityHeaders(w)
 
-	if !mod.checkAuth(r) {
+	if !mod.checkAuth(r, methodScope(r, ScopeRead, ScopeExec)) {
 		mod.setAuthFailed(w, r)
 		return
 	}
@@ -418,10 +478,74 @@ func (mod *RestAPI) eventsRoute(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (mod *RestAPI) showTags(w http.ResponseWriter, r *http.Request, mac string) {
+	if mac == "" {
+		mod.toJSON(w, mod.Session.Tags)
+	} else if mod.Session.Tags.Has(mac) {
+		mod.toJSON(w, mod.Session.Tags.Get(mac))
+	} else {
+		http.Error(w, "Not Found", 404)
+	}
+}
+
+func (mod *RestAPI) setTag(w http.ResponseWriter, r *http.Request, mac string) {
+	var tag TagRequest
+
+	if r.Body == nil {
+		http.Error(w, "Bad Request", 400)
+		return
+	} else if err := json.NewDecoder(r.Body).Decode(&tag); err != nil || tag.Name == "" {
+		http.Error(w, "Bad Request", 400)
+		return
+	} else if err = mod.Session.Tags.Set(mac, tag.Name, tag.Value); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	mod.toJSON(w, APIResponse{Success: true})
+}
+
+func (mod *RestAPI) delTag(w http.ResponseWriter, r *http.Request, mac string) {
+	if err := mod.Session.Tags.Del(mac, r.URL.Query().Get("name")); err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+
+	mod.toJSON(w, APIResponse{Success: true})
+}
+
+func (mod *RestAPI) tagsRoute(w http.ResponseWriter, r *http.Request) {
+	mod.setSecurityHeaders(w)
+
+	if !mod.checkAuth(r, methodScope(r, ScopeRead, ScopeExec)) {
+		mod.setAuthFailed(w, r)
+		return
+	}
+
+	mac := ""
+	if address := mux.Vars(r)["address"]; address != "" {
+		var err error
+		if mac, err = mod.Session.TagTarget(address); err != nil {
+			http.Error(w, err.Error(), 404)
+			return
+		}
+	}
+
+	if r.Method == "GET" {
+		mod.showTags(w, r, mac)
+	} else if mac != "" && r.Method == "POST" {
+		mod.setTag(w, r, mac)
+	} else if mac != "" && r.Method == "DELETE" {
+		mod.delTag(w, r, mac)
+	} else {
+		http.Error(w, "Bad Request", 400)
+	}
+}
+
 func (mod *RestAPI) fileRoute(w http.ResponseWriter, r *http.Request) {
 	mod.setSecurityHeaders(w)
 
-	if !mod.checkAuth(r) {
+	if !mod.checkAuth(r, methodScope(r, ScopeFiles, ScopeExec)) {
 		mod.setAuthFailed(w, r)
 		return
 	}
@@ -0,0 +1,178 @@
+package api_rest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/evilsocket/islazy/fs"
+	"github.com/gorilla/mux"
+)
+
+// kinds of files that can be listed and downloaded from /api/loot
+const (
+	LootHandshakes = "handshakes"
+	LootCaptures   = "captures"
+	LootArtifacts  = "artifacts"
+)
+
+var lootKinds = []string{LootHandshakes, LootCaptures, LootArtifacts}
+
+// LootFile is a file produced by the other modules, like a pcap with the
+// WPA handshakes or a file carved by the sniffer.
+type LootFile struct {
+	Kind     string    `json:"kind"`
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+
+	path string
+}
+
+// envPath returns the expanded value of the parameter name, if set.
+func (mod *RestAPI) envPath(name string) string {
+	if found, value := mod.Session.Env.Get(name); found && value != "" {
+		if expanded, err := fs.Expand(value); err == nil {
+			return expanded
+		}
+	}
+	return ""
+}
+
+// lootFiles lists the regular files in the folder dir whose name passes the
+// match callback.
+func lootFiles(kind string, dir string, match func(name string) bool) []LootFile {
+	files := make([]LootFile, 0)
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return files
+	}
+
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && match(entry.Name()) {
+			files = append(files, LootFile{
+				Kind:     kind,
+				Name:     entry.Name(),
+				Size:     entry.Size(),
+				Modified: entry.ModTime(),
+				path:     filepath.Join(dir, entry.Name()),
+			})
+		}
+	}
+	return files
+}
+
+// sameFile matches the file at path.
+func sameFile(path string) func(string) bool {
+	base := filepath.Base(path)
+	return func(name string) bool {
+		return name == base
+	}
+}
+
+// rotatedFiles matches the file at path and the ones net.sniff moved away
+// while rotating it.
+func rotatedFiles(path string) func(string) bool {
+	base := strings.TrimSuffix(filepath.Base(path), ".gz")
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+	return func(name string) bool {
+		name = strings.TrimSuffix(name, ".gz")
+		return name == base || (strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ext))
+	}
+}
+
+func (mod *RestAPI) listLoot(kind string) []LootFile {
+	files := make([]LootFile, 0)
+	switch kind {
+	case LootHandshakes:
+		// either a single pcap or a folder with one per network
+		if path := mod.envPath("wifi.handshakes.file"); path == "" {
+			break
+		} else if info, err := os.Stat(path); err == nil && info.IsDir() {
+			files = lootFiles(kind, path, func(name string) bool {
+				return strings.HasSuffix(name, ".pcap")
+			})
+		} else {
+			files = lootFiles(kind, filepath.Dir(path), sameFile(path))
+		}
+	case LootCaptures:
+		if path := mod.envPath("net.sniff.output"); path != "" {
+			files = lootFiles(kind, filepath.Dir(path), rotatedFiles(path))
+		}
+	case LootArtifacts:
+		if path := mod.envPath("net.sniff.carve"); path != "" {
+			files = lootFiles(kind, path, func(string) bool { return true })
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Modified.Before(files[j].Modified)
+	})
+	return files
+}
+
+func (mod *RestAPI) showLoot(w http.ResponseWriter, kind string) {
+	files := make([]LootFile, 0)
+	for _, k := range lootKinds {
+		if kind == "" || kind == k {
+			files = append(files, mod.listLoot(k)...)
+		}
+	}
+	mod.toJSON(w, files)
+}
+
+// downloadLoot serves a file only if it's in the list for its kind, so that
+// this can't be used to read anything else from the filesystem.
+func (mod *RestAPI) downloadLoot(w http.ResponseWriter, r *http.Request, kind string, name string) {
+	for _, file := range mod.listLoot(kind) {
+		if file.Name != name {
+			continue
+		}
+
+		fp, err := os.Open(file.path)
+		if err != nil {
+			msg := fmt.Sprintf("could not open %s for reading: %s", file.path, err)
+			mod.Debug(msg)
+			http.Error(w, msg, 404)
+			return
+		}
+		defer fp.Close()
+
+		mod.Debug("%s is downloading %s", r.RemoteAddr, file.path)
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Name))
+		http.ServeContent(w, r, file.Name, file.Modified, fp)
+		return
+	}
+
+	http.Error(w, "Not Found", 404)
+}
+
+func (mod *RestAPI) lootRoute(w http.ResponseWriter, r *http.Request) {
+	mod.setSecurityHeaders(w)
+
+	if !mod.checkAuth(r, ScopeFiles) {
+		mod.setAuthFailed(w, r)
+		return
+	} else if r.Method != "GET" {
+		http.Error(w, "Bad Request", 400)
+		return
+	}
+
+	params := mux.Vars(r)
+	kind, name := params["kind"], params["name"]
+	if kind != "" && kind != LootHandshakes && kind != LootCaptures && kind != LootArtifacts {
+		http.Error(w, "Not Found", 404)
+	} else if name == "" {
+		mod.showLoot(w, kind)
+	} else {
+		mod.downloadLoot(w, r, kind, name)
+	}
+}
@@ -34,7 +34,7 @@ func (mod *RestAPI) recordState() error {
 	events := new(bytes.Buffer)
 	encoder = json.NewEncoder(events)
 
-	if err := encoder.Encode(mod.getEvents(0)); err != nil {
+	if err := encoder.Encode(mod.getEvents(0, nil)); err != nil {
 		return err
 	}
 
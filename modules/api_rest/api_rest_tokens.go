@@ -0,0 +1,336 @@
+package api_rest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/islazy/fs"
+	"github.com/evilsocket/islazy/str"
+	"github.com/evilsocket/islazy/tui"
+)
+
+// scopes a token can be issued with
+const (
+	// GET the session, the events and the tags
+	ScopeRead = "read"
+	// run commands and change the session, the events and the tags
+	ScopeExec = "exec"
+	// download files and loot
+	ScopeFiles = "files"
+)
+
+var allScopes = []string{ScopeRead, ScopeExec, ScopeFiles}
+
+// APIToken is a token issued with api.rest.token.new, the signed JWT is
+// only shown once and never stored.
+type APIToken struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	Revoked   bool      `json:"revoked"`
+}
+
+func (t *APIToken) Expired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt)
+}
+
+func (t *APIToken) Valid() bool {
+	return !t.Revoked && !t.Expired()
+}
+
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenStore holds the secret used to sign the tokens and the tokens issued
+// so far, saved to path if set so that they survive restarts.
+type tokenStore struct {
+	sync.Mutex
+	Secret string      `json:"secret"`
+	Tokens []*APIToken `json:"tokens"`
+
+	path string
+}
+
+func randomHex(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func loadTokenStore(path string) (*tokenStore, error) {
+	store := &tokenStore{
+		Tokens: make([]*APIToken, 0),
+		path:   path,
+	}
+
+	if path != "" && fs.Exists(path) {
+		if raw, err := ioutil.ReadFile(path); err != nil {
+			return nil, err
+		} else if err = json.Unmarshal(raw, store); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %v", path, err)
+		}
+	}
+
+	// only saved once the first token is issued
+	if store.Secret == "" {
+		var err error
+		if store.Secret, err = randomHex(32); err != nil {
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+func (s *tokenStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path, raw, 0600)
+}
+
+func (s *tokenStore) find(id string) *APIToken {
+	for _, t := range s.Tokens {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// Issue creates a new token, returning it with its signed JWT.
+func (s *tokenStore) Issue(name string, scopes []string, ttl time.Duration) (*APIToken, string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token := &APIToken{
+		ID:       id,
+		Name:     name,
+		Scopes:   scopes,
+		IssuedAt: time.Now(),
+	}
+	claims := jwtClaims{
+		ID:       token.ID,
+		Subject:  name,
+		Scope:    strings.Join(scopes, " "),
+		IssuedAt: token.IssuedAt.Unix(),
+	}
+	if ttl > 0 {
+		token.ExpiresAt = token.IssuedAt.Add(ttl)
+		claims.ExpiresAt = token.ExpiresAt.Unix()
+	}
+
+	signed, err := jwtEncode(claims, []byte(s.Secret))
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.Tokens = append(s.Tokens, token)
+	return token, signed, s.save()
+}
+
+func (s *tokenStore) Revoke(id string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if token := s.find(id); token == nil {
+		return fmt.Errorf("token %s not found", id)
+	} else {
+		token.Revoked = true
+	}
+	return s.save()
+}
+
+// Verify returns the token a signed JWT has been issued for, as long as it
+// hasn't been revoked or expired.
+func (s *tokenStore) Verify(signed string) (*APIToken, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	claims, err := jwtDecode(signed, []byte(s.Secret), time.Now())
+	if err != nil {
+		return nil, err
+	} else if token := s.find(claims.ID); token == nil {
+		return nil, fmt.Errorf("unknown token %s", claims.ID)
+	} else if token.Revoked {
+		return nil, fmt.Errorf("token %s has been revoked", claims.ID)
+	} else if token.Expired() {
+		return nil, ErrTokenExpired
+	} else {
+		return token, nil
+	}
+}
+
+// Issued returns true if any token has ever been issued, even if it expired
+// or has been revoked since.
+func (s *tokenStore) Issued() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return len(s.Tokens) > 0
+}
+
+func (s *tokenStore) List() []APIToken {
+	s.Lock()
+	defer s.Unlock()
+
+	list := make([]APIToken, 0, len(s.Tokens))
+	for _, t := range s.Tokens {
+		list = append(list, *t)
+	}
+	return list
+}
+
+func parseScopes(list string) ([]string, error) {
+	scopes := make([]string, 0)
+	for _, scope := range str.Comma(strings.ToLower(list)) {
+		if scope == "all" {
+			return allScopes, nil
+		}
+
+		found := false
+		for _, s := range allScopes {
+			if s == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown scope '%s', use %s or all", scope, strings.Join(allScopes, ", "))
+		}
+		scopes = append(scopes, scope)
+	}
+
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("no scopes specified")
+	}
+	return scopes, nil
+}
+
+// getTokens returns the token store, (re)loading it if api.rest.tokens.file
+// changed.
+func (mod *RestAPI) getTokens() (*tokenStore, error) {
+	err, path := mod.StringParam("api.rest.tokens.file")
+	if err != nil {
+		return nil, err
+	} else if path, err = fs.Expand(path); err != nil {
+		return nil, err
+	}
+
+	mod.tokensLock.Lock()
+	defer mod.tokensLock.Unlock()
+
+	if mod.tokens == nil || mod.tokens.path != path {
+		if mod.tokens, err = loadTokenStore(path); err != nil {
+			return nil, err
+		}
+	}
+	return mod.tokens, nil
+}
+
+func (mod *RestAPI) newToken(name string, scopes string, ttl string) error {
+	list, err := parseScopes(scopes)
+	if err != nil {
+		return err
+	}
+
+	duration := time.Duration(0)
+	if ttl != "" {
+		if duration, err = time.ParseDuration(ttl); err != nil {
+			return fmt.Errorf("invalid token duration '%s': %v", ttl, err)
+		}
+	}
+
+	tokens, err := mod.getTokens()
+	if err != nil {
+		return err
+	}
+
+	token, signed, err := tokens.Issue(name, list, duration)
+	if err != nil {
+		return err
+	}
+
+	// printed and not logged, or it would be stored with the events
+	mod.Printf("token %s issued for %s with scopes %s, use it as 'Authorization: Bearer %s'\n",
+		tui.Bold(token.ID), tui.Yellow(name), strings.Join(list, ","), signed)
+	return nil
+}
+
+func (mod *RestAPI) revokeToken(id string) error {
+	tokens, err := mod.getTokens()
+	if err != nil {
+		return err
+	} else if err = tokens.Revoke(id); err != nil {
+		return err
+	}
+	mod.Info("token %s revoked", tui.Bold(id))
+	return nil
+}
+
+func (mod *RestAPI) showTokens() error {
+	tokens, err := mod.getTokens()
+	if err != nil {
+		return err
+	}
+
+	list := tokens.List()
+	if len(list) == 0 {
+		mod.Info("no tokens issued yet")
+		return nil
+	}
+
+	colNames := []string{"ID", "Name", "Scopes", "Issued", "Expires", "Status"}
+	rows := make([][]string, 0, len(list))
+	for _, t := range list {
+		expires := tui.Dim("never")
+		if !t.ExpiresAt.IsZero() {
+			expires = t.ExpiresAt.Format("2006-01-02 15:04:05")
+		}
+
+		status := tui.Green("valid")
+		if t.Revoked {
+			status = tui.Red("revoked")
+		} else if t.Expired() {
+			status = tui.Yellow("expired")
+		}
+
+		rows = append(rows, []string{
+			tui.Bold(t.ID),
+			t.Name,
+			strings.Join(t.Scopes, ","),
+			t.IssuedAt.Format("2006-01-02 15:04:05"),
+			expires,
+			status,
+		})
+	}
+
+	tui.Table(mod.Session.Events.Stdout, colNames, rows)
+	mod.Session.Refresh()
+	return nil
+}
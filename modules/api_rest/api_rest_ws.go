@@ -48,7 +48,7 @@ func (mod *RestAPI) sendPing(ws *websocket.Conn) error {
 	return nil
 }
 
-func (mod *RestAPI) streamWriter(ws *websocket.Conn, w http.ResponseWriter, r *http.Request) {
+func (mod *RestAPI) streamWriter(ws *websocket.Conn, filter *session.EventFilter) {
 	defer ws.Close()
 
 	// first we stream what we already have
@@ -57,7 +57,9 @@ func (mod *RestAPI) streamWriter(ws *websocket.Conn, w http.ResponseWriter, r *h
 	if n > 0 {
 		mod.Debug("Sending %d events.", n)
 		for _, event := range events {
-			if err := mod.streamEvent(ws, event); err != nil {
+			if !filter.Matches(event) {
+				continue
+			} else if err := mod.streamEvent(ws, event); err != nil {
 				return
 			}
 		}
@@ -78,7 +80,9 @@ func (mod *RestAPI) streamWriter(ws *websocket.Conn, w http.ResponseWriter, r *h
 				return
 			}
 		case event := <-listener:
-			if err := mod.streamEvent(ws, event); err != nil {
+			if !filter.Matches(event) {
+				continue
+			} else if err := mod.streamEvent(ws, event); err != nil {
 				return
 			}
 		case <-mod.quit:
@@ -88,21 +92,35 @@ func (mod *RestAPI) streamWriter(ws *websocket.Conn, w http.ResponseWriter, r *h
 	}
 }
 
-func (mod *RestAPI) streamReader(ws *websocket.Conn) {
+// streamReader reads the subscription messages sent by the client, updating
+// the filter of the events streamed to it.
+func (mod *RestAPI) streamReader(ws *websocket.Conn, filter *session.EventFilter) {
 	defer ws.Close()
-	ws.SetReadLimit(512)
+	ws.SetReadLimit(4096)
 	ws.SetReadDeadline(time.Now().Add(pongWait))
 	ws.SetPongHandler(func(string) error { ws.SetReadDeadline(time.Now().Add(pongWait)); return nil })
 	for {
-		_, _, err := ws.ReadMessage()
+		kind, msg, err := ws.ReadMessage()
 		if err != nil {
 			mod.Warning("error reading message from websocket: %v", err)
 			break
+		} else if kind != websocket.TextMessage {
+			continue
+		} else if err = updateFilter(filter, msg); err != nil {
+			mod.Warning("error updating websocket subscription: %v", err)
+		} else {
+			mod.Debug("websocket subscription updated: %s", msg)
 		}
 	}
 }
 
 func (mod *RestAPI) startStreamingEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := filterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
 	ws, err := mod.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		if _, ok := err.(websocket.HandshakeError); !ok {
@@ -113,6 +131,6 @@ func (mod *RestAPI) startStreamingEvents(w http.ResponseWriter, r *http.Request)
 
 	mod.Debug("websocket streaming started for %s", r.RemoteAddr)
 
-	go mod.streamWriter(ws, w, r)
-	mod.streamReader(ws)
+	go mod.streamWriter(ws, filter)
+	mod.streamReader(ws, filter)
 }
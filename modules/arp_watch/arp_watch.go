@@ -0,0 +1,326 @@
+package arp_watch
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// binding is the hardware address an IP address has been seen with.
+type binding struct {
+	mac      string
+	lastSeen time.Time
+}
+
+// sender counts the ARP replies of a hardware address in the current window
+// and keeps the addresses it claimed.
+type sender struct {
+	replies     int
+	windowStart time.Time
+	claimed     map[string]time.Time
+}
+
+type ArpWatcher struct {
+	session.SessionModule
+	Handle        *pcap.Handle
+	bindings      map[string]*binding
+	senders       map[string]*sender
+	lock          *sync.Mutex
+	ttl           time.Duration
+	rate          int
+	maxIPs        int
+	ignoreSelf    bool
+	alerts        *alertLog
+	waitGroup     *sync.WaitGroup
+	pktSourceChan chan gopacket.Packet
+}
+
+func NewArpWatcher(s *session.Session) *ArpWatcher {
+	mod := &ArpWatcher{
+		SessionModule: session.NewSessionModule("arp.watch", s),
+		bindings:      make(map[string]*binding),
+		senders:       make(map[string]*sender),
+		lock:          &sync.Mutex{},
+		alerts:        newAlertLog(),
+		waitGroup:     &sync.WaitGroup{},
+	}
+
+	mod.State.Store("alerts", []Alert{})
+
+	mod.AddParam(session.NewIntParameter("arp.watch.ttl",
+		"300",
+		"Seconds an IP to MAC binding is considered alive, a different MAC claiming the same IP within this time is reported as a conflict."))
+
+	mod.AddParam(session.NewIntParameter("arp.watch.rate",
+		"20",
+		"Maximum number of ARP replies and gratuitous announcements per minute from a single MAC address before it's reported, 0 to disable."))
+
+	mod.AddParam(session.NewIntParameter("arp.watch.max_ips",
+		"4",
+		"Maximum number of IP addresses a single MAC address can claim before it's reported, 0 to disable."))
+
+	mod.AddParam(session.NewBoolParameter("arp.watch.ignore_self",
+		"true",
+		"If true, ARP packets sent by this computer (for instance by arp.spoof) are not reported."))
+
+	mod.AddHandler(session.NewModuleHandler("arp.watch on", "",
+		"Start monitoring the ARP traffic of the network for spoofing attempts.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("arp.watch off", "",
+		"Stop monitoring the ARP traffic.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("arp.watch.alerts", "",
+		"Show the alerts raised so far.",
+		func(args []string) error {
+			return mod.showAlerts()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("arp.watch.clear", "",
+		"Clear the alerts and the known IP to MAC bindings.",
+		func(args []string) error {
+			mod.lock.Lock()
+			mod.bindings = make(map[string]*binding)
+			mod.senders = make(map[string]*sender)
+			mod.lock.Unlock()
+			mod.alerts.Clear()
+			mod.State.Store("alerts", []Alert{})
+			return nil
+		}))
+
+	return mod
+}
+
+func (mod ArpWatcher) Name() string {
+	return "arp.watch"
+}
+
+func (mod ArpWatcher) Description() string {
+	return "Monitors the ARP traffic of the network and raises alerts on IP/MAC conflicts, gateway impersonation and gratuitous ARP floods, the defensive counterpart of arp.spoof."
+}
+
+func (mod ArpWatcher) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *ArpWatcher) Configure() error {
+	var err error
+	var ttl int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, ttl = mod.IntParam("arp.watch.ttl"); err != nil {
+		return err
+	} else if err, mod.rate = mod.IntParam("arp.watch.rate"); err != nil {
+		return err
+	} else if err, mod.maxIPs = mod.IntParam("arp.watch.max_ips"); err != nil {
+		return err
+	} else if err, mod.ignoreSelf = mod.BoolParam("arp.watch.ignore_self"); err != nil {
+		return err
+	} else if ttl <= 0 {
+		return fmt.Errorf("arp.watch.ttl must be greater than 0")
+	} else if mod.rate < 0 || mod.maxIPs < 0 {
+		return fmt.Errorf("arp.watch.rate and arp.watch.max_ips can't be negative")
+	}
+
+	mod.ttl = time.Duration(ttl) * time.Second
+
+	if mod.Handle, err = network.Capture(mod.Session.Interface.Name()); err != nil {
+		return err
+	} else if err = mod.Handle.SetBPFFilter("arp"); err != nil {
+		mod.Handle.Close()
+		return err
+	}
+
+	mod.seed()
+
+	return nil
+}
+
+// seed fills the bindings with the endpoints we already know, so that a
+// spoofer already active when the module starts is caught as soon as it
+// sends its first poisoned reply.
+func (mod *ArpWatcher) seed() {
+	mod.lock.Lock()
+	defer mod.lock.Unlock()
+
+	now := time.Now()
+	add := func(e *network.Endpoint) {
+		if e == nil || e.IP == nil || e.HW == nil {
+			return
+		} else if _, found := mod.bindings[e.IpAddress]; !found {
+			mod.bindings[e.IpAddress] = &binding{mac: e.HwAddress, lastSeen: now}
+		}
+	}
+
+	add(mod.Session.Gateway)
+	for _, e := range mod.Session.Lan.List() {
+		add(e)
+	}
+}
+
+func (mod *ArpWatcher) isGateway(ip string) bool {
+	gw := mod.Session.Gateway
+	return gw != nil && gw != mod.Session.Interface && gw.IpAddress == ip
+}
+
+func (mod *ArpWatcher) onPacket(pkt gopacket.Packet) {
+	arp, ok := pkt.Layer(layers.LayerTypeARP).(*layers.ARP)
+	if !ok || arp.Protocol != layers.EthernetTypeIPv4 || len(arp.SourceProtAddress) != net.IPv4len {
+		return
+	}
+
+	srcHW := net.HardwareAddr(arp.SourceHwAddress)
+	srcIP := net.IP(arp.SourceProtAddress)
+	if srcIP.Equal(net.IPv4zero) {
+		// probes of hosts checking for conflicts before using an address
+		return
+	} else if mod.ignoreSelf && bytes.Equal(srcHW, mod.Session.Interface.HW) {
+		return
+	}
+
+	if eth, ok := pkt.Layer(layers.LayerTypeEthernet).(*layers.Ethernet); ok && !bytes.Equal(eth.SrcMAC, srcHW) {
+		mod.raise(AlertMismatch, srcIP.String(), srcHW.String(), eth.SrcMAC.String(),
+			"ARP sender %s doesn't match the ethernet source %s", srcHW, eth.SrcMAC)
+	}
+
+	gratuitous := srcIP.Equal(net.IP(arp.DstProtAddress))
+	if arp.Operation == layers.ARPReply || gratuitous {
+		mod.checkRate(srcHW.String(), gratuitous)
+	}
+
+	mod.checkBinding(srcIP.String(), srcHW.String(), gratuitous)
+}
+
+func (mod *ArpWatcher) checkRate(mac string, gratuitous bool) {
+	if mod.rate == 0 {
+		return
+	}
+
+	mod.lock.Lock()
+	s := mod.sender(mac)
+	now := time.Now()
+	if now.Sub(s.windowStart) > time.Minute {
+		s.windowStart = now
+		s.replies = 0
+	}
+	s.replies++
+	replies := s.replies
+	mod.lock.Unlock()
+
+	if replies == mod.rate+1 {
+		what := "replies"
+		if gratuitous {
+			what = "gratuitous announcements"
+		}
+		mod.raise(AlertFlood, "", mac, "", "%s sent more than %d ARP %s in a minute", mac, mod.rate, what)
+	}
+}
+
+// must be called with the lock held.
+func (mod *ArpWatcher) sender(mac string) *sender {
+	s, found := mod.senders[mac]
+	if !found {
+		s = &sender{
+			windowStart: time.Now(),
+			claimed:     make(map[string]time.Time),
+		}
+		mod.senders[mac] = s
+	}
+	return s
+}
+
+func (mod *ArpWatcher) checkBinding(ip, mac string, gratuitous bool) {
+	now := time.Now()
+
+	mod.lock.Lock()
+	b, found := mod.bindings[ip]
+	previous := ""
+	alive := false
+	if found {
+		previous = b.mac
+		alive = now.Sub(b.lastSeen) < mod.ttl
+	}
+	mod.bindings[ip] = &binding{mac: mac, lastSeen: now}
+
+	// addresses claimed by this mac within the ttl
+	s := mod.sender(mac)
+	s.claimed[ip] = now
+	claimed := 0
+	for addr, seen := range s.claimed {
+		if now.Sub(seen) < mod.ttl {
+			claimed++
+		} else {
+			delete(s.claimed, addr)
+		}
+	}
+	mod.lock.Unlock()
+
+	if gw := mod.Session.Gateway; mod.isGateway(ip) {
+		if mac != gw.HwAddress {
+			mod.raise(AlertGateway, ip, mac, gw.HwAddress,
+				"gateway %s is claimed by %s instead of %s", ip, mac, gw.HwAddress)
+		}
+	} else if found && previous != mac {
+		if alive {
+			how := "ARP"
+			if gratuitous {
+				how = "gratuitous ARP"
+			}
+			mod.raise(AlertConflict, ip, mac, previous,
+				"%s claimed by %s via %s while still bound to %s", ip, mac, how, previous)
+		} else {
+			mod.Debug("%s moved from %s to %s", ip, previous, mac)
+		}
+	}
+
+	if mod.maxIPs > 0 && claimed == mod.maxIPs+1 {
+		mod.raise(AlertMultipleIPs, ip, mac, "",
+			"%s claimed %d different IP addresses in the last %s", mac, claimed, mod.ttl)
+	}
+}
+
+func (mod *ArpWatcher) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.waitGroup.Add(1)
+		defer mod.waitGroup.Done()
+
+		mod.Info("monitoring %d known bindings", len(mod.bindings))
+
+		src := gopacket.NewPacketSource(mod.Handle, mod.Handle.LinkType())
+		mod.pktSourceChan = src.Packets()
+		for packet := range mod.pktSourceChan {
+			if !mod.Running() {
+				break
+			}
+
+			mod.onPacket(packet)
+		}
+	})
+}
+
+func (mod *ArpWatcher) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.pktSourceChan <- nil
+		mod.Handle.Close()
+		mod.waitGroup.Wait()
+	})
+}
@@ -0,0 +1,124 @@
+package arp_watch
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+const (
+	AlertConflict    = "conflict"
+	AlertGateway     = "gateway"
+	AlertFlood       = "flood"
+	AlertMultipleIPs = "multiple_ips"
+	AlertMismatch    = "mismatch"
+
+	// the same alert is not raised again before this time
+	alertCooldown = time.Minute
+	maxAlerts     = 1000
+)
+
+type Alert struct {
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"`
+	IP       string    `json:"ip"`
+	MAC      string    `json:"mac"`
+	Previous string    `json:"previous"`
+	Message  string    `json:"message"`
+}
+
+type alertLog struct {
+	sync.Mutex
+	alerts []Alert
+	last   map[string]time.Time
+}
+
+func newAlertLog() *alertLog {
+	return &alertLog{
+		alerts: make([]Alert, 0),
+		last:   make(map[string]time.Time),
+	}
+}
+
+// Add returns false if the same alert has been raised recently.
+func (l *alertLog) Add(a Alert) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	key := a.Kind + a.IP + a.MAC
+	if last, found := l.last[key]; found && a.Time.Sub(last) < alertCooldown {
+		return false
+	}
+	l.last[key] = a.Time
+
+	l.alerts = append(l.alerts, a)
+	if len(l.alerts) > maxAlerts {
+		l.alerts = l.alerts[len(l.alerts)-maxAlerts:]
+	}
+	return true
+}
+
+func (l *alertLog) List() []Alert {
+	l.Lock()
+	defer l.Unlock()
+	return append([]Alert{}, l.alerts...)
+}
+
+func (l *alertLog) Clear() {
+	l.Lock()
+	defer l.Unlock()
+	l.alerts = make([]Alert, 0)
+	l.last = make(map[string]time.Time)
+}
+
+func (mod *ArpWatcher) raise(kind, ip, mac, previous string, format string, args ...interface{}) {
+	a := Alert{
+		Time:     time.Now(),
+		Kind:     kind,
+		IP:       ip,
+		MAC:      mac,
+		Previous: previous,
+		Message:  fmt.Sprintf(format, args...),
+	}
+
+	if !mod.alerts.Add(a) {
+		return
+	}
+
+	mod.State.Store("alerts", mod.alerts.List())
+	session.I.Events.Add("arp.watch."+kind, a)
+}
+
+func (mod *ArpWatcher) showAlerts() error {
+	alerts := mod.alerts.List()
+	if len(alerts) == 0 {
+		mod.Info("no alerts raised yet")
+		return nil
+	}
+
+	colNames := []string{"Time", "Kind", "IP", "MAC", "Previous MAC", "Details"}
+	rows := make([][]string, 0, len(alerts))
+	for _, a := range alerts {
+		kind := tui.Yellow(a.Kind)
+		if a.Kind == AlertGateway || a.Kind == AlertConflict {
+			kind = tui.Red(a.Kind)
+		}
+
+		rows = append(rows, []string{
+			a.Time.Format("15:04:05"),
+			kind,
+			tui.Bold(a.IP),
+			a.MAC,
+			tui.Dim(a.Previous),
+			a.Message,
+		})
+	}
+
+	tui.Table(mod.Session.Events.Stdout, colNames, rows)
+	mod.Session.Refresh()
+	return nil
+}
@@ -45,6 +45,8 @@ func NewBLERecon(s *session.Session) *BLERecon {
 		connected:     false,
 	}
 
+	mod.SetInterfaceIndependent(true)
+
 	mod.InitState("scanning")
 
 	mod.selector = utils.ViewSelectorFor(&mod.SessionModule,
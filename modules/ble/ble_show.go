@@ -3,6 +3,7 @@
 package ble
 
 import (
+	"fmt"
 	"sort"
 	"time"
 
@@ -32,6 +33,10 @@ func (mod *BLERecon) getRow(dev *network.BLEDevice, withName bool) []string {
 		address = tui.Dim(address)
 	}
 
+	if tags := mod.Session.Tags.String(dev.Device.ID()); tags != "" {
+		address = fmt.Sprintf("%s %s", address, tui.Dim("["+tags+"]"))
+	}
+
 	if withName {
 		return []string{
 			rssi,
@@ -60,7 +65,8 @@ func (mod *BLERecon) doFilter(dev *network.BLEDevice) bool {
 	}
 	return mod.selector.Expression.MatchString(dev.Device.ID()) ||
 		mod.selector.Expression.MatchString(dev.Device.Name()) ||
-		mod.selector.Expression.MatchString(dev.Vendor)
+		mod.selector.Expression.MatchString(dev.Vendor) ||
+		mod.selector.Expression.MatchString(mod.Session.Tags.String(dev.Device.ID()))
 }
 
 func (mod *BLERecon) doSelection() (devices []*network.BLEDevice, err error) {
@@ -15,6 +15,8 @@ func NewBLERecon(s *session.Session) *BLERecon {
 		SessionModule: session.NewSessionModule("ble.recon", s),
 	}
 
+	mod.SetInterfaceIndependent(true)
+
 	mod.AddHandler(session.NewModuleHandler("ble.recon on", "",
 		"Start Bluetooth Low Energy devices discovery.",
 		func(args []string) error {
@@ -67,6 +67,8 @@ func NewC2(s *session.Session) *C2 {
 		},
 	}
 
+	mod.SetInterfaceIndependent(true)
+
 	mod.AddParam(session.NewStringParameter("c2.server",
 		mod.settings.server,
 		"",
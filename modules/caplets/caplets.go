@@ -25,6 +25,8 @@ func NewCapletsModule(s *session.Session) *CapletsModule {
 		SessionModule: session.NewSessionModule("caplets", s),
 	}
 
+	mod.SetInterfaceIndependent(true)
+
 	mod.AddHandler(session.NewModuleHandler("caplets.show", "",
 		"Show a list of installed caplets.",
 		func(args []string) error {
@@ -0,0 +1,180 @@
+package creds
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/fs"
+	"github.com/evilsocket/islazy/tui"
+)
+
+type CredsModule struct {
+	session.SessionModule
+}
+
+func NewCredsModule(s *session.Session) *CredsModule {
+	mod := &CredsModule{
+		SessionModule: session.NewSessionModule("creds", s),
+	}
+
+	mod.SetInterfaceIndependent(true)
+
+	mod.AddHandler(session.NewModuleHandler("creds.show FILTER?", `creds\.show\s*(.*)`,
+		"Show the credentials captured so far, optionally only the ones with a field containing FILTER.",
+		func(args []string) error {
+			return mod.Show(strings.TrimSpace(args[0]))
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("creds.export FILENAME", `creds\.export\s+(.+)`,
+		"Export the credentials captured so far to FILENAME, as CSV if its extension is .csv, only the hashes one per line and ready for cracking if it's .txt, or as JSON otherwise.",
+		func(args []string) error {
+			return mod.Export(strings.TrimSpace(args[0]))
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("creds.clear", "",
+		"Clear the credentials captured so far.",
+		func(args []string) error {
+			mod.Session.Credentials.Clear()
+			return nil
+		}))
+
+	return mod
+}
+
+func (mod *CredsModule) Name() string {
+	return "creds"
+}
+
+func (mod *CredsModule) Description() string {
+	return "Collects the credentials, hashes and SNMP communities captured by the sniffer, the proxies and the scanners."
+}
+
+func (mod *CredsModule) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *CredsModule) Configure() error {
+	return nil
+}
+
+func (mod *CredsModule) Start() error {
+	return nil
+}
+
+func (mod *CredsModule) Stop() error {
+	return nil
+}
+
+func (mod *CredsModule) Show(filter string) error {
+	list := mod.Session.Credentials.List(filter)
+	if len(list) == 0 {
+		if filter != "" {
+			mod.Info("no credentials matching '%s'", filter)
+		} else {
+			mod.Info("no credentials captured yet")
+		}
+		return nil
+	}
+
+	colNames := []string{"Protocol", "Type", "Client", "Server", "Username", "Secret", "Source", "Hits", "Last Seen"}
+	rows := make([][]string, 0, len(list))
+	for _, cred := range list {
+		rows = append(rows, []string{
+			tui.Bold(cred.Protocol),
+			tui.Dim(cred.Type),
+			cred.Client,
+			tui.Yellow(cred.Server),
+			tui.Bold(cred.Username),
+			tui.Red(cred.Secret),
+			tui.Dim(cred.Source),
+			strconv.Itoa(cred.Hits),
+			cred.LastSeen.Format("15:04:05"),
+		})
+	}
+
+	tui.Table(mod.Session.Events.Stdout, colNames, rows)
+	mod.Session.Refresh()
+	return nil
+}
+
+func (mod *CredsModule) Export(filename string) error {
+	filename, err := fs.Expand(filename)
+	if err != nil {
+		return err
+	}
+
+	list := mod.Session.Credentials.List("")
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		err = exportCSV(filename, list)
+	case ".txt":
+		list, err = exportHashes(filename, list)
+	default:
+		err = exportJSON(filename, list)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error exporting to %s: %v", filename, err)
+	}
+
+	mod.Info("%d credentials exported to %s", len(list), tui.Yellow(filename))
+	return nil
+}
+
+func exportJSON(filename string, list []session.Credential) error {
+	raw, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, raw, 0600)
+}
+
+// exportHashes writes the hashes only, in the formats hashcat and john expect,
+// returning the exported credentials.
+func exportHashes(filename string, list []session.Credential) ([]session.Credential, error) {
+	hashes := make([]session.Credential, 0)
+	lines := ""
+	for _, cred := range list {
+		if cred.Type == session.CredentialHash {
+			hashes = append(hashes, cred)
+			lines += cred.Secret + "\n"
+		}
+	}
+	return hashes, ioutil.WriteFile(filename, []byte(lines), 0600)
+}
+
+func exportCSV(filename string, list []session.Credential) error {
+	fp, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	w := csv.NewWriter(fp)
+	w.Write([]string{"protocol", "type", "source", "client", "server", "username", "secret", "first_seen", "last_seen", "hits"})
+	for _, cred := range list {
+		w.Write([]string{
+			cred.Protocol,
+			cred.Type,
+			cred.Source,
+			cred.Client,
+			cred.Server,
+			cred.Username,
+			cred.Secret,
+			cred.FirstSeen.Format(time.RFC3339),
+			cred.LastSeen.Format(time.RFC3339),
+			strconv.Itoa(cred.Hits),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
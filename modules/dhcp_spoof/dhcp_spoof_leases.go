@@ -0,0 +1,165 @@
+package dhcp_spoof
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+type Lease struct {
+	MAC      string    `json:"mac"`
+	IP       string    `json:"ip"`
+	Hostname string    `json:"hostname"`
+	Assigned time.Time `json:"assigned"`
+	Expires  time.Time `json:"expires"`
+}
+
+func (l *Lease) Expired() bool {
+	return time.Now().After(l.Expires)
+}
+
+// must be called with the leases lock held.
+func (mod *DHCPSpoofer) leasedTo(ip net.IP) string {
+	for mac, lease := range mod.leases {
+		if lease.IP == ip.String() && !lease.Expired() {
+			return mac
+		}
+	}
+	return ""
+}
+
+// must be called with the leases lock held.
+func (mod *DHCPSpoofer) isAvailableUnlocked(ip net.IP, mac net.HardwareAddr) bool {
+	if !mod.isUsable(ip) {
+		return false
+	} else if owner := mod.leasedTo(ip); owner != "" && owner != mac.String() {
+		return false
+	} else if e := mod.Session.Lan.GetByIp(ip.String()); e != nil && !bytes.Equal(e.HW, mac) {
+		return false
+	}
+	return true
+}
+
+// isAvailable returns true if ip can be assigned to mac without stealing
+// the address of another client.
+func (mod *DHCPSpoofer) isAvailable(ip net.IP, mac net.HardwareAddr) bool {
+	mod.leasesLock.Lock()
+	defer mod.leasesLock.Unlock()
+
+	return mod.isAvailableUnlocked(ip, mac)
+}
+
+// leaseFor returns the address to offer to a client, preferring its current
+// lease, then the address it asked for or already has and finally a free
+// address of the pool.
+func (mod *DHCPSpoofer) leaseFor(mac net.HardwareAddr, requested net.IP) net.IP {
+	mod.leasesLock.Lock()
+	defer mod.leasesLock.Unlock()
+
+	if lease, found := mod.leases[mac.String()]; found && !lease.Expired() {
+		return net.ParseIP(lease.IP).To4()
+	} else if requested != nil && mod.isAvailableUnlocked(requested, mac) {
+		return requested.To4()
+	} else if e, found := mod.Session.Lan.Get(mac.String()); found && e.IP != nil && mod.isAvailableUnlocked(e.IP, mac) {
+		return e.IP.To4()
+	}
+
+	for _, ip := range mod.pool {
+		if mod.isAvailableUnlocked(ip, mac) {
+			return ip
+		}
+	}
+	return nil
+}
+
+func (mod *DHCPSpoofer) assign(mac net.HardwareAddr, ip net.IP, hostname string) Lease {
+	mod.leasesLock.Lock()
+	defer mod.leasesLock.Unlock()
+
+	now := time.Now()
+	lease, found := mod.leases[mac.String()]
+	if !found || lease.IP != ip.String() {
+		lease = &Lease{
+			MAC:      mac.String(),
+			IP:       ip.String(),
+			Assigned: now,
+		}
+		mod.leases[mac.String()] = lease
+	}
+
+	if hostname != "" {
+		lease.Hostname = hostname
+	}
+	lease.Expires = now.Add(mod.config.LeaseTime)
+
+	mod.updateState()
+
+	return *lease
+}
+
+func (mod *DHCPSpoofer) release(mac net.HardwareAddr) *Lease {
+	mod.leasesLock.Lock()
+	defer mod.leasesLock.Unlock()
+
+	lease, found := mod.leases[mac.String()]
+	if found {
+		delete(mod.leases, mac.String())
+		mod.updateState()
+	}
+	return lease
+}
+
+// must be called with the leases lock held.
+func (mod *DHCPSpoofer) updateState() {
+	leases := make([]*Lease, 0, len(mod.leases))
+	for _, lease := range mod.leases {
+		l := *lease
+		leases = append(leases, &l)
+	}
+
+	sort.Slice(leases, func(i, j int) bool {
+		return bytes.Compare(net.ParseIP(leases[i].IP), net.ParseIP(leases[j].IP)) < 0
+	})
+
+	mod.State.Store("leases", leases)
+}
+
+func (mod *DHCPSpoofer) showLeases() error {
+	mod.leasesLock.Lock()
+	mod.updateState()
+	mod.leasesLock.Unlock()
+
+	leases := []*Lease{}
+	if v, found := mod.State.Load("leases"); found {
+		leases = v.([]*Lease)
+	}
+
+	if len(leases) == 0 {
+		mod.Info("no leases assigned yet")
+		return nil
+	}
+
+	colNames := []string{"IP", "MAC", "Hostname", "Assigned", "Expires"}
+	rows := make([][]string, 0, len(leases))
+	for _, lease := range leases {
+		expires := lease.Expires.Format("15:04:05")
+		if lease.Expired() {
+			expires = tui.Dim(expires + " (expired)")
+		}
+
+		rows = append(rows, []string{
+			tui.Bold(lease.IP),
+			lease.MAC,
+			tui.Yellow(lease.Hostname),
+			lease.Assigned.Format("15:04:05"),
+			expires,
+		})
+	}
+
+	tui.Table(mod.Session.Events.Stdout, colNames, rows)
+	mod.Session.Refresh()
+	return nil
+}
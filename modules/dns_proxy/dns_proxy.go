@@ -0,0 +1,358 @@
+package dns_proxy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/firewall"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/miekg/dns"
+
+	"github.com/evilsocket/islazy/fs"
+	"github.com/evilsocket/islazy/tui"
+)
+
+type DNSProxy struct {
+	session.SessionModule
+	address      string
+	port         int
+	upstream     *upstream
+	rules        []*Rule
+	script       *DNSProxyScript
+	ttl          uint32
+	doRedirect   bool
+	redirections []*firewall.Redirection
+	servers      []*dns.Server
+	waitGroup    *sync.WaitGroup
+}
+
+func NewDNSProxy(s *session.Session) *DNSProxy {
+	mod := &DNSProxy{
+		SessionModule: session.NewSessionModule("dns.proxy", s),
+		rules:         make([]*Rule, 0),
+		redirections:  make([]*firewall.Redirection, 0),
+		servers:       make([]*dns.Server, 0),
+		waitGroup:     &sync.WaitGroup{},
+	}
+
+	mod.AddParam(session.NewStringParameter("dns.proxy.address",
+		session.ParamIfaceAddress,
+		session.IPv4Validator,
+		"Address to bind the DNS proxy to."))
+
+	mod.AddParam(session.NewIntParameter("dns.proxy.port",
+		"53",
+		"Port to bind the DNS proxy to."))
+
+	mod.AddParam(session.NewStringParameter("dns.proxy.upstream",
+		"udp://1.1.1.1:53",
+		"",
+		"Resolver to forward queries to, either udp://host:port, tcp://host:port or an https:// DNS-over-HTTPS endpoint."))
+
+	mod.AddParam(session.NewStringParameter("dns.proxy.rules",
+		"",
+		"",
+		"If not empty, a file of rules to block, rewrite, delay or log queries, one '<action> <pattern> [argument]' per line."))
+
+	mod.AddParam(session.NewStringParameter("dns.proxy.script",
+		"",
+		"",
+		"Path of a proxy JS script."))
+
+	mod.AddParam(session.NewBoolParameter("dns.proxy.redirect",
+		"true",
+		"Enable or disable port redirection with iptables."))
+
+	mod.AddParam(session.NewIntParameter("dns.proxy.timeout",
+		"5",
+		"Seconds to wait for the upstream resolver to answer."))
+
+	mod.AddParam(session.NewIntParameter("dns.proxy.ttl",
+		"60",
+		"TTL of the answers rewritten by rules or scripts."))
+
+	mod.AddHandler(session.NewModuleHandler("dns.proxy on", "",
+		"Start the DNS proxy.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("dns.proxy off", "",
+		"Stop the DNS proxy.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	return mod
+}
+
+func (mod DNSProxy) Name() string {
+	return "dns.proxy"
+}
+
+func (mod DNSProxy) Description() string {
+	return "A DNS proxy forwarding queries to an upstream resolver, which can block, rewrite, delay or log them with rules or JS scripts."
+}
+
+func (mod DNSProxy) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *DNSProxy) Configure() error {
+	var err error
+	var upstream string
+	var rulesFile string
+	var scriptPath string
+	var timeout int
+	var ttl int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, mod.address = mod.StringParam("dns.proxy.address"); err != nil {
+		return err
+	} else if err, mod.port = mod.IntParam("dns.proxy.port"); err != nil {
+		return err
+	} else if err, upstream = mod.StringParam("dns.proxy.upstream"); err != nil {
+		return err
+	} else if err, rulesFile = mod.StringParam("dns.proxy.rules"); err != nil {
+		return err
+	} else if err, scriptPath = mod.StringParam("dns.proxy.script"); err != nil {
+		return err
+	} else if err, mod.doRedirect = mod.BoolParam("dns.proxy.redirect"); err != nil {
+		return err
+	} else if err, timeout = mod.IntParam("dns.proxy.timeout"); err != nil {
+		return err
+	} else if timeout <= 0 {
+		return fmt.Errorf("dns.proxy.timeout must be greater than 0")
+	} else if err, ttl = mod.IntParam("dns.proxy.ttl"); err != nil {
+		return err
+	} else if ttl < 0 {
+		return fmt.Errorf("dns.proxy.ttl can't be negative")
+	} else if mod.upstream, err = newUpstream(upstream, time.Duration(timeout)*time.Second); err != nil {
+		return err
+	}
+
+	mod.ttl = uint32(ttl)
+
+	mod.rules = make([]*Rule, 0)
+	if rulesFile != "" {
+		if rulesFile, err = fs.Expand(rulesFile); err != nil {
+			return err
+		} else if mod.rules, err = RulesFromFile(rulesFile); err != nil {
+			return err
+		}
+		mod.Info("loaded %d rules from %s", len(mod.rules), rulesFile)
+	}
+
+	mod.script = nil
+	if scriptPath != "" {
+		if err, mod.script = LoadDNSProxyScript(scriptPath, mod.Session); err != nil {
+			return err
+		}
+		mod.Debug("proxy script %s loaded.", scriptPath)
+	}
+
+	bind := fmt.Sprintf("%s:%d", mod.address, mod.port)
+	mod.servers = make([]*dns.Server, 0)
+	for _, proto := range []string{"udp", "tcp"} {
+		mod.servers = append(mod.servers, &dns.Server{
+			Addr:    bind,
+			Net:     proto,
+			Handler: dns.HandlerFunc(mod.onQuery),
+		})
+	}
+
+	return nil
+}
+
+func (mod *DNSProxy) enableRedirections() error {
+	if !mod.Session.Firewall.IsForwardingEnabled() {
+		mod.Info("enabling forwarding.")
+		mod.Session.Firewall.EnableForwarding(true)
+	}
+
+	mod.redirections = make([]*firewall.Redirection, 0)
+	for _, proto := range []string{"UDP", "TCP"} {
+		r := firewall.NewRedirection(mod.Session.Interface.Name(),
+			proto,
+			53,
+			mod.address,
+			mod.port)
+
+		if err := mod.Session.Firewall.EnableRedirection(r, true); err != nil {
+			mod.disableRedirections()
+			return err
+		}
+		mod.redirections = append(mod.redirections, r)
+		mod.Debug("applied redirection %s", r.String())
+	}
+
+	return nil
+}
+
+func (mod *DNSProxy) disableRedirections() {
+	for _, r := range mod.redirections {
+		mod.Debug("disabling redirection %s", r.String())
+		if err := mod.Session.Firewall.EnableRedirection(r, false); err != nil {
+			mod.Warning("%s", err)
+		}
+	}
+	mod.redirections = make([]*firewall.Redirection, 0)
+}
+
+func (mod *DNSProxy) clientName(w dns.ResponseWriter) string {
+	clientIP := strings.Split(w.RemoteAddr().String(), ":")[0]
+	if e := mod.Session.Lan.GetByIp(clientIP); e != nil {
+		return e.String()
+	}
+	return clientIP
+}
+
+func (mod *DNSProxy) answerWith(req *dns.Msg, address net.IP) *dns.Msg {
+	res := new(dns.Msg)
+	res.SetReply(req)
+
+	q := req.Question[0]
+	hdr := dns.RR_Header{
+		Name:   q.Name,
+		Class:  dns.ClassINET,
+		Ttl:    mod.ttl,
+		Rrtype: q.Qtype,
+	}
+
+	if v4 := address.To4(); v4 != nil && q.Qtype == dns.TypeA {
+		res.Answer = append(res.Answer, &dns.A{Hdr: hdr, A: v4})
+	} else if v4 == nil && q.Qtype == dns.TypeAAAA {
+		res.Answer = append(res.Answer, &dns.AAAA{Hdr: hdr, AAAA: address})
+	}
+	// other types get an empty answer, so that clients fall back to the
+	// record type we can spoof.
+	return res
+}
+
+func (mod *DNSProxy) onQuery(w dns.ResponseWriter, req *dns.Msg) {
+	if len(req.Question) == 0 {
+		res := new(dns.Msg)
+		w.WriteMsg(res.SetRcode(req, dns.RcodeFormatError))
+		return
+	}
+
+	q := req.Question[0]
+	name := strings.ToLower(strings.TrimSuffix(q.Name, "."))
+	qtype := dns.TypeToString[q.Qtype]
+	client := mod.clientName(w)
+
+	v := applyRules(mod.rules, name)
+	if v.log {
+		mod.Info("%s is resolving %s (%s)", tui.Bold(client), tui.Yellow(name), qtype)
+	}
+
+	query := &JSQuery{
+		Client:  strings.Split(w.RemoteAddr().String(), ":")[0],
+		Name:    name,
+		Type:    qtype,
+		Answers: make([]string, 0),
+		Block:   v.block,
+	}
+	if v.address != nil {
+		query.Address = v.address.String()
+	}
+
+	if mod.script != nil {
+		mod.script.OnQuery(query)
+	}
+
+	if v.delay > 0 {
+		mod.Debug("delaying %s for %s by %s", name, client, v.delay)
+		time.Sleep(v.delay)
+	}
+
+	var res *dns.Msg
+	if query.Block {
+		mod.Info("blocking %s for %s", tui.Yellow(name), tui.Bold(client))
+		res = new(dns.Msg)
+		res.SetRcode(req, dns.RcodeNameError)
+	} else if query.Address != "" {
+		address := net.ParseIP(query.Address)
+		if address == nil {
+			mod.Error("invalid address '%s' for %s", query.Address, name)
+			res = new(dns.Msg)
+			res.SetRcode(req, dns.RcodeServerFailure)
+		} else {
+			mod.Info("sending rewritten %s (%s) to %s", tui.Yellow(name), tui.Red(address.String()), tui.Bold(client))
+			res = mod.answerWith(req, address)
+		}
+	} else {
+		var err error
+		if res, err = mod.upstream.Exchange(req); err != nil {
+			mod.Debug("error resolving %s upstream: %v", name, err)
+			res = new(dns.Msg)
+			res.SetRcode(req, dns.RcodeServerFailure)
+		} else if mod.script != nil {
+			for _, rr := range res.Answer {
+				query.Answers = append(query.Answers, rr.String())
+			}
+
+			mod.script.OnResponse(query)
+
+			if query.Block {
+				res = new(dns.Msg)
+				res.SetRcode(req, dns.RcodeNameError)
+			} else if address := net.ParseIP(query.Address); address != nil {
+				res = mod.answerWith(req, address)
+			}
+		}
+	}
+
+	res.Id = req.Id
+	if err := w.WriteMsg(res); err != nil {
+		mod.Debug("error sending answer to %s: %v", client, err)
+	}
+}
+
+func (mod *DNSProxy) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	if mod.doRedirect {
+		if err := mod.enableRedirections(); err != nil {
+			return err
+		}
+	} else {
+		mod.Warning("port redirection disabled, clients must be configured to use the proxy manually")
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.Info("started on %s:%d, forwarding to %s", mod.address, mod.port, tui.Bold(mod.upstream.String()))
+
+		for _, server := range mod.servers {
+			mod.waitGroup.Add(1)
+			go func(server *dns.Server) {
+				defer mod.waitGroup.Done()
+				if err := server.ListenAndServe(); err != nil {
+					mod.Error("%s server: %v", server.Net, err)
+				}
+			}(server)
+		}
+
+		mod.waitGroup.Wait()
+	})
+}
+
+func (mod *DNSProxy) Stop() error {
+	return mod.SetRunning(false, func() {
+		for _, server := range mod.servers {
+			server.Shutdown()
+		}
+		mod.waitGroup.Wait()
+
+		if mod.doRedirect {
+			mod.disableRedirections()
+		}
+	})
+}
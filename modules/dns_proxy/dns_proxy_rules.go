@@ -0,0 +1,136 @@
+package dns_proxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gobwas/glob"
+)
+
+const (
+	RuleBlock   = "block"
+	RuleRewrite = "rewrite"
+	RuleDelay   = "delay"
+	RuleLog     = "log"
+)
+
+// Rule is a line of the rules file:
+//
+//	block   <pattern>
+//	rewrite <pattern> <address>
+//	delay   <pattern> <duration>
+//	log     <pattern>
+//
+// Patterns are matched against the queried names and support wildcards.
+type Rule struct {
+	Action  string
+	Pattern string
+	Address net.IP
+	Delay   time.Duration
+	glob    glob.Glob
+}
+
+func (r *Rule) Matches(name string) bool {
+	return r.glob.Match(name)
+}
+
+func ParseRule(line string) (*Rule, error) {
+	parts := strings.Fields(line)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid rule '%s'", line)
+	}
+
+	rule := &Rule{
+		Action:  strings.ToLower(parts[0]),
+		Pattern: strings.ToLower(strings.TrimSuffix(parts[1], ".")),
+	}
+
+	var err error
+	if rule.glob, err = glob.Compile(rule.Pattern); err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s': %v", rule.Pattern, err)
+	}
+
+	switch rule.Action {
+	case RuleBlock, RuleLog:
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s rules don't take arguments: '%s'", rule.Action, line)
+		}
+	case RuleRewrite:
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("rewrite rules need an address: '%s'", line)
+		} else if rule.Address = net.ParseIP(parts[2]); rule.Address == nil {
+			return nil, fmt.Errorf("invalid address '%s'", parts[2])
+		}
+	case RuleDelay:
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("delay rules need a duration: '%s'", line)
+		} else if rule.Delay, err = time.ParseDuration(parts[2]); err != nil {
+			return nil, fmt.Errorf("invalid duration '%s': %v", parts[2], err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown action '%s'", rule.Action)
+	}
+
+	return rule, nil
+}
+
+func RulesFromFile(filename string) ([]*Rule, error) {
+	input, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	rules := make([]*Rule, 0)
+	scanner := bufio.NewScanner(input)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		rule, err := ParseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, lineno, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, scanner.Err()
+}
+
+// verdict is what the rules decided for a query, block and rewrite rules
+// are final while delays add up and logging is enabled by any rule.
+type verdict struct {
+	block   bool
+	address net.IP
+	delay   time.Duration
+	log     bool
+}
+
+func applyRules(rules []*Rule, name string) verdict {
+	v := verdict{}
+	for _, rule := range rules {
+		if !rule.Matches(name) {
+			continue
+		}
+
+		switch rule.Action {
+		case RuleBlock:
+			v.block = true
+			return v
+		case RuleRewrite:
+			v.address = rule.Address
+			return v
+		case RuleDelay:
+			v.delay += rule.Delay
+		case RuleLog:
+			v.log = true
+		}
+	}
+	return v
+}
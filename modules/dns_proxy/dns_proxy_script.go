@@ -0,0 +1,79 @@
+package dns_proxy
+
+import (
+	"github.com/bettercap/bettercap/js"
+	"github.com/bettercap/bettercap/log"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/plugin"
+
+	"github.com/robertkrimen/otto"
+)
+
+// JSQuery is the object passed to the script callbacks, setting Block or
+// Address changes the answer sent to the client.
+type JSQuery struct {
+	Client  string
+	Name    string
+	Type    string
+	Answers []string
+	Block   bool
+	Address string
+}
+
+type DNSProxyScript struct {
+	*plugin.Plugin
+	doOnQuery    bool
+	doOnResponse bool
+}
+
+func LoadDNSProxyScript(path string, sess *session.Session) (err error, s *DNSProxyScript) {
+	log.Info("loading dns proxy script %s ...", path)
+
+	plug, err := plugin.Load(path)
+	if err != nil {
+		return
+	}
+
+	// define session pointer
+	if err = plug.Set("env", sess.Env.Data); err != nil {
+		log.Error("error while defining environment: %+v", err)
+		return
+	}
+
+	if err = js.SetHTTPPackage(plug); err != nil {
+		log.Error("error while defining http: %+v", err)
+		return
+	}
+
+	// run onLoad if defined
+	if plug.HasFunc("onLoad") {
+		if _, err = plug.Call("onLoad"); err != nil {
+			log.Error("error while executing onLoad callback: %s", "\ntraceback:\n  "+err.(*otto.Error).String())
+			return
+		}
+	}
+
+	s = &DNSProxyScript{
+		Plugin:       plug,
+		doOnQuery:    plug.HasFunc("onQuery"),
+		doOnResponse: plug.HasFunc("onResponse"),
+	}
+	return
+}
+
+func (s *DNSProxyScript) OnQuery(q *JSQuery) {
+	if s.doOnQuery {
+		if _, err := s.Call("onQuery", q); err != nil {
+			log.Error("error while executing onQuery callback: %s", err)
+		}
+	}
+}
+
+func (s *DNSProxyScript) OnResponse(q *JSQuery) {
+	if s.doOnResponse {
+		if _, err := s.Call("onResponse", q); err != nil {
+			log.Error("error while executing onResponse callback: %s", err)
+		}
+	}
+}
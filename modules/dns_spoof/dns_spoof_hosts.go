@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/gobwas/glob"
+	"github.com/google/gopacket/layers"
 
 	"github.com/evilsocket/islazy/str"
 )
@@ -15,10 +16,11 @@ import (
 var hostsSplitter = regexp.MustCompile(`\s+`)
 
 type HostEntry struct {
-	Host    string
-	Suffix  string
-	Expr    glob.Glob
-	Address net.IP
+	Host     string
+	Suffix   string
+	Expr     glob.Glob
+	Address  net.IP
+	Address6 net.IP
 }
 
 func (e HostEntry) Matches(host string) bool {
@@ -30,8 +32,13 @@ type Hosts []HostEntry
 
 func NewHostEntry(host string, address net.IP) HostEntry {
 	entry := HostEntry{
-		Host:    host,
-		Address: address,
+		Host: host,
+	}
+
+	if address != nil && address.To4() == nil {
+		entry.Address6 = address
+	} else {
+		entry.Address = address
 	}
 
 	if host[0] == '.' {
@@ -47,7 +54,7 @@ func NewHostEntry(host string, address net.IP) HostEntry {
 	return entry
 }
 
-func HostsFromFile(filename string, defaultAddress net.IP) (err error, entries []HostEntry) {
+func HostsFromFile(filename string, defaultAddress, defaultAddress6 net.IP) (err error, entries []HostEntry) {
 	input, err := os.Open(filename)
 	if err != nil {
 		return
@@ -57,26 +64,45 @@ func HostsFromFile(filename string, defaultAddress net.IP) (err error, entries [
 	scanner := bufio.NewScanner(input)
 	scanner.Split(bufio.ScanLines)
 	for scanner.Scan() {
-		line := str.Trim(scanner.Text())
-		if line == "" || line[0] == '#' {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx != -1 {
+			line = line[:idx]
+		}
+		if line = str.Trim(line); line == "" {
 			continue
 		}
-		if parts := hostsSplitter.Split(line, 2); len(parts) == 2 {
-			address := net.ParseIP(parts[0])
-			domain := parts[1]
-			entries = append(entries, NewHostEntry(domain, address))
+
+		// address followed by one or more domains, or domains only
+		parts := hostsSplitter.Split(line, -1)
+		if address := net.ParseIP(parts[0]); address != nil {
+			for _, domain := range parts[1:] {
+				entries = append(entries, NewHostEntry(domain, address))
+			}
 		} else {
-			entries = append(entries, NewHostEntry(line, defaultAddress))
+			for _, domain := range parts {
+				entry := NewHostEntry(domain, defaultAddress)
+				entry.Address6 = defaultAddress6
+				entries = append(entries, entry)
+			}
 		}
 	}
 
+	err = scanner.Err()
 	return
 }
 
-func (h Hosts) Resolve(host string) net.IP {
+// Resolve returns the address host must be mapped to for a query of the
+// given type, AAAA queries are only answered if an IPv6 address is set.
+func (h Hosts) Resolve(host string, qType layers.DNSType) net.IP {
 	for _, entry := range h {
 		if entry.Matches(host) {
-			return entry.Address
+			if qType == layers.DNSTypeAAAA {
+				if entry.Address6 != nil {
+					return entry.Address6
+				}
+			} else if entry.Address != nil {
+				return entry.Address
+			}
 		}
 	}
 	return nil
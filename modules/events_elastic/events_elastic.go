@@ -0,0 +1,273 @@
+package events_elastic
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/str"
+)
+
+const (
+	requestTimeout = 30 * time.Second
+	// how many batches and bytes are kept while the cluster can't be reached
+	maxPendingBatches = 10
+	maxPendingBytes   = 64 * 1024 * 1024
+)
+
+type EventsElastic struct {
+	session.SessionModule
+	url           string
+	username      string
+	password      string
+	apiKey        string
+	index         string
+	stateIndex    string
+	filter        *session.EventFilter
+	batchSize     int
+	flushInterval time.Duration
+	stateInterval time.Duration
+	sensor        string
+	client        *http.Client
+	pending       []bulkItem
+	pendingBytes  int
+	pendingLock   sync.Mutex
+	flushes       chan bool
+	quit          chan bool
+}
+
+func NewEventsElastic(s *session.Session) *EventsElastic {
+	mod := &EventsElastic{
+		SessionModule: session.NewSessionModule("events.elastic", s),
+		pending:       make([]bulkItem, 0),
+		quit:          make(chan bool),
+	}
+
+	mod.SetInterfaceIndependent(true)
+
+	mod.AddParam(session.NewStringParameter("events.elastic.url",
+		"http://127.0.0.1:9200",
+		"",
+		"URL of the Elasticsearch or OpenSearch cluster."))
+
+	mod.AddParam(session.NewStringParameter("events.elastic.username",
+		"",
+		"",
+		"Username to authenticate to the cluster with, if any."))
+
+	mod.AddParam(session.NewStringParameter("events.elastic.password",
+		"",
+		"",
+		"Password to authenticate to the cluster with, if any."))
+
+	mod.AddParam(session.NewStringParameter("events.elastic.api_key",
+		"",
+		"",
+		"Base64 encoded API key to authenticate to the cluster with, used instead of the username and password if set."))
+
+	mod.AddParam(session.NewBoolParameter("events.elastic.tls.insecure",
+		"false",
+		"If true, don't verify the TLS certificate of the cluster."))
+
+	mod.AddParam(session.NewStringParameter("events.elastic.index",
+		"bettercap-events-{date}",
+		"",
+		"Index the events are stored in, {date} is replaced with the day of the event like 2006.01.02."))
+
+	mod.AddParam(session.NewStringParameter("events.elastic.state.index",
+		"bettercap-state-{date}",
+		"",
+		"Index the snapshots of the endpoints, access points and BLE devices are stored in, {date} is replaced with the day of the snapshot."))
+
+	mod.AddParam(session.NewIntParameter("events.elastic.state.interval",
+		"60",
+		"Seconds between two snapshots of the endpoints, access points and BLE devices, 0 to only index the events."))
+
+	mod.AddParam(session.NewStringParameter("events.elastic.tags",
+		"",
+		"",
+		"Comma separated list of tag patterns like wifi.* of the events to index, empty for all."))
+
+	mod.AddParam(session.NewStringParameter("events.elastic.level",
+		"info",
+		"",
+		"Minimum severity of the events to index among debug, info, important, warning, error and fatal, events other than sys.log ones are info."))
+
+	mod.AddParam(session.NewIntParameter("events.elastic.batch.size",
+		"500",
+		"Maximum number of documents indexed with a single bulk request."))
+
+	mod.AddParam(session.NewIntParameter("events.elastic.flush",
+		"5",
+		"Seconds to wait for a batch to fill up before indexing it anyway."))
+
+	mod.AddHandler(session.NewModuleHandler("events.elastic on", "",
+		"Start indexing the events in Elasticsearch or OpenSearch.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("events.elastic off", "",
+		"Stop indexing the events in Elasticsearch or OpenSearch.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	return mod
+}
+
+func (mod *EventsElastic) Name() string {
+	return "events.elastic"
+}
+
+func (mod *EventsElastic) Description() string {
+	return "Bulk indexes the selected events and periodic snapshots of the endpoints, access points and BLE devices in Elasticsearch or OpenSearch."
+}
+
+func (mod *EventsElastic) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *EventsElastic) Configure() (err error) {
+	var tags, level string
+	var insecure bool
+	var flush, interval int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, mod.url = mod.StringParam("events.elastic.url"); err != nil {
+		return err
+	} else if err, mod.username = mod.StringParam("events.elastic.username"); err != nil {
+		return err
+	} else if err, mod.password = mod.StringParam("events.elastic.password"); err != nil {
+		return err
+	} else if err, mod.apiKey = mod.StringParam("events.elastic.api_key"); err != nil {
+		return err
+	} else if err, insecure = mod.BoolParam("events.elastic.tls.insecure"); err != nil {
+		return err
+	} else if err, mod.index = mod.StringParam("events.elastic.index"); err != nil {
+		return err
+	} else if err, mod.stateIndex = mod.StringParam("events.elastic.state.index"); err != nil {
+		return err
+	} else if err, interval = mod.IntParam("events.elastic.state.interval"); err != nil {
+		return err
+	} else if err, tags = mod.StringParam("events.elastic.tags"); err != nil {
+		return err
+	} else if err, level = mod.StringParam("events.elastic.level"); err != nil {
+		return err
+	} else if err, mod.batchSize = mod.IntParam("events.elastic.batch.size"); err != nil {
+		return err
+	} else if err, flush = mod.IntParam("events.elastic.flush"); err != nil {
+		return err
+	} else if mod.filter, err = session.NewEventFilter(str.Comma(tags), level); err != nil {
+		return err
+	}
+
+	mod.url = strings.TrimRight(mod.url, "/")
+	if parsed, err := url.Parse(mod.url); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("'%s' is not a valid cluster URL", mod.url)
+	}
+
+	for _, index := range []string{mod.index, mod.stateIndex} {
+		if index == "" || strings.ToLower(index) != index || strings.ContainsAny(index, ` "*\<|,>/?#`) {
+			return fmt.Errorf("'%s' is not a valid index name", index)
+		}
+	}
+
+	if mod.batchSize < 1 {
+		mod.batchSize = 1
+	}
+	if flush < 1 {
+		flush = 1
+	}
+	mod.flushInterval = time.Duration(flush) * time.Second
+	mod.stateInterval = time.Duration(interval) * time.Second
+
+	if mod.sensor, err = os.Hostname(); err != nil {
+		mod.sensor = "bettercap"
+	}
+
+	mod.client = &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+		},
+	}
+	mod.pending = make([]bulkItem, 0)
+	mod.pendingBytes = 0
+
+	// install the templates now to report a wrong configuration right away
+	return mod.putTemplates()
+}
+
+func (mod *EventsElastic) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.Info("indexing events in %s/%s", mod.url, mod.index)
+
+		listener := mod.Session.Events.Listen()
+		defer mod.Session.Events.Unlisten(listener)
+
+		// a pending signal is enough for the flusher to catch up
+		mod.flushes = make(chan bool, 1)
+		flusherDone := make(chan struct{})
+		go mod.flusher(flusherDone)
+
+		signal := func() {
+			select {
+			case mod.flushes <- true:
+			default:
+			}
+		}
+
+		flushTicker := time.NewTicker(mod.flushInterval)
+		defer flushTicker.Stop()
+
+		// a nil channel never fires if the snapshots are disabled
+		var snapshots <-chan time.Time
+		if mod.stateInterval > 0 {
+			stateTicker := time.NewTicker(mod.stateInterval)
+			defer stateTicker.Stop()
+			snapshots = stateTicker.C
+			mod.queue(mod.snapshot()...)
+		}
+
+		for {
+			select {
+			case e := <-listener:
+				if mod.Session.EventsIgnoreList.Ignored(e) || session.LoggedBy(e, mod.Name()) || !mod.filter.Matches(e) {
+					continue
+				}
+				if mod.queue(mod.eventItem(e)) >= mod.batchSize {
+					signal()
+				}
+			case <-snapshots:
+				mod.queue(mod.snapshot()...)
+			case <-flushTicker.C:
+				signal()
+			case <-mod.quit:
+				close(mod.flushes)
+				<-flusherDone
+				mod.flush()
+				return
+			}
+		}
+	})
+}
+
+func (mod *EventsElastic) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.quit <- true
+	})
+}
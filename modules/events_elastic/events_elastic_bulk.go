@@ -0,0 +1,313 @@
+package events_elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/acarl005/stripansi"
+	"github.com/evilsocket/islazy/log"
+)
+
+// bulkItem is a document encoded when queued, so that the data of the
+// events can't change before it's indexed.
+type bulkItem struct {
+	index string
+	doc   []byte
+}
+
+// bulkResponse is the part of the response of the _bulk API we care about.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// indexName returns the name of the index for a document of t.
+func indexName(index string, t time.Time) string {
+	return strings.ReplaceAll(index, "{date}", t.UTC().Format("2006.01.02"))
+}
+
+// template returns the name and the body of the index template for index.
+func template(index string, properties map[string]interface{}) (string, map[string]interface{}) {
+	name := strings.Trim(strings.ReplaceAll(index, "{date}", ""), "-_.")
+	if name == "" {
+		name = "bettercap"
+	}
+
+	properties["@timestamp"] = map[string]string{"type": "date"}
+	properties["sensor"] = map[string]string{"type": "keyword"}
+
+	return name, map[string]interface{}{
+		"index_patterns": []string{strings.ReplaceAll(index, "{date}", "*")},
+		"template": map[string]interface{}{
+			"settings": map[string]interface{}{
+				// the data of some events has a lot of fields
+				"index.mapping.total_fields.limit": 5000,
+			},
+			"mappings": map[string]interface{}{
+				"dynamic_templates": []interface{}{
+					map[string]interface{}{
+						"strings": map[string]interface{}{
+							"match_mapping_type": "string",
+							"mapping": map[string]interface{}{
+								"type":         "keyword",
+								"ignore_above": 1024,
+							},
+						},
+					},
+				},
+				"properties": properties,
+			},
+		},
+	}
+}
+
+func (mod *EventsElastic) request(method string, path string, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, mod.url+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	if mod.apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+mod.apiKey)
+	} else if mod.username != "" {
+		req.SetBasicAuth(mod.username, mod.password)
+	}
+
+	res, err := mod.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	raw, err := ioutil.ReadAll(io.LimitReader(res.Body, 10*1024*1024))
+	if err != nil {
+		return nil, err
+	} else if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %s: %s", res.Status, strings.TrimSpace(string(raw)))
+	}
+	return raw, nil
+}
+
+// putTemplates installs the index templates mapping the common fields of
+// the events and of the snapshots.
+func (mod *EventsElastic) putTemplates() error {
+	templates := map[string]map[string]interface{}{
+		mod.index: {
+			"tag":     map[string]string{"type": "keyword"},
+			"level":   map[string]string{"type": "keyword"},
+			"message": map[string]string{"type": "text"},
+		},
+		mod.stateIndex: {
+			"kind": map[string]string{"type": "keyword"},
+		},
+	}
+
+	for index, properties := range templates {
+		name, tpl := template(index, properties)
+		body, err := json.Marshal(tpl)
+		if err != nil {
+			return err
+		} else if _, err = mod.request("PUT", "/_index_template/"+name, "application/json", body); err != nil {
+			return fmt.Errorf("error installing the index template %s: %v", name, err)
+		}
+		mod.Debug("installed the index template %s", name)
+	}
+	return nil
+}
+
+// eventItem returns the document of e, the data is stored in a field named
+// after the tag so that events with different data don't conflict.
+func (mod *EventsElastic) eventItem(e session.Event) bulkItem {
+	doc := map[string]interface{}{
+		"@timestamp": e.Time.UTC().Format(time.RFC3339Nano),
+		"sensor":     mod.sensor,
+		"tag":        e.Tag,
+		"level":      log.LevelName(session.EventLevel(e)),
+	}
+
+	if m, ok := e.Data.(session.LogMessage); ok {
+		doc["message"] = stripansi.Strip(m.Message)
+	} else if e.Data != nil {
+		doc[strings.ReplaceAll(e.Tag, ".", "_")] = e.Data
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		mod.Debug("error encoding %s event: %v", e.Tag, err)
+		return bulkItem{}
+	}
+	return bulkItem{index: indexName(mod.index, e.Time), doc: raw}
+}
+
+func (mod *EventsElastic) stateItem(now time.Time, kind string, data interface{}) bulkItem {
+	raw, err := json.Marshal(map[string]interface{}{
+		"@timestamp": now.UTC().Format(time.RFC3339Nano),
+		"sensor":     mod.sensor,
+		"kind":       kind,
+		kind:         data,
+	})
+	if err != nil {
+		mod.Debug("error encoding %s: %v", kind, err)
+		return bulkItem{}
+	}
+	return bulkItem{index: indexName(mod.stateIndex, now), doc: raw}
+}
+
+// snapshot returns one document for every endpoint, access point and BLE
+// device currently known.
+func (mod *EventsElastic) snapshot() []bulkItem {
+	now := time.Now()
+	items := make([]bulkItem, 0)
+
+	if mod.Session.Lan != nil {
+		for _, e := range mod.Session.Lan.List() {
+			items = append(items, mod.stateItem(now, "endpoint", e))
+		}
+	}
+
+	if mod.Session.WiFi != nil {
+		for _, ap := range mod.Session.WiFi.List() {
+			items = append(items, mod.stateItem(now, "ap", ap))
+		}
+	}
+
+	if mod.Session.BLE != nil {
+		mod.Session.BLE.EachDevice(func(mac string, dev *network.BLEDevice) {
+			items = append(items, mod.stateItem(now, "ble", dev))
+		})
+	}
+
+	return items
+}
+
+// queue adds items to the pending ones and returns how many are pending.
+func (mod *EventsElastic) queue(items ...bulkItem) int {
+	mod.pendingLock.Lock()
+	defer mod.pendingLock.Unlock()
+
+	for _, item := range items {
+		if item.doc != nil {
+			mod.pending = append(mod.pending, item)
+			mod.pendingBytes += len(item.doc)
+		}
+	}
+	mod.trim()
+
+	return len(mod.pending)
+}
+
+// trim drops the oldest pending documents if the cluster has been
+// unreachable for too long, it must be called with the lock held.
+func (mod *EventsElastic) trim() {
+	limit, dropped := mod.batchSize*maxPendingBatches, 0
+	for len(mod.pending) > 0 && (len(mod.pending) > limit || mod.pendingBytes > maxPendingBytes) {
+		mod.pendingBytes -= len(mod.pending[0].doc)
+		mod.pending = mod.pending[1:]
+		dropped++
+	}
+
+	if dropped > 0 {
+		mod.Warning("dropping %d documents that could not be indexed", dropped)
+	}
+}
+
+// bulk indexes a batch, documents rejected by the cluster are not retried
+// since they would be rejected again.
+func (mod *EventsElastic) bulk(batch []bulkItem) error {
+	var body bytes.Buffer
+	for _, item := range batch {
+		action, _ := json.Marshal(map[string]interface{}{
+			"index": map[string]string{"_index": item.index},
+		})
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(item.doc)
+		body.WriteByte('\n')
+	}
+
+	raw, err := mod.request("POST", "/_bulk", "application/x-ndjson", body.Bytes())
+	if err != nil {
+		return err
+	}
+
+	res := bulkResponse{}
+	if err = json.Unmarshal(raw, &res); err != nil {
+		return fmt.Errorf("error decoding the bulk response: %v", err)
+	} else if res.Errors {
+		failed, reason := 0, ""
+		for _, item := range res.Items {
+			for _, result := range item {
+				if result.Status < 200 || result.Status > 299 {
+					if failed++; reason == "" {
+						reason = fmt.Sprintf("%s: %s", result.Error.Type, result.Error.Reason)
+					}
+				}
+			}
+		}
+		mod.Warning("%d of %d documents have been rejected: %s", failed, len(batch), reason)
+	}
+
+	mod.Debug("indexed %d documents", len(batch))
+	return nil
+}
+
+// flush indexes the pending documents, keeping them for the next flush if
+// the cluster can't be reached.
+func (mod *EventsElastic) flush() {
+	for {
+		mod.pendingLock.Lock()
+		n := mod.batchSize
+		if n > len(mod.pending) {
+			n = len(mod.pending)
+		}
+		if n == 0 {
+			mod.pendingLock.Unlock()
+			return
+		}
+
+		batch, size := mod.pending[:n:n], 0
+		for _, item := range batch {
+			size += len(item.doc)
+		}
+		mod.pending = mod.pending[n:]
+		mod.pendingBytes -= size
+		mod.pendingLock.Unlock()
+
+		if err := mod.bulk(batch); err != nil {
+			mod.Warning("error indexing %d documents, will retry: %v", n, err)
+
+			// put them back in front of the ones queued in the meantime
+			mod.pendingLock.Lock()
+			mod.pending = append(batch, mod.pending...)
+			mod.pendingBytes += size
+			mod.trim()
+			mod.pendingLock.Unlock()
+			return
+		}
+	}
+}
+
+// flusher indexes the pending documents every time it's signaled on
+// mod.flushes, so that the requests don't stall the events loop.
+func (mod *EventsElastic) flusher(done chan struct{}) {
+	defer close(done)
+	for range mod.flushes {
+		mod.flush()
+	}
+}
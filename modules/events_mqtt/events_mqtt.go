@@ -0,0 +1,312 @@
+package events_mqtt
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/str"
+)
+
+const (
+	// the CONNECT packet asks for a 60 seconds keep alive
+	pingInterval   = 30 * time.Second
+	connectTimeout = 10 * time.Second
+	// how long to wait before connecting again after a failure
+	reconnectWait = 10 * time.Second
+)
+
+var topicEscaper = strings.NewReplacer("/", "_", "+", "_", "#", "_")
+
+type EventsMQTT struct {
+	session.SessionModule
+	address   string
+	useTLS    bool
+	insecure  bool
+	username  string
+	password  string
+	clientID  string
+	topic     string
+	retain    bool
+	hostname  string
+	filter    *session.EventFilter
+	conn      net.Conn
+	lastRetry time.Time
+	dropped   uint64
+	quit      chan bool
+}
+
+func NewEventsMQTT(s *session.Session) *EventsMQTT {
+	mod := &EventsMQTT{
+		SessionModule: session.NewSessionModule("events.mqtt", s),
+		quit:          make(chan bool),
+	}
+
+	mod.SetInterfaceIndependent(true)
+
+	mod.AddParam(session.NewStringParameter("events.mqtt.address",
+		fmt.Sprintf("127.0.0.1:%d", packets.MQTTPort),
+		"",
+		"Address and port of the MQTT broker."))
+
+	mod.AddParam(session.NewBoolParameter("events.mqtt.tls",
+		"false",
+		"If true, connect to the broker over TLS."))
+
+	mod.AddParam(session.NewBoolParameter("events.mqtt.tls.insecure",
+		"false",
+		"If true, don't verify the TLS certificate of the broker."))
+
+	mod.AddParam(session.NewStringParameter("events.mqtt.username",
+		"",
+		"",
+		"Username to authenticate to the broker with, if any."))
+
+	mod.AddParam(session.NewStringParameter("events.mqtt.password",
+		"",
+		"",
+		"Password to authenticate to the broker with, if any."))
+
+	mod.AddParam(session.NewStringParameter("events.mqtt.client_id",
+		"bettercap",
+		"",
+		"Client identifier to connect to the broker with."))
+
+	mod.AddParam(session.NewStringParameter("events.mqtt.topic",
+		"bettercap/{hostname}/{tag}",
+		"",
+		"Topic the events are published to, {hostname} is replaced with the name of this host and {tag} with the tag of the event, with slashes instead of dots like wifi/ap/new."))
+
+	mod.AddParam(session.NewBoolParameter("events.mqtt.retain",
+		"false",
+		"If true, the broker will keep the last event of each topic for the new subscribers."))
+
+	mod.AddParam(session.NewStringParameter("events.mqtt.tags",
+		"",
+		"",
+		"Comma separated list of tag patterns like wifi.* of the events to publish, empty for all."))
+
+	mod.AddParam(session.NewStringParameter("events.mqtt.level",
+		"info",
+		"",
+		"Minimum severity of the events to publish among debug, info, important, warning, error and fatal, events other than sys.log ones are info."))
+
+	mod.AddHandler(session.NewModuleHandler("events.mqtt on", "",
+		"Start publishing the events to the MQTT broker.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("events.mqtt off", "",
+		"Stop publishing the events to the MQTT broker.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	return mod
+}
+
+func (mod *EventsMQTT) Name() string {
+	return "events.mqtt"
+}
+
+func (mod *EventsMQTT) Description() string {
+	return "Publishes the selected events to an MQTT broker, one topic per event tag."
+}
+
+func (mod *EventsMQTT) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *EventsMQTT) Configure() (err error) {
+	var tags, level string
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, mod.address = mod.StringParam("events.mqtt.address"); err != nil {
+		return err
+	} else if err, mod.useTLS = mod.BoolParam("events.mqtt.tls"); err != nil {
+		return err
+	} else if err, mod.insecure = mod.BoolParam("events.mqtt.tls.insecure"); err != nil {
+		return err
+	} else if err, mod.username = mod.StringParam("events.mqtt.username"); err != nil {
+		return err
+	} else if err, mod.password = mod.StringParam("events.mqtt.password"); err != nil {
+		return err
+	} else if err, mod.clientID = mod.StringParam("events.mqtt.client_id"); err != nil {
+		return err
+	} else if err, mod.topic = mod.StringParam("events.mqtt.topic"); err != nil {
+		return err
+	} else if err, mod.retain = mod.BoolParam("events.mqtt.retain"); err != nil {
+		return err
+	} else if err, tags = mod.StringParam("events.mqtt.tags"); err != nil {
+		return err
+	} else if err, level = mod.StringParam("events.mqtt.level"); err != nil {
+		return err
+	} else if mod.filter, err = session.NewEventFilter(str.Comma(tags), level); err != nil {
+		return err
+	} else if _, _, err = net.SplitHostPort(mod.address); err != nil {
+		return fmt.Errorf("'%s' is not a valid broker address: %v", mod.address, err)
+	} else if strings.ContainsAny(mod.topic, "+#") {
+		return fmt.Errorf("the topic can't contain wildcards")
+	}
+
+	if mod.hostname, err = os.Hostname(); err != nil {
+		mod.hostname = "bettercap"
+	}
+	mod.hostname = topicEscaper.Replace(mod.hostname)
+
+	// connect now to report a wrong configuration right away
+	return mod.connect()
+}
+
+// eventTopic returns the topic e is published to.
+func (mod *EventsMQTT) eventTopic(e session.Event) string {
+	tag := topicEscaper.Replace(e.Tag)
+	tag = strings.ReplaceAll(tag, ".", "/")
+	return strings.NewReplacer("{hostname}", mod.hostname, "{tag}", tag).Replace(mod.topic)
+}
+
+func (mod *EventsMQTT) connect() error {
+	mod.lastRetry = time.Now()
+
+	dialer := &net.Dialer{Timeout: connectTimeout}
+	var conn net.Conn
+	var err error
+	if mod.useTLS {
+		host, _, _ := net.SplitHostPort(mod.address)
+		conn, err = tls.DialWithDialer(dialer, "tcp", mod.address, &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: mod.insecure,
+		})
+	} else {
+		conn, err = dialer.Dial("tcp", mod.address)
+	}
+	if err != nil {
+		return err
+	}
+
+	conn.SetDeadline(time.Now().Add(connectTimeout))
+	if _, err = conn.Write(packets.NewMQTTConnect(mod.clientID, mod.username, mod.password)); err != nil {
+		conn.Close()
+		return err
+	}
+
+	res := make([]byte, 4)
+	if _, err = io.ReadFull(conn, res); err != nil {
+		conn.Close()
+		return err
+	} else if code, err := packets.ParseMQTTConnAck(res); err != nil {
+		conn.Close()
+		return err
+	} else if code != packets.MQTTConnAccepted {
+		conn.Close()
+		return fmt.Errorf("connection refused by %s: %s", mod.address, packets.MQTTConnAckMessage(code))
+	}
+	conn.SetDeadline(time.Time{})
+
+	// nothing we care about is sent back at QoS 0, just PINGRESPs
+	go io.Copy(ioutil.Discard, conn)
+
+	mod.conn = conn
+	mod.Debug("connected to %s", mod.address)
+	return nil
+}
+
+func (mod *EventsMQTT) disconnect() {
+	if mod.conn != nil {
+		mod.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		mod.conn.Write(packets.NewMQTTDisconnect())
+		mod.conn.Close()
+		mod.conn = nil
+	}
+}
+
+// write sends raw to the broker, connecting again if needed but not more
+// than once every reconnectWait.
+func (mod *EventsMQTT) write(raw []byte) error {
+	if mod.conn == nil {
+		if time.Since(mod.lastRetry) < reconnectWait {
+			return fmt.Errorf("not connected")
+		} else if err := mod.connect(); err != nil {
+			return err
+		}
+		if mod.dropped > 0 {
+			mod.Warning("connected again to %s, %d events have been dropped", mod.address, mod.dropped)
+			mod.dropped = 0
+		}
+	}
+
+	mod.conn.SetWriteDeadline(time.Now().Add(connectTimeout))
+	if _, err := mod.conn.Write(raw); err != nil {
+		mod.conn.Close()
+		mod.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (mod *EventsMQTT) publish(e session.Event) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		mod.Debug("error encoding %s event: %v", e.Tag, err)
+		return
+	}
+
+	wasConnected := mod.conn != nil
+	if err = mod.write(packets.NewMQTTPublish(mod.eventTopic(e), payload, mod.retain)); err != nil {
+		if wasConnected {
+			mod.Warning("lost connection to %s: %v", mod.address, err)
+		}
+		mod.dropped++
+	}
+}
+
+func (mod *EventsMQTT) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.Info("publishing events to %s as %s", mod.address, mod.topic)
+
+		listener := mod.Session.Events.Listen()
+		defer mod.Session.Events.Unlisten(listener)
+
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case e := <-listener:
+				if !mod.Session.EventsIgnoreList.Ignored(e) && !session.LoggedBy(e, mod.Name()) && mod.filter.Matches(e) {
+					mod.publish(e)
+				}
+			case <-ticker.C:
+				if mod.conn != nil {
+					if err := mod.write(packets.NewMQTTPingReq()); err != nil {
+						mod.Warning("lost connection to %s: %v", mod.address, err)
+					}
+				}
+			case <-mod.quit:
+				mod.disconnect()
+				return
+			}
+		}
+	})
+}
+
+func (mod *EventsMQTT) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.quit <- true
+	})
+}
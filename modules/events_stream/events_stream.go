@@ -38,6 +38,7 @@ type EventsStream struct {
 	dumpHttpReqs  bool
 	dumpHttpResp  bool
 	dumpFormatHex bool
+	syslog        *syslogSink
 }
 
 func NewEventsStream(s *session.Session) *EventsStream {
@@ -51,6 +52,8 @@ func NewEventsStream(s *session.Session) *EventsStream {
 		triggerList:   NewTriggerList(),
 	}
 
+	mod.SetInterfaceIndependent(true)
+
 	mod.State.Store("ignoring", &mod.Session.EventsIgnoreList)
 
 	mod.AddHandler(session.NewModuleHandler("events.stream on", "",
@@ -208,6 +211,25 @@ func NewEventsStream(s *session.Session) *EventsStream {
 		"10",
 		"File size (in MB) or time duration (in seconds) for log rotation."))
 
+	mod.AddParam(session.NewStringParameter("events.stream.syslog",
+		"",
+		"",
+		"If not empty, events will also be sent to this syslog server as RFC 5424 messages, like udp://10.0.0.1:514, tcp://10.0.0.1:601 or tls://10.0.0.1:6514."))
+
+	mod.AddParam(session.NewStringParameter("events.stream.syslog.facility",
+		"local0",
+		"",
+		"Syslog facility of the events."))
+
+	mod.AddParam(session.NewStringParameter("events.stream.syslog.map",
+		"",
+		"",
+		"Comma separated list of tag=priority rules overriding the facility and or the severity of the events with a matching tag, like wifi.client.handshake=alert,creds.*=auth.crit, severities default to the level of sys.log events and to info for the others."))
+
+	mod.AddParam(session.NewBoolParameter("events.stream.syslog.tls.insecure",
+		"false",
+		"If true, don't verify the TLS certificate of the syslog server."))
+
 	mod.AddParam(session.NewBoolParameter("events.stream.http.request.dump",
 		"false",
 		"If true all HTTP requests will be dumped."))
@@ -238,7 +260,9 @@ func (mod *EventsStream) Author() string {
 func (mod *EventsStream) Configure() (err error) {
 	var output string
 
-	if err, output = mod.StringParam("events.stream.output"); err == nil {
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, output = mod.StringParam("events.stream.output"); err == nil {
 		if output == "" {
 			mod.output = os.Stdout
 		} else if mod.outputName, err = fs.Expand(output); err == nil {
@@ -271,6 +295,31 @@ func (mod *EventsStream) Configure() (err error) {
 		return err
 	}
 
+	var syslogAddress, syslogFacility, syslogMap string
+	var syslogInsecure bool
+	if err, syslogAddress = mod.StringParam("events.stream.syslog"); err != nil {
+		return err
+	} else if err, syslogFacility = mod.StringParam("events.stream.syslog.facility"); err != nil {
+		return err
+	} else if err, syslogMap = mod.StringParam("events.stream.syslog.map"); err != nil {
+		return err
+	} else if err, syslogInsecure = mod.BoolParam("events.stream.syslog.tls.insecure"); err != nil {
+		return err
+	}
+
+	mod.syslog = nil
+	if syslogAddress != "" {
+		if mod.syslog, err = newSyslogSink(syslogAddress, syslogFacility, syslogMap, syslogInsecure); err != nil {
+			return err
+		} else if err = mod.syslog.connect(); err != nil {
+			return fmt.Errorf("error connecting to %s: %v", mod.syslog, err)
+		}
+		sink := mod.syslog
+		sink.Start(func(err error) {
+			mod.Warning("error sending event to %s: %v", sink, err)
+		})
+	}
+
 	return err
 }
 
@@ -294,6 +343,9 @@ func (mod *EventsStream) Start() error {
 
 				if !mod.Session.EventsIgnoreList.Ignored(e) {
 					mod.View(e, true)
+					if mod.syslog != nil {
+						mod.syslog.Send(e)
+					}
 				}
 
 				// this could generate sys.log events and lock the whole
@@ -360,6 +412,9 @@ func (mod *EventsStream) startWaitingFor(tag string, timeout int) error {
 func (mod *EventsStream) Stop() error {
 	return mod.SetRunning(false, func() {
 		mod.quit <- true
+		if mod.syslog != nil {
+			mod.syslog.Close()
+		}
 		if mod.output != os.Stdout {
 			if fp, ok := mod.output.(*os.File); ok {
 				fp.Close()
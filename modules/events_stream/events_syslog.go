@@ -0,0 +1,319 @@
+package events_stream
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/acarl005/stripansi"
+	"github.com/evilsocket/islazy/log"
+	"github.com/evilsocket/islazy/str"
+	"github.com/gobwas/glob"
+)
+
+const (
+	// the example private enterprise number of RFC 5612
+	syslogSDID        = "bettercap@32473"
+	syslogTimeout     = 5 * time.Second
+	syslogRetryWait   = 10 * time.Second
+	syslogMaxSDParams = 32
+	// events waiting to be sent, newer ones are dropped if it's full
+	syslogQueueSize = 1024
+)
+
+var (
+	syslogFacilities = map[string]int{
+		"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+		"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11, "ntp": 12, "security": 13, "console": 14,
+		"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+		"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+	}
+
+	syslogSeverities = map[string]int{
+		"emerg": 0, "alert": 1, "crit": 2, "err": 3, "error": 3,
+		"warning": 4, "warn": 4, "notice": 5, "info": 6, "debug": 7,
+	}
+
+	// how the log levels of the sys.log events map to syslog severities
+	levelSeverities = map[log.Verbosity]int{
+		log.DEBUG:     7,
+		log.INFO:      6,
+		log.IMPORTANT: 5,
+		log.WARNING:   4,
+		log.ERROR:     3,
+		log.FATAL:     2,
+	}
+
+	sdValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+)
+
+// syslogRule overrides the facility and or the severity of the events with
+// a tag matching its pattern, -1 if not overridden.
+type syslogRule struct {
+	pattern  glob.Glob
+	facility int
+	severity int
+}
+
+// parseSyslogRules parses a comma separated list of pattern=priority rules,
+// where priority is a facility, a severity or both like local1.alert.
+func parseSyslogRules(list string) ([]syslogRule, error) {
+	rules := make([]syslogRule, 0)
+	for _, expr := range str.Comma(list) {
+		parts := strings.SplitN(expr, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("'%s' is not a valid rule, use tag=facility.severity", expr)
+		}
+
+		g, err := glob.Compile(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a valid tag pattern: %v", parts[0], err)
+		}
+
+		rule := syslogRule{pattern: g, facility: -1, severity: -1}
+		for _, name := range strings.Split(strings.ToLower(strings.TrimSpace(parts[1])), ".") {
+			if facility, found := syslogFacilities[name]; found {
+				rule.facility = facility
+			} else if severity, found := syslogSeverities[name]; found {
+				rule.severity = severity
+			} else {
+				return nil, fmt.Errorf("unknown facility or severity '%s' in '%s'", name, expr)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// syslogSink sends the events as RFC 5424 messages over UDP, TCP or TLS, the
+// tag of the event is the MSGID and its fields are structured data. The
+// events are queued and sent by their own goroutine, so that a slow server
+// can't stall the events.stream loop.
+type syslogSink struct {
+	network   string
+	address   string
+	insecure  bool
+	facility  int
+	rules     []syslogRule
+	hostname  string
+	conn      net.Conn
+	lastRetry time.Time
+	queue     chan session.Event
+	done      chan struct{}
+	dropped   uint64
+}
+
+func newSyslogSink(address string, facility string, rules string, insecure bool) (*syslogSink, error) {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("'%s' is not a valid syslog address, use udp://, tcp:// or tls://host:port", address)
+	}
+
+	sink := &syslogSink{
+		network:  u.Scheme,
+		address:  u.Host,
+		insecure: insecure,
+		queue:    make(chan session.Event, syslogQueueSize),
+		done:     make(chan struct{}),
+	}
+
+	if sink.network != "udp" && sink.network != "tcp" && sink.network != "tls" {
+		return nil, fmt.Errorf("unsupported syslog protocol '%s', use udp, tcp or tls", u.Scheme)
+	} else if u.Port() == "" {
+		port := "514"
+		if sink.network == "tls" {
+			port = "6514"
+		}
+		sink.address = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	var found bool
+	if sink.facility, found = syslogFacilities[strings.ToLower(facility)]; !found {
+		return nil, fmt.Errorf("unknown syslog facility '%s'", facility)
+	} else if sink.rules, err = parseSyslogRules(rules); err != nil {
+		return nil, err
+	}
+
+	if sink.hostname, err = os.Hostname(); err != nil || sink.hostname == "" {
+		sink.hostname = "-"
+	}
+
+	return sink, nil
+}
+
+func (s *syslogSink) String() string {
+	return fmt.Sprintf("%s://%s", s.network, s.address)
+}
+
+func (s *syslogSink) connect() (err error) {
+	s.lastRetry = time.Now()
+
+	dialer := &net.Dialer{Timeout: syslogTimeout}
+	if s.network == "tls" {
+		host, _, _ := net.SplitHostPort(s.address)
+		s.conn, err = tls.DialWithDialer(dialer, "tcp", s.address, &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: s.insecure,
+		})
+	} else {
+		s.conn, err = dialer.Dial(s.network, s.address)
+	}
+	return err
+}
+
+// Start sends the queued events until Close is called, reporting the errors
+// to onError.
+func (s *syslogSink) Start(onError func(error)) {
+	go func() {
+		defer close(s.done)
+		for e := range s.queue {
+			if err := s.send(e); err != nil {
+				onError(err)
+			} else if dropped := atomic.SwapUint64(&s.dropped, 0); dropped > 0 {
+				onError(fmt.Errorf("dropped %d events while the server was too slow", dropped))
+			}
+		}
+	}()
+}
+
+// Send queues e, dropping it if the server can't keep up.
+func (s *syslogSink) Send(e session.Event) {
+	select {
+	case s.queue <- e:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+// Close stops the sender once the queued events have been sent and closes
+// the connection.
+func (s *syslogSink) Close() {
+	close(s.queue)
+	<-s.done
+	s.disconnect()
+}
+
+func (s *syslogSink) disconnect() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// priority returns the PRI of e, the facility and severity of the first rule
+// matching its tag or the default ones.
+func (s *syslogSink) priority(e session.Event) int {
+	facility, severity := s.facility, levelSeverities[session.EventLevel(e)]
+	for _, rule := range s.rules {
+		if rule.pattern.Match(e.Tag) {
+			if rule.facility >= 0 {
+				facility = rule.facility
+			}
+			if rule.severity >= 0 {
+				severity = rule.severity
+			}
+			break
+		}
+	}
+	return facility*8 + severity
+}
+
+// sdName returns a valid SD-NAME for a field name.
+func sdName(name string) string {
+	clean := make([]byte, 0, len(name))
+	for i := 0; i < len(name) && len(clean) < 32; i++ {
+		if c := name[i]; c > 32 && c < 127 && c != '=' && c != ']' && c != '"' {
+			clean = append(clean, c)
+		}
+	}
+	return string(clean)
+}
+
+// structuredData returns the SD-ELEMENT with the scalar fields of the data
+// of e, and the message with the event itself.
+func structuredData(e session.Event) (string, string) {
+	params := []string{fmt.Sprintf(`tag="%s"`, sdValueEscaper.Replace(e.Tag))}
+
+	if m, ok := e.Data.(session.LogMessage); ok {
+		return fmt.Sprintf("[%s %s]", syslogSDID, strings.Join(params, " ")), stripansi.Strip(m.Message)
+	}
+
+	raw, err := json.Marshal(e.Data)
+	if err != nil {
+		return fmt.Sprintf("[%s %s]", syslogSDID, strings.Join(params, " ")), ""
+	}
+
+	fields := make(map[string]interface{})
+	if json.Unmarshal(raw, &fields) == nil {
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if len(params) >= syslogMaxSDParams {
+				break
+			}
+			switch v := fields[name].(type) {
+			case string, float64, bool:
+				if sd := sdName(name); sd != "" && sd != "tag" {
+					params = append(params, fmt.Sprintf(`%s="%s"`, sd, sdValueEscaper.Replace(fmt.Sprintf("%v", v))))
+				}
+			}
+		}
+	}
+
+	return fmt.Sprintf("[%s %s]", syslogSDID, strings.Join(params, " ")), string(raw)
+}
+
+// format returns the RFC 5424 message for e.
+func (s *syslogSink) format(e session.Event) string {
+	msgID := sdName(e.Tag)
+	if msgID == "" {
+		msgID = "-"
+	}
+	sd, msg := structuredData(e)
+	return fmt.Sprintf("<%d>1 %s %s bettercap %d %s %s \xef\xbb\xbf%s",
+		s.priority(e),
+		e.Time.Format(time.RFC3339Nano),
+		s.hostname,
+		os.Getpid(),
+		msgID,
+		sd,
+		msg)
+}
+
+// send writes e to the server, connecting again if needed but not more than
+// once every syslogRetryWait.
+func (s *syslogSink) send(e session.Event) error {
+	if s.conn == nil {
+		if time.Since(s.lastRetry) < syslogRetryWait {
+			return nil
+		} else if err := s.connect(); err != nil {
+			return err
+		}
+	}
+
+	msg := s.format(e)
+	if s.network != "udp" {
+		// octet counting framing of RFC 6587
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+	if _, err := s.conn.Write([]byte(msg)); err != nil {
+		s.disconnect()
+		return err
+	}
+	return nil
+}
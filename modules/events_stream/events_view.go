@@ -5,12 +5,27 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/bettercap/bettercap/network"
 	"github.com/bettercap/bettercap/session"
 
+	"github.com/bettercap/bettercap/modules/arp_watch"
+	"github.com/bettercap/bettercap/modules/dhcp_spoof"
+	"github.com/bettercap/bettercap/modules/http_proxy"
+	"github.com/bettercap/bettercap/modules/iot_scan"
+	"github.com/bettercap/bettercap/modules/l2_recon"
+	"github.com/bettercap/bettercap/modules/mqtt_proxy"
+	"github.com/bettercap/bettercap/modules/name_spoof"
 	"github.com/bettercap/bettercap/modules/net_sniff"
+	"github.com/bettercap/bettercap/modules/ntlm_relay"
+	"github.com/bettercap/bettercap/modules/smb_recon"
+	"github.com/bettercap/bettercap/modules/snmp_scan"
+	"github.com/bettercap/bettercap/modules/spoof_stats"
 	"github.com/bettercap/bettercap/modules/syn_scan"
+	"github.com/bettercap/bettercap/modules/vlan_hop"
+
+	"github.com/dustin/go-humanize"
 
 	"github.com/google/go-github/github"
 
@@ -76,23 +91,367 @@ func (mod *EventsStream) viewModuleEvent(output io.Writer, e session.Event) {
 func (mod *EventsStream) viewSnifferEvent(output io.Writer, e session.Event) {
 	if strings.HasPrefix(e.Tag, "net.sniff.http.") {
 		mod.viewHttpEvent(output, e)
+	} else if se := e.Data.(net_sniff.SnifferEvent); se.Interface != "" {
+		fmt.Fprintf(output, "[%s] [%s] [%s] %s\n",
+			e.Time.Format(mod.timeFormat),
+			tui.Green(e.Tag),
+			tui.Dim(se.Interface),
+			se.Message)
 	} else {
 		fmt.Fprintf(output, "[%s] [%s] %s\n",
 			e.Time.Format(mod.timeFormat),
 			tui.Green(e.Tag),
-			e.Data.(net_sniff.SnifferEvent).Message)
+			se.Message)
 	}
 }
 
 func (mod *EventsStream) viewSynScanEvent(output io.Writer, e session.Event) {
 	se := e.Data.(syn_scan.SynScanEvent)
-	fmt.Fprintf(output, "[%s] [%s] found open port %d for %s\n",
+	fmt.Fprintf(output, "[%s] [%s] found open %s port %d for %s\n",
 		e.Time.Format(mod.timeFormat),
 		tui.Green(e.Tag),
+		se.Proto,
 		se.Port,
 		tui.Bold(se.Address))
 }
 
+// portSummary returns the product and version of a port if known, its
+// banner otherwise.
+func portSummary(port *syn_scan.OpenPort) string {
+	if port.Product != "" {
+		return strings.TrimSpace(port.Product + " " + port.Version)
+	} else if port.Banner != "" {
+		return port.Banner
+	}
+	return "certificate " + port.Cert
+}
+
+func (mod *EventsStream) viewPortChangeEvent(output io.Writer, e session.Event) {
+	pe := e.Data.(syn_scan.PortChangeEvent)
+	service := ""
+	if pe.Port.Service != "" {
+		service = fmt.Sprintf(" (%s)", pe.Port.Service)
+	}
+
+	if e.Tag == "endpoint.port.new" {
+		fmt.Fprintf(output, "[%s] [%s] %s port %d%s is now open on %s\n",
+			e.Time.Format(mod.timeFormat),
+			tui.Green(e.Tag),
+			pe.Port.Proto,
+			pe.Port.Port,
+			tui.Dim(service),
+			tui.Bold(pe.Address))
+	} else if e.Tag == "endpoint.port.changed" && pe.Previous != nil {
+		fmt.Fprintf(output, "[%s] [%s] %s port %d%s changed on %s: %s -> %s\n",
+			e.Time.Format(mod.timeFormat),
+			tui.Green(e.Tag),
+			pe.Port.Proto,
+			pe.Port.Port,
+			tui.Dim(service),
+			tui.Bold(pe.Address),
+			tui.Dim(portSummary(pe.Previous)),
+			tui.Yellow(portSummary(pe.Port)))
+	} else {
+		fmt.Fprintf(output, "[%s] [%s] %s port %d%s is now closed on %s, first seen %s\n",
+			e.Time.Format(mod.timeFormat),
+			tui.Green(e.Tag),
+			pe.Port.Proto,
+			pe.Port.Port,
+			tui.Dim(service),
+			tui.Bold(pe.Address),
+			pe.Port.FirstSeen.Format(mod.timeFormat))
+	}
+}
+
+func (mod *EventsStream) viewSNMPScanEvent(output io.Writer, e session.Event) {
+	se := e.Data.(snmp_scan.SNMPScanEvent)
+	name := ""
+	if se.SysName != "" {
+		name = fmt.Sprintf(" (%s)", se.SysName)
+	}
+
+	defaults := ""
+	if len(se.Defaults) > 0 {
+		defaults = tui.Red(" [default communities]")
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] %s%s accepts communities %s%s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		tui.Bold(se.Address),
+		tui.Dim(name),
+		tui.Yellow(strings.Join(se.Communities, ", ")),
+		defaults)
+}
+
+func (mod *EventsStream) viewSMBReconEvent(output io.Writer, e session.Event) {
+	se := e.Data.(smb_recon.SMBReconEvent)
+	osName := ""
+	if se.OS != "" {
+		osName = fmt.Sprintf(" (%s)", se.OS)
+	}
+
+	signing := ""
+	if se.Signing != "" {
+		signing = fmt.Sprintf(", signing %s", se.Signing)
+		if se.Signing != "required" {
+			signing = tui.Red(signing)
+		}
+	}
+
+	shares := ""
+	if se.NullSession {
+		names := make([]string, 0, len(se.Shares))
+		for _, share := range se.Shares {
+			names = append(names, share.Name)
+		}
+		shares = tui.Red(" null session")
+		if len(names) > 0 {
+			shares += fmt.Sprintf(", shares %s", tui.Yellow(strings.Join(names, ", ")))
+		}
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] %s%s speaks SMB %s%s%s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		tui.Bold(se.Address),
+		tui.Dim(osName),
+		strings.Join(se.Dialects, ", "),
+		signing,
+		shares)
+}
+
+func (mod *EventsStream) viewNTLMRelayEvent(output io.Writer, e session.Event) {
+	if e.Tag == "ntlm.relay.hash" {
+		he := e.Data.(ntlm_relay.NTLMRelayHashEvent)
+		fmt.Fprintf(output, "[%s] [%s] %s authenticated as %s on port %d\n%s\n",
+			e.Time.Format(mod.timeFormat),
+			tui.Green(e.Tag),
+			tui.Bold(he.Client),
+			tui.Red(fmt.Sprintf("%s\\%s", he.Domain, he.User)),
+			he.Port,
+			he.Hash)
+		return
+	}
+
+	me := e.Data.(ntlm_relay.NTLMRelayMessageEvent)
+	dir := "from"
+	if me.FromClient {
+		dir = "to"
+	}
+	fmt.Fprintf(output, "[%s] [%s] %s %s %s on port %d %s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		tui.Yellow(me.Type),
+		dir,
+		tui.Bold(me.Client),
+		me.Port,
+		tui.Dim(fmt.Sprintf("(%d bytes)", len(me.Blob)*3/4)))
+}
+
+func (mod *EventsStream) viewCredentialEvent(output io.Writer, e session.Event) {
+	cred := e.Data.(session.Credential)
+	who := cred.Username
+	if who != "" {
+		who = tui.Bold(who) + " "
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] %s %s%s %s for %s (%s)\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		cred.Protocol,
+		who,
+		cred.Type,
+		tui.Red(cred.Secret),
+		tui.Yellow(cred.Server),
+		tui.Dim(cred.Source))
+}
+
+func (mod *EventsStream) viewMQTTCredentialsEvent(output io.Writer, e session.Event) {
+	ce := e.Data.(mqtt_proxy.MQTTCredentialsEvent)
+	fmt.Fprintf(output, "[%s] [%s] %s (%s) connected to %s as %s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		tui.Bold(ce.Client),
+		ce.ClientID,
+		ce.Broker,
+		tui.Red(ce.Username+":"+ce.Password))
+}
+
+func (mod *EventsStream) viewIoTScanEvent(output io.Writer, e session.Event) {
+	ie := e.Data.(iot_scan.IoTScanEvent)
+	if ie.Protocol == "mqtt" {
+		proto := "mqtt"
+		if ie.TLS {
+			proto = "mqtts"
+		}
+
+		anon := ""
+		if ie.Anonymous {
+			anon = tui.Red(" accepting anonymous clients")
+		}
+
+		fmt.Fprintf(output, "[%s] [%s] found %s broker %s:%d%s\n",
+			e.Time.Format(mod.timeFormat),
+			tui.Green(e.Tag),
+			proto,
+			tui.Bold(ie.Address),
+			ie.Port,
+			anon)
+	} else {
+		fmt.Fprintf(output, "[%s] [%s] found coap endpoint %s with resources %s\n",
+			e.Time.Format(mod.timeFormat),
+			tui.Green(e.Tag),
+			tui.Bold(ie.Address),
+			tui.Yellow(strings.Join(ie.Resources, ", ")))
+	}
+}
+
+func (mod *EventsStream) viewDHCPLeaseEvent(output io.Writer, e session.Event) {
+	lease := e.Data.(dhcp_spoof.Lease)
+	hostname := ""
+	if lease.Hostname != "" {
+		hostname = " " + tui.Yellow(lease.Hostname)
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] %s assigned to %s%s until %s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		tui.Bold(lease.IP),
+		lease.MAC,
+		hostname,
+		lease.Expires.Format(mod.timeFormat))
+}
+
+func (mod *EventsStream) viewNameSpoofEvent(output io.Writer, e session.Event) {
+	p := e.Data.(name_spoof.Poisoning)
+	who := p.IP
+	if p.Hostname != "" {
+		who = fmt.Sprintf("%s (%s)", p.IP, tui.Yellow(p.Hostname))
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] %s poisoned with a spoofed %s answer for %s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		tui.Bold(who),
+		p.Protocol,
+		tui.Red(p.Name))
+}
+
+func (mod *EventsStream) viewProxyStatsEvent(output io.Writer, e session.Event) {
+	h := e.Data.(http_proxy.HostStats)
+	fmt.Fprintf(output, "[%s] [%s] %s %d requests (%d errors), sent %s and received %s, %s upstream and %s of overhead on average\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		tui.Bold(h.Host),
+		h.Requests,
+		h.Errors,
+		humanize.Bytes(h.SentBytes),
+		humanize.Bytes(h.RecvBytes),
+		h.AvgUpstream().Round(time.Millisecond),
+		tui.Yellow(h.AvgOverhead().Round(time.Microsecond).String()))
+}
+
+func (mod *EventsStream) viewFingerprintEvent(output io.Writer, e session.Event) {
+	f := e.Data.(http_proxy.Fingerprint)
+	if f.Client == "" {
+		fmt.Fprintf(output, "[%s] [%s] server %s has JA3S %s (%s)\n",
+			e.Time.Format(mod.timeFormat),
+			tui.Green(e.Tag),
+			tui.Bold(f.Host),
+			tui.Yellow(f.Hash),
+			tui.Dim(f.JA3))
+		return
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] client %s has JA3 %s (%s) connecting to %s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		tui.Bold(f.Client),
+		tui.Yellow(f.Hash),
+		tui.Dim(f.JA3),
+		f.Host)
+}
+
+func (mod *EventsStream) viewArpWatchEvent(output io.Writer, e session.Event) {
+	a := e.Data.(arp_watch.Alert)
+	fmt.Fprintf(output, "[%s] [%s] %s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Red(e.Tag),
+		a.Message)
+}
+
+func (mod *EventsStream) viewVLANHopEvent(output io.Writer, e session.Event) {
+	r := e.Data.(vlan_hop.HopResult)
+	outcome := tui.Green("no replies")
+	if r.Exposed {
+		outcome = tui.Red(fmt.Sprintf("%d replies, exposed to VLAN hopping", r.Replies))
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] %s in VLAN %d via native VLAN %d: %d probes, %s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		tui.Bold(r.Target),
+		r.TargetVLAN,
+		r.NativeVLAN,
+		r.Sent,
+		outcome)
+}
+
+func (mod *EventsStream) viewL2ReconEvent(output io.Writer, e session.Event) {
+	if e.Tag == "l2.recon.stp.root" {
+		fmt.Fprintf(output, "[%s] [%s] root bridge of the spanning tree is %s\n",
+			e.Time.Format(mod.timeFormat),
+			tui.Green(e.Tag),
+			tui.Bold(e.Data.(string)))
+		return
+	}
+
+	n := e.Data.(l2_recon.Neighbour)
+	name := ""
+	if n.Name != "" {
+		name = " " + tui.Yellow(n.Name)
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] new %s neighbour %s%s on port %s %s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		n.Protocol,
+		tui.Bold(n.ID),
+		name,
+		n.Port,
+		tui.Dim(n.Platform))
+}
+
+func (mod *EventsStream) viewSpoofStatsEvent(output io.Writer, e session.Event) {
+	v := e.Data.(spoof_stats.Victim)
+	who := v.IP
+	if v.Hostname != "" {
+		who = fmt.Sprintf("%s (%s)", v.IP, tui.Yellow(v.Hostname))
+	}
+
+	if e.Tag == "spoof.stats.new" {
+		fmt.Fprintf(output, "[%s] [%s] intercepting the traffic of %s\n",
+			e.Time.Format(mod.timeFormat),
+			tui.Green(e.Tag),
+			tui.Bold(who))
+		return
+	}
+
+	top := ""
+	if len(v.Destinations) > 0 {
+		top = fmt.Sprintf(", mostly to %s", v.Destinations[0].Address)
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] %s sent %s and received %s%s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		tui.Bold(who),
+		humanize.Bytes(v.SentBytes),
+		humanize.Bytes(v.RecvBytes),
+		top)
+}
+
 func (mod *EventsStream) viewUpdateEvent(output io.Writer, e session.Event) {
 	update := e.Data.(*github.RepositoryRelease)
 
@@ -103,6 +462,15 @@ func (mod *EventsStream) viewUpdateEvent(output io.Writer, e session.Event) {
 		*update.HTMLURL)
 }
 
+func (mod *EventsStream) viewSubnetEvent(output io.Writer, e session.Event) {
+	subnet := e.Data.(*network.Subnet)
+	fmt.Fprintf(output, "[%s] [%s] subnet %s learned from %s.\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Green(e.Tag),
+		tui.Bold(subnet.CIDR),
+		tui.Dim(subnet.Source))
+}
+
 func (mod *EventsStream) Render(output io.Writer, e session.Event) {
 	var err error
 	if err, mod.timeFormat = mod.StringParam("events.stream.time.format"); err != nil {
@@ -112,6 +480,8 @@ func (mod *EventsStream) Render(output io.Writer, e session.Event) {
 
 	if e.Tag == "sys.log" {
 		mod.viewLogEvent(output, e)
+	} else if strings.HasPrefix(e.Tag, "endpoint.port.") {
+		mod.viewPortChangeEvent(output, e)
 	} else if strings.HasPrefix(e.Tag, "endpoint.") {
 		mod.viewEndpointEvent(output, e)
 	} else if strings.HasPrefix(e.Tag, "wifi.") {
@@ -128,11 +498,43 @@ func (mod *EventsStream) Render(output io.Writer, e session.Event) {
 		mod.viewSnifferEvent(output, e)
 	} else if e.Tag == "syn.scan" {
 		mod.viewSynScanEvent(output, e)
+	} else if e.Tag == "snmp.scan" {
+		mod.viewSNMPScanEvent(output, e)
+	} else if e.Tag == "smb.recon" {
+		mod.viewSMBReconEvent(output, e)
+	} else if strings.HasPrefix(e.Tag, "ntlm.relay.") {
+		mod.viewNTLMRelayEvent(output, e)
+	} else if e.Tag == "iot.scan" {
+		mod.viewIoTScanEvent(output, e)
+	} else if e.Tag == "creds.new" {
+		mod.viewCredentialEvent(output, e)
+	} else if e.Tag == "mqtt.proxy.credentials" {
+		mod.viewMQTTCredentialsEvent(output, e)
+	} else if e.Tag == "dhcp.spoof.lease" {
+		mod.viewDHCPLeaseEvent(output, e)
+	} else if e.Tag == "vlan.hop" {
+		mod.viewVLANHopEvent(output, e)
+	} else if strings.HasPrefix(e.Tag, "l2.recon.") {
+		mod.viewL2ReconEvent(output, e)
+	} else if e.Tag == "name.spoof.poisoned" {
+		mod.viewNameSpoofEvent(output, e)
+	} else if strings.HasSuffix(e.Tag, ".ja3") || strings.HasSuffix(e.Tag, ".ja3s") {
+		mod.viewFingerprintEvent(output, e)
+	} else if strings.HasSuffix(e.Tag, ".proxy.stats") {
+		mod.viewProxyStatsEvent(output, e)
+	} else if strings.HasPrefix(e.Tag, "arp.watch.") {
+		mod.viewArpWatchEvent(output, e)
+	} else if strings.HasPrefix(e.Tag, "spoof.stats.") {
+		mod.viewSpoofStatsEvent(output, e)
+	} else if e.Tag == "net.subnet.new" {
+		mod.viewSubnetEvent(output, e)
 	} else if e.Tag == "update.available" {
 		mod.viewUpdateEvent(output, e)
 	} else if e.Tag == "gateway.change" {
 		mod.viewGatewayEvent(output, e)
-	} else if e.Tag != "tick" && e.Tag != "session.started" && e.Tag != "session.stopped" {
+	} else if strings.HasPrefix(e.Tag, "iface.") {
+		mod.viewInterfaceEvent(output, e)
+	} else if e.Tag != "tick" && e.Tag != "session.started" && e.Tag != "session.stopped" && e.Tag != "syn.scan.progress" {
 		fmt.Fprintf(output, "[%s] [%s] %v\n", e.Time.Format(mod.timeFormat), tui.Green(e.Tag), e)
 	}
 }
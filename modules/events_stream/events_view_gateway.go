@@ -3,6 +3,7 @@ package events_stream
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/bettercap/bettercap/session"
 
@@ -20,4 +21,33 @@ func (mod *EventsStream) viewGatewayEvent(output io.Writer, e session.Event) {
 		change.Prev.MAC,
 		change.New.IP,
 		change.New.MAC)
-}
\ No newline at end of file
+}
+func (mod *EventsStream) viewInterfaceEvent(output io.Writer, e session.Event) {
+	change := e.Data.(session.InterfaceChange)
+
+	what := ""
+	switch e.Tag {
+	case "iface.down":
+		what = "went down"
+	case "iface.up":
+		what = fmt.Sprintf("is back with address %s", change.New.IPv4)
+	default:
+		what = fmt.Sprintf("changed address: %s -> %s", change.Prev.IPv4, change.New.IPv4)
+	}
+
+	modules := ""
+	if len(change.Modules) > 0 {
+		action := "restarting"
+		if e.Tag == "iface.down" {
+			action = "pausing"
+		}
+		modules = fmt.Sprintf(" (%s %s)", action, strings.Join(change.Modules, ", "))
+	}
+
+	fmt.Fprintf(output, "[%s] [%s] interface %s %s%s\n",
+		e.Time.Format(mod.timeFormat),
+		tui.Red(e.Tag),
+		tui.Bold(change.Name),
+		what,
+		tui.Dim(modules))
+}
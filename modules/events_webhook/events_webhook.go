@@ -0,0 +1,263 @@
+package events_webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/str"
+)
+
+// formats of the payload POSTed to the endpoints
+const (
+	FormatJSON    = "json"
+	FormatSlack   = "slack"
+	FormatDiscord = "discord"
+)
+
+const (
+	// how long the last batches have to be sent when the module is stopped,
+	// within the 10 seconds the session waits for it
+	stopTimeout = 5 * time.Second
+	// batches waiting to be sent, the oldest are dropped when the endpoints
+	// can't keep up
+	maxPendingBatches = 64
+)
+
+type EventsWebhook struct {
+	session.SessionModule
+	urls         []string
+	headers      map[string]string
+	format       string
+	filter       *session.EventFilter
+	batchSize    int
+	batchTimeout time.Duration
+	retries      int
+	client       *http.Client
+	quit         chan bool
+	stopped      chan bool
+	sending      sync.WaitGroup
+	ctx          context.Context
+	cancel       context.CancelFunc
+}
+
+func NewEventsWebhook(s *session.Session) *EventsWebhook {
+	mod := &EventsWebhook{
+		SessionModule: session.NewSessionModule("events.webhook", s),
+		urls:          make([]string, 0),
+		headers:       make(map[string]string),
+		client:        &http.Client{Timeout: 10 * time.Second},
+		quit:          make(chan bool),
+		stopped:       make(chan bool),
+	}
+
+	mod.SetInterfaceIndependent(true)
+
+	mod.AddParam(session.NewStringParameter("events.webhook.urls",
+		"",
+		"",
+		"Comma separated list of URLs the events are POSTed to."))
+
+	mod.AddParam(session.NewStringParameter("events.webhook.headers",
+		"",
+		"",
+		"Semicolon separated list of 'Name: value' headers to add to the requests, like an authorization token."))
+
+	mod.AddParam(session.NewStringParameter("events.webhook.format",
+		FormatJSON,
+		fmt.Sprintf("^(%s|%s|%s)$", FormatJSON, FormatSlack, FormatDiscord),
+		"Payload format, json for an array of events, slack or discord for a message with one line per event."))
+
+	mod.AddParam(session.NewStringParameter("events.webhook.tags",
+		"",
+		"",
+		"Comma separated list of tag patterns like wifi.* of the events to send, empty for all."))
+
+	mod.AddParam(session.NewStringParameter("events.webhook.level",
+		"info",
+		"",
+		"Minimum severity of the events to send among debug, info, important, warning, error and fatal, events other than sys.log ones are info."))
+
+	mod.AddParam(session.NewIntParameter("events.webhook.batch.size",
+		"10",
+		"Maximum number of events sent with a single request."))
+
+	mod.AddParam(session.NewIntParameter("events.webhook.batch.timeout",
+		"5",
+		"Seconds to wait for a batch to fill up before sending it anyway."))
+
+	mod.AddParam(session.NewIntParameter("events.webhook.retries",
+		"3",
+		"How many times a failed request is retried, waiting twice as long every time."))
+
+	mod.AddHandler(session.NewModuleHandler("events.webhook on", "",
+		"Start sending the events to the webhooks.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("events.webhook off", "",
+		"Stop sending the events to the webhooks.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	return mod
+}
+
+func (mod *EventsWebhook) Name() string {
+	return "events.webhook"
+}
+
+func (mod *EventsWebhook) Description() string {
+	return "POSTs the selected events as JSON to one or more HTTP endpoints, like Slack or Discord webhooks."
+}
+
+func (mod *EventsWebhook) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func parseHeaders(list string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, header := range strings.Split(list, ";") {
+		if header = strings.TrimSpace(header); header == "" {
+			continue
+		} else if parts := strings.SplitN(header, ":", 2); len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("'%s' is not a valid header, use 'Name: value'", header)
+		} else {
+			headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return headers, nil
+}
+
+func (mod *EventsWebhook) Configure() (err error) {
+	var urls, headers, tags, level string
+	var timeout int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, urls = mod.StringParam("events.webhook.urls"); err != nil {
+		return err
+	} else if err, headers = mod.StringParam("events.webhook.headers"); err != nil {
+		return err
+	} else if err, mod.format = mod.StringParam("events.webhook.format"); err != nil {
+		return err
+	} else if err, tags = mod.StringParam("events.webhook.tags"); err != nil {
+		return err
+	} else if err, level = mod.StringParam("events.webhook.level"); err != nil {
+		return err
+	} else if err, mod.batchSize = mod.IntParam("events.webhook.batch.size"); err != nil {
+		return err
+	} else if err, timeout = mod.IntParam("events.webhook.batch.timeout"); err != nil {
+		return err
+	} else if err, mod.retries = mod.IntParam("events.webhook.retries"); err != nil {
+		return err
+	} else if mod.headers, err = parseHeaders(headers); err != nil {
+		return err
+	} else if mod.filter, err = session.NewEventFilter(str.Comma(tags), level); err != nil {
+		return err
+	}
+
+	mod.urls = str.Comma(urls)
+	if len(mod.urls) == 0 {
+		return fmt.Errorf("events.webhook.urls is empty")
+	}
+	for _, u := range mod.urls {
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return fmt.Errorf("'%s' is not a valid webhook URL", u)
+		}
+	}
+
+	if mod.batchSize < 1 {
+		mod.batchSize = 1
+	}
+	if timeout < 1 {
+		timeout = 1
+	}
+	mod.batchTimeout = time.Duration(timeout) * time.Second
+
+	return nil
+}
+
+func (mod *EventsWebhook) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	// canceled to abort the requests and retries still pending once stopped
+	mod.ctx, mod.cancel = context.WithCancel(context.Background())
+
+	return mod.SetRunning(true, func() {
+		mod.Info("sending events to %s", strings.Join(mod.urls, ", "))
+
+		listener := mod.Session.Events.Listen()
+		defer mod.Session.Events.Unlisten(listener)
+
+		ticker := time.NewTicker(mod.batchTimeout)
+		defer ticker.Stop()
+
+		batches := make(chan []session.Event, maxPendingBatches)
+		mod.sending.Add(1)
+		go mod.sender(batches)
+
+		batch := make([]session.Event, 0, mod.batchSize)
+		flush := func() {
+			if len(batch) > 0 {
+				mod.enqueue(batches, batch)
+				batch = make([]session.Event, 0, mod.batchSize)
+			}
+		}
+
+		for {
+			select {
+			case e := <-listener:
+				if mod.Session.EventsIgnoreList.Ignored(e) || session.LoggedBy(e, mod.Name()) || !mod.filter.Matches(e) {
+					continue
+				}
+				if batch = append(batch, e); len(batch) >= mod.batchSize {
+					flush()
+				}
+			case <-ticker.C:
+				flush()
+			case <-mod.quit:
+				flush()
+				close(batches)
+				mod.stopped <- true
+				return
+			}
+		}
+	})
+}
+
+func (mod *EventsWebhook) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.quit <- true
+		<-mod.stopped
+
+		// wait for the last batches to be sent, but not forever
+		ctx, cancel := context.WithTimeout(mod.ctx, stopTimeout)
+		defer cancel()
+
+		sent := make(chan struct{})
+		go func() {
+			mod.sending.Wait()
+			close(sent)
+		}()
+
+		select {
+		case <-sent:
+		case <-ctx.Done():
+			mod.Warning("could not send the last events in %s", stopTimeout)
+			mod.cancel()
+			<-sent
+		}
+		mod.cancel()
+	})
+}
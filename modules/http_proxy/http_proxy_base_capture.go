@@ -0,0 +1,61 @@
+package http_proxy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"sync"
+	"unicode/utf8"
+)
+
+// bodyCapture measures the body going through it, keeping a copy of up to
+// limit bytes, and calls done once when it's over.
+type bodyCapture struct {
+	io.ReadCloser
+	limit int
+	buf   bytes.Buffer
+	size  int64
+	once  sync.Once
+	done  func(*bodyCapture)
+}
+
+func (c *bodyCapture) Read(b []byte) (int, error) {
+	n, err := c.ReadCloser.Read(b)
+	if n > 0 {
+		c.size += int64(n)
+		if left := c.limit - c.buf.Len(); left > 0 {
+			if left > n {
+				left = n
+			}
+			c.buf.Write(b[:left])
+		}
+	}
+	if err == io.EOF {
+		c.finish()
+	}
+	return n, err
+}
+
+func (c *bodyCapture) Close() error {
+	c.finish()
+	return c.ReadCloser.Close()
+}
+
+func (c *bodyCapture) finish() {
+	if c.done != nil {
+		c.once.Do(func() {
+			c.done(c)
+		})
+	}
+}
+
+// text returns the captured body, base64 encoded if binary.
+func (c *bodyCapture) text() (string, string) {
+	if c == nil {
+		return "", ""
+	} else if raw := c.buf.Bytes(); utf8.Valid(raw) {
+		return string(raw), ""
+	} else {
+		return base64.StdEncoding.EncodeToString(raw), "base64"
+	}
+}
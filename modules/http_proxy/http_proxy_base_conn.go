@@ -0,0 +1,110 @@
+package http_proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+func newTLSConnection(client string, hostname string, port string, ja3 string, hello []byte) *JSConnection {
+	jsconn := NewJSConnection(client, hostname, port, true)
+	jsconn.JA3 = ja3
+	if protos, found := packets.TLSClientHelloALPN(hello); found {
+		jsconn.Protocols = protos
+	}
+	return jsconn
+}
+
+// interceptsConnections returns true if the script wants to see the
+// lifecycle of the connections.
+func (p *HTTPProxy) interceptsConnections() bool {
+	return p.Script != nil && (p.Script.doOnConnect || p.Script.doOnTLSHandshake || p.Script.doOnClose)
+}
+
+// onConnect passes conn to the script and returns false if it's been
+// blocked or bypassed, in which case it's been handled already.
+func (p *HTTPProxy) onConnect(jsconn *JSConnection, conn net.Conn) bool {
+	if p.Script == nil {
+		return true
+	}
+
+	p.Script.OnConnect(jsconn)
+	if jsconn.Block {
+		p.Info("connection from %s to %s blocked by the script", tui.Bold(jsconn.Client["IP"]), tui.Yellow(jsconn.Hostname))
+		conn.Close()
+		return false
+	} else if jsconn.Bypass {
+		p.Debug("connection from %s to %s bypassed by the script", tui.Bold(jsconn.Client["IP"]), tui.Yellow(jsconn.Hostname))
+		p.tunnel(conn, net.JoinHostPort(jsconn.Hostname, jsconn.Port))
+		return false
+	}
+
+	return true
+}
+
+func (p *HTTPProxy) onClose(jsconn *JSConnection) {
+	if p.Script != nil {
+		p.Script.OnClose(jsconn)
+	}
+}
+
+// tunnel connects conn to address without intercepting anything.
+func (p *HTTPProxy) tunnel(conn net.Conn, address string) {
+	defer conn.Close()
+
+	conn.SetDeadline(time.Time{})
+
+	dial := p.upstreamConnectDial()
+	if dial == nil {
+		dial = net.Dial
+	}
+
+	remote, err := dial("tcp", address)
+	if err != nil {
+		p.Warning("error connecting to %s: %s", address, err)
+		return
+	}
+	defer remote.Close()
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+
+	pipe := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		// unblock the other direction
+		dst.Close()
+	}
+
+	go pipe(remote, conn)
+	go pipe(conn, remote)
+
+	wg.Wait()
+}
+
+// onConnState notifies the script of the plain HTTP connections of the
+// clients, which are known by address only until a request is parsed.
+func (p *HTTPProxy) onConnState(conn net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		jsconn := NewJSConnection(stripPort(conn.RemoteAddr().String()), "", "", false)
+		p.conns.Store(conn, jsconn)
+		p.Script.OnConnect(jsconn)
+		if jsconn.Block {
+			p.Info("connection from %s blocked by the script", tui.Bold(jsconn.Client["IP"]))
+			conn.Close()
+		}
+
+	case http.StateHijacked, http.StateClosed:
+		if jsconn, found := p.conns.Load(conn); found {
+			p.conns.Delete(conn)
+			p.onClose(jsconn.(*JSConnection))
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package http_proxy
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// contentDecoders are the Content-Encoding schemes response bodies can be
+// decoded from before being passed to sslstrip, the rules and the scripts.
+var contentDecoders = map[string]func(io.Reader) (io.ReadCloser, error){
+	"gzip":    decodeGzip,
+	"x-gzip":  decodeGzip,
+	"deflate": decodeDeflate,
+	"br":      decodeBrotli,
+	"zstd":    decodeZstd,
+}
+
+func decodeGzip(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func decodeBrotli(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(brotli.NewReader(r)), nil
+}
+
+func decodeZstd(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
+
+// decodeDeflate handles both the zlib wrapped streams the RFC mandates and
+// the raw deflate ones some servers send instead.
+func decodeDeflate(r io.Reader) (io.ReadCloser, error) {
+	buf := bufio.NewReader(r)
+	if head, err := buf.Peek(2); err == nil && head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+		return zlib.NewReader(buf)
+	}
+	return flate.NewReader(buf), nil
+}
+
+// limitedReader fails once more than max bytes have been read, so that a
+// small compressed body can't be expanded to fill the memory.
+type limitedReader struct {
+	io.Reader
+	max  int64
+	read int64
+}
+
+func (l *limitedReader) Read(b []byte) (int, error) {
+	n, err := l.Reader.Read(b)
+	if l.read += int64(n); l.read > l.max {
+		return n, fmt.Errorf("decoded body bigger than %d bytes", l.max)
+	}
+	return n, err
+}
+
+// decodedBody closes both the decoder and the original body.
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *decodedBody) Close() (err error) {
+	for _, c := range b.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return
+}
+
+// contentEncodings returns the encodings of res in the order they've been
+// applied, ignoring identity.
+func contentEncodings(res *http.Response) []string {
+	encodings := make([]string, 0)
+	for _, value := range res.Header.Values("Content-Encoding") {
+		for _, enc := range strings.Split(value, ",") {
+			if enc = strings.ToLower(strings.TrimSpace(enc)); enc != "" && enc != "identity" {
+				encodings = append(encodings, enc)
+			}
+		}
+	}
+	return encodings
+}
+
+// decodeResponse replaces the compressed body of res with the decoded one
+// as it's read, removing the encoding since the client will get it in plain.
+// Compressed bodies are rare since the transport asks for gzip and decodes
+// it by itself, but several servers compress anyway with other schemes.
+func (p *HTTPProxy) decodeResponse(res *http.Response) error {
+	encodings := contentEncodings(res)
+	if len(encodings) == 0 || !hasBody(res.Body) {
+		return nil
+	}
+
+	// make sure we can decode all of them before touching the body
+	for _, enc := range encodings {
+		if _, found := contentDecoders[enc]; !found {
+			return fmt.Errorf("unsupported content encoding '%s'", enc)
+		}
+	}
+
+	body := &decodedBody{
+		Reader:  res.Body,
+		closers: []io.Closer{res.Body},
+	}
+	// the last encoding applied is the first to be decoded
+	for i := len(encodings) - 1; i >= 0; i-- {
+		decoder, err := contentDecoders[encodings[i]](body.Reader)
+		if err != nil {
+			// the body has been partially consumed, it can't be passed as is
+			body.Close()
+			res.Body = ioutil.NopCloser(strings.NewReader(""))
+			return fmt.Errorf("error decoding %s body: %v", encodings[i], err)
+		}
+		body.Reader = decoder
+		body.closers = append([]io.Closer{decoder}, body.closers...)
+	}
+
+	body.Reader = &limitedReader{Reader: body.Reader, max: int64(p.DecodeMaxSize) * 1024 * 1024}
+
+	p.Debug("decoded %s body of %s%s", strings.Join(encodings, ", "), res.Request.Host, res.Request.URL.Path)
+
+	res.Body = body
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strings"
 	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/elazarl/goproxy"
 
@@ -22,8 +24,22 @@ func (p *HTTPProxy) fixRequestHeaders(req *http.Request) {
 func (p *HTTPProxy) onRequestFilter(req *http.Request, ctx *goproxy.ProxyCtx) (*http.Request, *http.Response) {
 	if p.shouldProxy(req) {
 		p.Debug("< %s %s %s%s", req.RemoteAddr, req.Method, req.Host, req.URL.Path)
+		atomic.AddUint64(&p.Requests, 1)
+
+		p.stats.Begin(req, ctx)
+		defer p.stats.Filtered(ctx)
+
+		p.trackHAR(req, ctx)
+		if p.history != nil {
+			p.history.Add(req)
+		}
 
 		p.fixRequestHeaders(req)
+		p.harvestAuth(req)
+
+		if res := p.rejectAuth(req); res != nil {
+			return req, res
+		}
 
 		redir := p.Stripper.Preprocess(req, ctx)
 		if redir != nil {
@@ -32,6 +48,10 @@ func (p *HTTPProxy) onRequestFilter(req *http.Request, ctx *goproxy.ProxyCtx) (*
 			return req, redir
 		}
 
+		if res := p.applyRedirectRules(req); res != nil {
+			return req, res
+		}
+
 		// do we have a proxy script?
 		if p.Script == nil {
 			return req, nil
@@ -42,12 +62,18 @@ func (p *HTTPProxy) onRequestFilter(req *http.Request, ctx *goproxy.ProxyCtx) (*
 		if jsreq != nil {
 			// the request has been changed by the script
 			p.logRequestAction(req, jsreq)
-			return jsreq.ToRequest(), nil
+			if jsreq.Body != "" {
+				// the script read the body, it's been buffered already
+				return jsreq.ToRequest(), nil
+			}
+			return p.streamRequest(jsreq.ToRequest()), nil
 		} else if jsres != nil {
 			// a fake response has been returned by the script
 			p.logResponseAction(req, jsres)
 			return req, jsres.ToResponse(req)
 		}
+
+		return p.streamRequest(req), nil
 	}
 
 	return req, nil
@@ -102,13 +128,21 @@ func (p *HTTPProxy) doScriptInjection(res *http.Response, cType string) (error)
 func (p *HTTPProxy) onResponseFilter(res *http.Response, ctx *goproxy.ProxyCtx) *http.Response {
 	// sometimes it happens ¯\_(ツ)_/¯
 	if res == nil {
+		p.stats.Fail(ctx)
 		return nil
 	}
 
 	if p.shouldProxy(res.Request) {
+		responded := time.Now()
 		p.Debug("> %s %s %s%s", res.Request.RemoteAddr, res.Request.Method, res.Request.Host, res.Request.URL.Path)
 
+		// sslstrip, the rules and the scripts need the plain body
+		if err := p.decodeResponse(res); err != nil {
+			p.Debug("%s%s: %v", res.Request.Host, res.Request.URL.Path, err)
+		}
+
 		p.Stripper.Process(res, ctx)
+		p.downgradeAuth(res)
 
 		// do we have a proxy script?
 		if p.Script != nil {
@@ -116,19 +150,23 @@ func (p *HTTPProxy) onResponseFilter(res *http.Response, ctx *goproxy.ProxyCtx)
 			if jsres != nil {
 				// the response has been changed by the script
 				p.logResponseAction(res.Request, jsres)
-				return jsres.ToResponse(res.Request)
+				return p.stats.End(p.recordHAR(jsres.ToResponse(res.Request), ctx), ctx, responded)
 			}
 		}
 
+		p.applyResponseRules(res)
+
 		// inject javascript code if specified and needed
 		if doInject, cType := p.isScriptInjectable(res); doInject {
 			if err := p.doScriptInjection(res, cType); err != nil {
 				p.Error("error while injecting javascript: %s", err)
 			}
 		}
+
+		return p.stats.End(p.recordHAR(p.streamResponse(res), ctx), ctx, responded)
 	}
 
-	return res
+	return p.stats.End(res, ctx, time.Now())
 }
 
 func (p *HTTPProxy) logRequestAction(req *http.Request, jsreq *JSRequest) {
@@ -138,12 +176,16 @@ func (p *HTTPProxy) logRequestAction(req *http.Request, jsreq *JSRequest) {
 		Host   string
 		Path   string
 		Size   int
+		JA3    string
+		JA3S   string
 	}{
 		strings.Split(req.RemoteAddr, ":")[0],
 		jsreq.Method,
 		jsreq.Hostname,
 		jsreq.Path,
 		len(jsreq.Body),
+		p.clientJA3Of(strings.Split(req.RemoteAddr, ":")[0]),
+		p.serverJA3SOf(jsreq.Hostname),
 	})
 }
 
@@ -154,11 +196,15 @@ func (p *HTTPProxy) logResponseAction(req *http.Request, jsres *JSResponse) {
 		Host   string
 		Path   string
 		Size   int
+		JA3    string
+		JA3S   string
 	}{
 		strings.Split(req.RemoteAddr, ":")[0],
 		req.Method,
 		req.Host,
 		req.URL.Path,
 		len(jsres.Body),
+		p.clientJA3Of(strings.Split(req.RemoteAddr, ":")[0]),
+		p.serverJA3SOf(req.URL.Hostname()),
 	})
 }
@@ -0,0 +1,317 @@
+package http_proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/core"
+
+	"github.com/elazarl/goproxy"
+
+	"github.com/evilsocket/islazy/fs"
+)
+
+const (
+	// bodies bigger than this are truncated in the HAR file
+	harMaxBodySize = 1024 * 1024
+	// closes the entries array and the log, it's written after every entry
+	// so that the file is always valid
+	harTrailer = "]}}\n"
+)
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harTimings struct {
+	Send    int64 `json:"send"`
+	Wait    int64 `json:"wait"`
+	Receive int64 `json:"receive"`
+}
+
+type harEntry struct {
+	StartedDateTime string                 `json:"startedDateTime"`
+	Time            int64                  `json:"time"`
+	Request         harRequest             `json:"request"`
+	Response        harResponse            `json:"response"`
+	Cache           map[string]interface{} `json:"cache"`
+	Timings         harTimings             `json:"timings"`
+}
+
+// harContext is what we need to remember of a request until its response
+// has been sent to the client.
+type harContext struct {
+	started time.Time
+	body    *bodyCapture
+}
+
+// harWriter appends entries to a HAR file, rotating it when it gets too big.
+type harWriter struct {
+	sync.Mutex
+	path    string
+	maxSize int64
+	file    *os.File
+	entries int
+}
+
+func newHARWriter(path string, maxSize int64) (*harWriter, error) {
+	w := &harWriter{
+		path:    path,
+		maxSize: maxSize,
+	}
+
+	// a HAR file can't be appended to, move the old one away
+	if fs.Exists(path) {
+		if err := w.rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return w, w.open()
+}
+
+func (w *harWriter) open() (err error) {
+	if w.file, err = os.OpenFile(w.path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644); err != nil {
+		return err
+	}
+
+	header, _ := json.Marshal(map[string]string{
+		"name":    core.Name,
+		"version": core.Version,
+	})
+
+	w.entries = 0
+	_, err = fmt.Fprintf(w.file, `{"log":{"version":"1.2","creator":%s,"pages":[],"entries":[%s`, header, harTrailer)
+	return err
+}
+
+func (w *harWriter) rotate() error {
+	base := strings.TrimSuffix(w.path, ".har")
+	name := fmt.Sprintf("%s-%s.har", base, time.Now().Format("2006-01-02T15-04-05.000"))
+	return os.Rename(w.path, name)
+}
+
+func (w *harWriter) Add(entry *harEntry) error {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// overwrite the trailer
+	if _, err = w.file.Seek(-int64(len(harTrailer)), io.SeekEnd); err != nil {
+		return err
+	}
+	if w.entries > 0 {
+		raw = append([]byte{','}, raw...)
+	}
+	if _, err = w.file.Write(append(raw, harTrailer...)); err != nil {
+		return err
+	}
+	w.entries++
+
+	if w.maxSize > 0 {
+		if info, err := w.file.Stat(); err == nil && info.Size() >= w.maxSize {
+			w.file.Close()
+			if err = w.rotate(); err != nil {
+				w.file = nil
+				return err
+			}
+			return w.open()
+		}
+	}
+
+	return nil
+}
+
+func (w *harWriter) Close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	headers := make([]harNameValue, 0)
+	for name, values := range header {
+		for _, value := range values {
+			headers = append(headers, harNameValue{name, value})
+		}
+	}
+	return headers
+}
+
+func harCookies(cookies []*http.Cookie) []harNameValue {
+	list := make([]harNameValue, 0)
+	for _, cookie := range cookies {
+		list = append(list, harNameValue{cookie.Name, cookie.Value})
+	}
+	return list
+}
+
+func harMillis(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
+
+// trackHAR remembers when the request started and captures its body, if
+// needed, for the HAR entry written once the response is over.
+func (p *HTTPProxy) trackHAR(req *http.Request, ctx *goproxy.ProxyCtx) {
+	if p.har == nil {
+		return
+	}
+
+	hctx := &harContext{started: time.Now()}
+	if p.HARBodies && hasBody(req.Body) {
+		hctx.body = &bodyCapture{ReadCloser: req.Body, limit: harMaxBodySize}
+		req.Body = hctx.body
+	}
+	ctx.UserData = hctx
+}
+
+// recordHAR writes the HAR entry of res once its body has been sent to the
+// client.
+func (p *HTTPProxy) recordHAR(res *http.Response, ctx *goproxy.ProxyCtx) *http.Response {
+	hctx, ok := ctx.UserData.(*harContext)
+	if p.har == nil || !ok {
+		return res
+	}
+
+	har := p.har
+	req := res.Request
+	waited := time.Now()
+	write := func(body *bodyCapture) {
+		now := time.Now()
+		entry := &harEntry{
+			StartedDateTime: hctx.started.Format(time.RFC3339Nano),
+			Time:            harMillis(now.Sub(hctx.started)),
+			Request: harRequest{
+				Method:      req.Method,
+				URL:         req.URL.String(),
+				HTTPVersion: req.Proto,
+				Cookies:     harCookies(req.Cookies()),
+				Headers:     harHeaders(req.Header),
+				QueryString: make([]harNameValue, 0),
+				HeadersSize: -1,
+				BodySize:    -1,
+			},
+			Response: harResponse{
+				Status:      res.StatusCode,
+				StatusText:  http.StatusText(res.StatusCode),
+				HTTPVersion: res.Proto,
+				Cookies:     harCookies(res.Cookies()),
+				Headers:     harHeaders(res.Header),
+				Content: harContent{
+					Size:     -1,
+					MimeType: res.Header.Get("Content-Type"),
+				},
+				RedirectURL: res.Header.Get("Location"),
+				HeadersSize: -1,
+				BodySize:    -1,
+			},
+			Cache: make(map[string]interface{}),
+			Timings: harTimings{
+				Send:    0,
+				Wait:    harMillis(waited.Sub(hctx.started)),
+				Receive: harMillis(now.Sub(waited)),
+			},
+		}
+
+		for name, values := range req.URL.Query() {
+			for _, value := range values {
+				entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{name, value})
+			}
+		}
+
+		if hctx.body != nil {
+			text, encoding := hctx.body.text()
+			entry.Request.BodySize = hctx.body.size
+			entry.Request.PostData = &harPostData{
+				MimeType: req.Header.Get("Content-Type"),
+				Text:     text,
+				Encoding: encoding,
+			}
+		}
+
+		if body != nil {
+			entry.Response.BodySize = body.size
+			entry.Response.Content.Size = body.size
+			if p.HARBodies {
+				entry.Response.Content.Text, entry.Response.Content.Encoding = body.text()
+			}
+		} else {
+			entry.Response.BodySize = 0
+			entry.Response.Content.Size = 0
+		}
+
+		if err := har.Add(entry); err != nil {
+			p.Error("error writing HAR entry: %v", err)
+		}
+	}
+
+	if !hasBody(res.Body) {
+		write(nil)
+		return res
+	}
+
+	limit := 0
+	if p.HARBodies {
+		limit = harMaxBodySize
+	}
+	res.Body = &bodyCapture{ReadCloser: res.Body, limit: limit, done: write}
+	return res
+}
@@ -0,0 +1,230 @@
+package http_proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+const (
+	// request bodies bigger than this are truncated in the history
+	historyMaxBodySize = 1024 * 1024
+	// responses of replayed requests are only shown up to this size
+	replayMaxDumpSize = 64 * 1024
+	replayTimeout     = 30 * time.Second
+)
+
+// capturedRequest is a request seen by the proxy that can be replayed.
+type capturedRequest struct {
+	ID     int
+	Time   time.Time
+	Client string
+	Method string
+	URL    string
+	Header http.Header
+	body   *bodyCapture
+}
+
+func (r *capturedRequest) Body() []byte {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.buf.Bytes()
+}
+
+// requestHistory keeps the last size requests intercepted by the proxy.
+type requestHistory struct {
+	sync.Mutex
+	size     int
+	nextID   int
+	requests []*capturedRequest
+}
+
+func newRequestHistory(size int) *requestHistory {
+	return &requestHistory{
+		size:     size,
+		nextID:   1,
+		requests: make([]*capturedRequest, 0),
+	}
+}
+
+// Add saves req to the history, its body is captured while it's sent.
+func (h *requestHistory) Add(req *http.Request) {
+	h.Lock()
+	defer h.Unlock()
+
+	captured := &capturedRequest{
+		ID:     h.nextID,
+		Time:   time.Now(),
+		Client: strings.Split(req.RemoteAddr, ":")[0],
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: req.Header.Clone(),
+	}
+
+	if hasBody(req.Body) {
+		captured.body = &bodyCapture{ReadCloser: req.Body, limit: historyMaxBodySize}
+		req.Body = captured.body
+	}
+
+	h.nextID++
+	h.requests = append(h.requests, captured)
+	if len(h.requests) > h.size {
+		h.requests = h.requests[len(h.requests)-h.size:]
+	}
+}
+
+func (h *requestHistory) Get(id int) *capturedRequest {
+	h.Lock()
+	defer h.Unlock()
+
+	for _, captured := range h.requests {
+		if captured.ID == id {
+			return captured
+		}
+	}
+	return nil
+}
+
+func (h *requestHistory) All() []*capturedRequest {
+	h.Lock()
+	defer h.Unlock()
+
+	return append([]*capturedRequest{}, h.requests...)
+}
+
+func (p *HTTPProxy) ShowRequests() error {
+	if p.history == nil {
+		return fmt.Errorf("the requests history is disabled")
+	}
+
+	requests := p.history.All()
+	if len(requests) == 0 {
+		p.Info("no requests intercepted yet")
+		return nil
+	}
+
+	colNames := []string{"ID", "Time", "Client", "Method", "URL", "Body"}
+	rows := make([][]string, 0, len(requests))
+	for _, captured := range requests {
+		size := "0"
+		if captured.body != nil {
+			size = strconv.FormatInt(captured.body.size, 10)
+		}
+
+		rows = append(rows, []string{
+			tui.Bold(strconv.Itoa(captured.ID)),
+			captured.Time.Format("15:04:05"),
+			captured.Client,
+			tui.Yellow(captured.Method),
+			captured.URL,
+			size,
+		})
+	}
+
+	tui.Table(p.Sess.Events.Stdout, colNames, rows)
+	p.Sess.Refresh()
+	return nil
+}
+
+// Replay sends the request with the given id again from this host, after
+// applying the space separated name=value modifications, whose values are
+// URL encoded.
+func (p *HTTPProxy) Replay(id int, modifications string) error {
+	if p.history == nil {
+		return fmt.Errorf("the requests history is disabled")
+	}
+
+	captured := p.history.Get(id)
+	if captured == nil {
+		return fmt.Errorf("request %d not found", id)
+	}
+
+	method := captured.Method
+	target := captured.URL
+	header := captured.Header.Clone()
+	body := captured.Body()
+
+	if captured.body != nil && captured.body.size > int64(len(body)) {
+		p.Warning("the body of request %d has been truncated to %d bytes", id, len(body))
+	}
+
+	for _, modification := range strings.Fields(modifications) {
+		parts := strings.SplitN(modification, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid modification '%s', expected name=value", modification)
+		}
+
+		value, err := url.QueryUnescape(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid value in '%s': %v", modification, err)
+		}
+
+		switch strings.ToLower(parts[0]) {
+		case "method":
+			method = strings.ToUpper(value)
+		case "url":
+			target = value
+		case "body":
+			body = []byte(value)
+		case "header":
+			nameValue := strings.SplitN(value, ":", 2)
+			if len(nameValue) != 2 {
+				return fmt.Errorf("invalid header '%s', expected Name:Value", value)
+			}
+			header.Set(strings.TrimSpace(nameValue[0]), strings.TrimSpace(nameValue[1]))
+		case "unset":
+			header.Del(value)
+		default:
+			return fmt.Errorf("unknown modification '%s', use method, url, body, header or unset", parts[0])
+		}
+	}
+
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+
+	client := &http.Client{
+		Transport: p.transport,
+		Timeout:   replayTimeout,
+		// show redirects instead of following them
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	raw, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(raw))
+
+	p.Info("replayed request %d: %s %s -> %s (%d bytes)", id, tui.Yellow(method), target, tui.Bold(res.Status), len(raw))
+
+	dump, err := httputil.DumpResponse(res, true)
+	if err != nil {
+		return err
+	} else if len(dump) > replayMaxDumpSize {
+		dump = append(dump[:replayMaxDumpSize], []byte("\n...")...)
+	}
+
+	fmt.Fprintf(p.Sess.Events.Stdout, "\n%s\n\n", dump)
+	return nil
+}
@@ -0,0 +1,93 @@
+package http_proxy
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bettercap/bettercap/packets"
+
+	"golang.org/x/net/http2"
+)
+
+// headers that are only meaningful for HTTP/1.x connections and that are not
+// allowed in HTTP/2 responses (RFC 7540, 8.1.2.2)
+var http2HopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+type http2ResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w http2ResponseWriter) WriteHeader(code int) {
+	for _, name := range http2HopHeaders {
+		w.Header().Del(name)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w http2ResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// upstreamTransport returns the transport used to reach the real servers,
+// negotiating HTTP/2 with them if enabled and keeping up to PoolSize idle
+// connections to each one of them to be reused by the next requests.
+func (p *HTTPProxy) upstreamTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: tcpKeepAlive,
+	}
+
+	return &http.Transport{
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: true},
+		Proxy:                 p.upstreamProxy(),
+		ForceAttemptHTTP2:     p.HTTP2,
+		DialContext:           dialer.DialContext,
+		DialTLSContext:        p.dialTLS,
+		MaxIdleConnsPerHost:   p.PoolSize,
+		IdleConnTimeout:       p.idleTimeout(),
+		TLSHandshakeTimeout:   dialTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// idleTimeout returns how long idle keep-alive connections, both with the
+// clients and with the servers, are kept open.
+func (p *HTTPProxy) idleTimeout() time.Duration {
+	if p.IdleTimeout > 0 {
+		return time.Duration(p.IdleTimeout) * time.Second
+	}
+	return mitmIdleTimeout
+}
+
+func (p *HTTPProxy) offersHTTP2(hello []byte) bool {
+	if protos, found := packets.TLSClientHelloALPN(hello); found {
+		for _, proto := range protos {
+			if proto == http2.NextProtoTLS {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveHTTP2 serves the streams of a client that negotiated h2, each one
+// being passed to goproxy as a regular request so that filters and scripts
+// work as they do for HTTP/1.1.
+func (p *HTTPProxy) serveHTTP2(tlsConn *tls.Conn, handler http.Handler) {
+	server := &http2.Server{IdleTimeout: p.idleTimeout()}
+	server.ServeConn(tlsConn, &http2.ServeConnOpts{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			handler.ServeHTTP(http2ResponseWriter{w}, req)
+		}),
+	})
+}
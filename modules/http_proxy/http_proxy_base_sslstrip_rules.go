@@ -0,0 +1,147 @@
+package http_proxy
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evilsocket/islazy/fs"
+
+	"golang.org/x/net/idna"
+)
+
+// StripRule rewrites the hostnames of stripped links, so that browsers
+// don't apply the HSTS policy of the original host to them:
+//
+//	www.        wwww.        prefix, www.example.com -> wwww.example.com
+//	.paypal.com .paypa1.com  suffix, www.paypal.com -> www.paypa1.com
+//	paypal.com  pаypal.com   exact host, unicode names are converted to punycode
+type StripRule struct {
+	From string
+	To   string
+}
+
+func (r StripRule) isPrefix() bool {
+	return strings.HasSuffix(r.From, ".")
+}
+
+func (r StripRule) isSuffix() bool {
+	return strings.HasPrefix(r.From, ".")
+}
+
+// Apply returns the rewritten host and true if the rule matched.
+func (r StripRule) Apply(host string) (string, bool) {
+	if r.isPrefix() {
+		if strings.HasPrefix(host, r.From) && len(host) > len(r.From) {
+			return r.To + host[len(r.From):], true
+		}
+	} else if r.isSuffix() {
+		if strings.HasSuffix(host, r.From) && len(host) > len(r.From) {
+			return host[:len(host)-len(r.From)] + r.To, true
+		}
+	} else if host == r.From {
+		return r.To, true
+	}
+	return host, false
+}
+
+func ParseStripRule(line string) (StripRule, error) {
+	parts := strings.Fields(line)
+	if len(parts) != 2 {
+		return StripRule{}, fmt.Errorf("expected '<from> <to>', got '%s'", line)
+	}
+
+	rule := StripRule{
+		From: strings.ToLower(parts[0]),
+		To:   strings.ToLower(parts[1]),
+	}
+
+	if rule.isPrefix() != strings.HasSuffix(rule.To, ".") || rule.isSuffix() != strings.HasPrefix(rule.To, ".") {
+		return StripRule{}, fmt.Errorf("'%s' and '%s' must be both prefixes, suffixes or hosts", rule.From, rule.To)
+	} else if rule.From == rule.To {
+		return StripRule{}, fmt.Errorf("'%s' is rewritten to itself", rule.From)
+	}
+
+	return rule, nil
+}
+
+func StripRulesFromFile(filename string) ([]StripRule, error) {
+	input, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	rules := make([]StripRule, 0)
+	scanner := bufio.NewScanner(input)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		rule, err := ParseStripRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, lineno, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, scanner.Err()
+}
+
+// LoadRules loads the hostname rewriting rules from rulesFile, if not empty,
+// and sets the hosts that must never be stripped, wildcard expressions can
+// be used for the latter.
+func (s *SSLStripper) LoadRules(rulesFile string, exceptions []string) error {
+	rules := make([]StripRule, 0)
+	if rulesFile != "" {
+		var err error
+		if rulesFile, err = fs.Expand(rulesFile); err != nil {
+			return err
+		} else if rules, err = StripRulesFromFile(rulesFile); err != nil {
+			return err
+		}
+	}
+
+	s.rulesLock.Lock()
+	defer s.rulesLock.Unlock()
+
+	s.rules = rules
+	s.exceptions = exceptions
+	return nil
+}
+
+func (s *SSLStripper) isException(host string) bool {
+	s.rulesLock.RLock()
+	defer s.rulesLock.RUnlock()
+
+	for _, expr := range s.exceptions {
+		if matched, _ := filepath.Match(expr, host); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// stripHost returns the hostname the stripped links to host will point to,
+// the first matching rule wins.
+func (s *SSLStripper) stripHost(host string) string {
+	s.rulesLock.RLock()
+	defer s.rulesLock.RUnlock()
+
+	host = strings.ToLower(host)
+	for _, rule := range s.rules {
+		if rewritten, matched := rule.Apply(host); matched {
+			host = rewritten
+			break
+		}
+	}
+
+	if ascii, err := idna.ToASCII(host); err == nil {
+		return ascii
+	}
+	return host
+}
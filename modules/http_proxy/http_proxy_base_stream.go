@@ -0,0 +1,118 @@
+package http_proxy
+
+import (
+	"io"
+	"net/http"
+)
+
+// size of the chunks bodies are streamed in
+const streamChunkSize = 32 * 1024
+
+// chunkReader passes each chunk read from a body to a script callback, which
+// can change it, before handing it to whoever is consuming the body.
+type chunkReader struct {
+	io.ReadCloser
+	filter  func(*JSChunk)
+	buf     []byte
+	pending []byte
+	offset  int64
+	err     error
+}
+
+func newChunkReader(body io.ReadCloser, filter func(*JSChunk)) *chunkReader {
+	return &chunkReader{
+		ReadCloser: body,
+		filter:     filter,
+		buf:        make([]byte, streamChunkSize),
+	}
+}
+
+func (r *chunkReader) Read(b []byte) (int, error) {
+	// the script might have emptied a chunk, keep reading until there's
+	// something to return or the body is over
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		n, err := r.ReadCloser.Read(r.buf)
+		r.err = err
+		if n > 0 {
+			chunk := NewJSChunk(r.buf[:n], r.offset)
+			r.offset += int64(n)
+			r.filter(chunk)
+			r.pending = []byte(chunk.Data)
+		}
+	}
+
+	n := copy(b, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// flushWriter sends every chunk to the client as soon as it's written, so
+// that long polling and server sent events are not held in buffers.
+type flushWriter struct {
+	http.ResponseWriter
+}
+
+func (w flushWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
+
+func (w flushWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func hasBody(body io.ReadCloser) bool {
+	return body != nil && body != http.NoBody
+}
+
+// streamRequest passes the body of req to the onRequestChunk callback while
+// it's sent upstream, if the script defines it.
+func (p *HTTPProxy) streamRequest(req *http.Request) *http.Request {
+	if !p.Stream || p.Script == nil || !p.Script.doOnRequestChunk || !hasBody(req.Body) {
+		return req
+	}
+
+	// the callback sees everything but the body, which is being streamed
+	head := *req
+	head.Body = http.NoBody
+	jsreq := NewJSRequest(&head)
+
+	req.Body = newChunkReader(req.Body, func(chunk *JSChunk) {
+		p.Script.OnRequestChunk(jsreq, chunk)
+	})
+	// chunks can change size
+	req.ContentLength = -1
+	req.Header.Del("Content-Length")
+
+	return req
+}
+
+// streamResponse passes the body of res to the onResponseChunk callback
+// while it's sent to the client, if the script defines it.
+func (p *HTTPProxy) streamResponse(res *http.Response) *http.Response {
+	if !p.Stream || p.Script == nil || !p.Script.doOnResponseChunk || !hasBody(res.Body) {
+		return res
+	}
+
+	head := *res
+	head.Body = http.NoBody
+	jsreq := NewJSRequest(res.Request)
+	jsres := NewJSResponse(&head)
+
+	res.Body = newChunkReader(res.Body, func(chunk *JSChunk) {
+		p.Script.OnResponseChunk(jsreq, jsres, chunk)
+	})
+	res.ContentLength = -1
+	res.Header.Del("Content-Length")
+
+	return res
+}
@@ -0,0 +1,233 @@
+package http_proxy
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inconshreveable/go-vhost"
+	"golang.org/x/net/http2"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+const (
+	// a ClientHello bigger than this is not worth parsing
+	maxClientHelloSize = 16 * 1024
+	// connections terminated by the proxy are closed after being idle for this
+	// time, unless IdleTimeout is set
+	mitmIdleTimeout = 60 * time.Second
+)
+
+// helloRecorder keeps a copy of the first bytes read from the connection so
+// that the ClientHello can be parsed after the SNI has been extracted.
+type helloRecorder struct {
+	net.Conn
+	hello   []byte
+	stopped bool
+}
+
+func (r *helloRecorder) Read(b []byte) (int, error) {
+	n, err := r.Conn.Read(b)
+	if n > 0 && !r.stopped && len(r.hello) < maxClientHelloSize {
+		r.hello = append(r.hello, b[:n]...)
+	}
+	return n, err
+}
+
+// Stop returns what has been recorded so far and stops recording.
+func (r *helloRecorder) Stop() []byte {
+	hello := r.hello
+	r.stopped = true
+	r.hello = nil
+	return hello
+}
+
+// singleConnListener makes an http.Server serve a single connection, its
+// second Accept blocks until that connection is closed.
+type singleConnListener struct {
+	conn net.Conn
+	addr net.Addr
+	done chan struct{}
+	once sync.Once
+}
+
+type listenedConn struct {
+	net.Conn
+	l *singleConnListener
+}
+
+func (c *listenedConn) Close() error {
+	err := c.Conn.Close()
+	c.l.Close()
+	return err
+}
+
+func newSingleConnListener(conn net.Conn) *singleConnListener {
+	l := &singleConnListener{
+		addr: conn.LocalAddr(),
+		done: make(chan struct{}),
+	}
+	l.conn = &listenedConn{Conn: conn, l: l}
+	return l
+}
+
+func (l *singleConnListener) Accept() (net.Conn, error) {
+	if conn := l.conn; conn != nil {
+		l.conn = nil
+		return conn, nil
+	}
+	<-l.done
+	return nil, net.ErrClosed
+}
+
+func (l *singleConnListener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *singleConnListener) Addr() net.Addr {
+	return l.addr
+}
+
+// serveTLS terminates the TLS connection of a client instead of handing it
+// over to goproxy, this is needed to negotiate h2 and to take over the
+// connection when a WebSocket upgrade is requested.
+func (p *HTTPProxy) serveTLS(conn net.Conn, hostname string, port string, h2 bool, jsconn *JSConnection) {
+	defer conn.Close()
+
+	config, err := p.TLSConfigFromCA(p.ca)(hostname, nil)
+	if err != nil {
+		return
+	}
+
+	config.NextProtos = []string{"http/1.1"}
+	if h2 {
+		config.NextProtos = append([]string{http2.NextProtoTLS}, config.NextProtos...)
+	}
+
+	tlsConn := tls.Server(conn, config)
+	err = tlsConn.Handshake()
+
+	proto := tlsConn.ConnectionState().NegotiatedProtocol
+	if proto == "" {
+		proto = "http/1.1"
+	}
+
+	if jsconn != nil && p.Script != nil {
+		// a failure here usually means that the client rejected our
+		// certificate, like when pinning is in place
+		jsconn.Protocol = proto
+		if err != nil {
+			jsconn.Error = err.Error()
+		}
+		p.Script.OnTLSHandshake(jsconn)
+		if jsconn.Block {
+			p.Info("connection from %s to %s blocked by the script", tui.Bold(jsconn.Client["IP"]), tui.Yellow(hostname))
+			return
+		}
+	}
+
+	if err != nil {
+		p.Debug("error during TLS handshake with %s: %s", conn.RemoteAddr(), err)
+		return
+	}
+
+	p.Debug("serving %s to %s over %s", tui.Yellow(hostname), tui.Bold(stripPort(conn.RemoteAddr().String())), tui.Green(proto))
+
+	// from now on timeouts are handled by the servers
+	conn.SetDeadline(time.Time{})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.URL.Scheme = "https"
+		req.URL.Host = withPort(req.Host, port, "443")
+		if p.doProxy(req) {
+			p.serveRequest(w, req)
+		}
+	})
+
+	if proto == http2.NextProtoTLS {
+		p.serveHTTP2(tlsConn, handler)
+		return
+	}
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: httpReadTimeout,
+		IdleTimeout:       p.idleTimeout(),
+	}
+	server.Serve(newSingleConnListener(tlsConn))
+}
+
+// withPort adds port to host when it doesn't specify one and it's not the
+// default port of the scheme.
+func withPort(host string, port string, defaultPort string) string {
+	if port == defaultPort {
+		return host
+	} else if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), port)
+}
+
+// ServeConn intercepts a single HTTP or HTTPS connection of a client to
+// hostname:port accepted by another module, like socks5.proxy, so that it
+// goes through the same filters and scripts as the redirected traffic.
+func (p *HTTPProxy) ServeConn(conn net.Conn, hostname string, port int, isTLS bool) {
+	portStr := strconv.Itoa(port)
+
+	if isTLS {
+		rec := &helloRecorder{Conn: conn}
+		tlsConn, err := vhost.TLS(rec)
+		if err != nil {
+			p.Warning("error reading SNI: %s.", err)
+			conn.Close()
+			return
+		} else if sni := tlsConn.Host(); sni != "" {
+			hostname = sni
+		}
+
+		hello := rec.Stop()
+		client := stripPort(conn.RemoteAddr().String())
+		ja3 := p.trackJA3(client, hostname, hello)
+
+		var jsconn *JSConnection
+		if p.interceptsConnections() {
+			jsconn = newTLSConnection(client, hostname, portStr, ja3, hello)
+			if !p.onConnect(jsconn, tlsConn) {
+				return
+			}
+			defer p.onClose(jsconn)
+		}
+
+		p.serveTLS(tlsConn, hostname, portStr, p.HTTP2 && p.offersHTTP2(hello), jsconn)
+		return
+	}
+
+	if p.interceptsConnections() {
+		jsconn := NewJSConnection(stripPort(conn.RemoteAddr().String()), hostname, portStr, false)
+		if !p.onConnect(jsconn, conn) {
+			return
+		}
+		defer p.onClose(jsconn)
+	}
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			req.URL.Scheme = "http"
+			req.URL.Host = withPort(req.Host, portStr, "80")
+			if p.doProxy(req) {
+				p.serveRequest(w, req)
+			}
+		}),
+		ReadHeaderTimeout: httpReadTimeout,
+		IdleTimeout:       p.idleTimeout(),
+	}
+	server.Serve(newSingleConnListener(conn))
+}
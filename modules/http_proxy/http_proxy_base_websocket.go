@@ -0,0 +1,164 @@
+package http_proxy
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+// handshake headers set by the websocket library itself on the upstream side,
+// extensions are dropped so that frames are never compressed
+var wsHandshakeHeaders = []string{
+	"Upgrade",
+	"Connection",
+	"Sec-Websocket-Key",
+	"Sec-Websocket-Version",
+	"Sec-Websocket-Extensions",
+	"Sec-Websocket-Protocol",
+}
+
+func isWebSocketRequest(req *http.Request) bool {
+	return strings.Contains(strings.ToLower(req.Header.Get("Connection")), "upgrade") &&
+		strings.EqualFold(req.Header.Get("Upgrade"), "websocket")
+}
+
+func (p *HTTPProxy) interceptsWebSockets() bool {
+	return p.Script != nil && p.Script.doOnWsFrame
+}
+
+// serveRequest passes req to goproxy unless it's a WebSocket upgrade the
+// script wants to see the frames of.
+func (p *HTTPProxy) serveRequest(w http.ResponseWriter, req *http.Request) {
+	if p.interceptsWebSockets() && isWebSocketRequest(req) && p.shouldProxy(req) {
+		p.serveWebSocket(w, req)
+	} else if p.Stream {
+		p.Proxy.ServeHTTP(flushWriter{w}, req)
+	} else {
+		p.Proxy.ServeHTTP(w, req)
+	}
+}
+
+func (p *HTTPProxy) serveWebSocket(w http.ResponseWriter, req *http.Request) {
+	target := *req.URL
+	target.Scheme = "ws"
+	if req.URL.Scheme == "https" {
+		target.Scheme = "wss"
+	}
+
+	header := make(http.Header)
+	for name, values := range req.Header {
+		header[name] = values
+	}
+	for _, name := range wsHandshakeHeaders {
+		header.Del(name)
+	}
+
+	dialer := websocket.Dialer{
+		Proxy:            p.upstreamProxy(),
+		TLSClientConfig:  &tls.Config{InsecureSkipVerify: true},
+		HandshakeTimeout: httpReadTimeout,
+		Subprotocols:     websocket.Subprotocols(req),
+	}
+
+	upstream, res, err := dialer.Dial(target.String(), header)
+	if err != nil {
+		p.Warning("error connecting to %s: %s", target.String(), err)
+		if res != nil {
+			// forward the handshake error to the client
+			for name, values := range res.Header {
+				w.Header()[name] = values
+			}
+			w.WriteHeader(res.StatusCode)
+			io.Copy(w, res.Body)
+		} else {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		return
+	}
+	defer upstream.Close()
+
+	upgrader := websocket.Upgrader{
+		HandshakeTimeout: httpReadTimeout,
+		CheckOrigin:      func(r *http.Request) bool { return true },
+	}
+	if proto := upstream.Subprotocol(); proto != "" {
+		upgrader.Subprotocols = []string{proto}
+	}
+
+	resHeader := make(http.Header)
+	for _, cookie := range res.Header["Set-Cookie"] {
+		resHeader.Add("Set-Cookie", cookie)
+	}
+
+	client, err := upgrader.Upgrade(w, req, resHeader)
+	if err != nil {
+		p.Warning("error upgrading connection from %s: %s", req.RemoteAddr, err)
+		return
+	}
+	defer client.Close()
+
+	// clear the timeouts of the http server the connection was hijacked from
+	client.UnderlyingConn().SetDeadline(time.Time{})
+
+	p.Info("intercepting websocket %s <-> %s", tui.Bold(stripPort(req.RemoteAddr)), tui.Yellow(target.String()))
+
+	jsreq := NewJSRequest(req)
+	done := make(chan bool, 2)
+
+	go p.pumpWebSocket(jsreq, WsFrameUp, client, upstream, done)
+	go p.pumpWebSocket(jsreq, WsFrameDown, upstream, client, done)
+
+	// when one side is gone, closing both connections stops the other pump
+	<-done
+}
+
+// pumpWebSocket reads the messages of from and, after passing them to the
+// script, writes them to to, control frames are forwarded as they are.
+func (p *HTTPProxy) pumpWebSocket(jsreq *JSRequest, direction string, from, to *websocket.Conn, done chan bool) {
+	defer func() {
+		done <- true
+	}()
+
+	forward := func(msgType int) func(string) error {
+		return func(data string) error {
+			return to.WriteControl(msgType, []byte(data), time.Now().Add(httpWriteTimeout))
+		}
+	}
+	from.SetPingHandler(forward(websocket.PingMessage))
+	from.SetPongHandler(forward(websocket.PongMessage))
+
+	for {
+		msgType, data, err := from.ReadMessage()
+		if err != nil {
+			if closeErr, ok := err.(*websocket.CloseError); ok {
+				to.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(closeErr.Code, closeErr.Text),
+					time.Now().Add(httpWriteTimeout))
+			} else if _, ok := err.(net.Error); !ok && err != io.EOF {
+				p.Debug("error reading websocket frame: %s", err)
+			}
+			return
+		}
+
+		frame := NewJSWsFrame(direction, msgType, data)
+		p.Script.OnWsFrame(jsreq, frame)
+		if frame.Drop {
+			p.Debug("dropping %s websocket frame of %d bytes", direction, len(data))
+			continue
+		} else if frame.WasModified() {
+			data = []byte(frame.Data)
+		}
+
+		if err := to.WriteMessage(msgType, data); err != nil {
+			p.Debug("error writing websocket frame: %s", err)
+			return
+		}
+	}
+}
@@ -1,31 +1,90 @@
 package http_proxy
 
 import (
+	"crypto/sha1"
 	"crypto/tls"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
 	"sync"
+	"time"
+
+	btls "github.com/bettercap/bettercap/tls"
+
+	"github.com/evilsocket/islazy/fs"
 )
 
 var (
 	certCache = make(map[string]*tls.Certificate)
 	certLock  = &sync.Mutex{}
+
+	unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9\.\-]`)
 )
 
-func keyFor(domain string, port int) string {
-	return fmt.Sprintf("%s:%d", domain, port)
+// caID identifies the CA the certificates have been signed with, so that
+// changing it doesn't serve stale certificates.
+func caID(ca *tls.Certificate) string {
+	return fmt.Sprintf("%x", sha1.Sum(ca.Certificate[0]))[:16]
+}
+
+func keyFor(ca *tls.Certificate, domain string, port int) string {
+	return fmt.Sprintf("%s:%s:%d", caID(ca), domain, port)
 }
 
-func getCachedCert(domain string, port int) *tls.Certificate {
+func getCachedCert(ca *tls.Certificate, domain string, port int) *tls.Certificate {
 	certLock.Lock()
 	defer certLock.Unlock()
-	if cert, found := certCache[keyFor(domain, port)]; found {
+	if cert, found := certCache[keyFor(ca, domain, port)]; found {
 		return cert
 	}
 	return nil
 }
 
-func setCachedCert(domain string, port int, cert *tls.Certificate) {
+func setCachedCert(ca *tls.Certificate, domain string, port int, cert *tls.Certificate) {
 	certLock.Lock()
 	defer certLock.Unlock()
-	certCache[keyFor(domain, port)] = cert
+	certCache[keyFor(ca, domain, port)] = cert
+}
+
+func (p *HTTPProxy) storedCertPath(ca *tls.Certificate, domain string, port int) string {
+	fileName := fmt.Sprintf("%s_%d.pem", unsafeFileChars.ReplaceAllString(domain, "_"), port)
+	return filepath.Join(p.CertCacheDir, caID(ca), fileName)
+}
+
+// loadStoredCert returns the certificate for domain saved in the on disk
+// cache by a previous session, if still valid.
+func (p *HTTPProxy) loadStoredCert(ca *tls.Certificate, domain string, port int) *tls.Certificate {
+	if p.CertCacheDir == "" {
+		return nil
+	}
+
+	fileName := p.storedCertPath(ca, domain, port)
+	if !fs.Exists(fileName) {
+		return nil
+	}
+
+	cert, err := btls.LoadCertificate(fileName)
+	if err != nil {
+		p.Debug("error loading %s: %v", fileName, err)
+		return nil
+	} else if time.Now().After(cert.Leaf.NotAfter) {
+		p.Debug("stored certificate for %s:%d expired", domain, port)
+		return nil
+	}
+
+	return cert
+}
+
+func (p *HTTPProxy) storeCert(ca *tls.Certificate, domain string, port int, cert *tls.Certificate) {
+	if p.CertCacheDir == "" {
+		return
+	}
+
+	fileName := p.storedCertPath(ca, domain, port)
+	if err := os.MkdirAll(filepath.Dir(fileName), 0700); err != nil {
+		p.Warning("error creating certificates cache: %v", err)
+	} else if err = btls.SaveCertificate(cert, fileName); err != nil {
+		p.Warning("error saving certificate for %s:%d: %v", domain, port, err)
+	}
 }
@@ -0,0 +1,13 @@
+package http_proxy
+
+type JSChunk struct {
+	Data   string
+	Offset int64
+}
+
+func NewJSChunk(data []byte, offset int64) *JSChunk {
+	return &JSChunk{
+		Data:   string(data),
+		Offset: offset,
+	}
+}
@@ -0,0 +1,41 @@
+package http_proxy
+
+import (
+	"github.com/bettercap/bettercap/session"
+)
+
+// JSConnection is a connection of a client to the proxy, scripts can set
+// Block to close it or, before the TLS handshake, Bypass to tunnel it to the
+// server without intercepting it.
+type JSConnection struct {
+	Client    map[string]string
+	Hostname  string
+	Port      string
+	TLS       bool
+	Protocols []string
+	Protocol  string
+	JA3       string
+	Error     string
+	Block     bool
+	Bypass    bool
+}
+
+func jsClient(ip string) map[string]string {
+	mac := ""
+	alias := ""
+	if endpoint := session.I.Lan.GetByIp(ip); endpoint != nil {
+		mac = endpoint.HwAddress
+		alias = endpoint.Alias
+	}
+	return map[string]string{"IP": ip, "MAC": mac, "Alias": alias}
+}
+
+func NewJSConnection(ip string, hostname string, port string, isTLS bool) *JSConnection {
+	return &JSConnection{
+		Client:    jsClient(ip),
+		Hostname:  hostname,
+		Port:      port,
+		TLS:       isTLS,
+		Protocols: make([]string, 0),
+	}
+}
@@ -8,8 +8,6 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
-
-	"github.com/bettercap/bettercap/session"
 )
 
 type JSRequest struct {
@@ -46,16 +44,8 @@ func NewJSRequest(req *http.Request) *JSRequest {
 		}
 	}
 
-	client_ip := strings.Split(req.RemoteAddr, ":")[0]
-	client_mac := ""
-	client_alias := ""
-	if endpoint := session.I.Lan.GetByIp(client_ip); endpoint != nil {
-		client_mac = endpoint.HwAddress
-		client_alias = endpoint.Alias
-	}
-
 	jreq := &JSRequest{
-		Client:      map[string]string{"IP": client_ip, "MAC": client_mac, "Alias": client_alias},
+		Client:      jsClient(strings.Split(req.RemoteAddr, ":")[0]),
 		Method:      req.Method,
 		Version:     fmt.Sprintf("%d.%d", req.ProtoMajor, req.ProtoMinor),
 		Scheme:      req.URL.Scheme,
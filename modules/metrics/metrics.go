@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/session"
+)
+
+type Metrics struct {
+	session.SessionModule
+	server *http.Server
+	path   string
+	events map[string]uint64
+	lock   sync.Mutex
+	quit   chan struct{}
+}
+
+func NewMetrics(s *session.Session) *Metrics {
+	mod := &Metrics{
+		SessionModule: session.NewSessionModule("metrics", s),
+		server:        &http.Server{},
+		events:        make(map[string]uint64),
+	}
+
+	mod.SetInterfaceIndependent(true)
+
+	mod.AddParam(session.NewStringParameter("metrics.address",
+		"127.0.0.1",
+		session.IPv4Validator,
+		"Address to bind the metrics server to."))
+
+	mod.AddParam(session.NewIntParameter("metrics.port",
+		"9187",
+		"Port to bind the metrics server to."))
+
+	mod.AddParam(session.NewStringParameter("metrics.path",
+		"/metrics",
+		"^/.*",
+		"Path the metrics are exposed at."))
+
+	mod.AddHandler(session.NewModuleHandler("metrics on", "",
+		"Start exposing the session metrics in the Prometheus text format.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("metrics off", "",
+		"Stop exposing the session metrics.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	return mod
+}
+
+func (mod *Metrics) Name() string {
+	return "metrics"
+}
+
+func (mod *Metrics) Description() string {
+	return "Exposes the counters and gauges of the session, like packets, drops, hosts, handshakes, proxied requests and spoofed targets, to Prometheus."
+}
+
+func (mod *Metrics) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *Metrics) Configure() error {
+	var err error
+	var address string
+	var port int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, address = mod.StringParam("metrics.address"); err != nil {
+		return err
+	} else if err, port = mod.IntParam("metrics.port"); err != nil {
+		return err
+	} else if err, mod.path = mod.StringParam("metrics.path"); err != nil {
+		return err
+	}
+
+	router := http.NewServeMux()
+	router.HandleFunc(mod.path, mod.serveMetrics)
+
+	mod.server = &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", address, port),
+		Handler: router,
+	}
+
+	return nil
+}
+
+func (mod *Metrics) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	mod.quit = make(chan struct{})
+	go mod.countEvents(mod.quit)
+
+	return mod.SetRunning(true, func() {
+		mod.Info("exposing metrics on http://%s%s", mod.server.Addr, mod.path)
+		if err := mod.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			mod.Error("%v", err)
+			mod.Stop()
+		}
+	})
+}
+
+func (mod *Metrics) Stop() error {
+	return mod.SetRunning(false, func() {
+		close(mod.quit)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		mod.server.Shutdown(ctx)
+	})
+}
+
+// countEvents counts the events of the session by tag until quit is closed.
+func (mod *Metrics) countEvents(quit chan struct{}) {
+	listener := mod.Session.Events.Listen()
+	defer mod.Session.Events.Unlisten(listener)
+
+	for {
+		select {
+		case <-quit:
+			return
+		case event := <-listener:
+			mod.lock.Lock()
+			mod.events[event.Tag]++
+			mod.lock.Unlock()
+		}
+	}
+}
+
+func (mod *Metrics) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	mod.Debug("%s %s %s", r.RemoteAddr, r.Method, r.URL.Path)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := w.Write(mod.collect().Bytes()); err != nil {
+		mod.Debug("error writing metrics to %s: %v", r.RemoteAddr, err)
+	}
+}
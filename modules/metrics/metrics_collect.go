@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+)
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// exposition writes metrics in the Prometheus text format.
+type exposition struct {
+	bytes.Buffer
+}
+
+// Metric writes the header of a metric, kind is counter or gauge.
+func (e *exposition) Metric(name string, kind string, help string) {
+	fmt.Fprintf(e, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// Sample writes a value of the metric name, labels are name and value pairs.
+func (e *exposition) Sample(name string, value interface{}, labels ...string) {
+	e.WriteString(name)
+	if len(labels) > 0 {
+		pairs := make([]string, 0, len(labels)/2)
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1])))
+		}
+		e.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	fmt.Fprintf(e, " %v\n", value)
+}
+
+// Single writes a metric with a single value.
+func (e *exposition) Single(name string, kind string, help string, value interface{}) {
+	e.Metric(name, kind, help)
+	e.Sample(name, value)
+}
+
+// moduleState returns the value some other module stored in its state as
+// key, nil if the module or the key are not found.
+func (mod *Metrics) moduleState(name string, key string) interface{} {
+	if err, m := mod.Session.Module(name); err == nil {
+		return m.Extra()[key]
+	}
+	return nil
+}
+
+// stateNumber converts the counters and gauges modules keep in their state.
+func stateNumber(v interface{}) uint64 {
+	switch n := v.(type) {
+	case int:
+		return uint64(n)
+	case uint64:
+		return n
+	case *uint64:
+		if n != nil {
+			return atomic.LoadUint64(n)
+		}
+	}
+	return 0
+}
+
+func (mod *Metrics) collect() *exposition {
+	e := &exposition{}
+	sess := mod.Session
+
+	e.Single("bettercap_uptime_seconds", "gauge", "Seconds since the session started.",
+		int64(time.Since(sess.StartedAt).Seconds()))
+
+	if sess.Queue != nil {
+		e.Single("bettercap_packets_received_total", "counter", "Packets processed by the session.",
+			atomic.LoadUint64(&sess.Queue.Stats.PktReceived))
+		e.Single("bettercap_received_bytes_total", "counter", "Bytes received.",
+			atomic.LoadUint64(&sess.Queue.Stats.Received))
+		e.Single("bettercap_sent_bytes_total", "counter", "Bytes sent.",
+			atomic.LoadUint64(&sess.Queue.Stats.Sent))
+		e.Single("bettercap_packet_errors_total", "counter", "Errors while sending packets.",
+			atomic.LoadUint64(&sess.Queue.Stats.Errors))
+	}
+
+	e.Single("bettercap_pcap_dropped_total", "counter", "Packets dropped by the kernel while net.sniff was capturing.",
+		stateNumber(mod.moduleState("net.sniff", "dropped")))
+
+	if sess.Lan != nil {
+		e.Single("bettercap_endpoints", "gauge", "Endpoints known on the LAN.", len(sess.Lan.List()))
+	}
+
+	if sess.WiFi != nil {
+		aps := sess.WiFi.List()
+		clients, handshakes := 0, 0
+		for _, ap := range aps {
+			clients += ap.NumClients()
+			handshakes += ap.NumHandshakes()
+		}
+		e.Single("bettercap_wifi_aps", "gauge", "WiFi access points known.", len(aps))
+		e.Single("bettercap_wifi_clients", "gauge", "WiFi clients known.", clients)
+		e.Single("bettercap_wifi_handshakes", "gauge", "Complete WPA handshakes captured.", handshakes)
+	}
+
+	if sess.BLE != nil {
+		devices := 0
+		sess.BLE.EachDevice(func(mac string, dev *network.BLEDevice) {
+			devices++
+		})
+		e.Single("bettercap_ble_devices", "gauge", "BLE devices known.", devices)
+	}
+
+	if sess.HID != nil {
+		e.Single("bettercap_hid_devices", "gauge", "HID devices known.", len(sess.HID.Devices()))
+	}
+
+	e.Single("bettercap_credentials", "gauge", "Credentials captured.", sess.Credentials.Len())
+
+	e.Metric("bettercap_proxy_requests_total", "counter", "Requests intercepted by the proxies.")
+	for _, name := range []string{"http.proxy", "https.proxy"} {
+		e.Sample("bettercap_proxy_requests_total", stateNumber(mod.moduleState(name, "requests")), "proxy", name)
+	}
+
+	e.Metric("bettercap_spoofed_targets", "gauge", "Targets being spoofed.")
+	e.Sample("bettercap_spoofed_targets", stateNumber(mod.moduleState("arp.spoof", "targets")), "module", "arp.spoof")
+
+	e.Metric("bettercap_module_running", "gauge", "Whether a module is running.")
+	for _, m := range sess.Modules {
+		running := 0
+		if m.Running() {
+			running = 1
+		}
+		e.Sample("bettercap_module_running", running, "module", m.Name())
+	}
+
+	mod.lock.Lock()
+	tags := make([]string, 0, len(mod.events))
+	for tag := range mod.events {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	e.Metric("bettercap_events_total", "counter", "Events by tag since the metrics module started.")
+	for _, tag := range tags {
+		e.Sample("bettercap_events_total", mod.events[tag], "tag", tag)
+	}
+	mod.lock.Unlock()
+
+	return e
+}
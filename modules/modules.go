@@ -4,33 +4,56 @@ import (
 	"github.com/bettercap/bettercap/modules/any_proxy"
 	"github.com/bettercap/bettercap/modules/api_rest"
 	"github.com/bettercap/bettercap/modules/arp_spoof"
+	"github.com/bettercap/bettercap/modules/arp_watch"
 	"github.com/bettercap/bettercap/modules/ble"
 	"github.com/bettercap/bettercap/modules/c2"
 	"github.com/bettercap/bettercap/modules/caplets"
+	"github.com/bettercap/bettercap/modules/creds"
 	"github.com/bettercap/bettercap/modules/dhcp6_spoof"
+	"github.com/bettercap/bettercap/modules/dhcp_spoof"
+	"github.com/bettercap/bettercap/modules/dns_proxy"
 	"github.com/bettercap/bettercap/modules/dns_spoof"
+	"github.com/bettercap/bettercap/modules/events_elastic"
+	"github.com/bettercap/bettercap/modules/events_mqtt"
 	"github.com/bettercap/bettercap/modules/events_stream"
+	"github.com/bettercap/bettercap/modules/events_webhook"
 	"github.com/bettercap/bettercap/modules/gps"
 	"github.com/bettercap/bettercap/modules/hid"
 	"github.com/bettercap/bettercap/modules/http_proxy"
 	"github.com/bettercap/bettercap/modules/http_server"
 	"github.com/bettercap/bettercap/modules/https_proxy"
 	"github.com/bettercap/bettercap/modules/https_server"
+	"github.com/bettercap/bettercap/modules/icmp_spoof"
+	"github.com/bettercap/bettercap/modules/iot_scan"
+	"github.com/bettercap/bettercap/modules/l2_recon"
 	"github.com/bettercap/bettercap/modules/mac_changer"
+	"github.com/bettercap/bettercap/modules/mac_flood"
 	"github.com/bettercap/bettercap/modules/mdns_server"
+	"github.com/bettercap/bettercap/modules/metrics"
+	"github.com/bettercap/bettercap/modules/mqtt_proxy"
 	"github.com/bettercap/bettercap/modules/mysql_server"
+	"github.com/bettercap/bettercap/modules/name_spoof"
 	"github.com/bettercap/bettercap/modules/ndp_spoof"
 	"github.com/bettercap/bettercap/modules/net_probe"
 	"github.com/bettercap/bettercap/modules/net_recon"
+	"github.com/bettercap/bettercap/modules/net_report"
 	"github.com/bettercap/bettercap/modules/net_sniff"
+	"github.com/bettercap/bettercap/modules/ntlm_relay"
 	"github.com/bettercap/bettercap/modules/packet_proxy"
+	"github.com/bettercap/bettercap/modules/smb_recon"
+	"github.com/bettercap/bettercap/modules/snmp_scan"
+	"github.com/bettercap/bettercap/modules/socks5_proxy"
+	"github.com/bettercap/bettercap/modules/spoof_stats"
 	"github.com/bettercap/bettercap/modules/syn_scan"
 	"github.com/bettercap/bettercap/modules/tcp_proxy"
 	"github.com/bettercap/bettercap/modules/ticker"
+	"github.com/bettercap/bettercap/modules/traceroute"
 	"github.com/bettercap/bettercap/modules/ui"
 	"github.com/bettercap/bettercap/modules/update"
+	"github.com/bettercap/bettercap/modules/vlan_hop"
 	"github.com/bettercap/bettercap/modules/wifi"
 	"github.com/bettercap/bettercap/modules/wol"
+	"github.com/bettercap/bettercap/modules/wpad_server"
 
 	"github.com/bettercap/bettercap/session"
 )
@@ -38,24 +61,40 @@ import (
 func LoadModules(sess *session.Session) {
 	sess.Register(any_proxy.NewAnyProxy(sess))
 	sess.Register(arp_spoof.NewArpSpoofer(sess))
+	sess.Register(arp_watch.NewArpWatcher(sess))
 	sess.Register(api_rest.NewRestAPI(sess))
 	sess.Register(ble.NewBLERecon(sess))
 	sess.Register(dhcp6_spoof.NewDHCP6Spoofer(sess))
+	sess.Register(dhcp_spoof.NewDHCPSpoofer(sess))
 	sess.Register(net_recon.NewDiscovery(sess))
 	sess.Register(dns_spoof.NewDNSSpoofer(sess))
+	sess.Register(dns_proxy.NewDNSProxy(sess))
 	sess.Register(events_stream.NewEventsStream(sess))
+	sess.Register(events_webhook.NewEventsWebhook(sess))
+	sess.Register(events_mqtt.NewEventsMQTT(sess))
+	sess.Register(events_elastic.NewEventsElastic(sess))
 	sess.Register(gps.NewGPS(sess))
 	sess.Register(http_proxy.NewHttpProxy(sess))
 	sess.Register(http_server.NewHttpServer(sess))
 	sess.Register(https_proxy.NewHttpsProxy(sess))
 	sess.Register(https_server.NewHttpsServer(sess))
 	sess.Register(mac_changer.NewMacChanger(sess))
+	sess.Register(mac_flood.NewMACFlooder(sess))
 	sess.Register(mysql_server.NewMySQLServer(sess))
 	sess.Register(mdns_server.NewMDNSServer(sess))
+	sess.Register(mqtt_proxy.NewMQTTProxy(sess))
 	sess.Register(net_sniff.NewSniffer(sess))
 	sess.Register(packet_proxy.NewPacketProxy(sess))
 	sess.Register(net_probe.NewProber(sess))
+	sess.Register(net_report.NewNetReport(sess))
 	sess.Register(syn_scan.NewSynScanner(sess))
+	sess.Register(snmp_scan.NewSNMPScanner(sess))
+	sess.Register(spoof_stats.NewSpoofStats(sess))
+	sess.Register(smb_recon.NewSMBRecon(sess))
+	sess.Register(ntlm_relay.NewNTLMRelay(sess))
+	sess.Register(iot_scan.NewIoTScanner(sess))
+	sess.Register(traceroute.NewTraceroute(sess))
+	sess.Register(socks5_proxy.NewSocks5Proxy(sess))
 	sess.Register(tcp_proxy.NewTcpProxy(sess))
 	sess.Register(ticker.NewTicker(sess))
 	sess.Register(wifi.NewWiFiModule(sess))
@@ -63,8 +102,15 @@ func LoadModules(sess *session.Session) {
 	sess.Register(hid.NewHIDRecon(sess))
 	sess.Register(c2.NewC2(sess))
 	sess.Register(ndp_spoof.NewNDPSpoofer(sess))
+	sess.Register(name_spoof.NewNameSpoofer(sess))
+	sess.Register(wpad_server.NewWPADServer(sess))
+	sess.Register(icmp_spoof.NewICMPSpoofer(sess))
+	sess.Register(l2_recon.NewL2Recon(sess))
+	sess.Register(metrics.NewMetrics(sess))
+	sess.Register(vlan_hop.NewVLANHopper(sess))
 
 	sess.Register(caplets.NewCapletsModule(sess))
+	sess.Register(creds.NewCredsModule(sess))
 	sess.Register(update.NewUpdateModule(sess))
 	sess.Register(ui.NewUIModule(sess))
 }
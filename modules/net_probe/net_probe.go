@@ -1,6 +1,9 @@
 package net_probe
 
 import (
+	"fmt"
+	"net"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,6 +13,9 @@ import (
 	"github.com/malfunkt/iprange"
 )
 
+// subnets bigger than this are not swept
+const MinSubnetBits = 16
+
 type Probes struct {
 	NBNS bool
 	MDNS bool
@@ -19,9 +25,14 @@ type Probes struct {
 
 type Prober struct {
 	session.SessionModule
-	throttle  int
-	probes    Probes
-	waitGroup *sync.WaitGroup
+	throttle     int
+	interval     int
+	targets      string
+	subnets      bool
+	probes       Probes
+	mdnsServices []string
+	upnpFetched  map[string]bool
+	waitGroup    *sync.WaitGroup
 }
 
 func NewProber(s *session.Session) *Prober {
@@ -40,6 +51,11 @@ func NewProber(s *session.Session) *Prober {
 		"true",
 		"Enable mDNS discovery probes."))
 
+	mod.AddParam(session.NewStringParameter("net.probe.mdns.services",
+		strings.Join(services, ","),
+		"",
+		"Comma separated list of mDNS services to actively query for."))
+
 	mod.AddParam(session.NewBoolParameter("net.probe.upnp",
 		"true",
 		"Enable UPNP discovery probes."))
@@ -48,6 +64,19 @@ func NewProber(s *session.Session) *Prober {
 		"true",
 		"Enable WSD discovery probes."))
 
+	mod.AddParam(session.NewStringParameter("net.probe.targets",
+		"",
+		"",
+		"Comma separated list of IP addresses, CIDR ranges, MAC addresses or aliases to probe, leave empty to probe the whole interface subnet."))
+
+	mod.AddParam(session.NewBoolParameter("net.probe.subnets",
+		"false",
+		"If true and no targets are set, also probe the additional subnets reachable through the gateway (see net.subnets)."))
+
+	mod.AddParam(session.NewIntParameter("net.probe.interval",
+		"5",
+		"Number of seconds to wait between two probing rounds."))
+
 	mod.AddParam(session.NewIntParameter("net.probe.throttle",
 		"10",
 		"If greater than 0, probe packets will be throttled by this value in milliseconds."))
@@ -83,10 +112,22 @@ func (mod *Prober) Configure() error {
 	var err error
 	if err, mod.throttle = mod.IntParam("net.probe.throttle"); err != nil {
 		return err
+	} else if err, mod.interval = mod.IntParam("net.probe.interval"); err != nil {
+		return err
+	} else if mod.interval <= 0 {
+		return fmt.Errorf("net.probe.interval must be greater than 0")
+	} else if err, mod.targets = mod.StringParam("net.probe.targets"); err != nil {
+		return err
+	} else if err, mod.subnets = mod.BoolParam("net.probe.subnets"); err != nil {
+		return err
+	} else if _, _, err = network.ParseTargets(mod.targets, mod.Session.Lan.Aliases()); err != nil {
+		return err
 	} else if err, mod.probes.NBNS = mod.BoolParam("net.probe.nbns"); err != nil {
 		return err
 	} else if err, mod.probes.MDNS = mod.BoolParam("net.probe.mdns"); err != nil {
 		return err
+	} else if err, mod.mdnsServices = mod.ListParam("net.probe.mdns.services"); err != nil {
+		return err
 	} else if err, mod.probes.UPNP = mod.BoolParam("net.probe.upnp"); err != nil {
 		return err
 	} else if err, mod.probes.WSD = mod.BoolParam("net.probe.wsd"); err != nil {
@@ -111,22 +152,17 @@ func (mod *Prober) Start() error {
 			return
 		}
 
-		cidr := mod.Session.Interface.CIDR()
-		list, err := iprange.Parse(cidr)
-		if err != nil {
-			mod.Fatal("%s", err)
-		}
-
 		if mod.probes.MDNS {
 			go mod.mdnsProber()
 		}
 
 		fromIP := mod.Session.Interface.IP
 		fromHW := mod.Session.Interface.HW
-		addresses := list.Expand()
 		throttle := time.Duration(mod.throttle) * time.Millisecond
+		interval := time.Duration(mod.interval) * time.Second
 
-		mod.Info("probing %d addresses on %s", len(addresses), cidr)
+		mod.upnpFetched = make(map[string]bool)
+		probing := -1
 
 		for mod.Running() {
 			if mod.probes.MDNS {
@@ -135,12 +171,25 @@ func (mod *Prober) Start() error {
 
 			if mod.probes.UPNP {
 				mod.sendProbeUPNP(fromIP, fromHW)
+				mod.fetchUPNPDescriptions()
 			}
 
 			if mod.probes.WSD {
 				mod.sendProbeWSD(fromIP, fromHW)
 			}
 
+			// targets given by mac address or alias might
+			// resolve to a different set of ips every round
+			addresses, err := mod.probeTargets()
+			if err != nil {
+				mod.Fatal("%s", err)
+			}
+
+			if len(addresses) != probing {
+				probing = len(addresses)
+				mod.Info("probing %d addresses", probing)
+			}
+
 			for _, ip := range addresses {
 				if !mod.Running() {
 					return
@@ -153,11 +202,62 @@ func (mod *Prober) Start() error {
 				time.Sleep(throttle)
 			}
 
-			time.Sleep(5 * time.Second)
+			time.Sleep(interval)
 		}
 	})
 }
 
+// probeTargets returns the list of addresses to probe, either
+// from net.probe.targets or the whole interface subnet.
+func (mod *Prober) probeTargets() ([]net.IP, error) {
+	if mod.targets == "" {
+		list, err := iprange.Parse(mod.Session.Interface.CIDR())
+		if err != nil {
+			return nil, err
+		}
+
+		addresses := list.Expand()
+		if mod.subnets {
+			addresses = append(addresses, mod.subnetsTargets()...)
+		}
+		return addresses, nil
+	}
+
+	ips, macs, err := network.ParseTargets(mod.targets, mod.Session.Lan.Aliases())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hw := range macs {
+		if e, found := mod.Session.Lan.Get(hw.String()); found && e.IP != nil {
+			ips = append(ips, e.IP)
+		} else {
+			mod.Debug("could not find an ip address for %s", hw)
+		}
+	}
+
+	return ips, nil
+}
+
+// subnetsTargets returns the addresses of the additional IPv4 subnets
+// registered in the session, skipping the ones too big to be swept.
+func (mod *Prober) subnetsTargets() []net.IP {
+	addresses := make([]net.IP, 0)
+	for _, subnet := range mod.Session.Subnets.List() {
+		if subnet.Net.IP.To4() == nil {
+			continue
+		} else if ones, _ := subnet.Net.Mask.Size(); ones < MinSubnetBits {
+			mod.Debug("skipping subnet %s, too big to be probed", subnet.CIDR)
+			continue
+		} else if list, err := iprange.Parse(subnet.CIDR); err != nil {
+			mod.Debug("could not parse subnet %s: %v", subnet.CIDR, err)
+		} else {
+			addresses = append(addresses, list.Expand()...)
+		}
+	}
+	return addresses
+}
+
 func (mod *Prober) Stop() error {
 	return mod.SetRunning(false, func() {
 		mod.waitGroup.Wait()
@@ -21,6 +21,17 @@ var services = []string{
 	"_googlezone._tcp.local",
 	"_googlerpc._tcp.local",
 	"_googlecast._tcp.local",
+	"_ipp._tcp.local",
+	"_ipps._tcp.local",
+	"_printer._tcp.local",
+	"_smb._tcp.local",
+	"_afpovertcp._tcp.local",
+	"_ssh._tcp.local",
+	"_sftp-ssh._tcp.local",
+	"_http._tcp.local",
+	"_workstation._tcp.local",
+	"_device-info._tcp.local",
+	"_spotify-connect._tcp.local",
 	"local",
 }
 
@@ -65,6 +76,7 @@ func (mod *Prober) mdnsListener(c chan *mdns.ServiceEntry) {
 				}
 
 				meta["mdns:port"] = fmt.Sprintf("%d", entry.Port)
+				meta["mdns:services"] = packets.MDNSServiceType(entry.Name)
 
 				mod.Debug("meta for %s: %v", addr, meta)
 
@@ -91,7 +103,7 @@ func (mod *Prober) mdnsProber() {
 	go mod.mdnsListener(ch)
 
 	for mod.Running() {
-		for _, svc := range services {
+		for _, svc := range mod.mdnsServices {
 			if mod.Running() {
 				mdns.Lookup(svc, ch)
 			}
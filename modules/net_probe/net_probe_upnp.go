@@ -1,12 +1,25 @@
 package net_probe
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"net/url"
+	"time"
 
+	"github.com/bettercap/bettercap/network"
 	"github.com/bettercap/bettercap/packets"
 )
 
+const (
+	upnpFetchTimeout = 5 * time.Second
+	// device descriptions are a few KB at most
+	upnpMaxDescription = 256 * 1024
+)
+
 func (mod *Prober) sendProbeUPNP(from net.IP, from_hw net.HardwareAddr) {
 	name := fmt.Sprintf("%s:%d", packets.UPNPDestIP, packets.UPNPPort)
 	if addr, err := net.ResolveUDPAddr("udp", name); err != nil {
@@ -23,3 +36,74 @@ func (mod *Prober) sendProbeUPNP(from net.IP, from_hw net.HardwareAddr) {
 	}
 
 }
+
+// check every endpoint that answered to our M-SEARCH probes and
+// fetch the device description from its location, only once
+func (mod *Prober) fetchUPNPDescriptions() {
+	mod.Session.Lan.EachHost(func(mac string, e *network.Endpoint) {
+		location, ok := e.Meta.Get("upnp:Location").(string)
+		if !ok || location == "" {
+			return
+		} else if _, found := mod.upnpFetched[location]; found {
+			return
+		}
+
+		mod.upnpFetched[location] = true
+		go mod.fetchUPNPDescription(e, location)
+	})
+}
+
+// upnpSameHost returns an error if location doesn't point to the device
+// itself, so that a device on the LAN can't make us send requests to other
+// hosts or to our own services.
+func upnpSameHost(location string, ip string) error {
+	u, err := url.Parse(location)
+	if err != nil {
+		return err
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %s", u.Scheme)
+	} else if host := net.ParseIP(u.Hostname()); host == nil || !host.Equal(net.ParseIP(ip)) {
+		return fmt.Errorf("%s is not the address of the device", u.Hostname())
+	}
+	return nil
+}
+
+func (mod *Prober) fetchUPNPDescription(e *network.Endpoint, location string) {
+	if err := upnpSameHost(location, e.IpAddress); err != nil {
+		mod.Debug("ignoring upnp location %s of %s: %v", location, e.IpAddress, err)
+		return
+	}
+
+	client := http.Client{
+		Timeout: upnpFetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return errors.New("too many redirects")
+			}
+			return upnpSameHost(req.URL.String(), e.IpAddress)
+		},
+	}
+
+	res, err := client.Get(location)
+	if err != nil {
+		mod.Debug("error fetching upnp description from %s: %v", location, err)
+		return
+	}
+	defer res.Body.Close()
+
+	raw, err := ioutil.ReadAll(io.LimitReader(res.Body, upnpMaxDescription))
+	if err != nil {
+		mod.Debug("error reading upnp description from %s: %v", location, err)
+		return
+	}
+
+	desc, err := packets.UPNPParseDescription(raw)
+	if err != nil {
+		mod.Debug("error parsing upnp description from %s: %v", location, err)
+		return
+	}
+
+	meta := desc.Meta(location)
+	mod.Debug("upnp meta for %s: %v", e.IpAddress, meta)
+	e.OnMeta(meta)
+}
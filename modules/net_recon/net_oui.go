@@ -0,0 +1,114 @@
+package net_recon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+
+	"github.com/evilsocket/islazy/fs"
+)
+
+const ouiDownloadTimeout = time.Duration(60) * time.Second
+
+// load the previously downloaded vendors database and the user overrides
+func (mod *Discovery) loadOUI() error {
+	err, database := mod.StringParam("net.oui.database")
+	if err != nil {
+		return err
+	} else if database, err = fs.Expand(database); err != nil {
+		return err
+	} else if database != "" && fs.Exists(database) {
+		entries, err := network.ManufLoad(database)
+		if err != nil {
+			return err
+		}
+		network.ManufUpdate(entries)
+		mod.Debug("loaded %d vendors from %s", len(entries), database)
+	}
+
+	err, overrides := mod.StringParam("net.oui.overrides")
+	if err != nil {
+		return err
+	} else if overrides, err = fs.Expand(overrides); err != nil {
+		return err
+	} else if overrides != "" {
+		entries, err := network.ManufLoad(overrides)
+		if err != nil {
+			return err
+		}
+		network.ManufOverride(entries)
+		mod.Debug("loaded %d vendor overrides from %s", len(entries), overrides)
+	} else {
+		network.ManufOverride(make(map[string]string))
+	}
+
+	mod.refreshVendors()
+	return nil
+}
+
+func (mod *Discovery) updateOUI() error {
+	err, url := mod.StringParam("net.oui.url")
+	if err != nil {
+		return err
+	}
+
+	err, database := mod.StringParam("net.oui.database")
+	if err != nil {
+		return err
+	} else if database, err = fs.Expand(database); err != nil {
+		return err
+	}
+
+	mod.Info("downloading vendors database from %s ...", url)
+
+	client := http.Client{Timeout: ouiDownloadTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response from %s: %s", url, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	entries := network.ManufParse(data)
+	if len(entries) == 0 {
+		return fmt.Errorf("no vendors found in %s", url)
+	}
+
+	if database != "" {
+		if err = ioutil.WriteFile(database, data, 0644); err != nil {
+			return err
+		}
+	}
+
+	network.ManufUpdate(entries)
+	mod.refreshVendors()
+
+	mod.Info("loaded %d vendors, database saved to %s", len(entries), database)
+	return nil
+}
+
+// vendors of the endpoints we already know might have changed
+func (mod *Discovery) refreshVendors() {
+	refresh := func(e *network.Endpoint) {
+		if e != nil && e.HwAddress != "" {
+			e.Vendor = network.ManufLookup(e.HwAddress)
+		}
+	}
+
+	refresh(mod.Session.Interface)
+	refresh(mod.Session.Gateway)
+	mod.Session.Lan.EachHost(func(mac string, e *network.Endpoint) {
+		refresh(e)
+	})
+}
@@ -2,15 +2,32 @@ package net_recon
 
 import (
 	"github.com/bettercap/bettercap/modules/utils"
+	"math/rand"
+	"net"
+	"regexp"
 	"time"
 
 	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
 	"github.com/bettercap/bettercap/session"
 )
 
+const IPv6ProbeInterval = time.Duration(10) * time.Second
+
 type Discovery struct {
 	session.SessionModule
-	selector *utils.ViewSelector
+	selector    *utils.ViewSelector
+	filterField string
+	filterExpr  *regexp.Regexp
+	offset      int
+	total       int
+	shown       int
+	ipv6        bool
+	mld         bool
+	nodeInfo    bool
+	solicit     bool
+	vlan        int
+	vlanName    string
 }
 
 func NewDiscovery(s *session.Session) *Discovery {
@@ -37,6 +54,26 @@ func NewDiscovery(s *session.Session) *Discovery {
 			return nil
 		}))
 
+	mod.AddParam(session.NewBoolParameter("net.recon.ipv6",
+		"true",
+		"If true, periodically send ICMPv6 echo requests to the all-nodes multicast address so that IPv6 neighbors show up in the neighbor table."))
+
+	mod.AddParam(session.NewBoolParameter("net.recon.ipv6.mld",
+		"true",
+		"If true, together with the echo requests also send MLD general queries, hosts answer with the multicast groups they joined."))
+
+	mod.AddParam(session.NewBoolParameter("net.recon.ipv6.nodeinfo",
+		"true",
+		"If true, together with the echo requests also send node information queries, hosts supporting them answer with their name."))
+
+	mod.AddParam(session.NewBoolParameter("net.recon.ipv6.ns",
+		"true",
+		"If true, together with the echo requests also send neighbor solicitations for the EUI-64 link-local addresses of the known hosts without an IPv6 address, to find the ones ignoring multicast echo requests."))
+
+	mod.AddParam(session.NewIntParameter("net.recon.vlan",
+		"0",
+		"If greater than 0, also discover hosts on the 802.1Q sub interface for this VLAN identifier, creating it if needed."))
+
 	mod.AddParam(session.NewBoolParameter("net.show.meta",
 		"false",
 		"If true, the net.show command will show all metadata collected about each endpoint."))
@@ -53,13 +90,67 @@ func NewDiscovery(s *session.Session) *Discovery {
 			return mod.Show(args[0])
 		}))
 
+	mod.AddHandler(session.NewModuleHandler("net.show.vlans", "",
+		"Show the 802.1Q VLAN identifiers seen in the traffic and the endpoints using them.",
+		func(args []string) error {
+			return mod.showVLANs()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("net.subnets", "",
+		"Show the additional subnets reachable through the gateway, either learned from DHCP and router advertisements or added manually.",
+		func(args []string) error {
+			return mod.showSubnets()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("net.subnets.add CIDR", `net\.subnets\.add (.+)`,
+		"Register an additional subnet reachable through the gateway, so that net.probe and syn.scan can target it.",
+		func(args []string) error {
+			return mod.addSubnet(args[0])
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("net.subnets.del CIDR", `net\.subnets\.del (.+)`,
+		"Remove a previously registered subnet.",
+		func(args []string) error {
+			return mod.delSubnet(args[0])
+		}))
+
+	mod.AddParam(session.NewStringParameter("net.oui.url",
+		"https://standards-oui.ieee.org/oui/oui.csv",
+		"",
+		"URL of the vendors database downloaded by net.oui.update, either in IEEE CSV or wireshark manuf format."))
+
+	mod.AddParam(session.NewStringParameter("net.oui.database",
+		"~/bettercap.oui",
+		"",
+		"File where the vendors database is saved by net.oui.update and loaded from when the module starts."))
+
+	mod.AddParam(session.NewStringParameter("net.oui.overrides",
+		"",
+		"",
+		"If set, a file of custom vendors in the 'PREFIX[/BITS] VENDOR' format, taking precedence over any other database."))
+
+	mod.AddHandler(session.NewModuleHandler("net.oui.update", "",
+		"Download a fresh vendors database and use it instead of the compiled in one.",
+		func(args []string) error {
+			return mod.updateOUI()
+		}))
+
 	mod.AddHandler(session.NewModuleHandler("net.show.meta ADDRESS1, ADDRESS2", `net\.show\.meta (.+)`,
 		"Show meta information about a specific comma separated list of addresses (by IP or MAC).",
 		func(args []string) error {
 			return mod.showMeta(args[0])
 		}))
 
-	mod.selector = utils.ViewSelectorFor(&mod.SessionModule, "net.show", []string{"ip", "mac", "seen", "sent", "rcvd"},
+	mod.AddParam(session.NewStringParameter("net.show.columns",
+		"ip,mac,name,vendor,os,sent,rcvd,seen,tags",
+		`^((ip|mac|name|vendor|os|sent|rcvd|seen|tags),?\s*)+$`,
+		"Comma separated list of columns to show (ip, mac, name, vendor, os, sent, rcvd, seen, tags), the os and tags columns are only shown if at least one host has been fingerprinted or tagged."))
+
+	mod.AddParam(session.NewIntParameter("net.show.offset",
+		"0",
+		"Number of hosts to skip, use it together with net.show.limit to paginate large lists."))
+
+	mod.selector = utils.ViewSelectorFor(&mod.SessionModule, "net.show", []string{"ip", "mac", "name", "vendor", "os", "seen", "sent", "rcvd"},
 		"ip asc")
 
 	return mod
@@ -97,8 +188,146 @@ func (mod *Discovery) runDiff(cache network.ArpTable) {
 	}
 }
 
-func (mod *Discovery) Configure() error {
-	return nil
+func (mod *Discovery) Configure() (err error) {
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, mod.ipv6 = mod.BoolParam("net.recon.ipv6"); err != nil {
+		return
+	} else if mod.ipv6 && mod.Session.Interface.IPv6 == nil {
+		mod.Debug("interface %s has no IPv6 address, disabling neighbor discovery", mod.Session.Interface.Name())
+		mod.ipv6 = false
+	}
+
+	if err, mod.mld = mod.BoolParam("net.recon.ipv6.mld"); err != nil {
+		return
+	} else if err, mod.nodeInfo = mod.BoolParam("net.recon.ipv6.nodeinfo"); err != nil {
+		return
+	} else if err, mod.solicit = mod.BoolParam("net.recon.ipv6.ns"); err != nil {
+		return
+	}
+
+	if err = mod.loadOUI(); err != nil {
+		return
+	}
+
+	if err, mod.vlan = mod.IntParam("net.recon.vlan"); err != nil {
+		return
+	} else if mod.vlan > 0 {
+		if mod.vlanName, err = network.CreateVLANInterface(mod.Session.Interface.Name(), mod.vlan); err != nil {
+			return
+		}
+		mod.addVLANNetworks()
+	} else {
+		mod.vlanName = ""
+	}
+	return
+}
+
+// hosts on the vlan sub interface subnets are part of the LAN as well
+func (mod *Discovery) addVLANNetworks() {
+	iface, err := net.InterfaceByName(mod.vlanName)
+	if err != nil {
+		mod.Warning("could not find interface %s: %v", mod.vlanName, err)
+		return
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		mod.Warning("could not get addresses of %s: %v", mod.vlanName, err)
+		return
+	}
+
+	found := false
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			_, subnet, _ := net.ParseCIDR(ipNet.String())
+			mod.Session.Lan.AddNetwork(subnet)
+			mod.Info("discovering hosts on %s (%s)", mod.vlanName, subnet)
+			found = true
+		}
+	}
+
+	if !found {
+		mod.Warning("%s has no IPv4 address, only passive discovery will be performed on VLAN %d", mod.vlanName, mod.vlan)
+	}
+}
+
+func (mod *Discovery) arpUpdate(iface string) (network.ArpTable, error) {
+	if mod.vlanName == "" {
+		return network.ArpUpdate(iface)
+	}
+
+	// read the vlan entries first so that the global arp
+	// cache is left with the ones of the main interface
+	table := make(network.ArpTable)
+	if vlanTable, err := network.ArpUpdate(mod.vlanName); err != nil {
+		return nil, err
+	} else {
+		for ip, mac := range vlanTable {
+			table[ip] = mac
+		}
+	}
+
+	mainTable, err := network.ArpUpdate(iface)
+	if err != nil {
+		return nil, err
+	}
+	for ip, mac := range mainTable {
+		table[ip] = mac
+	}
+
+	return table, nil
+}
+
+func (mod *Discovery) sendIPv6Probe() {
+	iface := mod.Session.Interface
+	// MLD queries are only accepted from link-local addresses
+	src := iface.IPv6
+	if ip := net.ParseIP(iface.Ip6LinkLocal); ip != nil {
+		src = ip
+	}
+
+	probes := []struct {
+		name  string
+		build func() (error, []byte)
+		use   bool
+	}{
+		{"echo", func() (error, []byte) { return packets.ICMP6AllNodesEcho(iface.HW, iface.IPv6) }, true},
+		{"mld", func() (error, []byte) { return packets.ICMP6MLDQuery(iface.HW, src) }, mod.mld},
+		{"node information", func() (error, []byte) { return packets.ICMP6NodeNameQuery(iface.HW, src, rand.Uint64()) }, mod.nodeInfo},
+	}
+
+	for _, probe := range probes {
+		if !probe.use {
+			continue
+		} else if err, raw := probe.build(); err != nil {
+			mod.Error("error creating ipv6 %s probe: %v", probe.name, err)
+		} else if err = mod.Session.Queue.Send(raw); err != nil {
+			mod.Error("error sending ipv6 %s probe: %v", probe.name, err)
+		}
+	}
+
+	if mod.solicit {
+		mod.sendNeighborSolicitations(src)
+	}
+}
+
+// sendNeighborSolicitations asks the known hosts without an IPv6 address for
+// their EUI-64 link-local one, those using privacy extensions won't answer
+// but it's the only address we can guess.
+func (mod *Discovery) sendNeighborSolicitations(src net.IP) {
+	iface := mod.Session.Interface
+	for _, e := range mod.Session.Lan.List() {
+		if e.Ip6Address != "" || len(e.HW) != 6 {
+			continue
+		} else if err, raw := packets.ICMP6NeighborSolicitation(iface.HW, src, packets.EUI64LinkLocal(e.HW)); err != nil {
+			mod.Error("error creating neighbor solicitation: %v", err)
+			return
+		} else if err = mod.Session.Queue.Send(raw); err != nil {
+			mod.Error("error sending neighbor solicitation: %v", err)
+			return
+		}
+	}
 }
 
 func (mod *Discovery) Start() error {
@@ -109,8 +338,16 @@ func (mod *Discovery) Start() error {
 	return mod.SetRunning(true, func() {
 		every := time.Duration(1) * time.Second
 		iface := mod.Session.Interface.Name()
+		lastIPv6Probe := time.Time{}
 		for mod.Running() {
-			if table, err := network.ArpUpdate(iface); err != nil {
+			if mod.ipv6 && time.Since(lastIPv6Probe) >= IPv6ProbeInterval {
+				mod.sendIPv6Probe()
+				lastIPv6Probe = time.Now()
+			}
+
+			mod.importSubnets()
+
+			if table, err := mod.arpUpdate(iface); err != nil {
 				mod.Error("%s", err)
 			} else {
 				mod.runDiff(table)
@@ -121,5 +358,15 @@ func (mod *Discovery) Start() error {
 }
 
 func (mod *Discovery) Stop() error {
-	return mod.SetRunning(false, nil)
+	return mod.SetRunning(false, func() {
+		// the sub interface is only removed if it didn't exist before
+		// and nobody else is using it
+		if mod.vlanName != "" {
+			mod.Debug("releasing interface %s", mod.vlanName)
+			if err := network.DeleteVLANInterface(mod.vlanName); err != nil {
+				mod.Error("error deleting interface %s: %v", mod.vlanName, err)
+			}
+			mod.vlanName = ""
+		}
+	})
 }
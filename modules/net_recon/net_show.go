@@ -3,10 +3,13 @@ package net_recon
 import (
 	"fmt"
 	"github.com/bettercap/bettercap/modules/syn_scan"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/bettercap/bettercap/core"
 	"github.com/bettercap/bettercap/network"
 	"github.com/bettercap/bettercap/packets"
 
@@ -33,7 +36,7 @@ func (p ProtoPairList) Len() int           { return len(p) }
 func (p ProtoPairList) Less(i, j int) bool { return p[i].Hits < p[j].Hits }
 func (p ProtoPairList) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
-func (mod *Discovery) getRow(e *network.Endpoint, withMeta bool) [][]string {
+func (mod *Discovery) getRow(e *network.Endpoint, columns []string, withMeta bool) [][]string {
 	sinceStarted := time.Since(mod.Session.StartedAt)
 	sinceFirstSeen := time.Since(e.FirstSeen)
 
@@ -81,20 +84,32 @@ func (mod *Discovery) getRow(e *network.Endpoint, withMeta bool) [][]string {
 		seen = tui.Dim(seen)
 	}
 
-	row := []string{
-		addr,
-		mac,
-		name,
-		tui.Dim(e.Vendor),
-		humanize.Bytes(traffic.Sent),
-		humanize.Bytes(traffic.Received),
-		seen,
+	os := e.OS
+	if e.Device != "" {
+		os = fmt.Sprintf("%s %s", os, tui.Dim("("+e.Device+")"))
+	}
+
+	cells := map[string]string{
+		"ip":     addr,
+		"mac":    mac,
+		"name":   name,
+		"vendor": tui.Dim(e.Vendor),
+		"os":     os,
+		"sent":   humanize.Bytes(traffic.Sent),
+		"rcvd":   humanize.Bytes(traffic.Received),
+		"seen":   seen,
+		"tags":   tui.Dim(mod.Session.Tags.String(e.HwAddress)),
+	}
+
+	row := make([]string, len(columns))
+	for i, col := range columns {
+		row[i] = cells[col]
 	}
 
 	if !withMeta {
-		return [][]string{row}
+		return mod.withIPv6Rows(e, columns, [][]string{row})
 	} else if e.Meta.Empty() {
-		return [][]string{append(row, tui.Dim("-"))}
+		return mod.withIPv6Rows(e, columns, [][]string{append(row, tui.Dim("-"))})
 	}
 
 	metas := []string{}
@@ -115,28 +130,118 @@ func (mod *Discovery) getRow(e *network.Endpoint, withMeta bool) [][]string {
 		if i == 0 {
 			rows = append(rows, append(row, m))
 		} else {
-			rows = append(rows, []string{"", "", "", "", "", "", "", m})
+			rows = append(rows, append(make([]string, len(row)), m))
+		}
+	}
+
+	return mod.withIPv6Rows(e, columns, rows)
+}
+
+// list the IPv6 addresses of the endpoint right below its main address
+func (mod *Discovery) withIPv6Rows(e *network.Endpoint, columns []string, rows [][]string) [][]string {
+	col := -1
+	for i, name := range columns {
+		if name == "ip" {
+			col = i
+			break
+		}
+	}
+
+	if col == -1 {
+		return rows
+	}
+
+	addrs := []string{}
+	if e.Ip6Address != "" && e.Ip6Address != e.IpAddress {
+		addrs = append(addrs, e.Ip6Address)
+	}
+	if e.Ip6LinkLocal != "" && e.Ip6LinkLocal != e.Ip6Address && e.Ip6LinkLocal != e.IpAddress {
+		addrs = append(addrs, e.Ip6LinkLocal)
+	}
+
+	for i, addr := range addrs {
+		if i+1 < len(rows) {
+			rows[i+1][col] = tui.Dim(addr)
+		} else {
+			pad := make([]string, len(rows[0]))
+			pad[col] = tui.Dim(addr)
+			rows = append(rows, pad)
 		}
 	}
 
 	return rows
 }
 
+// a filter can be restricted to a single field, like "vendor:apple"
+var fieldFilterParser = regexp.MustCompile(`^(ip|mac|name|vendor|os|device|meta|tags):(.+)$`)
+
+func (mod *Discovery) updateFieldFilter() (err error) {
+	mod.filterField = ""
+	mod.filterExpr = mod.selector.Expression
+	if m := fieldFilterParser.FindStringSubmatch(mod.selector.Filter); m != nil {
+		mod.filterField = m[1]
+		mod.filterExpr, err = regexp.Compile(m[2])
+	}
+	return
+}
+
+func (mod *Discovery) fieldValues(target *network.Endpoint, field string) []string {
+	switch field {
+	case "ip":
+		return []string{target.IpAddress, target.Ip6Address, target.Ip6LinkLocal}
+	case "mac":
+		return []string{target.HwAddress}
+	case "name":
+		return []string{target.Hostname, target.Alias}
+	case "vendor":
+		return []string{target.Vendor}
+	case "os":
+		return []string{target.OS}
+	case "device":
+		return []string{target.Device}
+	case "meta":
+		values := []string{}
+		target.Meta.Each(func(name string, value interface{}) {
+			values = append(values, fmt.Sprintf("%s=%v", name, value))
+		})
+		return values
+	case "tags":
+		values := []string{}
+		for name, value := range mod.Session.Tags.Get(target.HwAddress) {
+			values = append(values, fmt.Sprintf("%s=%s", name, value))
+		}
+		return values
+	}
+	return nil
+}
+
 func (mod *Discovery) doFilter(target *network.Endpoint) bool {
-	if mod.selector.Expression == nil {
+	if mod.filterExpr == nil {
 		return true
 	}
-	return mod.selector.Expression.MatchString(target.IpAddress) ||
-		mod.selector.Expression.MatchString(target.Ip6Address) ||
-		mod.selector.Expression.MatchString(target.HwAddress) ||
-		mod.selector.Expression.MatchString(target.Hostname) ||
-		mod.selector.Expression.MatchString(target.Alias) ||
-		mod.selector.Expression.MatchString(target.Vendor)
+
+	fields := []string{mod.filterField}
+	if mod.filterField == "" {
+		fields = []string{"ip", "mac", "name", "vendor", "os", "device", "tags"}
+	}
+
+	for _, field := range fields {
+		for _, value := range mod.fieldValues(target, field) {
+			if value != "" && mod.filterExpr.MatchString(value) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (mod *Discovery) doSelection(arg string) (err error, targets []*network.Endpoint) {
 	if err = mod.selector.Update(); err != nil {
 		return
+	} else if err = mod.updateFieldFilter(); err != nil {
+		return
+	} else if err, mod.offset = mod.IntParam("net.show.offset"); err != nil {
+		return
 	}
 
 	if arg != "" {
@@ -160,6 +265,12 @@ func (mod *Discovery) doSelection(arg string) (err error, targets []*network.End
 		sort.Sort(ByIpSorter(targets))
 	case "mac":
 		sort.Sort(ByMacSorter(targets))
+	case "name":
+		sort.Sort(ByNameSorter(targets))
+	case "vendor":
+		sort.Sort(ByVendorSorter(targets))
+	case "os":
+		sort.Sort(ByOSSorter(targets))
 	case "seen":
 		sort.Sort(BySeenSorter(targets))
 	case "sent":
@@ -179,6 +290,15 @@ func (mod *Discovery) doSelection(arg string) (err error, targets []*network.End
 		}
 	}
 
+	mod.total = len(targets)
+
+	if mod.offset > 0 {
+		if mod.offset > len(targets) {
+			mod.offset = len(targets)
+		}
+		targets = targets[mod.offset:]
+	}
+
 	if mod.selector.Limit > 0 {
 		limit := mod.selector.Limit
 		max := len(targets)
@@ -188,29 +308,68 @@ func (mod *Discovery) doSelection(arg string) (err error, targets []*network.End
 		targets = targets[0:limit]
 	}
 
+	mod.shown = len(targets)
+
 	return
 }
 
-func (mod *Discovery) colNames(hasMeta bool) []string {
-	colNames := []string{"IP", "MAC", "Name", "Vendor", "Sent", "Recvd", "Seen"}
+var columnNames = map[string]string{
+	"ip":     "IP",
+	"mac":    "MAC",
+	"name":   "Name",
+	"vendor": "Vendor",
+	"os":     "OS",
+	"sent":   "Sent",
+	"rcvd":   "Recvd",
+	"seen":   "Seen",
+	"tags":   "Tags",
+}
+
+func (mod *Discovery) colNames(columns []string, hasMeta bool) []string {
+	colNames := make([]string, 0, len(columns)+1)
+	for _, col := range columns {
+		name := columnNames[col]
+		if col == mod.selector.SortField {
+			name += " " + mod.selector.SortSymbol
+		}
+		colNames = append(colNames, name)
+	}
+
 	if hasMeta {
 		colNames = append(colNames, "Meta")
 	}
 
-	switch mod.selector.SortField {
-	case "mac":
-		colNames[1] += " " + mod.selector.SortSymbol
-	case "sent":
-		colNames[4] += " " + mod.selector.SortSymbol
-	case "rcvd":
-		colNames[5] += " " + mod.selector.SortSymbol
-	case "seen":
-		colNames[6] += " " + mod.selector.SortSymbol
-	case "ip":
-		colNames[0] += " " + mod.selector.SortSymbol
+	return colNames
+}
+
+// the os and tags columns are only shown if at least one target has been
+// fingerprinted or tagged
+func (mod *Discovery) getColumns(targets []*network.Endpoint) (err error, columns []string) {
+	var list []string
+	if err, list = mod.ListParam("net.show.columns"); err != nil {
+		return
 	}
 
-	return colNames
+	hasOS := false
+	hasTags := false
+	for _, t := range targets {
+		if t.OS != "" || t.Device != "" {
+			hasOS = true
+		}
+		if mod.Session.Tags.Has(t.HwAddress) {
+			hasTags = true
+		}
+	}
+
+	columns = make([]string, 0, len(list))
+	for _, col := range list {
+		if _, found := columnNames[col]; !found {
+			return fmt.Errorf("unknown column '%s'", col), nil
+		} else if (col != "os" || hasOS) && (col != "tags" || hasTags) {
+			columns = append(columns, col)
+		}
+	}
+	return
 }
 
 func (mod *Discovery) showStatusBar() {
@@ -224,6 +383,10 @@ func (mod *Discovery) showStatusBar() {
 		parts = append(parts, fmt.Sprintf("%d errs", nErrors))
 	}
 
+	if shown := mod.shown; shown < mod.total {
+		parts = append(parts, fmt.Sprintf("%d-%d of %d hosts", mod.offset+1, mod.offset+shown, mod.total))
+	}
+
 	mod.Printf("\n%s\n\n", strings.Join(parts, " / "))
 }
 
@@ -253,12 +416,17 @@ func (mod *Discovery) Show(arg string) (err error) {
 		}
 	}
 
-	colNames := mod.colNames(hasMeta)
+	var columns []string
+	if err, columns = mod.getColumns(targets); err != nil {
+		return err
+	}
+
+	colNames := mod.colNames(columns, hasMeta)
 	padCols := make([]string, len(colNames))
 
 	rows := make([][]string, 0)
 	for i, t := range targets {
-		rows = append(rows, mod.getRow(t, hasMeta)...)
+		rows = append(rows, mod.getRow(t, columns, hasMeta)...)
 		if i == pad {
 			rows = append(rows, padCols)
 		}
@@ -310,12 +478,20 @@ func (mod *Discovery) showMeta(arg string) (err error) {
 						if info.Service != "" {
 							val += fmt.Sprintf("(%s)", info.Service)
 						}
+						if info.Product != "" {
+							val += fmt.Sprintf(" %s %s", info.Product, info.Version)
+						}
 						if info.Banner != "" {
 							val += fmt.Sprintf(" [%s]", info.Banner)
 						}
+						if info.Cert != "" {
+							val += fmt.Sprintf(" {%s}", info.Cert)
+						}
 						val += " "
 					}
 					val = str.Trim(val)
+				} else if when, ok := meta.(time.Time); ok {
+					val = humanize.Time(when)
 				} else {
 					val = fmt.Sprintf("%#v", meta)
 				}
@@ -336,3 +512,57 @@ func (mod *Discovery) showMeta(arg string) (err error) {
 
 	return nil
 }
+
+func (mod *Discovery) showVLANs() error {
+	hits := make(map[string]int)
+	mod.Session.Queue.VLANs.Range(func(k, v interface{}) bool {
+		hits[fmt.Sprintf("%d", k.(uint16))] = v.(int)
+		return true
+	})
+
+	// endpoints are tagged with one or more (comma separated) vlan paths
+	hosts := make(map[string][]string)
+	for _, e := range mod.Session.Lan.List() {
+		if ids, ok := e.Meta.Get("vlan:ids").(string); ok && ids != "" {
+			for _, path := range strings.Split(ids, ",") {
+				for _, id := range strings.Split(path, "/") {
+					hosts[id] = append(hosts[id], e.IpAddress)
+				}
+			}
+		}
+	}
+
+	if len(hits) == 0 && len(hosts) == 0 {
+		mod.Info("no 802.1Q tagged traffic seen yet")
+		return nil
+	}
+
+	ids := make([]string, 0)
+	for id := range hits {
+		ids = append(ids, id)
+	}
+	for id := range hosts {
+		if _, found := hits[id]; !found {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		return a < b
+	})
+
+	rows := make([][]string, 0, len(ids))
+	for _, id := range ids {
+		endpoints := core.UniqueStrings(hosts[id], true)
+		rows = append(rows, []string{
+			tui.Bold(id),
+			humanize.Comma(int64(hits[id])),
+			strings.Join(endpoints, ", "),
+		})
+	}
+
+	tui.Table(mod.Session.Events.Stdout, []string{"VLAN", "Packets", "Endpoints"}, rows)
+	mod.Session.Refresh()
+	return nil
+}
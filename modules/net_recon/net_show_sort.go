@@ -1,6 +1,8 @@
 package net_recon
 
 import (
+	"strings"
+
 	"github.com/bettercap/bettercap/network"
 	"github.com/bettercap/bettercap/packets"
 	"github.com/bettercap/bettercap/session"
@@ -33,6 +35,40 @@ func (a ByMacSorter) Less(i, j int) bool {
 	return a[i].HwAddress < a[j].HwAddress
 }
 
+func nameOf(e *network.Endpoint) string {
+	if e.Alias != "" {
+		return strings.ToLower(e.Alias)
+	}
+	return strings.ToLower(e.Hostname)
+}
+
+type ByNameSorter []*network.Endpoint
+
+func (a ByNameSorter) Len() int      { return len(a) }
+func (a ByNameSorter) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a ByNameSorter) Less(i, j int) bool {
+	return nameOf(a[i]) < nameOf(a[j])
+}
+
+type ByVendorSorter []*network.Endpoint
+
+func (a ByVendorSorter) Len() int      { return len(a) }
+func (a ByVendorSorter) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a ByVendorSorter) Less(i, j int) bool {
+	return strings.ToLower(a[i].Vendor) < strings.ToLower(a[j].Vendor)
+}
+
+type ByOSSorter []*network.Endpoint
+
+func (a ByOSSorter) Len() int      { return len(a) }
+func (a ByOSSorter) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a ByOSSorter) Less(i, j int) bool {
+	if a[i].OS == a[j].OS {
+		return a[i].Device < a[j].Device
+	}
+	return a[i].OS < a[j].OS
+}
+
 type BySeenSorter []*network.Endpoint
 
 func (a BySeenSorter) Len() int           { return len(a) }
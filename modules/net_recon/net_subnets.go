@@ -0,0 +1,78 @@
+package net_recon
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+// import the subnets advertised by routers that are not our own
+func (mod *Discovery) importSubnets() {
+	mod.Session.Queue.Subnets.Range(func(k, v interface{}) bool {
+		if _, subnet, err := net.ParseCIDR(k.(string)); err == nil && !mod.isOwnSubnet(subnet) {
+			mod.Session.Subnets.Add(subnet, v.(string))
+		}
+		return true
+	})
+}
+
+func (mod *Discovery) isOwnSubnet(subnet *net.IPNet) bool {
+	iface := mod.Session.Interface
+	return (iface.IP != nil && subnet.Contains(iface.IP)) ||
+		(iface.IPv6 != nil && subnet.Contains(iface.IPv6)) ||
+		(iface.Net != nil && iface.Net.String() == subnet.String())
+}
+
+func (mod *Discovery) addSubnet(cidr string) error {
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return fmt.Errorf("error while parsing subnet '%s': %v", cidr, err)
+	} else if mod.isOwnSubnet(subnet) {
+		return fmt.Errorf("%s is the subnet of %s", subnet, mod.Session.Interface.Name())
+	} else if !mod.Session.Subnets.Add(subnet, "manual") {
+		return fmt.Errorf("subnet %s already registered", subnet)
+	}
+	return nil
+}
+
+func (mod *Discovery) delSubnet(cidr string) error {
+	if !mod.Session.Subnets.Remove(cidr) {
+		return fmt.Errorf("subnet %s is not registered", cidr)
+	}
+	return nil
+}
+
+func (mod *Discovery) showSubnets() error {
+	subnets := mod.Session.Subnets.List()
+	if len(subnets) == 0 {
+		mod.Info("no additional subnets registered")
+		return nil
+	}
+
+	gateway := ""
+	if mod.Session.Gateway != nil && mod.Session.Gateway != mod.Session.Interface {
+		gateway = mod.Session.Gateway.IpAddress
+	}
+
+	rows := make([][]string, 0, len(subnets))
+	for _, subnet := range subnets {
+		via := tui.Dim("-")
+		if subnet.Net.IP.To4() != nil && gateway != "" {
+			via = gateway
+		}
+
+		rows = append(rows, []string{
+			tui.Bold(subnet.CIDR),
+			via,
+			subnet.Source,
+			humanize.Time(subnet.FirstSeen),
+		})
+	}
+
+	tui.Table(mod.Session.Events.Stdout, []string{"Subnet", "Via", "Source", "First Seen"}, rows)
+	mod.Session.Refresh()
+	return nil
+}
@@ -0,0 +1,269 @@
+package net_report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bettercap/bettercap/modules/syn_scan"
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/fs"
+)
+
+type HostRecord struct {
+	IP        string    `json:"ipv4"`
+	IPv6      string    `json:"ipv6"`
+	MAC       string    `json:"mac"`
+	Vendor    string    `json:"vendor"`
+	Hostname  string    `json:"hostname"`
+	Alias     string    `json:"alias"`
+	OS        string    `json:"os"`
+	Device    string    `json:"device"`
+	Ports     []string  `json:"ports"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+type Report struct {
+	Generated time.Time    `json:"generated"`
+	Interface string       `json:"interface"`
+	Hosts     []HostRecord `json:"hosts"`
+}
+
+type NetReport struct {
+	session.SessionModule
+	output  string
+	format  string
+	period  time.Duration
+	quit    chan bool
+	stopped chan bool
+}
+
+func NewNetReport(s *session.Session) *NetReport {
+	mod := &NetReport{
+		SessionModule: session.NewSessionModule("net.report", s),
+		quit:          make(chan bool),
+		stopped:       make(chan bool),
+	}
+
+	mod.SetInterfaceIndependent(true)
+
+	mod.AddParam(session.NewStringParameter("net.report.output",
+		"~/bettercap-hosts.json",
+		"",
+		"File where the hosts inventory is written."))
+
+	mod.AddParam(session.NewStringParameter("net.report.format",
+		"json",
+		"^(json|csv)$",
+		"Format of the hosts inventory, json or csv."))
+
+	mod.AddParam(session.NewIntParameter("net.report.period",
+		"60",
+		"Period in seconds between two writes of the hosts inventory."))
+
+	mod.AddHandler(session.NewModuleHandler("net.report on", "",
+		"Start writing the hosts inventory periodically.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("net.report off", "",
+		"Stop writing the hosts inventory.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("net.report.save", "",
+		"Write the hosts inventory now.",
+		func(args []string) error {
+			if err := mod.configure(); err != nil {
+				return err
+			}
+			return mod.save()
+		}))
+
+	return mod
+}
+
+func (mod *NetReport) Name() string {
+	return "net.report"
+}
+
+func (mod *NetReport) Description() string {
+	return "Periodically export the hosts inventory to a JSON or CSV file."
+}
+
+func (mod *NetReport) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *NetReport) configure() (err error) {
+	var period int
+
+	if err, mod.output = mod.StringParam("net.report.output"); err != nil {
+		return err
+	} else if mod.output, err = fs.Expand(mod.output); err != nil {
+		return err
+	} else if err, mod.format = mod.StringParam("net.report.format"); err != nil {
+		return err
+	} else if err, period = mod.IntParam("net.report.period"); err != nil {
+		return err
+	} else if period <= 0 {
+		return fmt.Errorf("net.report.period must be greater than 0")
+	}
+
+	mod.period = time.Duration(period) * time.Second
+	return nil
+}
+
+func (mod *NetReport) Configure() error {
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	}
+	return mod.configure()
+}
+
+func (mod *NetReport) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.Info("writing hosts inventory to %s every %s", mod.output, mod.period)
+
+		tick := time.NewTicker(mod.period)
+		defer tick.Stop()
+
+		for {
+			if err := mod.save(); err != nil {
+				mod.Error("%v", err)
+			}
+
+			select {
+			case <-tick.C:
+			case <-mod.quit:
+				mod.stopped <- true
+				return
+			}
+		}
+	})
+}
+
+func (mod *NetReport) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.quit <- true
+		<-mod.stopped
+	})
+}
+
+func openPorts(e *network.Endpoint) []string {
+	ports := make([]string, 0)
+	for _, proto := range []string{"tcp", "udp"} {
+		if open, ok := e.Meta.Get(syn_scan.PortsMetaName(proto)).(map[int]*syn_scan.OpenPort); ok {
+			numbers := make([]int, 0, len(open))
+			for port := range open {
+				numbers = append(numbers, port)
+			}
+			sort.Ints(numbers)
+
+			for _, port := range numbers {
+				ports = append(ports, fmt.Sprintf("%s/%d", proto, port))
+			}
+		}
+	}
+	return ports
+}
+
+func (mod *NetReport) report() Report {
+	report := Report{
+		Generated: time.Now(),
+		Interface: mod.Session.Interface.Name(),
+		Hosts:     make([]HostRecord, 0),
+	}
+
+	endpoints := []*network.Endpoint{mod.Session.Interface, mod.Session.Gateway}
+	endpoints = append(endpoints, mod.Session.Lan.List()...)
+	seen := make(map[*network.Endpoint]bool)
+	for _, e := range endpoints {
+		if e == nil || seen[e] {
+			continue
+		}
+		seen[e] = true
+
+		report.Hosts = append(report.Hosts, HostRecord{
+			IP:        e.IpAddress,
+			IPv6:      e.Ip6Address,
+			MAC:       e.HwAddress,
+			Vendor:    e.Vendor,
+			Hostname:  e.Hostname,
+			Alias:     e.Alias,
+			OS:        e.OS,
+			Device:    e.Device,
+			Ports:     openPorts(e),
+			FirstSeen: e.FirstSeen,
+			LastSeen:  e.LastSeen,
+		})
+	}
+
+	return report
+}
+
+func toCSV(report Report) ([]byte, error) {
+	buf := bytes.Buffer{}
+	w := csv.NewWriter(&buf)
+
+	w.Write([]string{"ipv4", "ipv6", "mac", "vendor", "hostname", "alias", "os", "device", "ports", "first_seen", "last_seen"})
+	for _, h := range report.Hosts {
+		w.Write([]string{
+			h.IP,
+			h.IPv6,
+			h.MAC,
+			h.Vendor,
+			h.Hostname,
+			h.Alias,
+			h.OS,
+			h.Device,
+			strings.Join(h.Ports, " "),
+			h.FirstSeen.Format(time.RFC3339),
+			h.LastSeen.Format(time.RFC3339),
+		})
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func (mod *NetReport) save() (err error) {
+	report := mod.report()
+
+	var data []byte
+	if mod.format == "csv" {
+		data, err = toCSV(report)
+	} else {
+		data, err = json.MarshalIndent(report, "", "  ")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// write and rename so that readers never see a partial report
+	tmpFile := mod.output + ".tmp"
+	if err = ioutil.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	} else if err = os.Rename(tmpFile, mod.output); err != nil {
+		return err
+	}
+
+	mod.Debug("saved %d hosts to %s", len(report.Hosts), mod.output)
+	return nil
+}
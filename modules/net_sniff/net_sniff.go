@@ -2,6 +2,7 @@ package net_sniff
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/bettercap/bettercap/session"
@@ -10,17 +11,24 @@ import (
 	"github.com/google/gopacket/layers"
 )
 
+const (
+	BackendPcap     = "pcap"
+	BackendAFPacket = "afpacket"
+)
+
 type Sniffer struct {
 	session.SessionModule
 	Stats         *SnifferStats
 	Ctx           *SnifferContext
 	pktSourceChan chan gopacket.Packet
 
-	fuzzActive bool
-	fuzzSilent bool
-	fuzzLayers []string
-	fuzzRate   float64
-	fuzzRatio  float64
+	fuzzActive    bool
+	fuzzSilent    bool
+	fuzzMode      string
+	fuzzLayers    []string
+	fuzzTemplates map[string]bool
+	fuzzRate      float64
+	fuzzRatio     float64
 }
 
 func NewSniffer(s *session.Session) *Sniffer {
@@ -29,6 +37,8 @@ func NewSniffer(s *session.Session) *Sniffer {
 		Stats:         nil,
 	}
 
+	mod.State.Store("dropped", 0)
+
 	mod.SessionModule.Requires("net.recon")
 
 	mod.AddParam(session.NewBoolParameter("net.sniff.verbose",
@@ -44,21 +54,97 @@ func NewSniffer(s *session.Session) *Sniffer {
 		"",
 		"BPF filter for the sniffer."))
 
+	mod.AddParam(session.NewStringParameter("net.sniff.filter.preset",
+		"",
+		"^("+strings.Join(filterPresetNames(), "|")+")?$",
+		"If set, only capture the traffic of this preset, combined with net.sniff.filter: "+strings.Join(filterPresetNames(), ", ")+"."))
+
+	mod.AddParam(session.NewBoolParameter("net.sniff.reassembly",
+		"false",
+		"If true, the TCP streams are reassembled and the parsers see the multi segment requests and responses whole, otherwise each packet is parsed on its own."))
+
+	mod.AddParam(session.NewIntParameter("net.sniff.sample",
+		"1",
+		"If greater than 1, only one in this many flows is parsed, the packets of every flow are still counted and written to the output file."))
+
+	mod.AddParam(session.NewIntParameter("net.sniff.budget",
+		"0",
+		"If greater than 0, the milliseconds each parser can take every second, the parsers over budget are skipped until the next one."))
+
 	mod.AddParam(session.NewStringParameter("net.sniff.regexp",
 		"",
 		"",
 		"If set, only packets matching this regular expression will be considered."))
 
+	mod.AddParam(session.NewStringParameter("net.sniff.regexp.file",
+		"",
+		"",
+		"If set, search the payloads for the rules in this file, one per line as a name followed by a regular expression, pushing an event with the capture groups for each match."))
+
+	mod.AddParam(session.NewIntParameter("net.sniff.regexp.context",
+		"32",
+		"How many bytes around each match of the net.sniff.regexp.file rules to include in their events."))
+
+	mod.AddParam(session.NewStringParameter("net.sniff.carve",
+		"",
+		"",
+		"If set, the files transferred over HTTP and read over SMB are saved in this folder."))
+
+	mod.AddParam(session.NewStringParameter("net.sniff.carve.types",
+		"",
+		"",
+		"Comma separated list of extensions or content types the carved files must match, like pdf,docx,image, empty for any."))
+
+	mod.AddParam(session.NewIntParameter("net.sniff.carve.maxsize",
+		"10",
+		"Files bigger than this many megabytes are not carved."))
+
 	mod.AddParam(session.NewStringParameter("net.sniff.output",
 		"",
 		"",
-		"If set, the sniffer will write captured packets to this file."))
+		"If set, the sniffer will write captured packets or parsed events to this file."))
+
+	mod.AddParam(session.NewStringParameter("net.sniff.output.format",
+		FormatPcap,
+		"^(pcap|pcapng|jsonl)$",
+		"Format of the output file, pcap or pcapng, the latter saves the interface, filter and host the packets have been captured on, or jsonl to write the parsed events as JSON lines, the file can be a FIFO."))
+
+	mod.AddParam(session.NewBoolParameter("net.sniff.output.compress",
+		"false",
+		"If true, the output file is gzip compressed and the .gz extension is added to its name."))
+
+	mod.AddParam(session.NewIntParameter("net.sniff.output.rotation.size",
+		"0",
+		"If greater than 0, the output file is moved to a timestamped one and a new file is started when it gets bigger than this many megabytes."))
+
+	mod.AddParam(session.NewIntParameter("net.sniff.output.rotation.time",
+		"0",
+		"If greater than 0, the output file is moved to a timestamped one and a new file is started every this many seconds."))
+
+	mod.AddParam(session.NewStringParameter("net.sniff.rtp.record",
+		"",
+		"",
+		"If set, the RTP streams of the calls set up with SIP are saved in this folder, as WAV files if G.711 encoded or as raw payloads otherwise."))
 
 	mod.AddParam(session.NewStringParameter("net.sniff.source",
 		"",
 		"",
 		"If set, the sniffer will read from this pcap file instead of the current interface."))
 
+	mod.AddParam(session.NewStringParameter("net.sniff.interfaces",
+		"",
+		"",
+		"Comma separated list of interfaces to capture from together with the main one, events are tagged with the interface they've been captured on."))
+
+	mod.AddParam(session.NewStringParameter("net.sniff.backend",
+		BackendPcap,
+		"^(pcap|afpacket)$",
+		"Capture backend, pcap or afpacket to use AF_PACKET with TPACKETv3 ring buffers on Linux, faster on busy links."))
+
+	mod.AddParam(session.NewIntParameter("net.sniff.vlan",
+		"0",
+		"If greater than 0, sniff on the 802.1Q sub interface for this VLAN identifier, creating it if needed."))
+
 	mod.AddHandler(session.NewModuleHandler("net.sniff stats", "",
 		"Print sniffer session configuration and statistics.",
 		func(args []string) error {
@@ -71,6 +157,12 @@ func NewSniffer(s *session.Session) *Sniffer {
 			return mod.Stats.Print()
 		}))
 
+	mod.AddHandler(session.NewModuleHandler("net.sniff tls", "",
+		"Show the TLS destinations of every host, with the ALPN and JA3 fingerprint of the last client hello and the certificate subject.",
+		func(args []string) error {
+			return mod.ShowTLS()
+		}))
+
 	mod.AddHandler(session.NewModuleHandler("net.sniff on", "",
 		"Start network sniffer in background.",
 		func(args []string) error {
@@ -95,6 +187,12 @@ func NewSniffer(s *session.Session) *Sniffer {
 			return mod.StopFuzzing()
 		}))
 
+	mod.AddHandler(session.NewModuleHandler("net.fuzz stats", "",
+		"Show the targets fuzzed in protocol mode, with how many packets they have been sent and the anomalies detected.",
+		func(args []string) error {
+			return mod.ShowFuzzTargets()
+		}))
+
 	mod.AddParam(session.NewStringParameter("net.fuzz.layers",
 		"Payload",
 		"",
@@ -112,6 +210,16 @@ func NewSniffer(s *session.Session) *Sniffer {
 		"false",
 		"If true it will not report fuzzed packets."))
 
+	mod.AddParam(session.NewStringParameter("net.fuzz.mode",
+		FuzzModeBytes,
+		"^(bytes|protocol)$",
+		"Fuzzing mode, bytes to randomly change the bytes of net.fuzz.layers or protocol to mutate the fields of the net.fuzz.templates protocols keeping them well formed, looking for targets misbehaving or not responding anymore."))
+
+	mod.AddParam(session.NewStringParameter("net.fuzz.templates",
+		strings.Join(fuzzTemplateNames(), ","),
+		"",
+		"Comma separated list of protocols to fuzz in protocol mode, any of "+strings.Join(fuzzTemplateNames(), ", ")+"."))
+
 	return mod
 }
 
@@ -146,6 +254,27 @@ func (mod Sniffer) isLocalPacket(packet gopacket.Packet) bool {
 	return false
 }
 
+// sampledOut returns true if the packet belongs to a flow left out by
+// net.sniff.sample, both directions of a flow are parsed or left out together
+// so that its stream can still be reassembled.
+func (mod *Sniffer) sampledOut(pkt gopacket.Packet) bool {
+	if mod.Ctx.Sample <= 1 {
+		return false
+	}
+
+	// the fast hashes of A->B and B->A flows are the same
+	hash := uint64(0)
+	if network := pkt.NetworkLayer(); network != nil {
+		hash = network.NetworkFlow().FastHash()
+		if transport := pkt.TransportLayer(); transport != nil {
+			hash = hash*31 + transport.TransportFlow().FastHash()
+		}
+	} else if link := pkt.LinkLayer(); link != nil {
+		hash = link.LinkFlow().FastHash()
+	}
+	return hash%uint64(mod.Ctx.Sample) != 0
+}
+
 func (mod *Sniffer) onPacketMatched(pkt gopacket.Packet) {
 	if mainParser(pkt, mod.Ctx.Verbose) {
 		mod.Stats.NumDumped++
@@ -176,20 +305,34 @@ func (mod *Sniffer) Start() error {
 	return mod.SetRunning(true, func() {
 		mod.Stats = NewSnifferStats()
 
-		src := gopacket.NewPacketSource(mod.Ctx.Handle, mod.Ctx.Handle.LinkType())
-		mod.pktSourceChan = src.Packets()
+		quit := make(chan struct{})
+		defer close(quit)
+
+		lastDropsReport := time.Now()
+		mod.pktSourceChan = mod.Ctx.Packets(quit)
 		for packet := range mod.pktSourceChan {
 			if !mod.Running() {
 				mod.Debug("end pkt loop (pkt=%v filter='%s')", packet, mod.Ctx.Filter)
 				break
 			}
 
+			if len(mod.Ctx.Extra) > 0 {
+				name := mod.Ctx.interfaceName(packet.Metadata().InterfaceIndex)
+				mod.Stats.Interfaces[name]++
+				setEventsInterface(name)
+			}
+
 			now := time.Now()
 			if mod.Stats.FirstPacket.IsZero() {
 				mod.Stats.FirstPacket = now
 			}
 			mod.Stats.LastPacket = now
 
+			if now.Sub(lastDropsReport) >= dropsReportInterval {
+				mod.reportDrops()
+				lastDropsReport = now
+			}
+
 			isLocal := mod.isLocalPacket(packet)
 			if isLocal {
 				mod.Stats.NumLocal++
@@ -204,17 +347,28 @@ func (mod *Sniffer) Start() error {
 				if mod.Ctx.Compiled == nil || mod.Ctx.Compiled.Match(data) {
 					mod.Stats.NumMatched++
 
-					mod.onPacketMatched(packet)
+					if mod.sampledOut(packet) {
+						mod.Stats.NumSkipped++
+					} else {
+						mod.onPacketMatched(packet)
+						if len(mod.Ctx.Rules) > 0 {
+							mod.onRegexpRules(packet)
+						}
+					}
 
-					if mod.Ctx.OutputWriter != nil {
-						mod.Ctx.OutputWriter.WritePacket(packet.Metadata().CaptureInfo, data)
-						mod.Stats.NumWrote++
+					if mod.Ctx.OutputWriter != nil && mod.Ctx.OutputWriter.Format != FormatJSONL {
+						if err := mod.Ctx.OutputWriter.WritePacket(packet.Metadata().CaptureInfo, data); err != nil {
+							mod.Error("error writing to %s: %v", mod.Ctx.OutputWriter.Path, err)
+						} else {
+							mod.Stats.NumWrote++
+						}
 					}
 				}
 			}
 		}
 
 		mod.pktSourceChan = nil
+		setEventsInterface("")
 	})
 }
 
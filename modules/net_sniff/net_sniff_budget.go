@@ -0,0 +1,149 @@
+package net_sniff
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket/pcap"
+)
+
+// how often the dropped and skipped packets are reported
+const dropsReportInterval = time.Minute
+
+// budgetTracker limits the time each parser can take every second, the
+// parsers over budget are skipped until the next one.
+type budgetTracker struct {
+	sync.Mutex
+	budget  time.Duration
+	window  time.Time
+	spent   map[string]time.Duration
+	skipped map[string]uint64
+}
+
+var (
+	// the parsers budget if enabled, set by the sniffer context
+	parserBudget *budgetTracker
+
+	parserNamesLock = sync.Mutex{}
+	parserNames     = make(map[uintptr]string)
+)
+
+func newBudgetTracker(budget time.Duration) *budgetTracker {
+	return &budgetTracker{
+		budget:  budget,
+		window:  time.Now(),
+		spent:   make(map[string]time.Duration),
+		skipped: make(map[string]uint64),
+	}
+}
+
+// parserName returns the name of a parser function, like httpParser.
+func parserName(parser interface{}) string {
+	parserNamesLock.Lock()
+	defer parserNamesLock.Unlock()
+
+	ptr := reflect.ValueOf(parser).Pointer()
+	if name, found := parserNames[ptr]; found {
+		return name
+	}
+
+	name := "?"
+	if fn := runtime.FuncForPC(ptr); fn != nil {
+		name = fn.Name()
+		if dot := strings.LastIndex(name, "."); dot != -1 {
+			name = name[dot+1:]
+		}
+	}
+	parserNames[ptr] = name
+	return name
+}
+
+// Allow returns false if the parser has used all of its budget for this
+// second.
+func (b *budgetTracker) Allow(name string) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if now := time.Now(); now.Sub(b.window) >= time.Second {
+		b.window = now
+		b.spent = make(map[string]time.Duration)
+	}
+
+	if b.spent[name] >= b.budget {
+		b.skipped[name]++
+		return false
+	}
+	return true
+}
+
+func (b *budgetTracker) Spent(name string, took time.Duration) {
+	b.Lock()
+	defer b.Unlock()
+	b.spent[name] += took
+}
+
+// Skipped returns the names of the parsers skipped so far, sorted, and how
+// many times each.
+func (b *budgetTracker) Skipped() ([]string, map[string]uint64) {
+	b.Lock()
+	defer b.Unlock()
+
+	names := make([]string, 0, len(b.skipped))
+	skipped := make(map[string]uint64, len(b.skipped))
+	for name, count := range b.skipped {
+		names = append(names, name)
+		skipped[name] = count
+	}
+	sort.Strings(names)
+	return names, skipped
+}
+
+// withBudget runs the parser unless it's over budget, accounting the time
+// it took.
+func withBudget(parser interface{}, run func() bool) bool {
+	if parserBudget == nil {
+		return run()
+	}
+
+	name := parserName(parser)
+	if !parserBudget.Allow(name) {
+		return false
+	}
+
+	started := time.Now()
+	parsed := run()
+	parserBudget.Spent(name, time.Since(started))
+	return parsed
+}
+
+// reportDrops logs how many packets have been dropped by the kernel, sampled
+// out or skipped by the parsers over budget so far.
+func (mod *Sniffer) reportDrops() {
+	drops := make([]string, 0)
+	if handle, ok := mod.Ctx.Handle.(interface{ Stats() (*pcap.Stats, error) }); ok {
+		if stats, err := handle.Stats(); err == nil && stats.PacketsDropped > 0 {
+			mod.State.Store("dropped", stats.PacketsDropped)
+			drops = append(drops, fmt.Sprintf("%d dropped by the kernel", stats.PacketsDropped))
+		}
+	}
+
+	if mod.Stats.NumSkipped > 0 {
+		drops = append(drops, fmt.Sprintf("%d sampled out", mod.Stats.NumSkipped))
+	}
+
+	if parserBudget != nil {
+		names, skipped := parserBudget.Skipped()
+		for _, name := range names {
+			drops = append(drops, fmt.Sprintf("%d skipped by %s", skipped[name], name))
+		}
+	}
+
+	if len(drops) > 0 {
+		mod.Info("packets %s", strings.Join(drops, ", "))
+	}
+}
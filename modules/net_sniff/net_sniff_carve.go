@@ -0,0 +1,267 @@
+package net_sniff
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/dustin/go-humanize"
+	"github.com/evilsocket/islazy/tui"
+)
+
+// tracked requests and files, all of them are forgotten when there are more
+const carveMaxTracked = 4096
+
+var (
+	carveNameRe = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+	// the file carver if enabled, set by the sniffer context
+	carver *fileCarver
+)
+
+// smbFile is a file being read over SMB.
+type smbFile struct {
+	Name      string
+	Data      []byte
+	Truncated bool
+}
+
+// smbRead is a READ request waiting for its response.
+type smbRead struct {
+	FileID string
+	Offset uint64
+}
+
+// fileCarver reconstructs the files transferred over HTTP and SMB, saving
+// the ones matching the types filter and not bigger than maxSize. The files
+// being read over the same SMB session can't take more than maxSize bytes
+// of memory altogether.
+type fileCarver struct {
+	sync.Mutex
+	path    string
+	types   []string
+	maxSize int
+
+	// the last URL requested, by client to server flow
+	httpURLs map[string]string
+	// CREATE requests by session and message id, then the opened files
+	smbNames map[string]string
+	smbFiles map[string]*smbFile
+	smbReads map[string]smbRead
+	// bytes of the files being read, by session
+	smbBuffered map[string]int
+}
+
+func newFileCarver(path string, types []string, maxSize int) (*fileCarver, error) {
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	for i, t := range types {
+		types[i] = strings.ToLower(strings.TrimPrefix(t, "."))
+	}
+
+	return &fileCarver{
+		path:     path,
+		types:    types,
+		maxSize:  maxSize,
+		httpURLs: make(map[string]string),
+		smbNames: make(map[string]string),
+		smbFiles: make(map[string]*smbFile),
+		smbReads: make(map[string]smbRead),
+
+		smbBuffered: make(map[string]int),
+	}, nil
+}
+
+// wanted returns true if a file with this name and content type must be
+// saved, types can be extensions or parts of the content type.
+func (c *fileCarver) wanted(name string, contentType string) bool {
+	if len(c.types) == 0 {
+		return true
+	}
+
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+	contentType = strings.ToLower(contentType)
+	for _, t := range c.types {
+		if t == ext || strings.Contains(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *fileCarver) save(protocol string, srcIP, dstIP net.IP, source string, name string, data []byte, when time.Time) {
+	if len(data) == 0 {
+		return
+	}
+
+	base := carveNameRe.ReplaceAllString(path.Base(name), "_")
+	if base == "" || base == "_" || base == "." {
+		base = "file"
+	}
+	filename := filepath.Join(c.path, fmt.Sprintf("%s_%s_%s", when.Format("20060102150405.000000"), srcIP, base))
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		NewSnifferEvent(when, "file", srcIP.String(), dstIP.String(), nil,
+			"%s error saving %s: %v", tui.Wrap(tui.BACKRED+tui.FOREWHITE, "file"), filename, err).Push()
+		return
+	}
+
+	md5sum, sha1sum, sha256sum := md5.Sum(data), sha1.Sum(data), sha256.Sum256(data)
+	NewSnifferEvent(
+		when,
+		"file",
+		srcIP.String(),
+		dstIP.String(),
+		SniffData{
+			"protocol": protocol,
+			"source":   source,
+			"filename": filename,
+			"size":     len(data),
+			"type":     http.DetectContentType(data),
+			"md5":      hex.EncodeToString(md5sum[:]),
+			"sha1":     hex.EncodeToString(sha1sum[:]),
+			"sha256":   hex.EncodeToString(sha256sum[:]),
+		},
+		"%s %s > %s : %s %s saved to %s %s",
+		tui.Wrap(tui.BACKGREEN+tui.FOREBLACK, "file"),
+		vIP(srcIP),
+		vIP(dstIP),
+		tui.Yellow(source),
+		tui.Dim(humanize.Bytes(uint64(len(data)))),
+		tui.Bold(filename),
+		tui.Dim("sha256 "+hex.EncodeToString(sha256sum[:])),
+	).Push()
+}
+
+// OnHTTPRequest remembers the URL requested by a client.
+func (c *fileCarver) OnHTTPRequest(srcIP, dstIP net.IP, tcp *layers.TCP, url string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if len(c.httpURLs) >= carveMaxTracked {
+		c.httpURLs = make(map[string]string)
+	}
+	c.httpURLs[flowKey(srcIP, dstIP, tcp)] = url
+}
+
+// OnHTTPResponse saves the body of a response, named after the URL of its
+// request.
+func (c *fileCarver) OnHTTPResponse(srcIP, dstIP net.IP, pkt gopacket.Packet, tcp *layers.TCP, res HTTPResponse) {
+	c.Lock()
+	defer c.Unlock()
+
+	client := reverseFlowKey(srcIP, dstIP, tcp)
+	url, found := c.httpURLs[client]
+	if !found || res.StatusCode != 200 || len(res.Body) > c.maxSize || int64(len(res.Body)) < res.ContentLength {
+		return
+	}
+	delete(c.httpURLs, client)
+
+	name := strings.SplitN(url, "?", 2)[0]
+	if !c.wanted(name, res.ContentType+" "+http.DetectContentType(res.Body)) {
+		return
+	}
+	c.save("http", srcIP, dstIP, url, name, res.Body, pkt.Metadata().Timestamp)
+}
+
+// OnSMB2 follows the files read by a client, saving them once closed. key
+// identifies the session, direction aside.
+func (c *fileCarver) OnSMB2(srcIP, dstIP net.IP, pkt gopacket.Packet, key string, h *packets.SMB2Header, msg []byte) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	if len(c.smbNames) >= carveMaxTracked || len(c.smbReads) >= carveMaxTracked || len(c.smbFiles) >= carveMaxTracked || len(c.smbBuffered) >= carveMaxTracked {
+		c.smbNames = make(map[string]string)
+		c.smbReads = make(map[string]smbRead)
+		c.smbFiles = make(map[string]*smbFile)
+		c.smbBuffered = make(map[string]int)
+	}
+
+	msgKey := fmt.Sprintf("%s/%d/%d", key, h.SessionID, h.MessageID)
+	response := h.Flags&packets.SMB2FlagResponse != 0
+	switch {
+	case h.Command == packets.SMB2Create && !response:
+		if name, err := packets.ParseSMB2CreateRequest(msg); err == nil && name != "" {
+			c.smbNames[msgKey] = name
+		}
+
+	case h.Command == packets.SMB2Create && h.Status == packets.SMB2StatusSuccess:
+		if name, found := c.smbNames[msgKey]; found {
+			delete(c.smbNames, msgKey)
+			if fileID, err := packets.ParseSMB2CreateResponse(msg); err == nil {
+				c.smbFiles[key+"/"+hex.EncodeToString(fileID)] = &smbFile{Name: name}
+			}
+		}
+
+	case h.Command == packets.SMB2Read && !response:
+		if fileID, offset, err := packets.ParseSMB2ReadRequest(msg); err == nil {
+			c.smbReads[msgKey] = smbRead{FileID: key + "/" + hex.EncodeToString(fileID), Offset: offset}
+		}
+
+	case h.Command == packets.SMB2Read && h.Status == packets.SMB2StatusSuccess:
+		read, found := c.smbReads[msgKey]
+		if !found {
+			return false
+		}
+		delete(c.smbReads, msgKey)
+
+		file, found := c.smbFiles[read.FileID]
+		data, err := packets.ParseSMB2ReadResponse(msg)
+		if !found || err != nil || file.Truncated {
+			return found
+		}
+
+		end := read.Offset + uint64(len(data))
+		if end > uint64(c.maxSize) || c.smbBuffered[key]+int(end)-len(file.Data) > c.maxSize {
+			c.release(key, file)
+			file.Truncated = true
+			return true
+		} else if end > uint64(len(file.Data)) {
+			c.smbBuffered[key] += int(end) - len(file.Data)
+			file.Data = append(file.Data, make([]byte, int(end)-len(file.Data))...)
+		}
+		copy(file.Data[read.Offset:], data)
+		return true
+
+	case h.Command == packets.SMB2Close && !response:
+		if fileID, err := packets.ParseSMB2CloseRequest(msg); err == nil {
+			id := key + "/" + hex.EncodeToString(fileID)
+			if file, found := c.smbFiles[id]; found {
+				delete(c.smbFiles, id)
+				if !file.Truncated && c.wanted(file.Name, http.DetectContentType(file.Data)) {
+					c.save("smb", dstIP, srcIP, file.Name, file.Name, file.Data, pkt.Metadata().Timestamp)
+				}
+				c.release(key, file)
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// release frees the data of a file read over the session key.
+func (c *fileCarver) release(key string, file *smbFile) {
+	if c.smbBuffered[key] -= len(file.Data); c.smbBuffered[key] <= 0 {
+		delete(c.smbBuffered, key)
+	}
+	file.Data = nil
+}
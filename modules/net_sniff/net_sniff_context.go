@@ -1,6 +1,7 @@
 package net_sniff
 
 import (
+	"fmt"
 	"os"
 	"regexp"
 	"time"
@@ -9,23 +10,44 @@ import (
 	"github.com/bettercap/bettercap/network"
 	"github.com/bettercap/bettercap/session"
 
+	"github.com/google/gopacket"
 	"github.com/google/gopacket/pcap"
-	"github.com/google/gopacket/pcapgo"
 
+	"github.com/evilsocket/islazy/fs"
+	"github.com/evilsocket/islazy/str"
 	"github.com/evilsocket/islazy/tui"
 )
 
+// captureSource is an additional interface the sniffer captures from.
+type captureSource struct {
+	Interface string
+	Handle    network.CaptureHandle
+}
+
 type SnifferContext struct {
-	Handle       *pcap.Handle
+	Handle       network.CaptureHandle
+	Backend      string
 	Source       string
+	Interface    string
+	Extra        []captureSource
+	VLAN         int
 	DumpLocal    bool
 	Verbose      bool
 	Filter       string
 	Expression   string
 	Compiled     *regexp.Regexp
+	Reassembly   bool
+	Sample       int
+	Budget       int
+	RulesFile    string
+	Rules        []regexpRule
+	RegexpWindow int
 	Output       string
-	OutputFile   *os.File
-	OutputWriter *pcapgo.Writer
+	OutputWriter *SnifferOutput
+	RTPRecord    string
+	Carve        string
+	// sub interfaces used for the VLAN, released when closing
+	vlanIfaces []string
 }
 
 func (mod *Sniffer) GetContext() (error, *SnifferContext) {
@@ -37,19 +59,37 @@ func (mod *Sniffer) GetContext() (error, *SnifferContext) {
 		return err, ctx
 	}
 
+	if err, ctx.VLAN = mod.IntParam("net.sniff.vlan"); err != nil {
+		return err, ctx
+	}
+
 	if ctx.Source == "" {
-		/*
-		 * We don't want to pcap.BlockForever otherwise pcap_close(handle)
-		 * could hang waiting for a timeout to expire ...
-		 */
-		readTimeout := 500 * time.Millisecond
-		if ctx.Handle, err = network.CaptureWithTimeout(mod.Session.Interface.Name(), readTimeout); err != nil {
+		var extra string
+		if err, ctx.Backend = mod.StringParam("net.sniff.backend"); err != nil {
+			return err, ctx
+		} else if err, extra = mod.StringParam("net.sniff.interfaces"); err != nil {
+			return err, ctx
+		} else if ctx.Interface, ctx.Handle, err = ctx.openCapture(mod.Session.Interface.Name()); err != nil {
 			return err, ctx
 		}
+
+		for _, name := range str.Comma(extra) {
+			if name == mod.Session.Interface.Name() {
+				continue
+			}
+
+			source := captureSource{}
+			if source.Interface, source.Handle, err = ctx.openCapture(name); err != nil {
+				return err, ctx
+			}
+			ctx.Extra = append(ctx.Extra, source)
+		}
 	} else {
-		if ctx.Handle, err = pcap.OpenOffline(ctx.Source); err != nil {
+		handle, err := pcap.OpenOffline(ctx.Source)
+		if err != nil {
 			return err, ctx
 		}
+		ctx.Handle = handle
 	}
 
 	if err, ctx.Verbose = mod.BoolParam("net.sniff.verbose"); err != nil {
@@ -60,13 +100,24 @@ func (mod *Sniffer) GetContext() (error, *SnifferContext) {
 		return err, ctx
 	}
 
+	var preset string
 	if err, ctx.Filter = mod.StringParam("net.sniff.filter"); err != nil {
 		return err, ctx
+	} else if err, preset = mod.StringParam("net.sniff.filter.preset"); err != nil {
+		return err, ctx
+	} else if ctx.Filter, err = withFilterPreset(preset, ctx.Filter); err != nil {
+		return err, ctx
 	} else if ctx.Filter != "" {
 		err = ctx.Handle.SetBPFFilter(ctx.Filter)
 		if err != nil {
 			return err, ctx
 		}
+
+		for _, source := range ctx.Extra {
+			if err = source.Handle.SetBPFFilter(ctx.Filter); err != nil {
+				return fmt.Errorf("%s: %v", source.Interface, err), ctx
+			}
+		}
 	}
 
 	if err, ctx.Expression = mod.StringParam("net.sniff.regexp"); err != nil {
@@ -77,31 +128,207 @@ func (mod *Sniffer) GetContext() (error, *SnifferContext) {
 		}
 	}
 
+	if err, ctx.Sample = mod.IntParam("net.sniff.sample"); err != nil {
+		return err, ctx
+	} else if err, ctx.Budget = mod.IntParam("net.sniff.budget"); err != nil {
+		return err, ctx
+	} else if ctx.Budget > 0 {
+		parserBudget = newBudgetTracker(time.Duration(ctx.Budget) * time.Millisecond)
+	} else {
+		parserBudget = nil
+	}
+
+	if err, ctx.Reassembly = mod.BoolParam("net.sniff.reassembly"); err != nil {
+		return err, ctx
+	} else if ctx.Reassembly {
+		tcpStreams = newTCPReassembler()
+	} else {
+		tcpStreams = nil
+	}
+
+	if err, ctx.RulesFile = mod.StringParam("net.sniff.regexp.file"); err != nil {
+		return err, ctx
+	} else if err, ctx.RegexpWindow = mod.IntParam("net.sniff.regexp.context"); err != nil {
+		return err, ctx
+	} else if ctx.RulesFile != "" {
+		if ctx.Rules, err = loadRegexpRules(ctx.RulesFile); err != nil {
+			return err, ctx
+		}
+	}
+
 	if err, ctx.Output = mod.StringParam("net.sniff.output"); err != nil {
 		return err, ctx
 	} else if ctx.Output != "" {
-		if ctx.OutputFile, err = os.Create(ctx.Output); err != nil {
+		var format string
+		var compress bool
+		var rotationSize int
+		var rotationTime int
+
+		if err, format = mod.StringParam("net.sniff.output.format"); err != nil {
+			return err, ctx
+		} else if err, compress = mod.BoolParam("net.sniff.output.compress"); err != nil {
+			return err, ctx
+		} else if err, rotationSize = mod.IntParam("net.sniff.output.rotation.size"); err != nil {
+			return err, ctx
+		} else if err, rotationTime = mod.IntParam("net.sniff.output.rotation.time"); err != nil {
+			return err, ctx
+		} else if ctx.OutputWriter, err = NewSnifferOutput(ctx.Output, format, compress, ctx.Handle.LinkType()); err != nil {
 			return err, ctx
 		}
 
-		ctx.OutputWriter = pcapgo.NewWriter(ctx.OutputFile)
-		ctx.OutputWriter.WriteFileHeader(65536, ctx.Handle.LinkType())
+		ctx.OutputWriter.MaxSize = int64(rotationSize) * 1024 * 1024
+		ctx.OutputWriter.MaxAge = time.Duration(rotationTime) * time.Second
+		ctx.OutputWriter.SetInterface(ctx.captureName(), ctx.Filter, mod.hostInfo())
+
+		if err = ctx.OutputWriter.Open(); err != nil {
+			return err, ctx
+		} else if format == FormatJSONL {
+			setEventsOutput(ctx.OutputWriter)
+		}
+	}
+
+	if err, ctx.RTPRecord = mod.StringParam("net.sniff.rtp.record"); err != nil {
+		return err, ctx
+	} else if ctx.RTPRecord != "" {
+		if ctx.RTPRecord, err = fs.Expand(ctx.RTPRecord); err != nil {
+			return err, ctx
+		}
+	}
+
+	if err = voip.Reset(ctx.RTPRecord); err != nil {
+		return err, ctx
+	}
+
+	var carveTypes string
+	var carveSize int
+	if err, ctx.Carve = mod.StringParam("net.sniff.carve"); err != nil {
+		return err, ctx
+	} else if err, carveTypes = mod.StringParam("net.sniff.carve.types"); err != nil {
+		return err, ctx
+	} else if err, carveSize = mod.IntParam("net.sniff.carve.maxsize"); err != nil {
+		return err, ctx
+	}
+
+	carver, tcpMaxMessage = nil, tcpMaxBuffer
+	if ctx.Carve != "" {
+		if ctx.Carve, err = fs.Expand(ctx.Carve); err != nil {
+			return err, ctx
+		} else if carver, err = newFileCarver(ctx.Carve, str.Comma(carveTypes), carveSize*1024*1024); err != nil {
+			return err, ctx
+		}
+
+		if !ctx.Reassembly {
+			mod.Warning("files spanning multiple packets can't be carved without net.sniff.reassembly")
+		} else if carveSize*1024*1024 > tcpMaxMessage {
+			tcpMaxMessage = carveSize * 1024 * 1024
+		}
 	}
 
 	return nil, ctx
 }
 
+// openCapture opens the capture of the interface, or of its sub interface
+// for the VLAN if set, with the configured backend.
+func (c *SnifferContext) openCapture(name string) (string, network.CaptureHandle, error) {
+	var err error
+	if c.VLAN > 0 {
+		if name, err = network.CreateVLANInterface(name, c.VLAN); err != nil {
+			return name, nil, err
+		}
+		c.vlanIfaces = append(c.vlanIfaces, name)
+	}
+
+	/*
+	 * We don't want to pcap.BlockForever otherwise pcap_close(handle)
+	 * could hang waiting for a timeout to expire ...
+	 */
+	readTimeout := 500 * time.Millisecond
+	if c.Backend == BackendAFPacket {
+		handle, err := network.CaptureAFPacket(name, readTimeout)
+		return name, handle, err
+	}
+
+	handle, err := network.CaptureWithTimeout(name, readTimeout)
+	if err != nil {
+		return name, nil, err
+	}
+	return name, handle, nil
+}
+
+// Packets returns the packets of all the interfaces, the ones of the
+// additional interfaces with their index in Extra plus one as the
+// InterfaceIndex. The forwarders quit when quit is closed.
+func (c *SnifferContext) Packets(quit chan struct{}) chan gopacket.Packet {
+	primary := gopacket.NewPacketSource(c.Handle, c.Handle.LinkType()).Packets()
+	if len(c.Extra) == 0 {
+		return primary
+	}
+
+	merged := make(chan gopacket.Packet)
+	forward := func(index int, packets chan gopacket.Packet) {
+		for packet := range packets {
+			packet.Metadata().InterfaceIndex = index
+			select {
+			case merged <- packet:
+			case <-quit:
+				return
+			}
+		}
+	}
+
+	go forward(0, primary)
+	for i, source := range c.Extra {
+		go forward(i+1, gopacket.NewPacketSource(source.Handle, source.Handle.LinkType()).Packets())
+	}
+	return merged
+}
+
+// interfaceName returns the name of the interface of a packet returned by
+// Packets.
+func (c *SnifferContext) interfaceName(index int) string {
+	if index > 0 && index <= len(c.Extra) {
+		return c.Extra[index-1].Interface
+	}
+	return c.Interface
+}
+
+// captureName returns the name of the interface or file packets are read from.
+func (c *SnifferContext) captureName() string {
+	if c.Source != "" {
+		return c.Source
+	}
+	return c.Interface
+}
+
+// hostInfo describes the host capturing the packets for the pcapng metadata.
+func (mod *Sniffer) hostInfo() string {
+	hostname, _ := os.Hostname()
+	iface := mod.Session.Interface
+	return fmt.Sprintf("%s (%s, %s)", hostname, iface.IpAddress, iface.HwAddress)
+}
+
 func NewSnifferContext() *SnifferContext {
 	return &SnifferContext{
 		Handle:       nil,
+		Backend:      BackendPcap,
+		Interface:    "",
+		Extra:        nil,
+		VLAN:         0,
 		DumpLocal:    false,
 		Verbose:      false,
 		Filter:       "",
 		Expression:   "",
 		Compiled:     nil,
+		Reassembly:   false,
+		Sample:       1,
+		Budget:       0,
+		RulesFile:    "",
+		Rules:        nil,
+		RegexpWindow: 0,
 		Output:       "",
-		OutputFile:   nil,
 		OutputWriter: nil,
+		RTPRecord:    "",
+		Carve:        "",
 	}
 }
 
@@ -115,11 +342,33 @@ var (
 )
 
 func (c *SnifferContext) Log(sess *session.Session) {
+	if c.Interface != "" {
+		log.Info("Interface          : %s (%s)", tui.Bold(c.Interface), c.Backend)
+	}
+	for _, source := range c.Extra {
+		log.Info("Interface          : %s (%s)", tui.Bold(source.Interface), c.Backend)
+	}
 	log.Info("Skip local packets : %s", yn[c.DumpLocal])
 	log.Info("Verbose            : %s", yn[c.Verbose])
 	log.Info("BPF Filter         : '%s'", tui.Yellow(c.Filter))
 	log.Info("Regular expression : '%s'", tui.Yellow(c.Expression))
-	log.Info("File output        : '%s'", tui.Yellow(c.Output))
+	log.Info("TCP reassembly     : %s", yn[c.Reassembly])
+	if c.Sample > 1 {
+		log.Info("Sampling           : 1 in %d flows", c.Sample)
+	}
+	if c.Budget > 0 {
+		log.Info("Parsers budget     : %dms per second", c.Budget)
+	}
+	if c.RulesFile != "" {
+		log.Info("Regexp rules       : '%s' (%d rules)", tui.Yellow(c.RulesFile), len(c.Rules))
+	}
+	if c.OutputWriter != nil {
+		log.Info("File output        : '%s'", tui.Yellow(c.OutputWriter.String()))
+	} else {
+		log.Info("File output        : '%s'", tui.Yellow(c.Output))
+	}
+	log.Info("RTP recording      : '%s'", tui.Yellow(c.RTPRecord))
+	log.Info("File carving       : '%s'", tui.Yellow(c.Carve))
 }
 
 func (c *SnifferContext) Close() {
@@ -130,10 +379,32 @@ func (c *SnifferContext) Close() {
 		c.Handle = nil
 	}
 
-	if c.OutputFile != nil {
+	for _, source := range c.Extra {
+		source.Handle.Close()
+	}
+	c.Extra = nil
+
+	for _, name := range c.vlanIfaces {
+		log.Debug("releasing interface %s", name)
+		if err := network.DeleteVLANInterface(name); err != nil {
+			log.Error("error deleting interface %s: %v", name, err)
+		}
+	}
+	c.vlanIfaces = nil
+
+	if c.OutputWriter != nil {
+		setEventsOutput(nil)
 		log.Debug("closing output")
-		c.OutputFile.Close()
+		if err := c.OutputWriter.Close(); err != nil {
+			log.Error("error closing %s: %v", c.OutputWriter.Path, err)
+		}
 		log.Debug("output closed")
-		c.OutputFile = nil
+		c.OutputWriter = nil
 	}
+
+	if tcpStreams != nil {
+		tcpStreams.Close()
+	}
+
+	voip.Close()
 }
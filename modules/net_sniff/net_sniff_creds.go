@@ -0,0 +1,90 @@
+package net_sniff
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/google/gopacket/layers"
+)
+
+var (
+	// form fields likely to hold the username and the password
+	formUserRe = regexp.MustCompile(`(?i)user|login|mail|account|name`)
+	formPassRe = regexp.MustCompile(`(?i)pass|pwd|secret`)
+
+	// USER commands of FTP and POP3 waiting for their PASS, by flow
+	pendingUsers = sync.Map{}
+)
+
+func flowKey(srcIP, dstIP net.IP, tcp *layers.TCP) string {
+	return fmt.Sprintf("%s:%d>%s:%d", srcIP, tcp.SrcPort, dstIP, tcp.DstPort)
+}
+
+// reverseFlowKey returns the key of the flow going the opposite way, where
+// the other side of a connection answers.
+func reverseFlowKey(srcIP, dstIP net.IP, tcp *layers.TCP) string {
+	return fmt.Sprintf("%s:%d>%s:%d", dstIP, tcp.DstPort, srcIP, tcp.SrcPort)
+}
+
+func addCredential(protocol string, kind string, client string, server string, username string, secret string) {
+	session.I.AddCredential(session.Credential{
+		Protocol: protocol,
+		Type:     kind,
+		Source:   "net.sniff",
+		Client:   client,
+		Server:   server,
+		Username: username,
+		Secret:   secret,
+	})
+}
+
+func tcpServer(dstIP net.IP, tcp *layers.TCP) string {
+	return net.JoinHostPort(dstIP.String(), fmt.Sprintf("%d", tcp.DstPort))
+}
+
+// onUserPass pairs the USER and PASS commands of a flow into a credential.
+func onUserPass(protocol string, srcIP, dstIP net.IP, tcp *layers.TCP, what string, value string) {
+	key := flowKey(srcIP, dstIP, tcp)
+	if strings.ToUpper(what) == "USER" {
+		pendingUsers.Store(key, value)
+		return
+	}
+
+	user := ""
+	if pending, found := pendingUsers.Load(key); found {
+		user = pending.(string)
+		pendingUsers.Delete(key)
+	}
+	addCredential(protocol, session.CredentialPassword, srcIP.String(), tcpServer(dstIP, tcp), user, value)
+}
+
+// formCredentials looks for a password, and the username next to it, in the
+// URL encoded form of a request.
+func formCredentials(req HTTPRequest) (string, string, bool) {
+	if !req.IsType("application/x-www-form-urlencoded") || len(req.Body) == 0 {
+		return "", "", false
+	}
+
+	form, err := url.ParseQuery(string(req.Body))
+	if err != nil {
+		return "", "", false
+	}
+
+	user, pass, found := "", "", false
+	for name, values := range form {
+		if len(values) == 0 || values[0] == "" {
+			continue
+		} else if formPassRe.MatchString(name) && !found {
+			pass, found = values[0], true
+		} else if formUserRe.MatchString(name) && user == "" {
+			user = values[0]
+		}
+	}
+	return user, pass, found
+}
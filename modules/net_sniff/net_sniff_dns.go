@@ -1,6 +1,7 @@
 package net_sniff
 
 import (
+	"encoding/binary"
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"net"
@@ -9,12 +10,9 @@ import (
 	"github.com/evilsocket/islazy/tui"
 )
 
-func dnsParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *layers.UDP) bool {
-	dns, parsed := pkt.Layer(layers.LayerTypeDNS).(*layers.DNS)
-	if !parsed {
-		return false
-	}
+const dnsPort = 53
 
+func onDNS(srcIP, dstIP net.IP, dns *layers.DNS, pkt gopacket.Packet) bool {
 	if dns.OpCode != layers.DNSOpCodeQuery {
 		return false
 	}
@@ -65,3 +63,37 @@ func dnsParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *la
 
 	return true
 }
+
+func dnsParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *layers.UDP) bool {
+	dns, parsed := pkt.Layer(layers.LayerTypeDNS).(*layers.DNS)
+	if !parsed {
+		return false
+	}
+	return onDNS(srcIP, dstIP, dns, pkt)
+}
+
+// DNS over TCP messages are prefixed by their size, mostly used for zone
+// transfers and truncated responses.
+func dnsTCPParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+	if tcp.SrcPort != dnsPort && tcp.DstPort != dnsPort {
+		return false
+	}
+
+	parsed := false
+	for data := tcp.Payload; len(data) > 2; {
+		size := int(binary.BigEndian.Uint16(data))
+		if size == 0 || len(data) < 2+size {
+			break
+		}
+
+		dns := &layers.DNS{}
+		if err := dns.DecodeFromBytes(data[2:2+size], gopacket.NilDecodeFeedback); err != nil {
+			break
+		} else if onDNS(srcIP, dstIP, dns, pkt) {
+			parsed = true
+		}
+		data = data[2+size:]
+	}
+
+	return parsed
+}
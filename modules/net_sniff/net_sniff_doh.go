@@ -0,0 +1,152 @@
+package net_sniff
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+const (
+	dotPort = 853
+
+	encryptedDNSOverTLS   = "dot"
+	encryptedDNSOverHTTPS = "doh"
+)
+
+var (
+	// server names of the public DNS over HTTPS resolvers
+	dohServerNames = []string{
+		"dns.google",
+		"dns.google.com",
+		"cloudflare-dns.com",
+		"mozilla.cloudflare-dns.com",
+		"chrome.cloudflare-dns.com",
+		"one.one.one.one",
+		"1dot1dot1dot1.cloudflare-dns.com",
+		"security.cloudflare-dns.com",
+		"family.cloudflare-dns.com",
+		"dns.quad9.net",
+		"dns9.quad9.net",
+		"dns10.quad9.net",
+		"dns11.quad9.net",
+		"doh.opendns.com",
+		"doh.familyshield.opendns.com",
+		"dns.nextdns.io",
+		"dns.adguard.com",
+		"dns.adguard-dns.com",
+		"doh.cleanbrowsing.org",
+		"doh.dns.sb",
+		"dns.switch.ch",
+		"doh.mullvad.net",
+		"dns.controld.com",
+		"freedns.controld.com",
+	}
+
+	// addresses of the public resolvers also answering over HTTPS
+	dohResolvers = []string{
+		"8.8.8.8",
+		"8.8.4.4",
+		"1.1.1.1",
+		"1.0.0.1",
+		"1.1.1.2",
+		"1.0.0.2",
+		"1.1.1.3",
+		"1.0.0.3",
+		"9.9.9.9",
+		"149.112.112.112",
+		"9.9.9.10",
+		"9.9.9.11",
+		"208.67.222.222",
+		"208.67.220.220",
+		"94.140.14.14",
+		"94.140.15.15",
+		"185.228.168.9",
+		"185.228.169.9",
+		"45.90.28.0",
+		"45.90.30.0",
+		"2001:4860:4860::8888",
+		"2001:4860:4860::8844",
+		"2606:4700:4700::1111",
+		"2606:4700:4700::1001",
+		"2620:fe::fe",
+		"2620:fe::9",
+		"2620:119:35::35",
+		"2620:119:53::53",
+	}
+
+	// client and resolver couples already reported
+	encryptedDNSSeen = sync.Map{}
+)
+
+func isDoHServerName(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for _, known := range dohServerNames {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+func isDoHResolver(ip net.IP) bool {
+	for _, known := range dohResolvers {
+		if ip.Equal(net.ParseIP(known)) {
+			return true
+		}
+	}
+	return false
+}
+
+// encryptedDNSKind returns the kind of encrypted DNS of a TLS connection, if
+// it's DNS over TLS or to a known DNS over HTTPS resolver.
+func encryptedDNSKind(dstIP net.IP, tcp *layers.TCP, sni string) string {
+	if tcp.DstPort == dotPort {
+		return encryptedDNSOverTLS
+	} else if tcp.DstPort == 443 && (isDoHServerName(sni) || isDoHResolver(dstIP)) {
+		return encryptedDNSOverHTTPS
+	}
+	return ""
+}
+
+// onEncryptedDNS reports, once per client and resolver, the DNS queries
+// the sniffer can't see, marking the client endpoint.
+func onEncryptedDNS(kind string, srcIP, dstIP net.IP, sni string, pkt gopacket.Packet) {
+	resolver := dstIP.String()
+	if sni != "" && sni != resolver {
+		resolver = fmt.Sprintf("%s (%s)", sni, dstIP)
+	}
+
+	key := fmt.Sprintf("%s>%s>%s", kind, srcIP, resolver)
+	if _, seen := encryptedDNSSeen.LoadOrStore(key, true); seen {
+		return
+	}
+
+	if e := session.I.Lan.GetByIp(srcIP.String()); e != nil {
+		e.Meta.Set("dns:encrypted", kind)
+		e.Meta.SetStrings("dns:resolvers", e.Meta.GetStringsWith("dns:resolvers", []string{resolver}, true))
+	}
+
+	NewSnifferEvent(
+		pkt.Metadata().Timestamp,
+		"dns.encrypted",
+		srcIP.String(),
+		dstIP.String(),
+		SniffData{
+			"kind":     kind,
+			"resolver": resolver,
+		},
+		"%s %s > %s : %s",
+		tui.Wrap(tui.BACKDARKGRAY+tui.FOREWHITE, kind),
+		vIP(srcIP),
+		tui.Yellow(resolver),
+		tui.Dim("encrypted DNS, queries are not visible"),
+	).Push()
+}
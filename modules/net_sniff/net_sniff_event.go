@@ -2,11 +2,33 @@ package net_sniff
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/bettercap/bettercap/log"
 	"github.com/bettercap/bettercap/session"
 )
 
+var (
+	// the jsonl output if enabled, set and cleared while events are pushed
+	eventsLock   = sync.Mutex{}
+	eventsOutput *SnifferOutput
+	// the interface of the packet being parsed, when capturing from many
+	eventsInterface string
+)
+
+func setEventsOutput(output *SnifferOutput) {
+	eventsLock.Lock()
+	defer eventsLock.Unlock()
+	eventsOutput = output
+}
+
+func setEventsInterface(name string) {
+	eventsLock.Lock()
+	defer eventsLock.Unlock()
+	eventsInterface = name
+}
+
 type SniffData map[string]interface{}
 
 type SnifferEvent struct {
@@ -14,22 +36,36 @@ type SnifferEvent struct {
 	Protocol    string      `json:"protocol"`
 	Source      string      `json:"from"`
 	Destination string      `json:"to"`
+	Interface   string      `json:"interface,omitempty"`
 	Message     string      `json:"message"`
 	Data        interface{} `json:"data"`
 }
 
 func NewSnifferEvent(t time.Time, proto string, src string, dst string, data interface{}, format string, args ...interface{}) SnifferEvent {
+	eventsLock.Lock()
+	iface := eventsInterface
+	eventsLock.Unlock()
+
 	return SnifferEvent{
 		PacketTime:  t,
 		Protocol:    proto,
 		Source:      src,
 		Destination: dst,
+		Interface:   iface,
 		Message:     fmt.Sprintf(format, args...),
 		Data:        data,
 	}
 }
 
 func (e SnifferEvent) Push() {
+	eventsLock.Lock()
+	if eventsOutput != nil {
+		if err := eventsOutput.WriteEvent(e); err != nil {
+			log.Error("error writing to %s: %v", eventsOutput.Path, err)
+		}
+	}
+	eventsLock.Unlock()
+
 	session.I.Events.Add("net.sniff."+e.Protocol, e)
 	session.I.Refresh()
 }
@@ -21,6 +21,12 @@ func ftpParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *la
 	if matches := ftpRe.FindAllStringSubmatch(data, -1); matches != nil {
 		what := str.Trim(matches[0][1])
 		cred := str.Trim(matches[0][2])
+		proto := "ftp"
+		if tcp.DstPort == 110 {
+			proto = "pop3"
+		}
+		onUserPass(proto, srcIP, dstIP, tcp, what, cred)
+
 		NewSnifferEvent(
 			pkt.Metadata().Timestamp,
 			"ftp",
@@ -1,6 +1,7 @@
 package net_sniff
 
 import (
+	"fmt"
 	"math/rand"
 	"strings"
 
@@ -35,8 +36,19 @@ func (mod *Sniffer) fuzz(data []byte) int {
 }
 
 func (mod *Sniffer) doFuzzing(pkt gopacket.Packet) {
+	if mod.fuzzMode == FuzzModeProtocol {
+		// our own frames are neither responses nor worth fuzzing again
+		if fuzzed.IsOwn(pkt.Data()) {
+			return
+		}
+		mod.observeFuzzTargets(pkt)
+	}
+
 	if rand.Float64() > mod.fuzzRate {
 		return
+	} else if mod.fuzzMode == FuzzModeProtocol {
+		mod.doProtocolFuzzing(pkt)
+		return
 	}
 
 	layersChanged := 0
@@ -89,6 +101,22 @@ func (mod *Sniffer) configureFuzzing() (err error) {
 		return
 	}
 
+	if err, mod.fuzzMode = mod.StringParam("net.fuzz.mode"); err != nil {
+		return
+	}
+
+	templates := ""
+	if err, templates = mod.StringParam("net.fuzz.templates"); err != nil {
+		return
+	}
+	mod.fuzzTemplates = make(map[string]bool)
+	for _, name := range str.Comma(templates) {
+		if !isFuzzTemplate(name) {
+			return fmt.Errorf("unknown fuzzing template '%s', use one of %s", name, strings.Join(fuzzTemplateNames(), ", "))
+		}
+		mod.fuzzTemplates[name] = true
+	}
+
 	return
 }
 
@@ -107,7 +135,17 @@ func (mod *Sniffer) StartFuzzing() error {
 
 	mod.fuzzActive = true
 
-	mod.Info("active on layer types %s (rate:%f ratio:%f)", strings.Join(mod.fuzzLayers, ","), mod.fuzzRate, mod.fuzzRatio)
+	if mod.fuzzMode == FuzzModeProtocol {
+		protos := make([]string, 0)
+		for _, name := range fuzzTemplateNames() {
+			if mod.fuzzTemplates[name] {
+				protos = append(protos, name)
+			}
+		}
+		mod.Info("active on protocols %s (rate:%f ratio:%f)", strings.Join(protos, ","), mod.fuzzRate, mod.fuzzRatio)
+	} else {
+		mod.Info("active on layer types %s (rate:%f ratio:%f)", strings.Join(mod.fuzzLayers, ","), mod.fuzzRate, mod.fuzzRatio)
+	}
 
 	return nil
 }
@@ -0,0 +1,449 @@
+package net_sniff
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+const (
+	FuzzModeBytes    = "bytes"
+	FuzzModeProtocol = "protocol"
+
+	// targets not sending anything for this long after being fuzzed, while
+	// they were before, might have crashed
+	fuzzTargetTimeout = 5 * time.Second
+	// how many of the frames we sent are remembered to recognize them when
+	// they're sniffed back
+	fuzzMaxSentFrames = 4096
+)
+
+// fuzzTemplate mutates the fields of a protocol within its structure, it
+// returns false if the packet doesn't carry it.
+type fuzzTemplate struct {
+	Name   string
+	Mutate func(pkt gopacket.Packet, ratio float64) bool
+}
+
+var fuzzTemplates = []fuzzTemplate{
+	{"dns", func(pkt gopacket.Packet, ratio float64) bool { return fuzzDNS(pkt, ratio, false) }},
+	{"mdns", func(pkt gopacket.Packet, ratio float64) bool { return fuzzDNS(pkt, ratio, true) }},
+	{"dhcp", fuzzDHCP},
+	{"arp", fuzzARP},
+}
+
+func fuzzTemplateNames() []string {
+	names := make([]string, 0, len(fuzzTemplates))
+	for _, t := range fuzzTemplates {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func isFuzzTemplate(name string) bool {
+	for _, t := range fuzzTemplates {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzTarget is a host fuzzed packets have been sent to.
+type fuzzTarget struct {
+	Address   string
+	Protocols map[string]bool
+	Sent      int
+	Responses int
+	Anomalies int
+	Down      bool
+	LastSent  time.Time
+	LastSeen  time.Time
+}
+
+type fuzzTargets struct {
+	sync.Mutex
+	targets map[string]*fuzzTarget
+	frames  map[uint64]bool
+}
+
+var fuzzed = fuzzTargets{
+	targets: make(map[string]*fuzzTarget),
+	frames:  make(map[uint64]bool),
+}
+
+func frameHash(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
+
+func fuzzBytes(size int) []byte {
+	data := make([]byte, size)
+	rand.Read(data)
+	return data
+}
+
+// fuzzString returns one of the strings parsers usually choke on.
+func fuzzString(max int) []byte {
+	var s string
+	switch rand.Intn(5) {
+	case 0:
+		s = strings.Repeat("A", rand.Intn(max+1))
+	case 1:
+		s = strings.Repeat("%s%n", max/4)
+	case 2:
+		s = string(fuzzBytes(rand.Intn(max + 1)))
+	case 3:
+		s = strings.Repeat("\xff\xfe", max/2)
+	default:
+		s = ""
+	}
+	if len(s) > max {
+		s = s[:max]
+	}
+	return []byte(s)
+}
+
+// fuzzName returns a domain name with valid label sizes but unusual labels.
+func fuzzName() []byte {
+	labels := make([]string, 0)
+	size := 0
+	for n := 1 + rand.Intn(8); n > 0; n-- {
+		label := strings.ReplaceAll(string(fuzzString(63)), ".", "-")
+		if label == "" {
+			label = "x"
+		}
+		if size+len(label)+1 > 253 {
+			break
+		}
+		labels = append(labels, label)
+		size += len(label) + 1
+	}
+	return []byte(strings.Join(labels, "."))
+}
+
+func fuzzDNS(pkt gopacket.Packet, ratio float64, mdns bool) bool {
+	dns, ok := pkt.Layer(layers.LayerTypeDNS).(*layers.DNS)
+	if !ok {
+		return false
+	}
+
+	udp, ok := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	if isMDNS := ok && (udp.SrcPort == 5353 || udp.DstPort == 5353); isMDNS != mdns {
+		return false
+	}
+
+	if rand.Float64() < ratio {
+		dns.ID = uint16(rand.Intn(0x10000))
+	}
+	if rand.Float64() < ratio {
+		dns.OpCode = layers.DNSOpCode(rand.Intn(16))
+	}
+	if rand.Float64() < ratio {
+		dns.ResponseCode = layers.DNSResponseCode(rand.Intn(16))
+	}
+	if rand.Float64() < ratio {
+		dns.QR, dns.AA, dns.TC, dns.RD, dns.RA = !dns.QR, !dns.AA, !dns.TC, !dns.RD, !dns.RA
+	}
+
+	for i := range dns.Questions {
+		if rand.Float64() < ratio {
+			dns.Questions[i].Name = fuzzName()
+		}
+		if rand.Float64() < ratio {
+			dns.Questions[i].Type = layers.DNSType(rand.Intn(0x10000))
+		}
+		if rand.Float64() < ratio {
+			dns.Questions[i].Class = layers.DNSClass(rand.Intn(0x10000))
+		}
+	}
+
+	if len(dns.Questions) > 0 && rand.Float64() < ratio {
+		for n := rand.Intn(32); n > 0; n-- {
+			dns.Questions = append(dns.Questions, dns.Questions[0])
+		}
+	}
+
+	if rand.Float64() < ratio {
+		txts := make([][]byte, 0)
+		for n := 1 + rand.Intn(8); n > 0; n-- {
+			txts = append(txts, fuzzString(255))
+		}
+		dns.Answers = append(dns.Answers, layers.DNSResourceRecord{
+			Name:  fuzzName(),
+			Type:  layers.DNSTypeTXT,
+			Class: layers.DNSClassIN,
+			TTL:   uint32(rand.Int63()),
+			TXTs:  txts,
+		})
+	}
+
+	return true
+}
+
+func fuzzDHCP(pkt gopacket.Packet, ratio float64) bool {
+	dhcp, ok := pkt.Layer(layers.LayerTypeDHCPv4).(*layers.DHCPv4)
+	if !ok {
+		return false
+	}
+
+	if rand.Float64() < ratio {
+		dhcp.Xid = rand.Uint32()
+	}
+	if rand.Float64() < ratio {
+		dhcp.Flags = uint16(rand.Intn(0x10000))
+	}
+	if rand.Float64() < ratio {
+		dhcp.ServerName = fuzzString(64)
+	}
+	if rand.Float64() < ratio {
+		dhcp.File = fuzzString(128)
+	}
+
+	for i, opt := range dhcp.Options {
+		if opt.Type == layers.DHCPOptEnd || opt.Type == layers.DHCPOptPad || rand.Float64() >= ratio {
+			continue
+		}
+		data := fuzzString(255)
+		dhcp.Options[i] = layers.NewDHCPOption(opt.Type, data)
+	}
+
+	if rand.Float64() < ratio {
+		extra := layers.NewDHCPOption(layers.DHCPOpt(1+rand.Intn(254)), fuzzBytes(rand.Intn(256)))
+		if n := len(dhcp.Options); n > 0 && dhcp.Options[n-1].Type == layers.DHCPOptEnd {
+			dhcp.Options = append(dhcp.Options[:n-1], extra, dhcp.Options[n-1])
+		} else {
+			dhcp.Options = append(dhcp.Options, extra)
+		}
+	}
+
+	return true
+}
+
+func fuzzARP(pkt gopacket.Packet, ratio float64) bool {
+	arp, ok := pkt.Layer(layers.LayerTypeARP).(*layers.ARP)
+	if !ok {
+		return false
+	}
+
+	if rand.Float64() < ratio {
+		operations := []uint16{layers.ARPRequest, layers.ARPReply, 3, 4, 8, 9, uint16(rand.Intn(0x10000))}
+		arp.Operation = operations[rand.Intn(len(operations))]
+	}
+	if rand.Float64() < ratio {
+		arp.SourceHwAddress = fuzzBytes(len(arp.SourceHwAddress))
+	}
+	if rand.Float64() < ratio {
+		arp.SourceProtAddress = fuzzBytes(len(arp.SourceProtAddress))
+	}
+	if rand.Float64() < ratio {
+		arp.DstHwAddress = fuzzBytes(len(arp.DstHwAddress))
+	}
+
+	return true
+}
+
+// fuzzDestination returns the address of the host a packet is sent to.
+func fuzzDestination(pkt gopacket.Packet) string {
+	if arp, ok := pkt.Layer(layers.LayerTypeARP).(*layers.ARP); ok {
+		return net.IP(arp.DstProtAddress).String()
+	} else if nl := pkt.NetworkLayer(); nl != nil {
+		return nl.NetworkFlow().Dst().String()
+	}
+	return ""
+}
+
+// serialize rebuilds the packet from its decoded and possibly mutated
+// layers, fixing lengths and checksums.
+func serialize(pkt gopacket.Packet) ([]byte, error) {
+	serializable := make([]gopacket.SerializableLayer, 0)
+	for _, layer := range pkt.Layers() {
+		if udp, ok := layer.(*layers.UDP); ok && pkt.NetworkLayer() != nil {
+			udp.SetNetworkLayerForChecksum(pkt.NetworkLayer())
+		}
+
+		sl, ok := layer.(gopacket.SerializableLayer)
+		if !ok {
+			break
+		}
+		serializable = append(serializable, sl)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, serializable...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (mod *Sniffer) doProtocolFuzzing(pkt gopacket.Packet) {
+	for _, template := range fuzzTemplates {
+		if !mod.fuzzTemplates[template.Name] || !template.Mutate(pkt, mod.fuzzRatio) {
+			continue
+		}
+
+		data, err := serialize(pkt)
+		if err != nil {
+			mod.Debug("error serializing fuzzed %s packet: %v", template.Name, err)
+			return
+		} else if err = mod.Session.Queue.Send(data); err != nil {
+			mod.Error("error sending fuzzed packet: %s", err)
+			return
+		}
+
+		target := fuzzDestination(pkt)
+		fuzzed.OnSent(target, template.Name, data)
+
+		logFn := mod.Info
+		if mod.fuzzSilent {
+			logFn = mod.Debug
+		}
+		logFn("sent fuzzed %s packet to %s.", template.Name, target)
+		return
+	}
+}
+
+func (t *fuzzTargets) OnSent(address string, protocol string, frame []byte) {
+	t.Lock()
+	defer t.Unlock()
+
+	if len(t.frames) >= fuzzMaxSentFrames {
+		t.frames = make(map[uint64]bool)
+	}
+	t.frames[frameHash(frame)] = true
+
+	target, found := t.targets[address]
+	if !found {
+		target = &fuzzTarget{
+			Address:   address,
+			Protocols: make(map[string]bool),
+		}
+		t.targets[address] = target
+	}
+	target.Protocols[protocol] = true
+	target.Sent++
+	target.LastSent = time.Now()
+}
+
+// IsOwn returns true if frame is one of the fuzzed packets we sent, sniffed
+// back from the wire.
+func (t *fuzzTargets) IsOwn(frame []byte) bool {
+	t.Lock()
+	defer t.Unlock()
+
+	hash := frameHash(frame)
+	if t.frames[hash] {
+		delete(t.frames, hash)
+		return true
+	}
+	return false
+}
+
+// observeFuzzTargets looks for the packets sent by the fuzzed hosts, counting
+// malformed ones and server failures as anomalies, and reports the hosts
+// which stopped responding.
+func (mod *Sniffer) observeFuzzTargets(pkt gopacket.Packet) {
+	fuzzed.Lock()
+	defer fuzzed.Unlock()
+
+	now := time.Now()
+	src := ""
+	if arp, ok := pkt.Layer(layers.LayerTypeARP).(*layers.ARP); ok {
+		src = net.IP(arp.SourceProtAddress).String()
+	} else if nl := pkt.NetworkLayer(); nl != nil {
+		src = nl.NetworkFlow().Src().String()
+	}
+
+	if target, found := fuzzed.targets[src]; found {
+		target.Responses++
+		target.LastSeen = now
+		if target.Down {
+			target.Down = false
+			mod.Info("fuzzed target %s is responding again.", tui.Bold(src))
+		}
+
+		if err := pkt.ErrorLayer(); err != nil {
+			target.Anomalies++
+			mod.Warning("malformed packet from fuzzed target %s: %v", tui.Bold(src), err.Error())
+		} else if dns, ok := pkt.Layer(layers.LayerTypeDNS).(*layers.DNS); ok && dns.QR && dns.ResponseCode == layers.DNSResponseCodeServFail {
+			target.Anomalies++
+			mod.Warning("fuzzed target %s answered with a server failure.", tui.Bold(src))
+		}
+	}
+
+	for _, target := range fuzzed.targets {
+		if !target.Down && target.Responses > 0 && target.LastSeen.Before(target.LastSent) && now.Sub(target.LastSent) > fuzzTargetTimeout {
+			target.Down = true
+			target.Anomalies++
+			mod.Warning("fuzzed target %s stopped responding, it might have crashed.", tui.Bold(target.Address))
+		}
+	}
+}
+
+func (mod *Sniffer) ShowFuzzTargets() error {
+	fuzzed.Lock()
+	defer fuzzed.Unlock()
+
+	if len(fuzzed.targets) == 0 {
+		mod.Info("no targets fuzzed yet")
+		return nil
+	}
+
+	addresses := make([]string, 0, len(fuzzed.targets))
+	for address := range fuzzed.targets {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	colNames := []string{"Target", "Protocols", "Sent", "Responses", "Anomalies", "State", "Last Seen"}
+	rows := make([][]string, 0, len(addresses))
+	for _, address := range addresses {
+		target := fuzzed.targets[address]
+
+		protos := make([]string, 0)
+		for proto := range target.Protocols {
+			protos = append(protos, proto)
+		}
+		sort.Strings(protos)
+
+		state := tui.Green("up")
+		if target.Down {
+			state = tui.Red("down")
+		}
+		lastSeen := "never"
+		if !target.LastSeen.IsZero() {
+			lastSeen = target.LastSeen.Format("15:04:05")
+		}
+
+		anomalies := strconv.Itoa(target.Anomalies)
+		if target.Anomalies > 0 {
+			anomalies = tui.Red(anomalies)
+		}
+
+		rows = append(rows, []string{
+			tui.Bold(address),
+			strings.Join(protos, ", "),
+			strconv.Itoa(target.Sent),
+			strconv.Itoa(target.Responses),
+			anomalies,
+			state,
+			lastSeen,
+		})
+	}
+
+	tui.Table(mod.Session.Events.Stdout, colNames, rows)
+	mod.Session.Refresh()
+	return nil
+}
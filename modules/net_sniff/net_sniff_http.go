@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/bettercap/bettercap/session"
+
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 
@@ -120,13 +122,16 @@ func toSerializableResponse(res *http.Response) HTTPResponse {
 func httpParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
 	data := tcp.Payload
 	if req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(data))); err == nil {
+		sreq := toSerializableRequest(req)
+		server := tcpServer(dstIP, tcp)
 		if user, pass, ok := req.BasicAuth(); ok {
+			addCredential("http", session.CredentialPassword, srcIP.String(), server, user, pass)
 			NewSnifferEvent(
 				pkt.Metadata().Timestamp,
 				"http.request",
 				srcIP.String(),
 				req.Host,
-				toSerializableRequest(req),
+				sreq,
 				"%s %s %s %s%s - %s %s, %s %s",
 				tui.Wrap(tui.BACKRED+tui.FOREBLACK, "http"),
 				vIP(srcIP),
@@ -144,7 +149,7 @@ func httpParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *l
 				"http.request",
 				srcIP.String(),
 				req.Host,
-				toSerializableRequest(req),
+				sreq,
 				"%s %s %s %s%s",
 				tui.Wrap(tui.BACKRED+tui.FOREBLACK, "http"),
 				vIP(srcIP),
@@ -154,9 +159,20 @@ func httpParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *l
 			).Push()
 		}
 
+		if user, pass, found := formCredentials(sreq); found {
+			addCredential("http", session.CredentialPassword, srcIP.String(), server, user, pass)
+		}
+		if carver != nil {
+			carver.OnHTTPRequest(srcIP, dstIP, tcp, "http://"+req.Host+req.URL.RequestURI())
+		}
+
 		return true
 	} else if res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), nil); err == nil {
 		sres := toSerializableResponse(res)
+		if carver != nil {
+			carver.OnHTTPResponse(srcIP, dstIP, pkt, tcp, sres)
+		}
+
 		NewSnifferEvent(
 			pkt.Metadata().Timestamp,
 			"http.response",
@@ -0,0 +1,86 @@
+package net_sniff
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+func icsEvent(proto string, srcIP, dstIP net.IP, pkt gopacket.Packet, tcp *layers.TCP, data SniffData, format string, args ...interface{}) {
+	NewSnifferEvent(
+		pkt.Metadata().Timestamp,
+		proto,
+		srcIP.String(),
+		dstIP.String(),
+		data,
+		"%s %s:%s > %s:%s - %s",
+		tui.Wrap(tui.BACKRED+tui.FOREBLACK, proto),
+		vIP(srcIP),
+		vPort(tcp.SrcPort),
+		vIP(dstIP),
+		vPort(tcp.DstPort),
+		fmt.Sprintf(format, args...),
+	).Push()
+}
+
+func modbusParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+	request := tcp.DstPort == packets.ModbusPort
+	if !request && tcp.SrcPort != packets.ModbusPort {
+		return false
+	}
+
+	frames, err := packets.ParseModbus(tcp.Payload)
+	if err != nil || len(frames) == 0 {
+		return false
+	}
+
+	for _, frame := range frames {
+		desc := frame.Describe(request)
+		icsEvent("modbus", srcIP, dstIP, pkt, tcp, SniffData{
+			"transaction": frame.TransactionID,
+			"unit":        frame.UnitID,
+			"function":    frame.Function,
+			"request":     request,
+			"details":     desc,
+		}, "unit %d %s %s", frame.UnitID, tui.Bold(frame.FunctionName()), tui.Yellow(desc))
+	}
+
+	return true
+}
+
+func dnp3Parser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+	if tcp.DstPort != packets.DNP3Port && tcp.SrcPort != packets.DNP3Port {
+		return false
+	}
+
+	frame, err := packets.ParseDNP3(tcp.Payload)
+	if err != nil {
+		return false
+	}
+
+	from := "outstation"
+	if frame.FromMaster() {
+		from = "master"
+	}
+
+	desc := ""
+	if frame.IIN != 0 {
+		desc = tui.Dim(fmt.Sprintf("iin %04x", frame.IIN))
+	}
+
+	icsEvent("dnp3", srcIP, dstIP, pkt, tcp, SniffData{
+		"source":      frame.Source,
+		"destination": frame.Destination,
+		"from_master": frame.FromMaster(),
+		"function":    frame.FunctionName(),
+		"iin":         frame.IIN,
+	}, "%s %d > %d %s %s", from, frame.Source, frame.Destination, tui.Bold(frame.FunctionName()), desc)
+
+	return true
+}
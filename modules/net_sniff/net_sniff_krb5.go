@@ -2,9 +2,12 @@ package net_sniff
 
 import (
 	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
 	"net"
 
 	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
@@ -12,18 +15,19 @@ import (
 	"github.com/evilsocket/islazy/tui"
 )
 
-func krb5Parser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *layers.UDP) bool {
-	if udp.DstPort != 88 {
-		return false
-	}
+const krb5Port = 88
 
+func krb5Request(srcIP, dstIP net.IP, data []byte, pkt gopacket.Packet) bool {
 	var req packets.Krb5Request
-	_, err := asn1.UnmarshalWithParams(udp.Payload, &req, packets.Krb5AsReqParam)
+	_, err := asn1.UnmarshalWithParams(data, &req, packets.Krb5AsReqParam)
 	if err != nil {
 		return false
 	}
 
 	if s, err := req.String(); err == nil {
+		user := fmt.Sprintf("%s@%s", req.ReqBody.Cname.NameString[0], req.ReqBody.Realm)
+		addCredential("krb5", session.CredentialHash, srcIP.String(), fmt.Sprintf("%s:%d", dstIP, krb5Port), user, s)
+
 		NewSnifferEvent(
 			pkt.Metadata().Timestamp,
 			"krb5",
@@ -42,3 +46,65 @@ func krb5Parser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *l
 
 	return false
 }
+
+// krb5Reply exports the AS-REP of the users not requiring pre-authentication
+// and the TGS-REP with the service tickets in hashcat format.
+func krb5Reply(srcIP, dstIP net.IP, data []byte, pkt gopacket.Packet) bool {
+	rep, err := packets.ParseKrb5Reply(data)
+	if err != nil {
+		return false
+	}
+
+	label := "krb-as-rep"
+	if rep.MsgType == packets.Krb5TgsReplyType {
+		label = "krb-tgs-rep"
+	}
+
+	hash, err := rep.Hash()
+	if err != nil {
+		hash = tui.Dim(fmt.Sprintf("%s@%s (%v)", rep.User(), rep.Crealm, err))
+	} else {
+		user := fmt.Sprintf("%s@%s", rep.User(), rep.Crealm)
+		addCredential("krb5", session.CredentialHash, dstIP.String(), fmt.Sprintf("%s:%d", srcIP, krb5Port), user, hash)
+	}
+
+	NewSnifferEvent(
+		pkt.Metadata().Timestamp,
+		"krb5",
+		srcIP.String(),
+		dstIP.String(),
+		nil,
+		"%s %s -> %s : %s",
+		tui.Wrap(tui.BACKRED+tui.FOREBLACK, label),
+		vIP(srcIP),
+		vIP(dstIP),
+		hash,
+	).Push()
+
+	return true
+}
+
+func krb5Parser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *layers.UDP) bool {
+	if udp.DstPort == krb5Port {
+		return krb5Request(srcIP, dstIP, udp.Payload, pkt)
+	} else if udp.SrcPort == krb5Port {
+		return krb5Reply(srcIP, dstIP, udp.Payload, pkt)
+	}
+	return false
+}
+
+// over TCP every message is prefixed by its length, only the ones fitting
+// in a single segment are parsed.
+func krb5TCPParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+	data := tcp.Payload
+	if (tcp.DstPort != krb5Port && tcp.SrcPort != krb5Port) || len(data) <= 4 {
+		return false
+	} else if int(binary.BigEndian.Uint32(data)) != len(data)-4 {
+		return false
+	}
+
+	if tcp.DstPort == krb5Port {
+		return krb5Request(srcIP, dstIP, data[4:], pkt)
+	}
+	return krb5Reply(srcIP, dstIP, data[4:], pkt)
+}
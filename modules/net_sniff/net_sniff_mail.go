@@ -0,0 +1,86 @@
+package net_sniff
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+var (
+	imapLoginRe = regexp.MustCompile(`(?i)^\S+ LOGIN ("[^"]*"|\S+) ("[^"]*"|\S+)[\r\n]+$`)
+	authPlainRe = regexp.MustCompile(`(?i)^(\S+ )?AUTH(ENTICATE)? PLAIN ([A-Za-z0-9+/=]+)[\r\n]+$`)
+)
+
+func mailProtocol(port layers.TCPPort) string {
+	switch port {
+	case 143:
+		return "imap"
+	case 25, 465, 587:
+		return "smtp"
+	case 110:
+		return "pop3"
+	}
+	return "mail"
+}
+
+// decodes the "authzid\0user\0pass" SASL PLAIN message
+func decodePlain(encoded string) (string, string, bool) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+
+	parts := bytes.Split(raw, []byte{0})
+	if len(parts) != 3 {
+		return "", "", false
+	}
+	return string(parts[1]), string(parts[2]), true
+}
+
+func mailParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+	data := string(tcp.Payload)
+	user, pass, ok := "", "", false
+
+	if matches := imapLoginRe.FindStringSubmatch(data); matches != nil {
+		user = strings.Trim(matches[1], `"`)
+		pass = strings.Trim(matches[2], `"`)
+		ok = true
+	} else if matches := authPlainRe.FindStringSubmatch(data); matches != nil {
+		user, pass, ok = decodePlain(matches[3])
+	}
+
+	if !ok {
+		return false
+	}
+
+	proto := mailProtocol(tcp.DstPort)
+	addCredential(proto, session.CredentialPassword, srcIP.String(), tcpServer(dstIP, tcp), user, pass)
+
+	NewSnifferEvent(
+		pkt.Metadata().Timestamp,
+		proto,
+		srcIP.String(),
+		dstIP.String(),
+		nil,
+		"%s %s > %s:%s - %s %s, %s %s",
+		tui.Wrap(tui.BACKYELLOW+tui.FOREWHITE, proto),
+		vIP(srcIP),
+		vIP(dstIP),
+		vPort(tcp.DstPort),
+		tui.Bold("USER"),
+		tui.Yellow(user),
+		tui.Bold("PASS"),
+		tui.Yellow(pass),
+	).Push()
+
+	return true
+}
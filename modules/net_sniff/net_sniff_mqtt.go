@@ -0,0 +1,110 @@
+package net_sniff
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+const mqttMaxPayloadView = 64
+
+// protocol level of the connections, by client to broker flow
+var mqttLevels = sync.Map{}
+
+func mqttPayloadView(payload []byte) string {
+	view := strconv.Quote(string(payload))
+	if len(view) > mqttMaxPayloadView {
+		view = view[:mqttMaxPayloadView] + "..."
+	}
+	return view
+}
+
+func mqttEvent(srcIP, dstIP net.IP, pkt gopacket.Packet, tcp *layers.TCP, data SniffData, format string, args ...interface{}) {
+	NewSnifferEvent(
+		pkt.Metadata().Timestamp,
+		"mqtt",
+		srcIP.String(),
+		dstIP.String(),
+		data,
+		"%s %s > %s:%s - %s",
+		tui.Wrap(tui.BACKYELLOW+tui.FOREWHITE, "mqtt"),
+		vIP(srcIP),
+		vIP(dstIP),
+		vPort(tcp.DstPort),
+		fmt.Sprintf(format, args...),
+	).Push()
+}
+
+func mqttParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+	fromClient := tcp.DstPort == packets.MQTTPort
+	if !fromClient && tcp.SrcPort != packets.MQTTPort {
+		return false
+	}
+
+	key := flowKey(srcIP, dstIP, tcp)
+	if !fromClient {
+		key = reverseFlowKey(srcIP, dstIP, tcp)
+	}
+
+	level := byte(packets.MQTTProtocol311)
+	if stored, found := mqttLevels.Load(key); found {
+		level = stored.(byte)
+	}
+
+	found := false
+	for data := tcp.Payload; len(data) > 0; {
+		size, err := packets.MQTTPacketSize(data)
+		if err != nil || size > len(data) {
+			break
+		}
+		raw := data[:size]
+		data = data[size:]
+
+		switch raw[0] >> 4 {
+		case packets.MQTTConnect:
+			connect, err := packets.ParseMQTTConnect(raw)
+			if err != nil {
+				continue
+			}
+			found = true
+			level = connect.Level
+			mqttLevels.Store(key, level)
+
+			desc := fmt.Sprintf("%s %s", tui.Bold("connect"), tui.Yellow(connect.ClientID))
+			if connect.HasUsername() || connect.HasPassword() {
+				addCredential("mqtt", session.CredentialPassword, srcIP.String(), tcpServer(dstIP, tcp), connect.Username, connect.Password)
+				desc += fmt.Sprintf(" %s %s %s %s", tui.Bold("USER"), tui.Red(connect.Username), tui.Bold("PASS"), tui.Red(connect.Password))
+			}
+			mqttEvent(srcIP, dstIP, pkt, tcp, SniffData{"client_id": connect.ClientID, "username": connect.Username}, "%s", desc)
+
+		case packets.MQTTSubscribe:
+			if topics, err := packets.ParseMQTTSubscribe(raw, level); err == nil {
+				found = true
+				mqttEvent(srcIP, dstIP, pkt, tcp, SniffData{"topics": topics},
+					"%s %s", tui.Bold("subscribe"), tui.Yellow(strings.Join(topics, ", ")))
+			}
+
+		case packets.MQTTPublish:
+			if publish, err := packets.ParseMQTTPublish(raw, level); err == nil {
+				found = true
+				mqttEvent(srcIP, dstIP, pkt, tcp, SniffData{"topic": publish.Topic, "payload": string(publish.Payload)},
+					"%s %s %s", tui.Bold("publish"), tui.Yellow(publish.Topic), mqttPayloadView(publish.Payload))
+			}
+
+		case packets.MQTTDisconnect:
+			mqttLevels.Delete(key)
+		}
+	}
+
+	return found
+}
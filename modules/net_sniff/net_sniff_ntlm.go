@@ -1,11 +1,14 @@
 package net_sniff
 
 import (
+	"bytes"
 	"net"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
@@ -13,56 +16,117 @@ import (
 	"github.com/evilsocket/islazy/tui"
 )
 
+const (
+	// partial messages bigger than this are dropped
+	ntlmMaxPending = 64 * 1024
+	// tracked flows, all of them are forgotten when there are more
+	ntlmMaxFlows = 4096
+)
+
 var (
-	ntlmRe  = regexp.MustCompile("(WWW-|Proxy-|)(Authenticate|Authorization): (NTLM|Negotiate)")
-	challRe = regexp.MustCompile("(WWW-|Proxy-|)(Authenticate): (NTLM|Negotiate)")
-	respRe  = regexp.MustCompile("(WWW-|Proxy-|)(Authorization): (NTLM|Negotiate)")
-	ntlm    = packets.NewNTLMState()
+	ntlmRe = regexp.MustCompile("(?i)(WWW-|Proxy-|)(Authenticate|Authorization): (NTLM|Negotiate)")
+
+	ntlmLock = sync.Mutex{}
+	// chunks of NTLMSSP messages spanning multiple segments, by flow
+	ntlmPending = make(map[string][]byte)
+	// the last CHALLENGE sent by a server, by client to server flow
+	ntlmChallenges = make(map[string]packets.NTLMMessage)
 )
 
-func isNtlm(s string) bool {
-	return ntlmRe.FindString(s) != ""
+func isNtlm(data []byte) bool {
+	return bytes.Contains(data, []byte("NTLMSSP")) || ntlmRe.Match(data)
 }
 
-func isChallenge(s string) bool {
-	return challRe.FindString(s) != ""
-}
+// ntlmMessages returns the NTLMSSP messages of the flow, either carried
+// raw by SMB or encoded in HTTP headers, keeping any partial one for the
+// next segments unless the streams are already reassembled.
+func ntlmMessages(key string, payload []byte) []packets.NTLMMessage {
+	if tcpStreams != nil {
+		msgs, _ := packets.ExtractNTLMMessages(payload)
+		return msgs
+	}
+
+	pending, found := ntlmPending[key]
+	if !found && !isNtlm(payload) {
+		return nil
+	}
+
+	data := append(pending, payload...)
+	msgs, consumed := packets.ExtractNTLMMessages(data)
+
+	// the extractor always keeps a few bytes in case a token is split, we
+	// only care about real partial messages
+	if rest := data[consumed:]; len(rest) > len("Negotiate ") && len(rest) < ntlmMaxPending {
+		if len(ntlmPending) >= ntlmMaxFlows {
+			ntlmPending = make(map[string][]byte)
+		}
+		ntlmPending[key] = append([]byte(nil), rest...)
+	} else {
+		delete(ntlmPending, key)
+	}
 
-func isResponse(s string) bool {
-	return respRe.FindString(s) != ""
+	return msgs
 }
 
 func ntlmParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
-	data := tcp.Payload
-	ok := false
+	if len(tcp.Payload) == 0 {
+		return false
+	}
 
-	for _, line := range strings.Split(string(data), "\r\n") {
-		if isNtlm(line) {
-			tokens := strings.Split(line, " ")
-			if len(tokens) != 3 {
+	ntlmLock.Lock()
+	defer ntlmLock.Unlock()
+
+	key := flowKey(srcIP, dstIP, tcp)
+	msgs := ntlmMessages(key, tcp.Payload)
+	if len(msgs) == 0 {
+		return false
+	}
+
+	for _, msg := range msgs {
+		switch msg.Type {
+		case packets.NTLMChallengeMsg:
+			// the client answers on the opposite flow
+			if len(ntlmChallenges) >= ntlmMaxFlows {
+				ntlmChallenges = make(map[string]packets.NTLMMessage)
+			}
+			ntlmChallenges[reverseFlowKey(srcIP, dstIP, tcp)] = msg
+
+		case packets.NTLMAuthenticate:
+			challenge, found := ntlmChallenges[key]
+			if !found {
 				continue
 			}
-			if isChallenge(line) {
-				ok = true
-				ntlm.AddServerResponse(tcp.Ack, tokens[2])
-			} else if isResponse(line) {
-				ok = true
-				ntlm.AddClientResponse(tcp.Seq, tokens[2], func(data packets.NTLMChallengeResponseParsed) {
-					NewSnifferEvent(
-						pkt.Metadata().Timestamp,
-						"ntlm.response",
-						srcIP.String(),
-						dstIP.String(),
-						nil,
-						"%s %s > %s | %s",
-						tui.Wrap(tui.BACKDARKGRAY+tui.FOREWHITE, "ntlm.response"),
-						vIP(srcIP),
-						vIP(dstIP),
-						data.LcString(),
-					).Push()
-				})
+			delete(ntlmChallenges, key)
+
+			line, err := packets.NTLMHashcat(challenge, msg)
+			if err != nil {
+				continue
+			}
+
+			// user::domain:...
+			user := ""
+			if parts := strings.Split(line, ":"); len(parts) > 2 {
+				user = parts[0]
+				if parts[2] != "" {
+					user = parts[2] + "\\" + user
+				}
 			}
+			addCredential("ntlm", session.CredentialHash, srcIP.String(), tcpServer(dstIP, tcp), user, line)
+
+			NewSnifferEvent(
+				pkt.Metadata().Timestamp,
+				"ntlm.response",
+				srcIP.String(),
+				dstIP.String(),
+				nil,
+				"%s %s > %s | %s",
+				tui.Wrap(tui.BACKDARKGRAY+tui.FOREWHITE, "ntlm.response"),
+				vIP(srcIP),
+				vIP(dstIP),
+				line,
+			).Push()
 		}
 	}
-	return ok
+
+	return true
 }
@@ -0,0 +1,281 @@
+package net_sniff
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/bettercap/bettercap/core"
+
+	"github.com/acarl005/stripansi"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+const (
+	FormatPcap   = "pcap"
+	FormatPcapNG = "pcapng"
+	FormatJSONL  = "jsonl"
+
+	outputSnapLen = 65536
+)
+
+type packetWriter interface {
+	WritePacket(ci gopacket.CaptureInfo, data []byte) error
+}
+
+// countingWriter keeps track of how many bytes have been written to the file,
+// compressed ones if gzip is enabled.
+type countingWriter struct {
+	io.Writer
+	written int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.Writer.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// jsonEvent is a line of the jsonl output.
+type jsonEvent struct {
+	Time        time.Time   `json:"time"`
+	Protocol    string      `json:"protocol"`
+	Source      string      `json:"from"`
+	Destination string      `json:"to"`
+	Interface   string      `json:"interface,omitempty"`
+	Message     string      `json:"message"`
+	Data        interface{} `json:"data,omitempty"`
+}
+
+// SnifferOutput writes the captured packets to a pcap or pcapng file, or
+// the parsed events as JSON lines to a file or FIFO, optionally gzip
+// compressed, moving it away to a timestamped one and starting a new file
+// every MaxSize bytes or MaxAge.
+type SnifferOutput struct {
+	Path     string
+	Format   string
+	Compress bool
+	MaxSize  int64
+	MaxAge   time.Duration
+
+	linkType  layers.LinkType
+	iface     string
+	filter    string
+	hostInfo  string
+	file      *os.File
+	counter   *countingWriter
+	gz        *gzip.Writer
+	ng        *pcapgo.NgWriter
+	writer    packetWriter
+	encoder   *json.Encoder
+	pipe      bool
+	opened    time.Time
+	rotations int
+}
+
+func NewSnifferOutput(path string, format string, compress bool, linkType layers.LinkType) (*SnifferOutput, error) {
+	if format != FormatPcap && format != FormatPcapNG && format != FormatJSONL {
+		return nil, fmt.Errorf("unknown output format '%s', use %s, %s or %s", format, FormatPcap, FormatPcapNG, FormatJSONL)
+	} else if compress && !strings.HasSuffix(path, ".gz") {
+		path += ".gz"
+	}
+
+	return &SnifferOutput{
+		Path:     path,
+		Format:   format,
+		Compress: compress,
+		linkType: linkType,
+	}, nil
+}
+
+// SetInterface sets the metadata saved in the pcapng interface and section
+// blocks, it must be called before Open.
+func (o *SnifferOutput) SetInterface(name string, filter string, host string) {
+	o.iface = name
+	o.filter = filter
+	o.hostInfo = host
+}
+
+func (o *SnifferOutput) Open() (err error) {
+	// FIFOs are opened read-write not to block until a reader shows up, and
+	// never rotated
+	if info, err := os.Stat(o.Path); err == nil && info.Mode()&os.ModeNamedPipe != 0 {
+		o.pipe = true
+		if o.file, err = os.OpenFile(o.Path, os.O_RDWR, 0); err != nil {
+			return err
+		}
+	} else if o.file, err = os.Create(o.Path); err != nil {
+		return err
+	}
+
+	o.opened = time.Now()
+	o.counter = &countingWriter{Writer: o.file}
+
+	var out io.Writer = o.counter
+	if o.Compress {
+		o.gz = gzip.NewWriter(o.counter)
+		out = o.gz
+	}
+
+	if o.Format == FormatJSONL {
+		o.encoder = json.NewEncoder(out)
+	} else if o.Format == FormatPcapNG {
+		intf := pcapgo.NgInterface{
+			Name:                o.iface,
+			Description:         o.hostInfo,
+			Filter:              o.filter,
+			OS:                  runtime.GOOS,
+			LinkType:            o.linkType,
+			SnapLength:          outputSnapLen,
+			TimestampResolution: 9,
+		}
+		options := pcapgo.NgWriterOptions{
+			SectionInfo: pcapgo.NgSectionInfo{
+				Hardware:    runtime.GOARCH,
+				OS:          runtime.GOOS,
+				Application: fmt.Sprintf("%s v%s", core.Name, core.Version),
+			},
+		}
+		if o.ng, err = pcapgo.NewNgWriterInterface(out, intf, options); err != nil {
+			o.file.Close()
+			return err
+		}
+		o.writer = o.ng
+	} else {
+		w := pcapgo.NewWriter(out)
+		if err = w.WriteFileHeader(outputSnapLen, o.linkType); err != nil {
+			o.file.Close()
+			return err
+		}
+		o.writer = w
+	}
+
+	return nil
+}
+
+func (o *SnifferOutput) WritePacket(ci gopacket.CaptureInfo, data []byte) error {
+	if o.writer == nil {
+		return nil
+	}
+
+	// pcapng writers only know about the interface with index 0
+	ci.InterfaceIndex = 0
+	if err := o.writer.WritePacket(ci, data); err != nil {
+		return err
+	}
+
+	return o.checkRotation()
+}
+
+// WriteEvent writes a parsed event as a JSON line.
+func (o *SnifferOutput) WriteEvent(e SnifferEvent) error {
+	if o.encoder == nil {
+		return nil
+	}
+
+	err := o.encoder.Encode(jsonEvent{
+		Time:        e.PacketTime,
+		Protocol:    e.Protocol,
+		Source:      e.Source,
+		Destination: e.Destination,
+		Interface:   e.Interface,
+		Message:     stripansi.Strip(e.Message),
+		Data:        e.Data,
+	})
+	if err != nil {
+		return err
+	} else if o.pipe && o.gz != nil {
+		// readers on the other side want the events as they come
+		return o.gz.Flush()
+	}
+
+	return o.checkRotation()
+}
+
+func (o *SnifferOutput) checkRotation() error {
+	if o.pipe {
+		return nil
+	} else if (o.MaxSize > 0 && o.size() >= o.MaxSize) || (o.MaxAge > 0 && time.Since(o.opened) >= o.MaxAge) {
+		return o.rotate()
+	}
+	return nil
+}
+
+// size returns the size of the file so far, since the data is buffered by
+// the writers it's only an approximation.
+func (o *SnifferOutput) size() int64 {
+	return o.counter.written
+}
+
+func (o *SnifferOutput) rotatedPath() string {
+	ext := filepath.Ext(strings.TrimSuffix(o.Path, ".gz"))
+	base := strings.TrimSuffix(strings.TrimSuffix(o.Path, ".gz"), ext)
+	if o.Compress {
+		ext += ".gz"
+	}
+	return fmt.Sprintf("%s-%s%s", base, o.opened.Format("2006-01-02T15-04-05.000"), ext)
+}
+
+func (o *SnifferOutput) rotate() error {
+	if err := o.Close(); err != nil {
+		return err
+	} else if err = os.Rename(o.Path, o.rotatedPath()); err != nil {
+		return err
+	}
+	o.rotations++
+	return o.Open()
+}
+
+func (o *SnifferOutput) Close() (err error) {
+	if o.file == nil {
+		return nil
+	}
+
+	if o.ng != nil {
+		err = o.ng.Flush()
+		o.ng = nil
+	}
+	if o.gz != nil {
+		if gzErr := o.gz.Close(); err == nil {
+			err = gzErr
+		}
+		o.gz = nil
+	}
+	if closeErr := o.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	o.file = nil
+	o.writer = nil
+	o.encoder = nil
+	return
+}
+
+func (o *SnifferOutput) String() string {
+	desc := o.Format
+	if o.Compress {
+		desc += ", gzip"
+	}
+	if o.MaxSize > 0 {
+		desc += fmt.Sprintf(", rotated every %d MB", o.MaxSize/(1024*1024))
+	}
+	if o.MaxAge > 0 {
+		desc += fmt.Sprintf(", rotated every %s", o.MaxAge)
+	}
+	if o.pipe {
+		desc += ", fifo"
+	}
+	if o.rotations > 0 {
+		desc += fmt.Sprintf(", %d rotations", o.rotations)
+	}
+	return fmt.Sprintf("%s (%s)", o.Path, desc)
+}
@@ -0,0 +1,49 @@
+package net_sniff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type filterPreset struct {
+	Name   string
+	Filter string
+}
+
+// BPF expressions for the traffic the parsers are interested in
+var filterPresets = []filterPreset{
+	{"creds", "tcp port 21 or tcp port 23 or tcp port 25 or tcp port 80 or port 88 or tcp port 110 or tcp port 143 or " +
+		"udp port 161 or tcp port 389 or tcp port 445 or tcp port 587 or tcp port 1883 or tcp port 8080"},
+	{"dns", "port 53 or tcp port 853 or udp port 5353"},
+	{"voip", "port 5060 or tcp port 5061 or udp portrange 10000-20000 or udp portrange 16384-32767"},
+	{"smb", "tcp port 139 or tcp port 445 or udp port 137 or udp port 138"},
+	{"cleartext", "tcp port 21 or tcp port 23 or tcp port 25 or udp port 69 or tcp port 80 or tcp port 110 or tcp port 143 or " +
+		"udp port 161 or tcp port 389 or tcp port 1883 or port 5060 or tcp port 8080"},
+}
+
+func filterPresetNames() []string {
+	names := make([]string, 0, len(filterPresets))
+	for _, preset := range filterPresets {
+		names = append(names, preset.Name)
+	}
+	return names
+}
+
+// withFilterPreset returns the BPF expression of the named preset, and of
+// filter too if set.
+func withFilterPreset(name string, filter string) (string, error) {
+	if name == "" {
+		return filter, nil
+	}
+
+	for _, preset := range filterPresets {
+		if preset.Name == name {
+			if filter == "" {
+				return preset.Filter, nil
+			}
+			return fmt.Sprintf("(%s) and (%s)", preset.Filter, filter), nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown filter preset '%s', use one of %s", name, strings.Join(filterPresetNames(), ", "))
+}
@@ -0,0 +1,86 @@
+package net_sniff
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+func rdpEvent(srcIP, dstIP net.IP, pkt gopacket.Packet, tcp *layers.TCP, data SniffData, format string, args ...interface{}) {
+	NewSnifferEvent(
+		pkt.Metadata().Timestamp,
+		"rdp",
+		srcIP.String(),
+		dstIP.String(),
+		data,
+		"%s %s > %s:%s - %s",
+		tui.Wrap(tui.BACKRED+tui.FOREWHITE, "rdp"),
+		vIP(srcIP),
+		vIP(dstIP),
+		vPort(tcp.DstPort),
+		fmt.Sprintf(format, args...),
+	).Push()
+}
+
+func rdpParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+	data := tcp.Payload
+	if len(data) == 0 {
+		return false
+	}
+
+	if tcp.DstPort == packets.RDPPort {
+		if req, err := packets.ParseRDPConnectionRequest(data); err == nil {
+			protos := strings.Join(packets.RDPProtocolNames(req.Protocols), ", ")
+			desc := fmt.Sprintf("%s %s", tui.Bold("connect"), tui.Yellow(protos))
+			if req.Cookie != "" {
+				desc += fmt.Sprintf(" %s %s", tui.Bold("USER"), tui.Red(req.Cookie))
+			}
+			rdpEvent(srcIP, dstIP, pkt, tcp, SniffData{"user": req.Cookie, "protocols": protos}, "%s", desc)
+			return true
+		} else if name, found := packets.RDPClientName(data); found {
+			rdpEvent(srcIP, dstIP, pkt, tcp, SniffData{"client": name}, "%s %s", tui.Bold("client"), tui.Yellow(name))
+			return true
+		}
+	} else if tcp.SrcPort == packets.RDPPort {
+		if confirm, err := packets.ParseRDPConnectionConfirm(data); err == nil {
+			nla := tui.Green("nla required")
+			if !confirm.NLA() {
+				nla = tui.Red("no nla")
+			}
+			rdpEvent(srcIP, dstIP, pkt, tcp, SniffData{"security": confirm.String(), "nla": confirm.NLA()},
+				"%s %s, %s", tui.Bold("security"), tui.Yellow(confirm.String()), nla)
+			return true
+		} else if cert, found := packets.TLSServerCertificate(data); found {
+			selfSigned := cert.Issuer.String() == cert.Subject.String()
+			fingerprint := fmt.Sprintf("%x", sha1.Sum(cert.Raw))
+			desc := fmt.Sprintf("%s %s, expires %s, sha1 %s",
+				tui.Bold("certificate"),
+				tui.Yellow(cert.Subject.CommonName),
+				cert.NotAfter.Format("2006-01-02"),
+				tui.Dim(fingerprint))
+			if selfSigned {
+				desc += " " + tui.Red("(self signed)")
+			} else {
+				desc += fmt.Sprintf(" issued by %s", tui.Yellow(cert.Issuer.CommonName))
+			}
+			rdpEvent(srcIP, dstIP, pkt, tcp, SniffData{
+				"subject":     cert.Subject.String(),
+				"issuer":      cert.Issuer.String(),
+				"not_after":   cert.NotAfter,
+				"self_signed": selfSigned,
+				"sha1":        fingerprint,
+			}, "%s", desc)
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,182 @@
+package net_sniff
+
+import (
+	"bytes"
+	"net"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/tcpassembly"
+)
+
+const (
+	// data of a stream no parser recognized is dropped when bigger than this
+	tcpMaxBuffer = 16 * 1024
+	// streams that aren't HTTP or SMB and that no parser recognized within
+	// this many bytes are not parsed anymore
+	tcpGiveUpSize = 4 * 1024
+	// streams without new segments for this long are forgotten
+	tcpStreamTimeout = 2 * time.Minute
+)
+
+var (
+	httpStartRe         = regexp.MustCompile(`^(GET|POST|PUT|HEAD|DELETE|OPTIONS|PATCH|CONNECT|TRACE) |^HTTP/1\.[01] `)
+	httpContentLengthRe = regexp.MustCompile(`(?i)\r\ncontent-length:\s*(\d+)`)
+	httpChunkedRe       = regexp.MustCompile(`(?i)\r\ntransfer-encoding:\s*chunked`)
+
+	// the TCP reassembler if enabled, set by the sniffer context
+	tcpStreams *tcpReassembler
+	// how much of an HTTP or SMB message to wait for, raised when carving
+	tcpMaxMessage = tcpMaxBuffer
+)
+
+// httpIncomplete returns true if data is the beginning of an HTTP request or
+// response whose headers or body are still to come.
+func httpIncomplete(data []byte) bool {
+	if !httpStartRe.Match(data) {
+		return false
+	}
+
+	end := bytes.Index(data, []byte("\r\n\r\n"))
+	if end == -1 {
+		return true
+	}
+	headers, body := data[:end+2], data[end+4:]
+
+	if m := httpContentLengthRe.FindSubmatch(headers); m != nil {
+		size, err := strconv.Atoi(string(m[1]))
+		return err == nil && len(body) < size
+	} else if httpChunkedRe.Match(headers) {
+		return !bytes.HasSuffix(body, []byte("0\r\n\r\n"))
+	}
+	return false
+}
+
+// tcpMessage returns true if data is the beginning of an HTTP or SMB message,
+// whose end is known.
+func tcpMessage(data []byte, tcp *layers.TCP) bool {
+	return isSMBPort(tcp.SrcPort) || isSMBPort(tcp.DstPort) || httpStartRe.Match(data)
+}
+
+// tcpIncomplete returns true if data ends with a truncated HTTP or SMB
+// message.
+func tcpIncomplete(data []byte, tcp *layers.TCP) bool {
+	if isSMBPort(tcp.SrcPort) || isSMBPort(tcp.DstPort) {
+		return packets.SMBIncomplete(data)
+	}
+	return httpIncomplete(data)
+}
+
+// tcpReassembler reorders the segments of every TCP connection, the
+// parsers are given the data of each direction as it accumulates until one
+// of them recognizes it.
+type tcpReassembler struct {
+	sync.Mutex
+	assembler *tcpassembly.Assembler
+	lastFlush time.Time
+
+	// the packet being assembled, streams are only parsed when set
+	srcIP   net.IP
+	dstIP   net.IP
+	payload []byte
+	pkt     gopacket.Packet
+	tcp     *layers.TCP
+	parsed  bool
+}
+
+// tcpStream is a direction of a TCP connection.
+type tcpStream struct {
+	r       *tcpReassembler
+	buffer  []byte
+	ignored bool
+}
+
+func newTCPReassembler() *tcpReassembler {
+	r := &tcpReassembler{
+		lastFlush: time.Now(),
+	}
+	r.assembler = tcpassembly.NewAssembler(tcpassembly.NewStreamPool(r))
+	r.assembler.MaxBufferedPagesPerConnection = 64
+	r.assembler.MaxBufferedPagesTotal = 16384
+	return r
+}
+
+func (r *tcpReassembler) New(netFlow, tcpFlow gopacket.Flow) tcpassembly.Stream {
+	return &tcpStream{r: r}
+}
+
+// Assemble feeds a TCP packet to its stream, returning true if any parser
+// recognized the data reassembled so far.
+func (r *tcpReassembler) Assemble(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	r.srcIP, r.dstIP, r.payload, r.pkt, r.tcp, r.parsed = srcIP, dstIP, payload, pkt, tcp, false
+	r.assembler.AssembleWithTimestamp(pkt.NetworkLayer().NetworkFlow(), tcp, pkt.Metadata().Timestamp)
+	parsed := r.parsed
+	r.pkt, r.tcp = nil, nil
+
+	if now := time.Now(); now.Sub(r.lastFlush) >= tcpStreamTimeout {
+		r.assembler.FlushOlderThan(now.Add(-tcpStreamTimeout))
+		r.lastFlush = now
+	}
+
+	return parsed
+}
+
+func (r *tcpReassembler) Close() {
+	r.Lock()
+	defer r.Unlock()
+	r.assembler.FlushAll()
+}
+
+func (s *tcpStream) Reassembled(chunks []tcpassembly.Reassembly) {
+	if s.ignored {
+		return
+	}
+
+	for _, chunk := range chunks {
+		if chunk.Skip != 0 {
+			// missing data, whatever was before is useless now
+			s.buffer = nil
+		}
+		s.buffer = append(s.buffer, chunk.Bytes...)
+	}
+
+	// flushed because of a timeout or by the sniffer closing
+	if s.r.pkt == nil || len(s.buffer) == 0 {
+		return
+	} else if tcpIncomplete(s.buffer, s.r.tcp) && len(s.buffer) < tcpMaxMessage {
+		return
+	}
+
+	segment := *s.r.tcp
+	segment.Payload = s.buffer
+	for _, parser := range tcpParsers {
+		parser := parser
+		if withBudget(parser, func() bool { return parser(s.r.srcIP, s.r.dstIP, s.r.payload, s.r.pkt, &segment) }) {
+			s.r.parsed = true
+			s.buffer = nil
+			return
+		}
+	}
+
+	if tcpMessage(s.buffer, s.r.tcp) {
+		// a whole message nobody is interested in, wait for the next one
+		s.buffer = nil
+	} else if len(s.buffer) >= tcpGiveUpSize {
+		// rerunning the parsers on an ever growing buffer is quadratic
+		s.ignored = true
+		s.buffer = nil
+	}
+}
+
+func (s *tcpStream) ReassemblyComplete() {
+	s.buffer = nil
+}
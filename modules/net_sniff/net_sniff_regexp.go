@@ -0,0 +1,139 @@
+package net_sniff
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/google/gopacket"
+
+	"github.com/evilsocket/islazy/fs"
+	"github.com/evilsocket/islazy/tui"
+)
+
+// regexpRule is a named expression the payloads are searched for.
+type regexpRule struct {
+	Name       string
+	Expression *regexp.Regexp
+}
+
+// loadRegexpRules reads a rule per line from filename, as the name of the
+// rule followed by its expression, skipping empty lines and # comments.
+func loadRegexpRules(filename string) ([]regexpRule, error) {
+	filename, err := fs.Expand(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	rules := make([]regexpRule, 0)
+	scanner := bufio.NewScanner(fp)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		sep := strings.IndexAny(line, " \t")
+		if sep == -1 {
+			return nil, fmt.Errorf("%s:%d: expected a name and an expression", filename, lineno)
+		}
+
+		expr, err := regexp.Compile(strings.TrimSpace(line[sep:]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, lineno, err)
+		}
+		rules = append(rules, regexpRule{
+			Name:       line[:sep],
+			Expression: expr,
+		})
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	} else if len(rules) == 0 {
+		return nil, fmt.Errorf("no rules found in %s", filename)
+	}
+	return rules, nil
+}
+
+// window returns the bytes around data[start:end], up to size on each side.
+func window(data []byte, start int, end int, size int) []byte {
+	if size < 0 {
+		size = 0
+	}
+	if start -= size; start < 0 {
+		start = 0
+	}
+	if end += size; end > len(data) {
+		end = len(data)
+	}
+	return data[start:end]
+}
+
+func (mod *Sniffer) onRegexpRules(pkt gopacket.Packet) {
+	var payload []byte
+	if app := pkt.ApplicationLayer(); app != nil {
+		payload = app.Payload()
+	} else {
+		payload = pkt.Data()
+	}
+
+	src, dst := "", ""
+	if nlayer := pkt.NetworkLayer(); nlayer != nil {
+		src, dst = nlayer.NetworkFlow().Src().String(), nlayer.NetworkFlow().Dst().String()
+	}
+	if tlayer := pkt.TransportLayer(); tlayer != nil && src != "" {
+		src = fmt.Sprintf("%s:%s", src, tlayer.TransportFlow().Src())
+		dst = fmt.Sprintf("%s:%s", dst, tlayer.TransportFlow().Dst())
+	}
+
+	for _, rule := range mod.Ctx.Rules {
+		names := rule.Expression.SubexpNames()
+		for _, match := range rule.Expression.FindAllSubmatchIndex(payload, -1) {
+			groups := make([]string, 0)
+			named := make(map[string]string)
+			for i := 1; i < len(names); i++ {
+				value := ""
+				if match[2*i] >= 0 {
+					value = string(payload[match[2*i]:match[2*i+1]])
+				}
+				groups = append(groups, value)
+				if names[i] != "" {
+					named[names[i]] = value
+				}
+			}
+
+			found := payload[match[0]:match[1]]
+			context := window(payload, match[0], match[1], mod.Ctx.RegexpWindow)
+
+			NewSnifferEvent(
+				pkt.Metadata().Timestamp,
+				"regexp",
+				src,
+				dst,
+				SniffData{
+					"rule":    rule.Name,
+					"match":   string(found),
+					"offset":  match[0],
+					"groups":  groups,
+					"named":   named,
+					"context": string(context),
+				},
+				"%s %s > %s : %s %s",
+				tui.Wrap(tui.BACKRED+tui.FOREWHITE, "regexp"),
+				src,
+				dst,
+				tui.Bold(rule.Name),
+				tui.Dim(fmt.Sprintf("%q", context)),
+			).Push()
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package net_sniff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/packets"
+)
+
+const (
+	wavHeaderSize = 44
+	wavMuLaw      = 7
+	wavALaw       = 6
+	// calls with no packets for this long are forgotten
+	voipCallTimeout = time.Hour
+	// streams with no packets for this long are closed and forgotten
+	voipStreamTimeout = time.Minute
+	// at most this many calls and streams per call are tracked
+	voipMaxCalls   = 1024
+	voipMaxStreams = 64
+	// a stream resuming more often than this is not recorded anymore
+	rtpMaxParts = 100
+)
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9\-_.]+`)
+
+// rtpRecorder saves the payload of an RTP stream, G.711 ones in a WAV file
+// that can be played as it is.
+type rtpRecorder struct {
+	Path   string
+	file   *os.File
+	format uint16
+	size   int
+}
+
+func newRTPRecorder(path string, pt uint8) (*rtpRecorder, error) {
+	r := &rtpRecorder{}
+	switch pt {
+	case packets.RTPPayloadPCMU:
+		r.format = wavMuLaw
+	case packets.RTPPayloadPCMA:
+		r.format = wavALaw
+	}
+
+	ext := ".raw"
+	if r.format != 0 {
+		ext = ".wav"
+	}
+
+	// a stream that resumes after being closed gets a new file
+	var err error
+	for i := 1; r.file == nil; i++ {
+		if r.Path = path + ext; i > 1 {
+			r.Path = fmt.Sprintf("%s-%d%s", path, i, ext)
+		}
+		if r.file, err = os.OpenFile(r.Path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644); err != nil && (!os.IsExist(err) || i >= rtpMaxParts) {
+			return nil, err
+		}
+	}
+
+	if r.format != 0 {
+		// the sizes are updated once the stream is over
+		_, err = r.file.Write(r.header())
+	}
+	return r, err
+}
+
+func (r *rtpRecorder) header() []byte {
+	h := make([]byte, wavHeaderSize)
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], uint32(wavHeaderSize-8+r.size))
+	copy(h[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], r.format)
+	// mono, 8000Hz, 8 bits per sample
+	binary.LittleEndian.PutUint16(h[22:], 1)
+	binary.LittleEndian.PutUint32(h[24:], 8000)
+	binary.LittleEndian.PutUint32(h[28:], 8000)
+	binary.LittleEndian.PutUint16(h[32:], 1)
+	binary.LittleEndian.PutUint16(h[34:], 8)
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], uint32(r.size))
+	return h
+}
+
+func (r *rtpRecorder) Write(payload []byte) error {
+	n, err := r.file.Write(payload)
+	r.size += n
+	return err
+}
+
+func (r *rtpRecorder) Close() error {
+	if r.format != 0 {
+		if _, err := r.file.WriteAt(r.header(), 0); err != nil {
+			r.file.Close()
+			return err
+		}
+	}
+	return r.file.Close()
+}
+
+type rtpStream struct {
+	SSRC        uint32
+	PayloadType uint8
+	Packets     int
+	Bytes       int
+	LastSeen    time.Time
+	recorder    *rtpRecorder
+}
+
+type sipCall struct {
+	ID        string
+	From      string
+	To        string
+	Started   time.Time
+	Answered  time.Time
+	LastSeen  time.Time
+	endpoints []string
+	streams   map[uint32]*rtpStream
+}
+
+func (c *sipCall) Packets() int {
+	total := 0
+	for _, stream := range c.streams {
+		total += stream.Packets
+	}
+	return total
+}
+
+// voipTracker keeps track of the calls set up with SIP and of the RTP
+// streams negotiated in their SDP bodies.
+type voipTracker struct {
+	sync.Mutex
+	recordDir string
+	calls     map[string]*sipCall
+	endpoints map[string]*sipCall
+	lastSweep time.Time
+}
+
+var voip = newVoipTracker()
+
+func newVoipTracker() *voipTracker {
+	return &voipTracker{
+		calls:     make(map[string]*sipCall),
+		endpoints: make(map[string]*sipCall),
+	}
+}
+
+// Reset forgets every call, recording the RTP streams in recordDir from
+// now on if it's not empty.
+func (t *voipTracker) Reset(recordDir string) error {
+	t.Close()
+
+	t.Lock()
+	defer t.Unlock()
+
+	if recordDir != "" {
+		if err := os.MkdirAll(recordDir, 0755); err != nil {
+			return err
+		}
+	}
+
+	t.recordDir = recordDir
+	t.calls = make(map[string]*sipCall)
+	t.endpoints = make(map[string]*sipCall)
+	return nil
+}
+
+// Close stops the recordings.
+func (t *voipTracker) Close() {
+	t.Lock()
+	defer t.Unlock()
+	for _, call := range t.calls {
+		t.stopRecording(call)
+	}
+}
+
+func (t *voipTracker) stopRecording(call *sipCall) {
+	for _, stream := range call.streams {
+		if stream.recorder != nil {
+			stream.recorder.Close()
+			stream.recorder = nil
+		}
+	}
+}
+
+// expire closes and forgets the streams that have been idle for too long
+// and the calls that have been idle for even longer.
+func (t *voipTracker) expire(now time.Time) {
+	t.lastSweep = now
+	for _, call := range t.calls {
+		if now.Sub(call.LastSeen) > voipCallTimeout {
+			t.forget(call)
+			continue
+		}
+		for ssrc, stream := range call.streams {
+			if now.Sub(stream.LastSeen) > voipStreamTimeout {
+				if stream.recorder != nil {
+					stream.recorder.Close()
+				}
+				delete(call.streams, ssrc)
+			}
+		}
+	}
+}
+
+func (t *voipTracker) forget(call *sipCall) {
+	t.stopRecording(call)
+	for _, endpoint := range call.endpoints {
+		delete(t.endpoints, endpoint)
+	}
+	delete(t.calls, call.ID)
+}
+
+// Call returns the call with the given identifier, creating it if needed.
+func (t *voipTracker) Call(id string, from string, to string, when time.Time) *sipCall {
+	t.Lock()
+	defer t.Unlock()
+
+	if call, found := t.calls[id]; found {
+		call.LastSeen = when
+		return call
+	}
+
+	t.expire(when)
+	if len(t.calls) >= voipMaxCalls {
+		var oldest *sipCall
+		for _, call := range t.calls {
+			if oldest == nil || call.LastSeen.Before(oldest.LastSeen) {
+				oldest = call
+			}
+		}
+		t.forget(oldest)
+	}
+
+	call := &sipCall{
+		ID:        id,
+		From:      from,
+		To:        to,
+		Started:   when,
+		LastSeen:  when,
+		endpoints: make([]string, 0),
+		streams:   make(map[uint32]*rtpStream),
+	}
+	t.calls[id] = call
+	return call
+}
+
+// AddMedia adds the RTP endpoints announced in an SDP body to a call.
+func (t *voipTracker) AddMedia(call *sipCall, media []packets.SDPMedia) {
+	t.Lock()
+	defer t.Unlock()
+
+	for _, m := range media {
+		if m.IP == nil || m.Port == 0 {
+			continue
+		}
+		endpoint := fmt.Sprintf("%s:%d", m.IP, m.Port)
+		if _, found := t.endpoints[endpoint]; !found && len(call.endpoints) < voipMaxStreams {
+			t.endpoints[endpoint] = call
+			call.endpoints = append(call.endpoints, endpoint)
+		}
+	}
+}
+
+// End removes a call, returning it if it was known.
+func (t *voipTracker) End(id string) *sipCall {
+	t.Lock()
+	defer t.Unlock()
+
+	call, found := t.calls[id]
+	if found {
+		t.forget(call)
+	}
+	return call
+}
+
+// OnRTP accounts an RTP packet sent from src to dst, returning its call,
+// its stream and true if the stream just started.
+func (t *voipTracker) OnRTP(src string, dst string, pkt *packets.RTPPacket, when time.Time) (*sipCall, *rtpStream, bool, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	call, found := t.endpoints[dst]
+	if !found {
+		if call, found = t.endpoints[src]; !found {
+			return nil, nil, false, nil
+		}
+	}
+
+	call.LastSeen = when
+	if when.Sub(t.lastSweep) > voipStreamTimeout {
+		t.expire(when)
+	}
+
+	stream, found := call.streams[pkt.SSRC]
+	if !found {
+		if len(call.streams) >= voipMaxStreams {
+			return call, nil, false, nil
+		}
+		stream = &rtpStream{SSRC: pkt.SSRC, PayloadType: pkt.PayloadType}
+		call.streams[pkt.SSRC] = stream
+	}
+
+	stream.LastSeen = when
+	stream.Packets++
+	stream.Bytes += len(pkt.Payload)
+
+	var err error
+	if t.recordDir != "" {
+		if stream.recorder == nil && !found {
+			name := fmt.Sprintf("%s-%08x", unsafeChars.ReplaceAllString(call.ID, "_"), pkt.SSRC)
+			stream.recorder, err = newRTPRecorder(filepath.Join(t.recordDir, name), pkt.PayloadType)
+		}
+		if stream.recorder != nil {
+			err = stream.recorder.Write(pkt.Payload)
+		}
+	}
+
+	return call, stream, !found, err
+}
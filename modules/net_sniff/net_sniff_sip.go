@@ -0,0 +1,137 @@
+package net_sniff
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/bettercap/bettercap/log"
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+func sipEvent(when time.Time, what string, srcIP, dstIP net.IP, data SniffData, format string, args ...interface{}) {
+	NewSnifferEvent(
+		when,
+		what,
+		srcIP.String(),
+		dstIP.String(),
+		data,
+		"%s %s > %s - %s",
+		tui.Wrap(tui.BACKGREEN+tui.FOREBLACK, what),
+		vIP(srcIP),
+		vIP(dstIP),
+		fmt.Sprintf(format, args...),
+	).Push()
+}
+
+func onSIP(srcIP, dstIP net.IP, srcPort, dstPort int, data []byte, pkt gopacket.Packet) bool {
+	msg, err := packets.ParseSIP(data)
+	if err != nil {
+		return false
+	}
+
+	when := pkt.Metadata().Timestamp
+	callID := msg.CallID()
+	sdp := packets.ParseSDP(msg.Body)
+
+	if !msg.IsRequest() {
+		desc := fmt.Sprintf("%s %s %s", tui.Bold(fmt.Sprintf("%d", msg.StatusCode)), msg.Reason, tui.Dim(msg.CSeqMethod()))
+		if msg.CSeqMethod() == "INVITE" && msg.StatusCode == 200 {
+			call := voip.Call(callID, msg.From(), msg.To(), when)
+			call.Answered = when
+			voip.AddMedia(call, sdp)
+			desc += fmt.Sprintf(", call %s answered", tui.Yellow(call.To))
+		}
+		sipEvent(when, "sip", srcIP, dstIP, SniffData{"call_id": callID, "status": msg.StatusCode}, "%s", desc)
+		return true
+	}
+
+	desc := fmt.Sprintf("%s %s > %s", tui.Bold(msg.Method), tui.Yellow(msg.From()), tui.Yellow(msg.To()))
+
+	if digest, found := msg.Digest(); found {
+		hash := digest.Hashcat(msg.Method, dstIP.String(), srcIP.String())
+		server := net.JoinHostPort(dstIP.String(), fmt.Sprintf("%d", dstPort))
+		user := digest.Username
+		if digest.Realm != "" {
+			user += "@" + digest.Realm
+		}
+		addCredential("sip", session.CredentialHash, srcIP.String(), server, user, hash)
+		desc += fmt.Sprintf(" %s %s", tui.Bold("USER"), tui.Red(user))
+	}
+
+	switch msg.Method {
+	case "INVITE":
+		call := voip.Call(callID, msg.From(), msg.To(), when)
+		voip.AddMedia(call, sdp)
+		if len(sdp) > 0 {
+			desc += fmt.Sprintf(" (%s:%d)", sdp[0].IP, sdp[0].Port)
+		}
+
+	case "BYE", "CANCEL":
+		if call := voip.End(callID); call != nil {
+			duration := time.Duration(0)
+			if !call.Answered.IsZero() {
+				duration = when.Sub(call.Answered)
+			}
+			desc += fmt.Sprintf(", call lasted %s with %d rtp streams and %d packets",
+				tui.Bold(duration.Round(time.Second).String()),
+				len(call.streams),
+				call.Packets())
+		}
+	}
+
+	sipEvent(when, "sip", srcIP, dstIP, SniffData{"call_id": callID, "method": msg.Method, "from": msg.From(), "to": msg.To()}, "%s", desc)
+	return true
+}
+
+func sipParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *layers.UDP) bool {
+	if udp.SrcPort != packets.SIPPort && udp.DstPort != packets.SIPPort {
+		return false
+	}
+	return onSIP(srcIP, dstIP, int(udp.SrcPort), int(udp.DstPort), udp.Payload, pkt)
+}
+
+func sipTCPParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+	if tcp.SrcPort != packets.SIPPort && tcp.DstPort != packets.SIPPort {
+		return false
+	}
+	return onSIP(srcIP, dstIP, int(tcp.SrcPort), int(tcp.DstPort), tcp.Payload, pkt)
+}
+
+// rtpParser accounts the RTP packets of the streams negotiated by SIP.
+func rtpParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, udp *layers.UDP) bool {
+	rtp, err := packets.ParseRTP(udp.Payload)
+	if err != nil {
+		return false
+	}
+
+	src := fmt.Sprintf("%s:%d", srcIP, udp.SrcPort)
+	dst := fmt.Sprintf("%s:%d", dstIP, udp.DstPort)
+	call, stream, started, err := voip.OnRTP(src, dst, rtp, pkt.Metadata().Timestamp)
+	if call == nil {
+		return false
+	} else if err != nil {
+		log.Warning("error recording rtp stream %08x: %v", rtp.SSRC, err)
+	}
+
+	if started {
+		codec := packets.RTPPayloadName(stream.PayloadType)
+		sipEvent(pkt.Metadata().Timestamp, "rtp", srcIP, dstIP,
+			SniffData{"call_id": call.ID, "ssrc": stream.SSRC, "codec": codec},
+			"%s %08x %s, %s > %s",
+			tui.Bold("stream"),
+			stream.SSRC,
+			tui.Yellow(codec),
+			strings.TrimPrefix(call.From, "sip:"),
+			strings.TrimPrefix(call.To, "sip:"))
+	}
+
+	return true
+}
@@ -0,0 +1,121 @@
+package net_sniff
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+func isSMBPort(port layers.TCPPort) bool {
+	return port == packets.SMBPort || port == 139
+}
+
+func smbEvent(srcIP, dstIP net.IP, pkt gopacket.Packet, tcp *layers.TCP, data SniffData, what string, desc string) {
+	NewSnifferEvent(
+		pkt.Metadata().Timestamp,
+		"smb",
+		srcIP.String(),
+		dstIP.String(),
+		data,
+		"%s %s > %s:%s - %s %s",
+		tui.Wrap(tui.BACKLIGHTBLUE+tui.FOREBLACK, "smb"),
+		vIP(srcIP),
+		vIP(dstIP),
+		vPort(tcp.DstPort),
+		tui.Bold(what),
+		desc,
+	).Push()
+}
+
+func smb1Message(srcIP, dstIP net.IP, pkt gopacket.Packet, tcp *layers.TCP, msg []byte) bool {
+	if dialects, err := packets.ParseSMB1NegotiateRequest(msg); err == nil {
+		smbEvent(srcIP, dstIP, pkt, tcp, SniffData{"dialects": dialects},
+			"negotiate", tui.Yellow(strings.Join(dialects, ", ")))
+		return true
+	} else if signing, err := packets.SMB1Signing(msg); err == nil {
+		smbEvent(srcIP, dstIP, pkt, tcp, SniffData{"dialect": "NT LM 0.12", "signing": signing},
+			"smb1", fmt.Sprintf("signing %s", tui.Yellow(signing)))
+		return true
+	}
+	return false
+}
+
+func smb2Message(srcIP, dstIP net.IP, pkt gopacket.Packet, tcp *layers.TCP, msg []byte) bool {
+	h, err := packets.ParseSMB2Header(msg)
+	if err != nil {
+		return false
+	}
+
+	response := h.Flags&packets.SMB2FlagResponse != 0
+	switch {
+	case h.Command == packets.SMB2Negotiate && !response:
+		if _, dialects, err := packets.ParseSMB2NegotiateRequest(msg); err == nil {
+			names := make([]string, len(dialects))
+			for i, dialect := range dialects {
+				names[i] = packets.SMBDialectName(dialect)
+			}
+			smbEvent(srcIP, dstIP, pkt, tcp, SniffData{"dialects": names},
+				"negotiate", tui.Yellow(strings.Join(names, ", ")))
+			return true
+		}
+
+	case h.Command == packets.SMB2Negotiate && h.Status == packets.SMB2StatusSuccess:
+		if res, err := packets.ParseSMB2NegotiateResponse(msg); err == nil {
+			dialect := packets.SMBDialectName(res.Dialect)
+			smbEvent(srcIP, dstIP, pkt, tcp,
+				SniffData{"dialect": dialect, "signing": res.Signing(), "encryption": res.Encryption()},
+				dialect, fmt.Sprintf("signing %s, encryption %s", tui.Yellow(res.Signing()), tui.Yellow(res.Encryption())))
+			return true
+		}
+
+	case h.Command == packets.SMB2TreeConnect && !response:
+		if path, err := packets.ParseSMB2TreeConnectRequest(msg); err == nil {
+			smbEvent(srcIP, dstIP, pkt, tcp, SniffData{"share": path}, "tree connect", tui.Yellow(path))
+			return true
+		}
+
+	case h.Command == packets.SMB2Create && !response:
+		if name, err := packets.ParseSMB2CreateRequest(msg); err == nil && name != "" {
+			smbEvent(srcIP, dstIP, pkt, tcp, SniffData{"file": name, "tree": h.TreeID}, "open", tui.Yellow(name))
+			return true
+		}
+	}
+
+	return false
+}
+
+func smbParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+	if !isSMBPort(tcp.SrcPort) && !isSMBPort(tcp.DstPort) {
+		return false
+	}
+
+	// the session as seen by the client
+	conn := flowKey(srcIP, dstIP, tcp)
+	if isSMBPort(tcp.SrcPort) {
+		conn = reverseFlowKey(srcIP, dstIP, tcp)
+	}
+
+	found := false
+	for _, msg := range packets.SMBMessages(tcp.Payload) {
+		if packets.IsSMB1(msg) {
+			found = smb1Message(srcIP, dstIP, pkt, tcp, msg) || found
+		} else if packets.IsSMB2(msg) {
+			for _, cmd := range packets.SMB2Compound(msg) {
+				found = smb2Message(srcIP, dstIP, pkt, tcp, cmd) || found
+				if carver != nil {
+					if h, err := packets.ParseSMB2Header(cmd); err == nil {
+						found = carver.OnSMB2(srcIP, dstIP, pkt, conn, h, cmd) || found
+					}
+				}
+			}
+		}
+	}
+	return found
+}
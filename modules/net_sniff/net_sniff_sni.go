@@ -1,10 +1,14 @@
 package net_sniff
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
-
 	"regexp"
+	"strings"
+
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
@@ -12,38 +16,123 @@ import (
 	"github.com/evilsocket/islazy/tui"
 )
 
-// poor man's TLS Client Hello with SNI extension parser :P
+// poor man's TLS Client Hello with SNI extension parser :P used when the
+// hello is too mangled to be parsed
 var sniRe = regexp.MustCompile("\x00\x00.{4}\x00.{2}([a-z0-9]+([\\-\\.]{1}[a-z0-9]+)*\\.[a-z]{2,6})\x00")
 
-func sniParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+const (
+	tlsHandshake   = 0x16
+	tlsClientHello = 0x01
+)
+
+func clientHelloParser(srcIP, dstIP net.IP, pkt gopacket.Packet, tcp *layers.TCP) bool {
 	data := tcp.Payload
-	dataSize := len(data)
 
-	if dataSize < 2 || data[0] != 0x16 || data[1] != 0x03 {
-		return false
+	domain, found := packets.TLSClientHelloSNI(data)
+	if !found {
+		if m := sniRe.FindSubmatch(data); len(m) >= 2 {
+			domain = string(m[1])
+		} else {
+			// no server name, the destination is the address
+			domain = dstIP.String()
+		}
 	}
-
-	m := sniRe.FindSubmatch(data)
-	if len(m) < 2 {
-		return false
+	if kind := encryptedDNSKind(dstIP, tcp, domain); kind != "" {
+		onEncryptedDNS(kind, srcIP, dstIP, domain, pkt)
 	}
-
-	domain := string(m[1])
 	if tcp.DstPort != 443 {
 		domain = fmt.Sprintf("%s:%d", domain, tcp.DstPort)
 	}
 
+	alpn, _ := packets.TLSClientHelloALPN(data)
+	ciphers := make([]string, 0)
+	if ids, found := packets.TLSClientHelloCiphers(data); found {
+		for _, id := range ids {
+			ciphers = append(ciphers, tls.CipherSuiteName(id))
+		}
+	}
+	ja3, hash, _ := packets.TLSClientHelloJA3(data)
+
+	names := tlsHosts.OnClientHello(srcIP.String(), flowKey(srcIP, dstIP, tcp), domain, strings.Join(alpn, ","), hash, pkt.Metadata().Timestamp)
+	if e := session.I.Lan.GetByIp(srcIP.String()); e != nil {
+		if hash != "" {
+			e.Meta.Set("tls:ja3", hash)
+		}
+		e.Meta.SetStrings("tls:destinations", names)
+	}
+
+	desc := ""
+	if len(alpn) > 0 {
+		desc += " " + tui.Dim(strings.Join(alpn, ","))
+	}
+	if hash != "" {
+		desc += " " + tui.Dim("ja3 "+hash)
+	}
+
 	NewSnifferEvent(
 		pkt.Metadata().Timestamp,
 		"https",
 		srcIP.String(),
 		domain,
-		nil,
-		"%s %s > %s",
+		SniffData{
+			"sni":      domain,
+			"alpn":     alpn,
+			"ciphers":  ciphers,
+			"ja3":      ja3,
+			"ja3_hash": hash,
+		},
+		"%s %s > %s%s",
 		tui.Wrap(tui.BACKYELLOW+tui.FOREWHITE, "sni"),
 		vIP(srcIP),
 		tui.Yellow("https://"+domain),
+		desc,
 	).Push()
 
 	return true
 }
+
+// certificates are only parsed if sent in the clear, TLS 1.2 and older, and
+// in the same segment of the server hello.
+func serverCertificateParser(srcIP, dstIP net.IP, pkt gopacket.Packet, tcp *layers.TCP) bool {
+	cert, found := packets.TLSServerCertificate(tcp.Payload)
+	if !found {
+		return false
+	}
+
+	flow := reverseFlowKey(srcIP, dstIP, tcp)
+	domain := tlsHosts.OnCertificate(flow, cert.Subject.String())
+	if domain == "" {
+		domain = fmt.Sprintf("%s:%d", srcIP, tcp.SrcPort)
+	}
+
+	NewSnifferEvent(
+		pkt.Metadata().Timestamp,
+		"https",
+		domain,
+		dstIP.String(),
+		SniffData{
+			"subject":   cert.Subject.String(),
+			"issuer":    cert.Issuer.String(),
+			"names":     cert.DNSNames,
+			"not_after": cert.NotAfter,
+		},
+		"%s %s > %s %s %s",
+		tui.Wrap(tui.BACKYELLOW+tui.FOREWHITE, "cert"),
+		tui.Yellow("https://"+domain),
+		vIP(dstIP),
+		tui.Bold(cert.Subject.CommonName),
+		tui.Dim("issued by "+cert.Issuer.CommonName),
+	).Push()
+
+	return true
+}
+
+func sniParser(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+	data := tcp.Payload
+	if len(data) < 6 || data[0] != tlsHandshake || data[1] != 0x03 {
+		return false
+	} else if data[5] == tlsClientHello {
+		return clientHelloParser(srcIP, dstIP, pkt, tcp)
+	}
+	return serverCertificateParser(srcIP, dstIP, pkt, tcp)
+}
@@ -10,9 +10,12 @@ type SnifferStats struct {
 	NumMatched  uint64
 	NumDumped   uint64
 	NumWrote    uint64
+	NumSkipped  uint64
 	Started     time.Time
 	FirstPacket time.Time
 	LastPacket  time.Time
+	// packets by interface, when capturing from many
+	Interfaces map[string]uint64
 }
 
 func NewSnifferStats() *SnifferStats {
@@ -21,9 +24,11 @@ func NewSnifferStats() *SnifferStats {
 		NumMatched:  0,
 		NumDumped:   0,
 		NumWrote:    0,
+		NumSkipped:  0,
 		Started:     time.Now(),
 		FirstPacket: time.Time{},
 		LastPacket:  time.Time{},
+		Interfaces:  make(map[string]uint64),
 	}
 }
 
@@ -45,6 +50,16 @@ func (s *SnifferStats) Print() error {
 	log.Info("Matched Packets    : %d", s.NumMatched)
 	log.Info("Dumped Packets     : %d", s.NumDumped)
 	log.Info("Wrote Packets      : %d", s.NumWrote)
+	log.Info("Sampled Out        : %d", s.NumSkipped)
+	if parserBudget != nil {
+		names, skipped := parserBudget.Skipped()
+		for _, name := range names {
+			log.Info("Over Budget        : %s skipped %d times", name, skipped[name])
+		}
+	}
+	for name, count := range s.Interfaces {
+		log.Info("Packets on %-8s: %d", name, count)
+	}
 
 	return nil
 }
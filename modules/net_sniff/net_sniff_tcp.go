@@ -11,20 +11,41 @@ import (
 )
 
 var tcpParsers = []func(net.IP, net.IP, []byte, gopacket.Packet, *layers.TCP) bool{
+	rdpParser,
 	sniParser,
+	krb5TCPParser,
+	dnsTCPParser,
 	ntlmParser,
+	smbParser,
 	httpParser,
 	ftpParser,
+	mailParser,
+	sipTCPParser,
+	mqttParser,
+	modbusParser,
+	dnp3Parser,
 	teamViewerParser,
 }
 
-func onTCP(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, verbose bool) {
-	tcp := pkt.Layer(layers.LayerTypeTCP).(*layers.TCP)
+func parseTCP(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, tcp *layers.TCP) bool {
+	if tcpStreams != nil {
+		return tcpStreams.Assemble(srcIP, dstIP, payload, pkt, tcp)
+	}
+
 	for _, parser := range tcpParsers {
-		if parser(srcIP, dstIP, payload, pkt, tcp) {
-			return
+		parser := parser
+		if withBudget(parser, func() bool { return parser(srcIP, dstIP, payload, pkt, tcp) }) {
+			return true
 		}
 	}
+	return false
+}
+
+func onTCP(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, verbose bool) {
+	tcp := pkt.Layer(layers.LayerTypeTCP).(*layers.TCP)
+	if parseTCP(srcIP, dstIP, payload, pkt, tcp) {
+		return
+	}
 
 	if verbose {
 		sz := len(payload)
@@ -0,0 +1,138 @@
+package net_sniff
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+// drop the pending flows when there are too many waiting for a certificate
+const tlsMaxFlows = 4096
+
+// TLSDestination is a TLS server a host has been seen talking to.
+type TLSDestination struct {
+	Name     string
+	ALPN     string
+	JA3      string
+	Subject  string
+	Hits     int
+	LastSeen time.Time
+}
+
+// tlsTracker keeps the TLS destinations of every host, by server name.
+type tlsTracker struct {
+	sync.Mutex
+	hosts map[string]map[string]*TLSDestination
+	// destinations waiting for the server certificate, by client to server flow
+	flows map[string]*TLSDestination
+}
+
+var tlsHosts = newTLSTracker()
+
+func newTLSTracker() *tlsTracker {
+	return &tlsTracker{
+		hosts: make(map[string]map[string]*TLSDestination),
+		flows: make(map[string]*TLSDestination),
+	}
+}
+
+// OnClientHello accounts a connection of client to name, returning the
+// names of all its destinations so far.
+func (t *tlsTracker) OnClientHello(client string, flow string, name string, alpn string, ja3 string, when time.Time) []string {
+	t.Lock()
+	defer t.Unlock()
+
+	dests, found := t.hosts[client]
+	if !found {
+		dests = make(map[string]*TLSDestination)
+		t.hosts[client] = dests
+	}
+
+	dest, found := dests[name]
+	if !found {
+		dest = &TLSDestination{Name: name}
+		dests[name] = dest
+	}
+	dest.ALPN = alpn
+	dest.JA3 = ja3
+	dest.Hits++
+	dest.LastSeen = when
+
+	if len(t.flows) >= tlsMaxFlows {
+		t.flows = make(map[string]*TLSDestination)
+	}
+	t.flows[flow] = dest
+
+	names := make([]string, 0, len(dests))
+	for name := range dests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OnCertificate sets the certificate subject of the destination of a flow,
+// returning its name if it's known.
+func (t *tlsTracker) OnCertificate(flow string, subject string) string {
+	t.Lock()
+	defer t.Unlock()
+
+	if dest, found := t.flows[flow]; found {
+		delete(t.flows, flow)
+		dest.Subject = subject
+		return dest.Name
+	}
+	return ""
+}
+
+// Each calls cb for every host and destination, sorted by host and name.
+func (t *tlsTracker) Each(cb func(host string, dest TLSDestination)) {
+	t.Lock()
+	defer t.Unlock()
+
+	hosts := make([]string, 0, len(t.hosts))
+	for host := range t.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	for _, host := range hosts {
+		names := make([]string, 0, len(t.hosts[host]))
+		for name := range t.hosts[host] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			cb(host, *t.hosts[host][name])
+		}
+	}
+}
+
+func (mod *Sniffer) ShowTLS() error {
+	colNames := []string{"Host", "Destination", "ALPN", "JA3", "Certificate", "Hits", "Last Seen"}
+	rows := make([][]string, 0)
+	tlsHosts.Each(func(host string, dest TLSDestination) {
+		rows = append(rows, []string{
+			host,
+			tui.Yellow(dest.Name),
+			tui.Dim(dest.ALPN),
+			tui.Dim(dest.JA3),
+			dest.Subject,
+			strconv.Itoa(dest.Hits),
+			dest.LastSeen.Format("15:04:05"),
+		})
+	})
+
+	if len(rows) == 0 {
+		mod.Info("no TLS destinations seen yet")
+		return nil
+	}
+
+	tui.Table(mod.Session.Events.Stdout, colNames, rows)
+	mod.Session.Refresh()
+	return nil
+}
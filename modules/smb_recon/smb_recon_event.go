@@ -0,0 +1,23 @@
+package smb_recon
+
+import (
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+)
+
+type SMBReconEvent struct {
+	Address     string
+	Host        *network.Endpoint
+	Dialects    []string
+	Signing     string
+	OS          string
+	NullSession bool
+	Shares      []packets.SMBShare
+	Accessible  []string
+}
+
+func (e SMBReconEvent) Push() {
+	session.I.Events.Add("smb.recon", e)
+	session.I.Refresh()
+}
@@ -0,0 +1,339 @@
+package snmp_scan
+
+import (
+	"encoding/asn1"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/evilsocket/islazy/async"
+)
+
+const maxInterfaces = 64
+
+type SNMPScanner struct {
+	session.SessionModule
+	communities []string
+	defaults    []string
+	version     int
+	timeout     time.Duration
+	interfaces  bool
+	scanned     sync.Map
+	scanQueue   *async.WorkQueue
+	waitGroup   *sync.WaitGroup
+}
+
+func NewSNMPScanner(s *session.Session) *SNMPScanner {
+	mod := &SNMPScanner{
+		SessionModule: session.NewSessionModule("snmp.scan", s),
+		waitGroup:     &sync.WaitGroup{},
+	}
+
+	mod.scanQueue = async.NewQueue(0, mod.scanWorker)
+
+	mod.SessionModule.Requires("net.recon")
+
+	mod.AddParam(session.NewStringParameter("snmp.scan.communities",
+		"public,private,community,manager,admin,monitor,snmp,secret,cisco,default",
+		"",
+		"Comma separated list of SNMP communities to try against every host."))
+
+	mod.AddParam(session.NewStringParameter("snmp.scan.communities.default",
+		"public,private",
+		"",
+		"Comma separated list of SNMP communities to flag as default ones."))
+
+	mod.AddParam(session.NewStringParameter("snmp.scan.version",
+		"2c",
+		"^(1|2c)$",
+		"SNMP protocol version to use, either 1 or 2c."))
+
+	mod.AddParam(session.NewIntParameter("snmp.scan.timeout",
+		"1000",
+		"Timeout in milliseconds to wait for an SNMP response."))
+
+	mod.AddParam(session.NewBoolParameter("snmp.scan.interfaces",
+		"true",
+		"If true, the interfaces table will be fetched from every host with a valid community."))
+
+	mod.AddHandler(session.NewModuleHandler("snmp.scan on", "",
+		"Start scanning discovered hosts for SNMP communities.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("snmp.scan off", "",
+		"Stop scanning discovered hosts for SNMP communities.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("snmp.scan.clear", "",
+		"Clear the list of already scanned hosts so that they will be scanned again.",
+		func(args []string) error {
+			mod.scanned.Range(func(k, v interface{}) bool {
+				mod.scanned.Delete(k)
+				return true
+			})
+			return nil
+		}))
+
+	return mod
+}
+
+func (mod *SNMPScanner) Name() string {
+	return "snmp.scan"
+}
+
+func (mod *SNMPScanner) Description() string {
+	return "Try a list of SNMP communities against discovered hosts, collecting system information and flagging devices using default communities."
+}
+
+func (mod *SNMPScanner) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *SNMPScanner) Configure() (err error) {
+	var version string
+	var timeout int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, mod.communities = mod.ListParam("snmp.scan.communities"); err != nil {
+		return err
+	} else if err, mod.defaults = mod.ListParam("snmp.scan.communities.default"); err != nil {
+		return err
+	} else if err, version = mod.StringParam("snmp.scan.version"); err != nil {
+		return err
+	} else if err, timeout = mod.IntParam("snmp.scan.timeout"); err != nil {
+		return err
+	} else if err, mod.interfaces = mod.BoolParam("snmp.scan.interfaces"); err != nil {
+		return err
+	} else if len(mod.communities) == 0 {
+		return fmt.Errorf("no communities specified")
+	}
+
+	mod.version = packets.SNMPVersion2c
+	if version == "1" {
+		mod.version = packets.SNMPVersion1
+	}
+	mod.timeout = time.Duration(timeout) * time.Millisecond
+
+	return nil
+}
+
+func (mod *SNMPScanner) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.waitGroup.Add(1)
+		defer mod.waitGroup.Done()
+
+		mod.Info("scanning hosts with %d communities", len(mod.communities))
+
+		for mod.Running() {
+			targets := append(mod.Session.Lan.List(), mod.Session.Gateway)
+			for _, target := range targets {
+				if !mod.Running() {
+					return
+				} else if target.IP == nil || target.IP.To4() == nil {
+					continue
+				} else if target != mod.Session.Gateway && mod.Session.Skip(target.IP) {
+					continue
+				} else if _, found := mod.scanned.LoadOrStore(target.IpAddress, true); !found {
+					mod.scanQueue.Add(async.Job(target))
+				}
+			}
+			time.Sleep(5 * time.Second)
+		}
+	})
+}
+
+func (mod *SNMPScanner) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.waitGroup.Wait()
+		// the workers give up as soon as they see we're not running
+		mod.scanQueue.WaitDone()
+	})
+}
+
+func (mod *SNMPScanner) isDefault(community string) bool {
+	for _, c := range mod.defaults {
+		if c == community {
+			return true
+		}
+	}
+	return false
+}
+
+func (mod *SNMPScanner) request(conn net.Conn, community string, pduType byte, oids ...asn1.ObjectIdentifier) (*packets.SNMPResponse, error) {
+	reqID := int(rand.Int31())
+	raw, err := packets.NewSNMPRequest(mod.version, community, pduType, reqID, oids...)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(mod.timeout))
+	if wrote, err := conn.Write(raw); err != nil {
+		mod.Session.Queue.TrackError()
+		return nil, err
+	} else {
+		mod.Session.Queue.TrackSent(uint64(wrote))
+	}
+
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := packets.ParseSNMPResponse(buf[:n])
+		if err != nil {
+			return nil, err
+		} else if res.RequestID != reqID {
+			continue
+		} else if res.ErrorStatus != 0 {
+			return nil, fmt.Errorf("snmp error status %d", res.ErrorStatus)
+		}
+		return res, nil
+	}
+}
+
+func hasPrefix(oid, prefix asn1.ObjectIdentifier) bool {
+	if len(oid) <= len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if oid[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (mod *SNMPScanner) walkInterfaces(conn net.Conn, community string) []string {
+	names := []string{}
+	oid := packets.SNMPOidIfDescr
+	for len(names) < maxInterfaces && mod.Running() {
+		res, err := mod.request(conn, community, packets.SNMPGetNextRequest, oid)
+		if err != nil || len(res.VarBinds) == 0 {
+			break
+		}
+
+		vb := res.VarBinds[0]
+		if vb.IsEmpty() || !hasPrefix(vb.Name, packets.SNMPOidIfDescr) {
+			break
+		}
+
+		names = append(names, vb.String())
+		oid = vb.Name
+	}
+	return names
+}
+
+func (mod *SNMPScanner) scanWorker(job async.Job) {
+	target := job.(*network.Endpoint)
+	address := net.JoinHostPort(target.IpAddress, strconv.Itoa(packets.SNMPPort))
+
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		mod.Debug("could not dial %s: %v", address, err)
+		return
+	}
+	defer conn.Close()
+
+	found := []string{}
+	var info *packets.SNMPResponse
+	for _, community := range mod.communities {
+		if !mod.Running() {
+			// scan it again next time
+			mod.scanned.Delete(target.IpAddress)
+			return
+		}
+
+		res, err := mod.request(conn, community, packets.SNMPGetRequest, packets.SNMPOidSysDescr, packets.SNMPOidSysName)
+		if err != nil {
+			mod.Debug("%s community %s: %v", target.IpAddress, community, err)
+			continue
+		}
+
+		found = append(found, community)
+		if info == nil {
+			info = res
+		}
+	}
+
+	if len(found) == 0 {
+		return
+	}
+
+	meta := map[string]string{
+		"snmp:communities": strings.Join(found, ","),
+	}
+
+	for _, vb := range info.VarBinds {
+		if vb.IsEmpty() {
+			continue
+		} else if vb.Name.Equal(packets.SNMPOidSysDescr) {
+			meta["snmp:sysDescr"] = vb.String()
+		} else if vb.Name.Equal(packets.SNMPOidSysName) {
+			meta["snmp:sysName"] = vb.String()
+		}
+	}
+
+	// these are optional and on SNMPv1 a single missing
+	// object would make the whole request fail
+	if res, err := mod.request(conn, found[0], packets.SNMPGetRequest, packets.SNMPOidSysLocation, packets.SNMPOidSysContact); err == nil {
+		for _, vb := range res.VarBinds {
+			if vb.IsEmpty() {
+				continue
+			} else if vb.Name.Equal(packets.SNMPOidSysLocation) {
+				meta["snmp:sysLocation"] = vb.String()
+			} else if vb.Name.Equal(packets.SNMPOidSysContact) {
+				meta["snmp:sysContact"] = vb.String()
+			}
+		}
+	}
+
+	if mod.interfaces {
+		if ifaces := mod.walkInterfaces(conn, found[0]); len(ifaces) > 0 {
+			meta["snmp:interfaces"] = strings.Join(ifaces, ",")
+		}
+	}
+
+	defaults := []string{}
+	for _, community := range found {
+		if mod.isDefault(community) {
+			defaults = append(defaults, community)
+		}
+	}
+	if len(defaults) > 0 {
+		meta["snmp:default-community"] = strings.Join(defaults, ",")
+		mod.Warning("%s is using default SNMP communities: %s", target.IpAddress, strings.Join(defaults, ", "))
+	}
+
+	target.OnMeta(meta)
+
+	for _, community := range found {
+		mod.Session.AddCredential(session.Credential{
+			Protocol: "snmp",
+			Type:     session.CredentialCommunity,
+			Source:   mod.Name(),
+			Server:   target.IpAddress,
+			Secret:   community,
+		})
+	}
+
+	NewSNMPScanEvent(target, found, defaults, meta["snmp:sysName"], meta["snmp:sysDescr"]).Push()
+}
@@ -0,0 +1,31 @@
+package snmp_scan
+
+import (
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/session"
+)
+
+type SNMPScanEvent struct {
+	Address     string
+	Host        *network.Endpoint
+	Communities []string
+	Defaults    []string
+	SysName     string
+	SysDescr    string
+}
+
+func NewSNMPScanEvent(h *network.Endpoint, communities []string, defaults []string, name string, descr string) SNMPScanEvent {
+	return SNMPScanEvent{
+		Address:     h.IpAddress,
+		Host:        h,
+		Communities: communities,
+		Defaults:    defaults,
+		SysName:     name,
+		SysDescr:    descr,
+	}
+}
+
+func (e SNMPScanEvent) Push() {
+	session.I.Events.Add("snmp.scan", e)
+	session.I.Refresh()
+}
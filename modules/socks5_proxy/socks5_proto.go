@@ -0,0 +1,185 @@
+package socks5_proxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// RFC 1928 and RFC 1929
+const (
+	socksVersion = 0x05
+	authVersion  = 0x01
+
+	methodNoAuth       = 0x00
+	methodUserPass     = 0x02
+	methodNoAcceptable = 0xff
+
+	cmdConnect = 0x01
+
+	addrIPv4   = 0x01
+	addrDomain = 0x03
+	addrIPv6   = 0x04
+
+	replySuccess             = 0x00
+	replyFailure             = 0x01
+	replyHostUnreachable     = 0x04
+	replyCommandNotSupported = 0x07
+	replyAddrNotSupported    = 0x08
+
+	authSuccess = 0x00
+	authFailure = 0x01
+)
+
+// socksRequest is the destination a client asked us to connect to.
+type socksRequest struct {
+	command byte
+	host    string
+	port    int
+}
+
+func (r socksRequest) Address() string {
+	return net.JoinHostPort(r.host, strconv.Itoa(r.port))
+}
+
+// negotiate reads the methods offered by the client and selects the one we
+// require, performing the username and password authentication if needed.
+func (mod *Socks5Proxy) negotiate(conn net.Conn) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	} else if header[0] != socksVersion {
+		return fmt.Errorf("unsupported socks version %d", header[0])
+	}
+
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return err
+	}
+
+	wanted := byte(methodNoAuth)
+	if mod.username != "" {
+		wanted = methodUserPass
+	}
+
+	offered := false
+	for _, method := range methods {
+		if method == wanted {
+			offered = true
+			break
+		}
+	}
+
+	if !offered {
+		conn.Write([]byte{socksVersion, methodNoAcceptable})
+		return fmt.Errorf("client did not offer authentication method %d", wanted)
+	} else if _, err := conn.Write([]byte{socksVersion, wanted}); err != nil {
+		return err
+	} else if wanted == methodUserPass {
+		return mod.authenticate(conn)
+	}
+
+	return nil
+}
+
+func (mod *Socks5Proxy) authenticate(conn net.Conn) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	} else if header[0] != authVersion {
+		return fmt.Errorf("unsupported authentication version %d", header[0])
+	}
+
+	username := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, username); err != nil {
+		return err
+	}
+
+	size := make([]byte, 1)
+	if _, err := io.ReadFull(conn, size); err != nil {
+		return err
+	}
+
+	password := make([]byte, size[0])
+	if _, err := io.ReadFull(conn, password); err != nil {
+		return err
+	}
+
+	if string(username) != mod.username || string(password) != mod.password {
+		conn.Write([]byte{authVersion, authFailure})
+		return fmt.Errorf("invalid credentials for user '%s'", username)
+	}
+
+	_, err := conn.Write([]byte{authVersion, authSuccess})
+	return err
+}
+
+func (mod *Socks5Proxy) readRequest(conn net.Conn) (*socksRequest, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	} else if header[0] != socksVersion {
+		return nil, fmt.Errorf("unsupported socks version %d", header[0])
+	}
+
+	req := &socksRequest{command: header[1]}
+
+	switch header[3] {
+	case addrIPv4, addrIPv6:
+		size := net.IPv4len
+		if header[3] == addrIPv6 {
+			size = net.IPv6len
+		}
+		ip := make([]byte, size)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return nil, err
+		}
+		req.host = net.IP(ip).String()
+
+	case addrDomain:
+		size := make([]byte, 1)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return nil, err
+		}
+		domain := make([]byte, size[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return nil, err
+		}
+		req.host = string(domain)
+
+	default:
+		mod.reply(conn, replyAddrNotSupported, nil)
+		return nil, fmt.Errorf("unsupported address type %d", header[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return nil, err
+	}
+	req.port = int(binary.BigEndian.Uint16(port))
+
+	return req, nil
+}
+
+// reply sends the outcome of a request to the client along with the address
+// we're connecting from, if any.
+func (mod *Socks5Proxy) reply(conn net.Conn, code byte, bound net.Addr) error {
+	ip := net.IPv4zero.To4()
+	port := 0
+	if addr, ok := bound.(*net.TCPAddr); ok {
+		ip, port = addr.IP, addr.Port
+	}
+
+	msg := []byte{socksVersion, code, 0x00}
+	if ip4 := ip.To4(); ip4 != nil {
+		msg = append(append(msg, addrIPv4), ip4...)
+	} else {
+		msg = append(append(msg, addrIPv6), ip.To16()...)
+	}
+	msg = append(msg, byte(port>>8), byte(port))
+
+	_, err := conn.Write(msg)
+	return err
+}
@@ -0,0 +1,377 @@
+package socks5_proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/modules/http_proxy"
+	"github.com/bettercap/bettercap/session"
+	"github.com/bettercap/bettercap/tls"
+
+	"github.com/evilsocket/islazy/fs"
+	"github.com/evilsocket/islazy/str"
+	"github.com/evilsocket/islazy/tui"
+)
+
+const (
+	dialTimeout = 10 * time.Second
+	// how long to wait for the client to speak first before tunneling the
+	// connection as it is, server first protocols would hang otherwise
+	sniffTimeout = 500 * time.Millisecond
+)
+
+var httpMethods = [][]byte{
+	[]byte("GET "),
+	[]byte("POST"),
+	[]byte("PUT "),
+	[]byte("HEAD"),
+	[]byte("DELE"),
+	[]byte("OPTI"),
+	[]byte("PATC"),
+}
+
+type Socks5Proxy struct {
+	session.SessionModule
+	proxy     *http_proxy.HTTPProxy
+	listener  net.Listener
+	username  string
+	password  string
+	intercept bool
+	conns     sync.Map
+}
+
+// sniffedConn gives back the bytes read to detect the protocol.
+type sniffedConn struct {
+	net.Conn
+	head []byte
+}
+
+func (c *sniffedConn) Read(b []byte) (int, error) {
+	if len(c.head) > 0 {
+		n := copy(b, c.head)
+		c.head = c.head[n:]
+		return n, nil
+	}
+	return c.Conn.Read(b)
+}
+
+func NewSocks5Proxy(s *session.Session) *Socks5Proxy {
+	mod := &Socks5Proxy{
+		SessionModule: session.NewSessionModule("socks5.proxy", s),
+		proxy:         http_proxy.NewHTTPProxy(s, "socks5.proxy"),
+	}
+
+	mod.AddParam(session.NewStringParameter("socks5.proxy.address",
+		session.ParamIfaceAddress,
+		session.IPv4Validator,
+		"Address to bind the SOCKS5 proxy to."))
+
+	mod.AddParam(session.NewIntParameter("socks5.proxy.port",
+		"1080",
+		"Port to bind the SOCKS5 proxy to."))
+
+	mod.AddParam(session.NewStringParameter("socks5.proxy.username",
+		"",
+		"",
+		"If not empty, clients must authenticate with this username and socks5.proxy.password."))
+
+	mod.AddParam(session.NewStringParameter("socks5.proxy.password",
+		"",
+		"",
+		"Password of the clients if socks5.proxy.username is set."))
+
+	mod.AddParam(session.NewBoolParameter("socks5.proxy.intercept",
+		"true",
+		"If true, HTTP and HTTPS connections are intercepted and passed to the script, otherwise all the traffic is just tunneled."))
+
+	mod.AddParam(session.NewStringParameter("socks5.proxy.script",
+		"",
+		"",
+		"Path of a proxy JS script, the same callbacks of http.proxy scripts are supported."))
+
+	mod.AddParam(session.NewStringParameter("socks5.proxy.injectjs",
+		"",
+		"",
+		"URL, path or javascript code to inject into every HTML page."))
+
+	mod.AddParam(session.NewStringParameter("socks5.proxy.rules",
+		"",
+		"",
+		"If not empty, JSON file of rules injecting javascript or HTML, replacing strings, setting headers or redirecting the requests matching a host, path and content type."))
+
+	mod.AddParam(session.NewStringParameter("socks5.proxy.storage",
+		"~/.bettercap-proxy-storage.json",
+		"",
+		"File the key-value storage object of the proxy scripts is saved to, shared by all the proxies of the session using the same file. If empty the storage is kept in memory only."))
+
+	mod.AddParam(session.NewBoolParameter("socks5.proxy.stream",
+		"false",
+		"If true, request and response bodies are piped through as they are received and passed to the onRequestChunk and onResponseChunk script callbacks, they are only buffered if the script reads them whole."))
+
+	mod.AddParam(session.NewStringParameter("socks5.proxy.blacklist", "", "",
+		"Comma separated list of hostnames to skip while proxying (wildcard expressions can be used)."))
+
+	mod.AddParam(session.NewStringParameter("socks5.proxy.whitelist", "", "",
+		"Comma separated list of hostnames to proxy if the blacklist is used (wildcard expressions can be used)."))
+
+	mod.AddParam(session.NewStringParameter("socks5.proxy.certificate",
+		"~/.bettercap-ca.cert.pem",
+		"",
+		"Certification authority TLS certificate file used to intercept HTTPS connections."))
+
+	mod.AddParam(session.NewStringParameter("socks5.proxy.key",
+		"~/.bettercap-ca.key.pem",
+		"",
+		"Certification authority TLS key file used to intercept HTTPS connections."))
+
+	tls.CertConfigToModule("socks5.proxy", &mod.SessionModule, tls.DefaultSpoofConfig)
+
+	mod.AddHandler(session.NewModuleHandler("socks5.proxy on", "",
+		"Start the SOCKS5 proxy.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("socks5.proxy off", "",
+		"Stop the SOCKS5 proxy.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	return mod
+}
+
+func (mod *Socks5Proxy) Name() string {
+	return "socks5.proxy"
+}
+
+func (mod *Socks5Proxy) Description() string {
+	return "A SOCKS5 proxy that intercepts the HTTP and HTTPS connections of the clients configured to use it, with the same scripting support of http.proxy, and tunnels everything else."
+}
+
+func (mod *Socks5Proxy) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *Socks5Proxy) Configure() error {
+	var err error
+	var address string
+	var port int
+	var scriptPath string
+	var jsToInject string
+	var rulesFile string
+	var blacklist string
+	var whitelist string
+	var certFile string
+	var keyFile string
+	var stream bool
+	var storageFile string
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, address = mod.StringParam("socks5.proxy.address"); err != nil {
+		return err
+	} else if err, port = mod.IntParam("socks5.proxy.port"); err != nil {
+		return err
+	} else if err, mod.username = mod.StringParam("socks5.proxy.username"); err != nil {
+		return err
+	} else if err, mod.password = mod.StringParam("socks5.proxy.password"); err != nil {
+		return err
+	} else if err, mod.intercept = mod.BoolParam("socks5.proxy.intercept"); err != nil {
+		return err
+	} else if err, scriptPath = mod.StringParam("socks5.proxy.script"); err != nil {
+		return err
+	} else if err, jsToInject = mod.StringParam("socks5.proxy.injectjs"); err != nil {
+		return err
+	} else if err, rulesFile = mod.StringParam("socks5.proxy.rules"); err != nil {
+		return err
+	} else if err, stream = mod.BoolParam("socks5.proxy.stream"); err != nil {
+		return err
+	} else if err, storageFile = mod.StringParam("socks5.proxy.storage"); err != nil {
+		return err
+	} else if err, blacklist = mod.StringParam("socks5.proxy.blacklist"); err != nil {
+		return err
+	} else if err, whitelist = mod.StringParam("socks5.proxy.whitelist"); err != nil {
+		return err
+	} else if err, certFile = mod.StringParam("socks5.proxy.certificate"); err != nil {
+		return err
+	} else if certFile, err = fs.Expand(certFile); err != nil {
+		return err
+	} else if err, keyFile = mod.StringParam("socks5.proxy.key"); err != nil {
+		return err
+	} else if keyFile, err = fs.Expand(keyFile); err != nil {
+		return err
+	} else if len(mod.username) > 255 || len(mod.password) > 255 {
+		return fmt.Errorf("socks5.proxy.username and socks5.proxy.password can't be longer than 255 characters")
+	}
+
+	if mod.intercept {
+		if !fs.Exists(certFile) || !fs.Exists(keyFile) {
+			cfg, err := tls.CertConfigFromModule("socks5.proxy", mod.SessionModule)
+			if err != nil {
+				return err
+			}
+
+			mod.Debug("%+v", cfg)
+			mod.Info("generating proxy certification authority TLS key to %s", keyFile)
+			mod.Info("generating proxy certification authority TLS certificate to %s", certFile)
+			if err := tls.Generate(cfg, certFile, keyFile, true); err != nil {
+				return err
+			}
+		}
+
+		mod.proxy.Blacklist = str.Comma(blacklist)
+		mod.proxy.Whitelist = str.Comma(whitelist)
+		mod.proxy.Stream = stream
+		mod.proxy.RulesFile = rulesFile
+		mod.proxy.StorageFile = storageFile
+
+		// clients are configured to use us, no redirection is needed
+		if err = mod.proxy.ConfigureTLS(address, port, 0, false, scriptPath, certFile, keyFile, jsToInject, false); err != nil {
+			return err
+		}
+	}
+
+	if mod.listener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", address, port)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// sniff returns the connection to use from now on and whether it carries
+// HTTP or TLS traffic.
+func (mod *Socks5Proxy) sniff(conn net.Conn) (net.Conn, bool, bool) {
+	head := make([]byte, 4)
+
+	conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+	n, _ := io.ReadFull(conn, head)
+	conn.SetReadDeadline(time.Time{})
+
+	head = head[:n]
+	sniffed := &sniffedConn{Conn: conn, head: head}
+
+	if len(head) > 0 && head[0] == 0x16 {
+		return sniffed, false, true
+	}
+	for _, method := range httpMethods {
+		if bytes.Equal(head, method) {
+			return sniffed, true, false
+		}
+	}
+	return sniffed, false, false
+}
+
+func (mod *Socks5Proxy) tunnel(client, remote net.Conn) {
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+
+	pipe := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		// unblock the other direction
+		if tcp, ok := dst.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+
+	go pipe(remote, client)
+	go pipe(client, remote)
+
+	wg.Wait()
+}
+
+func (mod *Socks5Proxy) handleConnection(conn net.Conn) {
+	mod.conns.Store(conn, true)
+	defer func(c net.Conn) {
+		mod.conns.Delete(c)
+		c.Close()
+	}(conn)
+
+	client := tui.Bold(conn.RemoteAddr().String())
+
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	if err := mod.negotiate(conn); err != nil {
+		mod.Warning("%s: %v", client, err)
+		return
+	}
+
+	req, err := mod.readRequest(conn)
+	if err != nil {
+		mod.Warning("%s: %v", client, err)
+		return
+	} else if req.command != cmdConnect {
+		mod.reply(conn, replyCommandNotSupported, nil)
+		mod.Warning("%s: unsupported command %d", client, req.command)
+		return
+	}
+
+	remote, err := net.DialTimeout("tcp", req.Address(), dialTimeout)
+	if err != nil {
+		mod.reply(conn, replyHostUnreachable, nil)
+		mod.Debug("%s: error connecting to %s: %v", client, req.Address(), err)
+		return
+	}
+	defer remote.Close()
+
+	if err := mod.reply(conn, replySuccess, remote.LocalAddr()); err != nil {
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	if mod.intercept {
+		sniffed, isHTTP, isTLS := mod.sniff(conn)
+		if isHTTP || isTLS {
+			// the http proxy connects to the destination by itself
+			remote.Close()
+			mod.Debug("intercepting %s -> %s", client, tui.Yellow(req.Address()))
+			mod.proxy.ServeConn(sniffed, req.host, req.port, isTLS)
+			return
+		}
+		conn = sniffed
+	}
+
+	mod.Debug("tunneling %s -> %s", client, tui.Yellow(req.Address()))
+	mod.tunnel(conn, remote)
+}
+
+func (mod *Socks5Proxy) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.Info("started on %s", mod.listener.Addr())
+
+		for mod.Running() {
+			conn, err := mod.listener.Accept()
+			if err != nil {
+				if mod.Running() {
+					mod.Warning("error while accepting connection: %s", err)
+				}
+				continue
+			}
+
+			go mod.handleConnection(conn)
+		}
+	})
+}
+
+func (mod *Socks5Proxy) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.listener.Close()
+		mod.conns.Range(func(k, v interface{}) bool {
+			k.(net.Conn).Close()
+			return true
+		})
+		if mod.intercept {
+			mod.Session.UnkCmdCallback = nil
+		}
+	})
+}
@@ -0,0 +1,253 @@
+package spoof_stats
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+type SpoofStats struct {
+	session.SessionModule
+	Handle        *pcap.Handle
+	victims       map[string]*Victim
+	victimsLock   *sync.Mutex
+	top           int
+	interval      time.Duration
+	waitGroup     *sync.WaitGroup
+	pktSourceChan chan gopacket.Packet
+}
+
+func NewSpoofStats(s *session.Session) *SpoofStats {
+	mod := &SpoofStats{
+		SessionModule: session.NewSessionModule("spoof.stats", s),
+		victims:       make(map[string]*Victim),
+		victimsLock:   &sync.Mutex{},
+		waitGroup:     &sync.WaitGroup{},
+	}
+
+	mod.State.Store("victims", []*Victim{})
+
+	mod.AddParam(session.NewIntParameter("spoof.stats.top",
+		"5",
+		"Number of top destinations to show for each victim."))
+
+	mod.AddParam(session.NewIntParameter("spoof.stats.interval",
+		"30",
+		"Seconds between spoof.stats.update events, 0 to disable them."))
+
+	mod.AddHandler(session.NewModuleHandler("spoof.stats on", "",
+		"Start counting the traffic forwarded for the victims of arp.spoof and ndp.spoof.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("spoof.stats off", "",
+		"Stop counting the traffic of the victims.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("spoof.stats", "",
+		"Show the traffic forwarded for each victim and its top destinations.",
+		func(args []string) error {
+			return mod.showStats()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("spoof.stats.clear", "",
+		"Clear the traffic statistics.",
+		func(args []string) error {
+			mod.victimsLock.Lock()
+			mod.victims = make(map[string]*Victim)
+			mod.updateState()
+			mod.victimsLock.Unlock()
+			return nil
+		}))
+
+	return mod
+}
+
+func (mod SpoofStats) Name() string {
+	return "spoof.stats"
+}
+
+func (mod SpoofStats) Description() string {
+	return "Counts the packets, bytes and destinations of the traffic forwarded for the victims of arp.spoof and ndp.spoof, to confirm the interception is working."
+}
+
+func (mod SpoofStats) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *SpoofStats) Configure() error {
+	var err error
+	var interval int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, mod.top = mod.IntParam("spoof.stats.top"); err != nil {
+		return err
+	} else if err, interval = mod.IntParam("spoof.stats.interval"); err != nil {
+		return err
+	} else if mod.top <= 0 {
+		return fmt.Errorf("spoof.stats.top must be greater than 0")
+	} else if interval < 0 {
+		return fmt.Errorf("spoof.stats.interval can't be negative")
+	}
+
+	mod.interval = time.Duration(interval) * time.Second
+
+	// packets sent to our mac address but not to any of our addresses are
+	// the ones we're forwarding
+	iface := mod.Session.Interface
+	filter := fmt.Sprintf("ether dst %s and not host %s", iface.HW, iface.IP)
+	if iface.IPv6 != nil {
+		filter += fmt.Sprintf(" and not host %s", iface.IPv6)
+	}
+
+	if mod.Handle, err = network.Capture(iface.Name()); err != nil {
+		return err
+	} else if err = mod.Handle.SetBPFFilter(filter); err != nil {
+		mod.Handle.Close()
+		return err
+	}
+
+	return nil
+}
+
+func (mod *SpoofStats) spoofing() bool {
+	return mod.Session.IsOn("arp.spoof") || mod.Session.IsOn("ndp.spoof")
+}
+
+func (mod *SpoofStats) isVictim(ip net.IP) bool {
+	if mod.Session.Skip(ip) || ip.IsMulticast() {
+		return false
+	} else if subnet := mod.Session.Interface.Net; subnet != nil && subnet.Contains(ip) {
+		return true
+	}
+	return mod.Session.Lan.GetByIp(ip.String()) != nil
+}
+
+func (mod *SpoofStats) onPacket(pkt gopacket.Packet) {
+	var src, dst net.IP
+	if ip4, ok := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4); ok {
+		src, dst = ip4.SrcIP, ip4.DstIP
+	} else if ip6, ok := pkt.Layer(layers.LayerTypeIPv6).(*layers.IPv6); ok {
+		src, dst = ip6.SrcIP, ip6.DstIP
+	} else {
+		return
+	}
+
+	eth, _ := pkt.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	size := uint64(len(pkt.Data()))
+
+	if mod.isVictim(src) {
+		var hw net.HardwareAddr
+		if eth != nil {
+			hw = eth.SrcMAC
+		}
+		mod.track(src, hw, dst, size, true)
+	} else if mod.isVictim(dst) {
+		mod.track(dst, nil, src, size, false)
+	}
+}
+
+func (mod *SpoofStats) track(victim net.IP, hw net.HardwareAddr, remote net.IP, size uint64, sent bool) {
+	mod.victimsLock.Lock()
+	defer mod.victimsLock.Unlock()
+
+	v, found := mod.victims[victim.String()]
+	if !found {
+		v = NewVictim(victim)
+		mod.victims[victim.String()] = v
+	}
+
+	if v.MAC == "" && hw != nil {
+		v.MAC = hw.String()
+	}
+	if e := mod.Session.Lan.GetByIp(v.IP); e != nil {
+		v.MAC = e.HwAddress
+		v.Hostname = e.Hostname
+		if e.Alias != "" {
+			v.Hostname = e.Alias
+		}
+	}
+
+	v.Track(remote.String(), size, sent)
+
+	if !found {
+		session.I.Events.Add("spoof.stats.new", *v.Copy(mod.top))
+	}
+}
+
+// must be called with the victims lock held.
+func (mod *SpoofStats) updateState() {
+	mod.State.Store("victims", mod.sortedVictims())
+}
+
+func (mod *SpoofStats) reporter() {
+	defer mod.waitGroup.Done()
+
+	if mod.interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(mod.interval)
+	defer ticker.Stop()
+
+	for mod.Running() {
+		<-ticker.C
+
+		mod.victimsLock.Lock()
+		victims := mod.sortedVictims()
+		mod.State.Store("victims", victims)
+		mod.victimsLock.Unlock()
+
+		for _, v := range victims {
+			if time.Since(v.LastSeen) < mod.interval {
+				session.I.Events.Add("spoof.stats.update", *v)
+			}
+		}
+	}
+}
+
+func (mod *SpoofStats) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		if !mod.spoofing() {
+			mod.Warning("neither arp.spoof nor ndp.spoof are running, traffic will be counted once they are started")
+		}
+
+		mod.waitGroup.Add(2)
+		defer mod.waitGroup.Done()
+		go mod.reporter()
+
+		src := gopacket.NewPacketSource(mod.Handle, mod.Handle.LinkType())
+		mod.pktSourceChan = src.Packets()
+		for packet := range mod.pktSourceChan {
+			if !mod.Running() {
+				break
+			} else if mod.spoofing() {
+				mod.onPacket(packet)
+			}
+		}
+	})
+}
+
+func (mod *SpoofStats) Stop() error {
+	return mod.SetRunning(false, func() {
+		mod.pktSourceChan <- nil
+		mod.Handle.Close()
+		mod.waitGroup.Wait()
+	})
+}
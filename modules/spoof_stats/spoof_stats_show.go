@@ -0,0 +1,143 @@
+package spoof_stats
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+type Destination struct {
+	Address string `json:"address"`
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+type Victim struct {
+	IP           string         `json:"ip"`
+	MAC          string         `json:"mac"`
+	Hostname     string         `json:"hostname"`
+	SentPackets  uint64         `json:"sent_packets"`
+	SentBytes    uint64         `json:"sent_bytes"`
+	RecvPackets  uint64         `json:"received_packets"`
+	RecvBytes    uint64         `json:"received_bytes"`
+	FirstSeen    time.Time      `json:"first_seen"`
+	LastSeen     time.Time      `json:"last_seen"`
+	Destinations []*Destination `json:"destinations"`
+
+	destinations map[string]*Destination
+}
+
+func NewVictim(ip net.IP) *Victim {
+	now := time.Now()
+	return &Victim{
+		IP:           ip.String(),
+		FirstSeen:    now,
+		LastSeen:     now,
+		Destinations: make([]*Destination, 0),
+		destinations: make(map[string]*Destination),
+	}
+}
+
+func (v *Victim) Track(remote string, size uint64, sent bool) {
+	v.LastSeen = time.Now()
+	if sent {
+		v.SentPackets++
+		v.SentBytes += size
+	} else {
+		v.RecvPackets++
+		v.RecvBytes += size
+	}
+
+	d, found := v.destinations[remote]
+	if !found {
+		d = &Destination{Address: remote}
+		v.destinations[remote] = d
+	}
+	d.Packets++
+	d.Bytes += size
+}
+
+// Copy returns a snapshot of the victim with its top destinations only.
+func (v *Victim) Copy(top int) *Victim {
+	c := *v
+	c.destinations = nil
+	c.Destinations = make([]*Destination, 0, len(v.destinations))
+	for _, d := range v.destinations {
+		dc := *d
+		c.Destinations = append(c.Destinations, &dc)
+	}
+
+	sort.Slice(c.Destinations, func(i, j int) bool {
+		return c.Destinations[i].Bytes > c.Destinations[j].Bytes
+	})
+	if len(c.Destinations) > top {
+		c.Destinations = c.Destinations[:top]
+	}
+	return &c
+}
+
+// must be called with the victims lock held.
+func (mod *SpoofStats) sortedVictims() []*Victim {
+	victims := make([]*Victim, 0, len(mod.victims))
+	for _, v := range mod.victims {
+		victims = append(victims, v.Copy(mod.top))
+	}
+
+	sort.Slice(victims, func(i, j int) bool {
+		return victims[i].SentBytes+victims[i].RecvBytes > victims[j].SentBytes+victims[j].RecvBytes
+	})
+	return victims
+}
+
+func (mod *SpoofStats) showStats() error {
+	err, top := mod.IntParam("spoof.stats.top")
+	if err != nil {
+		return err
+	}
+
+	mod.victimsLock.Lock()
+	if top > 0 {
+		mod.top = top
+	}
+	mod.updateState()
+	victims := mod.sortedVictims()
+	mod.victimsLock.Unlock()
+
+	if len(victims) == 0 {
+		mod.Info("no traffic forwarded for any victim yet")
+		return nil
+	}
+
+	colNames := []string{"Victim", "MAC", "Sent", "Received", "Last Seen", "Top Destinations"}
+	rows := make([][]string, 0, len(victims))
+	for _, v := range victims {
+		who := tui.Bold(v.IP)
+		if v.Hostname != "" {
+			who = fmt.Sprintf("%s (%s)", who, tui.Yellow(v.Hostname))
+		}
+
+		top := make([]string, len(v.Destinations))
+		for i, d := range v.Destinations {
+			top[i] = fmt.Sprintf("%s %s", d.Address, tui.Dim(humanize.Bytes(d.Bytes)))
+		}
+
+		rows = append(rows, []string{
+			who,
+			v.MAC,
+			fmt.Sprintf("%s (%d pkts)", humanize.Bytes(v.SentBytes), v.SentPackets),
+			fmt.Sprintf("%s (%d pkts)", humanize.Bytes(v.RecvBytes), v.RecvPackets),
+			v.LastSeen.Format("15:04:05"),
+			strings.Join(top, ", "),
+		})
+	}
+
+	tui.Table(mod.Session.Events.Stdout, colNames, rows)
+	mod.Session.Refresh()
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/bettercap/bettercap/network"
+
 	"github.com/evilsocket/islazy/async"
 )
 
@@ -13,12 +15,25 @@ type bannerGrabberFn func(mod *SynScanner, ip string, port int) string
 
 type grabberJob struct {
 	IP   string
+	Host *network.Endpoint
 	Port *OpenPort
+	// true if the port was already open in a previous scan
+	Known bool
+}
+
+// serviceChanged returns true if a port answered with a different banner,
+// service or certificate than the last time it has been grabbed.
+func serviceChanged(previous *OpenPort, current *OpenPort) bool {
+	return previous.Banner != current.Banner ||
+		previous.Service != current.Service ||
+		previous.Product != current.Product ||
+		previous.Version != current.Version ||
+		previous.Cert != current.Cert
 }
 
 func (mod *SynScanner) bannerGrabber(arg async.Job) {
 	job := arg.(grabberJob)
-	if job.Port.Proto != "tcp" {
+	if !mod.banners || job.Port.Proto != "tcp" {
 		return
 	}
 
@@ -33,9 +48,35 @@ func (mod *SynScanner) bannerGrabber(arg async.Job) {
 		fn = dnsGrabber
 	}
 
+	previous := *job.Port
+
 	mod.Debug("grabbing banner for %s:%d", ip, port)
-	job.Port.Banner = fn(mod, ip, port)
-	if job.Port.Banner != "" {
+	// a port that doesn't answer this time keeps what has been grabbed before
+	if banner := fn(mod, ip, port); banner != "" {
+		job.Port.Banner = banner
 		mod.Info("found banner for %s:%d -> %s", ip, port, job.Port.Banner)
+
+		if service, product, version := detectVersion(job.Port.Banner); service != "" {
+			if job.Port.Service == "" {
+				job.Port.Service = service
+			}
+			job.Port.Product = product
+			job.Port.Version = version
+			if product != "" {
+				mod.Info("%s:%d is running %s %s", ip, port, product, version)
+			}
+		}
+	}
+
+	if tlsPorts[port] {
+		if cert := tlsGrabber(mod, ip, port); cert != "" {
+			job.Port.Cert = cert
+			mod.Info("found certificate for %s:%d -> %s", ip, port, job.Port.Cert)
+		}
+	}
+
+	// nothing to compare with if the port had never been grabbed before
+	if job.Known && job.Host != nil && previous.Banner+previous.Cert != "" && serviceChanged(&previous, job.Port) {
+		NewPortChangeEvent(ip, job.Host, job.Port).WithPrevious(&previous).Push("endpoint.port.changed")
 	}
 }
@@ -86,6 +86,9 @@ func httpGrabber(mod *SynScanner, ip string, port int) string {
 	}
 
 	if title := searchForTitle(doc); title != "" {
+		if fallback != "" {
+			return fmt.Sprintf("%s (%s)", title, fallback)
+		}
 		return title
 	}
 
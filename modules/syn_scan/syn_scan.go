@@ -3,6 +3,7 @@ package syn_scan
 import (
 	"fmt"
 	"net"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,20 +18,33 @@ import (
 	"github.com/google/gopacket/pcap"
 )
 
-const synSourcePort = 666
+const (
+	synSourcePort = 666
+	// how long to wait for late responses once all probes have been sent
+	synResponseGrace = time.Duration(1) * time.Second
+	// above this the interval between two probes would round to zero
+	synMaxRate = 1000000
+)
 
 type synScannerStats struct {
-	numPorts     uint64
-	numAddresses uint64
-	totProbes    uint64
-	doneProbes   uint64
-	openPorts    uint64
-	started      time.Time
+	numPorts      uint64
+	numAddresses  uint64
+	totProbes     uint64
+	doneProbes    uint64
+	openPorts     uint64
+	closedPorts   uint64
+	filteredPorts uint64
+	started       time.Time
 }
 
 type SynScanner struct {
 	session.SessionModule
 	addresses     []net.IP
+	proto         string
+	banners       bool
+	rate          int
+	parallel      int
+	limiter       *time.Ticker
 	startPort     int
 	endPort       int
 	handle        *pcap.Handle
@@ -38,7 +52,6 @@ type SynScanner struct {
 	progressEvery time.Duration
 	stats         synScannerStats
 	waitGroup     *sync.WaitGroup
-	scanQueue     *async.WorkQueue
 	bannerQueue   *async.WorkQueue
 }
 
@@ -50,12 +63,28 @@ func NewSynScanner(s *session.Session) *SynScanner {
 		progressEvery: time.Duration(1) * time.Second,
 	}
 
-	mod.scanQueue = async.NewQueue(0, mod.scanWorker)
 	mod.bannerQueue = async.NewQueue(0, mod.bannerGrabber)
 
 	mod.State.Store("scanning", &mod.addresses)
 	mod.State.Store("progress", 0.0)
 
+	mod.AddParam(session.NewStringParameter("syn.scan.proto",
+		"tcp",
+		"^(tcp|udp)$",
+		"Protocol to scan, tcp for SYN scanning or udp for UDP scanning with protocol aware probes."))
+
+	mod.AddParam(session.NewBoolParameter("syn.scan.banners",
+		"true",
+		"If true, connect to every open TCP port to grab its banner, TLS certificate and detect the service version."))
+
+	mod.AddParam(session.NewIntParameter("syn.scan.rate",
+		"500",
+		fmt.Sprintf("Maximum number of probes per second sent by the scanner, from 1 to %d.", synMaxRate)))
+
+	mod.AddParam(session.NewIntParameter("syn.scan.parallel",
+		"0",
+		"Number of hosts to scan in parallel, 0 to use the number of logical CPUs."))
+
 	mod.AddParam(session.NewIntParameter("syn.scan.show-progress-every",
 		"1",
 		"Period in seconds for the scanning progress reporting."))
@@ -96,6 +125,12 @@ func NewSynScanner(s *session.Session) *SynScanner {
 			return mod.showProgress()
 		}))
 
+	mod.AddHandler(session.NewModuleHandler("syn.scan.report FILENAME", "syn\\.scan\\.report (.+)",
+		"Save the scan results to FILENAME in nmap XML format.",
+		func(args []string) error {
+			return mod.writeReport(args[0])
+		}))
+
 	return mod
 }
 
@@ -104,7 +139,7 @@ func (mod *SynScanner) Name() string {
 }
 
 func (mod *SynScanner) Description() string {
-	return "A module to perform SYN port scanning."
+	return "A module to perform SYN and UDP port scanning."
 }
 
 func (mod *SynScanner) Author() string {
@@ -114,16 +149,36 @@ func (mod *SynScanner) Author() string {
 func (mod *SynScanner) Configure() (err error) {
 	if mod.Running() {
 		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, mod.proto = mod.StringParam("syn.scan.proto"); err != nil {
+		return err
+	} else if err, mod.banners = mod.BoolParam("syn.scan.banners"); err != nil {
+		return err
+	} else if err, mod.rate = mod.IntParam("syn.scan.rate"); err != nil {
+		return err
+	} else if mod.rate <= 0 || mod.rate > synMaxRate {
+		return fmt.Errorf("syn.scan.rate must be between 1 and %d", synMaxRate)
+	} else if err, mod.parallel = mod.IntParam("syn.scan.parallel"); err != nil {
+		return err
 	}
+
+	if mod.parallel <= 0 {
+		mod.parallel = runtime.NumCPU()
+	}
+
 	if mod.handle == nil {
 		if mod.handle, err = network.Capture(mod.Session.Interface.Name()); err != nil {
 			return err
-		} else if err = mod.handle.SetBPFFilter(fmt.Sprintf("tcp dst port %d", synSourcePort)); err != nil {
-			return err
 		}
 		mod.packets = gopacket.NewPacketSource(mod.handle, mod.handle.LinkType()).Packets()
 	}
-	return nil
+
+	filter := fmt.Sprintf("tcp dst port %d", synSourcePort)
+	if mod.proto == "udp" {
+		// we also need ICMP to tell closed ports from filtered ones
+		filter = fmt.Sprintf("udp dst port %d or icmp or icmp6", synSourcePort)
+	}
+
+	return mod.handle.SetBPFFilter(filter)
 }
 
 func (mod *SynScanner) Start() error {
@@ -140,10 +195,20 @@ func plural(n uint64) string {
 func (mod *SynScanner) showProgress() error {
 	progress := 100.0 * (float64(mod.stats.doneProbes) / float64(mod.stats.totProbes))
 	mod.State.Store("progress", progress)
-	mod.Info("[%.2f%%] found %d open port%s for %d address%s, sent %d/%d packets in %s",
+
+	NewSynScanProgressEvent(mod.proto, progress, &mod.stats).Push()
+
+	closed := ""
+	if mod.proto == "udp" {
+		closed = fmt.Sprintf(" (%d closed, %d filtered)", mod.stats.closedPorts, mod.stats.filteredPorts)
+	}
+
+	mod.Info("[%.2f%%] found %d open %s port%s%s for %d address%s, sent %d/%d packets in %s",
 		progress,
 		mod.stats.openPorts,
+		mod.proto,
 		plural(mod.stats.openPorts),
+		closed,
 		mod.stats.numAddresses,
 		plural(mod.stats.numAddresses),
 		mod.stats.doneProbes,
@@ -181,19 +246,28 @@ func (mod *SynScanner) scanWorker(job async.Job) {
 
 		atomic.AddUint64(&mod.stats.doneProbes, 1)
 
-		err, raw := packets.NewTCPSyn(fromIP, fromHW, scan.Address, scan.Mac, synSourcePort, dstPort)
+		if mod.limiter != nil {
+			<-mod.limiter.C
+		}
+
+		var err error
+		var raw []byte
+		if mod.proto == "udp" {
+			err, raw = packets.NewUDPPacket(fromIP, fromHW, scan.Address, scan.Mac, synSourcePort, dstPort, udpPayloadFor(dstPort))
+		} else {
+			err, raw = packets.NewTCPSyn(fromIP, fromHW, scan.Address, scan.Mac, synSourcePort, dstPort)
+		}
+
 		if err != nil {
-			mod.Error("error creating SYN packet: %s", err)
+			mod.Error("error creating %s probe: %s", mod.proto, err)
 			continue
 		}
 
 		if err := mod.Session.Queue.Send(raw); err != nil {
-			mod.Error("error sending SYN packet: %s", err)
+			mod.Error("error sending %s probe: %s", mod.proto, err)
 		} else {
-			mod.Debug("sent %d bytes of SYN packet to %s for port %d", len(raw), scan.Address.String(), dstPort)
+			mod.Debug("sent %d bytes of %s probe to %s for port %d", len(raw), mod.proto, scan.Address.String(), dstPort)
 		}
-
-		time.Sleep(time.Duration(15) * time.Millisecond)
 	}
 }
 
@@ -215,6 +289,8 @@ func (mod *SynScanner) synScan() error {
 		})
 
 		mod.stats.openPorts = 0
+		mod.stats.closedPorts = 0
+		mod.stats.filteredPorts = 0
 		mod.stats.numPorts = uint64(mod.endPort - mod.startPort + 1)
 		mod.stats.started = time.Now()
 		mod.stats.numAddresses = uint64(len(mod.addresses))
@@ -226,13 +302,19 @@ func (mod *SynScanner) synScan() error {
 		}
 
 		if mod.stats.numPorts > 1 {
-			mod.Info("scanning %d address%s from port %d to port %d ...", mod.stats.numAddresses, plural, mod.startPort, mod.endPort)
+			mod.Info("scanning %d address%s from %s port %d to port %d ...", mod.stats.numAddresses, plural, mod.proto, mod.startPort, mod.endPort)
 		} else {
-			mod.Info("scanning %d address%s on port %d ...", mod.stats.numAddresses, plural, mod.startPort)
+			mod.Info("scanning %d address%s on %s port %d ...", mod.stats.numAddresses, plural, mod.proto, mod.startPort)
 		}
 
 		mod.State.Store("progress", 0.0)
 
+		mod.limiter = time.NewTicker(time.Second / time.Duration(mod.rate))
+		defer func() {
+			mod.limiter.Stop()
+			mod.limiter = nil
+		}()
+
 		// start the collector
 		mod.waitGroup.Add(1)
 		go func() {
@@ -258,25 +340,46 @@ func (mod *SynScanner) synScan() error {
 			}
 		}()
 
-		// start sending SYN packets and wait
+		// start the workers
+		jobs := make(chan async.Job)
+		workers := &sync.WaitGroup{}
+		for i := 0; i < mod.parallel; i++ {
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				for job := range jobs {
+					mod.scanWorker(job)
+				}
+			}()
+		}
+
+		// start sending probes and wait
 		for _, address := range mod.addresses {
 			if !mod.Running() {
 				break
 			}
-			mac, err := mod.Session.FindMAC(address, true)
+			// hosts on other subnets are reached through the gateway
+			mac, err := mod.Session.NextHopMAC(address, true)
 			if err != nil {
 				atomic.AddUint64(&mod.stats.doneProbes, mod.stats.numPorts)
 				mod.Debug("could not get MAC for %s: %s", address.String(), err)
 				continue
 			}
 
-			mod.scanQueue.Add(async.Job(scanJob{
+			jobs <- async.Job(scanJob{
 				Address: address,
 				Mac:     mac,
-			}))
+			})
 		}
 
-		mod.scanQueue.WaitDone()
+		close(jobs)
+		workers.Wait()
+
+		// an interrupted scan can't tell us which ports have been closed
+		if mod.Running() {
+			time.Sleep(synResponseGrace)
+			mod.trackChanges()
+		}
 	})
 
 	return nil
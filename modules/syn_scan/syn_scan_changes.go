@@ -0,0 +1,44 @@
+package syn_scan
+
+import (
+	"time"
+)
+
+// PortsMetaName returns the endpoint meta key where open ports of proto are stored.
+func PortsMetaName(proto string) string {
+	// keep tcp ports where they've always been for backwards compatibility
+	if proto != "tcp" {
+		return proto + "-ports"
+	}
+	return "ports"
+}
+
+func scannedMetaName(proto string) string {
+	return PortsMetaName(proto) + ":scanned"
+}
+
+// compare the results of a completed scan with the previous ones, ports in
+// the scanned range that didn't answer this time have been closed.
+func (mod *SynScanner) trackChanges() {
+	metaName := PortsMetaName(mod.proto)
+	now := time.Now()
+
+	for _, address := range mod.addresses {
+		host := mod.hostFor(address)
+		if host == nil {
+			continue
+		}
+
+		if ports, ok := host.Meta.Get(metaName).(map[int]*OpenPort); ok {
+			for port, open := range ports {
+				if port >= mod.startPort && port <= mod.endPort && open.LastSeen.Before(mod.stats.started) {
+					delete(ports, port)
+					NewPortChangeEvent(address.String(), host, open).Push("endpoint.port.closed")
+				}
+			}
+			host.Meta.Set(metaName, ports)
+		}
+
+		host.Meta.Set(scannedMetaName(mod.proto), now)
+	}
+}
@@ -1,6 +1,8 @@
 package syn_scan
 
 import (
+	"sync/atomic"
+
 	"github.com/bettercap/bettercap/network"
 	"github.com/bettercap/bettercap/session"
 )
@@ -8,13 +10,15 @@ import (
 type SynScanEvent struct {
 	Address string
 	Host    *network.Endpoint
+	Proto   string
 	Port    int
 }
 
-func NewSynScanEvent(address string, h *network.Endpoint, port int) SynScanEvent {
+func NewSynScanEvent(address string, h *network.Endpoint, proto string, port int) SynScanEvent {
 	return SynScanEvent{
 		Address: address,
 		Host:    h,
+		Proto:   proto,
 		Port:    port,
 	}
 }
@@ -23,3 +27,53 @@ func (e SynScanEvent) Push() {
 	session.I.Events.Add("syn.scan", e)
 	session.I.Refresh()
 }
+
+type SynScanProgressEvent struct {
+	Proto     string
+	Progress  float64
+	Addresses uint64
+	OpenPorts uint64
+	Sent      uint64
+	Total     uint64
+}
+
+func NewSynScanProgressEvent(proto string, progress float64, stats *synScannerStats) SynScanProgressEvent {
+	return SynScanProgressEvent{
+		Proto:     proto,
+		Progress:  progress,
+		Addresses: atomic.LoadUint64(&stats.numAddresses),
+		OpenPorts: atomic.LoadUint64(&stats.openPorts),
+		Sent:      atomic.LoadUint64(&stats.doneProbes),
+		Total:     atomic.LoadUint64(&stats.totProbes),
+	}
+}
+
+func (e SynScanProgressEvent) Push() {
+	session.I.Events.Add("syn.scan.progress", e)
+}
+
+type PortChangeEvent struct {
+	Address string
+	Host    *network.Endpoint
+	Port    *OpenPort
+	// what the port was running before, only set for endpoint.port.changed
+	Previous *OpenPort
+}
+
+func NewPortChangeEvent(address string, h *network.Endpoint, port *OpenPort) PortChangeEvent {
+	return PortChangeEvent{
+		Address: address,
+		Host:    h,
+		Port:    port,
+	}
+}
+
+func (e PortChangeEvent) WithPrevious(previous *OpenPort) PortChangeEvent {
+	e.Previous = previous
+	return e
+}
+
+func (e PortChangeEvent) Push(tag string) {
+	session.I.Events.Add(tag, e)
+	session.I.Refresh()
+}
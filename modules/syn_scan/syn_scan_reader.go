@@ -1,7 +1,9 @@
 package syn_scan
 
 import (
+	"net"
 	"sync/atomic"
+	"time"
 
 	"github.com/bettercap/bettercap/network"
 
@@ -12,95 +14,123 @@ import (
 )
 
 type OpenPort struct {
-	Proto   string `json:"proto"`
-	Banner  string `json:"banner"`
-	Service string `json:"service"`
-	Port    int    `json:"port"`
+	Proto     string    `json:"proto"`
+	Banner    string    `json:"banner"`
+	Service   string    `json:"service"`
+	Product   string    `json:"product"`
+	Version   string    `json:"version"`
+	Cert      string    `json:"cert"`
+	Port      int       `json:"port"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
 }
 
-func (mod *SynScanner) onPacket(pkt gopacket.Packet) {
-	if pkt == nil || pkt.Data() == nil {
-		return
-	}
-
-	var eth layers.Ethernet
-	var ip4 layers.IPv4
-	var ip6 layers.IPv6
-	var tcp layers.TCP
-
-
-
-	isIPv6 := false
-	foundLayerTypes := []gopacket.LayerType{}
-	parser := gopacket.NewDecodingLayerParser(
-		layers.LayerTypeEthernet,
-		&eth,
-		&ip4,
-		&tcp,
-	)
-
-	err := parser.DecodeLayers(pkt.Data(), &foundLayerTypes)
-	if err != nil {
-		// try ipv6
-		parser := gopacket.NewDecodingLayerParser(
-			layers.LayerTypeEthernet,
-			&eth,
-			&ip6,
-			&tcp,
-		)
-		err = parser.DecodeLayers(pkt.Data(), &foundLayerTypes)
-		if err != nil {
-			return
+func (mod *SynScanner) hostFor(ip net.IP) *network.Endpoint {
+	if ip.To4() == nil {
+		if ip.Equal(mod.Session.Interface.IPv6) {
+			return mod.Session.Interface
+		} else if ip.Equal(mod.Session.Gateway.IPv6) {
+			return mod.Session.Gateway
+		}
+	} else {
+		if ip.Equal(mod.Session.Interface.IP) {
+			return mod.Session.Interface
+		} else if ip.Equal(mod.Session.Gateway.IP) {
+			return mod.Session.Gateway
 		}
-		isIPv6 = true
 	}
+	return mod.Session.Lan.GetByIp(ip.String())
+}
 
-	if tcp.DstPort == synSourcePort && tcp.SYN && tcp.ACK {
-		atomic.AddUint64(&mod.stats.openPorts, 1)
+func (mod *SynScanner) onOpenPort(from net.IP, proto string, port int) {
+	atomic.AddUint64(&mod.stats.openPorts, 1)
 
-		port := int(tcp.SrcPort)
+	now := time.Now()
+	openPort := &OpenPort{
+		Proto:     proto,
+		Port:      port,
+		Service:   network.GetServiceByPort(port, proto),
+		FirstSeen: now,
+		LastSeen:  now,
+	}
 
-		openPort := &OpenPort{
-			Proto:   "tcp",
-			Port:    port,
-			Service: network.GetServiceByPort(port, "tcp"),
+	address := from.String()
+	host := mod.hostFor(from)
+	known := false
+	if host != nil {
+		metaName := PortsMetaName(proto)
+		ports := host.Meta.GetOr(metaName, map[int]*OpenPort{}).(map[int]*OpenPort)
+		if previous, found := ports[port]; found {
+			previous.LastSeen = now
+			openPort = previous
+			known = true
+		} else {
+			ports[port] = openPort
+			// only a change if this host has been scanned before
+			if host.Meta.GetOr(scannedMetaName(proto), nil) != nil {
+				NewPortChangeEvent(address, host, openPort).Push("endpoint.port.new")
+			}
 		}
+		host.Meta.Set(metaName, ports)
+	}
 
-		var host *network.Endpoint
-
-		from := ""
+	mod.bannerQueue.Add(async.Job(grabberJob{
+		IP:    address,
+		Host:  host,
+		Port:  openPort,
+		Known: known,
+	}))
 
-		if isIPv6 {
-			from = ip6.SrcIP.String()
-			if ip6.SrcIP.Equal(mod.Session.Interface.IPv6) {
-				host = mod.Session.Interface
-			} else if ip6.SrcIP.Equal(mod.Session.Gateway.IPv6) {
-				host = mod.Session.Gateway
-			} else {
-				host = mod.Session.Lan.GetByIp(from)
-			}
-		} else {
-			from = ip4.SrcIP.String()
+	NewSynScanEvent(address, host, proto, port).Push()
+}
 
-			if ip4.SrcIP.Equal(mod.Session.Interface.IP) {
-				host = mod.Session.Interface
-			} else if ip4.SrcIP.Equal(mod.Session.Gateway.IP) {
-				host = mod.Session.Gateway
+// interpret ICMP destination unreachable messages for our UDP probes,
+// port unreachable means closed, anything else means filtered.
+func (mod *SynScanner) onUnreachable(from net.IP, portUnreachable bool, payload []byte, first gopacket.LayerType) {
+	original := gopacket.NewPacket(payload, first, gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+	if ludp := original.Layer(layers.LayerTypeUDP); ludp != nil {
+		if udp := ludp.(*layers.UDP); udp.SrcPort == synSourcePort {
+			if portUnreachable {
+				atomic.AddUint64(&mod.stats.closedPorts, 1)
+				mod.Debug("udp port %d of %s is closed", udp.DstPort, from)
 			} else {
-				host = mod.Session.Lan.GetByIp(from)
+				atomic.AddUint64(&mod.stats.filteredPorts, 1)
+				mod.Debug("udp port %d of %s is filtered", udp.DstPort, from)
 			}
 		}
+	}
+}
 
-		if host != nil {
-			ports := host.Meta.GetOr("ports", map[int]*OpenPort{}).(map[int]*OpenPort)
-			if _, found := ports[port]; !found {
-				ports[port] = openPort
-			}
-			host.Meta.Set("ports", ports)
-		}
+func (mod *SynScanner) onPacket(pkt gopacket.Packet) {
+	if pkt == nil || pkt.Data() == nil {
+		return
+	}
 
-		mod.bannerQueue.Add(async.Job(grabberJob{from, openPort}))
+	var from net.IP
+	if lip4 := pkt.Layer(layers.LayerTypeIPv4); lip4 != nil {
+		from = lip4.(*layers.IPv4).SrcIP
+	} else if lip6 := pkt.Layer(layers.LayerTypeIPv6); lip6 != nil {
+		from = lip6.(*layers.IPv6).SrcIP
+	} else {
+		return
+	}
 
-		NewSynScanEvent(from, host, port).Push()
+	if ltcp := pkt.Layer(layers.LayerTypeTCP); ltcp != nil {
+		if tcp := ltcp.(*layers.TCP); tcp.DstPort == synSourcePort && tcp.SYN && tcp.ACK {
+			mod.onOpenPort(from, "tcp", int(tcp.SrcPort))
+		}
+	} else if ludp := pkt.Layer(layers.LayerTypeUDP); ludp != nil {
+		if udp := ludp.(*layers.UDP); udp.DstPort == synSourcePort {
+			mod.onOpenPort(from, "udp", int(udp.SrcPort))
+		}
+	} else if licmp := pkt.Layer(layers.LayerTypeICMPv4); licmp != nil {
+		if icmp := licmp.(*layers.ICMPv4); icmp.TypeCode.Type() == layers.ICMPv4TypeDestinationUnreachable {
+			mod.onUnreachable(from, icmp.TypeCode.Code() == layers.ICMPv4CodePort, icmp.Payload, layers.LayerTypeIPv4)
+		}
+	} else if licmp6 := pkt.Layer(layers.LayerTypeICMPv6); licmp6 != nil {
+		// skip the 4 unused bytes preceding the original datagram
+		if icmp6 := licmp6.(*layers.ICMPv6); icmp6.TypeCode.Type() == layers.ICMPv6TypeDestinationUnreachable && len(icmp6.Payload) > 4 {
+			mod.onUnreachable(from, icmp6.TypeCode.Code() == layers.ICMPv6CodePortUnreachable, icmp6.Payload[4:], layers.LayerTypeIPv6)
+		}
 	}
 }
@@ -0,0 +1,290 @@
+package syn_scan
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bettercap/bettercap/core"
+	"github.com/bettercap/bettercap/network"
+
+	"github.com/evilsocket/islazy/fs"
+)
+
+// the following types map the subset of the nmap XML output
+// format that is needed by most parsers to import scan results.
+type nmapRun struct {
+	XMLName          xml.Name       `xml:"nmaprun"`
+	Scanner          string         `xml:"scanner,attr"`
+	Args             string         `xml:"args,attr"`
+	Start            int64          `xml:"start,attr"`
+	StartStr         string         `xml:"startstr,attr"`
+	Version          string         `xml:"version,attr"`
+	XMLOutputVersion string         `xml:"xmloutputversion,attr"`
+	ScanInfo         []nmapScanInfo `xml:"scaninfo"`
+	Hosts            []nmapHost     `xml:"host"`
+	RunStats         nmapRunStats   `xml:"runstats"`
+}
+
+type nmapScanInfo struct {
+	Type        string `xml:"type,attr"`
+	Protocol    string `xml:"protocol,attr"`
+	NumServices int    `xml:"numservices,attr"`
+	Services    string `xml:"services,attr"`
+}
+
+type nmapHost struct {
+	Status    nmapStatus     `xml:"status"`
+	Addresses []nmapAddress  `xml:"address"`
+	Hostnames []nmapHostname `xml:"hostnames>hostname"`
+	Ports     []nmapPort     `xml:"ports>port"`
+	OS        *nmapOS        `xml:"os,omitempty"`
+}
+
+type nmapStatus struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+type nmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+	Vendor   string `xml:"vendor,attr,omitempty"`
+}
+
+type nmapHostname struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type nmapPort struct {
+	Protocol string        `xml:"protocol,attr"`
+	PortID   int           `xml:"portid,attr"`
+	State    nmapPortState `xml:"state"`
+	Service  nmapService   `xml:"service"`
+	Scripts  []nmapScript  `xml:"script"`
+}
+
+type nmapPortState struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+type nmapService struct {
+	Name    string `xml:"name,attr"`
+	Product string `xml:"product,attr,omitempty"`
+	Version string `xml:"version,attr,omitempty"`
+	Tunnel  string `xml:"tunnel,attr,omitempty"`
+	Method  string `xml:"method,attr"`
+	Conf    int    `xml:"conf,attr"`
+}
+
+type nmapScript struct {
+	ID     string `xml:"id,attr"`
+	Output string `xml:"output,attr"`
+}
+
+type nmapOS struct {
+	Matches []nmapOSMatch `xml:"osmatch"`
+}
+
+type nmapOSMatch struct {
+	Name     string `xml:"name,attr"`
+	Accuracy int    `xml:"accuracy,attr"`
+}
+
+type nmapRunStats struct {
+	Finished nmapFinished  `xml:"finished"`
+	Hosts    nmapHostStats `xml:"hosts"`
+}
+
+type nmapFinished struct {
+	Time    int64  `xml:"time,attr"`
+	TimeStr string `xml:"timestr,attr"`
+	Elapsed string `xml:"elapsed,attr"`
+}
+
+type nmapHostStats struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
+}
+
+func newNmapPort(port *OpenPort) nmapPort {
+	p := nmapPort{
+		Protocol: port.Proto,
+		PortID:   port.Port,
+		State: nmapPortState{
+			State:  "open",
+			Reason: "syn-ack",
+		},
+		Service: nmapService{
+			Name:    port.Service,
+			Product: port.Product,
+			Version: port.Version,
+			Method:  "table",
+			Conf:    3,
+		},
+		Scripts: make([]nmapScript, 0),
+	}
+
+	if port.Proto == "udp" {
+		p.State.Reason = "udp-response"
+	}
+
+	if port.Product != "" {
+		p.Service.Method = "probed"
+		p.Service.Conf = 10
+	}
+
+	if port.Cert != "" {
+		p.Service.Tunnel = "ssl"
+		p.Scripts = append(p.Scripts, nmapScript{ID: "ssl-cert", Output: port.Cert})
+	}
+
+	if port.Banner != "" {
+		p.Scripts = append(p.Scripts, nmapScript{ID: "banner", Output: port.Banner})
+	}
+
+	return p
+}
+
+func newNmapHost(e *network.Endpoint) (host nmapHost, found bool) {
+	host = nmapHost{
+		Status: nmapStatus{
+			State:  "up",
+			Reason: "arp-response",
+		},
+		Addresses: make([]nmapAddress, 0),
+		Hostnames: make([]nmapHostname, 0),
+		Ports:     make([]nmapPort, 0),
+	}
+
+	for _, metaName := range []string{PortsMetaName("tcp"), PortsMetaName("udp")} {
+		if ports, ok := e.Meta.Get(metaName).(map[int]*OpenPort); ok {
+			for _, port := range ports {
+				host.Ports = append(host.Ports, newNmapPort(port))
+			}
+		}
+	}
+
+	if len(host.Ports) == 0 {
+		return host, false
+	}
+
+	sort.Slice(host.Ports, func(i, j int) bool {
+		if host.Ports[i].Protocol != host.Ports[j].Protocol {
+			return host.Ports[i].Protocol < host.Ports[j].Protocol
+		}
+		return host.Ports[i].PortID < host.Ports[j].PortID
+	})
+
+	if e.IpAddress != "" {
+		host.Addresses = append(host.Addresses, nmapAddress{Addr: e.IpAddress, AddrType: "ipv4"})
+	}
+	if e.Ip6Address != "" {
+		host.Addresses = append(host.Addresses, nmapAddress{Addr: e.Ip6Address, AddrType: "ipv6"})
+	}
+	if e.HwAddress != "" {
+		host.Addresses = append(host.Addresses, nmapAddress{
+			Addr:     strings.ToUpper(e.HwAddress),
+			AddrType: "mac",
+			Vendor:   e.Vendor,
+		})
+	}
+
+	if e.Hostname != "" {
+		host.Hostnames = append(host.Hostnames, nmapHostname{Name: e.Hostname, Type: "PTR"})
+	}
+	if e.Alias != "" {
+		host.Hostnames = append(host.Hostnames, nmapHostname{Name: e.Alias, Type: "user"})
+	}
+
+	if e.OS != "" {
+		host.OS = &nmapOS{
+			Matches: []nmapOSMatch{{Name: e.OS, Accuracy: 50}},
+		}
+	}
+
+	return host, true
+}
+
+func (mod *SynScanner) writeReport(fileName string) (err error) {
+	if fileName, err = fs.Expand(fileName); err != nil {
+		return err
+	}
+
+	started := mod.stats.started
+	if started.IsZero() {
+		started = time.Now()
+	}
+	finished := time.Now()
+
+	report := nmapRun{
+		Scanner:          "bettercap",
+		Args:             "syn.scan",
+		Start:            started.Unix(),
+		StartStr:         started.Format(time.ANSIC),
+		Version:          core.Version,
+		XMLOutputVersion: "1.04",
+		ScanInfo:         make([]nmapScanInfo, 0),
+		Hosts:            make([]nmapHost, 0),
+	}
+
+	if mod.startPort > 0 && mod.endPort > 0 {
+		report.ScanInfo = append(report.ScanInfo, nmapScanInfo{
+			Type:        "syn",
+			Protocol:    mod.proto,
+			NumServices: mod.endPort - mod.startPort + 1,
+			Services:    fmt.Sprintf("%d-%d", mod.startPort, mod.endPort),
+		})
+		if mod.proto == "udp" {
+			report.ScanInfo[0].Type = "udp"
+		}
+	}
+
+	endpoints := []*network.Endpoint{mod.Session.Interface, mod.Session.Gateway}
+	endpoints = append(endpoints, mod.Session.Lan.List()...)
+	seen := make(map[*network.Endpoint]bool)
+	for _, e := range endpoints {
+		if e == nil || seen[e] {
+			continue
+		}
+		seen[e] = true
+
+		if host, found := newNmapHost(e); found {
+			report.Hosts = append(report.Hosts, host)
+		}
+	}
+
+	report.RunStats = nmapRunStats{
+		Finished: nmapFinished{
+			Time:    finished.Unix(),
+			TimeStr: finished.Format(time.ANSIC),
+			Elapsed: fmt.Sprintf("%.2f", finished.Sub(started).Seconds()),
+		},
+		Hosts: nmapHostStats{
+			Up:    len(report.Hosts),
+			Total: len(report.Hosts),
+		},
+	}
+
+	raw, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	data := []byte(xml.Header + "<!DOCTYPE nmaprun>\n")
+	data = append(data, raw...)
+	data = append(data, '\n')
+
+	if err = ioutil.WriteFile(fileName, data, 0644); err != nil {
+		return err
+	}
+
+	mod.Info("saved %d hosts to %s", len(report.Hosts), fileName)
+	return nil
+}
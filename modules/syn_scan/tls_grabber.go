@@ -0,0 +1,54 @@
+package syn_scan
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+)
+
+var tlsPorts = map[int]bool{
+	443:  true,
+	465:  true,
+	636:  true,
+	853:  true,
+	993:  true,
+	995:  true,
+	5986: true,
+	8443: true,
+}
+
+func tlsGrabber(mod *SynScanner, ip string, port int) string {
+	dialer := &net.Dialer{
+		Timeout: bannerGrabTimeout,
+	}
+
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(ip, fmt.Sprintf("%d", port)), &tls.Config{
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		mod.Debug("error while grabbing certificate from %s:%d: %v", ip, port, err)
+		return ""
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return ""
+	}
+
+	cert := certs[0]
+	parts := []string{
+		fmt.Sprintf("CN=%s", cert.Subject.CommonName),
+	}
+	if len(cert.Subject.Organization) > 0 {
+		parts = append(parts, fmt.Sprintf("O=%s", strings.Join(cert.Subject.Organization, ",")))
+	}
+	if len(cert.DNSNames) > 0 {
+		parts = append(parts, fmt.Sprintf("SAN=%s", strings.Join(cert.DNSNames, ",")))
+	}
+	parts = append(parts, fmt.Sprintf("issuer=%s", cert.Issuer.CommonName))
+	parts = append(parts, fmt.Sprintf("expires=%s", cert.NotAfter.Format("2006-01-02")))
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,47 @@
+package syn_scan
+
+import (
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/miekg/dns"
+)
+
+// protocol aware payloads, most UDP services won't
+// answer to anything but a well formed request
+var udpPayloads = map[int][]byte{
+	123:  ntpPayload(),
+	137:  packets.NBNSRequest,
+	1900: packets.UPNPDiscoveryPayload,
+	3702: packets.WSDDiscoveryPayload,
+}
+
+func init() {
+	udpPayloads[53] = dnsPayload("version.bind.", dns.TypeTXT, dns.ClassCHAOS)
+	udpPayloads[5353] = dnsPayload(packets.MDNSServicesQuery+".", dns.TypePTR, dns.ClassINET)
+	if raw, err := packets.NewSNMPRequest(packets.SNMPVersion1, "public", packets.SNMPGetRequest, 1, packets.SNMPOidSysDescr); err == nil {
+		udpPayloads[packets.SNMPPort] = raw
+	}
+}
+
+func ntpPayload() []byte {
+	// LI = 3 (unsynchronized), VN = 4, Mode = 3 (client)
+	payload := make([]byte, 48)
+	payload[0] = 0xe3
+	return payload
+}
+
+func dnsPayload(name string, qtype uint16, qclass uint16) []byte {
+	m := new(dns.Msg)
+	m.Question = []dns.Question{{Name: name, Qtype: qtype, Qclass: qclass}}
+	if raw, err := m.Pack(); err == nil {
+		return raw
+	}
+	return nil
+}
+
+func udpPayloadFor(port int) []byte {
+	if payload, found := udpPayloads[port]; found {
+		return payload
+	}
+	return []byte{}
+}
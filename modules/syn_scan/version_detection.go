@@ -0,0 +1,54 @@
+package syn_scan
+
+import (
+	"regexp"
+)
+
+type versionMatcher struct {
+	Service string
+	Product string
+	Parser  *regexp.Regexp
+}
+
+// the first capture group, if any, is the version
+var versionMatchers = []versionMatcher{
+	{"ssh", "OpenSSH", regexp.MustCompile(`^SSH-[\d.]+-OpenSSH_([\w.]+)`)},
+	{"ssh", "Dropbear", regexp.MustCompile(`^SSH-[\d.]+-dropbear_([\w.]+)`)},
+	{"ssh", "Cisco SSH", regexp.MustCompile(`^SSH-[\d.]+-Cisco-([\w.]+)`)},
+	{"ssh", "", regexp.MustCompile(`^SSH-[\d.]+-`)},
+	{"ftp", "ProFTPD", regexp.MustCompile(`^220.*ProFTPD ([\w.]+)`)},
+	{"ftp", "vsftpd", regexp.MustCompile(`^220.*\(vsFTPd ([\w.]+)\)`)},
+	{"ftp", "FileZilla Server", regexp.MustCompile(`^220.*FileZilla Server (?:version )?([\w.]+)`)},
+	{"ftp", "Pure-FTPd", regexp.MustCompile(`^220.*Pure-FTPd`)},
+	{"ftp", "Microsoft FTP Service", regexp.MustCompile(`^220.*Microsoft FTP Service`)},
+	{"smtp", "Postfix", regexp.MustCompile(`^220.*ESMTP Postfix`)},
+	{"smtp", "Exim", regexp.MustCompile(`^220.*ESMTP Exim ([\w.]+)`)},
+	{"smtp", "Sendmail", regexp.MustCompile(`^220.*ESMTP Sendmail ([\w.]+)`)},
+	{"smtp", "Microsoft Exchange", regexp.MustCompile(`^220.*Microsoft ESMTP MAIL Service`)},
+	{"pop3", "Dovecot", regexp.MustCompile(`^\+OK.*Dovecot`)},
+	{"imap", "Dovecot", regexp.MustCompile(`^\* OK.*Dovecot`)},
+	{"imap", "Courier", regexp.MustCompile(`^\* OK.*Courier-IMAP`)},
+	{"vnc", "", regexp.MustCompile(`^RFB (\d{3}\.\d{3})`)},
+	{"mysql", "MariaDB", regexp.MustCompile(`(\d+\.\d+\.\d+)-MariaDB`)},
+	{"http", "nginx", regexp.MustCompile(`nginx/([\w.]+)`)},
+	{"http", "Apache httpd", regexp.MustCompile(`Apache/([\w.]+)`)},
+	{"http", "Microsoft IIS", regexp.MustCompile(`Microsoft-IIS/([\w.]+)`)},
+	{"http", "lighttpd", regexp.MustCompile(`lighttpd/([\w.]+)`)},
+	{"http", "Jetty", regexp.MustCompile(`Jetty\(([\w.\-]+)\)`)},
+	{"http", "mini_httpd", regexp.MustCompile(`mini_httpd/([\w.]+)`)},
+	{"http", "GoAhead", regexp.MustCompile(`GoAhead-Webs`)},
+	{"domain", "dnsmasq", regexp.MustCompile(`dnsmasq-([\w.]+)`)},
+}
+
+// detectVersion maps a banner to its service, product and version if possible.
+func detectVersion(banner string) (service, product, version string) {
+	for _, m := range versionMatchers {
+		if match := m.Parser.FindStringSubmatch(banner); match != nil {
+			if len(match) > 1 {
+				version = match[1]
+			}
+			return m.Service, m.Product, version
+		}
+	}
+	return "", "", ""
+}
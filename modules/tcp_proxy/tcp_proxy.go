@@ -20,6 +20,7 @@ type TcpProxy struct {
 	tunnelAddr  *net.TCPAddr
 	listener    *net.TCPListener
 	script      *TcpProxyScript
+	framing     framer
 }
 
 func NewTcpProxy(s *session.Session) *TcpProxy {
@@ -50,6 +51,11 @@ func NewTcpProxy(s *session.Session) *TcpProxy {
 		"",
 		"Path of a TCP proxy JS script."))
 
+	mod.AddParam(session.NewStringParameter("tcp.proxy.framing",
+		"",
+		`^((length:[1248](:(be|le))?(:inclusive)?)|(delimiter:.+))?$`,
+		"How the data is split into the messages passed to the script: empty to pass it as it is read, length:SIZE[:be|le][:inclusive] for messages prefixed by their SIZE bytes long length (big endian by default, inclusive if it counts the prefix too), delimiter:SEP for messages terminated by SEP (escapes like \\r\\n or \\x00 can be used). The script callbacks get and return the messages without their prefix or delimiter."))
+
 	mod.AddParam(session.NewStringParameter("tcp.tunnel.address",
 		"",
 		"",
@@ -93,6 +99,7 @@ func (mod *TcpProxy) Configure() error {
 	var address string
 	var proxyAddress string
 	var scriptPath string
+	var framing string
 	var tunnelAddress string
 	var tunnelPort int
 
@@ -112,6 +119,10 @@ func (mod *TcpProxy) Configure() error {
 		return err
 	} else if err, scriptPath = mod.StringParam("tcp.proxy.script"); err != nil {
 		return err
+	} else if err, framing = mod.StringParam("tcp.proxy.framing"); err != nil {
+		return err
+	} else if mod.framing, err = parseFraming(framing); err != nil {
+		return err
 	} else if mod.localAddr, err = net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", proxyAddress, proxyPort)); err != nil {
 		return err
 	} else if mod.remoteAddr, err = net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", address, port)); err != nil {
@@ -152,9 +163,50 @@ func (mod *TcpProxy) Configure() error {
 	return nil
 }
 
-func (mod *TcpProxy) doPipe(from, to net.Addr, src *net.TCPConn, dst io.ReadWriter, wg *sync.WaitGroup) {
+// forward passes the payload of a frame, or the data as read if no framing
+// is used, to the script and sends the frame, or its replacement, to dst.
+func (mod *TcpProxy) forward(from, to net.Addr, fromClient bool, src *net.TCPConn, dst io.Writer, payload []byte, frame []byte, framing framer) error {
+	if mod.script != nil {
+		ret := mod.script.OnData(from, to, fromClient, payload, func(call otto.FunctionCall) otto.Value {
+			mod.Debug("onData dropCallback called")
+			src.Close()
+			return otto.Value{}
+		})
+
+		if ret != nil {
+			nret := len(ret)
+			mod.Info("overriding %d bytes of data from %s to %s with %d bytes of new data.",
+				len(payload), from.String(), to.String(), nret)
+
+			if framing == nil {
+				frame = make([]byte, nret)
+				copy(frame, ret)
+			} else if encoded, err := framing.Encode(ret); err != nil {
+				mod.Warning("can't frame the new data, sending the original one: %v", err)
+			} else {
+				frame = encoded
+			}
+		}
+	}
+
+	n, err := dst.Write(frame)
+	if err != nil {
+		return err
+	}
+
+	mod.Debug("%s -> %s : %d bytes", from.String(), to.String(), n)
+	return nil
+}
+
+func (mod *TcpProxy) doPipe(from, to net.Addr, fromClient bool, src *net.TCPConn, dst io.ReadWriter, wg *sync.WaitGroup) {
 	defer wg.Done()
 
+	framing := mod.framing
+	if mod.script == nil {
+		framing = nil
+	}
+
+	pending := make([]byte, 0)
 	buff := make([]byte, 0xffff)
 	for {
 		n, err := src.Read(buff)
@@ -162,33 +214,46 @@ func (mod *TcpProxy) doPipe(from, to net.Addr, src *net.TCPConn, dst io.ReadWrit
 			if err.Error() != "EOF" {
 				mod.Warning("read failed: %s", err)
 			}
+			// send what's left of an incomplete frame as it is
+			if len(pending) > 0 {
+				dst.Write(pending)
+			}
 			return
 		}
-		b := buff[:n]
-
-		if mod.script != nil {
-			ret := mod.script.OnData(from, to, b, func(call otto.FunctionCall) otto.Value {
-				mod.Debug("onData dropCallback called")
-				src.Close()
-				return otto.Value{}
-			})
-
-			if ret != nil {
-				nret := len(ret)
-				mod.Info("overriding %d bytes of data from %s to %s with %d bytes of new data.",
-					n, from.String(), to.String(), nret)
-				b = make([]byte, nret)
-				copy(b, ret)
+
+		if framing == nil {
+			if err = mod.forward(from, to, fromClient, src, dst, buff[:n], buff[:n], nil); err != nil {
+				mod.Warning("write failed: %s", err)
+				return
 			}
+			continue
 		}
 
-		n, err = dst.Write(b)
-		if err != nil {
-			mod.Warning("write failed: %s", err)
-			return
-		}
+		pending = append(pending, buff[:n]...)
+		for len(pending) > 0 {
+			payload, size, err := framing.Next(pending)
+			if err != nil {
+				// we're out of sync with the protocol, stop framing and
+				// just pass the data as it is from now on
+				mod.Warning("%s -> %s : %v, framing disabled for this connection", from.String(), to.String(), err)
+				framing = nil
+				if err = mod.forward(from, to, fromClient, src, dst, pending, pending, nil); err != nil {
+					mod.Warning("write failed: %s", err)
+					return
+				}
+				pending = pending[:0]
+				break
+			} else if payload == nil {
+				// incomplete frame
+				break
+			}
 
-		mod.Debug("%s -> %s : %d bytes", from.String(), to.String(), n)
+			if err = mod.forward(from, to, fromClient, src, dst, payload, pending[:size], framing); err != nil {
+				mod.Warning("write failed: %s", err)
+				return
+			}
+			pending = pending[size:]
+		}
 	}
 }
 
@@ -214,8 +279,8 @@ func (mod *TcpProxy) handleConnection(c *net.TCPConn) {
 	wg.Add(2)
 
 	// start pipeing
-	go mod.doPipe(c.RemoteAddr(), mod.remoteAddr, c, remote, &wg)
-	go mod.doPipe(mod.remoteAddr, c.RemoteAddr(), remote, c, &wg)
+	go mod.doPipe(c.RemoteAddr(), mod.remoteAddr, true, c, remote, &wg)
+	go mod.doPipe(mod.remoteAddr, c.RemoteAddr(), false, remote, c, &wg)
 
 	wg.Wait()
 }
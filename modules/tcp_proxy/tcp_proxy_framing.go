@@ -0,0 +1,157 @@
+package tcp_proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// frames bigger than this are considered garbage
+const maxFrameSize = 16 * 1024 * 1024
+
+// framer splits the stream of a binary protocol into the messages passed to
+// the script and frames them again once the script is done with them.
+type framer interface {
+	// Next returns the payload of the first complete frame of buf and how
+	// many bytes it takes, a nil payload if more data is needed.
+	Next(buf []byte) ([]byte, int, error)
+	// Encode frames the payload the script returned.
+	Encode(payload []byte) ([]byte, error)
+}
+
+// lengthFramer handles messages prefixed by their length.
+type lengthFramer struct {
+	size      int
+	order     binary.ByteOrder
+	inclusive bool
+}
+
+func (f *lengthFramer) Next(buf []byte) ([]byte, int, error) {
+	if len(buf) < f.size {
+		return nil, 0, nil
+	}
+
+	var length uint64
+	switch f.size {
+	case 1:
+		length = uint64(buf[0])
+	case 2:
+		length = uint64(f.order.Uint16(buf))
+	case 4:
+		length = uint64(f.order.Uint32(buf))
+	case 8:
+		length = f.order.Uint64(buf)
+	}
+
+	total := length + uint64(f.size)
+	if f.inclusive {
+		if length < uint64(f.size) {
+			return nil, 0, fmt.Errorf("frame length %d is shorter than its %d bytes prefix", length, f.size)
+		}
+		total = length
+	}
+
+	if total > maxFrameSize {
+		return nil, 0, fmt.Errorf("frame length %d exceeds %d bytes", total, maxFrameSize)
+	} else if uint64(len(buf)) < total {
+		return nil, 0, nil
+	}
+
+	return buf[f.size:total], int(total), nil
+}
+
+func (f *lengthFramer) Encode(payload []byte) ([]byte, error) {
+	length := uint64(len(payload))
+	if f.inclusive {
+		length += uint64(f.size)
+	}
+
+	if f.size < 8 && length >= uint64(1)<<(8*uint(f.size)) {
+		return nil, fmt.Errorf("%d bytes don't fit a %d bytes length prefix", length, f.size)
+	}
+
+	frame := make([]byte, f.size, f.size+len(payload))
+	switch f.size {
+	case 1:
+		frame[0] = byte(length)
+	case 2:
+		f.order.PutUint16(frame, uint16(length))
+	case 4:
+		f.order.PutUint32(frame, uint32(length))
+	case 8:
+		f.order.PutUint64(frame, length)
+	}
+
+	return append(frame, payload...), nil
+}
+
+// delimiterFramer handles messages terminated by a separator.
+type delimiterFramer struct {
+	delimiter []byte
+}
+
+func (f *delimiterFramer) Next(buf []byte) ([]byte, int, error) {
+	if idx := bytes.Index(buf, f.delimiter); idx != -1 {
+		return buf[:idx], idx + len(f.delimiter), nil
+	} else if len(buf) > maxFrameSize {
+		return nil, 0, fmt.Errorf("no delimiter found in %d bytes", len(buf))
+	}
+	return nil, 0, nil
+}
+
+func (f *delimiterFramer) Encode(payload []byte) ([]byte, error) {
+	frame := make([]byte, 0, len(payload)+len(f.delimiter))
+	frame = append(frame, payload...)
+	return append(frame, f.delimiter...), nil
+}
+
+// parseFraming parses the tcp.proxy.framing parameter, returning nil if the
+// data must be passed to the script as it's read:
+//
+//	length:SIZE[:be|le][:inclusive]  SIZE bytes long length prefix, big endian by default
+//	delimiter:SEP                    messages terminated by SEP, like \r\n or \x00
+func parseFraming(spec string) (framer, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("invalid framing '%s'", spec)
+	}
+
+	switch parts[0] {
+	case "length":
+		f := &lengthFramer{order: binary.BigEndian}
+		for i, opt := range strings.Split(parts[1], ":") {
+			if i == 0 {
+				size, err := strconv.Atoi(opt)
+				if err != nil || (size != 1 && size != 2 && size != 4 && size != 8) {
+					return nil, fmt.Errorf("invalid length prefix size '%s', must be 1, 2, 4 or 8", opt)
+				}
+				f.size = size
+			} else if opt == "be" {
+				f.order = binary.BigEndian
+			} else if opt == "le" {
+				f.order = binary.LittleEndian
+			} else if opt == "inclusive" {
+				f.inclusive = true
+			} else {
+				return nil, fmt.Errorf("unknown length framing option '%s'", opt)
+			}
+		}
+		return f, nil
+
+	case "delimiter":
+		// allow escapes like \r\n and \x00
+		delimiter, err := strconv.Unquote(`"` + strings.Replace(parts[1], `"`, `\"`, -1) + `"`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delimiter '%s': %v", parts[1], err)
+		}
+		return &delimiterFramer{delimiter: []byte(delimiter)}, nil
+	}
+
+	return nil, fmt.Errorf("unknown framing '%s', use length or delimiter", parts[0])
+}
@@ -4,6 +4,7 @@ import (
 	"net"
 	"strings"
 
+	"github.com/bettercap/bettercap/js"
 	"github.com/bettercap/bettercap/log"
 	"github.com/bettercap/bettercap/session"
 
@@ -14,7 +15,9 @@ import (
 
 type TcpProxyScript struct {
 	*plugin.Plugin
-	doOnData bool
+	doOnData       bool
+	doOnClientData bool
+	doOnServerData bool
 }
 
 func LoadTcpProxyScript(path string, sess *session.Session) (err error, s *TcpProxyScript) {
@@ -31,6 +34,11 @@ func LoadTcpProxyScript(path string, sess *session.Session) (err error, s *TcpPr
 		return
 	}
 
+	if err = js.SetHTTPPackage(plug); err != nil {
+		log.Error("error while defining http: %+v", err)
+		return
+	}
+
 	// run onLoad if defined
 	if plug.HasFunc("onLoad") {
 		if _, err = plug.Call("onLoad"); err != nil {
@@ -40,27 +48,78 @@ func LoadTcpProxyScript(path string, sess *session.Session) (err error, s *TcpPr
 	}
 
 	s = &TcpProxyScript{
-		Plugin:   plug,
-		doOnData: plug.HasFunc("onData"),
+		Plugin:         plug,
+		doOnData:       plug.HasFunc("onData"),
+		doOnClientData: plug.HasFunc("onClientData"),
+		doOnServerData: plug.HasFunc("onServerData"),
 	}
 	return
 }
 
-func (s *TcpProxyScript) OnData(from, to net.Addr, data []byte, callback func(call otto.FunctionCall) otto.Value) []byte {
-	if s.doOnData {
-		addrFrom := strings.Split(from.String(), ":")[0]
-		addrTo := strings.Split(to.String(), ":")[0]
+// OnData passes data going from the client to the server, or the other way
+// around, to onClientData or onServerData if defined, to onData otherwise,
+// returning the data to send instead if the callback changed it.
+func (s *TcpProxyScript) OnData(from, to net.Addr, fromClient bool, data []byte, callback func(call otto.FunctionCall) otto.Value) []byte {
+	name := ""
+	if fromClient && s.doOnClientData {
+		name = "onClientData"
+	} else if !fromClient && s.doOnServerData {
+		name = "onServerData"
+	} else if s.doOnData {
+		name = "onData"
+	} else {
+		return nil
+	}
 
-		if ret, err := s.Call("onData", addrFrom, addrTo, data, callback); err != nil {
-			log.Error("error while executing onData callback: %s", err)
-			return nil
-		} else if ret != nil {
-			array, ok := ret.([]byte)
-			if !ok {
-				log.Error("error while casting exported value to array of byte: value = %+v", ret)
-			}
-			return array
+	addrFrom := strings.Split(from.String(), ":")[0]
+	addrTo := strings.Split(to.String(), ":")[0]
+
+	if ret, err := s.Call(name, addrFrom, addrTo, data, callback); err != nil {
+		log.Error("error while executing %s callback: %s", name, err)
+		return nil
+	} else if ret != nil {
+		array, ok := toBytes(ret)
+		if !ok {
+			log.Error("error while casting exported value to array of byte: value = %+v", ret)
 		}
+		return array
 	}
 	return nil
 }
+
+// toBytes converts what the callbacks return, the same buffer, a string or
+// an array of numbers, to the bytes to send.
+func toBytes(value interface{}) ([]byte, bool) {
+	switch v := value.(type) {
+	case []byte:
+		return v, true
+	case string:
+		return []byte(v), true
+	case []int64:
+		b := make([]byte, len(v))
+		for i, n := range v {
+			b[i] = byte(n)
+		}
+		return b, true
+	case []float64:
+		b := make([]byte, len(v))
+		for i, n := range v {
+			b[i] = byte(n)
+		}
+		return b, true
+	case []interface{}:
+		b := make([]byte, len(v))
+		for i, e := range v {
+			switch n := e.(type) {
+			case int64:
+				b[i] = byte(n)
+			case float64:
+				b[i] = byte(n)
+			default:
+				return nil, false
+			}
+		}
+		return b, true
+	}
+	return nil, false
+}
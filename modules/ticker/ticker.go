@@ -17,6 +17,8 @@ func NewTicker(s *session.Session) *Ticker {
 		SessionModule: session.NewSessionModule("ticker", s),
 	}
 
+	mod.SetInterfaceIndependent(true)
+
 	mod.AddParam(session.NewStringParameter("ticker.commands",
 		"clear; net.show; events.show 20",
 		"",
@@ -0,0 +1,279 @@
+package traceroute
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/google/gopacket"
+)
+
+type Hop struct {
+	TTL     int           `json:"ttl"`
+	Address string        `json:"address"`
+	Name    string        `json:"name"`
+	RTT     time.Duration `json:"rtt"`
+}
+
+type Route struct {
+	Target  string    `json:"target"`
+	Mode    string    `json:"mode"`
+	Reached bool      `json:"reached"`
+	Started time.Time `json:"started"`
+	Hops    []*Hop    `json:"hops"`
+}
+
+type Traceroute struct {
+	session.SessionModule
+	mode    string
+	port    int
+	maxHops int
+	timeout time.Duration
+	resolve bool
+	routes  sync.Map
+}
+
+func NewTraceroute(s *session.Session) *Traceroute {
+	mod := &Traceroute{
+		SessionModule: session.NewSessionModule("traceroute", s),
+	}
+
+	mod.State.Store("routes", []*Route{})
+
+	mod.AddParam(session.NewStringParameter("traceroute.mode",
+		packets.TraceICMP,
+		"^(icmp|udp|tcp)$",
+		"Type of probes to send, icmp echo requests, udp datagrams or tcp syn packets."))
+
+	mod.AddParam(session.NewIntParameter("traceroute.port",
+		"80",
+		"Destination port of the tcp probes."))
+
+	mod.AddParam(session.NewIntParameter("traceroute.max-hops",
+		"30",
+		"Maximum number of hops to trace."))
+
+	mod.AddParam(session.NewIntParameter("traceroute.timeout",
+		"1000",
+		"Time in milliseconds to wait for the answer of every hop."))
+
+	mod.AddParam(session.NewBoolParameter("traceroute.resolve",
+		"true",
+		"If true, reverse resolve the address of every hop."))
+
+	mod.AddHandler(session.NewModuleHandler("traceroute ADDRESS", `traceroute ([^\s]+)`,
+		"Trace the route to an address or host name.",
+		func(args []string) error {
+			if mod.Running() {
+				return fmt.Errorf("a traceroute is already running, wait for it to end before starting a new one")
+			}
+			return mod.start(args[0])
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("traceroute.stop", `traceroute\.(stop|off)`,
+		"Stop the current traceroute.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("traceroute.show", "",
+		"Show the routes traced so far.",
+		func(args []string) error {
+			return mod.show()
+		}))
+
+	return mod
+}
+
+func (mod *Traceroute) Name() string {
+	return "traceroute"
+}
+
+func (mod *Traceroute) Description() string {
+	return "Trace the route to arbitrary targets using icmp, udp or tcp probes."
+}
+
+func (mod *Traceroute) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *Traceroute) Configure() (err error) {
+	var timeout int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, mod.mode = mod.StringParam("traceroute.mode"); err != nil {
+		return err
+	} else if err, mod.port = mod.IntParam("traceroute.port"); err != nil {
+		return err
+	} else if err, mod.maxHops = mod.IntParam("traceroute.max-hops"); err != nil {
+		return err
+	} else if err, timeout = mod.IntParam("traceroute.timeout"); err != nil {
+		return err
+	} else if err, mod.resolve = mod.BoolParam("traceroute.resolve"); err != nil {
+		return err
+	} else if mod.maxHops <= 0 || mod.maxHops > packets.TraceMaxTTL {
+		return fmt.Errorf("traceroute.max-hops must be between 1 and %d", packets.TraceMaxTTL)
+	}
+
+	mod.timeout = time.Duration(timeout) * time.Millisecond
+
+	return nil
+}
+
+func (mod *Traceroute) Start() error {
+	return fmt.Errorf("use traceroute ADDRESS")
+}
+
+func (mod *Traceroute) Stop() error {
+	return mod.SetRunning(false, nil)
+}
+
+func lookupTarget(target string) (net.IP, error) {
+	if ip := net.ParseIP(target); ip != nil {
+		return ip.To4(), nil
+	}
+
+	addrs, err := net.LookupIP(target)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if ip4 := addr.To4(); ip4 != nil {
+			return ip4, nil
+		}
+	}
+	return nil, fmt.Errorf("%s has no IPv4 address", target)
+}
+
+func (mod *Traceroute) start(target string) error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	ip, err := lookupTarget(target)
+	if err != nil {
+		return err
+	} else if ip == nil {
+		return fmt.Errorf("only IPv4 targets are supported")
+	}
+
+	mac, err := mod.Session.NextHopMAC(ip, true)
+	if err != nil {
+		return err
+	}
+
+	handle, err := network.CaptureWithTimeout(mod.Session.Interface.Name(), time.Duration(100)*time.Millisecond)
+	if err != nil {
+		return err
+	} else if err = handle.SetBPFFilter(fmt.Sprintf("icmp or (tcp and src host %s)", ip)); err != nil {
+		handle.Close()
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		defer mod.SetRunning(false, nil)
+
+		replies := make(chan *packets.TraceReply, packets.TraceMaxTTL)
+		done := make(chan bool)
+		id := uint16(32768 + rand.Intn(16384))
+
+		go func() {
+			defer handle.Close()
+			src := gopacket.NewPacketSource(handle, handle.LinkType())
+			for {
+				select {
+				case <-done:
+					return
+				case pkt, ok := <-src.Packets():
+					if !ok {
+						return
+					} else if reply, found := packets.TraceParseReply(pkt, mod.mode, id, ip); found {
+						replies <- reply
+					}
+				}
+			}
+		}()
+		defer close(done)
+
+		route := &Route{
+			Target:  ip.String(),
+			Mode:    mod.mode,
+			Started: time.Now(),
+			Hops:    make([]*Hop, 0),
+		}
+
+		mod.Info("tracing route to %s (%s) over a maximum of %d hops ...", target, ip, mod.maxHops)
+
+		for ttl := 1; ttl <= mod.maxHops && mod.Running() && !route.Reached; ttl++ {
+			hop := mod.probe(ip, mac, id, ttl, replies)
+			if hop.Address == "" {
+				mod.Info("%2d  *", ttl)
+			} else {
+				if hop.Address == route.Target {
+					route.Reached = true
+				}
+
+				if hop.Name != "" {
+					mod.Info("%2d  %s (%s)  %s", ttl, hop.Name, hop.Address, hop.RTT)
+				} else {
+					mod.Info("%2d  %s  %s", ttl, hop.Address, hop.RTT)
+				}
+			}
+			route.Hops = append(route.Hops, hop)
+		}
+
+		mod.routes.Store(route.Target, route)
+		mod.State.Store("routes", mod.sortedRoutes())
+
+		if e := mod.Session.Lan.GetByIp(route.Target); e != nil {
+			e.Meta.Set("traceroute:hops", fmt.Sprintf("%d", len(route.Hops)))
+		}
+
+		if !route.Reached {
+			mod.Warning("%s not reached after %d hops", ip, len(route.Hops))
+		}
+	})
+}
+
+func (mod *Traceroute) probe(ip net.IP, mac net.HardwareAddr, id uint16, ttl int, replies chan *packets.TraceReply) *Hop {
+	hop := &Hop{TTL: ttl}
+
+	err, raw := packets.NewTraceProbe(mod.mode, mod.Session.Interface.IP, mod.Session.Interface.HW, ip, mac, id, ttl, mod.port)
+	if err != nil {
+		mod.Error("error creating probe: %v", err)
+		return hop
+	}
+
+	sent := time.Now()
+	if err = mod.Session.Queue.Send(raw); err != nil {
+		mod.Error("error sending probe: %v", err)
+		return hop
+	}
+
+	deadline := time.After(mod.timeout)
+	for {
+		select {
+		case <-deadline:
+			return hop
+		case reply := <-replies:
+			// late answers to previous probes are ignored
+			if reply.TTL != ttl {
+				continue
+			}
+
+			hop.RTT = time.Since(sent)
+			hop.Address = reply.From.String()
+			if mod.resolve {
+				hop.Name, _ = network.PTRLookup(hop.Address, mod.timeout)
+			}
+			return hop
+		}
+	}
+}
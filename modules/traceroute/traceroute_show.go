@@ -0,0 +1,58 @@
+package traceroute
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+func (mod *Traceroute) sortedRoutes() []*Route {
+	routes := make([]*Route, 0)
+	mod.routes.Range(func(k, v interface{}) bool {
+		routes = append(routes, v.(*Route))
+		return true
+	})
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Started.Before(routes[j].Started)
+	})
+
+	return routes
+}
+
+func (mod *Traceroute) show() error {
+	routes := mod.sortedRoutes()
+	if len(routes) == 0 {
+		mod.Info("no routes traced yet")
+		return nil
+	}
+
+	colNames := []string{"TTL", "Address", "Name", "RTT"}
+	for _, route := range routes {
+		status := tui.Green("reached")
+		if !route.Reached {
+			status = tui.Red("not reached")
+		}
+		mod.Printf("\n%s (%s, %s)\n\n", tui.Bold(route.Target), route.Mode, status)
+
+		rows := make([][]string, 0, len(route.Hops))
+		for _, hop := range route.Hops {
+			if hop.Address == "" {
+				rows = append(rows, []string{fmt.Sprintf("%d", hop.TTL), tui.Dim("*"), "", ""})
+			} else {
+				rows = append(rows, []string{
+					fmt.Sprintf("%d", hop.TTL),
+					hop.Address,
+					tui.Yellow(hop.Name),
+					hop.RTT.String(),
+				})
+			}
+		}
+
+		tui.Table(mod.Session.Events.Stdout, colNames, rows)
+	}
+
+	mod.Session.Refresh()
+	return nil
+}
@@ -43,6 +43,8 @@ func NewUIModule(s *session.Session) *UIModule {
 		client:        github.NewClient(nil),
 	}
 
+	mod.SetInterfaceIndependent(true)
+
 	mod.AddParam(session.NewStringParameter("ui.basepath",
 		getDefaultInstallBase(),
 		"",
@@ -25,6 +25,8 @@ func NewUpdateModule(s *session.Session) *UpdateModule {
 		client:        github.NewClient(nil),
 	}
 
+	mod.SetInterfaceIndependent(true)
+
 	mod.AddHandler(session.NewModuleHandler("update.check on", "",
 		"Check latest available stable version and compare it with the one being used.",
 		func(args []string) error {
@@ -0,0 +1,312 @@
+package vlan_hop
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/packets"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/google/gopacket"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+// HopResult is the outcome of a double tagging test toward a VLAN.
+type HopResult struct {
+	NativeVLAN int       `json:"native_vlan"`
+	TargetVLAN int       `json:"target_vlan"`
+	Target     string    `json:"target"`
+	Started    time.Time `json:"started"`
+	Sent       int       `json:"sent"`
+	Replies    int       `json:"replies"`
+	ReplyVLANs []int     `json:"reply_vlans"`
+	Exposed    bool      `json:"exposed"`
+}
+
+type VLANHopper struct {
+	session.SessionModule
+	native  int
+	count   int
+	timeout time.Duration
+	source  net.IP
+	dstHW   net.HardwareAddr
+	results sync.Map
+}
+
+func NewVLANHopper(s *session.Session) *VLANHopper {
+	mod := &VLANHopper{
+		SessionModule: session.NewSessionModule("vlan.hop", s),
+	}
+
+	mod.State.Store("results", []*HopResult{})
+
+	mod.AddParam(session.NewIntParameter("vlan.hop.native",
+		"1",
+		"Native VLAN of the port we're connected to, used as the outer tag of the probes."))
+
+	mod.AddParam(session.NewIntParameter("vlan.hop.count",
+		"5",
+		"Number of probes to send, one per second."))
+
+	mod.AddParam(session.NewIntParameter("vlan.hop.timeout",
+		"3000",
+		"Time in milliseconds to wait for replies after the last probe has been sent."))
+
+	mod.AddParam(session.NewStringParameter("vlan.hop.source",
+		"",
+		`^(\d+\.\d+\.\d+\.\d+)?$`,
+		"Source address of the probes, if empty the interface address is used. Replies can only be seen if the target can route them back to this address."))
+
+	mod.AddParam(session.NewStringParameter("vlan.hop.mac",
+		"ff:ff:ff:ff:ff:ff",
+		`^([0-9a-fA-F]{2}:){5}[0-9a-fA-F]{2}$`,
+		"Destination MAC address of the probes, broadcast by default as the target is on another segment."))
+
+	mod.AddHandler(session.NewModuleHandler("vlan.hop VLAN ADDRESS", `vlan\.hop (\d+) (\d+\.\d+\.\d+\.\d+)`,
+		"Send double tagged ICMP echo requests to ADDRESS in VLAN and report whether it answers.",
+		func(args []string) error {
+			if mod.Running() {
+				return fmt.Errorf("a vlan.hop test is already running, wait for it to end before starting a new one")
+			}
+			vlan, _ := strconv.Atoi(args[0])
+			return mod.start(vlan, net.ParseIP(args[1]))
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("vlan.hop.stop", `vlan\.hop\.(stop|off)`,
+		"Stop the current test.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("vlan.hop.show", "",
+		"Show the results of the tests performed so far.",
+		func(args []string) error {
+			return mod.show()
+		}))
+
+	return mod
+}
+
+func (mod *VLANHopper) Name() string {
+	return "vlan.hop"
+}
+
+func (mod *VLANHopper) Description() string {
+	return "Test for VLAN hopping exposure by sending double tagged 802.1Q frames toward a target VLAN."
+}
+
+func (mod *VLANHopper) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+func (mod *VLANHopper) Configure() (err error) {
+	var timeout int
+	var source string
+	var mac string
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, mod.native = mod.IntParam("vlan.hop.native"); err != nil {
+		return err
+	} else if err, mod.count = mod.IntParam("vlan.hop.count"); err != nil {
+		return err
+	} else if err, timeout = mod.IntParam("vlan.hop.timeout"); err != nil {
+		return err
+	} else if err, source = mod.StringParam("vlan.hop.source"); err != nil {
+		return err
+	} else if err, mac = mod.StringParam("vlan.hop.mac"); err != nil {
+		return err
+	} else if mod.dstHW, err = net.ParseMAC(mac); err != nil {
+		return err
+	} else if mod.native < 1 || mod.native > 4094 {
+		return fmt.Errorf("vlan.hop.native must be between 1 and 4094")
+	} else if mod.count <= 0 {
+		return fmt.Errorf("vlan.hop.count must be greater than 0")
+	}
+
+	mod.timeout = time.Duration(timeout) * time.Millisecond
+	mod.source = mod.Session.Interface.IP
+	if source != "" {
+		mod.source = net.ParseIP(source).To4()
+	}
+
+	return nil
+}
+
+func (mod *VLANHopper) Start() error {
+	return fmt.Errorf("use vlan.hop VLAN ADDRESS")
+}
+
+func (mod *VLANHopper) Stop() error {
+	return mod.SetRunning(false, nil)
+}
+
+func (mod *VLANHopper) start(vlan int, target net.IP) error {
+	if err := mod.Configure(); err != nil {
+		return err
+	} else if vlan < 1 || vlan > 4094 {
+		return fmt.Errorf("VLAN must be between 1 and 4094")
+	} else if vlan == mod.native {
+		return fmt.Errorf("target VLAN is the native one, nothing to hop to")
+	} else if target = target.To4(); target == nil {
+		return fmt.Errorf("only IPv4 targets are supported")
+	}
+
+	handle, err := network.CaptureWithTimeout(mod.Session.Interface.Name(), time.Duration(100)*time.Millisecond)
+	if err != nil {
+		return err
+	} else if err = handle.SetBPFFilter(fmt.Sprintf("(icmp or (vlan and icmp)) and src host %s", target)); err != nil {
+		handle.Close()
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		defer mod.SetRunning(false, nil)
+
+		result := &HopResult{
+			NativeVLAN: mod.native,
+			TargetVLAN: vlan,
+			Target:     target.String(),
+			Started:    time.Now(),
+			ReplyVLANs: make([]int, 0),
+		}
+
+		id := uint16(32768 + rand.Intn(16384))
+		replies := make(chan uint16, mod.count)
+		done := make(chan bool)
+
+		go func() {
+			defer handle.Close()
+			src := gopacket.NewPacketSource(handle, handle.LinkType())
+			for {
+				select {
+				case <-done:
+					return
+				case pkt, ok := <-src.Packets():
+					if !ok {
+						return
+					} else if tag, found := packets.VLANHopParseReply(pkt, target, id); found {
+						select {
+						case replies <- tag:
+						default:
+						}
+					}
+				}
+			}
+		}()
+		defer close(done)
+
+		mod.Info("sending %d probes to %s in VLAN %d through native VLAN %d ...", mod.count, target, vlan, mod.native)
+
+		seen := make(map[int]bool)
+		collect := func(tag uint16) {
+			result.Replies++
+			if !seen[int(tag)] {
+				seen[int(tag)] = true
+				result.ReplyVLANs = append(result.ReplyVLANs, int(tag))
+			}
+		}
+
+		for seq := 0; seq < mod.count && mod.Running(); seq++ {
+			err, raw := packets.NewVLANHopProbe(mod.Session.Interface.HW, mod.source, mod.dstHW, target,
+				uint16(mod.native), uint16(vlan), id, uint16(seq))
+			if err != nil {
+				mod.Error("error creating probe: %v", err)
+				return
+			} else if err = mod.Session.Queue.Send(raw); err != nil {
+				mod.Error("error sending probe: %v", err)
+				return
+			}
+			result.Sent++
+
+			wait := time.Second
+			if seq == mod.count-1 {
+				wait = mod.timeout
+			}
+
+			deadline := time.After(wait)
+			for waiting := true; waiting && mod.Running(); {
+				select {
+				case tag := <-replies:
+					collect(tag)
+				case <-deadline:
+					waiting = false
+				}
+			}
+		}
+
+		sort.Ints(result.ReplyVLANs)
+		result.Exposed = result.Replies > 0
+
+		mod.results.Store(fmt.Sprintf("%d/%s", vlan, target), result)
+		mod.State.Store("results", mod.sortedResults())
+		session.I.Events.Add("vlan.hop", *result)
+
+		if result.Exposed {
+			mod.Warning("%s in VLAN %d answered %d of %d double tagged probes, the network is exposed to VLAN hopping",
+				target, vlan, result.Replies, result.Sent)
+		} else {
+			mod.Info("no answer from %s in VLAN %d, note that the attack is one way and the lack of replies doesn't prove the probes weren't delivered", target, vlan)
+		}
+	})
+}
+
+func (mod *VLANHopper) sortedResults() []*HopResult {
+	results := make([]*HopResult, 0)
+	mod.results.Range(func(k, v interface{}) bool {
+		results = append(results, v.(*HopResult))
+		return true
+	})
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Started.Before(results[j].Started)
+	})
+	return results
+}
+
+func (mod *VLANHopper) show() error {
+	results := mod.sortedResults()
+	if len(results) == 0 {
+		mod.Info("no tests performed yet")
+		return nil
+	}
+
+	colNames := []string{"Started", "Native", "Target VLAN", "Target", "Sent", "Replies", "Reply VLANs", "Exposed"}
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		vlans := make([]string, len(r.ReplyVLANs))
+		for i, v := range r.ReplyVLANs {
+			vlans[i] = strconv.Itoa(v)
+			if v == 0 {
+				vlans[i] = "untagged"
+			}
+		}
+
+		exposed := tui.Green("no")
+		if r.Exposed {
+			exposed = tui.Red("yes")
+		}
+
+		rows = append(rows, []string{
+			r.Started.Format("15:04:05"),
+			strconv.Itoa(r.NativeVLAN),
+			tui.Bold(strconv.Itoa(r.TargetVLAN)),
+			r.Target,
+			strconv.Itoa(r.Sent),
+			strconv.Itoa(r.Replies),
+			tui.Dim(fmt.Sprintf("%v", vlans)),
+			exposed,
+		})
+	}
+
+	tui.Table(mod.Session.Events.Stdout, colNames, rows)
+	mod.Session.Refresh()
+	return nil
+}
@@ -61,6 +61,10 @@ func (mod *WiFiModule) getRow(station *network.Station) ([]string, bool) {
 		}
 	}
 
+	if tags := mod.Session.Tags.String(station.HwAddress); tags != "" {
+		bssid = fmt.Sprintf("%s %s", bssid, tui.Dim("["+tags+"]"))
+	}
+
 	sent := ops.Ternary(station.Sent > 0, humanize.Bytes(station.Sent), "").(string)
 	recvd := ops.Ternary(station.Received > 0, humanize.Bytes(station.Received), "").(string)
 
@@ -168,7 +172,8 @@ func (mod *WiFiModule) doFilter(station *network.Station) bool {
 		mod.selector.Expression.MatchString(station.ESSID()) ||
 		mod.selector.Expression.MatchString(station.Alias) ||
 		mod.selector.Expression.MatchString(station.Vendor) ||
-		mod.selector.Expression.MatchString(station.Encryption)
+		mod.selector.Expression.MatchString(station.Encryption) ||
+		mod.selector.Expression.MatchString(mod.Session.Tags.String(station.HwAddress))
 }
 
 func (mod *WiFiModule) doSelection() (err error, stations []*network.Station) {
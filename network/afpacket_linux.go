@@ -0,0 +1,149 @@
+package network
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/afpacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"golang.org/x/net/bpf"
+)
+
+const (
+	// TPACKETv3 blocks hold a variable number of frames, the ring is 64MB
+	AFPACKET_FRAME_SIZE = 1 << 16
+	AFPACKET_BLOCK_SIZE = AFPACKET_FRAME_SIZE * 32
+	AFPACKET_NUM_BLOCKS = 32
+)
+
+// AFPacketHandle captures packets with an AF_PACKET socket and TPACKETv3
+// ring buffers, dropping less than libpcap on busy links.
+type AFPacketHandle struct {
+	sync.RWMutex
+	tp      *afpacket.TPacket
+	ifName  string
+	promisc bool
+	closed  bool
+}
+
+type ifreqFlags struct {
+	Name  [syscall.IFNAMSIZ]byte
+	Flags uint16
+	_     [22]byte
+}
+
+// setPromisc sets or clears the promiscuous flag of the interface, returning
+// true if it's been changed.
+func setPromisc(ifName string, enabled bool) (bool, error) {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return false, err
+	}
+	defer syscall.Close(fd)
+
+	req := ifreqFlags{}
+	copy(req.Name[:syscall.IFNAMSIZ-1], ifName)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCGIFFLAGS, uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return false, errno
+	}
+
+	if isSet := req.Flags&syscall.IFF_PROMISC != 0; isSet == enabled {
+		return false, nil
+	} else if enabled {
+		req.Flags |= syscall.IFF_PROMISC
+	} else {
+		req.Flags &^= syscall.IFF_PROMISC
+	}
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return false, errno
+	}
+	return true, nil
+}
+
+func CaptureAFPacket(ifName string, timeout time.Duration) (CaptureHandle, error) {
+	Debug("creating AF_PACKET capture for '%s'", ifName)
+
+	tp, err := afpacket.NewTPacket(
+		afpacket.OptInterface(ifName),
+		afpacket.OptFrameSize(AFPACKET_FRAME_SIZE),
+		afpacket.OptBlockSize(AFPACKET_BLOCK_SIZE),
+		afpacket.OptNumBlocks(AFPACKET_NUM_BLOCKS),
+		afpacket.OptPollTimeout(timeout),
+		afpacket.TPacketVersion3)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening interface %s: %s", ifName, err)
+	}
+
+	h := &AFPacketHandle{
+		tp:     tp,
+		ifName: ifName,
+	}
+
+	if PCAP_DEFAULT_PROMISC {
+		if h.promisc, err = setPromisc(ifName, true); err != nil {
+			tp.Close()
+			return nil, fmt.Errorf("error while settng promiscuous mode to true: %s", err)
+		}
+	}
+
+	return h, nil
+}
+
+func (h *AFPacketHandle) ReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	h.RLock()
+	defer h.RUnlock()
+
+	// the ring is unmapped once closed
+	if h.closed {
+		return nil, gopacket.CaptureInfo{}, io.EOF
+	}
+	return h.tp.ReadPacketData()
+}
+
+func (h *AFPacketHandle) LinkType() layers.LinkType {
+	return layers.LinkTypeEthernet
+}
+
+// SetBPFFilter compiles expr with libpcap and attaches it to the socket.
+func (h *AFPacketHandle) SetBPFFilter(expr string) error {
+	compiled, err := pcap.CompileBPFFilter(h.LinkType(), PCAP_DEFAULT_SNAPLEN, expr)
+	if err != nil {
+		return err
+	}
+
+	raw := make([]bpf.RawInstruction, 0, len(compiled))
+	for _, ins := range compiled {
+		raw = append(raw, bpf.RawInstruction{
+			Op: ins.Code,
+			Jt: ins.Jt,
+			Jf: ins.Jf,
+			K:  ins.K,
+		})
+	}
+
+	return h.tp.SetBPF(raw)
+}
+
+func (h *AFPacketHandle) Close() {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+	h.tp.Close()
+
+	if h.promisc {
+		if _, err := setPromisc(h.ifName, false); err != nil {
+			Debug("error while restoring promiscuous mode of %s: %s", h.ifName, err)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package network
+
+import (
+	"errors"
+	"time"
+)
+
+var errAFPacketUnsupported = errors.New("AF_PACKET capture is only supported on Linux")
+
+func CaptureAFPacket(ifName string, timeout time.Duration) (CaptureHandle, error) {
+	return nil, errAFPacketUnsupported
+}
@@ -19,6 +19,8 @@ type LAN struct {
 	hosts   map[string]*Endpoint
 	iface   *Endpoint
 	gateway *Endpoint
+	nets    []*net.IPNet
+	subnets *Subnets
 	ttl     map[string]uint
 	aliases *data.UnsortedKV
 	newCb   EndpointNewCallback
@@ -34,6 +36,7 @@ func NewLAN(iface, gateway *Endpoint, aliases *data.UnsortedKV, newcb EndpointNe
 		iface:   iface,
 		gateway: gateway,
 		hosts:   make(map[string]*Endpoint),
+		nets:    make([]*net.IPNet, 0),
 		ttl:     make(map[string]uint),
 		aliases: aliases,
 		newCb:   newcb,
@@ -82,7 +85,7 @@ func (lan *LAN) GetByIp(ip string) *Endpoint {
 	}
 
 	for _, e := range lan.hosts {
-		if e.IpAddress == ip {
+		if e.IpAddress == ip || e.HasIPv6(ip) {
 			return e
 		}
 	}
@@ -90,6 +93,21 @@ func (lan *LAN) GetByIp(ip string) *Endpoint {
 	return nil
 }
 
+// FindByName returns the endpoints having name as their alias, host name
+// or any of the names discovered for them via mDNS, NBNS and so on.
+func (lan *LAN) FindByName(name string) []*Endpoint {
+	lan.Lock()
+	defer lan.Unlock()
+
+	found := make([]*Endpoint, 0)
+	for _, e := range lan.hosts {
+		if e.HasName(name) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
 func (lan *LAN) List() (list []*Endpoint) {
 	lan.Lock()
 	defer lan.Unlock()
@@ -135,12 +153,15 @@ func (lan *LAN) Remove(ip, mac string) {
 }
 
 func (lan *LAN) shouldIgnore(ip, mac string) bool {
+	addr := net.ParseIP(ip)
+	routed := addr != nil && lan.isRouted(addr)
+
 	// skip our own address
 	if ip == lan.iface.IpAddress || mac == lan.iface.HwAddress {
 		return true
 	}
-	// skip the gateway
-	if ip == lan.gateway.IpAddress || mac == lan.gateway.HwAddress {
+	// skip the gateway, but not the hosts behind it sharing its mac
+	if ip == lan.gateway.IpAddress || (mac == lan.gateway.HwAddress && !routed) {
 		return true
 	}
 	// skip broadcast addresses
@@ -151,9 +172,62 @@ func (lan *LAN) shouldIgnore(ip, mac string) bool {
 	if strings.ToLower(mac) == BroadcastMac {
 		return true
 	}
-	// skip everything which is not in our subnet (multicast noise)
-	addr := net.ParseIP(ip)
-	return addr.To4() != nil && !lan.iface.Net.Contains(addr)
+	// skip everything which is not in our subnets (multicast noise)
+	if addr == nil || addr.IsMulticast() {
+		return true
+	}
+	return addr.To4() != nil && !lan.inNetworks(addr) && !routed
+}
+
+func (lan *LAN) inNetworks(addr net.IP) bool {
+	if lan.iface.Net.Contains(addr) {
+		return true
+	}
+	for _, n := range lan.nets {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRouted returns true if addr belongs to one of the subnets reachable
+// through the gateway rather than to the ones we're attached to.
+func (lan *LAN) isRouted(addr net.IP) bool {
+	return lan.subnets != nil && !lan.inNetworks(addr) && lan.subnets.Contains(addr) != nil
+}
+
+// SetSubnets sets the subnets reachable through the gateway whose hosts are
+// part of the LAN too, since they all share the mac of the gateway they're
+// tracked by address.
+func (lan *LAN) SetSubnets(subnets *Subnets) {
+	lan.Lock()
+	defer lan.Unlock()
+	lan.subnets = subnets
+}
+
+// AddNetwork registers an additional subnet whose hosts are
+// part of the LAN, such as the one of a VLAN sub interface.
+func (lan *LAN) AddNetwork(n *net.IPNet) {
+	lan.Lock()
+	defer lan.Unlock()
+
+	for _, known := range lan.nets {
+		if known.String() == n.String() {
+			return
+		}
+	}
+	lan.nets = append(lan.nets, n)
+}
+
+// Networks returns the additional subnets registered with AddNetwork.
+func (lan *LAN) Networks() []*net.IPNet {
+	lan.Lock()
+	defer lan.Unlock()
+
+	nets := make([]*net.IPNet, len(lan.nets))
+	copy(nets, lan.nets)
+	return nets
 }
 
 func (lan *LAN) Has(ip string) bool {
@@ -161,7 +235,7 @@ func (lan *LAN) Has(ip string) bool {
 	defer lan.Unlock()
 
 	for _, e := range lan.hosts {
-		if e.IpAddress == ip {
+		if e.IpAddress == ip || e.HasIPv6(ip) {
 			return true
 		}
 	}
@@ -186,17 +260,36 @@ func (lan *LAN) AddIfNew(ip, mac string) *Endpoint {
 
 	if lan.shouldIgnore(ip, mac) {
 		return nil
-	} else if t, found := lan.hosts[mac]; found {
-		if lan.ttl[mac] < LANDefaultttl {
-			lan.ttl[mac]++
+	}
+
+	addr := net.ParseIP(ip)
+	isIPv6 := addr.To4() == nil
+
+	key := mac
+	if lan.isRouted(addr) {
+		key = ip
+	}
+
+	if t, found := lan.hosts[key]; found {
+		if lan.ttl[key] < LANDefaultttl {
+			lan.ttl[key]++
+		}
+		if isIPv6 {
+			t.AddIPv6(addr)
+		} else if t.IP.To4() == nil {
+			// first seen as IPv6 only, now we have its IPv4 too
+			t.SetIP(ip)
 		}
 		return t
 	}
 
-	e := NewEndpointWithAlias(ip, mac, lan.aliases.GetOr(mac, ""))
+	e := NewEndpointWithAlias(ip, mac, lan.aliases.GetOr(key, ""))
+	if isIPv6 {
+		e.AddIPv6(addr)
+	}
 
-	lan.hosts[mac] = e
-	lan.ttl[mac] = LANDefaultttl
+	lan.hosts[key] = e
+	lan.ttl[key] = LANDefaultttl
 
 	lan.newCb(e)
 
@@ -21,12 +21,15 @@ type Endpoint struct {
 	HW               net.HardwareAddr       `json:"-"`
 	IpAddress        string                 `json:"ipv4"`
 	Ip6Address       string                 `json:"ipv6"`
+	Ip6LinkLocal     string                 `json:"ipv6_link_local"`
 	SubnetBits       uint32                 `json:"-"`
 	IpAddressUint32  uint32                 `json:"-"`
 	HwAddress        string                 `json:"mac"`
 	Hostname         string                 `json:"hostname"`
 	Alias            string                 `json:"alias"`
 	Vendor           string                 `json:"vendor"`
+	OS               string                 `json:"os"`
+	Device           string                 `json:"device"`
 	ResolvedCallback OnHostResolvedCallback `json:"-"`
 	FirstSeen        time.Time              `json:"first_seen"`
 	LastSeen         time.Time              `json:"last_seen"`
@@ -62,16 +65,7 @@ func NewEndpointNoResolve(ip, mac, name string, bits uint32) *Endpoint {
 
 func NewEndpoint(ip, mac string) *Endpoint {
 	e := NewEndpointNoResolve(ip, mac, "", 0)
-	// start resolver goroutine
-	go func() {
-		if names, err := net.LookupAddr(e.IpAddress); err == nil && len(names) > 0 {
-			e.Hostname = names[0]
-			if e.ResolvedCallback != nil {
-				e.ResolvedCallback(e)
-			}
-		}
-	}()
-
+	DefaultResolver.Resolve(e)
 	return e
 }
 
@@ -105,7 +99,59 @@ func (t *Endpoint) SetIPv6(netw string) {
 	t.IPv6 = net.ParseIP(address)
 	if t.IPv6 != nil {
 		t.Ip6Address = t.IPv6.String()
+		if t.IPv6.IsLinkLocalUnicast() {
+			t.Ip6LinkLocal = t.Ip6Address
+		}
+	}
+}
+
+// AddIPv6 keeps track of an IPv6 address discovered for this endpoint, global
+// addresses take precedence over link-local ones for the main IPv6 field.
+func (t *Endpoint) AddIPv6(ip net.IP) {
+	if ip == nil || ip.To4() != nil {
+		return
+	}
+
+	if ip.IsLinkLocalUnicast() {
+		t.Ip6LinkLocal = ip.String()
+		if t.IPv6 != nil && !t.IPv6.IsLinkLocalUnicast() {
+			return
+		}
+	}
+
+	t.IPv6 = ip
+	t.Ip6Address = ip.String()
+}
+
+// HasName returns true if name matches, case insensitively and regardless
+// of the .local suffix, the alias, the host name or any name found in the
+// meta data of the endpoint.
+func (t *Endpoint) HasName(name string) bool {
+	normalize := func(s string) string {
+		return strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(s), "."), ".local")
+	}
+
+	if name = normalize(name); name == "" {
+		return false
 	}
+
+	names := []string{t.Alias, t.Hostname}
+	t.Meta.Each(func(k string, v interface{}) {
+		if s, ok := v.(string); ok && (strings.HasSuffix(k, ":hostname") || k == "mdns:md") {
+			names = append(names, s)
+		}
+	})
+
+	for _, n := range names {
+		if normalize(n) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Endpoint) HasIPv6(ip string) bool {
+	return ip != "" && (t.Ip6Address == ip || t.Ip6LinkLocal == ip)
 }
 
 func (t *Endpoint) SetIP(ip string) {
@@ -169,6 +215,14 @@ func (t *Endpoint) OnMeta(meta map[string]string) {
 			host = v
 		} else if k == "mdns:md" && len(v) > len(host) {
 			host = v
+		} else if k == "os:guess" {
+			t.OS = v
+		} else if k == "device:type" {
+			t.Device = v
+		} else if strings.HasSuffix(k, ":services") || k == "vlan:ids" || k == "mld:groups" {
+			// lists of services, vlans and groups are merged with what we already know
+			t.Meta.SetStrings(k, t.Meta.GetStringsWith(k, strings.Split(v, ","), true))
+			continue
 		}
 		t.Meta.Set(k, v)
 	}
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"net"
 	"testing"
 
 	"github.com/evilsocket/islazy/data"
@@ -207,3 +208,90 @@ func TestShouldIgnore(t *testing.T) {
 		t.Fatalf("expected '%v', got '%v'", exp, got)
 	}
 }
+
+func TestEndpointAddIPv6(t *testing.T) {
+	e := NewEndpointNoResolve("192.168.1.2", "aa:bb:cc:dd:ee:ff", "", 24)
+
+	e.AddIPv6(net.ParseIP("fe80::1"))
+	if e.Ip6Address != "fe80::1" || e.Ip6LinkLocal != "fe80::1" {
+		t.Fatalf("unexpected addresses '%s' and '%s'", e.Ip6Address, e.Ip6LinkLocal)
+	}
+
+	e.AddIPv6(net.ParseIP("2001:db8::1"))
+	e.AddIPv6(net.ParseIP("fe80::2"))
+	if e.Ip6Address != "2001:db8::1" {
+		t.Fatalf("expected global address, got '%s'", e.Ip6Address)
+	} else if e.Ip6LinkLocal != "fe80::2" {
+		t.Fatalf("expected 'fe80::2', got '%s'", e.Ip6LinkLocal)
+	} else if !e.HasIPv6("2001:db8::1") || !e.HasIPv6("fe80::2") {
+		t.Fatal("expected endpoint to have both addresses")
+	}
+
+	e.AddIPv6(net.ParseIP("10.0.0.1"))
+	if e.Ip6Address != "2001:db8::1" {
+		t.Fatalf("IPv4 address should have been ignored, got '%s'", e.Ip6Address)
+	}
+}
+
+func TestEndpointHasName(t *testing.T) {
+	e := NewEndpointNoResolve("192.168.1.10", "aa:bb:cc:dd:ee:ff", "Johns-MacBook.local.", 24)
+	e.Alias = "john"
+	e.Meta.Set("nbns:hostname", "JOHNPC")
+
+	for _, name := range []string{"john", "johns-macbook", "JOHNS-MACBOOK.local", "johnpc"} {
+		if !e.HasName(name) {
+			t.Fatalf("expected %s to match", name)
+		}
+	}
+
+	for _, name := range []string{"", "johns", "macbook.local"} {
+		if e.HasName(name) {
+			t.Fatalf("expected %s not to match", name)
+		}
+	}
+}
+
+func TestAddNetwork(t *testing.T) {
+	iface := NewEndpointNoResolve("192.168.1.2", "aa:bb:cc:dd:ee:01", "", 24)
+	gateway := NewEndpointNoResolve("192.168.1.1", "aa:bb:cc:dd:ee:02", "", 24)
+	lan := NewLAN(iface, gateway, &data.UnsortedKV{}, func(e *Endpoint) {}, func(e *Endpoint) {})
+
+	if !lan.shouldIgnore("10.0.10.5", "aa:bb:cc:dd:ee:03") {
+		t.Fatal("address outside of the interface subnet should have been ignored")
+	}
+
+	_, vlan, _ := net.ParseCIDR("10.0.10.0/24")
+	lan.AddNetwork(vlan)
+	lan.AddNetwork(vlan)
+
+	if nets := lan.Networks(); len(nets) != 1 {
+		t.Fatalf("expected 1 network, got %d", len(nets))
+	} else if lan.shouldIgnore("10.0.10.5", "aa:bb:cc:dd:ee:03") {
+		t.Fatal("address of an additional network should not have been ignored")
+	}
+}
+
+func TestRoutedSubnets(t *testing.T) {
+	iface := NewEndpointNoResolve("192.168.1.2", "aa:bb:cc:dd:ee:01", "", 24)
+	gateway := NewEndpointNoResolve("192.168.1.1", "aa:bb:cc:dd:ee:02", "", 24)
+	lan := NewLAN(iface, gateway, &data.UnsortedKV{}, func(e *Endpoint) {}, func(e *Endpoint) {})
+
+	subnets := NewSubnets(nil)
+	_, routed, _ := net.ParseCIDR("10.0.20.0/24")
+	subnets.Add(routed, "manual")
+	lan.SetSubnets(subnets)
+
+	if !lan.shouldIgnore("10.0.30.5", gateway.HwAddress) {
+		t.Fatal("address outside of the known subnets should have been ignored")
+	}
+
+	// hosts behind the gateway share its mac
+	lan.AddIfNew("10.0.20.5", gateway.HwAddress)
+	lan.AddIfNew("10.0.20.6", gateway.HwAddress)
+
+	if n := len(lan.List()); n != 2 {
+		t.Fatalf("expected 2 routed hosts, got %d", n)
+	} else if e := lan.GetByIp("10.0.20.6"); e == nil || e.HwAddress != gateway.HwAddress {
+		t.Fatalf("unexpected endpoint %v", e)
+	}
+}
@@ -42245,7 +42245,7 @@ func ManufLookup(mac string) string {
     for mask := uint(0); mask < 48; mask++ {
         shifted := new(big.Int).Rsh(macInt, mask)
         key := fmt.Sprintf("%d.%s", mask, shifted)
-        if vendor, found := manuf[key]; found {
+        if vendor, found := manufGet(key); found {
             return vendor
         }   
     }
@@ -0,0 +1,173 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// IEEE registries, MA-L is the classic 24 bits OUI.
+	ManufRegistryL = "MA-L"
+	ManufRegistryM = "MA-M"
+	ManufRegistryS = "MA-S"
+)
+
+var (
+	manufLock      = sync.RWMutex{}
+	manufUpdates   = make(map[string]string)
+	manufOverrides = make(map[string]string)
+
+	manufLineParser = regexp.MustCompile(`^([0-9a-fA-F:\-\.]+)(/\d+)?\s+(.+)$`)
+	manufRegistries = map[string]int{
+		ManufRegistryL: 24,
+		ManufRegistryM: 28,
+		ManufRegistryS: 36,
+	}
+)
+
+// the lookup keys are the amount of bits masked from the 48 bits address
+// followed by the value of the remaining prefix, see make_manuf.py
+func manufKey(prefix string, bits int) (string, error) {
+	prefixHex := strings.NewReplacer(":", "", "-", "", ".", "").Replace(prefix)
+	if prefixHex == "" || len(prefixHex) > 12 {
+		return "", fmt.Errorf("invalid prefix '%s'", prefix)
+	}
+
+	prefixInt := new(big.Int)
+	if _, ok := prefixInt.SetString(prefixHex, 16); !ok {
+		return "", fmt.Errorf("invalid prefix '%s'", prefix)
+	}
+
+	available := 4 * len(prefixHex)
+	if bits <= 0 {
+		bits = available
+	} else if bits > available || bits > 48 {
+		return "", fmt.Errorf("prefix '%s' is shorter than %d bits", prefix, bits)
+	}
+
+	prefixInt.Rsh(prefixInt, uint(available-bits))
+	return fmt.Sprintf("%d.%s", 48-bits, prefixInt), nil
+}
+
+func manufGet(key string) (string, bool) {
+	manufLock.RLock()
+	defer manufLock.RUnlock()
+
+	if vendor, found := manufOverrides[key]; found {
+		return vendor, true
+	} else if vendor, found := manufUpdates[key]; found {
+		return vendor, true
+	}
+	vendor, found := manuf[key]
+	return vendor, found
+}
+
+func manufParseCSV(line string) (key string, vendor string, err error) {
+	fields, err := csv.NewReader(strings.NewReader(line)).Read()
+	if err != nil {
+		return "", "", err
+	} else if len(fields) < 3 {
+		return "", "", fmt.Errorf("unexpected number of fields")
+	}
+
+	bits, found := manufRegistries[fields[0]]
+	if !found {
+		return "", "", fmt.Errorf("unknown registry '%s'", fields[0])
+	}
+
+	if key, err = manufKey(fields[1], bits); err != nil {
+		return "", "", err
+	}
+	return key, strings.TrimSpace(fields[2]), nil
+}
+
+func manufParseLine(line string) (key string, vendor string, err error) {
+	m := manufLineParser.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", fmt.Errorf("unexpected line format")
+	}
+
+	bits := 0
+	if m[2] != "" {
+		bits, _ = strconv.Atoi(m[2][1:])
+	}
+
+	if key, err = manufKey(m[1], bits); err != nil {
+		return "", "", err
+	}
+
+	// wireshark format has a short name followed by an optional long one
+	vendor = m[3]
+	if idx := strings.Index(vendor, "#"); idx != -1 {
+		vendor = vendor[:idx]
+	}
+	if parts := strings.SplitN(strings.TrimSpace(vendor), "\t", 2); len(parts) == 2 {
+		vendor = parts[1]
+	}
+	return key, strings.TrimSpace(vendor), nil
+}
+
+// ManufParse parses either the IEEE CSV registries or the wireshark
+// manuf format ("PREFIX[/BITS] VENDOR" per line), returning the entries
+// indexed by lookup key; invalid lines are skipped.
+func ManufParse(data []byte) map[string]string {
+	entries := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		var key, vendor string
+		var err error
+		if strings.HasPrefix(line, "MA-") {
+			key, vendor, err = manufParseCSV(line)
+		} else {
+			key, vendor, err = manufParseLine(line)
+		}
+
+		if err == nil && vendor != "" {
+			entries[key] = vendor
+		}
+	}
+	return entries
+}
+
+// ManufLoad reads and parses a vendors database from fileName.
+func ManufLoad(fileName string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := ManufParse(data)
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no vendors found in %s", fileName)
+	}
+	return entries, nil
+}
+
+// ManufUpdate replaces the entries that take precedence over the
+// compiled in vendors table, typically from a fresh IEEE database.
+func ManufUpdate(entries map[string]string) {
+	manufLock.Lock()
+	defer manufLock.Unlock()
+	manufUpdates = entries
+}
+
+// ManufOverride replaces the user defined entries, these take precedence
+// over both the compiled in and the updated tables.
+func ManufOverride(entries map[string]string) {
+	manufLock.Lock()
+	defer manufLock.Unlock()
+	manufOverrides = entries
+}
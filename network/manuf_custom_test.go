@@ -0,0 +1,58 @@
+package network
+
+import (
+	"testing"
+)
+
+func TestManufParse(t *testing.T) {
+	data := []byte(`# comment
+Registry,Assignment,Organization Name,Organization Address
+MA-L,AABBCC,"Acme, Inc.",Somewhere
+MA-S,70B3D5F2E,Tiny Corp,Elsewhere
+00:00:18	WebsterC	Webster Computer Corporation	# Appletalk/Ethernet Gateway
+02:00:00	Local Lab Devices
+00:1B:C5:00:00:00/36	Converging Systems
+not a line
+`)
+
+	entries := ManufParse(data)
+	if len(entries) != 5 {
+		t.Fatalf("expected 5 entries, got %d: %v", len(entries), entries)
+	}
+
+	var units = []struct {
+		mac      string
+		expected string
+	}{
+		{"aa:bb:cc:11:22:33", "Acme, Inc."},
+		{"70:b3:d5:f2:e1:23", "Tiny Corp"},
+		{"00:00:18:01:02:03", "Webster Computer Corporation"},
+		{"02:00:00:aa:bb:cc", "Local Lab Devices"},
+		{"00:1b:c5:00:00:42", "Converging Systems"},
+	}
+
+	ManufUpdate(entries)
+	defer ManufUpdate(make(map[string]string))
+
+	for _, u := range units {
+		if got := ManufLookup(u.mac); got != u.expected {
+			t.Fatalf("expected '%s' for %s, got '%s'", u.expected, u.mac, got)
+		}
+	}
+}
+
+func TestManufOverride(t *testing.T) {
+	mac := "aa:bb:cc:11:22:33"
+
+	ManufUpdate(ManufParse([]byte("MA-L,AABBCC,Acme,Somewhere")))
+	defer ManufUpdate(make(map[string]string))
+
+	ManufOverride(ManufParse([]byte("aa:bb:cc:11:22:33 My Laptop")))
+	defer ManufOverride(make(map[string]string))
+
+	if got := ManufLookup(mac); got != "My Laptop" {
+		t.Fatalf("expected override, got '%s'", got)
+	} else if got = ManufLookup("aa:bb:cc:11:22:34"); got != "Acme" {
+		t.Fatalf("expected updated vendor, got '%s'", got)
+	}
+}
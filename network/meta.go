@@ -73,6 +73,21 @@ func (m *Meta) SetInts(name string, ints []int) {
 	m.Set(name, strings.Join(list, ","))
 }
 
+func (m *Meta) GetStringsWith(name string, with []string, sorted bool) []string {
+	list := append([]string{}, with...)
+	for _, s := range strings.Split(m.Get(name).(string), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+
+	return core.UniqueStrings(list, sorted)
+}
+
+func (m *Meta) SetStrings(name string, list []string) {
+	m.Set(name, strings.Join(list, ","))
+}
+
 func (m *Meta) GetOr(name string, dflt interface{}) interface{} {
 	m.Lock()
 	defer m.Unlock()
@@ -156,6 +156,37 @@ func ParseTargets(targets string, aliasMap *data.UnsortedKV) (ips []net.IP, macs
 	return
 }
 
+func isAlias(name string, aliasMap *data.UnsortedKV) bool {
+	found := false
+	aliasMap.Each(func(mac, alias string) bool {
+		found = alias == name
+		return found
+	})
+	return found
+}
+
+// SplitTargetNames removes from targets the host names which are not
+// addresses, ranges or aliases, so that they can be resolved against the
+// endpoints table every time, returning them and what's left for ParseTargets.
+func SplitTargetNames(targets string, aliasMap *data.UnsortedKV) (string, []string) {
+	names := make([]string, 0)
+	rest := make([]string, 0)
+
+	for _, target := range strings.Split(targets, ",") {
+		if target = str.Trim(target); target == "" {
+			continue
+		} else if macParser.MatchString(target) || isAlias(target, aliasMap) {
+			rest = append(rest, target)
+		} else if _, err := iprange.ParseList(target); err == nil {
+			rest = append(rest, target)
+		} else {
+			names = append(names, target)
+		}
+	}
+
+	return strings.Join(rest, ", "), names
+}
+
 func ParseEndpoints(targets string, lan *LAN) ([]*Endpoint, error) {
 	ips, macs, err := ParseTargets(targets, lan.Aliases())
 	if err != nil {
@@ -297,6 +328,17 @@ func ActivateInterface(name string) error {
 	return nil
 }
 
+// VLANInterfaceName returns the name of the 802.1Q sub interface
+// of parent for the given VLAN identifier.
+func VLANInterfaceName(parent string, id int) string {
+	name := fmt.Sprintf("%s.%d", parent, id)
+	// most systems limit interface names to 15 characters
+	if len(name) > 15 {
+		name = fmt.Sprintf("vlan%d", id)
+	}
+	return name
+}
+
 func SetInterfaceTxPower(name string, txpower int) error {
 	if core.HasBinary("iw") {
 		Debug("SetInterfaceTxPower(%s, %d) iw based", name, txpower)
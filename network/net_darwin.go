@@ -58,3 +58,11 @@ func GetSupportedFrequencies(iface string) ([]int, error) {
 	}
 	return getFrequenciesFromChannels(out)
 }
+
+func CreateVLANInterface(parent string, id int) (string, error) {
+	return "", fmt.Errorf("macOS does not support VLAN sub interfaces.")
+}
+
+func DeleteVLANInterface(name string) error {
+	return fmt.Errorf("macOS does not support VLAN sub interfaces.")
+}
@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/bettercap/bettercap/core"
 )
@@ -132,3 +133,76 @@ func GetSupportedFrequencies(iface string) ([]int, error) {
 
 	return nil, fmt.Errorf("no iw or iwlist binaries found in $PATH")
 }
+
+// vlanIface keeps track of who's using a sub interface and of whether it
+// existed before.
+type vlanIface struct {
+	refs    int
+	created bool
+}
+
+var (
+	vlanLock   = sync.Mutex{}
+	vlanIfaces = make(map[string]*vlanIface)
+)
+
+// CreateVLANInterface creates and activates the 802.1Q sub interface of
+// parent for the given VLAN identifier, if it does not exist already, and
+// returns its name, every call must be paired with DeleteVLANInterface.
+func CreateVLANInterface(parent string, id int) (string, error) {
+	if id < 1 || id > 4094 {
+		return "", fmt.Errorf("invalid VLAN identifier %d", id)
+	}
+
+	vlanLock.Lock()
+	defer vlanLock.Unlock()
+
+	name := VLANInterfaceName(parent, id)
+	if vlan, found := vlanIfaces[name]; found {
+		vlan.refs++
+		return name, nil
+	} else if _, err := net.InterfaceByName(name); err == nil {
+		vlanIfaces[name] = &vlanIface{refs: 1}
+		return name, nil
+	} else if !core.HasBinary("ip") {
+		return "", fmt.Errorf("no ip binary found in $PATH")
+	}
+
+	if out, err := core.Exec("ip", []string{"link", "add", "link", parent, "name", name, "type", "vlan", "id", fmt.Sprintf("%d", id)}); err != nil {
+		return "", fmt.Errorf("ip: out=%s err=%s", out, err)
+	} else if out, err = core.Exec("ip", []string{"link", "set", name, "up"}); err != nil {
+		deleteLink(name)
+		return "", fmt.Errorf("ip: out=%s err=%s", out, err)
+	}
+
+	vlanIfaces[name] = &vlanIface{refs: 1, created: true}
+	return name, nil
+}
+
+// DeleteVLANInterface releases a sub interface returned by
+// CreateVLANInterface, removing it once it's not used anymore if it didn't
+// exist before.
+func DeleteVLANInterface(name string) error {
+	vlanLock.Lock()
+	defer vlanLock.Unlock()
+
+	vlan, found := vlanIfaces[name]
+	if !found {
+		return fmt.Errorf("%s is not in use", name)
+	} else if vlan.refs--; vlan.refs > 0 {
+		return nil
+	}
+
+	delete(vlanIfaces, name)
+	if vlan.created {
+		return deleteLink(name)
+	}
+	return nil
+}
+
+func deleteLink(name string) error {
+	if out, err := core.Exec("ip", []string{"link", "delete", name}); err != nil {
+		return fmt.Errorf("ip: out=%s err=%s", out, err)
+	}
+	return nil
+}
@@ -102,6 +102,31 @@ func TestParseTargets(t *testing.T) {
 	}
 }
 
+func TestSplitTargetNames(t *testing.T) {
+	aliasMap, err := data.NewMemUnsortedKV()
+	if err != nil {
+		panic(err)
+	}
+	aliasMap.Set("5c:00:0b:90:a9:f0", "test_alias")
+
+	rest, names := SplitTargetNames("192.168.1.2, 10.0.0.1-10,test_alias, nas.local, 5c:00:0b:90:a9:f1, Johns-iPhone", aliasMap)
+	if rest != "192.168.1.2, 10.0.0.1-10, test_alias, 5c:00:0b:90:a9:f1" {
+		t.Fatalf("unexpected targets '%s'", rest)
+	} else if len(names) != 2 || names[0] != "nas.local" || names[1] != "Johns-iPhone" {
+		t.Fatalf("unexpected names %v", names)
+	}
+
+	if ips, macs, err := ParseTargets(rest, aliasMap); err != nil {
+		t.Fatal(err)
+	} else if len(ips) != 11 || len(macs) != 2 {
+		t.Fatalf("unexpected targets %v %v", ips, macs)
+	}
+
+	if rest, names = SplitTargetNames("", aliasMap); rest != "" || len(names) != 0 {
+		t.Fatalf("unexpected split '%s' %v", rest, names)
+	}
+}
+
 func TestBuildEndpointFromInterface(t *testing.T) {
 	ifaces, err := net.Interfaces()
 	if err != nil {
@@ -167,3 +192,21 @@ func TestFindInterface(t *testing.T) {
 		t.Error("unable to find a given interface by name to build endpoint")
 	}
 }
+
+func TestVLANInterfaceName(t *testing.T) {
+	cases := []struct {
+		parent   string
+		id       int
+		expected string
+	}{
+		{"eth0", 10, "eth0.10"},
+		{"wlan0", 4094, "wlan0.4094"},
+		{"enx00e04c680001", 100, "vlan100"},
+	}
+
+	for _, c := range cases {
+		if got := VLANInterfaceName(c.parent, c.id); got != c.expected {
+			t.Fatalf("expected '%s', got '%s'", c.expected, got)
+		}
+	}
+}
@@ -53,3 +53,11 @@ func GetSupportedFrequencies(iface string) ([]int, error) {
 	freqs := make([]int, 0)
 	return freqs, fmt.Errorf("Windows does not support WiFi channel hopping.")
 }
+
+func CreateVLANInterface(parent string, id int) (string, error) {
+	return "", fmt.Errorf("Windows does not support VLAN sub interfaces.")
+}
+
+func DeleteVLANInterface(name string) error {
+	return fmt.Errorf("Windows does not support VLAN sub interfaces.")
+}
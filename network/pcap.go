@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/evilsocket/islazy/tui"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
 )
 
@@ -24,6 +26,14 @@ var CAPTURE_DEFAULTS = CaptureOptions{
 	Timeout: PCAP_DEFAULT_TIMEOUT,
 }
 
+// CaptureHandle is implemented by the libpcap and AF_PACKET captures.
+type CaptureHandle interface {
+	gopacket.PacketDataSource
+	LinkType() layers.LinkType
+	SetBPFFilter(expr string) error
+	Close()
+}
+
 type CaptureOptions struct {
 	Monitor bool
 	Snaplen int
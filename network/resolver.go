@@ -0,0 +1,158 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ResolverWorkers   = 4
+	ResolverTimeout   = time.Duration(2) * time.Second
+	ResolverQueueSize = 1024
+)
+
+// ResolverLookup returns the hostname of an address using a specific protocol.
+type ResolverLookup func(address string, timeout time.Duration) (string, error)
+
+type resolverLookup struct {
+	Name   string
+	Lookup ResolverLookup
+}
+
+// Resolver reverse resolves endpoint addresses in background with a pool
+// of workers, concurrent requests for the same address are deduplicated.
+type Resolver struct {
+	sync.Mutex
+	dropped uint64
+	timeout time.Duration
+	lookups []resolverLookup
+	pending map[string][]*Endpoint
+	jobs    chan string
+}
+
+// DefaultResolver is used by NewEndpoint, other protocols than
+// DNS can be added to it with AddLookup.
+var DefaultResolver = NewResolver(ResolverWorkers, ResolverTimeout).AddLookup("ptr", PTRLookup)
+
+func NewResolver(workers int, timeout time.Duration) *Resolver {
+	r := &Resolver{
+		timeout: timeout,
+		lookups: make([]resolverLookup, 0),
+		pending: make(map[string][]*Endpoint),
+		jobs:    make(chan string, ResolverQueueSize),
+	}
+
+	for i := 0; i < workers; i++ {
+		go r.worker()
+	}
+
+	return r
+}
+
+// AddLookup adds a lookup method, methods are tried in the order
+// they've been added until one of them returns a hostname.
+func (r *Resolver) AddLookup(name string, lookup ResolverLookup) *Resolver {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, l := range r.lookups {
+		if l.Name == name {
+			return r
+		}
+	}
+
+	r.lookups = append(r.lookups, resolverLookup{
+		Name:   name,
+		Lookup: lookup,
+	})
+	return r
+}
+
+// Resolve schedules the resolution of the endpoint address, without blocking.
+func (r *Resolver) Resolve(e *Endpoint) {
+	address := e.IpAddress
+	if address == "" || address == MonitorModeAddress {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if waiting, found := r.pending[address]; found {
+		r.pending[address] = append(waiting, e)
+		return
+	}
+	r.pending[address] = []*Endpoint{e}
+
+	select {
+	case r.jobs <- address:
+	default:
+		// queue is full, don't block the caller nor pile up goroutines
+		// waiting for room
+		delete(r.pending, address)
+		atomic.AddUint64(&r.dropped, 1)
+	}
+}
+
+// Dropped returns how many resolutions have been skipped because the
+// queue was full.
+func (r *Resolver) Dropped() uint64 {
+	return atomic.LoadUint64(&r.dropped)
+}
+
+func (r *Resolver) lookup(address string) string {
+	r.Lock()
+	lookups := append([]resolverLookup{}, r.lookups...)
+	r.Unlock()
+
+	for _, l := range lookups {
+		if name, err := l.Lookup(address, r.timeout); err == nil && name != "" {
+			Debug("resolved %s as %s via %s", address, name, l.Name)
+			return name
+		}
+	}
+	return ""
+}
+
+func (r *Resolver) worker() {
+	for address := range r.jobs {
+		name := r.lookup(address)
+
+		r.Lock()
+		endpoints := r.pending[address]
+		delete(r.pending, address)
+		r.Unlock()
+
+		if name == "" {
+			continue
+		}
+
+		for _, e := range endpoints {
+			// don't override names we already learned otherwise
+			if e.Hostname == "" {
+				e.Hostname = name
+				if e.ResolvedCallback != nil {
+					e.ResolvedCallback(e)
+				}
+			}
+		}
+	}
+}
+
+// PTRLookup resolves address with a DNS PTR query.
+func PTRLookup(address string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	names, err := net.DefaultResolver.LookupAddr(ctx, address)
+	if err != nil {
+		return "", err
+	} else if len(names) == 0 {
+		return "", fmt.Errorf("no PTR records for %s", address)
+	}
+	return names[0], nil
+}
@@ -0,0 +1,84 @@
+package network
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResolverDeduplicatesAndFallsBack(t *testing.T) {
+	var ptrCalls, mdnsCalls int32
+	release := make(chan bool)
+
+	r := NewResolver(2, time.Second).
+		AddLookup("ptr", func(address string, timeout time.Duration) (string, error) {
+			atomic.AddInt32(&ptrCalls, 1)
+			<-release
+			return "", fmt.Errorf("no PTR records")
+		}).
+		AddLookup("mdns", func(address string, timeout time.Duration) (string, error) {
+			atomic.AddInt32(&mdnsCalls, 1)
+			return "host.local", nil
+		})
+
+	resolved := make(chan *Endpoint, 2)
+	a := NewEndpointNoResolve("192.168.1.10", "aa:bb:cc:dd:ee:01", "", 24)
+	b := NewEndpointNoResolve("192.168.1.10", "aa:bb:cc:dd:ee:01", "", 24)
+	a.ResolvedCallback = func(e *Endpoint) { resolved <- e }
+	b.ResolvedCallback = func(e *Endpoint) { resolved <- e }
+
+	r.Resolve(a)
+	r.Resolve(b)
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case e := <-resolved:
+			if e.Hostname != "host.local" {
+				t.Fatalf("unexpected hostname '%s'", e.Hostname)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout while waiting for the resolution")
+		}
+	}
+
+	if n := atomic.LoadInt32(&ptrCalls); n != 1 {
+		t.Fatalf("expected 1 ptr lookup, got %d", n)
+	} else if n = atomic.LoadInt32(&mdnsCalls); n != 1 {
+		t.Fatalf("expected 1 mdns lookup, got %d", n)
+	}
+}
+
+func TestResolverKeepsKnownHostname(t *testing.T) {
+	done := make(chan bool)
+	r := NewResolver(1, time.Second).AddLookup("ptr", func(address string, timeout time.Duration) (string, error) {
+		defer close(done)
+		return "ptr.example.com", nil
+	})
+
+	e := NewEndpointNoResolve("192.168.1.11", "aa:bb:cc:dd:ee:02", "known", 24)
+	r.Resolve(e)
+
+	<-done
+	time.Sleep(50 * time.Millisecond)
+	if e.Hostname != "known" {
+		t.Fatalf("hostname should not have been overridden, got '%s'", e.Hostname)
+	}
+}
+
+func TestResolverDropsWhenFull(t *testing.T) {
+	// no workers, nothing is ever taken out of the queue
+	r := NewResolver(0, time.Second)
+
+	for i := 0; i <= ResolverQueueSize; i++ {
+		ip := fmt.Sprintf("10.%d.%d.1", i/256, i%256)
+		r.Resolve(NewEndpointNoResolve(ip, "aa:bb:cc:dd:ee:03", "", 24))
+	}
+
+	if n := r.Dropped(); n != 1 {
+		t.Fatalf("expected 1 dropped lookup, got %d", n)
+	} else if n := len(r.pending); n != ResolverQueueSize {
+		t.Fatalf("expected %d pending lookups, got %d", ResolverQueueSize, n)
+	}
+}
@@ -0,0 +1,108 @@
+package network
+
+import (
+	"encoding/json"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Subnet is a network which is not directly attached to the
+// interface but can be reached through the gateway.
+type Subnet struct {
+	Net       *net.IPNet `json:"-"`
+	CIDR      string     `json:"cidr"`
+	Source    string     `json:"source"`
+	FirstSeen time.Time  `json:"first_seen"`
+}
+
+type SubnetNewCallback func(s *Subnet)
+
+type Subnets struct {
+	sync.Mutex
+	subnets map[string]*Subnet
+	newCb   SubnetNewCallback
+}
+
+func NewSubnets(newcb SubnetNewCallback) *Subnets {
+	return &Subnets{
+		subnets: make(map[string]*Subnet),
+		newCb:   newcb,
+	}
+}
+
+func (s *Subnets) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.List())
+}
+
+// Add registers the subnet and returns true if it was not known already,
+// source describes how it's been learned (manual, dhcp, ra, etc).
+func (s *Subnets) Add(n *net.IPNet, source string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	cidr := n.String()
+	if _, found := s.subnets[cidr]; found {
+		return false
+	}
+
+	subnet := &Subnet{
+		Net:       n,
+		CIDR:      cidr,
+		Source:    source,
+		FirstSeen: time.Now(),
+	}
+	s.subnets[cidr] = subnet
+
+	if s.newCb != nil {
+		s.newCb(subnet)
+	}
+
+	return true
+}
+
+func (s *Subnets) Remove(cidr string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, n, err := net.ParseCIDR(cidr); err == nil {
+		cidr = n.String()
+	}
+
+	if _, found := s.subnets[cidr]; found {
+		delete(s.subnets, cidr)
+		return true
+	}
+	return false
+}
+
+// List returns the registered subnets sorted by CIDR.
+func (s *Subnets) List() []*Subnet {
+	s.Lock()
+	defer s.Unlock()
+
+	list := make([]*Subnet, 0, len(s.subnets))
+	for _, subnet := range s.subnets {
+		list = append(list, subnet)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CIDR < list[j].CIDR
+	})
+
+	return list
+}
+
+// Contains returns the registered subnet ip belongs to, if any.
+func (s *Subnets) Contains(ip net.IP) *Subnet {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, subnet := range s.subnets {
+		if subnet.Net.Contains(ip) {
+			return subnet
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSubnets(t *testing.T) {
+	added := 0
+	subnets := NewSubnets(func(s *Subnet) {
+		added++
+	})
+
+	_, a, _ := net.ParseCIDR("10.0.20.0/24")
+	_, b, _ := net.ParseCIDR("10.0.10.0/24")
+
+	if !subnets.Add(a, "manual") || !subnets.Add(b, "dhcp") {
+		t.Fatal("expected new subnets to be added")
+	} else if subnets.Add(a, "ra") {
+		t.Fatal("expected duplicated subnet to be ignored")
+	} else if added != 2 {
+		t.Fatalf("expected 2 callbacks, got %d", added)
+	}
+
+	list := subnets.List()
+	if len(list) != 2 || list[0].CIDR != "10.0.10.0/24" || list[1].CIDR != "10.0.20.0/24" {
+		t.Fatalf("unexpected list %v", list)
+	}
+
+	if s := subnets.Contains(net.ParseIP("10.0.20.5")); s == nil || s.Source != "manual" {
+		t.Fatalf("unexpected subnet %v", s)
+	} else if s = subnets.Contains(net.ParseIP("192.168.1.1")); s != nil {
+		t.Fatalf("unexpected subnet %v", s)
+	}
+
+	if !subnets.Remove("10.0.20.1/24") {
+		t.Fatal("expected subnet to be removed")
+	} else if subnets.Remove("10.0.20.0/24") {
+		t.Fatal("subnet was already removed")
+	} else if len(subnets.List()) != 1 {
+		t.Fatal("expected one subnet left")
+	}
+}
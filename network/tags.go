@@ -0,0 +1,139 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Tags holds arbitrary name=value tags and notes attached to endpoints,
+// access points and BLE devices by their MAC address, they are stored
+// on disk so that they survive the entity being lost and found again.
+type Tags struct {
+	sync.RWMutex
+	fileName string
+	m        map[string]map[string]string
+}
+
+func NewTags(fileName string) (*Tags, error) {
+	t := &Tags{
+		fileName: fileName,
+		m:        make(map[string]map[string]string),
+	}
+
+	if fileName != "" {
+		if raw, err := ioutil.ReadFile(fileName); err == nil {
+			if err = json.Unmarshal(raw, &t.m); err != nil {
+				return nil, fmt.Errorf("error parsing %s: %v", fileName, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+func (t *Tags) flush() error {
+	if t.fileName == "" {
+		return nil
+	}
+
+	raw, err := json.MarshalIndent(t.m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(t.fileName, raw, 0644)
+}
+
+func (t *Tags) MarshalJSON() ([]byte, error) {
+	t.RLock()
+	defer t.RUnlock()
+	return json.Marshal(t.m)
+}
+
+func (t *Tags) Set(mac, name, value string) error {
+	t.Lock()
+	defer t.Unlock()
+
+	mac = NormalizeMac(mac)
+	if _, found := t.m[mac]; !found {
+		t.m[mac] = make(map[string]string)
+	}
+	t.m[mac][name] = value
+
+	return t.flush()
+}
+
+// Del removes the tag name from mac or every tag if name is empty.
+func (t *Tags) Del(mac, name string) error {
+	t.Lock()
+	defer t.Unlock()
+
+	mac = NormalizeMac(mac)
+	if tags, found := t.m[mac]; !found {
+		return fmt.Errorf("%s has no tags", mac)
+	} else if name == "" {
+		delete(t.m, mac)
+	} else if _, found := tags[name]; !found {
+		return fmt.Errorf("%s has no tag %s", mac, name)
+	} else {
+		delete(tags, name)
+		if len(tags) == 0 {
+			delete(t.m, mac)
+		}
+	}
+
+	return t.flush()
+}
+
+// Get returns a copy of the tags of mac.
+func (t *Tags) Get(mac string) map[string]string {
+	t.RLock()
+	defer t.RUnlock()
+
+	tags := make(map[string]string)
+	for name, value := range t.m[NormalizeMac(mac)] {
+		tags[name] = value
+	}
+	return tags
+}
+
+func (t *Tags) Has(mac string) bool {
+	t.RLock()
+	defer t.RUnlock()
+	_, found := t.m[NormalizeMac(mac)]
+	return found
+}
+
+// Each calls cb with every tagged address, sorted.
+func (t *Tags) Each(cb func(mac string, tags map[string]string)) {
+	t.RLock()
+	macs := make([]string, 0, len(t.m))
+	for mac := range t.m {
+		macs = append(macs, mac)
+	}
+	t.RUnlock()
+
+	sort.Strings(macs)
+	for _, mac := range macs {
+		if tags := t.Get(mac); len(tags) > 0 {
+			cb(mac, tags)
+		}
+	}
+}
+
+// String returns the tags of mac in the name=value form, sorted by name.
+func (t *Tags) String(mac string) string {
+	tags := t.Get(mac)
+	list := make([]string, 0, len(tags))
+	for name, value := range tags {
+		list = append(list, fmt.Sprintf("%s=%s", name, value))
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
@@ -0,0 +1,77 @@
+package network
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTags(t *testing.T) {
+	tags, err := NewTags("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags.Set("AA:BB:CC:DD:EE:FF", "owner", "alice")
+	tags.Set("aa:bb:cc:dd:ee:ff", "note", "printer, 2nd floor")
+
+	if !tags.Has("aa:bb:cc:dd:ee:ff") {
+		t.Fatal("expected tags to be found")
+	} else if got := tags.String("aa-bb-cc-dd-ee-ff"); got != "note=printer, 2nd floor, owner=alice" {
+		t.Fatalf("unexpected tags '%s'", got)
+	}
+
+	// changing the copy must not change the store
+	tags.Get("aa:bb:cc:dd:ee:ff")["owner"] = "bob"
+	if got := tags.Get("aa:bb:cc:dd:ee:ff")["owner"]; got != "alice" {
+		t.Fatalf("expected 'alice', got '%s'", got)
+	}
+
+	if err = tags.Del("aa:bb:cc:dd:ee:ff", "nope"); err == nil {
+		t.Fatal("expected error for unknown tag")
+	} else if err = tags.Del("aa:bb:cc:dd:ee:ff", "owner"); err != nil {
+		t.Fatal(err)
+	} else if err = tags.Del("aa:bb:cc:dd:ee:ff", "note"); err != nil {
+		t.Fatal(err)
+	} else if tags.Has("aa:bb:cc:dd:ee:ff") {
+		t.Fatal("expected empty tags to be removed")
+	}
+}
+
+func TestTagsPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, "tags.json")
+	tags, err := NewTags(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags.Set("11:22:33:44:55:66", "role", "dc")
+	tags.Set("66:55:44:33:22:11", "role", "nas")
+	tags.Del("66:55:44:33:22:11", "")
+
+	if tags, err = NewTags(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := 0
+	tags.Each(func(mac string, values map[string]string) {
+		seen++
+		if mac != "11:22:33:44:55:66" || values["role"] != "dc" {
+			t.Fatalf("unexpected tags %s %v", mac, values)
+		}
+	})
+	if seen != 1 {
+		t.Fatalf("expected 1 tagged address, got %d", seen)
+	}
+
+	ioutil.WriteFile(fileName, []byte("{"), 0644)
+	if _, err = NewTags(fileName); err == nil {
+		t.Fatal("expected error for corrupted file")
+	}
+}
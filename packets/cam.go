@@ -0,0 +1,26 @@
+package packets
+
+import (
+	"math/rand"
+	"net"
+
+	"github.com/google/gopacket/layers"
+)
+
+// RandomMAC returns a random unicast MAC address, multicast addresses are
+// never learned by switches.
+func RandomMAC() net.HardwareAddr {
+	hw := make(net.HardwareAddr, 6)
+	rand.Read(hw)
+	hw[0] &^= 0x01
+	return hw
+}
+
+// NewCAMFrame returns a minimal frame with the given source address, sending
+// it makes the switch learn that src is reachable through our port. Frames are
+// addressed to dst, usually our own address, so that the switch doesn't
+// forward them anywhere.
+func NewCAMFrame(src net.HardwareAddr, dst net.HardwareAddr) (error, []byte) {
+	eth, arp := NewARPTo(net.IPv4zero, src, net.IPv4zero, dst, layers.ARPRequest)
+	return Serialize(&eth, &arp)
+}
@@ -0,0 +1,42 @@
+package packets
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestRandomMAC(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if hw := RandomMAC(); len(hw) != 6 {
+			t.Fatalf("unexpected length %d", len(hw))
+		} else if hw[0]&0x01 != 0 {
+			t.Fatalf("%s is a multicast address", hw)
+		}
+	}
+}
+
+func TestNewCAMFrame(t *testing.T) {
+	src := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	dst := net.HardwareAddr{0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb}
+
+	err, raw := NewCAMFrame(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+	eth, ok := pkt.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	if !ok {
+		t.Fatal("expected an ethernet layer")
+	} else if !bytes.Equal(eth.SrcMAC, src) {
+		t.Fatalf("expected source %s, got %s", src, eth.SrcMAC)
+	} else if !bytes.Equal(eth.DstMAC, dst) {
+		t.Fatalf("expected destination %s, got %s", dst, eth.DstMAC)
+	} else if pkt.Layer(layers.LayerTypeARP) == nil {
+		t.Fatal("expected an arp layer")
+	}
+}
@@ -0,0 +1,179 @@
+package packets
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	CoAPPort = 5683
+
+	CoAPVersion         = 1
+	CoAPConfirmable     = 0
+	CoAPNonConfirmable  = 1
+	CoAPAcknowledgement = 2
+	CoAPReset           = 3
+
+	CoAPGet     = 0x01
+	CoAPContent = 0x45
+
+	CoAPOptionUriPath = 11
+	coapPayloadMarker = 0xff
+)
+
+var (
+	CoAPWellKnownCore = "/.well-known/core"
+
+	ErrCoAPShort = errors.New("coap message too short")
+)
+
+type CoAPMessage struct {
+	Type      byte
+	Code      byte
+	MessageID uint16
+	Token     []byte
+	Options   map[int][]string
+	Payload   []byte
+}
+
+// CodeString returns the code in the class.detail format, for instance 2.05.
+func (m CoAPMessage) CodeString() string {
+	return fmt.Sprintf("%d.%02d", m.Code>>5, m.Code&0x1f)
+}
+
+func coapOptionNibble(value int) (nibble byte, extended []byte) {
+	if value < 13 {
+		return byte(value), nil
+	} else if value < 269 {
+		return 13, []byte{byte(value - 13)}
+	}
+	extended = make([]byte, 2)
+	binary.BigEndian.PutUint16(extended, uint16(value-269))
+	return 14, extended
+}
+
+// NewCoAPGet returns a confirmable GET request for path.
+func NewCoAPGet(messageID uint16, token []byte, path string) []byte {
+	raw := make([]byte, 4, 64)
+	raw[0] = CoAPVersion<<6 | CoAPConfirmable<<4 | byte(len(token)&0x0f)
+	raw[1] = CoAPGet
+	binary.BigEndian.PutUint16(raw[2:], messageID)
+	raw = append(raw, token...)
+
+	last := 0
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+
+		delta, deltaExt := coapOptionNibble(CoAPOptionUriPath - last)
+		size, sizeExt := coapOptionNibble(len(segment))
+		raw = append(raw, delta<<4|size)
+		raw = append(raw, deltaExt...)
+		raw = append(raw, sizeExt...)
+		raw = append(raw, segment...)
+		last = CoAPOptionUriPath
+	}
+
+	return raw
+}
+
+func coapOptionValue(nibble byte, raw []byte, off int) (int, int, error) {
+	switch nibble {
+	case 13:
+		if off+1 > len(raw) {
+			return 0, 0, ErrCoAPShort
+		}
+		return int(raw[off]) + 13, off + 1, nil
+	case 14:
+		if off+2 > len(raw) {
+			return 0, 0, ErrCoAPShort
+		}
+		return int(binary.BigEndian.Uint16(raw[off:])) + 269, off + 2, nil
+	case 15:
+		return 0, 0, fmt.Errorf("invalid coap option")
+	}
+	return int(nibble), off, nil
+}
+
+// ParseCoAPMessage parses a CoAP message.
+func ParseCoAPMessage(raw []byte) (*CoAPMessage, error) {
+	if len(raw) < 4 {
+		return nil, ErrCoAPShort
+	} else if raw[0]>>6 != CoAPVersion {
+		return nil, fmt.Errorf("unexpected coap version %d", raw[0]>>6)
+	}
+
+	tokenLen := int(raw[0] & 0x0f)
+	if tokenLen > 8 || 4+tokenLen > len(raw) {
+		return nil, ErrCoAPShort
+	}
+
+	msg := &CoAPMessage{
+		Type:      (raw[0] >> 4) & 0x03,
+		Code:      raw[1],
+		MessageID: binary.BigEndian.Uint16(raw[2:]),
+		Token:     raw[4 : 4+tokenLen],
+		Options:   make(map[int][]string),
+		Payload:   []byte{},
+	}
+
+	off := 4 + tokenLen
+	number := 0
+	for off < len(raw) {
+		if raw[off] == coapPayloadMarker {
+			msg.Payload = raw[off+1:]
+			break
+		}
+
+		header := raw[off]
+		off++
+
+		delta, next, err := coapOptionValue(header>>4, raw, off)
+		if err != nil {
+			return nil, err
+		}
+		size, next, err := coapOptionValue(header&0x0f, raw, next)
+		if err != nil {
+			return nil, err
+		} else if next+size > len(raw) {
+			return nil, ErrCoAPShort
+		}
+
+		number += delta
+		msg.Options[number] = append(msg.Options[number], string(raw[next:next+size]))
+		off = next + size
+	}
+
+	return msg, nil
+}
+
+// CoAPParseLinkFormat returns the resources listed in a CoRE link
+// format document such as the one served at /.well-known/core.
+func CoAPParseLinkFormat(payload []byte) []string {
+	resources := []string{}
+	inQuotes := false
+	inTarget := false
+	target := ""
+	for _, c := range string(payload) {
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+			continue
+		case c == '<':
+			inTarget = true
+			target = ""
+		case c == '>' && inTarget:
+			inTarget = false
+			if target != "" {
+				resources = append(resources, target)
+			}
+		case inTarget:
+			target += string(c)
+		}
+	}
+	return resources
+}
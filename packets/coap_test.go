@@ -0,0 +1,60 @@
+package packets
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestNewCoAPGet(t *testing.T) {
+	exp := []byte{
+		0x41, 0x01, 0x12, 0x34,
+		0xaa,
+		0xbb, '.', 'w', 'e', 'l', 'l', '-', 'k', 'n', 'o', 'w', 'n',
+		0x04, 'c', 'o', 'r', 'e',
+	}
+
+	if got := NewCoAPGet(0x1234, []byte{0xaa}, CoAPWellKnownCore); !bytes.Equal(got, exp) {
+		t.Fatalf("expected '%x', got '%x'", exp, got)
+	}
+}
+
+func TestParseCoAPMessage(t *testing.T) {
+	raw := []byte{
+		0x61, CoAPContent, 0x12, 0x34,
+		0xaa,
+		// content format 40, application/link-format
+		0xc1, 0x28,
+		0xff,
+	}
+	raw = append(raw, []byte(`</sensors/temp>;rt="a,<b>";if="sensor",</light>`)...)
+
+	msg, err := ParseCoAPMessage(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Type != CoAPAcknowledgement || msg.CodeString() != "2.05" || msg.MessageID != 0x1234 {
+		t.Fatalf("unexpected message %+v", msg)
+	} else if !bytes.Equal(msg.Token, []byte{0xaa}) {
+		t.Fatalf("unexpected token %x", msg.Token)
+	} else if format := msg.Options[12]; len(format) != 1 || format[0] != "\x28" {
+		t.Fatalf("unexpected options %v", msg.Options)
+	}
+
+	exp := []string{"/sensors/temp", "/light"}
+	if got := CoAPParseLinkFormat(msg.Payload); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected '%v', got '%v'", exp, got)
+	}
+
+	// request roundtrip
+	if msg, err = ParseCoAPMessage(NewCoAPGet(1, nil, CoAPWellKnownCore)); err != nil {
+		t.Fatal(err)
+	} else if path := msg.Options[CoAPOptionUriPath]; !reflect.DeepEqual(path, []string{".well-known", "core"}) {
+		t.Fatalf("unexpected path %v", path)
+	}
+
+	if _, err = ParseCoAPMessage([]byte{0x41, 0x01, 0x00}); err == nil {
+		t.Fatal("expected error for short message")
+	}
+}
@@ -0,0 +1,296 @@
+package packets
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	DCERPCRequest  = 0x00
+	DCERPCResponse = 0x02
+	DCERPCFault    = 0x03
+	DCERPCBind     = 0x0b
+	DCERPCBindAck  = 0x0c
+	DCERPCBindNak  = 0x0d
+
+	DCERPCFirstFrag = 0x01
+	DCERPCLastFrag  = 0x02
+
+	DCERPCHeaderSize = 16
+	DCERPCMaxFrag    = 4280
+
+	SRVSVCPipe            = "srvsvc"
+	SRVSVCNetShareEnumAll = 15
+
+	STypeDisk      = 0x00000000
+	STypePrintQ    = 0x00000001
+	STypeDevice    = 0x00000002
+	STypeIPC       = 0x00000003
+	STypeSpecial   = 0x80000000
+	STypeTemporary = 0x40000000
+)
+
+var (
+	SRVSVCSyntax = DCERPCSyntax{UUID: "4b324fc8-1670-01d3-1278-5a47bf6ee188", Major: 3}
+	NDRSyntax    = DCERPCSyntax{UUID: "8a885d04-1ceb-11c9-9fe8-08002b104860", Major: 2}
+)
+
+type DCERPCSyntax struct {
+	UUID  string
+	Major uint16
+	Minor uint16
+}
+
+// the first three fields of the uuid are little endian on the wire
+func (s DCERPCSyntax) bytes() []byte {
+	raw, _ := hex.DecodeString(strings.Replace(s.UUID, "-", "", -1))
+	if len(raw) != 16 {
+		raw = make([]byte, 16)
+	}
+
+	wire := make([]byte, 20)
+	binary.LittleEndian.PutUint32(wire[0:], binary.BigEndian.Uint32(raw[0:]))
+	binary.LittleEndian.PutUint16(wire[4:], binary.BigEndian.Uint16(raw[4:]))
+	binary.LittleEndian.PutUint16(wire[6:], binary.BigEndian.Uint16(raw[6:]))
+	copy(wire[8:16], raw[8:])
+	binary.LittleEndian.PutUint16(wire[16:], s.Major)
+	binary.LittleEndian.PutUint16(wire[18:], s.Minor)
+	return wire
+}
+
+type DCERPCHeader struct {
+	Type       byte
+	Flags      byte
+	FragLength uint16
+	CallID     uint32
+}
+
+func (h DCERPCHeader) Last() bool {
+	return h.Flags&DCERPCLastFrag != 0
+}
+
+func dcerpcPDU(ptype byte, callID uint32, body []byte) []byte {
+	raw := make([]byte, DCERPCHeaderSize+len(body))
+	raw[0] = 5
+	raw[2] = ptype
+	raw[3] = DCERPCFirstFrag | DCERPCLastFrag
+	// little endian, ascii, ieee floats
+	raw[4] = 0x10
+	binary.LittleEndian.PutUint16(raw[8:], uint16(len(raw)))
+	binary.LittleEndian.PutUint32(raw[12:], callID)
+	copy(raw[DCERPCHeaderSize:], body)
+	return raw
+}
+
+// NewDCERPCBind returns a BIND request for the interface with the given
+// syntax, using NDR as the transfer syntax.
+func NewDCERPCBind(callID uint32, syntax DCERPCSyntax) []byte {
+	body := make([]byte, 12)
+	binary.LittleEndian.PutUint16(body[0:], DCERPCMaxFrag)
+	binary.LittleEndian.PutUint16(body[2:], DCERPCMaxFrag)
+	// one context with one transfer syntax
+	body[8] = 1
+	body = append(body, 0, 0, 1, 0)
+	body = append(body, syntax.bytes()...)
+	body = append(body, NDRSyntax.bytes()...)
+	return dcerpcPDU(DCERPCBind, callID, body)
+}
+
+// NewDCERPCRequest returns a REQUEST for the operation opnum with the given stub data.
+func NewDCERPCRequest(callID uint32, opnum uint16, stub []byte) []byte {
+	body := make([]byte, 8+len(stub))
+	binary.LittleEndian.PutUint32(body[0:], uint32(len(stub)))
+	binary.LittleEndian.PutUint16(body[6:], opnum)
+	copy(body[8:], stub)
+	return dcerpcPDU(DCERPCRequest, callID, body)
+}
+
+// ParseDCERPCHeader parses the common header of a PDU.
+func ParseDCERPCHeader(raw []byte) (*DCERPCHeader, error) {
+	if len(raw) < DCERPCHeaderSize {
+		return nil, fmt.Errorf("dcerpc pdu too short")
+	} else if raw[0] != 5 {
+		return nil, fmt.Errorf("unexpected dcerpc version %d", raw[0])
+	}
+
+	return &DCERPCHeader{
+		Type:       raw[2],
+		Flags:      raw[3],
+		FragLength: binary.LittleEndian.Uint16(raw[8:]),
+		CallID:     binary.LittleEndian.Uint32(raw[12:]),
+	}, nil
+}
+
+// DCERPCResponseStub returns the stub data of a RESPONSE fragment or
+// the status code of a FAULT as an error.
+func DCERPCResponseStub(frag []byte) ([]byte, error) {
+	h, err := ParseDCERPCHeader(frag)
+	if err != nil {
+		return nil, err
+	} else if int(h.FragLength) > len(frag) || h.FragLength < DCERPCHeaderSize+8 {
+		return nil, fmt.Errorf("dcerpc fragment too short")
+	} else if h.Type == DCERPCFault {
+		return nil, fmt.Errorf("dcerpc fault 0x%08x", binary.LittleEndian.Uint32(frag[DCERPCHeaderSize+8:]))
+	} else if h.Type != DCERPCResponse {
+		return nil, fmt.Errorf("unexpected dcerpc pdu type %d", h.Type)
+	}
+	return frag[DCERPCHeaderSize+8 : h.FragLength], nil
+}
+
+type SMBShare struct {
+	Name   string `json:"name"`
+	Type   uint32 `json:"type"`
+	Remark string `json:"remark"`
+}
+
+func (s SMBShare) IsDisk() bool {
+	return s.Type&0x0fffffff == STypeDisk
+}
+
+func (s SMBShare) TypeName() string {
+	switch s.Type & 0x0fffffff {
+	case STypeDisk:
+		return "disk"
+	case STypePrintQ:
+		return "printer"
+	case STypeDevice:
+		return "device"
+	case STypeIPC:
+		return "ipc"
+	}
+	return "unknown"
+}
+
+func ndrString(s string) []byte {
+	chars := append(smbUTF16(s), 0, 0)
+	count := uint32(len(chars) / 2)
+	raw := make([]byte, 12, 12+len(chars)+2)
+	binary.LittleEndian.PutUint32(raw[0:], count)
+	binary.LittleEndian.PutUint32(raw[8:], count)
+	raw = append(raw, chars...)
+	for len(raw)%4 != 0 {
+		raw = append(raw, 0)
+	}
+	return raw
+}
+
+// SRVSVCNetShareEnumAllStub returns the stub of a NetrShareEnum
+// request for the level 1 information of every share.
+func SRVSVCNetShareEnumAllStub(server string) []byte {
+	stub := make([]byte, 4)
+	// ServerName, unique pointer
+	binary.LittleEndian.PutUint32(stub, 0x00020000)
+	stub = append(stub, ndrString(server)...)
+
+	tail := make([]byte, 32)
+	// InfoStruct, level 1 and the matching union arm
+	binary.LittleEndian.PutUint32(tail[0:], 1)
+	binary.LittleEndian.PutUint32(tail[4:], 1)
+	// pointer to an empty SHARE_INFO_1_CONTAINER
+	binary.LittleEndian.PutUint32(tail[8:], 0x00020004)
+	// PreferedMaximumLength
+	binary.LittleEndian.PutUint32(tail[20:], 0xffffffff)
+	// ResumeHandle, unique pointer to 0
+	binary.LittleEndian.PutUint32(tail[24:], 0x00020008)
+
+	return append(stub, tail...)
+}
+
+type ndrReader struct {
+	raw []byte
+	off int
+	err error
+}
+
+func (r *ndrReader) align() {
+	for r.off%4 != 0 {
+		r.off++
+	}
+}
+
+func (r *ndrReader) u32() uint32 {
+	if r.err != nil {
+		return 0
+	} else if r.off+4 > len(r.raw) {
+		r.err = fmt.Errorf("ndr data too short")
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(r.raw[r.off:])
+	r.off += 4
+	return v
+}
+
+func (r *ndrReader) str() string {
+	r.align()
+	r.u32()
+	r.u32()
+	count := int(r.u32())
+	if r.err != nil {
+		return ""
+	} else if count < 0 || r.off+2*count > len(r.raw) {
+		r.err = fmt.Errorf("ndr string too long")
+		return ""
+	}
+	s := smbString(r.raw[r.off : r.off+2*count])
+	r.off += 2 * count
+	r.align()
+	return s
+}
+
+// ParseSRVSVCNetShareEnumAll parses the stub of a level 1 NetrShareEnum response.
+func ParseSRVSVCNetShareEnumAll(stub []byte) ([]SMBShare, error) {
+	if len(stub) < 4 {
+		return nil, fmt.Errorf("ndr data too short")
+	} else if status := binary.LittleEndian.Uint32(stub[len(stub)-4:]); status != 0 {
+		return nil, fmt.Errorf("NetrShareEnum error 0x%08x", status)
+	}
+
+	r := &ndrReader{raw: stub}
+	if level := r.u32(); r.err == nil && level != 1 {
+		return nil, fmt.Errorf("unexpected info level %d", level)
+	}
+	// union switch, container pointer and number of entries
+	r.u32()
+	if container := r.u32(); container == 0 {
+		return nil, r.err
+	}
+	r.u32()
+
+	shares := make([]SMBShare, 0)
+	if buffer := r.u32(); buffer == 0 {
+		return shares, r.err
+	}
+
+	count := int(r.u32())
+	if r.err != nil {
+		return nil, r.err
+	} else if count*12 > len(stub) {
+		return nil, fmt.Errorf("unexpected number of shares %d", count)
+	}
+
+	type entry struct {
+		name   uint32
+		remark uint32
+	}
+
+	entries := make([]entry, count)
+	for i := range entries {
+		entries[i].name = r.u32()
+		shares = append(shares, SMBShare{Type: r.u32()})
+		entries[i].remark = r.u32()
+	}
+
+	// pointed strings follow the array
+	for i, e := range entries {
+		if e.name != 0 {
+			shares[i].Name = r.str()
+		}
+		if e.remark != 0 {
+			shares[i].Remark = r.str()
+		}
+	}
+
+	return shares, r.err
+}
@@ -0,0 +1,217 @@
+package packets
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	DHCPServerPort = 67
+	DHCPClientPort = 68
+
+	// web proxy auto discovery, not defined by gopacket
+	DHCPOptWPAD = layers.DHCPOpt(252)
+
+	dhcpFlagBroadcast = 0x8000
+)
+
+var (
+	ipv4Broadcast = net.IPv4bcast
+	macBroadcast  = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+)
+
+// DHCPServerConfig holds the network configuration handed out to clients.
+type DHCPServerConfig struct {
+	ServerIP  net.IP
+	ServerHW  net.HardwareAddr
+	Netmask   net.IPMask
+	Router    net.IP
+	DNS       []net.IP
+	Domain    string
+	LeaseTime time.Duration
+	// URL of the proxy auto configuration file, if any
+	WPAD string
+}
+
+// DHCPOptionData returns the data of the first option of the given type.
+func DHCPOptionData(dhcp *layers.DHCPv4, t layers.DHCPOpt) ([]byte, bool) {
+	for _, opt := range dhcp.Options {
+		if opt.Type == t {
+			return opt.Data, true
+		}
+	}
+	return nil, false
+}
+
+// DHCPMessageType returns the type of a DHCP message, or DHCPMsgTypeUnspecified
+// if the option is missing.
+func DHCPMessageType(dhcp *layers.DHCPv4) layers.DHCPMsgType {
+	if data, found := DHCPOptionData(dhcp, layers.DHCPOptMessageType); found && len(data) == 1 {
+		return layers.DHCPMsgType(data[0])
+	}
+	return layers.DHCPMsgTypeUnspecified
+}
+
+// DHCPRequestedIP returns the address a client is asking for, from the
+// requested address option or from ciaddr when renewing a lease.
+func DHCPRequestedIP(dhcp *layers.DHCPv4) net.IP {
+	if data, found := DHCPOptionData(dhcp, layers.DHCPOptRequestIP); found && len(data) == net.IPv4len {
+		return net.IP(data)
+	} else if ip := dhcp.ClientIP.To4(); ip != nil && !ip.Equal(net.IPv4zero) {
+		return ip
+	}
+	return nil
+}
+
+// DHCPServerID returns the server identifier of a REQUEST, which tells which
+// server's offer the client accepted.
+func DHCPServerID(dhcp *layers.DHCPv4) net.IP {
+	if data, found := DHCPOptionData(dhcp, layers.DHCPOptServerID); found && len(data) == net.IPv4len {
+		return net.IP(data)
+	}
+	return nil
+}
+
+// DHCPHostname returns the host name sent by the client, if any.
+func DHCPHostname(dhcp *layers.DHCPv4) string {
+	if data, found := DHCPOptionData(dhcp, layers.DHCPOptHostname); found {
+		return string(data)
+	}
+	return ""
+}
+
+func dhcpUint32(v uint32) []byte {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, v)
+	return data
+}
+
+// options returns the options of a reply, answers to INFORM messages carry
+// no lease since the client already has an address.
+func (c DHCPServerConfig) options(msgType layers.DHCPMsgType, lease bool) []layers.DHCPOption {
+	opts := []layers.DHCPOption{
+		layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(msgType)}),
+		layers.NewDHCPOption(layers.DHCPOptServerID, c.ServerIP.To4()),
+	}
+
+	if msgType == layers.DHCPMsgTypeNak {
+		return opts
+	}
+
+	if lease {
+		leaseTime := uint32(c.LeaseTime / time.Second)
+		opts = append(opts,
+			layers.NewDHCPOption(layers.DHCPOptLeaseTime, dhcpUint32(leaseTime)),
+			// renewal at 50% and rebinding at 87.5% of the lease time
+			layers.NewDHCPOption(layers.DHCPOptT1, dhcpUint32(leaseTime/2)),
+			layers.NewDHCPOption(layers.DHCPOptT2, dhcpUint32(leaseTime/8*7)),
+		)
+	}
+	opts = append(opts, layers.NewDHCPOption(layers.DHCPOptSubnetMask, []byte(c.Netmask)))
+
+	if c.Router != nil {
+		opts = append(opts, layers.NewDHCPOption(layers.DHCPOptRouter, c.Router.To4()))
+	}
+
+	if len(c.DNS) > 0 {
+		dns := make([]byte, 0, len(c.DNS)*net.IPv4len)
+		for _, ip := range c.DNS {
+			dns = append(dns, ip.To4()...)
+		}
+		opts = append(opts, layers.NewDHCPOption(layers.DHCPOptDNS, dns))
+	}
+
+	if c.Domain != "" {
+		opts = append(opts, layers.NewDHCPOption(layers.DHCPOptDomainName, []byte(c.Domain)))
+	}
+
+	if c.WPAD != "" {
+		opts = append(opts, layers.NewDHCPOption(DHCPOptWPAD, []byte(c.WPAD)))
+	}
+
+	return opts
+}
+
+// NewDHCPReply returns the OFFER, ACK or NAK answering req and assigning
+// yourIP to the client, using the configuration of our rogue server.
+func NewDHCPReply(req *layers.DHCPv4, msgType layers.DHCPMsgType, yourIP net.IP, config DHCPServerConfig) (error, []byte) {
+	dstHW := req.ClientHWAddr
+	dstIP := yourIP
+	// clients without an address yet might not accept unicast packets
+	if msgType == layers.DHCPMsgTypeNak || req.Flags&dhcpFlagBroadcast != 0 || yourIP == nil {
+		dstHW = macBroadcast
+		dstIP = ipv4Broadcast
+	}
+
+	eth := layers.Ethernet{
+		SrcMAC:       config.ServerHW,
+		DstMAC:       dstHW,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{
+		Protocol: layers.IPProtocolUDP,
+		Version:  4,
+		TTL:      64,
+		SrcIP:    config.ServerIP,
+		DstIP:    dstIP,
+	}
+	udp := layers.UDP{
+		SrcPort: DHCPServerPort,
+		DstPort: DHCPClientPort,
+	}
+	udp.SetNetworkLayerForChecksum(&ip4)
+
+	dhcp := layers.DHCPv4{
+		Operation:    layers.DHCPOpReply,
+		HardwareType: layers.LinkTypeEthernet,
+		Xid:          req.Xid,
+		Flags:        req.Flags,
+		RelayAgentIP: req.RelayAgentIP,
+		ClientHWAddr: req.ClientHWAddr,
+		Options:      config.options(msgType, true),
+	}
+
+	if msgType != layers.DHCPMsgTypeNak {
+		dhcp.YourClientIP = yourIP
+		dhcp.NextServerIP = config.ServerIP
+	}
+
+	return Serialize(&eth, &ip4, &udp, &dhcp)
+}
+
+// NewDHCPInformReply returns the ACK answering an INFORM message, sent by
+// clients that already have an address and only want the other parameters,
+// such as the WPAD URL.
+func NewDHCPInformReply(req *layers.DHCPv4, config DHCPServerConfig) (error, []byte) {
+	eth := layers.Ethernet{
+		SrcMAC:       config.ServerHW,
+		DstMAC:       req.ClientHWAddr,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{
+		Protocol: layers.IPProtocolUDP,
+		Version:  4,
+		TTL:      64,
+		SrcIP:    config.ServerIP,
+		DstIP:    req.ClientIP,
+	}
+	udp := layers.UDP{
+		SrcPort: DHCPServerPort,
+		DstPort: DHCPClientPort,
+	}
+	udp.SetNetworkLayerForChecksum(&ip4)
+
+	dhcp := layers.DHCPv4{
+		Operation:    layers.DHCPOpReply,
+		HardwareType: layers.LinkTypeEthernet,
+		Xid:          req.Xid,
+		ClientIP:     req.ClientIP,
+		ClientHWAddr: req.ClientHWAddr,
+		Options:      config.options(layers.DHCPMsgTypeAck, false),
+	}
+
+	return Serialize(&eth, &ip4, &udp, &dhcp)
+}
@@ -0,0 +1,75 @@
+package packets
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	DeviceComputer = "Computer"
+	DevicePhone    = "Phone"
+	DevicePrinter  = "Printer"
+	DeviceEmbedded = "Embedded"
+)
+
+// a DHCP fingerprint is the list of options requested by the client
+// (option 55), its order is specific to the DHCP client implementation.
+type dhcpFingerprint struct {
+	Params string
+	OS     string
+	Device string
+}
+
+var dhcpFingerprints = []dhcpFingerprint{
+	{"1,3,6,15,31,33,43,44,46,47,119,121,249,252", "Windows 10/11", DeviceComputer},
+	{"1,15,3,6,44,46,47,31,33,121,249,43,252", "Windows 8", DeviceComputer},
+	{"1,15,3,6,44,46,47,31,33,121,249,43", "Windows 7", DeviceComputer},
+	{"1,15,3,6,44,46,47,31,33,249,43", "Windows Vista", DeviceComputer},
+	{"1,15,3,6,44,46,47,31,33,249,43,252", "Windows", DeviceComputer},
+	{"1,121,3,6,15,119,252,95,44,46", "macOS", DeviceComputer},
+	{"1,121,3,6,15,114,119,252,95,44,46", "macOS", DeviceComputer},
+	{"1,121,3,6,15,119,252", "iOS", DevicePhone},
+	{"1,121,3,6,15,114,119,252", "iOS", DevicePhone},
+	{"1,3,6,15,26,28,51,58,59,43", "Android", DevicePhone},
+	{"1,3,6,15,26,28,51,58,59", "Android", DevicePhone},
+	{"1,3,6,28,51,58,59,43", "Android", DevicePhone},
+	{"1,33,3,6,15,28,51,58,59", "Android", DevicePhone},
+	{"1,28,2,3,15,6,119,12,44,47,26,121,42", "Linux (dhclient)", DeviceComputer},
+	{"1,3,6,12,15,28,42,51,54,58,59,119", "Linux (dhclient)", DeviceComputer},
+	{"1,3,6,12,15,17,23,28,29,31,33,40,41,42,119", "Linux (NetworkManager)", DeviceComputer},
+	{"1,121,33,3,6,12,15,26,28,42,51,54,58,59,119", "Linux (systemd-networkd)", DeviceComputer},
+	{"1,3,6,12,15,28,42", "Linux (udhcpc)", DeviceEmbedded},
+	{"1,3,6,12,15,28,40,41,42", "Linux (udhcpc)", DeviceEmbedded},
+	{"1,3,6,15,44,47", "HP JetDirect", DevicePrinter},
+	{"1,3,6,15,44,47,81,119", "Printer", DevicePrinter},
+}
+
+// DHCPFingerprint encodes the parameter request list as a comma separated
+// list of option codes, the same format used by public fingerprint databases.
+func DHCPFingerprint(params []byte) string {
+	codes := make([]string, len(params))
+	for i, p := range params {
+		codes[i] = fmt.Sprintf("%d", p)
+	}
+	return strings.Join(codes, ",")
+}
+
+// DHCPFingerprintGuess returns the operating system and device type
+// given a DHCP fingerprint as returned by DHCPFingerprint.
+func DHCPFingerprintGuess(fingerprint string) (os string, device string) {
+	for _, fp := range dhcpFingerprints {
+		if fp.Params == fingerprint {
+			return fp.OS, fp.Device
+		}
+	}
+
+	// fallback on some well known options
+	codes := "," + fingerprint + ","
+	if strings.Contains(codes, ",249,") && strings.Contains(codes, ",43,") {
+		// classless static routes (Microsoft) and vendor specific
+		return "Windows", DeviceComputer
+	} else if strings.HasPrefix(fingerprint, "1,121,3,6,15,") && strings.Contains(codes, ",252,") {
+		return "macOS/iOS", ""
+	}
+	return "", ""
+}
@@ -0,0 +1,150 @@
+package packets
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var (
+	testDHCPClientHW, _ = net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	testDHCPServerHW, _ = net.ParseMAC("11:22:33:44:55:66")
+	testDHCPConfig      = DHCPServerConfig{
+		ServerIP:  net.ParseIP("192.168.1.5"),
+		ServerHW:  testDHCPServerHW,
+		Netmask:   net.CIDRMask(24, 32),
+		Router:    net.ParseIP("192.168.1.5"),
+		DNS:       []net.IP{net.ParseIP("192.168.1.5"), net.ParseIP("1.1.1.1")},
+		Domain:    "corp.local",
+		LeaseTime: time.Hour,
+	}
+)
+
+func testDHCPRequest(msgType layers.DHCPMsgType, opts ...layers.DHCPOption) *layers.DHCPv4 {
+	return &layers.DHCPv4{
+		Operation:    layers.DHCPOpRequest,
+		HardwareType: layers.LinkTypeEthernet,
+		Xid:          0xdeadbeef,
+		Flags:        dhcpFlagBroadcast,
+		ClientHWAddr: testDHCPClientHW,
+		Options: append([]layers.DHCPOption{
+			layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(msgType)}),
+		}, opts...),
+	}
+}
+
+func TestDHCPRequestFields(t *testing.T) {
+	req := testDHCPRequest(layers.DHCPMsgTypeRequest,
+		layers.NewDHCPOption(layers.DHCPOptRequestIP, []byte{192, 168, 1, 100}),
+		layers.NewDHCPOption(layers.DHCPOptServerID, []byte{192, 168, 1, 1}),
+		layers.NewDHCPOption(layers.DHCPOptHostname, []byte("laptop")))
+
+	if msgType := DHCPMessageType(req); msgType != layers.DHCPMsgTypeRequest {
+		t.Fatalf("unexpected message type %s", msgType)
+	} else if ip := DHCPRequestedIP(req); !ip.Equal(net.ParseIP("192.168.1.100")) {
+		t.Fatalf("unexpected requested address %s", ip)
+	} else if ip = DHCPServerID(req); !ip.Equal(net.ParseIP("192.168.1.1")) {
+		t.Fatalf("unexpected server id %s", ip)
+	} else if name := DHCPHostname(req); name != "laptop" {
+		t.Fatalf("unexpected host name '%s'", name)
+	}
+
+	renew := testDHCPRequest(layers.DHCPMsgTypeRequest)
+	renew.ClientIP = net.ParseIP("192.168.1.42")
+	if ip := DHCPRequestedIP(renew); !ip.Equal(renew.ClientIP) {
+		t.Fatalf("unexpected requested address %s", ip)
+	} else if ip = DHCPRequestedIP(testDHCPRequest(layers.DHCPMsgTypeDiscover)); ip != nil {
+		t.Fatalf("unexpected requested address %s", ip)
+	}
+}
+
+func TestNewDHCPReply(t *testing.T) {
+	yourIP := net.ParseIP("192.168.1.100")
+	err, raw := NewDHCPReply(testDHCPRequest(layers.DHCPMsgTypeDiscover), layers.DHCPMsgTypeOffer, yourIP, testDHCPConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+	eth := pkt.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	ip4 := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	ldhcp := pkt.Layer(layers.LayerTypeDHCPv4)
+	if ldhcp == nil {
+		t.Fatalf("no dhcp layer found in %x", raw)
+	}
+
+	dhcp := ldhcp.(*layers.DHCPv4)
+	if eth.DstMAC.String() != "ff:ff:ff:ff:ff:ff" || !ip4.DstIP.Equal(net.IPv4bcast) {
+		t.Fatalf("expected broadcast reply, got %s %s", eth.DstMAC, ip4.DstIP)
+	} else if dhcp.Operation != layers.DHCPOpReply || dhcp.Xid != 0xdeadbeef {
+		t.Fatalf("unexpected reply %+v", dhcp)
+	} else if !dhcp.YourClientIP.Equal(yourIP) {
+		t.Fatalf("unexpected address %s", dhcp.YourClientIP)
+	} else if msgType := DHCPMessageType(dhcp); msgType != layers.DHCPMsgTypeOffer {
+		t.Fatalf("unexpected message type %s", msgType)
+	} else if id := DHCPServerID(dhcp); !id.Equal(testDHCPConfig.ServerIP) {
+		t.Fatalf("unexpected server id %s", id)
+	}
+
+	if dns, _ := DHCPOptionData(dhcp, layers.DHCPOptDNS); len(dns) != 8 || !net.IP(dns[4:]).Equal(net.ParseIP("1.1.1.1")) {
+		t.Fatalf("unexpected dns servers %x", dns)
+	} else if router, _ := DHCPOptionData(dhcp, layers.DHCPOptRouter); !net.IP(router).Equal(testDHCPConfig.Router) {
+		t.Fatalf("unexpected router %x", router)
+	} else if domain, _ := DHCPOptionData(dhcp, layers.DHCPOptDomainName); string(domain) != "corp.local" {
+		t.Fatalf("unexpected domain %s", domain)
+	}
+}
+
+func TestNewDHCPNak(t *testing.T) {
+	err, raw := NewDHCPReply(testDHCPRequest(layers.DHCPMsgTypeRequest), layers.DHCPMsgTypeNak, nil, testDHCPConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+	dhcp := pkt.Layer(layers.LayerTypeDHCPv4).(*layers.DHCPv4)
+	if msgType := DHCPMessageType(dhcp); msgType != layers.DHCPMsgTypeNak {
+		t.Fatalf("unexpected message type %s", msgType)
+	} else if !dhcp.YourClientIP.Equal(net.IPv4zero) {
+		t.Fatalf("unexpected address %s", dhcp.YourClientIP)
+	} else if _, found := DHCPOptionData(dhcp, layers.DHCPOptLeaseTime); found {
+		t.Fatal("unexpected lease time in nak")
+	}
+}
+
+func TestNewDHCPInformReply(t *testing.T) {
+	config := testDHCPConfig
+	config.WPAD = "http://192.168.1.5/wpad.dat"
+
+	req := testDHCPRequest(layers.DHCPMsgTypeInform)
+	req.Flags = 0
+	req.ClientIP = net.ParseIP("192.168.1.42").To4()
+
+	err, raw := NewDHCPInformReply(req, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+	eth := pkt.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	ip4 := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	dhcp, ok := pkt.Layer(layers.LayerTypeDHCPv4).(*layers.DHCPv4)
+	if !ok {
+		t.Fatalf("no dhcp layer found in %x", raw)
+	}
+
+	if eth.DstMAC.String() != testDHCPClientHW.String() || !ip4.DstIP.Equal(req.ClientIP) {
+		t.Fatalf("expected unicast reply, got %s %s", eth.DstMAC, ip4.DstIP)
+	} else if msgType := DHCPMessageType(dhcp); msgType != layers.DHCPMsgTypeAck {
+		t.Fatalf("unexpected message type %s", msgType)
+	} else if !dhcp.YourClientIP.Equal(net.IPv4zero) {
+		t.Fatalf("unexpected assigned address %s", dhcp.YourClientIP)
+	} else if _, found := DHCPOptionData(dhcp, layers.DHCPOptLeaseTime); found {
+		t.Fatal("unexpected lease time")
+	} else if data, found := DHCPOptionData(dhcp, DHCPOptWPAD); !found || string(data) != config.WPAD {
+		t.Fatalf("unexpected wpad option '%s'", data)
+	}
+}
@@ -0,0 +1,139 @@
+package packets
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	DNP3Port = 20000
+
+	dnp3HeaderSize = 10
+	dnp3BlockSize  = 16
+
+	dnp3LinkDir = 0x80
+	dnp3LinkPrm = 0x40
+
+	dnp3ConfirmedUserData   = 0x03
+	dnp3UnconfirmedUserData = 0x04
+
+	DNP3Response            = 0x81
+	DNP3UnsolicitedResponse = 0x82
+)
+
+var dnp3Functions = map[byte]string{
+	0x00: "confirm",
+	0x01: "read",
+	0x02: "write",
+	0x03: "select",
+	0x04: "operate",
+	0x05: "direct operate",
+	0x06: "direct operate no ack",
+	0x07: "immediate freeze",
+	0x08: "immediate freeze no ack",
+	0x09: "freeze clear",
+	0x0a: "freeze clear no ack",
+	0x0d: "cold restart",
+	0x0e: "warm restart",
+	0x0f: "initialize data",
+	0x10: "initialize application",
+	0x11: "start application",
+	0x12: "stop application",
+	0x14: "enable unsolicited",
+	0x15: "disable unsolicited",
+	0x16: "assign class",
+	0x17: "delay measure",
+	0x18: "record current time",
+	0x19: "open file",
+	0x1a: "close file",
+	0x1b: "delete file",
+	0x1e: "abort file",
+	0x81: "response",
+	0x82: "unsolicited response",
+}
+
+// DNP3Frame is a DNP3 link layer frame and, if it carries user data, the
+// function of its application layer fragment.
+type DNP3Frame struct {
+	Control     byte
+	Destination uint16
+	Source      uint16
+	HasApp      bool
+	AppControl  byte
+	AppFunction byte
+	// internal indications of responses
+	IIN uint16
+}
+
+// dnp3CRC computes the CRC of the header and the data blocks.
+func dnp3CRC(data []byte) uint16 {
+	crc := uint16(0)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = (crc >> 1) ^ 0xa6bc
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return ^crc
+}
+
+// ParseDNP3 parses the DNP3 frame at the beginning of data.
+func ParseDNP3(data []byte) (*DNP3Frame, error) {
+	if len(data) < dnp3HeaderSize {
+		return nil, fmt.Errorf("dnp3 frame too short")
+	} else if data[0] != 0x05 || data[1] != 0x64 {
+		return nil, fmt.Errorf("missing dnp3 start bytes")
+	} else if data[2] < 5 {
+		return nil, fmt.Errorf("invalid dnp3 length %d", data[2])
+	} else if crc := binary.LittleEndian.Uint16(data[8:]); crc != dnp3CRC(data[:8]) {
+		return nil, fmt.Errorf("invalid dnp3 header crc")
+	}
+
+	f := &DNP3Frame{
+		Control:     data[3],
+		Destination: binary.LittleEndian.Uint16(data[4:]),
+		Source:      binary.LittleEndian.Uint16(data[6:]),
+	}
+
+	linkFunction := f.Control & 0x0f
+	if f.Control&dnp3LinkPrm == 0 || (linkFunction != dnp3ConfirmedUserData && linkFunction != dnp3UnconfirmedUserData) {
+		return f, nil
+	}
+
+	// the length counts control, addresses and user data, crcs excluded
+	userSize := int(data[2]) - 5
+	block := data[dnp3HeaderSize:]
+	if userSize > dnp3BlockSize {
+		userSize = dnp3BlockSize
+	}
+	if len(block) < userSize+2 || userSize < 3 {
+		return f, nil
+	}
+
+	// transport header, application control and function
+	f.HasApp = true
+	f.AppControl = block[1]
+	f.AppFunction = block[2]
+	if (f.AppFunction == DNP3Response || f.AppFunction == DNP3UnsolicitedResponse) && userSize >= 5 {
+		f.IIN = binary.BigEndian.Uint16(block[3:])
+	}
+	return f, nil
+}
+
+// FromMaster returns true if the frame has been sent by the master station.
+func (f DNP3Frame) FromMaster() bool {
+	return f.Control&dnp3LinkDir != 0
+}
+
+func (f DNP3Frame) FunctionName() string {
+	if !f.HasApp {
+		return fmt.Sprintf("link function %d", f.Control&0x0f)
+	} else if name, found := dnp3Functions[f.AppFunction]; found {
+		return name
+	}
+	return fmt.Sprintf("function 0x%02x", f.AppFunction)
+}
@@ -0,0 +1,52 @@
+package packets
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func dnp3Frame(header []byte, user []byte) []byte {
+	frame := append([]byte{}, header...)
+	frame = append(frame, 0, 0)
+	binary.LittleEndian.PutUint16(frame[8:], dnp3CRC(header))
+	if len(user) > 0 {
+		frame = append(frame, user...)
+		frame = append(frame, 0, 0)
+		binary.LittleEndian.PutUint16(frame[len(frame)-2:], dnp3CRC(user))
+	}
+	return frame
+}
+
+func TestDNP3CRC(t *testing.T) {
+	// reset link states
+	header := []byte{0x05, 0x64, 0x05, 0xc0, 0x01, 0x00, 0x00, 0x04}
+	if crc := dnp3CRC(header); crc != 0x21e9 {
+		t.Fatalf("unexpected crc %04x", crc)
+	}
+}
+
+func TestParseDNP3(t *testing.T) {
+	// unconfirmed user data from the master with a read request
+	frame := dnp3Frame([]byte{0x05, 0x64, 0x08, 0xc4, 0x0a, 0x00, 0x01, 0x00}, []byte{0xc0, 0xc1, 0x01})
+	f, err := ParseDNP3(frame)
+	if err != nil {
+		t.Fatal(err)
+	} else if !f.FromMaster() || !f.HasApp || f.FunctionName() != "read" {
+		t.Fatalf("unexpected frame %+v", f)
+	} else if f.Destination != 10 || f.Source != 1 {
+		t.Fatalf("unexpected addresses %d and %d", f.Destination, f.Source)
+	}
+
+	// response from the outstation with its internal indications
+	frame = dnp3Frame([]byte{0x05, 0x64, 0x0a, 0x44, 0x01, 0x00, 0x0a, 0x00}, []byte{0xc0, 0xc1, 0x81, 0x90, 0x00})
+	if f, err = ParseDNP3(frame); err != nil {
+		t.Fatal(err)
+	} else if f.FromMaster() || f.FunctionName() != "response" || f.IIN != 0x9000 {
+		t.Fatalf("unexpected frame %+v", f)
+	}
+
+	frame[8] ^= 0xff
+	if _, err = ParseDNP3(frame); err == nil {
+		t.Fatal("expected error for a bad crc")
+	}
+}
@@ -0,0 +1,64 @@
+package packets
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	ICMPTypeRedirect     = 5
+	ICMPCodeRedirectHost = 1
+
+	icmpRedirectSrcPort = 41582
+	icmpRedirectDstPort = 53
+)
+
+// NewICMPRedirect returns an ICMP host redirect sent on behalf of gateway,
+// telling target to route the traffic for destination through newGateway.
+func NewICMPRedirect(srcHW net.HardwareAddr, gateway net.IP, target net.IP, targetHW net.HardwareAddr, destination net.IP, newGateway net.IP) (error, []byte) {
+	eth := layers.Ethernet{
+		SrcMAC:       srcHW,
+		DstMAC:       targetHW,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{
+		Protocol: layers.IPProtocolICMPv4,
+		Version:  4,
+		TTL:      64,
+		SrcIP:    gateway,
+		DstIP:    target,
+	}
+
+	newGW := newGateway.To4()
+	icmp := layers.ICMPv4{
+		TypeCode: layers.CreateICMPv4TypeCode(ICMPTypeRedirect, ICMPCodeRedirectHost),
+		// the address of the new gateway takes the place of id and sequence
+		Id:  binary.BigEndian.Uint16(newGW[0:]),
+		Seq: binary.BigEndian.Uint16(newGW[2:]),
+	}
+
+	// the header and the first 8 bytes of the datagram that triggered the
+	// redirect, a packet from target to destination
+	origIP := layers.IPv4{
+		Protocol: layers.IPProtocolUDP,
+		Version:  4,
+		TTL:      64,
+		SrcIP:    target,
+		DstIP:    destination,
+	}
+	origUDP := layers.UDP{
+		SrcPort: icmpRedirectSrcPort,
+		DstPort: icmpRedirectDstPort,
+	}
+	origUDP.SetNetworkLayerForChecksum(&origIP)
+
+	err, orig := Serialize(&origIP, &origUDP)
+	if err != nil {
+		return err, nil
+	}
+
+	return Serialize(&eth, &ip4, &icmp, gopacket.Payload(orig))
+}
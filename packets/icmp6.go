@@ -1,8 +1,10 @@
 package packets
 
 import (
-	"github.com/google/gopacket/layers"
+	"encoding/binary"
 	"net"
+
+	"github.com/google/gopacket/layers"
 )
 
 func ICMP6NeighborAdvertisement(srcHW net.HardwareAddr, srcIP net.IP, dstHW net.HardwareAddr, dstIP net.IP, routerIP net.IP) (error, []byte) {
@@ -40,7 +42,20 @@ func ICMP6NeighborAdvertisement(srcHW net.HardwareAddr, srcIP net.IP, dstHW net.
 var macIpv6Multicast = net.HardwareAddr([]byte{0x33, 0x33, 0x00, 0x00, 0x00, 0x01})
 var ipv6Multicast = net.ParseIP("ff02::1")
 
+// ICMP6RouterAdvertisement returns a router advertisement announcing us as
+// the default router and the given prefix as on-link.
 func ICMP6RouterAdvertisement(ip net.IP, hw net.HardwareAddr, prefix string, prefixLength uint8) (error, []byte) {
+	// router lifetime 1800, valid lifetime 2592000 and preferred lifetime 604800
+	return icmp6RouterAdvertisement(ip, hw, prefix, prefixLength, 1800, 2592000, 604800)
+}
+
+// ICMP6RouterWithdrawal returns a router advertisement with every lifetime
+// set to zero, removing us from the default routers and deprecating the prefix.
+func ICMP6RouterWithdrawal(ip net.IP, hw net.HardwareAddr, prefix string, prefixLength uint8) (error, []byte) {
+	return icmp6RouterAdvertisement(ip, hw, prefix, prefixLength, 0, 0, 0)
+}
+
+func icmp6RouterAdvertisement(ip net.IP, hw net.HardwareAddr, prefix string, prefixLength uint8, routerLifetime uint16, validLifetime, preferredLifetime uint32) (error, []byte) {
 	eth := layers.Ethernet{
 		SrcMAC:       hw,
 		DstMAC:       macIpv6Multicast,
@@ -57,19 +72,17 @@ func ICMP6RouterAdvertisement(ip net.IP, hw net.HardwareAddr, prefix string, pre
 	icmp6 := layers.ICMPv6{
 		TypeCode: layers.ICMPv6TypeRouterAdvertisement << 8,
 	}
-	prefixData := []byte{
-		prefixLength,
-		0x0c,                   // flags
-		0x00, 0x27, 0x8d, 0x00, // valid lifetime (2592000)
-		0x00, 0x09, 0x3a, 0x80, // preferred lifetime (604800)
-		0x00, 0x00, 0x00, 0x00, // reserved
-	}
+	prefixData := make([]byte, 14, 14+net.IPv6len)
+	prefixData[0] = prefixLength
+	prefixData[1] = 0x0c // flags
+	binary.BigEndian.PutUint32(prefixData[2:], validLifetime)
+	binary.BigEndian.PutUint32(prefixData[6:], preferredLifetime)
 	prefixData = append(prefixData, []byte(net.ParseIP(prefix))...)
 
 	adv := layers.ICMPv6RouterAdvertisement{
 		HopLimit:       255,
 		Flags:          0x08, // prf
-		RouterLifetime: 1800,
+		RouterLifetime: routerLifetime,
 		Options: []layers.ICMPv6Option{
 			{
 				Type: layers.ICMPv6OptSourceAddress,
@@ -89,3 +102,64 @@ func ICMP6RouterAdvertisement(ip net.IP, hw net.HardwareAddr, prefix string, pre
 
 	return Serialize(&eth, &ip6, &icmp6, &adv)
 }
+
+// ICMP6ManagedRouterAdvertisement returns a router advertisement with the
+// managed and other configuration flags set, telling hosts to configure
+// themselves via DHCPv6, with a zero lifetime so that we are not used as
+// their default router.
+func ICMP6ManagedRouterAdvertisement(ip net.IP, hw net.HardwareAddr) (error, []byte) {
+	eth := layers.Ethernet{
+		SrcMAC:       hw,
+		DstMAC:       macIpv6Multicast,
+		EthernetType: layers.EthernetTypeIPv6,
+	}
+	ip6 := layers.IPv6{
+		NextHeader:   layers.IPProtocolICMPv6,
+		TrafficClass: 224,
+		Version:      6,
+		HopLimit:     255,
+		SrcIP:        ip,
+		DstIP:        ipv6Multicast,
+	}
+	icmp6 := layers.ICMPv6{
+		TypeCode: layers.ICMPv6TypeRouterAdvertisement << 8,
+	}
+	adv := layers.ICMPv6RouterAdvertisement{
+		HopLimit: 255,
+		Flags:    0x80 | 0x40, // managed && other
+		Options: []layers.ICMPv6Option{
+			{
+				Type: layers.ICMPv6OptSourceAddress,
+				Data: hw,
+			},
+		},
+	}
+	icmp6.SetNetworkLayerForChecksum(&ip6)
+
+	return Serialize(&eth, &ip6, &icmp6, &adv)
+}
+
+func ICMP6AllNodesEcho(srcHW net.HardwareAddr, srcIP net.IP) (error, []byte) {
+	eth := layers.Ethernet{
+		SrcMAC:       srcHW,
+		DstMAC:       macIpv6Multicast,
+		EthernetType: layers.EthernetTypeIPv6,
+	}
+	ip6 := layers.IPv6{
+		NextHeader: layers.IPProtocolICMPv6,
+		Version:    6,
+		HopLimit:   255,
+		SrcIP:      srcIP,
+		DstIP:      ipv6Multicast,
+	}
+	icmp6 := layers.ICMPv6{
+		TypeCode: layers.ICMPv6TypeEchoRequest << 8,
+	}
+	echo := layers.ICMPv6Echo{
+		Identifier: 0xbeef,
+		SeqNumber:  1,
+	}
+	icmp6.SetNetworkLayerForChecksum(&ip6)
+
+	return Serialize(&eth, &ip6, &icmp6, &echo)
+}
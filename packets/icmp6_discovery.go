@@ -0,0 +1,249 @@
+package packets
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	ICMP6TypeMLDQuery     = 130
+	ICMP6TypeMLDReport    = 131
+	ICMP6TypeMLDv2Report  = 143
+	ICMP6TypeNodeInfo     = 139
+	ICMP6TypeNodeInfoResp = 140
+
+	// RFC 4620, the subject of the query is an IPv6 address
+	NodeInfoSubjectIPv6 = 0
+	NodeInfoSuccess     = 0
+	NodeInfoQTypeName   = 2
+
+	// how long hosts can wait before answering a query, in milliseconds
+	MLDQueryResponseDelay = 5000
+)
+
+// MLDQueryBody returns the body of an MLDv2 general query, asking every
+// listener on the link to report the multicast groups it joined.
+func MLDQueryBody() []byte {
+	body := make([]byte, 24)
+	binary.BigEndian.PutUint16(body[0:], MLDQueryResponseDelay)
+	// the multicast address is left unspecified for a general query,
+	// then robustness variable and query interval
+	body[20] = 2
+	body[21] = 125
+	return body
+}
+
+// ICMP6MLDQuery returns a general MLD query for the all-nodes address, it
+// must be sent from a link-local address with the router alert option.
+func ICMP6MLDQuery(srcHW net.HardwareAddr, srcIP net.IP) (error, []byte) {
+	eth := layers.Ethernet{
+		SrcMAC:       srcHW,
+		DstMAC:       macIpv6Multicast,
+		EthernetType: layers.EthernetTypeIPv6,
+	}
+	hbh := &layers.IPv6HopByHop{
+		Options: []*layers.IPv6HopByHopOption{
+			{
+				// router alert, MLD message
+				OptionType:   0x05,
+				OptionLength: 2,
+				OptionData:   []byte{0x00, 0x00},
+			},
+			{
+				// PadN, the header must be 8 bytes long
+				OptionType: 0x01,
+			},
+		},
+	}
+	hbh.NextHeader = layers.IPProtocolICMPv6
+	ip6 := layers.IPv6{
+		NextHeader: layers.IPProtocolIPv6HopByHop,
+		Version:    6,
+		HopLimit:   1,
+		SrcIP:      srcIP,
+		DstIP:      ipv6Multicast,
+		HopByHop:   hbh,
+	}
+	icmp6 := layers.ICMPv6{
+		TypeCode: ICMP6TypeMLDQuery << 8,
+	}
+	icmp6.SetNetworkLayerForChecksum(&ip6)
+
+	return Serialize(&eth, &ip6, &icmp6, gopacket.Payload(MLDQueryBody()))
+}
+
+// NodeNameQueryBody returns the body of a node information query for the
+// name of the nodes listening on subject.
+func NodeNameQueryBody(nonce uint64, subject net.IP) []byte {
+	body := make([]byte, 12, 12+net.IPv6len)
+	binary.BigEndian.PutUint16(body[0:], NodeInfoQTypeName)
+	binary.BigEndian.PutUint64(body[4:], nonce)
+	return append(body, subject.To16()...)
+}
+
+// ICMP6NodeNameQuery returns a node information query sent to the all-nodes
+// address, asking every host supporting RFC 4620 for its name.
+func ICMP6NodeNameQuery(srcHW net.HardwareAddr, srcIP net.IP, nonce uint64) (error, []byte) {
+	eth := layers.Ethernet{
+		SrcMAC:       srcHW,
+		DstMAC:       macIpv6Multicast,
+		EthernetType: layers.EthernetTypeIPv6,
+	}
+	ip6 := layers.IPv6{
+		NextHeader: layers.IPProtocolICMPv6,
+		Version:    6,
+		HopLimit:   255,
+		SrcIP:      srcIP,
+		DstIP:      ipv6Multicast,
+	}
+	icmp6 := layers.ICMPv6{
+		TypeCode: ICMP6TypeNodeInfo<<8 | NodeInfoSubjectIPv6,
+	}
+	icmp6.SetNetworkLayerForChecksum(&ip6)
+
+	return Serialize(&eth, &ip6, &icmp6, gopacket.Payload(NodeNameQueryBody(nonce, ipv6Multicast)))
+}
+
+// ParseNodeNameReply returns the first name found in the body of a
+// successful node information reply to a name query.
+func ParseNodeNameReply(body []byte) (string, bool) {
+	// qtype, flags, nonce and ttl
+	if len(body) < 16 || binary.BigEndian.Uint16(body[0:]) != NodeInfoQTypeName {
+		return "", false
+	}
+
+	// dns wire format, single label names might be missing the final zero
+	labels := []string{}
+	for data := body[16:]; len(data) > 0; {
+		size := int(data[0])
+		if size == 0 {
+			break
+		} else if size > 63 || 1+size > len(data) {
+			return "", false
+		}
+		labels = append(labels, string(data[1:1+size]))
+		data = data[1+size:]
+	}
+
+	if len(labels) == 0 {
+		return "", false
+	}
+	return strings.Join(labels, "."), true
+}
+
+// ParseMLDReport returns the multicast groups listed in the body of either
+// an MLDv1 or MLDv2 listener report.
+func ParseMLDReport(icmpType uint8, body []byte) []net.IP {
+	groups := []net.IP{}
+
+	if icmpType == ICMP6TypeMLDReport {
+		if len(body) >= 4+net.IPv6len {
+			groups = append(groups, net.IP(body[4:4+net.IPv6len]))
+		}
+		return groups
+	} else if icmpType != ICMP6TypeMLDv2Report || len(body) < 4 {
+		return groups
+	}
+
+	records := int(binary.BigEndian.Uint16(body[2:]))
+	data := body[4:]
+	for i := 0; i < records && len(data) >= 4+net.IPv6len; i++ {
+		auxLen := int(data[1]) * 4
+		sources := int(binary.BigEndian.Uint16(data[2:]))
+		groups = append(groups, net.IP(data[4:4+net.IPv6len]))
+
+		size := 4 + net.IPv6len + sources*net.IPv6len + auxLen
+		if size > len(data) {
+			break
+		}
+		data = data[size:]
+	}
+
+	return groups
+}
+
+// ICMP6GetMeta extracts the names from node information replies and the
+// multicast groups from MLD reports.
+func ICMP6GetMeta(pkt gopacket.Packet) map[string]string {
+	licmp6 := pkt.Layer(layers.LayerTypeICMPv6)
+	if licmp6 == nil {
+		return nil
+	}
+
+	icmp6 := licmp6.(*layers.ICMPv6)
+	body := icmp6.LayerPayload()
+	switch icmp6.TypeCode.Type() {
+	case ICMP6TypeNodeInfoResp:
+		if icmp6.TypeCode.Code() == NodeInfoSuccess {
+			if name, ok := ParseNodeNameReply(body); ok {
+				return map[string]string{
+					"icmp6:hostname": name,
+				}
+			}
+		}
+
+	case ICMP6TypeMLDReport, ICMP6TypeMLDv2Report:
+		if groups := ParseMLDReport(icmp6.TypeCode.Type(), body); len(groups) > 0 {
+			list := make([]string, len(groups))
+			for i, group := range groups {
+				list[i] = group.String()
+			}
+			return map[string]string{
+				"mld:groups": strings.Join(list, ","),
+			}
+		}
+	}
+
+	return nil
+}
+
+// SolicitedNodeMulticast returns the solicited-node multicast address of ip
+// and the hardware address the packets sent to it are delivered to.
+func SolicitedNodeMulticast(ip net.IP) (net.IP, net.HardwareAddr) {
+	ip = ip.To16()
+	return net.IP{0xff, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01, 0xff, ip[13], ip[14], ip[15]},
+		net.HardwareAddr{0x33, 0x33, 0xff, ip[13], ip[14], ip[15]}
+}
+
+// EUI64LinkLocal returns the link-local address a host with the given
+// hardware address has when it doesn't use privacy extensions.
+func EUI64LinkLocal(hw net.HardwareAddr) net.IP {
+	return net.IP{0xfe, 0x80, 0, 0, 0, 0, 0, 0, hw[0] ^ 0x02, hw[1], hw[2], 0xff, 0xfe, hw[3], hw[4], hw[5]}
+}
+
+// ICMP6NeighborSolicitation returns a neighbor solicitation for target, sent
+// to its solicited-node multicast address.
+func ICMP6NeighborSolicitation(srcHW net.HardwareAddr, srcIP net.IP, target net.IP) (error, []byte) {
+	dstIP, dstHW := SolicitedNodeMulticast(target)
+	eth := layers.Ethernet{
+		SrcMAC:       srcHW,
+		DstMAC:       dstHW,
+		EthernetType: layers.EthernetTypeIPv6,
+	}
+	ip6 := layers.IPv6{
+		NextHeader: layers.IPProtocolICMPv6,
+		Version:    6,
+		HopLimit:   255,
+		SrcIP:      srcIP,
+		DstIP:      dstIP,
+	}
+	icmp6 := layers.ICMPv6{
+		TypeCode: layers.ICMPv6TypeNeighborSolicitation << 8,
+	}
+	ns := layers.ICMPv6NeighborSolicitation{
+		TargetAddress: target,
+		Options: []layers.ICMPv6Option{
+			{
+				Type: layers.ICMPv6OptSourceAddress,
+				Data: srcHW,
+			},
+		},
+	}
+	icmp6.SetNetworkLayerForChecksum(&ip6)
+
+	return Serialize(&eth, &ip6, &icmp6, &ns)
+}
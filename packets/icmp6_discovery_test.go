@@ -0,0 +1,127 @@
+package packets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestMLDQueryBody(t *testing.T) {
+	body := MLDQueryBody()
+	if len(body) != 24 {
+		t.Fatalf("unexpected body size %d", len(body))
+	} else if delay := binary.BigEndian.Uint16(body); delay != MLDQueryResponseDelay {
+		t.Fatalf("unexpected response delay %d", delay)
+	} else if !net.IP(body[4:20]).Equal(net.IPv6unspecified) {
+		t.Fatalf("expected a general query, got %s", net.IP(body[4:20]))
+	}
+}
+
+func TestNodeNameQueryBody(t *testing.T) {
+	body := NodeNameQueryBody(0x1122334455667788, ipv6Multicast)
+	if len(body) != 28 {
+		t.Fatalf("unexpected body size %d", len(body))
+	} else if qtype := binary.BigEndian.Uint16(body); qtype != NodeInfoQTypeName {
+		t.Fatalf("unexpected qtype %d", qtype)
+	} else if nonce := binary.BigEndian.Uint64(body[4:]); nonce != 0x1122334455667788 {
+		t.Fatalf("unexpected nonce %x", nonce)
+	} else if !net.IP(body[12:]).Equal(ipv6Multicast) {
+		t.Fatalf("unexpected subject %s", net.IP(body[12:]))
+	}
+}
+
+func TestParseNodeNameReply(t *testing.T) {
+	reply := NodeNameQueryBody(1, ipv6Multicast)[:12]
+	reply = append(reply, 0, 0, 0, 0)
+
+	var units = []struct {
+		name []byte
+		exp  string
+		ok   bool
+	}{
+		{[]byte("\x07macbook\x05local\x00"), "macbook.local", true},
+		{[]byte("\x07macbook\x00\x00"), "macbook", true},
+		{[]byte("\x07macbook"), "macbook", true},
+		{[]byte("\x09macbook"), "", false},
+		{[]byte{}, "", false},
+	}
+	for _, u := range units {
+		got, ok := ParseNodeNameReply(append(append([]byte{}, reply...), u.name...))
+		if got != u.exp || ok != u.ok {
+			t.Fatalf("expected '%s' (%v), got '%s' (%v)", u.exp, u.ok, got, ok)
+		}
+	}
+
+	if _, ok := ParseNodeNameReply(reply[:10]); ok {
+		t.Fatal("expected short reply to be rejected")
+	}
+}
+
+func TestParseMLDReport(t *testing.T) {
+	mdns := net.ParseIP("ff02::fb")
+	llmnr := net.ParseIP("ff02::1:3")
+
+	v1 := append(make([]byte, 4), mdns...)
+	if groups := ParseMLDReport(ICMP6TypeMLDReport, v1); len(groups) != 1 || !groups[0].Equal(mdns) {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+
+	v2 := []byte{0, 0, 0, 2}
+	// change to exclude mode with one source and one word of aux data
+	v2 = append(v2, 4, 1, 0, 1)
+	v2 = append(v2, mdns...)
+	v2 = append(v2, bytes.Repeat([]byte{0xaa}, net.IPv6len+4)...)
+	v2 = append(v2, 4, 0, 0, 0)
+	v2 = append(v2, llmnr...)
+
+	groups := ParseMLDReport(ICMP6TypeMLDv2Report, v2)
+	if len(groups) != 2 || !groups[0].Equal(mdns) || !groups[1].Equal(llmnr) {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+
+	// truncated records are ignored
+	if groups = ParseMLDReport(ICMP6TypeMLDv2Report, v2[:30]); len(groups) != 1 {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+
+	if groups = ParseMLDReport(ICMP6TypeMLDQuery, v1); len(groups) != 0 {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+}
+
+func TestEUI64LinkLocal(t *testing.T) {
+	hw, _ := net.ParseMAC("00:11:22:33:44:55")
+	exp := net.ParseIP("fe80::211:22ff:fe33:4455")
+	if got := EUI64LinkLocal(hw); !got.Equal(exp) {
+		t.Fatalf("expected %s, got %s", exp, got)
+	}
+}
+
+func TestICMP6NeighborSolicitation(t *testing.T) {
+	hw, _ := net.ParseMAC("00:11:22:33:44:55")
+	src := net.ParseIP("fe80::1")
+	target := net.ParseIP("fe80::211:22ff:fe33:4455")
+
+	err, raw := ICMP6NeighborSolicitation(hw, src, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+	eth := pkt.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	ip6 := pkt.Layer(layers.LayerTypeIPv6).(*layers.IPv6)
+	ns, ok := pkt.Layer(layers.LayerTypeICMPv6NeighborSolicitation).(*layers.ICMPv6NeighborSolicitation)
+	if !ok {
+		t.Fatal("expected a neighbor solicitation")
+	} else if !bytes.Equal(eth.DstMAC, []byte{0x33, 0x33, 0xff, 0x33, 0x44, 0x55}) {
+		t.Fatalf("unexpected destination mac %s", eth.DstMAC)
+	} else if !ip6.DstIP.Equal(net.ParseIP("ff02::1:ff33:4455")) {
+		t.Fatalf("unexpected destination %s", ip6.DstIP)
+	} else if !ns.TargetAddress.Equal(target) {
+		t.Fatalf("unexpected target %s", ns.TargetAddress)
+	}
+}
@@ -0,0 +1,90 @@
+package packets
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func decodeRouterAdvertisement(t *testing.T, raw []byte) (*layers.ICMPv6RouterAdvertisement, []byte) {
+	pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+	layer := pkt.Layer(layers.LayerTypeICMPv6RouterAdvertisement)
+	if layer == nil {
+		t.Fatalf("no router advertisement found in %x", raw)
+	}
+
+	ra := layer.(*layers.ICMPv6RouterAdvertisement)
+	for _, opt := range ra.Options {
+		if opt.Type == layers.ICMPv6OptPrefixInfo {
+			return ra, opt.Data
+		}
+	}
+	t.Fatal("no prefix information found")
+	return nil, nil
+}
+
+func TestICMP6RouterAdvertisement(t *testing.T) {
+	hw, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	ip := net.ParseIP("fe80::1")
+
+	err, raw := ICMP6RouterAdvertisement(ip, hw, "d00d::", 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ra, prefix := decodeRouterAdvertisement(t, raw)
+	if ra.RouterLifetime != 1800 {
+		t.Fatalf("unexpected router lifetime %d", ra.RouterLifetime)
+	} else if prefix[0] != 64 {
+		t.Fatalf("unexpected prefix length %d", prefix[0])
+	} else if valid := binary.BigEndian.Uint32(prefix[2:]); valid != 2592000 {
+		t.Fatalf("unexpected valid lifetime %d", valid)
+	} else if preferred := binary.BigEndian.Uint32(prefix[6:]); preferred != 604800 {
+		t.Fatalf("unexpected preferred lifetime %d", preferred)
+	} else if !net.IP(prefix[14:]).Equal(net.ParseIP("d00d::")) {
+		t.Fatalf("unexpected prefix %s", net.IP(prefix[14:]))
+	}
+}
+
+func TestICMP6RouterWithdrawal(t *testing.T) {
+	hw, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	ip := net.ParseIP("fe80::1")
+
+	err, raw := ICMP6RouterWithdrawal(ip, hw, "d00d::", 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ra, prefix := decodeRouterAdvertisement(t, raw)
+	if ra.RouterLifetime != 0 {
+		t.Fatalf("unexpected router lifetime %d", ra.RouterLifetime)
+	} else if valid := binary.BigEndian.Uint32(prefix[2:]); valid != 0 {
+		t.Fatalf("unexpected valid lifetime %d", valid)
+	} else if preferred := binary.BigEndian.Uint32(prefix[6:]); preferred != 0 {
+		t.Fatalf("unexpected preferred lifetime %d", preferred)
+	}
+}
+
+func TestICMP6ManagedRouterAdvertisement(t *testing.T) {
+	hw, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	err, raw := ICMP6ManagedRouterAdvertisement(net.ParseIP("fe80::1"), hw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+	layer := pkt.Layer(layers.LayerTypeICMPv6RouterAdvertisement)
+	if layer == nil {
+		t.Fatalf("no router advertisement found in %x", raw)
+	}
+
+	ra := layer.(*layers.ICMPv6RouterAdvertisement)
+	if !ra.ManagedAddressConfig() || !ra.OtherConfig() {
+		t.Fatalf("unexpected flags %02x", ra.Flags)
+	} else if ra.RouterLifetime != 0 {
+		t.Fatalf("unexpected router lifetime %d", ra.RouterLifetime)
+	}
+}
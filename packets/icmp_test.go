@@ -0,0 +1,54 @@
+package packets
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestNewICMPRedirect(t *testing.T) {
+	srcHW, _ := net.ParseMAC("11:22:33:44:55:66")
+	targetHW, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	gateway := net.ParseIP("192.168.1.1")
+	target := net.ParseIP("192.168.1.42")
+	destination := net.ParseIP("10.0.0.1")
+	newGateway := net.ParseIP("192.168.1.5")
+
+	err, raw := NewICMPRedirect(srcHW, gateway, target, targetHW, destination, newGateway)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+	eth := pkt.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	ip4 := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	icmp, ok := pkt.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4)
+	if !ok {
+		t.Fatalf("no icmp layer found in %x", raw)
+	}
+
+	if eth.DstMAC.String() != targetHW.String() || eth.SrcMAC.String() != srcHW.String() {
+		t.Fatalf("unexpected ethernet addresses %s -> %s", eth.SrcMAC, eth.DstMAC)
+	} else if !ip4.SrcIP.Equal(gateway) || !ip4.DstIP.Equal(target) {
+		t.Fatalf("unexpected addresses %s -> %s", ip4.SrcIP, ip4.DstIP)
+	} else if icmp.TypeCode.Type() != ICMPTypeRedirect || icmp.TypeCode.Code() != ICMPCodeRedirectHost {
+		t.Fatalf("unexpected type %s", icmp.TypeCode)
+	}
+
+	if gw := net.IPv4(byte(icmp.Id>>8), byte(icmp.Id), byte(icmp.Seq>>8), byte(icmp.Seq)); !gw.Equal(newGateway) {
+		t.Fatalf("unexpected gateway %s", gw)
+	}
+
+	// the payload is the header of the original datagram plus 8 bytes
+	orig := gopacket.NewPacket(icmp.Payload, layers.LayerTypeIPv4, gopacket.Default)
+	origIP, ok := orig.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !ok {
+		t.Fatalf("no original datagram found in %x", icmp.Payload)
+	} else if len(icmp.Payload) != 20+8 {
+		t.Fatalf("unexpected payload size %d", len(icmp.Payload))
+	} else if !origIP.SrcIP.Equal(target) || !origIP.DstIP.Equal(destination) {
+		t.Fatalf("unexpected original addresses %s -> %s", origIP.SrcIP, origIP.DstIP)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"encoding/asn1"
@@ -12,6 +13,8 @@ import (
 
 const (
 	Krb5AsRequestType         = 10
+	Krb5AsReplyType           = 11
+	Krb5TgsReplyType          = 13
 	Krb5Krb5PrincipalNameType = 1
 	Krb5CryptDesCbcMd4        = 2
 	Krb5CryptDescCbcMd5       = 3
@@ -22,8 +25,13 @@ var (
 	ErrNoCrypt  = errors.New("No crypt alg found")
 	ErrReqData  = errors.New("Failed to extract pnData from as-req")
 	ErrNoCipher = errors.New("No encryption type or cipher found")
+	ErrNoReply  = errors.New("Not an as-rep or tgs-rep")
+	ErrEtype    = errors.New("Only rc4-hmac replies can be exported")
 
-	Krb5AsReqParam = "application,explicit,tag:10"
+	Krb5AsReqParam  = "application,explicit,tag:10"
+	Krb5AsRepParam  = "application,explicit,tag:11"
+	Krb5TgsRepParam = "application,explicit,tag:13"
+	Krb5TicketParam = "application,explicit,tag:1"
 )
 
 type Krb5PrincipalName struct {
@@ -112,3 +120,76 @@ func (pd Krb5PnData) getParsedValue() (Krb5EncryptedData, error) {
 	}
 	return encData, nil
 }
+
+// Krb5Reply is the KDC-REP message shared by AS-REP and TGS-REP, the
+// ticket is kept raw since it's tagged twice, the explicit context tag
+// wrapping the application one.
+type Krb5Reply struct {
+	Pvno       int               `asn1:"explicit,tag:0"`
+	MsgType    int               `asn1:"explicit,tag:1"`
+	Krb5PnData []Krb5PnData      `asn1:"optional,explicit,tag:2"`
+	Crealm     string            `asn1:"general,explicit,tag:3"`
+	Cname      Krb5PrincipalName `asn1:"explicit,tag:4"`
+	RawTicket  asn1.RawValue     `asn1:"explicit,tag:5"`
+	EncPart    Krb5EncryptedData `asn1:"explicit,tag:6"`
+}
+
+// ParseKrb5Reply parses an AS-REP or a TGS-REP message.
+func ParseKrb5Reply(data []byte) (*Krb5Reply, error) {
+	for _, param := range []string{Krb5AsRepParam, Krb5TgsRepParam} {
+		var rep Krb5Reply
+		if _, err := asn1.UnmarshalWithParams(data, &rep, param); err == nil {
+			return &rep, nil
+		}
+	}
+	return nil, ErrNoReply
+}
+
+func (rep Krb5Reply) Ticket() (Krb5Ticket, error) {
+	var ticket Krb5Ticket
+	if _, err := asn1.UnmarshalWithParams(rep.RawTicket.Bytes, &ticket, Krb5TicketParam); err != nil {
+		return Krb5Ticket{}, err
+	}
+	return ticket, nil
+}
+
+func (rep Krb5Reply) User() string {
+	return strings.Join(rep.Cname.NameString, "/")
+}
+
+// rc4-hmac ciphers start with the 16 bytes checksum
+func krb5SplitCipher(enc Krb5EncryptedData) (string, string, error) {
+	if enc.Etype != Krb5CryptRc4Hmac {
+		return "", "", ErrEtype
+	} else if len(enc.Cipher) <= 16 {
+		return "", "", ErrNoCipher
+	}
+	return hex.EncodeToString(enc.Cipher[:16]), hex.EncodeToString(enc.Cipher[16:]), nil
+}
+
+// Hash returns the reply in hashcat format, 18200 for AS-REP messages of
+// users without pre-authentication and 13100 for TGS-REP ones.
+func (rep Krb5Reply) Hash() (string, error) {
+	switch rep.MsgType {
+	case Krb5AsReplyType:
+		checksum, data, err := krb5SplitCipher(rep.EncPart)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("$krb5asrep$%d$%s@%s:%s$%s", rep.EncPart.Etype, rep.User(), rep.Crealm, checksum, data), nil
+
+	case Krb5TgsReplyType:
+		ticket, err := rep.Ticket()
+		if err != nil {
+			return "", err
+		}
+		checksum, data, err := krb5SplitCipher(ticket.EncPart)
+		if err != nil {
+			return "", err
+		}
+		spn := strings.Join(ticket.Sname.NameString, "/")
+		return fmt.Sprintf("$krb5tgs$%d$*%s$%s$%s*$%s$%s", ticket.EncPart.Etype, rep.User(), ticket.Realm, spn, checksum, data), nil
+	}
+
+	return "", ErrNoReply
+}
@@ -206,3 +206,71 @@ func TestKrb5Request(t *testing.T) {
 
 // TODO: add test for func (kdc Krb5Request) String()
 // TODO: add test for func (pd Krb5PnData) getParsedValue()
+
+func krb5TestReply(t *testing.T, msgType int, param string) []byte {
+	ticket, err := asn1.MarshalWithParams(Krb5Ticket{
+		TktVno: 5,
+		Realm:  "EXAMPLE.COM",
+		Sname:  Krb5PrincipalName{NameType: 2, NameString: []string{"MSSQLSvc", "db.example.com"}},
+		EncPart: Krb5EncryptedData{
+			Etype:  Krb5CryptRc4Hmac,
+			Cipher: append(make([]byte, 16), 0xaa, 0xbb),
+		},
+	}, Krb5TicketParam)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := asn1.MarshalWithParams(Krb5Reply{
+		Pvno:      5,
+		MsgType:   msgType,
+		Crealm:    "EXAMPLE.COM",
+		Cname:     Krb5PrincipalName{NameType: Krb5Krb5PrincipalNameType, NameString: []string{"alice"}},
+		RawTicket: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 5, IsCompound: true, Bytes: ticket},
+		EncPart: Krb5EncryptedData{
+			Etype:  Krb5CryptRc4Hmac,
+			Cipher: append([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}, 0xcc, 0xdd),
+		},
+	}, param)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestKrb5ReplyHash(t *testing.T) {
+	var units = []struct {
+		raw []byte
+		exp string
+	}{
+		{
+			krb5TestReply(t, Krb5AsReplyType, Krb5AsRepParam),
+			"$krb5asrep$23$alice@EXAMPLE.COM:0102030405060708090a0b0c0d0e0f10$ccdd",
+		},
+		{
+			krb5TestReply(t, Krb5TgsReplyType, Krb5TgsRepParam),
+			"$krb5tgs$23$*alice$EXAMPLE.COM$MSSQLSvc/db.example.com*$00000000000000000000000000000000$aabb",
+		},
+	}
+	for _, u := range units {
+		rep, err := ParseKrb5Reply(u.raw)
+		if err != nil {
+			t.Fatal(err)
+		} else if hash, err := rep.Hash(); err != nil {
+			t.Fatal(err)
+		} else if hash != u.exp {
+			t.Fatalf("expected '%s', got '%s'", u.exp, hash)
+		}
+	}
+}
+
+func TestKrb5ReplyErrors(t *testing.T) {
+	if _, err := ParseKrb5Reply([]byte{0x30, 0x00}); err != ErrNoReply {
+		t.Fatalf("expected '%v', got '%v'", ErrNoReply, err)
+	}
+
+	rep := Krb5Reply{MsgType: Krb5AsReplyType, EncPart: Krb5EncryptedData{Etype: 18, Cipher: make([]byte, 32)}}
+	if _, err := rep.Hash(); err != ErrEtype {
+		t.Fatalf("expected '%v', got '%v'", ErrEtype, err)
+	}
+}
@@ -0,0 +1,67 @@
+package packets
+
+import (
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	LLMNRPort = 5355
+
+	// not defined by gopacket
+	dnsTypeAny = layers.DNSType(255)
+)
+
+var (
+	LLMNRDestIP  = net.ParseIP("224.0.0.252")
+	LLMNRDestIP6 = net.ParseIP("ff02::1:3")
+)
+
+// NameQueryReply returns the payload of a reply to an LLMNR or mDNS query,
+// answering its A, AAAA and ANY questions with address and address6. If
+// none of the questions can be answered, a nil payload is returned.
+func NameQueryReply(req *layers.DNS, address net.IP, address6 net.IP, ttl uint32, class layers.DNSClass) (error, []byte) {
+	answers := make([]layers.DNSResourceRecord, 0)
+	for _, q := range req.Questions {
+		if (q.Type == layers.DNSTypeA || q.Type == dnsTypeAny) && address != nil {
+			answers = append(answers, layers.DNSResourceRecord{
+				Name:  q.Name,
+				Type:  layers.DNSTypeA,
+				Class: class,
+				TTL:   ttl,
+				IP:    address.To4(),
+			})
+		}
+		if (q.Type == layers.DNSTypeAAAA || q.Type == dnsTypeAny) && address6 != nil {
+			answers = append(answers, layers.DNSResourceRecord{
+				Name:  q.Name,
+				Type:  layers.DNSTypeAAAA,
+				Class: class,
+				TTL:   ttl,
+				IP:    address6,
+			})
+		}
+	}
+
+	if len(answers) == 0 {
+		return nil, nil
+	}
+
+	reply := layers.DNS{
+		ID:        req.ID,
+		QR:        true,
+		AA:        true,
+		OpCode:    layers.DNSOpCodeQuery,
+		QDCount:   req.QDCount,
+		Questions: req.Questions,
+		Answers:   answers,
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := reply.SerializeTo(buf, SerializationOptions); err != nil {
+		return err, nil
+	}
+	return nil, buf.Bytes()
+}
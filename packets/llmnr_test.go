@@ -0,0 +1,63 @@
+package packets
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestNameQueryReply(t *testing.T) {
+	address := net.ParseIP("192.168.1.5")
+	address6 := net.ParseIP("fe80::5")
+
+	var units = []struct {
+		qType    layers.DNSType
+		address6 net.IP
+		answers  []layers.DNSType
+	}{
+		{layers.DNSTypeA, address6, []layers.DNSType{layers.DNSTypeA}},
+		{layers.DNSTypeAAAA, address6, []layers.DNSType{layers.DNSTypeAAAA}},
+		{dnsTypeAny, address6, []layers.DNSType{layers.DNSTypeA, layers.DNSTypeAAAA}},
+		{layers.DNSTypeAAAA, nil, []layers.DNSType{}},
+		{layers.DNSTypeMX, address6, []layers.DNSType{}},
+	}
+
+	for _, u := range units {
+		req := &layers.DNS{
+			ID:      0x1234,
+			QDCount: 1,
+			Questions: []layers.DNSQuestion{
+				{Name: []byte("wpad"), Type: u.qType, Class: layers.DNSClassIN},
+			},
+		}
+
+		err, raw := NameQueryReply(req, address, u.address6, 30, layers.DNSClassIN)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(u.answers) == 0 {
+			if raw != nil {
+				t.Fatalf("unexpected reply for %s", u.qType)
+			}
+			continue
+		}
+
+		reply := gopacket.NewPacket(raw, layers.LayerTypeDNS, gopacket.Default).Layer(layers.LayerTypeDNS).(*layers.DNS)
+		if !reply.QR || reply.ID != 0x1234 || len(reply.Questions) != 1 {
+			t.Fatalf("unexpected reply %+v", reply)
+		} else if len(reply.Answers) != len(u.answers) {
+			t.Fatalf("expected %d answers, got %d", len(u.answers), len(reply.Answers))
+		}
+
+		for i, answer := range reply.Answers {
+			if answer.Type != u.answers[i] || string(answer.Name) != "wpad" || answer.TTL != 30 {
+				t.Fatalf("unexpected answer %+v", answer)
+			} else if answer.Type == layers.DNSTypeA && !answer.IP.Equal(address) {
+				t.Fatalf("unexpected address %s", answer.IP)
+			} else if answer.Type == layers.DNSTypeAAAA && !answer.IP.Equal(address6) {
+				t.Fatalf("unexpected address %s", answer.IP)
+			}
+		}
+	}
+}
@@ -9,13 +9,28 @@ import (
 	"github.com/google/gopacket/layers"
 )
 
-const MDNSPort = 5353
+const (
+	MDNSPort          = 5353
+	MDNSServicesQuery = "_services._dns-sd._udp.local"
+)
 
 var (
 	MDNSDestMac = net.HardwareAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0xfb}
 	MDNSDestIP  = net.ParseIP("224.0.0.251")
 )
 
+// MDNSServiceType returns the service type (for instance "_airplay._tcp")
+// of either a service or a service instance name.
+func MDNSServiceType(name string) string {
+	name = strings.TrimSuffix(strings.TrimSuffix(name, "."), ".local")
+	if !strings.HasPrefix(name, "_") {
+		if idx := strings.Index(name, "._"); idx != -1 {
+			name = name[idx+1:]
+		}
+	}
+	return name
+}
+
 func MDNSGetMeta(pkt gopacket.Packet) map[string]string {
 	meta := make(map[string]string)
 
@@ -32,11 +47,21 @@ func MDNSGetMeta(pkt gopacket.Packet) map[string]string {
 				answers := append(dns.Answers, dns.Additionals...)
 				answers = append(answers, dns.Authorities...)
 
+				services := []string{}
 				for _, answer := range answers {
 					switch answer.Type {
 					case layers.DNSTypePTR:
-					case layers.DNSTypeA:
-					case layers.DNSTypeAAAA:
+						if name := string(answer.Name); name == MDNSServicesQuery {
+							services = append(services, MDNSServiceType(string(answer.PTR)))
+						} else if strings.HasPrefix(name, "_") {
+							services = append(services, MDNSServiceType(name))
+						}
+
+					case layers.DNSTypeSRV:
+						services = append(services, MDNSServiceType(string(answer.Name)))
+						meta["mdns:hostname"] = string(answer.SRV.Name)
+
+					case layers.DNSTypeA, layers.DNSTypeAAAA:
 						meta["mdns:hostname"] = string(answer.Name)
 
 					case layers.DNSTypeTXT:
@@ -48,6 +73,10 @@ func MDNSGetMeta(pkt gopacket.Packet) map[string]string {
 						}
 					}
 				}
+
+				if len(services) > 0 {
+					meta["mdns:services"] = strings.Join(services, ",")
+				}
 			}
 		}
 	}
@@ -84,7 +113,7 @@ func NewMDNSProbe(from net.IP, from_hw net.HardwareAddr) (error, []byte) {
 		OpCode: layers.DNSOpCodeQuery,
 		Questions: []layers.DNSQuestion{
 			{
-				Name:  []byte("_services._dns-sd._udp.local"),
+				Name:  []byte(MDNSServicesQuery),
 				Type:  layers.DNSTypePTR,
 				Class: layers.DNSClassIN,
 			},
@@ -0,0 +1,24 @@
+package packets
+
+import (
+	"testing"
+)
+
+func TestMDNSServiceType(t *testing.T) {
+	var units = []struct {
+		from string
+		to   string
+	}{
+		{"_airplay._tcp.local", "_airplay._tcp"},
+		{"_ipp._tcp.local.", "_ipp._tcp"},
+		{"Living Room._airplay._tcp.local", "_airplay._tcp"},
+		{"My.Printer._ipp._tcp.local.", "_ipp._tcp"},
+		{"_smb._tcp", "_smb._tcp"},
+	}
+
+	for _, u := range units {
+		if got := MDNSServiceType(u.from); got != u.to {
+			t.Fatalf("expected '%s', got '%s'", u.to, got)
+		}
+	}
+}
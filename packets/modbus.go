@@ -0,0 +1,165 @@
+package packets
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	ModbusPort = 502
+
+	ModbusReadCoils              = 0x01
+	ModbusReadDiscreteInputs     = 0x02
+	ModbusReadHoldingRegisters   = 0x03
+	ModbusReadInputRegisters     = 0x04
+	ModbusWriteSingleCoil        = 0x05
+	ModbusWriteSingleRegister    = 0x06
+	ModbusReadExceptionStatus    = 0x07
+	ModbusDiagnostics            = 0x08
+	ModbusWriteMultipleCoils     = 0x0f
+	ModbusWriteMultipleRegisters = 0x10
+	ModbusReportServerID         = 0x11
+	ModbusMaskWriteRegister      = 0x16
+	ModbusReadWriteRegisters     = 0x17
+	ModbusEncapsulatedInterface  = 0x2b
+
+	modbusMBAPSize   = 7
+	modbusException  = 0x80
+	modbusMaxADUSize = 260
+)
+
+var modbusFunctions = map[byte]string{
+	ModbusReadCoils:              "read coils",
+	ModbusReadDiscreteInputs:     "read discrete inputs",
+	ModbusReadHoldingRegisters:   "read holding registers",
+	ModbusReadInputRegisters:     "read input registers",
+	ModbusWriteSingleCoil:        "write single coil",
+	ModbusWriteSingleRegister:    "write single register",
+	ModbusReadExceptionStatus:    "read exception status",
+	ModbusDiagnostics:            "diagnostics",
+	ModbusWriteMultipleCoils:     "write multiple coils",
+	ModbusWriteMultipleRegisters: "write multiple registers",
+	ModbusReportServerID:         "report server id",
+	ModbusMaskWriteRegister:      "mask write register",
+	ModbusReadWriteRegisters:     "read/write multiple registers",
+	ModbusEncapsulatedInterface:  "read device identification",
+}
+
+var modbusExceptions = map[byte]string{
+	0x01: "illegal function",
+	0x02: "illegal data address",
+	0x03: "illegal data value",
+	0x04: "server device failure",
+	0x05: "acknowledge",
+	0x06: "server device busy",
+	0x08: "memory parity error",
+	0x0a: "gateway path unavailable",
+	0x0b: "gateway target device failed to respond",
+}
+
+// ModbusFrame is a Modbus TCP application data unit.
+type ModbusFrame struct {
+	TransactionID uint16
+	UnitID        byte
+	Function      byte
+	Data          []byte
+}
+
+// ParseModbus returns the Modbus TCP frames in data.
+func ParseModbus(data []byte) ([]ModbusFrame, error) {
+	frames := make([]ModbusFrame, 0)
+	for len(data) > 0 {
+		if len(data) < modbusMBAPSize+1 {
+			return nil, fmt.Errorf("modbus frame too short")
+		} else if protocol := binary.BigEndian.Uint16(data[2:]); protocol != 0 {
+			return nil, fmt.Errorf("unexpected modbus protocol %d", protocol)
+		}
+
+		// the length includes the unit identifier
+		size := int(binary.BigEndian.Uint16(data[4:]))
+		if size < 2 || modbusMBAPSize-1+size > modbusMaxADUSize || modbusMBAPSize-1+size > len(data) {
+			return nil, fmt.Errorf("invalid modbus length %d", size)
+		}
+
+		frames = append(frames, ModbusFrame{
+			TransactionID: binary.BigEndian.Uint16(data[0:]),
+			UnitID:        data[6],
+			Function:      data[7],
+			Data:          data[8 : modbusMBAPSize-1+size],
+		})
+		data = data[modbusMBAPSize-1+size:]
+	}
+	return frames, nil
+}
+
+func (f ModbusFrame) IsException() bool {
+	return f.Function&modbusException != 0
+}
+
+func (f ModbusFrame) FunctionName() string {
+	if name, found := modbusFunctions[f.Function&^modbusException]; found {
+		return name
+	}
+	return fmt.Sprintf("function 0x%02x", f.Function&^modbusException)
+}
+
+// Describe returns the details of a request or response, like the range of
+// registers being read or written and their values.
+func (f ModbusFrame) Describe(request bool) string {
+	if f.IsException() {
+		if len(f.Data) == 0 {
+			return "exception"
+		} else if name, found := modbusExceptions[f.Data[0]]; found {
+			return fmt.Sprintf("exception: %s", name)
+		}
+		return fmt.Sprintf("exception 0x%02x", f.Data[0])
+	}
+
+	word := func(offset int) int {
+		return int(binary.BigEndian.Uint16(f.Data[offset:]))
+	}
+
+	switch f.Function {
+	case ModbusReadCoils, ModbusReadDiscreteInputs, ModbusReadHoldingRegisters, ModbusReadInputRegisters:
+		if request && len(f.Data) >= 4 {
+			return fmt.Sprintf("address %d, count %d", word(0), word(2))
+		} else if !request && len(f.Data) >= 1 {
+			values := f.Data[1:]
+			if f.Function == ModbusReadHoldingRegisters || f.Function == ModbusReadInputRegisters {
+				regs := make([]uint16, len(values)/2)
+				for i := range regs {
+					regs[i] = binary.BigEndian.Uint16(values[2*i:])
+				}
+				return fmt.Sprintf("values %v", regs)
+			}
+			return fmt.Sprintf("values %x", values)
+		}
+
+	case ModbusWriteSingleCoil:
+		if len(f.Data) >= 4 {
+			return fmt.Sprintf("address %d, value %v", word(0), word(2) == 0xff00)
+		}
+
+	case ModbusWriteSingleRegister:
+		if len(f.Data) >= 4 {
+			return fmt.Sprintf("address %d, value %d", word(0), word(2))
+		}
+
+	case ModbusWriteMultipleCoils, ModbusWriteMultipleRegisters:
+		if len(f.Data) >= 4 {
+			return fmt.Sprintf("address %d, count %d", word(0), word(2))
+		}
+
+	case ModbusMaskWriteRegister:
+		if len(f.Data) >= 6 {
+			return fmt.Sprintf("address %d, and 0x%04x, or 0x%04x", word(0), word(2), word(4))
+		}
+
+	case ModbusReadWriteRegisters:
+		if request && len(f.Data) >= 8 {
+			return fmt.Sprintf("read address %d, count %d, write address %d, count %d", word(0), word(2), word(4), word(6))
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,45 @@
+package packets
+
+import (
+	"testing"
+)
+
+func TestParseModbus(t *testing.T) {
+	// read holding registers request followed by an exception response
+	data := []byte{
+		0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x11, 0x03, 0x00, 0x6b, 0x00, 0x03,
+		0x00, 0x02, 0x00, 0x00, 0x00, 0x03, 0x11, 0x83, 0x02,
+	}
+	frames, err := ParseModbus(data)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(frames))
+	}
+
+	var units = []struct {
+		frame   ModbusFrame
+		request bool
+		name    string
+		desc    string
+	}{
+		{frames[0], true, "read holding registers", "address 107, count 3"},
+		{frames[1], false, "read holding registers", "exception: illegal data address"},
+		{ModbusFrame{Function: ModbusReadHoldingRegisters, Data: []byte{4, 0x02, 0x2b, 0x00, 0x00}}, false, "read holding registers", "values [555 0]"},
+		{ModbusFrame{Function: ModbusWriteSingleCoil, Data: []byte{0x00, 0xac, 0xff, 0x00}}, true, "write single coil", "address 172, value true"},
+		{ModbusFrame{Function: 0x64}, true, "function 0x64", ""},
+	}
+	for _, u := range units {
+		if name := u.frame.FunctionName(); name != u.name {
+			t.Fatalf("expected '%s', got '%s'", u.name, name)
+		} else if desc := u.frame.Describe(u.request); desc != u.desc {
+			t.Fatalf("expected '%s', got '%s'", u.desc, desc)
+		}
+	}
+
+	if _, err = ParseModbus([]byte("GET / HTTP/1.1\r\n")); err == nil {
+		t.Fatal("expected error for http")
+	} else if _, err = ParseModbus(data[:10]); err == nil {
+		t.Fatal("expected error for a truncated frame")
+	}
+}
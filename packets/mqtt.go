@@ -0,0 +1,446 @@
+package packets
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	MQTTPort    = 1883
+	MQTTTLSPort = 8883
+
+	MQTTConnect     = 0x01
+	MQTTConnAck     = 0x02
+	MQTTPublish     = 0x03
+	MQTTSubscribe   = 0x08
+	MQTTPingReq     = 0x0c
+	MQTTDisconnect  = 0x0e
+	MQTTProtocol311 = 0x04
+
+	MQTTConnAccepted         = 0x00
+	MQTTConnBadProtocol      = 0x01
+	MQTTConnBadClientID      = 0x02
+	MQTTConnUnavailable      = 0x03
+	MQTTConnBadCredentials   = 0x04
+	MQTTConnNotAuthorized    = 0x05
+	mqttMaxRemainingLenBytes = 4
+)
+
+var ErrMQTTShort = errors.New("mqtt packet too short")
+
+// MQTTConnAckMessage returns a description of a CONNACK return code.
+func MQTTConnAckMessage(code byte) string {
+	switch code {
+	case MQTTConnAccepted:
+		return "accepted"
+	case MQTTConnBadProtocol:
+		return "unacceptable protocol version"
+	case MQTTConnBadClientID:
+		return "identifier rejected"
+	case MQTTConnUnavailable:
+		return "server unavailable"
+	case MQTTConnBadCredentials:
+		return "bad user name or password"
+	case MQTTConnNotAuthorized:
+		return "not authorized"
+	}
+	return fmt.Sprintf("unknown return code %d", code)
+}
+
+// MQTTEncodeLength encodes the remaining length field of the fixed header.
+func MQTTEncodeLength(size int) []byte {
+	encoded := make([]byte, 0, mqttMaxRemainingLenBytes)
+	for {
+		digit := byte(size % 128)
+		size /= 128
+		if size > 0 {
+			digit |= 0x80
+		}
+		encoded = append(encoded, digit)
+		if size == 0 {
+			return encoded
+		}
+	}
+}
+
+// MQTTDecodeLength decodes the remaining length field at the beginning
+// of raw, returning its value and how many bytes it takes.
+func MQTTDecodeLength(raw []byte) (size int, used int, err error) {
+	multiplier := 1
+	for used < len(raw) && used < mqttMaxRemainingLenBytes {
+		digit := raw[used]
+		used++
+		size += int(digit&0x7f) * multiplier
+		if digit&0x80 == 0 {
+			return size, used, nil
+		}
+		multiplier *= 128
+	}
+	return 0, 0, ErrMQTTShort
+}
+
+func mqttString(s string) []byte {
+	raw := make([]byte, 2+len(s))
+	binary.BigEndian.PutUint16(raw, uint16(len(s)))
+	copy(raw[2:], s)
+	return raw
+}
+
+// NewMQTTConnect returns an MQTT 3.1.1 CONNECT packet with a clean session,
+// if user is empty no credentials are sent.
+func NewMQTTConnect(clientID, user, pass string) []byte {
+	flags := byte(0x02)
+	payload := mqttString(clientID)
+	if user != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(user)...)
+		if pass != "" {
+			flags |= 0x40
+			payload = append(payload, mqttString(pass)...)
+		}
+	}
+
+	variable := append(mqttString("MQTT"), MQTTProtocol311, flags, 0x00, 0x3c)
+	body := append(variable, payload...)
+
+	raw := []byte{MQTTConnect << 4}
+	raw = append(raw, MQTTEncodeLength(len(body))...)
+	return append(raw, body...)
+}
+
+// NewMQTTDisconnect returns a DISCONNECT packet.
+func NewMQTTDisconnect() []byte {
+	return []byte{MQTTDisconnect << 4, 0x00}
+}
+
+// NewMQTTPingReq returns a PINGREQ packet, clients send it to keep the
+// connection alive when they have nothing else to send.
+func NewMQTTPingReq() []byte {
+	return []byte{MQTTPingReq << 4, 0x00}
+}
+
+// NewMQTTPublish returns an MQTT 3.1.1 QoS 0 PUBLISH packet.
+func NewMQTTPublish(topic string, payload []byte, retain bool) []byte {
+	p := &MQTTPublishPacket{
+		Topic:   topic,
+		Payload: payload,
+	}
+	if retain {
+		p.Flags |= 0x01
+	}
+	return p.Serialize(MQTTProtocol311)
+}
+
+// ParseMQTTConnAck returns the return code of a CONNACK packet.
+func ParseMQTTConnAck(raw []byte) (byte, error) {
+	if len(raw) < 4 {
+		return 0, ErrMQTTShort
+	} else if raw[0]>>4 != MQTTConnAck || raw[1] != 2 {
+		return 0, fmt.Errorf("not an mqtt connack packet")
+	}
+	return raw[3], nil
+}
+
+const (
+	MQTTProtocol5 = 0x05
+
+	mqttFlagUsername = 0x80
+	mqttFlagPassword = 0x40
+	mqttFlagWill     = 0x04
+)
+
+// MQTTPacketSize returns the size of the whole packet at the beginning of
+// raw, fixed header included, or ErrMQTTShort if its header is incomplete.
+func MQTTPacketSize(raw []byte) (int, error) {
+	if len(raw) < 2 {
+		return 0, ErrMQTTShort
+	}
+
+	size, used, err := MQTTDecodeLength(raw[1:])
+	if err != nil {
+		if len(raw) > mqttMaxRemainingLenBytes {
+			return 0, fmt.Errorf("invalid mqtt remaining length")
+		}
+		return 0, err
+	}
+	return 1 + used + size, nil
+}
+
+// mqttReader reads the fields of the variable header and payload.
+type mqttReader struct {
+	raw []byte
+	err error
+}
+
+func (r *mqttReader) readBytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	} else if len(r.raw) < n {
+		r.err = ErrMQTTShort
+		return nil
+	}
+	b := r.raw[:n]
+	r.raw = r.raw[n:]
+	return b
+}
+
+func (r *mqttReader) readByte() byte {
+	if b := r.readBytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *mqttReader) readUint16() uint16 {
+	if b := r.readBytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *mqttReader) readBinary() []byte {
+	size := r.readUint16()
+	return r.readBytes(int(size))
+}
+
+func (r *mqttReader) readString() string {
+	return string(r.readBinary())
+}
+
+// readProperties returns the MQTT 5 properties, length included, as they are.
+func (r *mqttReader) readProperties() []byte {
+	if r.err != nil {
+		return nil
+	}
+	size, used, err := MQTTDecodeLength(r.raw)
+	if err != nil {
+		r.err = err
+		return nil
+	}
+	return r.readBytes(used + size)
+}
+
+// mqttBody returns the body of raw if it's a packet of the given type.
+func mqttBody(raw []byte, packetType byte) ([]byte, error) {
+	if len(raw) < 2 {
+		return nil, ErrMQTTShort
+	} else if raw[0]>>4 != packetType {
+		return nil, fmt.Errorf("unexpected mqtt packet type %d", raw[0]>>4)
+	}
+
+	size, used, err := MQTTDecodeLength(raw[1:])
+	if err != nil {
+		return nil, err
+	} else if len(raw) < 1+used+size {
+		return nil, ErrMQTTShort
+	}
+	return raw[1+used : 1+used+size], nil
+}
+
+func mqttPacket(header byte, body []byte) []byte {
+	raw := []byte{header}
+	raw = append(raw, MQTTEncodeLength(len(body))...)
+	return append(raw, body...)
+}
+
+func mqttBinary(b []byte) []byte {
+	raw := make([]byte, 2+len(b))
+	binary.BigEndian.PutUint16(raw, uint16(len(b)))
+	copy(raw[2:], b)
+	return raw
+}
+
+// MQTTConnectPacket is a parsed CONNECT packet, the MQTT 5 properties are
+// kept as they are so that it can be serialized again after changing the
+// client identifier or the credentials.
+type MQTTConnectPacket struct {
+	Protocol       string
+	Level          byte
+	Flags          byte
+	KeepAlive      uint16
+	Properties     []byte
+	ClientID       string
+	WillProperties []byte
+	WillTopic      string
+	WillMessage    []byte
+	Username       string
+	Password       string
+}
+
+func (c *MQTTConnectPacket) HasUsername() bool {
+	return c.Flags&mqttFlagUsername != 0
+}
+
+func (c *MQTTConnectPacket) HasPassword() bool {
+	return c.Flags&mqttFlagPassword != 0
+}
+
+// ParseMQTTConnect parses a CONNECT packet of any protocol version.
+func ParseMQTTConnect(raw []byte) (*MQTTConnectPacket, error) {
+	body, err := mqttBody(raw, MQTTConnect)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &mqttReader{raw: body}
+	c := &MQTTConnectPacket{
+		Protocol:  r.readString(),
+		Level:     r.readByte(),
+		Flags:     r.readByte(),
+		KeepAlive: r.readUint16(),
+	}
+	if c.Level == MQTTProtocol5 {
+		c.Properties = r.readProperties()
+	}
+
+	c.ClientID = r.readString()
+	if c.Flags&mqttFlagWill != 0 {
+		if c.Level == MQTTProtocol5 {
+			c.WillProperties = r.readProperties()
+		}
+		c.WillTopic = r.readString()
+		c.WillMessage = r.readBinary()
+	}
+	if c.HasUsername() {
+		c.Username = r.readString()
+	}
+	if c.HasPassword() {
+		// MQTT 5 passwords are binary data, but they're text most of the times
+		c.Password = r.readString()
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return c, nil
+}
+
+// Serialize returns the packet, setting the flags of the credentials
+// according to which ones are not empty.
+func (c *MQTTConnectPacket) Serialize() []byte {
+	flags := c.Flags &^ (mqttFlagUsername | mqttFlagPassword)
+	if c.Username != "" {
+		flags |= mqttFlagUsername
+	}
+	if c.Password != "" {
+		flags |= mqttFlagPassword
+	}
+
+	body := append(mqttString(c.Protocol), c.Level, flags, byte(c.KeepAlive>>8), byte(c.KeepAlive))
+	if c.Level == MQTTProtocol5 {
+		body = append(body, mqttProperties(c.Properties)...)
+	}
+
+	body = append(body, mqttString(c.ClientID)...)
+	if flags&mqttFlagWill != 0 {
+		if c.Level == MQTTProtocol5 {
+			body = append(body, mqttProperties(c.WillProperties)...)
+		}
+		body = append(body, mqttString(c.WillTopic)...)
+		body = append(body, mqttBinary(c.WillMessage)...)
+	}
+	if flags&mqttFlagUsername != 0 {
+		body = append(body, mqttString(c.Username)...)
+	}
+	if flags&mqttFlagPassword != 0 {
+		body = append(body, mqttString(c.Password)...)
+	}
+
+	return mqttPacket(MQTTConnect<<4, body)
+}
+
+// mqttProperties returns the properties to serialize, an empty list if
+// there are none.
+func mqttProperties(properties []byte) []byte {
+	if len(properties) == 0 {
+		return []byte{0x00}
+	}
+	return properties
+}
+
+// MQTTPublishPacket is a parsed PUBLISH packet.
+type MQTTPublishPacket struct {
+	Flags      byte
+	Topic      string
+	PacketID   uint16
+	Properties []byte
+	Payload    []byte
+}
+
+func (p *MQTTPublishPacket) QoS() byte {
+	return (p.Flags >> 1) & 0x03
+}
+
+func (p *MQTTPublishPacket) Retain() bool {
+	return p.Flags&0x01 != 0
+}
+
+// ParseMQTTPublish parses a PUBLISH packet, level is the protocol version
+// negotiated by the CONNECT packet.
+func ParseMQTTPublish(raw []byte, level byte) (*MQTTPublishPacket, error) {
+	body, err := mqttBody(raw, MQTTPublish)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &mqttReader{raw: body}
+	p := &MQTTPublishPacket{
+		Flags: raw[0] & 0x0f,
+		Topic: r.readString(),
+	}
+	if p.QoS() > 0 {
+		p.PacketID = r.readUint16()
+	}
+	if level == MQTTProtocol5 {
+		p.Properties = r.readProperties()
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	p.Payload = r.raw
+	return p, nil
+}
+
+func (p *MQTTPublishPacket) Serialize(level byte) []byte {
+	body := mqttString(p.Topic)
+	if p.QoS() > 0 {
+		body = append(body, byte(p.PacketID>>8), byte(p.PacketID))
+	}
+	if level == MQTTProtocol5 {
+		body = append(body, mqttProperties(p.Properties)...)
+	}
+	body = append(body, p.Payload...)
+
+	return mqttPacket(MQTTPublish<<4|p.Flags&0x0f, body)
+}
+
+// ParseMQTTSubscribe returns the topic filters of a SUBSCRIBE packet.
+func ParseMQTTSubscribe(raw []byte, level byte) ([]string, error) {
+	body, err := mqttBody(raw, MQTTSubscribe)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &mqttReader{raw: body}
+	r.readUint16()
+	if level == MQTTProtocol5 {
+		r.readProperties()
+	}
+
+	topics := make([]string, 0)
+	for r.err == nil && len(r.raw) > 0 {
+		topic := r.readString()
+		// subscription options
+		r.readByte()
+		if r.err == nil {
+			topics = append(topics, topic)
+		}
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return topics, nil
+}
@@ -0,0 +1,216 @@
+package packets
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMQTTLength(t *testing.T) {
+	var units = []struct {
+		size    int
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{16383, []byte{0xff, 0x7f}},
+		{2097152, []byte{0x80, 0x80, 0x80, 0x01}},
+	}
+
+	for _, u := range units {
+		if got := MQTTEncodeLength(u.size); !bytes.Equal(got, u.encoded) {
+			t.Fatalf("expected '%x' for %d, got '%x'", u.encoded, u.size, got)
+		}
+
+		size, used, err := MQTTDecodeLength(u.encoded)
+		if err != nil {
+			t.Fatal(err)
+		} else if size != u.size || used != len(u.encoded) {
+			t.Fatalf("expected %d (%d bytes), got %d (%d bytes)", u.size, len(u.encoded), size, used)
+		}
+	}
+
+	if _, _, err := MQTTDecodeLength([]byte{0x80, 0x80}); err == nil {
+		t.Fatal("expected error for truncated length")
+	}
+}
+
+func TestNewMQTTConnect(t *testing.T) {
+	exp := []byte{
+		0x10, 0x0e,
+		0x00, 0x04, 'M', 'Q', 'T', 'T',
+		0x04, 0x02, 0x00, 0x3c,
+		0x00, 0x02, 'b', 'c',
+	}
+
+	if got := NewMQTTConnect("bc", "", ""); !bytes.Equal(got, exp) {
+		t.Fatalf("expected '%x', got '%x'", exp, got)
+	}
+
+	withCreds := NewMQTTConnect("bc", "u", "p")
+	if withCreds[9] != 0xc2 {
+		t.Fatalf("unexpected connect flags %02x", withCreds[9])
+	} else if !bytes.HasSuffix(withCreds, []byte{0x00, 0x01, 'u', 0x00, 0x01, 'p'}) {
+		t.Fatalf("credentials not found in '%x'", withCreds)
+	}
+}
+
+func TestNewMQTTPublish(t *testing.T) {
+	raw := NewMQTTPublish("a/b", []byte("hi"), true)
+	exp := []byte{0x31, 0x07, 0x00, 0x03, 'a', '/', 'b', 'h', 'i'}
+	if !bytes.Equal(raw, exp) {
+		t.Fatalf("expected '%x', got '%x'", exp, raw)
+	}
+
+	p, err := ParseMQTTPublish(NewMQTTPublish("a/b", []byte("hi"), false), MQTTProtocol311)
+	if err != nil {
+		t.Fatal(err)
+	} else if p.Topic != "a/b" || string(p.Payload) != "hi" || p.Retain() || p.QoS() != 0 {
+		t.Fatalf("unexpected packet %+v", p)
+	}
+
+	if ping := NewMQTTPingReq(); !bytes.Equal(ping, []byte{0xc0, 0x00}) {
+		t.Fatalf("unexpected pingreq '%x'", ping)
+	}
+}
+
+func TestParseMQTTConnAck(t *testing.T) {
+	if code, err := ParseMQTTConnAck([]byte{0x20, 0x02, 0x00, MQTTConnNotAuthorized}); err != nil {
+		t.Fatal(err)
+	} else if code != MQTTConnNotAuthorized {
+		t.Fatalf("unexpected return code %d", code)
+	} else if MQTTConnAckMessage(code) != "not authorized" {
+		t.Fatalf("unexpected message '%s'", MQTTConnAckMessage(code))
+	}
+
+	if _, err := ParseMQTTConnAck([]byte{0x30, 0x02, 0x00, 0x00}); err == nil {
+		t.Fatal("expected error for non connack packet")
+	}
+}
+
+func TestMQTTPacketSize(t *testing.T) {
+	if size, err := MQTTPacketSize([]byte{0x30, 0x80, 0x01, 0x00}); err != nil {
+		t.Fatal(err)
+	} else if size != 131 {
+		t.Fatalf("expected 131, got %d", size)
+	}
+
+	if _, err := MQTTPacketSize([]byte{0x30}); err != ErrMQTTShort {
+		t.Fatalf("expected ErrMQTTShort, got %v", err)
+	} else if _, err = MQTTPacketSize([]byte{0x30, 0x80, 0x80}); err != ErrMQTTShort {
+		t.Fatalf("expected ErrMQTTShort, got %v", err)
+	} else if _, err = MQTTPacketSize([]byte{0x30, 0x80, 0x80, 0x80, 0x80}); err == nil || err == ErrMQTTShort {
+		t.Fatalf("expected invalid length error, got %v", err)
+	}
+}
+
+func TestParseMQTTConnect(t *testing.T) {
+	raw := NewMQTTConnect("bc", "user", "pass")
+	c, err := ParseMQTTConnect(raw)
+	if err != nil {
+		t.Fatal(err)
+	} else if c.Protocol != "MQTT" || c.Level != MQTTProtocol311 || c.KeepAlive != 60 {
+		t.Fatalf("unexpected header %+v", c)
+	} else if c.ClientID != "bc" || c.Username != "user" || c.Password != "pass" {
+		t.Fatalf("unexpected payload %+v", c)
+	} else if got := c.Serialize(); !bytes.Equal(got, raw) {
+		t.Fatalf("expected '%x', got '%x'", raw, got)
+	}
+
+	c.Password = ""
+	c.Username = "admin"
+	if got := c.Serialize(); !bytes.Equal(got, NewMQTTConnect("bc", "admin", "")) {
+		t.Fatalf("unexpected serialization '%x'", got)
+	}
+
+	if _, err = ParseMQTTConnect(raw[:len(raw)-2]); err == nil {
+		t.Fatal("expected error for truncated packet")
+	} else if _, err = ParseMQTTConnect(NewMQTTDisconnect()); err == nil {
+		t.Fatal("expected error for non connect packet")
+	}
+}
+
+func TestParseMQTT5Connect(t *testing.T) {
+	body := []byte{
+		0x00, 0x04, 'M', 'Q', 'T', 'T',
+		MQTTProtocol5, 0xc6, 0x00, 0x1e,
+		// session expiry interval
+		0x05, 0x11, 0x00, 0x00, 0x00, 0x0a,
+		0x00, 0x01, 'c',
+		// will properties, topic and message
+		0x00,
+		0x00, 0x01, 'w',
+		0x00, 0x02, 0xde, 0xad,
+		0x00, 0x01, 'u',
+		0x00, 0x01, 'p',
+	}
+	raw := append([]byte{MQTTConnect << 4, byte(len(body))}, body...)
+
+	c, err := ParseMQTTConnect(raw)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(c.Properties, []byte{0x05, 0x11, 0x00, 0x00, 0x00, 0x0a}) {
+		t.Fatalf("unexpected properties '%x'", c.Properties)
+	} else if c.ClientID != "c" || c.WillTopic != "w" || !bytes.Equal(c.WillMessage, []byte{0xde, 0xad}) {
+		t.Fatalf("unexpected payload %+v", c)
+	} else if c.Username != "u" || c.Password != "p" {
+		t.Fatalf("unexpected credentials %+v", c)
+	} else if got := c.Serialize(); !bytes.Equal(got, raw) {
+		t.Fatalf("expected '%x', got '%x'", raw, got)
+	}
+}
+
+func TestParseMQTTPublish(t *testing.T) {
+	raw := []byte{
+		MQTTPublish<<4 | 0x03, 0x0b,
+		0x00, 0x03, 'a', '/', 'b',
+		0x00, 0x2a,
+		'o', 'n', '!', '!',
+	}
+
+	p, err := ParseMQTTPublish(raw, MQTTProtocol311)
+	if err != nil {
+		t.Fatal(err)
+	} else if p.Topic != "a/b" || p.QoS() != 1 || !p.Retain() || p.PacketID != 42 || string(p.Payload) != "on!!" {
+		t.Fatalf("unexpected packet %+v", p)
+	} else if got := p.Serialize(MQTTProtocol311); !bytes.Equal(got, raw) {
+		t.Fatalf("expected '%x', got '%x'", raw, got)
+	}
+
+	p.Payload = []byte("off")
+	if again, err := ParseMQTTPublish(p.Serialize(MQTTProtocol311), MQTTProtocol311); err != nil {
+		t.Fatal(err)
+	} else if string(again.Payload) != "off" || again.PacketID != 42 {
+		t.Fatalf("unexpected packet %+v", again)
+	}
+
+	// qos 0, no packet id, empty properties
+	raw5 := []byte{MQTTPublish << 4, 0x06, 0x00, 0x01, 't', 0x00, 'h', 'i'}
+	if p, err = ParseMQTTPublish(raw5, MQTTProtocol5); err != nil {
+		t.Fatal(err)
+	} else if p.Topic != "t" || string(p.Payload) != "hi" {
+		t.Fatalf("unexpected packet %+v", p)
+	} else if got := p.Serialize(MQTTProtocol5); !bytes.Equal(got, raw5) {
+		t.Fatalf("expected '%x', got '%x'", raw5, got)
+	}
+}
+
+func TestParseMQTTSubscribe(t *testing.T) {
+	raw := []byte{
+		MQTTSubscribe<<4 | 0x02, 0x0e,
+		0x00, 0x01,
+		0x00, 0x03, 'a', '/', '#', 0x00,
+		0x00, 0x03, 'b', '/', '+', 0x01,
+	}
+
+	if topics, err := ParseMQTTSubscribe(raw, MQTTProtocol311); err != nil {
+		t.Fatal(err)
+	} else if len(topics) != 2 || topics[0] != "a/#" || topics[1] != "b/+" {
+		t.Fatalf("unexpected topics %v", topics)
+	}
+
+	raw[1]--
+	if _, err := ParseMQTTSubscribe(raw[:len(raw)-1], MQTTProtocol311); err == nil {
+		t.Fatal("expected error for truncated packet")
+	}
+}
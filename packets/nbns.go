@@ -1,6 +1,8 @@
 package packets
 
 import (
+	"encoding/binary"
+	"net"
 	"strconv"
 
 	"github.com/evilsocket/islazy/str"
@@ -10,8 +12,16 @@ import (
 )
 
 const (
-	NBNSPort        = 137
-	NBNSMinRespSize = 73
+	NBNSPort          = 137
+	NBNSMinRespSize   = 73
+	NBNSNamesOffset   = 57
+	NBNSNameEntrySize = 18
+	NBNSEncodedSize   = 32
+	NBNSTypeNB        = 0x0020
+	NBNSClassIN       = 0x0001
+
+	nbnsFlagResponse = 0x8000
+	nbnsOpCodeMask   = 0x7800
 )
 
 var (
@@ -29,13 +39,136 @@ var (
 func NBNSGetMeta(pkt gopacket.Packet) map[string]string {
 	if ludp := pkt.Layer(layers.LayerTypeUDP); ludp != nil {
 		if udp := ludp.(*layers.UDP); udp != nil && udp.SrcPort == NBNSPort && len(udp.Payload) >= NBNSMinRespSize {
-			hostname := str.Trim(string(udp.Payload[57:72]))
-			if strconv.IsPrint(rune(hostname[0])) {
-				return map[string]string{
-					"nbns:hostname": hostname,
-				}
+			return NBNSParseNodeStatus(udp.Payload)
+		}
+	}
+	return nil
+}
+
+// NBNSParseNodeStatus parses the names table of a node status response,
+// extracting the hostname, the workgroup and the domain of the node.
+func NBNSParseNodeStatus(payload []byte) map[string]string {
+	if len(payload) < NBNSMinRespSize {
+		return nil
+	}
+
+	meta := make(map[string]string)
+	numNames := int(payload[NBNSNamesOffset-1])
+	for i := 0; i < numNames; i++ {
+		off := NBNSNamesOffset + i*NBNSNameEntrySize
+		if off+NBNSNameEntrySize > len(payload) {
+			break
+		}
+
+		name := str.Trim(string(payload[off : off+15]))
+		suffix := payload[off+15]
+		isGroup := binary.BigEndian.Uint16(payload[off+16:off+18])&0x8000 != 0
+		if name == "" || !strconv.IsPrint(rune(name[0])) {
+			continue
+		}
+
+		switch {
+		case suffix == 0x00 && !isGroup:
+			meta["nbns:hostname"] = name
+		case suffix == 0x20 && !isGroup:
+			if _, found := meta["nbns:hostname"]; !found {
+				meta["nbns:hostname"] = name
 			}
+		case suffix == 0x00 && isGroup:
+			meta["nbns:workgroup"] = name
+		case suffix == 0x1c && isGroup:
+			meta["nbns:domain"] = name
 		}
 	}
+
+	if len(meta) > 0 {
+		return meta
+	}
 	return nil
 }
+
+// NBNSQuery is an NB name query request.
+type NBNSQuery struct {
+	ID     uint16
+	Name   string
+	Suffix byte
+	// first level encoded name, as sent by the client
+	RawName []byte
+}
+
+// NBNSDecodeName decodes a first level encoded NetBIOS name, returning the
+// name without padding and its suffix.
+func NBNSDecodeName(encoded []byte) (string, byte, bool) {
+	if len(encoded) != NBNSEncodedSize {
+		return "", 0, false
+	}
+
+	decoded := make([]byte, NBNSEncodedSize/2)
+	for i := range decoded {
+		hi, lo := encoded[i*2]-'A', encoded[i*2+1]-'A'
+		if hi > 0x0f || lo > 0x0f {
+			return "", 0, false
+		}
+		decoded[i] = hi<<4 | lo
+	}
+
+	return str.Trim(string(decoded[:15])), decoded[15], true
+}
+
+// NBNSParseNameQuery parses the payload of an NB name query request.
+func NBNSParseNameQuery(payload []byte) (*NBNSQuery, bool) {
+	// header, encoded name with its length and terminator, type and class
+	if len(payload) < 12+1+NBNSEncodedSize+1+4 {
+		return nil, false
+	}
+
+	flags := binary.BigEndian.Uint16(payload[2:])
+	if flags&nbnsFlagResponse != 0 || flags&nbnsOpCodeMask != 0 || binary.BigEndian.Uint16(payload[4:]) != 1 {
+		return nil, false
+	} else if payload[12] != NBNSEncodedSize || payload[13+NBNSEncodedSize] != 0x00 {
+		return nil, false
+	}
+
+	qType := binary.BigEndian.Uint16(payload[14+NBNSEncodedSize:])
+	qClass := binary.BigEndian.Uint16(payload[16+NBNSEncodedSize:])
+	if qType != NBNSTypeNB || qClass != NBNSClassIN {
+		return nil, false
+	}
+
+	raw := payload[13 : 13+NBNSEncodedSize]
+	name, suffix, ok := NBNSDecodeName(raw)
+	if !ok || name == "" {
+		return nil, false
+	}
+
+	return &NBNSQuery{
+		ID:      binary.BigEndian.Uint16(payload[0:]),
+		Name:    name,
+		Suffix:  suffix,
+		RawName: raw,
+	}, true
+}
+
+// NBNSNameQueryResponse returns the payload of a positive response to q,
+// mapping the name to address.
+func NBNSNameQueryResponse(q *NBNSQuery, address net.IP, ttl uint32) []byte {
+	resp := make([]byte, 12, 12+1+NBNSEncodedSize+1+16)
+	binary.BigEndian.PutUint16(resp[0:], q.ID)
+	// response, authoritative answer, recursion desired
+	binary.BigEndian.PutUint16(resp[2:], 0x8500)
+	binary.BigEndian.PutUint16(resp[6:], 1)
+
+	resp = append(resp, NBNSEncodedSize)
+	resp = append(resp, q.RawName...)
+	resp = append(resp, 0x00)
+
+	rr := make([]byte, 16)
+	binary.BigEndian.PutUint16(rr[0:], NBNSTypeNB)
+	binary.BigEndian.PutUint16(rr[2:], NBNSClassIN)
+	binary.BigEndian.PutUint32(rr[4:], ttl)
+	binary.BigEndian.PutUint16(rr[8:], 6)
+	// unique name of a B node, then the address
+	copy(rr[12:], address.To4())
+
+	return append(resp, rr...)
+}
@@ -0,0 +1,114 @@
+package packets
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func nbnsName(name string, suffix byte, flags uint16) []byte {
+	entry := make([]byte, NBNSNameEntrySize)
+	for i := 0; i < 15; i++ {
+		entry[i] = ' '
+	}
+	copy(entry, name)
+	entry[15] = suffix
+	entry[16] = byte(flags >> 8)
+	entry[17] = byte(flags)
+	return entry
+}
+
+func TestNBNSParseNodeStatus(t *testing.T) {
+	payload := make([]byte, NBNSNamesOffset)
+	payload[NBNSNamesOffset-1] = 4
+	payload = append(payload, nbnsName("DESKTOP-42", 0x00, 0x0400)...)
+	payload = append(payload, nbnsName("DESKTOP-42", 0x20, 0x0400)...)
+	payload = append(payload, nbnsName("CORP", 0x00, 0x8400)...)
+	payload = append(payload, nbnsName("CORP", 0x1c, 0x8400)...)
+	payload = append(payload, make([]byte, 6)...)
+
+	meta := NBNSParseNodeStatus(payload)
+	if meta == nil {
+		t.Fatal("expected meta, got nil")
+	}
+
+	var units = []struct {
+		key   string
+		value string
+	}{
+		{"nbns:hostname", "DESKTOP-42"},
+		{"nbns:workgroup", "CORP"},
+		{"nbns:domain", "CORP"},
+	}
+
+	for _, u := range units {
+		if got := meta[u.key]; got != u.value {
+			t.Fatalf("expected '%s' for %s, got '%s'", u.value, u.key, got)
+		}
+	}
+}
+
+func TestNBNSParseNodeStatusShort(t *testing.T) {
+	if meta := NBNSParseNodeStatus([]byte{0x00, 0x01}); meta != nil {
+		t.Fatalf("expected nil, got %v", meta)
+	}
+}
+
+func nbnsQuery(id uint16, name string, suffix byte) []byte {
+	padded := []byte("               ")
+	copy(padded, name)
+	padded = append(padded, suffix)
+
+	query := []byte{byte(id >> 8), byte(id), 0x01, 0x10, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, NBNSEncodedSize}
+	for _, b := range padded {
+		query = append(query, 'A'+b>>4, 'A'+b&0x0f)
+	}
+	return append(query, 0x00, 0x00, 0x20, 0x00, 0x01)
+}
+
+func TestNBNSParseNameQuery(t *testing.T) {
+	q, ok := NBNSParseNameQuery(nbnsQuery(0x1234, "WPAD", 0x00))
+	if !ok {
+		t.Fatal("expected query to be parsed")
+	} else if q.ID != 0x1234 || q.Name != "WPAD" || q.Suffix != 0x00 {
+		t.Fatalf("unexpected query %+v", q)
+	}
+
+	// node status requests are not name queries
+	if _, ok := NBNSParseNameQuery(NBNSRequest); ok {
+		t.Fatal("unexpected node status request parsed as a name query")
+	}
+
+	invalid := nbnsQuery(0x1234, "WPAD", 0x00)
+	invalid[13] = 'z'
+	if _, ok := NBNSParseNameQuery(invalid); ok {
+		t.Fatal("unexpected invalid name parsed")
+	}
+}
+
+func TestNBNSNameQueryResponse(t *testing.T) {
+	q, _ := NBNSParseNameQuery(nbnsQuery(0x4242, "FILESRV", 0x20))
+	resp := NBNSNameQueryResponse(q, net.ParseIP("192.168.1.5"), 30)
+
+	if len(resp) != 12+1+NBNSEncodedSize+1+16 {
+		t.Fatalf("unexpected response size %d", len(resp))
+	} else if binary.BigEndian.Uint16(resp[0:]) != 0x4242 {
+		t.Fatal("unexpected transaction id")
+	} else if binary.BigEndian.Uint16(resp[2:])&0x8000 == 0 {
+		t.Fatal("expected response flag")
+	} else if binary.BigEndian.Uint16(resp[6:]) != 1 {
+		t.Fatal("expected one answer")
+	}
+
+	name, suffix, ok := NBNSDecodeName(resp[13 : 13+NBNSEncodedSize])
+	if !ok || name != "FILESRV" || suffix != 0x20 {
+		t.Fatalf("unexpected name '%s' <%02x>", name, suffix)
+	}
+
+	rr := resp[14+NBNSEncodedSize:]
+	if ttl := binary.BigEndian.Uint32(rr[4:]); ttl != 30 {
+		t.Fatalf("unexpected ttl %d", ttl)
+	} else if ip := net.IP(rr[12:16]); !ip.Equal(net.ParseIP("192.168.1.5")) {
+		t.Fatalf("unexpected address %s", ip)
+	}
+}
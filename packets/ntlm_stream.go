@@ -0,0 +1,190 @@
+package packets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	NTLMNegotiate    = 1
+	NTLMChallengeMsg = 2
+	NTLMAuthenticate = 3
+
+	// anything bigger than this is not a real ntlmssp message
+	ntlmMaxMessageSize = 0xffff
+)
+
+// ntlm or negotiate tokens in WWW-Authenticate, Authorization and their
+// proxy counterparts, only matched once the header line is complete
+var (
+	ntlmHTTPToken   = regexp.MustCompile(`(?i)\b(?:NTLM|Negotiate) ([A-Za-z0-9+/]+=*)\r?\n`)
+	ntlmHTTPPartial = regexp.MustCompile(`(?i)\b(?:NTLM|Negotiate) [A-Za-z0-9+/]*=*\r?$`)
+)
+
+// NTLMMessage is an NTLMSSP message found in a stream.
+type NTLMMessage struct {
+	Type uint32
+	Raw  []byte
+}
+
+// Base64 returns the message encoded as it would be in an HTTP header.
+func (m NTLMMessage) Base64() string {
+	return base64.StdEncoding.EncodeToString(m.Raw)
+}
+
+func (m NTLMMessage) TypeName() string {
+	switch m.Type {
+	case NTLMNegotiate:
+		return "NEGOTIATE"
+	case NTLMChallengeMsg:
+		return "CHALLENGE"
+	case NTLMAuthenticate:
+		return "AUTHENTICATE"
+	}
+	return fmt.Sprintf("TYPE%d", m.Type)
+}
+
+// ntlmMessageSize returns the size of the NTLMSSP message at the beginning
+// of msg, which ends with the last of its payload fields. ErrSMBShort is
+// returned if msg does not contain the whole message yet.
+func ntlmMessageSize(msg []byte) (int, error) {
+	if len(msg) < 12 {
+		return 0, ErrSMBShort
+	}
+
+	var header int
+	var fields []int
+	switch msgType := binary.LittleEndian.Uint32(msg[8:]); msgType {
+	case NTLMNegotiate:
+		header, fields = NTLM_TYPE1_DATA_OFFSET, []int{NTLM_TYPE1_DOMAIN_OFFSET, NTLM_TYPE1_WORKSTN_OFFSET}
+	case NTLMChallengeMsg:
+		header, fields = NTLM_TYPE2_DATA_OFFSET, []int{NTLM_TYPE2_TARGET_OFFSET, NTLM_TYPE2_TARGETINFO_OFFSET}
+	case NTLMAuthenticate:
+		header, fields = NTLM_TYPE3_DATA_OFFSET, []int{
+			NTLM_TYPE3_LMRESP_OFFSET,
+			NTLM_TYPE3_NTRESP_OFFSET,
+			NTLM_TYPE3_DOMAIN_OFFSET,
+			NTLM_TYPE3_USER_OFFSET,
+			NTLM_TYPE3_WORKSTN_OFFSET,
+			NTLM_TYPE3_SESSIONKEY_OFFSET,
+		}
+	default:
+		return 0, fmt.Errorf("unknown ntlmssp message type %d", msgType)
+	}
+
+	if len(msg) < header {
+		return 0, ErrSMBShort
+	}
+
+	size := header
+	for _, offset := range fields {
+		fieldLen := int(binary.LittleEndian.Uint16(msg[offset:]))
+		fieldStart := int(binary.LittleEndian.Uint32(msg[offset+4:]))
+		if fieldLen == 0 {
+			continue
+		} else if end := fieldStart + fieldLen; fieldStart < 8 || end > ntlmMaxMessageSize {
+			return 0, fmt.Errorf("invalid ntlmssp field at offset %d", offset)
+		} else if end > size {
+			size = end
+		}
+	}
+
+	if size > len(msg) {
+		return 0, ErrSMBShort
+	}
+	return size, nil
+}
+
+// ExtractNTLMMessages returns the complete NTLMSSP messages found in a
+// chunk of stream, either raw as they are carried by SMB or base64 encoded
+// in HTTP authentication headers, and how many bytes of data have been
+// consumed. The remaining bytes might contain a partial message and should
+// be kept for the next call.
+func ExtractNTLMMessages(data []byte) ([]NTLMMessage, int) {
+	msgs := make([]NTLMMessage, 0)
+	consumed := 0
+
+	for _, match := range ntlmHTTPToken.FindAllSubmatchIndex(data, -1) {
+		consumed = match[1]
+		decoded, err := base64.StdEncoding.DecodeString(string(data[match[2]:match[3]]))
+		if err != nil {
+			continue
+		}
+		// kerberos tokens negotiated over spnego are not our business
+		if idx := bytes.Index(decoded, ntlmSignature); idx != -1 {
+			if size, err := ntlmMessageSize(decoded[idx:]); err == nil {
+				msgs = append(msgs, NTLMMessage{
+					Type: binary.LittleEndian.Uint32(decoded[idx+8:]),
+					Raw:  decoded[idx : idx+size],
+				})
+			}
+		}
+	}
+
+	for pos := consumed; pos < len(data); {
+		idx := bytes.Index(data[pos:], ntlmSignature)
+		if idx == -1 {
+			// keep enough bytes for a signature or a header token split
+			// across two chunks
+			if loc := ntlmHTTPPartial.FindIndex(data[consumed:]); loc != nil {
+				consumed += loc[0]
+			} else if tail := len(data) - len("Negotiate "); tail > consumed {
+				consumed = tail
+			}
+			break
+		}
+
+		start := pos + idx
+		size, err := ntlmMessageSize(data[start:])
+		if err == ErrSMBShort {
+			consumed = start
+			break
+		} else if err != nil {
+			pos = start + len(ntlmSignature)
+			consumed = pos
+			continue
+		}
+
+		raw := make([]byte, size)
+		copy(raw, data[start:])
+		msgs = append(msgs, NTLMMessage{
+			Type: binary.LittleEndian.Uint32(raw[8:]),
+			Raw:  raw,
+		})
+		pos = start + size
+		consumed = pos
+	}
+
+	return msgs, consumed
+}
+
+// NTLMHashcat returns the hashcat line (mode 5500 or 5600) of the
+// authentication answering challenge.
+func NTLMHashcat(challenge, authenticate NTLMMessage) (string, error) {
+	if challenge.Type != NTLMChallengeMsg || authenticate.Type != NTLMAuthenticate {
+		return "", fmt.Errorf("expected a CHALLENGE and AUTHENTICATE pair")
+	} else if len(challenge.Raw) < NTLM_TYPE2_CHALLENGE_OFFSET+8 {
+		return "", ErrSMBShort
+	} else if _, err := ntlmMessageSize(authenticate.Raw); err != nil {
+		return "", err
+	}
+
+	pair := NTLMChallengeResponse{
+		Challenge: challenge.Base64(),
+		Response:  authenticate.Base64(),
+	}
+	// anonymous authentications carry no hash
+	if hdr := pair.getResponseHeader(); hdr.NtLen != 24 && hdr.NtLen <= 16 {
+		return "", fmt.Errorf("no ntlm response")
+	}
+
+	parsed, err := pair.Parsed()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(parsed.LcString()), nil
+}
@@ -0,0 +1,115 @@
+package packets
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func buildNTLMAuthenticate(ntLen int) []byte {
+	domain := smbUTF16("CORP")
+	user := smbUTF16("bob")
+	nt := make([]byte, ntLen)
+	for i := range nt {
+		nt[i] = byte(i)
+	}
+
+	msg := make([]byte, NTLM_TYPE3_DATA_OFFSET)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], NTLMAuthenticate)
+
+	offset := NTLM_TYPE3_DATA_OFFSET
+	field := func(at int, data []byte) {
+		binary.LittleEndian.PutUint16(msg[at:], uint16(len(data)))
+		binary.LittleEndian.PutUint16(msg[at+2:], uint16(len(data)))
+		binary.LittleEndian.PutUint32(msg[at+4:], uint32(offset))
+		offset += len(data)
+	}
+	field(NTLM_TYPE3_DOMAIN_OFFSET, domain)
+	field(NTLM_TYPE3_USER_OFFSET, user)
+	field(NTLM_TYPE3_NTRESP_OFFSET, nt)
+
+	msg = append(msg, domain...)
+	msg = append(msg, user...)
+	return append(msg, nt...)
+}
+
+func TestExtractNTLMMessagesRaw(t *testing.T) {
+	challenge := buildNTLMChallenge()
+	stream := append([]byte("\x00\x00\x01\x00smb2 header"), challenge...)
+	stream = append(stream, []byte("trailer")...)
+
+	msgs, consumed := ExtractNTLMMessages(stream)
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(msgs))
+	} else if msgs[0].Type != NTLMChallengeMsg || msgs[0].TypeName() != "CHALLENGE" {
+		t.Fatalf("unexpected message type %d", msgs[0].Type)
+	} else if len(msgs[0].Raw) != len(challenge) {
+		t.Fatalf("expected %d bytes, got %d", len(challenge), len(msgs[0].Raw))
+	} else if exp := len(stream) - len("trailer"); consumed != exp {
+		t.Fatalf("expected %d consumed bytes, got %d", exp, consumed)
+	}
+}
+
+func TestExtractNTLMMessagesPartial(t *testing.T) {
+	challenge := buildNTLMChallenge()
+	stream := append([]byte("header"), challenge[:40]...)
+
+	msgs, consumed := ExtractNTLMMessages(stream)
+	if len(msgs) != 0 {
+		t.Fatalf("expected no messages, got %d", len(msgs))
+	} else if consumed != len("header") {
+		t.Fatalf("expected the partial message to be kept, consumed %d", consumed)
+	}
+
+	stream = append(stream[consumed:], challenge[40:]...)
+	if msgs, _ = ExtractNTLMMessages(stream); len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(msgs))
+	}
+}
+
+func TestExtractNTLMMessagesHTTP(t *testing.T) {
+	token := base64.StdEncoding.EncodeToString(NTLMNegotiateMessage())
+	request := "GET / HTTP/1.1\r\nHost: intranet\r\nAuthorization: NTLM " + token + "\r\n\r\n"
+
+	msgs, consumed := ExtractNTLMMessages([]byte(request))
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(msgs))
+	} else if msgs[0].Type != NTLMNegotiate {
+		t.Fatalf("unexpected message type %d", msgs[0].Type)
+	} else if msgs[0].Base64() != token {
+		t.Fatalf("unexpected token %s", msgs[0].Base64())
+	} else if consumed < strings.Index(request, token)+len(token) {
+		t.Fatalf("token not consumed")
+	}
+
+	// the header is not complete yet
+	partial := request[:strings.Index(request, token)+10]
+	if msgs, consumed = ExtractNTLMMessages([]byte(partial)); len(msgs) != 0 {
+		t.Fatalf("expected no messages, got %d", len(msgs))
+	} else if consumed > strings.Index(partial, "NTLM ") {
+		t.Fatalf("partial token consumed")
+	}
+}
+
+func TestNTLMHashcat(t *testing.T) {
+	challenge := NTLMMessage{Type: NTLMChallengeMsg, Raw: buildNTLMChallenge()}
+	binary.LittleEndian.PutUint64(challenge.Raw[NTLM_TYPE2_CHALLENGE_OFFSET:], 0x0807060504030201)
+
+	auth := NTLMMessage{Type: NTLMAuthenticate, Raw: buildNTLMAuthenticate(32)}
+	line, err := NTLMHashcat(challenge, auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "bob::CORP:0102030405060708:000102030405060708090a0b0c0d0e0f:101112131415161718191a1b1c1d1e1f"
+	if line != exp {
+		t.Fatalf("expected '%s', got '%s'", exp, line)
+	}
+
+	anonymous := NTLMMessage{Type: NTLMAuthenticate, Raw: buildNTLMAuthenticate(0)}
+	if _, err = NTLMHashcat(challenge, anonymous); err == nil {
+		t.Fatalf("expected error for anonymous authentication")
+	}
+}
@@ -0,0 +1,217 @@
+package packets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	NTLMNegotiateUnicode     = 0x00000001
+	NTLMRequestTarget        = 0x00000004
+	NTLMNegotiateNTLM        = 0x00000200
+	NTLMNegotiateAnonymous   = 0x00000800
+	NTLMNegotiateAlwaysSign  = 0x00008000
+	NTLMNegotiateExtended    = 0x00080000
+	NTLMNegotiateTargetInfo  = 0x00800000
+	NTLMNegotiateVersion     = 0x02000000
+	NTLMNegotiate128         = 0x20000000
+	NTLMNegotiate56          = 0x80000000
+	NTLMNegotiateClientFlags = NTLMNegotiateUnicode | NTLMRequestTarget | NTLMNegotiateNTLM |
+		NTLMNegotiateAlwaysSign | NTLMNegotiateExtended | NTLMNegotiateTargetInfo |
+		NTLMNegotiateVersion | NTLMNegotiate128 | NTLMNegotiate56
+
+	ntlmAvEOL              = 0x0000
+	ntlmAvNbComputerName   = 0x0001
+	ntlmAvNbDomainName     = 0x0002
+	ntlmAvDnsComputerName  = 0x0003
+	ntlmAvDnsDomainName    = 0x0004
+	ntlmAvDnsTreeName      = 0x0005
+	ntlmType2VersionOffset = 48
+	ntlmType3PayloadOffset = 64
+)
+
+var (
+	ntlmSignature = []byte("NTLMSSP\x00")
+
+	spnegoOID = []byte{0x06, 0x06, 0x2b, 0x06, 0x01, 0x05, 0x05, 0x02}
+	ntlmOID   = []byte{0x06, 0x0a, 0x2b, 0x06, 0x01, 0x04, 0x01, 0x82, 0x37, 0x02, 0x02, 0x0a}
+)
+
+// NTLMChallenge holds the information a server discloses in the
+// CHALLENGE message, before any authentication takes place.
+type NTLMChallenge struct {
+	Flags          uint32
+	TargetName     string
+	NbComputerName string
+	NbDomainName   string
+	DnsComputer    string
+	DnsDomain      string
+	DnsTree        string
+	Major          uint8
+	Minor          uint8
+	Build          uint16
+}
+
+// OS returns the operating system matching the version the server
+// reported, if it looks like a Windows one.
+func (c NTLMChallenge) OS() string {
+	// samba and other implementations don't report any build
+	if c.Flags&NTLMNegotiateVersion == 0 || c.Build == 0 {
+		return ""
+	}
+
+	name := fmt.Sprintf("Windows %d.%d", c.Major, c.Minor)
+	switch {
+	case c.Major == 5 && c.Minor == 0:
+		name = "Windows 2000"
+	case c.Major == 5 && c.Minor == 1:
+		name = "Windows XP"
+	case c.Major == 5 && c.Minor == 2:
+		name = "Windows Server 2003"
+	case c.Major == 6 && c.Minor == 0:
+		name = "Windows Vista / Server 2008"
+	case c.Major == 6 && c.Minor == 1:
+		name = "Windows 7 / Server 2008 R2"
+	case c.Major == 6 && c.Minor == 2:
+		name = "Windows 8 / Server 2012"
+	case c.Major == 6 && c.Minor == 3:
+		name = "Windows 8.1 / Server 2012 R2"
+	case c.Major == 10 && c.Build >= 22000:
+		name = "Windows 11 / Server 2022"
+	case c.Major == 10:
+		name = "Windows 10 / Server 2016"
+	}
+
+	return fmt.Sprintf("%s (build %d)", name, c.Build)
+}
+
+func asn1Wrap(tag byte, content []byte) []byte {
+	size := len(content)
+	wrapped := []byte{tag}
+	if size < 0x80 {
+		wrapped = append(wrapped, byte(size))
+	} else if size < 0x100 {
+		wrapped = append(wrapped, 0x81, byte(size))
+	} else {
+		wrapped = append(wrapped, 0x82, byte(size>>8), byte(size))
+	}
+	return append(wrapped, content...)
+}
+
+// SPNEGOInit wraps an NTLMSSP token in a SPNEGO NegTokenInit.
+func SPNEGOInit(token []byte) []byte {
+	mechTypes := asn1Wrap(0xa0, asn1Wrap(0x30, ntlmOID))
+	mechToken := asn1Wrap(0xa2, asn1Wrap(0x04, token))
+	negTokenInit := asn1Wrap(0xa0, asn1Wrap(0x30, append(mechTypes, mechToken...)))
+	return asn1Wrap(0x60, append(append([]byte{}, spnegoOID...), negTokenInit...))
+}
+
+// SPNEGOResponse wraps an NTLMSSP token in a SPNEGO NegTokenResp.
+func SPNEGOResponse(token []byte) []byte {
+	return asn1Wrap(0xa1, asn1Wrap(0x30, asn1Wrap(0xa2, asn1Wrap(0x04, token))))
+}
+
+// NTLMNegotiateMessage returns an NTLMSSP NEGOTIATE message.
+func NTLMNegotiateMessage() []byte {
+	raw := make([]byte, 32)
+	copy(raw, ntlmSignature)
+	binary.LittleEndian.PutUint32(raw[8:], 1)
+	binary.LittleEndian.PutUint32(raw[12:], NTLMNegotiateClientFlags)
+	return raw
+}
+
+// NTLMAnonymousMessage returns an NTLMSSP AUTHENTICATE message with
+// empty credentials, used to establish a null session.
+func NTLMAnonymousMessage() []byte {
+	raw := make([]byte, ntlmType3PayloadOffset+1)
+	copy(raw, ntlmSignature)
+	binary.LittleEndian.PutUint32(raw[8:], 3)
+	// the lm response is a single zero byte, every other field is empty
+	binary.LittleEndian.PutUint16(raw[12:], 1)
+	binary.LittleEndian.PutUint16(raw[14:], 1)
+	binary.LittleEndian.PutUint32(raw[16:], ntlmType3PayloadOffset)
+	for offset := 20; offset < 60; offset += 8 {
+		binary.LittleEndian.PutUint32(raw[offset+4:], ntlmType3PayloadOffset+1)
+	}
+	binary.LittleEndian.PutUint32(raw[60:], NTLMNegotiateClientFlags|NTLMNegotiateAnonymous)
+	return raw
+}
+
+func ntlmField(msg []byte, offset int) ([]byte, error) {
+	if offset+8 > len(msg) {
+		return nil, ErrSMBShort
+	}
+
+	size := int(binary.LittleEndian.Uint16(msg[offset:]))
+	start := int(binary.LittleEndian.Uint32(msg[offset+4:]))
+	if size == 0 {
+		return []byte{}, nil
+	} else if start+size > len(msg) {
+		return nil, ErrSMBShort
+	}
+	return msg[start : start+size], nil
+}
+
+// ParseNTLMChallenge looks for an NTLMSSP CHALLENGE message in blob,
+// which is usually wrapped in SPNEGO, and parses it.
+func ParseNTLMChallenge(blob []byte) (*NTLMChallenge, error) {
+	idx := bytes.Index(blob, ntlmSignature)
+	if idx == -1 {
+		return nil, fmt.Errorf("no ntlmssp message found")
+	}
+
+	msg := blob[idx:]
+	if len(msg) < ntlmType2VersionOffset {
+		return nil, ErrSMBShort
+	} else if msgType := binary.LittleEndian.Uint32(msg[8:]); msgType != 2 {
+		return nil, fmt.Errorf("unexpected ntlmssp message type %d", msgType)
+	}
+
+	c := &NTLMChallenge{
+		Flags: binary.LittleEndian.Uint32(msg[20:]),
+	}
+
+	if name, err := ntlmField(msg, 12); err != nil {
+		return nil, err
+	} else {
+		c.TargetName = smbString(name)
+	}
+
+	if c.Flags&NTLMNegotiateVersion != 0 && len(msg) >= ntlmType2VersionOffset+8 {
+		c.Major = msg[ntlmType2VersionOffset]
+		c.Minor = msg[ntlmType2VersionOffset+1]
+		c.Build = binary.LittleEndian.Uint16(msg[ntlmType2VersionOffset+2:])
+	}
+
+	info, err := ntlmField(msg, 40)
+	if err != nil {
+		return nil, err
+	}
+
+	for len(info) >= 4 {
+		avID := binary.LittleEndian.Uint16(info[0:])
+		avLen := int(binary.LittleEndian.Uint16(info[2:]))
+		if avID == ntlmAvEOL || 4+avLen > len(info) {
+			break
+		}
+
+		value := smbString(info[4 : 4+avLen])
+		switch avID {
+		case ntlmAvNbComputerName:
+			c.NbComputerName = value
+		case ntlmAvNbDomainName:
+			c.NbDomainName = value
+		case ntlmAvDnsComputerName:
+			c.DnsComputer = value
+		case ntlmAvDnsDomainName:
+			c.DnsDomain = value
+		case ntlmAvDnsTreeName:
+			c.DnsTree = value
+		}
+
+		info = info[4+avLen:]
+	}
+
+	return c, nil
+}
@@ -0,0 +1,233 @@
+package packets
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// a p0f style passive signature, options are encoded as a comma
+// separated list of M (mss), N (nop), W (window scale), S (sack ok),
+// T (timestamps) and E (end of options list).
+type osSignature struct {
+	TTL     uint8
+	Options string
+	Window  []uint16
+	OS      string
+}
+
+var osSignatures = []osSignature{
+	{128, "M,N,W,N,N,S", nil, "Windows"},
+	{128, "M,N,W,S,T", nil, "Windows"},
+	{128, "M,N,N,S", nil, "Windows XP"},
+	{64, "M,N,W,N,N,T,S,E", nil, "macOS/iOS"},
+	{64, "M,N,N,T,N,W,N,N,S", nil, "macOS/iOS"},
+	{64, "M,S,T,N,W", []uint16{5720, 14600, 26883, 28800, 29200, 42340, 43690, 64240, 65160, 65483, 65495}, "Linux"},
+	{64, "M,S,T,N,W", nil, "Linux/Android"},
+	{64, "M,N,W,S,T", []uint16{65535}, "FreeBSD"},
+	{64, "M,N,W,N,N,S", nil, "Linux (embedded)"},
+	{64, "M", nil, "Linux (embedded)"},
+	{255, "M", nil, "Network device"},
+	{255, "M,N,W,N,N,T,N,N,S", nil, "Solaris"},
+}
+
+var dhcpVendorClasses = []struct {
+	Prefix string
+	OS     string
+	Device string
+}{
+	{"MSFT", "Windows", DeviceComputer},
+	{"android-dhcp", "Android", DevicePhone},
+	{"dhcpcd", "Linux", ""},
+	{"udhcp", "Linux (embedded)", DeviceEmbedded},
+	{"Cisco", "Cisco IOS", DeviceEmbedded},
+	{"HUAWEI", "Huawei", ""},
+}
+
+// OSInitialTTL guesses the initial TTL of a packet given the observed one.
+func OSInitialTTL(ttl uint8) uint8 {
+	if ttl <= 32 {
+		return 32
+	} else if ttl <= 64 {
+		return 64
+	} else if ttl <= 128 {
+		return 128
+	}
+	return 255
+}
+
+// OSGuess returns the best operating system guess given the initial TTL,
+// the window size and the options layout of a TCP SYN packet.
+func OSGuess(ttl uint8, window uint16, options string) string {
+	ttl = OSInitialTTL(ttl)
+	// first look for a match with a specific window size, then with any
+	for _, withWindow := range []bool{true, false} {
+		for _, sig := range osSignatures {
+			if sig.TTL != ttl || sig.Options != options || (sig.Window != nil) != withWindow {
+				continue
+			} else if !withWindow {
+				return sig.OS
+			}
+
+			for _, w := range sig.Window {
+				if w == window {
+					return sig.OS
+				}
+			}
+		}
+	}
+
+	// fallback on the TTL only
+	switch ttl {
+	case 64:
+		return "Unix"
+	case 128:
+		return "Windows"
+	case 255:
+		return "Network device"
+	}
+	return ""
+}
+
+// OSGuessFromDHCPVendor maps a DHCP vendor class identifier to an operating system.
+func OSGuessFromDHCPVendor(vendor string) string {
+	os, _ := dhcpVendorGuess(vendor)
+	return os
+}
+
+func dhcpVendorGuess(vendor string) (os string, device string) {
+	for _, vc := range dhcpVendorClasses {
+		if strings.HasPrefix(vendor, vc.Prefix) {
+			return vc.OS, vc.Device
+		}
+	}
+	return "", ""
+}
+
+func tcpOptionsLayout(tcp *layers.TCP) string {
+	layout := make([]string, 0, len(tcp.Options))
+	for _, opt := range tcp.Options {
+		switch opt.OptionType {
+		case layers.TCPOptionKindMSS:
+			layout = append(layout, "M")
+		case layers.TCPOptionKindNop:
+			layout = append(layout, "N")
+		case layers.TCPOptionKindWindowScale:
+			layout = append(layout, "W")
+		case layers.TCPOptionKindSACKPermitted:
+			layout = append(layout, "S")
+		case layers.TCPOptionKindTimestamps:
+			layout = append(layout, "T")
+		case layers.TCPOptionKindEndList:
+			layout = append(layout, "E")
+		default:
+			layout = append(layout, fmt.Sprintf("?%d", opt.OptionType))
+		}
+	}
+	return strings.Join(layout, ",")
+}
+
+func osGetTCPMeta(pkt gopacket.Packet) map[string]string {
+	ltcp := pkt.Layer(layers.LayerTypeTCP)
+	if ltcp == nil {
+		return nil
+	}
+
+	// only connection attempts carry a meaningful signature
+	tcp := ltcp.(*layers.TCP)
+	if !tcp.SYN || tcp.ACK {
+		return nil
+	}
+
+	ttl := uint8(0)
+	if lip4 := pkt.Layer(layers.LayerTypeIPv4); lip4 != nil {
+		ttl = lip4.(*layers.IPv4).TTL
+	} else if lip6 := pkt.Layer(layers.LayerTypeIPv6); lip6 != nil {
+		ttl = lip6.(*layers.IPv6).HopLimit
+	} else {
+		return nil
+	}
+
+	options := tcpOptionsLayout(tcp)
+	meta := map[string]string{
+		"os:signature": fmt.Sprintf("%d:%d:%s", OSInitialTTL(ttl), tcp.Window, options),
+	}
+
+	if guess := OSGuess(ttl, tcp.Window, options); guess != "" {
+		meta["os:guess"] = guess
+	}
+
+	return meta
+}
+
+// DHCPGetMeta fingerprints the client of a DHCP discover or request from its
+// parameters request list and vendor class, returning its hardware address since the requests are usually sent
+// before the client has an address.
+func DHCPGetMeta(pkt gopacket.Packet) (net.HardwareAddr, map[string]string) {
+	ldhcp := pkt.Layer(layers.LayerTypeDHCPv4)
+	if ldhcp == nil {
+		return nil, nil
+	}
+
+	dhcp := ldhcp.(*layers.DHCPv4)
+	if dhcp.Operation != layers.DHCPOpRequest || len(dhcp.ClientHWAddr) != 6 {
+		return nil, nil
+	}
+
+	// only discover and request messages carry the full parameters list
+	if msgType := DHCPMessageType(dhcp); msgType != layers.DHCPMsgTypeDiscover && msgType != layers.DHCPMsgTypeRequest {
+		return nil, nil
+	}
+
+	meta := make(map[string]string)
+	os, device := "", ""
+	for _, opt := range dhcp.Options {
+		if len(opt.Data) == 0 {
+			continue
+		}
+
+		switch opt.Type {
+		case layers.DHCPOptParamsRequest:
+			fingerprint := DHCPFingerprint(opt.Data)
+			meta["dhcp:fingerprint"] = fingerprint
+			// the parameters list is more specific than the vendor class
+			if fpOS, fpDevice := DHCPFingerprintGuess(fingerprint); fpOS != "" {
+				os = fpOS
+				if fpDevice != "" {
+					device = fpDevice
+				}
+			}
+		case layers.DHCPOptClassID:
+			vendor := string(opt.Data)
+			meta["dhcp:vendor"] = vendor
+			if vcOS, vcDevice := dhcpVendorGuess(vendor); os == "" {
+				os, device = vcOS, vcDevice
+			} else if device == "" {
+				device = vcDevice
+			}
+		case layers.DHCPOptHostname:
+			meta["dhcp:hostname"] = string(opt.Data)
+		}
+	}
+
+	if len(meta) == 0 {
+		return nil, nil
+	} else if os != "" {
+		meta["os:guess"] = os
+	}
+	if device != "" {
+		meta["device:type"] = device
+	}
+
+	return dhcp.ClientHWAddr, meta
+}
+
+// OSGetMeta passively fingerprints the operating system of the packet
+// source from its TCP SYN packets, DHCP clients are fingerprinted by
+// DHCPGetMeta.
+func OSGetMeta(pkt gopacket.Packet) map[string]string {
+	return osGetTCPMeta(pkt)
+}
@@ -0,0 +1,145 @@
+package packets
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestOSInitialTTL(t *testing.T) {
+	var units = []struct {
+		from uint8
+		to   uint8
+	}{
+		{1, 32},
+		{57, 64},
+		{64, 64},
+		{113, 128},
+		{250, 255},
+	}
+
+	for _, u := range units {
+		if got := OSInitialTTL(u.from); got != u.to {
+			t.Fatalf("expected '%d', got '%d'", u.to, got)
+		}
+	}
+}
+
+func TestOSGuess(t *testing.T) {
+	var units = []struct {
+		ttl     uint8
+		window  uint16
+		options string
+		os      string
+	}{
+		{127, 64240, "M,N,W,N,N,S", "Windows"},
+		{63, 65535, "M,N,W,N,N,T,S,E", "macOS/iOS"},
+		{64, 64240, "M,S,T,N,W", "Linux"},
+		{64, 1234, "M,S,T,N,W", "Linux/Android"},
+		{61, 65535, "M,N,W,S,T", "FreeBSD"},
+		{64, 1234, "?30", "Unix"},
+		{20, 1234, "M", ""},
+	}
+
+	for _, u := range units {
+		if got := OSGuess(u.ttl, u.window, u.options); got != u.os {
+			t.Fatalf("expected '%s', got '%s'", u.os, got)
+		}
+	}
+}
+
+func TestOSGuessFromDHCPVendor(t *testing.T) {
+	if got := OSGuessFromDHCPVendor("MSFT 5.0"); got != "Windows" {
+		t.Fatalf("expected 'Windows', got '%s'", got)
+	} else if got = OSGuessFromDHCPVendor("android-dhcp-11"); got != "Android" {
+		t.Fatalf("expected 'Android', got '%s'", got)
+	} else if got = OSGuessFromDHCPVendor("whatever"); got != "" {
+		t.Fatalf("expected empty guess, got '%s'", got)
+	}
+}
+
+func TestDHCPFingerprint(t *testing.T) {
+	if got := DHCPFingerprint([]byte{1, 121, 3, 6, 15, 119, 252}); got != "1,121,3,6,15,119,252" {
+		t.Fatalf("unexpected fingerprint '%s'", got)
+	} else if got = DHCPFingerprint(nil); got != "" {
+		t.Fatalf("expected empty fingerprint, got '%s'", got)
+	}
+}
+
+func TestDHCPFingerprintGuess(t *testing.T) {
+	var units = []struct {
+		fingerprint string
+		os          string
+		device      string
+	}{
+		{"1,3,6,15,31,33,43,44,46,47,119,121,249,252", "Windows 10/11", DeviceComputer},
+		{"1,121,3,6,15,119,252", "iOS", DevicePhone},
+		{"1,3,6,15,26,28,51,58,59,43", "Android", DevicePhone},
+		{"1,15,3,6,44,46,47,31,33,249,43,252,12", "Windows", DeviceComputer},
+		{"1,121,3,6,15,119,252,95", "macOS/iOS", ""},
+		{"1,2,3", "", ""},
+	}
+
+	for _, u := range units {
+		if os, device := DHCPFingerprintGuess(u.fingerprint); os != u.os || device != u.device {
+			t.Fatalf("expected '%s'/'%s', got '%s'/'%s'", u.os, u.device, os, device)
+		}
+	}
+}
+
+func buildDHCPClientPacket(t *testing.T, msgType layers.DHCPMsgType, client net.HardwareAddr) gopacket.Packet {
+	eth := layers.Ethernet{
+		SrcMAC:       client,
+		DstMAC:       layers.EthernetBroadcast,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.IPv4zero,
+		DstIP:    net.IPv4bcast,
+	}
+	udp := layers.UDP{SrcPort: 68, DstPort: 67}
+	udp.SetNetworkLayerForChecksum(&ip4)
+	dhcp := layers.DHCPv4{
+		Operation:    layers.DHCPOpRequest,
+		HardwareType: layers.LinkTypeEthernet,
+		HardwareLen:  6,
+		ClientHWAddr: client,
+		Options: layers.DHCPOptions{
+			layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(msgType)}),
+			layers.NewDHCPOption(layers.DHCPOptParamsRequest, []byte{1, 3, 6, 15, 31, 33, 43, 44, 46, 47, 119, 121, 249, 252}),
+			layers.NewDHCPOption(layers.DHCPOptClassID, []byte("MSFT 5.0")),
+		},
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, &eth, &ip4, &udp, &dhcp); err != nil {
+		t.Fatal(err)
+	}
+
+	return gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+}
+
+func TestDHCPGetMeta(t *testing.T) {
+	client, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+
+	for _, msgType := range []layers.DHCPMsgType{layers.DHCPMsgTypeDiscover, layers.DHCPMsgTypeRequest} {
+		mac, meta := DHCPGetMeta(buildDHCPClientPacket(t, msgType, client))
+		if mac.String() != client.String() {
+			t.Fatalf("expected client %s, got %s", client, mac)
+		} else if meta["dhcp:vendor"] != "MSFT 5.0" || meta["dhcp:fingerprint"] != "1,3,6,15,31,33,43,44,46,47,119,121,249,252" {
+			t.Fatalf("unexpected meta %v", meta)
+		} else if meta["os:guess"] != "Windows 10/11" || meta["device:type"] != DeviceComputer {
+			t.Fatalf("unexpected guess %v", meta)
+		}
+	}
+
+	if _, meta := DHCPGetMeta(buildDHCPClientPacket(t, layers.DHCPMsgTypeRelease, client)); meta != nil {
+		t.Fatalf("unexpected meta for a release %v", meta)
+	}
+}
@@ -14,6 +14,9 @@ import (
 	"github.com/google/gopacket/pcap"
 )
 
+// how many DHCP clients are kept waiting for them to get an address
+const maxDHCPClients = 1024
+
 type Activity struct {
 	IP     net.IP
 	MAC    net.HardwareAddr
@@ -40,32 +43,47 @@ type Queue struct {
 	Stats      Stats
 	Protos     sync.Map
 	Traffic    sync.Map
+	VLANs      sync.Map
+	Subnets    sync.Map
 	Activities chan Activity
 
 	iface      *network.Endpoint
+	subnets    *network.Subnets
 	handle     *pcap.Handle
 	source     *gopacket.PacketSource
 	srcChannel chan gopacket.Packet
 	writes     *sync.WaitGroup
 	active     bool
+	// closed to make the current worker exit, and by the worker when done
+	quit chan struct{}
+	done chan struct{}
+
+	// fingerprints of the DHCP clients, waiting for them to show up on the
+	// LAN with an address
+	dhcpLock    sync.Mutex
+	dhcpClients map[string]map[string]string
 }
 
 type queueJSON struct {
 	Stats   Stats               `json:"stats"`
 	Protos  map[string]int      `json:"protos"`
 	Traffic map[string]*Traffic `json:"traffic"`
+	VLANs   map[string]int      `json:"vlans"`
 }
 
 func NewQueue(iface *network.Endpoint) (q *Queue, err error) {
 	q = &Queue{
 		Protos:     sync.Map{},
 		Traffic:    sync.Map{},
+		VLANs:      sync.Map{},
+		Subnets:    sync.Map{},
 		Stats:      Stats{},
 		Activities: make(chan Activity),
 
-		writes: &sync.WaitGroup{},
-		iface:  iface,
-		active: !iface.IsMonitor(),
+		writes:      &sync.WaitGroup{},
+		iface:       iface,
+		active:      !iface.IsMonitor(),
+		dhcpClients: make(map[string]map[string]string),
 	}
 
 	if q.active {
@@ -73,9 +91,7 @@ func NewQueue(iface *network.Endpoint) (q *Queue, err error) {
 			return
 		}
 
-		q.source = gopacket.NewPacketSource(q.handle, q.handle.LinkType())
-		q.srcChannel = q.source.Packets()
-		go q.worker()
+		q.startWorker()
 	}
 
 	return
@@ -88,6 +104,7 @@ func (q *Queue) MarshalJSON() ([]byte, error) {
 		Stats:   q.Stats,
 		Protos:  make(map[string]int),
 		Traffic: make(map[string]*Traffic),
+		VLANs:   make(map[string]int),
 	}
 
 	q.Protos.Range(func(k, v interface{}) bool {
@@ -100,6 +117,11 @@ func (q *Queue) MarshalJSON() ([]byte, error) {
 		return true
 	})
 
+	q.VLANs.Range(func(k, v interface{}) bool {
+		doc.VLANs[fmt.Sprintf("%d", k.(uint16))] = v.(int)
+		return true
+	})
+
 	return json.Marshal(doc)
 }
 
@@ -121,6 +143,26 @@ func (q *Queue) trackProtocols(pkt gopacket.Packet) {
 	}
 }
 
+func (q *Queue) trackVLANs(pkt gopacket.Packet) {
+	for _, id := range VLANGetIDs(pkt) {
+		if v, found := q.VLANs.Load(id); !found {
+			q.VLANs.Store(id, 1)
+		} else {
+			q.VLANs.Store(id, v.(int)+1)
+		}
+	}
+}
+
+// keep track of the subnets routers are advertising, the
+// session will decide which ones are worth registering
+func (q *Queue) trackSubnets(pkt gopacket.Packet) {
+	if subnets, source := SubnetsGetFromPacket(pkt); subnets != nil {
+		for _, subnet := range subnets {
+			q.Subnets.LoadOrStore(subnet.String(), source)
+		}
+	}
+}
+
 func (q *Queue) trackActivity(eth *layers.Ethernet, address net.IP, meta map[string]string, pktSize uint64, isSent bool) {
 	// push to activity channel
 	q.Activities <- Activity{
@@ -172,57 +214,163 @@ func (q *Queue) getPacketMeta(pkt gopacket.Packet) map[string]string {
 		meta = nbns
 	} else if upnp := UPNPGetMeta(pkt); upnp != nil {
 		meta = upnp
+	} else if wsd := WSDGetMeta(pkt); wsd != nil {
+		meta = wsd
+	} else if icmp6 := ICMP6GetMeta(pkt); icmp6 != nil {
+		meta = icmp6
+	} else if os := OSGetMeta(pkt); os != nil {
+		meta = os
+	}
+
+	// vlan tags are orthogonal to the discovery protocols
+	if vlan := VLANGetMeta(pkt); vlan != nil {
+		for k, v := range vlan {
+			meta[k] = v
+		}
+	}
+	return meta
+}
+
+// trackDHCPClient keeps the fingerprint of a DHCP client until it shows up on
+// the LAN, requests are sent from 0.0.0.0 before it has an address.
+func (q *Queue) trackDHCPClient(mac net.HardwareAddr, meta map[string]string) {
+	q.dhcpLock.Lock()
+	defer q.dhcpLock.Unlock()
+
+	if len(q.dhcpClients) >= maxDHCPClients {
+		q.dhcpClients = make(map[string]map[string]string)
+	}
+	q.dhcpClients[mac.String()] = meta
+}
+
+// withDHCPMeta adds the pending DHCP fingerprint of mac, if any, to meta.
+func (q *Queue) withDHCPMeta(mac net.HardwareAddr, meta map[string]string) map[string]string {
+	q.dhcpLock.Lock()
+	defer q.dhcpLock.Unlock()
+
+	key := mac.String()
+	if dhcp, found := q.dhcpClients[key]; found {
+		delete(q.dhcpClients, key)
+		for k, v := range dhcp {
+			meta[k] = v
+		}
 	}
 	return meta
 }
 
-func (q *Queue) worker() {
-	for pkt := range q.srcChannel {
-		if !q.active {
+// SetSubnets sets the subnets reachable through the gateway, the activity
+// of their hosts is tracked as well.
+func (q *Queue) SetSubnets(subnets *network.Subnets) {
+	q.Lock()
+	defer q.Unlock()
+	q.subnets = subnets
+}
+
+// IPv6 hosts are only considered part of the LAN
+// when using link-local addresses, the neighbor table
+// takes care of the global ones
+func (q *Queue) isLAN(ip net.IP) bool {
+	if ip.To4() == nil {
+		return ip.IsLinkLocalUnicast()
+	} else if q.iface.Net.Contains(ip) {
+		return true
+	}
+
+	q.RLock()
+	subnets := q.subnets
+	q.RUnlock()
+	return subnets != nil && subnets.Contains(ip) != nil
+}
+
+// startWorker starts reading the packets of the current handle, once the
+// previous worker is gone, must be called with the lock held.
+func (q *Queue) startWorker() {
+	prev := q.done
+	q.source = gopacket.NewPacketSource(q.handle, q.handle.LinkType())
+	q.srcChannel = q.source.Packets()
+	q.quit = make(chan struct{})
+	q.done = make(chan struct{})
+	go q.worker(q.srcChannel, q.quit, q.done, prev)
+}
+
+func (q *Queue) worker(packets chan gopacket.Packet, quit chan struct{}, done chan struct{}, prev chan struct{}) {
+	defer close(done)
+
+	if prev != nil {
+		select {
+		case <-prev:
+		case <-quit:
 			return
 		}
+	}
 
-		q.trackProtocols(pkt)
-
-		pktSize := uint64(len(pkt.Data()))
-
-		q.TrackPacket(pktSize)
-
-		// decode eth and ipv4/6 layers
-		leth := pkt.Layer(layers.LayerTypeEthernet)
-		lip4 := pkt.Layer(layers.LayerTypeIPv4)
-		lip6 := pkt.Layer(layers.LayerTypeIPv6)
-		if leth != nil && (lip4 != nil || lip6 != nil) {
-			var srcIP, dstIP net.IP
-			if lip4 != nil {
-				ip4 := lip4.(*layers.IPv4)
-				srcIP = ip4.SrcIP
-				dstIP = ip4.DstIP
-			} else {
-				ip6 := lip6.(*layers.IPv6)
-				srcIP = ip6.SrcIP
-				dstIP = ip6.DstIP
+	for {
+		select {
+		case <-quit:
+			return
+		case pkt, ok := <-packets:
+			if !ok {
+				// the capture ended on its own, for instance because the
+				// interface went away
+				q.Lock()
+				if q.quit == quit {
+					q.active = false
+				}
+				q.Unlock()
+				return
 			}
+			q.process(pkt)
+		}
+	}
+}
 
-			// here we try to discover new hosts
-			// on this lan by inspecting packets
-			// we manage to sniff
-			eth := leth.(*layers.Ethernet)
-
-			// something coming from someone on the LAN
-			isFromMe := q.iface.IP.Equal(srcIP) || q.iface.IPv6.Equal(srcIP)
-			isFromLAN := q.iface.Net.Contains(srcIP)
-			if !isFromMe && isFromLAN {
-				meta := q.getPacketMeta(pkt)
-				q.trackActivity(eth, srcIP, meta, pktSize, true)
-			}
+func (q *Queue) process(pkt gopacket.Packet) {
+	q.trackProtocols(pkt)
+	q.trackVLANs(pkt)
+	q.trackSubnets(pkt)
 
-			// something going to someone on the LAN
-			isToMe := q.iface.IP.Equal(dstIP) || q.iface.IPv6.Equal(dstIP)
-			isToLAN := q.iface.Net.Contains(dstIP)
-			if !isToMe && isToLAN {
-				q.trackActivity(eth, dstIP, nil, pktSize, false)
-			}
+	pktSize := uint64(len(pkt.Data()))
+
+	q.TrackPacket(pktSize)
+
+	if mac, meta := DHCPGetMeta(pkt); meta != nil {
+		q.trackDHCPClient(mac, meta)
+	}
+
+	// decode eth and ipv4/6 layers
+	leth := pkt.Layer(layers.LayerTypeEthernet)
+	lip4 := pkt.Layer(layers.LayerTypeIPv4)
+	lip6 := pkt.Layer(layers.LayerTypeIPv6)
+	if leth != nil && (lip4 != nil || lip6 != nil) {
+		var srcIP, dstIP net.IP
+		if lip4 != nil {
+			ip4 := lip4.(*layers.IPv4)
+			srcIP = ip4.SrcIP
+			dstIP = ip4.DstIP
+		} else {
+			ip6 := lip6.(*layers.IPv6)
+			srcIP = ip6.SrcIP
+			dstIP = ip6.DstIP
+		}
+
+		// here we try to discover new hosts
+		// on this lan by inspecting packets
+		// we manage to sniff
+		eth := leth.(*layers.Ethernet)
+
+		// something coming from someone on the LAN
+		isFromMe := q.iface.IP.Equal(srcIP) || q.iface.IPv6.Equal(srcIP)
+		isFromLAN := q.isLAN(srcIP)
+		if !isFromMe && isFromLAN {
+			meta := q.withDHCPMeta(eth.SrcMAC, q.getPacketMeta(pkt))
+			q.trackActivity(eth, srcIP, meta, pktSize, true)
+		}
+
+		// something going to someone on the LAN
+		isToMe := q.iface.IP.Equal(dstIP) || q.iface.IPv6.Equal(dstIP)
+		isToLAN := q.isLAN(dstIP)
+		if !isToMe && isToLAN {
+			q.trackActivity(eth, dstIP, nil, pktSize, false)
 		}
 	}
 }
@@ -248,6 +396,36 @@ func (q *Queue) Send(raw []byte) error {
 	return nil
 }
 
+// Reopen starts capturing again on the interface, used when it went
+// away and came back.
+func (q *Queue) Reopen() (err error) {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.iface.IsMonitor() {
+		return nil
+	}
+
+	q.writes.Wait()
+	if q.active {
+		close(q.quit)
+	}
+	if q.handle != nil {
+		q.handle.Close()
+	}
+
+	if q.handle, err = network.Capture(q.iface.Name()); err != nil {
+		q.active = false
+		return
+	}
+
+	// the new worker waits for the previous one to exit
+	q.active = true
+	q.startWorker()
+
+	return
+}
+
 func (q *Queue) Stop() {
 	q.Lock()
 	defer q.Unlock()
@@ -255,9 +433,9 @@ func (q *Queue) Stop() {
 	if q.active {
 		// wait for write operations to be completed
 		q.writes.Wait()
-		// signal the main loop to exit and close the handle
+		// signal the worker to exit and close the handle
 		q.active = false
-		q.srcChannel <- nil
+		close(q.quit)
 		q.handle.Close()
 	}
 }
@@ -0,0 +1,195 @@
+package packets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	RDPPort = 3389
+
+	RDPProtocolRDP      = 0x00000000
+	RDPProtocolSSL      = 0x00000001
+	RDPProtocolHybrid   = 0x00000002
+	RDPProtocolRDSTLS   = 0x00000004
+	RDPProtocolHybridEx = 0x00000008
+
+	RDPFailureSSLRequired    = 0x00000001
+	RDPFailureSSLNotAllowed  = 0x00000002
+	RDPFailureNoCertificate  = 0x00000003
+	RDPFailureInconsistent   = 0x00000004
+	RDPFailureHybridRequired = 0x00000005
+
+	x224ConnectionRequest = 0xe0
+	x224ConnectionConfirm = 0xd0
+
+	rdpNegRequest  = 0x01
+	rdpNegResponse = 0x02
+	rdpNegFailure  = 0x03
+
+	rdpClientCoreData = 0xc001
+)
+
+var rdpCookie = []byte("Cookie: mstshash=")
+
+// RDPConnectionRequest is the first message of an RDP client.
+type RDPConnectionRequest struct {
+	// user name hint, usually truncated to 9 characters
+	Cookie    string
+	Protocols uint32
+}
+
+// RDPConnectionConfirm is the answer of the server to an RDPConnectionRequest,
+// either the selected protocol or why the negotiation failed.
+type RDPConnectionConfirm struct {
+	Protocol uint32
+	Failed   bool
+	Failure  uint32
+}
+
+// NLA returns true if the server requires Network Level Authentication
+// via CredSSP, before any RDP session is created.
+func (c RDPConnectionConfirm) NLA() bool {
+	if c.Failed {
+		return c.Failure == RDPFailureHybridRequired
+	}
+	return c.Protocol&(RDPProtocolHybrid|RDPProtocolHybridEx) != 0
+}
+
+func (c RDPConnectionConfirm) String() string {
+	if !c.Failed {
+		return strings.Join(RDPProtocolNames(c.Protocol), ", ")
+	}
+
+	switch c.Failure {
+	case RDPFailureSSLRequired:
+		return "failed, tls required"
+	case RDPFailureSSLNotAllowed:
+		return "failed, tls not allowed"
+	case RDPFailureNoCertificate:
+		return "failed, no certificate"
+	case RDPFailureInconsistent:
+		return "failed, inconsistent flags"
+	case RDPFailureHybridRequired:
+		return "failed, nla required"
+	}
+	return fmt.Sprintf("failed, code %d", c.Failure)
+}
+
+// RDPProtocolNames returns the human readable security protocols of a
+// negotiation request or response.
+func RDPProtocolNames(protocols uint32) []string {
+	if protocols == RDPProtocolRDP {
+		return []string{"rdp"}
+	}
+
+	names := make([]string, 0)
+	for _, p := range []struct {
+		flag uint32
+		name string
+	}{
+		{RDPProtocolSSL, "tls"},
+		{RDPProtocolHybrid, "credssp"},
+		{RDPProtocolRDSTLS, "rdstls"},
+		{RDPProtocolHybridEx, "credssp-ex"},
+	} {
+		if protocols&p.flag != 0 {
+			names = append(names, p.name)
+		}
+	}
+	return names
+}
+
+// x224 returns the X.224 TPDU code and its variable part carried by a TPKT
+// packet.
+func x224(data []byte) (byte, []byte, error) {
+	if len(data) < 4+7 || data[0] != 0x03 || data[1] != 0x00 {
+		return 0, nil, fmt.Errorf("not a tpkt packet")
+	} else if size := int(binary.BigEndian.Uint16(data[2:])); size > len(data) {
+		return 0, nil, fmt.Errorf("tpkt packet truncated")
+	}
+
+	tpdu := data[4:]
+	indicator := int(tpdu[0])
+	if indicator < 6 || 1+indicator > len(tpdu) {
+		return 0, nil, fmt.Errorf("invalid x.224 length indicator %d", indicator)
+	}
+	return tpdu[1] & 0xf0, tpdu[7 : 1+indicator], nil
+}
+
+// ParseRDPConnectionRequest parses the X.224 Connection Request of an RDP
+// client.
+func ParseRDPConnectionRequest(data []byte) (*RDPConnectionRequest, error) {
+	code, variable, err := x224(data)
+	if err != nil {
+		return nil, err
+	} else if code != x224ConnectionRequest {
+		return nil, fmt.Errorf("not an x.224 connection request")
+	}
+
+	req := &RDPConnectionRequest{}
+	if bytes.HasPrefix(variable, rdpCookie) {
+		end := bytes.Index(variable, []byte("\r\n"))
+		if end == -1 {
+			return nil, fmt.Errorf("unterminated rdp cookie")
+		}
+		req.Cookie = string(variable[len(rdpCookie):end])
+		variable = variable[end+2:]
+	}
+
+	if len(variable) >= 8 && variable[0] == rdpNegRequest {
+		req.Protocols = binary.LittleEndian.Uint32(variable[4:])
+	}
+	return req, nil
+}
+
+// ParseRDPConnectionConfirm parses the X.224 Connection Confirm of an RDP
+// server.
+func ParseRDPConnectionConfirm(data []byte) (*RDPConnectionConfirm, error) {
+	code, variable, err := x224(data)
+	if err != nil {
+		return nil, err
+	} else if code != x224ConnectionConfirm {
+		return nil, fmt.Errorf("not an x.224 connection confirm")
+	}
+
+	// legacy servers don't negotiate and only speak standard rdp security
+	confirm := &RDPConnectionConfirm{}
+	if len(variable) >= 8 {
+		switch variable[0] {
+		case rdpNegResponse:
+			confirm.Protocol = binary.LittleEndian.Uint32(variable[4:])
+		case rdpNegFailure:
+			confirm.Failed = true
+			confirm.Failure = binary.LittleEndian.Uint32(variable[4:])
+		}
+	}
+	return confirm, nil
+}
+
+// RDPClientName returns the name of the client computer found in the core
+// data of an MCS Connect Initial, only sent in clear text with standard RDP
+// security.
+func RDPClientName(data []byte) (string, bool) {
+	for offset := 0; offset+24+32 <= len(data); offset++ {
+		if binary.LittleEndian.Uint16(data[offset:]) != rdpClientCoreData {
+			continue
+		}
+		// the major version is 8 for every RDP 4.0 and later client
+		if version := binary.LittleEndian.Uint32(data[offset+4:]); version>>16 != 0x0008 {
+			continue
+		}
+		// header, version, width, height, color depth, SAS sequence,
+		// keyboard layout and client build
+		name := smbString(data[offset+4+4+2+2+2+2+4+4:][:32])
+		if end := strings.IndexRune(name, 0); end != -1 {
+			name = name[:end]
+		}
+		if name != "" {
+			return name, true
+		}
+	}
+	return "", false
+}
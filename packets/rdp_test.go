@@ -0,0 +1,80 @@
+package packets
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func rdpTPKT(code byte, variable []byte) []byte {
+	tpdu := append([]byte{byte(6 + len(variable)), code, 0, 0, 0, 0, 0}, variable...)
+	raw := append([]byte{0x03, 0x00, 0, 0}, tpdu...)
+	binary.BigEndian.PutUint16(raw[2:], uint16(len(raw)))
+	return raw
+}
+
+func rdpNeg(typ byte, value uint32) []byte {
+	neg := []byte{typ, 0, 8, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(neg[4:], value)
+	return neg
+}
+
+func TestParseRDPConnectionRequest(t *testing.T) {
+	variable := append([]byte("Cookie: mstshash=alice\r\n"), rdpNeg(rdpNegRequest, RDPProtocolSSL|RDPProtocolHybrid)...)
+	req, err := ParseRDPConnectionRequest(rdpTPKT(x224ConnectionRequest, variable))
+	if err != nil {
+		t.Fatal(err)
+	} else if req.Cookie != "alice" {
+		t.Fatalf("unexpected cookie %s", req.Cookie)
+	} else if names := RDPProtocolNames(req.Protocols); !reflect.DeepEqual(names, []string{"tls", "credssp"}) {
+		t.Fatalf("unexpected protocols %v", names)
+	}
+
+	if _, err = ParseRDPConnectionRequest(rdpTPKT(x224ConnectionConfirm, nil)); err == nil {
+		t.Fatal("expected error for a connection confirm")
+	} else if _, err = ParseRDPConnectionRequest([]byte{0x16, 0x03, 0x01}); err == nil {
+		t.Fatal("expected error for a tls record")
+	}
+}
+
+func TestParseRDPConnectionConfirm(t *testing.T) {
+	var units = []struct {
+		variable []byte
+		nla      bool
+		desc     string
+	}{
+		{nil, false, "rdp"},
+		{rdpNeg(rdpNegResponse, RDPProtocolSSL), false, "tls"},
+		{rdpNeg(rdpNegResponse, RDPProtocolHybridEx), true, "credssp-ex"},
+		{rdpNeg(rdpNegFailure, RDPFailureHybridRequired), true, "failed, nla required"},
+	}
+	for _, u := range units {
+		confirm, err := ParseRDPConnectionConfirm(rdpTPKT(x224ConnectionConfirm, u.variable))
+		if err != nil {
+			t.Fatal(err)
+		} else if confirm.NLA() != u.nla {
+			t.Fatalf("expected nla %v for %s", u.nla, confirm)
+		} else if confirm.String() != u.desc {
+			t.Fatalf("expected '%s', got '%s'", u.desc, confirm)
+		}
+	}
+}
+
+func TestRDPClientName(t *testing.T) {
+	core := make([]byte, 4+20+32)
+	binary.LittleEndian.PutUint16(core[0:], rdpClientCoreData)
+	binary.LittleEndian.PutUint16(core[2:], uint16(len(core)))
+	binary.LittleEndian.PutUint32(core[4:], 0x00080004)
+	copy(core[24:], smbUTF16("WORKSTATION1"))
+
+	data := append([]byte{0x7f, 0x65, 0x82, 0x01, 0x00}, core...)
+	if name, found := RDPClientName(data); !found {
+		t.Fatal("expected client name")
+	} else if name != "WORKSTATION1" {
+		t.Fatalf("unexpected client name %s", name)
+	}
+
+	if _, found := RDPClientName(data[:20]); found {
+		t.Fatal("unexpected client name")
+	}
+}
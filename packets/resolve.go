@@ -0,0 +1,106 @@
+package packets
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// ReverseAddress returns the in-addr.arpa or ip6.arpa name of ip.
+func ReverseAddress(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return ""
+	}
+
+	nibbles := make([]string, 0, 32)
+	for i := len(ip16) - 1; i >= 0; i-- {
+		nibbles = append(nibbles, fmt.Sprintf("%x.%x", ip16[i]&0x0f, ip16[i]>>4))
+	}
+	return strings.Join(nibbles, ".") + ".ip6.arpa"
+}
+
+func udpQuery(address string, port int, query []byte, timeout time.Duration) ([]byte, error) {
+	con, err := net.DialTimeout("udp", net.JoinHostPort(address, fmt.Sprintf("%d", port)), timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer con.Close()
+
+	con.SetDeadline(time.Now().Add(timeout))
+	if _, err = con.Write(query); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 1500)
+	n, err := con.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// MDNSLookupAddr sends a unicast mDNS PTR query for the reverse name of
+// address directly to the host, which will answer with its .local name.
+func MDNSLookupAddr(address string, timeout time.Duration) (string, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return "", fmt.Errorf("invalid address %s", address)
+	}
+
+	query := layers.DNS{
+		ID:     uint16(time.Now().UnixNano()),
+		OpCode: layers.DNSOpCodeQuery,
+		Questions: []layers.DNSQuestion{
+			{
+				Name:  []byte(ReverseAddress(ip)),
+				Type:  layers.DNSTypePTR,
+				Class: layers.DNSClassIN,
+			},
+		},
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := query.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		return "", err
+	}
+
+	raw, err := udpQuery(address, MDNSPort, buf.Bytes(), timeout)
+	if err != nil {
+		return "", err
+	}
+
+	answer := layers.DNS{}
+	if err = answer.DecodeFromBytes(raw, gopacket.NilDecodeFeedback); err != nil {
+		return "", err
+	}
+
+	for _, rr := range answer.Answers {
+		if rr.Type == layers.DNSTypePTR && len(rr.PTR) > 0 {
+			return strings.TrimSuffix(string(rr.PTR), "."), nil
+		}
+	}
+	return "", fmt.Errorf("no mDNS PTR records for %s", address)
+}
+
+// NBNSLookupAddr sends a NetBIOS node status request to address
+// and returns the name the host registered.
+func NBNSLookupAddr(address string, timeout time.Duration) (string, error) {
+	raw, err := udpQuery(address, NBNSPort, NBNSRequest, timeout)
+	if err != nil {
+		return "", err
+	}
+
+	if meta := NBNSParseNodeStatus(raw); meta != nil && meta["nbns:hostname"] != "" {
+		return meta["nbns:hostname"], nil
+	}
+	return "", fmt.Errorf("no NetBIOS name for %s", address)
+}
@@ -0,0 +1,26 @@
+package packets
+
+import (
+	"net"
+	"testing"
+)
+
+func TestReverseAddress(t *testing.T) {
+	var units = []struct {
+		ip       string
+		expected string
+	}{
+		{"192.168.1.10", "10.1.168.192.in-addr.arpa"},
+		{"2001:db8::567:89ab", "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"},
+	}
+
+	for _, u := range units {
+		if got := ReverseAddress(net.ParseIP(u.ip)); got != u.expected {
+			t.Fatalf("expected '%s', got '%s'", u.expected, got)
+		}
+	}
+
+	if got := ReverseAddress(nil); got != "" {
+		t.Fatalf("expected empty name, got '%s'", got)
+	}
+}
@@ -0,0 +1,323 @@
+package packets
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const SIPPort = 5060
+
+// compact forms of the SIP headers
+var sipCompactHeaders = map[string]string{
+	"i": "call-id",
+	"f": "from",
+	"t": "to",
+	"m": "contact",
+	"v": "via",
+	"l": "content-length",
+	"c": "content-type",
+}
+
+// SIPMessage is a SIP request, if Method is set, or response.
+type SIPMessage struct {
+	Method     string
+	URI        string
+	StatusCode int
+	Reason     string
+	// lower case names, the first value only
+	Headers map[string]string
+	Body    []byte
+}
+
+// ParseSIP parses a SIP request or response.
+func ParseSIP(data []byte) (*SIPMessage, error) {
+	end := bytes.Index(data, []byte("\r\n\r\n"))
+	if end == -1 {
+		return nil, fmt.Errorf("sip headers not terminated")
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data[:end]))
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("empty sip message")
+	}
+
+	msg := &SIPMessage{
+		Headers: make(map[string]string),
+		Body:    data[end+4:],
+	}
+
+	first := strings.SplitN(scanner.Text(), " ", 3)
+	if len(first) != 3 {
+		return nil, fmt.Errorf("invalid sip start line")
+	} else if first[0] == "SIP/2.0" {
+		code, err := strconv.Atoi(first[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid sip status code %s", first[1])
+		}
+		msg.StatusCode = code
+		msg.Reason = first[2]
+	} else if first[2] == "SIP/2.0" {
+		msg.Method = first[0]
+		msg.URI = first[1]
+	} else {
+		return nil, fmt.Errorf("not a sip message")
+	}
+
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if long, found := sipCompactHeaders[name]; found {
+			name = long
+		}
+		if _, found := msg.Headers[name]; !found {
+			msg.Headers[name] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	if size, err := strconv.Atoi(msg.Headers["content-length"]); err == nil && size < len(msg.Body) {
+		msg.Body = msg.Body[:size]
+	}
+
+	return msg, nil
+}
+
+func (m SIPMessage) IsRequest() bool {
+	return m.Method != ""
+}
+
+func (m SIPMessage) CallID() string {
+	return m.Headers["call-id"]
+}
+
+// CSeqMethod returns the method of the request a response is answering.
+func (m SIPMessage) CSeqMethod() string {
+	if parts := strings.Fields(m.Headers["cseq"]); len(parts) == 2 {
+		return parts[1]
+	}
+	return ""
+}
+
+// sipAddress returns the URI of a From or To header value.
+func sipAddress(value string) string {
+	if start := strings.Index(value, "<"); start != -1 {
+		if end := strings.Index(value[start:], ">"); end != -1 {
+			return value[start+1 : start+end]
+		}
+	}
+	return strings.SplitN(value, ";", 2)[0]
+}
+
+func (m SIPMessage) From() string {
+	return sipAddress(m.Headers["from"])
+}
+
+func (m SIPMessage) To() string {
+	return sipAddress(m.Headers["to"])
+}
+
+// SIPDigest is the digest authentication answer of a SIP client.
+type SIPDigest struct {
+	Username  string
+	Realm     string
+	Nonce     string
+	URI       string
+	Response  string
+	Algorithm string
+	QOP       string
+	NC        string
+	CNonce    string
+}
+
+// Digest returns the digest authentication of a request, if any.
+func (m SIPMessage) Digest() (*SIPDigest, bool) {
+	value := m.Headers["authorization"]
+	if value == "" {
+		value = m.Headers["proxy-authorization"]
+	}
+	if len(value) < 7 || !strings.EqualFold(value[:7], "digest ") {
+		return nil, false
+	}
+
+	d := &SIPDigest{Algorithm: "MD5"}
+	for _, param := range strings.Split(value[7:], ",") {
+		parts := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		val := strings.Trim(parts[1], `"`)
+		switch strings.ToLower(parts[0]) {
+		case "username":
+			d.Username = val
+		case "realm":
+			d.Realm = val
+		case "nonce":
+			d.Nonce = val
+		case "uri":
+			d.URI = val
+		case "response":
+			d.Response = val
+		case "algorithm":
+			d.Algorithm = val
+		case "qop":
+			d.QOP = val
+		case "nc":
+			d.NC = val
+		case "cnonce":
+			d.CNonce = val
+		}
+	}
+
+	return d, d.Username != "" && d.Response != ""
+}
+
+// Hashcat returns the digest in hashcat format (mode 11400), server and
+// client being the addresses of the two parties.
+func (d SIPDigest) Hashcat(method, server, client string) string {
+	uri := strings.SplitN(d.URI, ":", 3)
+	for len(uri) < 3 {
+		uri = append(uri, "")
+	}
+	return fmt.Sprintf("$sip$*%s*%s*%s*%s*%s*%s*%s*%s*%s*%s*%s*%s*%s*%s",
+		server, client, d.Username, d.Realm, method,
+		uri[0], uri[1], uri[2],
+		d.Nonce, d.CNonce, d.NC, d.QOP,
+		strings.ToUpper(d.Algorithm), d.Response)
+}
+
+// SDPMedia is a media stream announced in an SDP body.
+type SDPMedia struct {
+	Type     string
+	IP       net.IP
+	Port     int
+	Payloads []int
+}
+
+// ParseSDP returns the media streams of an SDP body, with the connection
+// address of the session if they don't have their own.
+func ParseSDP(body []byte) []SDPMedia {
+	media := make([]SDPMedia, 0)
+	var sessionIP net.IP
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) < 2 || line[1] != '=' {
+			continue
+		}
+
+		fields := strings.Fields(line[2:])
+		switch line[0] {
+		case 'c':
+			// c=IN IP4 192.168.1.10
+			if len(fields) == 3 {
+				ip := net.ParseIP(strings.SplitN(fields[2], "/", 2)[0])
+				if len(media) == 0 {
+					sessionIP = ip
+				} else {
+					media[len(media)-1].IP = ip
+				}
+			}
+		case 'm':
+			// m=audio 49170 RTP/AVP 0 8 101
+			if len(fields) < 3 {
+				continue
+			}
+			port, err := strconv.Atoi(strings.SplitN(fields[1], "/", 2)[0])
+			if err != nil {
+				continue
+			}
+			m := SDPMedia{Type: fields[0], IP: sessionIP, Port: port, Payloads: make([]int, 0)}
+			for _, pt := range fields[3:] {
+				if n, err := strconv.Atoi(pt); err == nil {
+					m.Payloads = append(m.Payloads, n)
+				}
+			}
+			media = append(media, m)
+		}
+	}
+
+	return media
+}
+
+const (
+	RTPPayloadPCMU = 0
+	RTPPayloadGSM  = 3
+	RTPPayloadPCMA = 8
+	RTPPayloadG722 = 9
+	RTPPayloadG729 = 18
+)
+
+// RTPPacket is an RTP packet with its header parsed.
+type RTPPacket struct {
+	PayloadType uint8
+	Marker      bool
+	Sequence    uint16
+	Timestamp   uint32
+	SSRC        uint32
+	Payload     []byte
+}
+
+// ParseRTP parses a version 2 RTP packet.
+func ParseRTP(data []byte) (*RTPPacket, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("rtp packet too short")
+	} else if data[0]>>6 != 2 {
+		return nil, fmt.Errorf("unsupported rtp version %d", data[0]>>6)
+	}
+
+	pkt := &RTPPacket{
+		PayloadType: data[1] & 0x7f,
+		Marker:      data[1]&0x80 != 0,
+		Sequence:    binary.BigEndian.Uint16(data[2:]),
+		Timestamp:   binary.BigEndian.Uint32(data[4:]),
+		SSRC:        binary.BigEndian.Uint32(data[8:]),
+	}
+
+	offset := 12 + 4*int(data[0]&0x0f)
+	if data[0]&0x10 != 0 {
+		// header extension
+		if len(data) < offset+4 {
+			return nil, fmt.Errorf("rtp extension truncated")
+		}
+		offset += 4 + 4*int(binary.BigEndian.Uint16(data[offset+2:]))
+	}
+
+	end := len(data)
+	if data[0]&0x20 != 0 && end > 0 {
+		end -= int(data[end-1])
+	}
+	if offset > end {
+		return nil, fmt.Errorf("invalid rtp packet")
+	}
+
+	pkt.Payload = data[offset:end]
+	return pkt, nil
+}
+
+// RTPPayloadName returns the codec of the static payload types.
+func RTPPayloadName(pt uint8) string {
+	switch pt {
+	case RTPPayloadPCMU:
+		return "PCMU"
+	case RTPPayloadGSM:
+		return "GSM"
+	case RTPPayloadPCMA:
+		return "PCMA"
+	case RTPPayloadG722:
+		return "G722"
+	case RTPPayloadG729:
+		return "G729"
+	}
+	if pt >= 96 {
+		return fmt.Sprintf("dynamic/%d", pt)
+	}
+	return fmt.Sprintf("%d", pt)
+}
@@ -0,0 +1,119 @@
+package packets
+
+import (
+	"reflect"
+	"testing"
+)
+
+const sipRegister = "REGISTER sip:192.168.100.121 SIP/2.0\r\n" +
+	"Via: SIP/2.0/UDP 192.168.100.100:5060;branch=z9hG4bK1\r\n" +
+	"From: \"Alice\" <sip:alice@192.168.100.121>;tag=1\r\n" +
+	"t: <sip:alice@192.168.100.121>\r\n" +
+	"i: abc@192.168.100.100\r\n" +
+	"CSeq: 2 REGISTER\r\n" +
+	"Authorization: Digest username=\"alice\", realm=\"asterisk\", nonce=\"2b01df0b\", " +
+	"uri=\"sip:192.168.100.121\", response=\"ad0520061ca07c120d7e8ce696a6df2d\", algorithm=MD5\r\n" +
+	"Content-Length: 0\r\n\r\n"
+
+const sipInviteOK = "SIP/2.0 200 OK\r\n" +
+	"Call-ID: call1\r\n" +
+	"CSeq: 1 INVITE\r\n" +
+	"Content-Type: application/sdp\r\n" +
+	"Content-Length: 100\r\n\r\n" +
+	"v=0\r\n" +
+	"c=IN IP4 10.0.0.1\r\n" +
+	"m=audio 49170 RTP/AVP 0 8 101\r\n" +
+	"m=video 51372 RTP/AVP 99\r\n" +
+	"c=IN IP4 10.0.0.2\r\n" +
+	"trailing garbage"
+
+func TestParseSIPRequest(t *testing.T) {
+	msg, err := ParseSIP([]byte(sipRegister))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var units = []struct {
+		got interface{}
+		exp interface{}
+	}{
+		{msg.IsRequest(), true},
+		{msg.Method, "REGISTER"},
+		{msg.URI, "sip:192.168.100.121"},
+		{msg.CallID(), "abc@192.168.100.100"},
+		{msg.From(), "sip:alice@192.168.100.121"},
+		{msg.To(), "sip:alice@192.168.100.121"},
+		{msg.CSeqMethod(), "REGISTER"},
+	}
+	for _, u := range units {
+		if !reflect.DeepEqual(u.exp, u.got) {
+			t.Fatalf("expected '%v', got '%v'", u.exp, u.got)
+		}
+	}
+
+	digest, found := msg.Digest()
+	if !found {
+		t.Fatal("expected digest")
+	}
+
+	exp := "$sip$*192.168.100.100*192.168.100.121*alice*asterisk*REGISTER*sip*192.168.100.121**2b01df0b****MD5*ad0520061ca07c120d7e8ce696a6df2d"
+	if hash := digest.Hashcat(msg.Method, "192.168.100.100", "192.168.100.121"); hash != exp {
+		t.Fatalf("expected '%s', got '%s'", exp, hash)
+	}
+}
+
+func TestParseSIPResponse(t *testing.T) {
+	msg, err := ParseSIP([]byte(sipInviteOK))
+	if err != nil {
+		t.Fatal(err)
+	} else if msg.IsRequest() || msg.StatusCode != 200 || msg.CSeqMethod() != "INVITE" {
+		t.Fatalf("unexpected message %+v", msg)
+	} else if _, found := msg.Digest(); found {
+		t.Fatal("unexpected digest")
+	}
+
+	media := ParseSDP(msg.Body)
+	if len(media) != 2 {
+		t.Fatalf("expected 2 media, got %d", len(media))
+	} else if m := media[0]; m.Type != "audio" || m.Port != 49170 || m.IP.String() != "10.0.0.1" || !reflect.DeepEqual(m.Payloads, []int{0, 8, 101}) {
+		t.Fatalf("unexpected media %+v", m)
+	} else if m = media[1]; m.Type != "video" || m.IP.String() != "10.0.0.2" {
+		t.Fatalf("unexpected media %+v", m)
+	}
+
+	if _, err = ParseSIP([]byte("GET / HTTP/1.1\r\n\r\n")); err == nil {
+		t.Fatal("expected error for an http request")
+	}
+}
+
+func TestParseRTP(t *testing.T) {
+	// version 2, padding, one csrc, marker and pcma
+	data := []byte{0xa1, 0x88, 0x00, 0x01, 0, 0, 0, 160, 0xde, 0xad, 0xbe, 0xef, 1, 2, 3, 4, 0xaa, 0xbb, 0x00, 0x02}
+	pkt, err := ParseRTP(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var units = []struct {
+		got interface{}
+		exp interface{}
+	}{
+		{pkt.PayloadType, uint8(RTPPayloadPCMA)},
+		{pkt.Marker, true},
+		{pkt.Sequence, uint16(1)},
+		{pkt.Timestamp, uint32(160)},
+		{pkt.SSRC, uint32(0xdeadbeef)},
+		{pkt.Payload, []byte{0xaa, 0xbb}},
+		{RTPPayloadName(pkt.PayloadType), "PCMA"},
+		{RTPPayloadName(101), "dynamic/101"},
+	}
+	for _, u := range units {
+		if !reflect.DeepEqual(u.exp, u.got) {
+			t.Fatalf("expected '%v', got '%v'", u.exp, u.got)
+		}
+	}
+
+	if _, err = ParseRTP([]byte{0x40, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}); err == nil {
+		t.Fatal("expected error for version 1")
+	}
+}
@@ -0,0 +1,468 @@
+package packets
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+const (
+	SMBPort = 445
+
+	SMB2HeaderSize = 64
+
+	SMB2Negotiate    = 0x0000
+	SMB2SessionSetup = 0x0001
+	SMB2TreeConnect  = 0x0003
+	SMB2Create       = 0x0005
+	SMB2Close        = 0x0006
+	SMB2Read         = 0x0008
+	SMB2Ioctl        = 0x000b
+
+	SMB2StatusSuccess                = 0x00000000
+	SMB2StatusPending                = 0x00000103
+	SMB2StatusBufferOverflow         = 0x80000005
+	SMB2StatusMoreProcessingRequired = 0xc0000016
+	SMB2StatusAccessDenied           = 0xc0000022
+	SMB2StatusLogonFailure           = 0xc000006d
+	SMB2StatusBadNetworkName         = 0xc00000cc
+
+	SMB2FlagResponse = 0x00000001
+
+	SMB2SigningEnabled  = 0x0001
+	SMB2SigningRequired = 0x0002
+
+	SMB2SessionGuest = 0x0001
+	SMB2SessionNull  = 0x0002
+
+	SMB2Dialect202 = 0x0202
+	SMB2Dialect210 = 0x0210
+	SMB2Dialect300 = 0x0300
+	SMB2Dialect302 = 0x0302
+	SMB2Dialect311 = 0x0311
+
+	SMB2FsctlPipeTransceive = 0x0011c017
+
+	SMB2CapEncryption = 0x00000040
+
+	SMB2CipherAES128CCM = 0x0001
+	SMB2CipherAES128GCM = 0x0002
+	SMB2CipherAES256CCM = 0x0003
+	SMB2CipherAES256GCM = 0x0004
+
+	smb2PreauthIntegrityContext = 0x0001
+	smb2EncryptionContext       = 0x0002
+	smb2PreauthSHA512           = 0x0001
+	smb2PreauthSaltSize         = 32
+)
+
+var (
+	SMB2Dialects = []uint16{
+		SMB2Dialect202,
+		SMB2Dialect210,
+		SMB2Dialect300,
+		SMB2Dialect302,
+		SMB2Dialect311,
+	}
+
+	smb2Magic = []byte{0xfe, 'S', 'M', 'B'}
+	smb1Magic = []byte{0xff, 'S', 'M', 'B'}
+
+	ErrSMBShort = errors.New("smb message too short")
+)
+
+// SMBDialectName returns the human readable version of an SMB2 dialect.
+func SMBDialectName(dialect uint16) string {
+	switch dialect {
+	case SMB2Dialect202:
+		return "2.0.2"
+	case SMB2Dialect210:
+		return "2.1"
+	case SMB2Dialect300:
+		return "3.0"
+	case SMB2Dialect302:
+		return "3.0.2"
+	case SMB2Dialect311:
+		return "3.1.1"
+	}
+	return fmt.Sprintf("0x%04x", dialect)
+}
+
+// SMB2CipherName returns the human readable version of an SMB 3 cipher.
+func SMB2CipherName(cipher uint16) string {
+	switch cipher {
+	case SMB2CipherAES128CCM:
+		return "AES-128-CCM"
+	case SMB2CipherAES128GCM:
+		return "AES-128-GCM"
+	case SMB2CipherAES256CCM:
+		return "AES-256-CCM"
+	case SMB2CipherAES256GCM:
+		return "AES-256-GCM"
+	}
+	return fmt.Sprintf("0x%04x", cipher)
+}
+
+func smbUTF16(s string) []byte {
+	encoded := utf16.Encode([]rune(s))
+	raw := make([]byte, 2*len(encoded))
+	for i, c := range encoded {
+		binary.LittleEndian.PutUint16(raw[2*i:], c)
+	}
+	return raw
+}
+
+func smbString(raw []byte) string {
+	if len(raw)%2 != 0 {
+		raw = raw[:len(raw)-1]
+	}
+	chars := make([]uint16, len(raw)/2)
+	for i := range chars {
+		chars[i] = binary.LittleEndian.Uint16(raw[2*i:])
+	}
+	// strip the NULL terminator if any
+	for len(chars) > 0 && chars[len(chars)-1] == 0 {
+		chars = chars[:len(chars)-1]
+	}
+	return string(utf16.Decode(chars))
+}
+
+// SMBFrame prepends the NetBIOS session service header to an SMB message.
+func SMBFrame(msg []byte) []byte {
+	frame := make([]byte, 4+len(msg))
+	binary.BigEndian.PutUint32(frame, uint32(len(msg))&0x00ffffff)
+	copy(frame[4:], msg)
+	return frame
+}
+
+type SMB2Header struct {
+	Command     uint16
+	Status      uint32
+	Flags       uint32
+	NextCommand uint32
+	MessageID   uint64
+	TreeID      uint32
+	SessionID   uint64
+}
+
+// Message returns the serialized header followed by body.
+func (h SMB2Header) Message(body []byte) []byte {
+	raw := make([]byte, SMB2HeaderSize+len(body))
+	copy(raw[0:4], smb2Magic)
+	binary.LittleEndian.PutUint16(raw[4:], SMB2HeaderSize)
+	if h.Command != SMB2Negotiate {
+		// credit charge
+		binary.LittleEndian.PutUint16(raw[6:], 1)
+	}
+	binary.LittleEndian.PutUint32(raw[8:], h.Status)
+	binary.LittleEndian.PutUint16(raw[12:], h.Command)
+	// credits requested
+	binary.LittleEndian.PutUint16(raw[14:], 31)
+	binary.LittleEndian.PutUint32(raw[16:], h.Flags)
+	binary.LittleEndian.PutUint32(raw[20:], h.NextCommand)
+	binary.LittleEndian.PutUint64(raw[24:], h.MessageID)
+	binary.LittleEndian.PutUint32(raw[36:], h.TreeID)
+	binary.LittleEndian.PutUint64(raw[40:], h.SessionID)
+	copy(raw[SMB2HeaderSize:], body)
+	return raw
+}
+
+// ParseSMB2Header parses the header of an SMB2 message.
+func ParseSMB2Header(raw []byte) (*SMB2Header, error) {
+	if len(raw) < SMB2HeaderSize {
+		return nil, ErrSMBShort
+	} else if string(raw[0:4]) != string(smb2Magic) {
+		return nil, fmt.Errorf("not an smb2 message")
+	}
+
+	return &SMB2Header{
+		Status:      binary.LittleEndian.Uint32(raw[8:]),
+		Command:     binary.LittleEndian.Uint16(raw[12:]),
+		Flags:       binary.LittleEndian.Uint32(raw[16:]),
+		NextCommand: binary.LittleEndian.Uint32(raw[20:]),
+		MessageID:   binary.LittleEndian.Uint64(raw[24:]),
+		TreeID:      binary.LittleEndian.Uint32(raw[36:]),
+		SessionID:   binary.LittleEndian.Uint64(raw[40:]),
+	}, nil
+}
+
+// smbBuffer returns the buffer at offset (from the start of the header) and
+// size of an SMB2 message, making sure it's within bounds.
+func smbBuffer(raw []byte, offset, size int) ([]byte, error) {
+	if size == 0 {
+		return []byte{}, nil
+	} else if offset < SMB2HeaderSize || offset+size > len(raw) {
+		return nil, ErrSMBShort
+	}
+	return raw[offset : offset+size], nil
+}
+
+// SMB2NegotiateBody builds a NEGOTIATE request for the given dialects, adding
+// the mandatory preauth integrity context if 3.1.1 is one of them.
+func SMB2NegotiateBody(dialects []uint16) []byte {
+	body := make([]byte, 36+2*len(dialects))
+	binary.LittleEndian.PutUint16(body[0:], 36)
+	binary.LittleEndian.PutUint16(body[2:], uint16(len(dialects)))
+	binary.LittleEndian.PutUint16(body[4:], SMB2SigningEnabled)
+	rand.Read(body[12:28])
+
+	with311 := false
+	for i, dialect := range dialects {
+		binary.LittleEndian.PutUint16(body[36+2*i:], dialect)
+		if dialect == SMB2Dialect311 {
+			with311 = true
+		}
+	}
+
+	if with311 {
+		// contexts are 8 bytes aligned
+		for (SMB2HeaderSize+len(body))%8 != 0 {
+			body = append(body, 0)
+		}
+		binary.LittleEndian.PutUint32(body[28:], uint32(SMB2HeaderSize+len(body)))
+		binary.LittleEndian.PutUint16(body[32:], 1)
+
+		ctx := make([]byte, 8+6+smb2PreauthSaltSize)
+		binary.LittleEndian.PutUint16(ctx[0:], smb2PreauthIntegrityContext)
+		binary.LittleEndian.PutUint16(ctx[2:], uint16(len(ctx)-8))
+		binary.LittleEndian.PutUint16(ctx[8:], 1)
+		binary.LittleEndian.PutUint16(ctx[10:], smb2PreauthSaltSize)
+		binary.LittleEndian.PutUint16(ctx[12:], smb2PreauthSHA512)
+		rand.Read(ctx[14:])
+
+		body = append(body, ctx...)
+	}
+
+	return body
+}
+
+type SMB2NegotiateResponse struct {
+	SecurityMode uint16
+	Dialect      uint16
+	Capabilities uint32
+	SecurityBlob []byte
+	// the encryption ciphers selected by 3.1.1 servers, if any
+	Ciphers []uint16
+}
+
+func (r SMB2NegotiateResponse) Signing() string {
+	if r.SecurityMode&SMB2SigningRequired != 0 {
+		return "required"
+	} else if r.SecurityMode&SMB2SigningEnabled != 0 {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+// Encryption returns the cipher negotiated by 3.1.1 servers, or if the
+// encryption is supported at all for the 3.0 dialects.
+func (r SMB2NegotiateResponse) Encryption() string {
+	if len(r.Ciphers) > 0 {
+		return SMB2CipherName(r.Ciphers[0])
+	} else if r.Capabilities&SMB2CapEncryption != 0 {
+		return "supported"
+	}
+	return "disabled"
+}
+
+// ParseSMB2NegotiateResponse parses a full NEGOTIATE response message.
+func ParseSMB2NegotiateResponse(raw []byte) (*SMB2NegotiateResponse, error) {
+	if len(raw) < SMB2HeaderSize+64 {
+		return nil, ErrSMBShort
+	}
+
+	body := raw[SMB2HeaderSize:]
+	blob, err := smbBuffer(raw, int(binary.LittleEndian.Uint16(body[56:])), int(binary.LittleEndian.Uint16(body[58:])))
+	if err != nil {
+		return nil, err
+	}
+
+	res := &SMB2NegotiateResponse{
+		SecurityMode: binary.LittleEndian.Uint16(body[2:]),
+		Dialect:      binary.LittleEndian.Uint16(body[4:]),
+		Capabilities: binary.LittleEndian.Uint32(body[24:]),
+		SecurityBlob: blob,
+	}
+
+	if res.Dialect == SMB2Dialect311 {
+		count := int(binary.LittleEndian.Uint16(body[6:]))
+		offset := int(binary.LittleEndian.Uint32(body[60:]))
+		res.Ciphers = smb2Ciphers(raw, offset, count)
+	}
+
+	return res, nil
+}
+
+// smb2Ciphers returns the ciphers of the ENCRYPTION_CAPABILITIES context,
+// if any among the count negotiate contexts at offset.
+func smb2Ciphers(raw []byte, offset, count int) []uint16 {
+	for i := 0; i < count && offset+8 <= len(raw); i++ {
+		ctxType := binary.LittleEndian.Uint16(raw[offset:])
+		size := int(binary.LittleEndian.Uint16(raw[offset+2:]))
+		data := raw[offset+8:]
+		if size > len(data) {
+			return nil
+		}
+
+		if ctxType == smb2EncryptionContext && size >= 2 {
+			ciphers := make([]uint16, 0)
+			for n := 0; n < int(binary.LittleEndian.Uint16(data)) && 4+2*n <= size; n++ {
+				ciphers = append(ciphers, binary.LittleEndian.Uint16(data[2+2*n:]))
+			}
+			return ciphers
+		}
+
+		// contexts are 8 bytes aligned
+		offset += 8 + size
+		for offset%8 != 0 {
+			offset++
+		}
+	}
+	return nil
+}
+
+// SMB2SessionSetupBody builds a SESSION_SETUP request with the given security token.
+func SMB2SessionSetupBody(token []byte) []byte {
+	body := make([]byte, 24+len(token))
+	binary.LittleEndian.PutUint16(body[0:], 25)
+	body[3] = SMB2SigningEnabled
+	binary.LittleEndian.PutUint16(body[12:], SMB2HeaderSize+24)
+	binary.LittleEndian.PutUint16(body[14:], uint16(len(token)))
+	copy(body[24:], token)
+	return body
+}
+
+// ParseSMB2SessionSetupResponse returns the session flags and the security token.
+func ParseSMB2SessionSetupResponse(raw []byte) (flags uint16, token []byte, err error) {
+	if len(raw) < SMB2HeaderSize+8 {
+		return 0, nil, ErrSMBShort
+	}
+
+	body := raw[SMB2HeaderSize:]
+	flags = binary.LittleEndian.Uint16(body[2:])
+	token, err = smbBuffer(raw, int(binary.LittleEndian.Uint16(body[4:])), int(binary.LittleEndian.Uint16(body[6:])))
+	return
+}
+
+// SMB2TreeConnectBody builds a TREE_CONNECT request for a \\server\share path.
+func SMB2TreeConnectBody(path string) []byte {
+	name := smbUTF16(path)
+	body := make([]byte, 8+len(name))
+	binary.LittleEndian.PutUint16(body[0:], 9)
+	binary.LittleEndian.PutUint16(body[4:], SMB2HeaderSize+8)
+	binary.LittleEndian.PutUint16(body[6:], uint16(len(name)))
+	copy(body[8:], name)
+	return body
+}
+
+// SMB2CreatePipeBody builds a CREATE request to open a named pipe for reading and writing.
+func SMB2CreatePipeBody(pipe string) []byte {
+	name := smbUTF16(pipe)
+	body := make([]byte, 56+len(name))
+	binary.LittleEndian.PutUint16(body[0:], 57)
+	// impersonation level
+	binary.LittleEndian.PutUint32(body[4:], 2)
+	// generic read and write
+	binary.LittleEndian.PutUint32(body[24:], 0x0012019f)
+	// share read, write and delete
+	binary.LittleEndian.PutUint32(body[32:], 0x00000007)
+	// open existing
+	binary.LittleEndian.PutUint32(body[36:], 0x00000001)
+	binary.LittleEndian.PutUint16(body[44:], SMB2HeaderSize+56)
+	binary.LittleEndian.PutUint16(body[46:], uint16(len(name)))
+	copy(body[56:], name)
+	return body
+}
+
+// ParseSMB2CreateResponse returns the identifier of the opened file.
+func ParseSMB2CreateResponse(raw []byte) ([]byte, error) {
+	if len(raw) < SMB2HeaderSize+80 {
+		return nil, ErrSMBShort
+	}
+	return raw[SMB2HeaderSize+64 : SMB2HeaderSize+80], nil
+}
+
+// SMB2CloseBody builds a CLOSE request for fileID.
+func SMB2CloseBody(fileID []byte) []byte {
+	body := make([]byte, 24)
+	binary.LittleEndian.PutUint16(body[0:], 24)
+	copy(body[8:], fileID)
+	return body
+}
+
+// SMB2TransceiveBody builds an IOCTL request writing input to a named pipe
+// and reading its answer in the same round trip.
+func SMB2TransceiveBody(fileID []byte, input []byte, maxOutput uint32) []byte {
+	body := make([]byte, 56+len(input))
+	binary.LittleEndian.PutUint16(body[0:], 57)
+	binary.LittleEndian.PutUint32(body[4:], SMB2FsctlPipeTransceive)
+	copy(body[8:], fileID)
+	binary.LittleEndian.PutUint32(body[24:], SMB2HeaderSize+56)
+	binary.LittleEndian.PutUint32(body[28:], uint32(len(input)))
+	binary.LittleEndian.PutUint32(body[44:], maxOutput)
+	// this is an fsctl
+	binary.LittleEndian.PutUint32(body[48:], 1)
+	copy(body[56:], input)
+	return body
+}
+
+// ParseSMB2IoctlResponse returns the output buffer of an IOCTL response.
+func ParseSMB2IoctlResponse(raw []byte) ([]byte, error) {
+	if len(raw) < SMB2HeaderSize+48 {
+		return nil, ErrSMBShort
+	}
+
+	body := raw[SMB2HeaderSize:]
+	return smbBuffer(raw, int(binary.LittleEndian.Uint32(body[32:])), int(binary.LittleEndian.Uint32(body[36:])))
+}
+
+// SMB2ReadBody builds a READ request of size bytes from fileID.
+func SMB2ReadBody(fileID []byte, size uint32) []byte {
+	body := make([]byte, 49)
+	binary.LittleEndian.PutUint16(body[0:], 49)
+	body[2] = 0x50
+	binary.LittleEndian.PutUint32(body[4:], size)
+	copy(body[16:], fileID)
+	return body
+}
+
+// ParseSMB2ReadResponse returns the data of a READ response.
+func ParseSMB2ReadResponse(raw []byte) ([]byte, error) {
+	if len(raw) < SMB2HeaderSize+16 {
+		return nil, ErrSMBShort
+	}
+
+	body := raw[SMB2HeaderSize:]
+	return smbBuffer(raw, int(body[2]), int(binary.LittleEndian.Uint32(body[4:])))
+}
+
+// SMB1NegotiateRequest returns an SMB1 NEGOTIATE message offering
+// the NT LM 0.12 dialect only, used to tell if SMB1 is enabled.
+func SMB1NegotiateRequest() []byte {
+	dialect := append([]byte{0x02}, []byte("NT LM 0.12\x00")...)
+	raw := make([]byte, 32+3+len(dialect))
+	copy(raw[0:4], smb1Magic)
+	// negotiate
+	raw[4] = 0x72
+	// case insensitive, canonicalized paths
+	raw[9] = 0x18
+	// unicode, nt status, extended security, long names
+	binary.LittleEndian.PutUint16(raw[10:], 0xc801)
+	binary.LittleEndian.PutUint16(raw[26:], 0xfeff)
+	binary.LittleEndian.PutUint16(raw[33:], uint16(len(dialect)))
+	copy(raw[35:], dialect)
+	return raw
+}
+
+// SMB1NegotiateAccepted returns true if raw is an SMB1 NEGOTIATE
+// response selecting one of the dialects we offered.
+func SMB1NegotiateAccepted(raw []byte) bool {
+	if len(raw) < 35 || string(raw[0:4]) != string(smb1Magic) || raw[4] != 0x72 {
+		return false
+	} else if status := binary.LittleEndian.Uint32(raw[5:]); status != 0 {
+		return false
+	}
+	return raw[32] > 0 && binary.LittleEndian.Uint16(raw[33:]) != 0xffff
+}
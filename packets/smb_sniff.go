@@ -0,0 +1,201 @@
+package packets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	SMB1Negotiate = 0x72
+
+	smb1HeaderSize       = 32
+	smb1FlagReply        = 0x80
+	smb1SigningEnabled   = 0x04
+	smb1SigningRequired  = 0x08
+	smbNetBIOSHeaderSize = 4
+)
+
+var smb2TransformMagic = []byte{0xfd, 'S', 'M', 'B'}
+
+// SMBMessages splits a chunk of an SMB session in the messages of its
+// NetBIOS frames, the last one might be truncated.
+func SMBMessages(data []byte) [][]byte {
+	msgs := make([][]byte, 0)
+	for len(data) > smbNetBIOSHeaderSize && data[0] == 0x00 {
+		size := int(binary.BigEndian.Uint32(data) & 0x00ffffff)
+		data = data[smbNetBIOSHeaderSize:]
+		if size > len(data) {
+			size = len(data)
+		}
+
+		if msg := data[:size]; len(msg) >= 4 && (IsSMB1(msg) || IsSMB2(msg) || IsSMB2Encrypted(msg)) {
+			msgs = append(msgs, msg)
+		}
+		data = data[size:]
+	}
+	return msgs
+}
+
+func IsSMB1(msg []byte) bool {
+	return len(msg) >= 4 && bytes.Equal(msg[:4], smb1Magic)
+}
+
+func IsSMB2(msg []byte) bool {
+	return len(msg) >= 4 && bytes.Equal(msg[:4], smb2Magic)
+}
+
+// IsSMB2Encrypted returns true if msg is wrapped in an SMB 3 transform header.
+func IsSMB2Encrypted(msg []byte) bool {
+	return len(msg) >= 4 && bytes.Equal(msg[:4], smb2TransformMagic)
+}
+
+// SMB2Compound splits a compound SMB2 message in its chained ones.
+func SMB2Compound(msg []byte) [][]byte {
+	msgs := make([][]byte, 0)
+	for {
+		h, err := ParseSMB2Header(msg)
+		if err != nil {
+			break
+		}
+
+		next := int(h.NextCommand)
+		if next == 0 || next < SMB2HeaderSize || next > len(msg) {
+			msgs = append(msgs, msg)
+			break
+		}
+		msgs = append(msgs, msg[:next])
+		msg = msg[next:]
+	}
+	return msgs
+}
+
+// ParseSMB2NegotiateRequest returns the security mode and the dialects
+// offered by a client.
+func ParseSMB2NegotiateRequest(raw []byte) (uint16, []uint16, error) {
+	if len(raw) < SMB2HeaderSize+36 {
+		return 0, nil, ErrSMBShort
+	}
+
+	body := raw[SMB2HeaderSize:]
+	count := int(binary.LittleEndian.Uint16(body[2:]))
+	if len(body) < 36+2*count {
+		return 0, nil, ErrSMBShort
+	}
+
+	dialects := make([]uint16, count)
+	for i := range dialects {
+		dialects[i] = binary.LittleEndian.Uint16(body[36+2*i:])
+	}
+	return binary.LittleEndian.Uint16(body[4:]), dialects, nil
+}
+
+// ParseSMB2TreeConnectRequest returns the \\server\share path a client is
+// connecting to.
+func ParseSMB2TreeConnectRequest(raw []byte) (string, error) {
+	if len(raw) < SMB2HeaderSize+8 {
+		return "", ErrSMBShort
+	}
+
+	body := raw[SMB2HeaderSize:]
+	path, err := smbBuffer(raw, int(binary.LittleEndian.Uint16(body[4:])), int(binary.LittleEndian.Uint16(body[6:])))
+	if err != nil {
+		return "", err
+	}
+	return smbString(path), nil
+}
+
+// ParseSMB2CreateRequest returns the name of the file or pipe a client is
+// opening, relative to the share, empty for its root.
+func ParseSMB2CreateRequest(raw []byte) (string, error) {
+	if len(raw) < SMB2HeaderSize+56 {
+		return "", ErrSMBShort
+	}
+
+	body := raw[SMB2HeaderSize:]
+	name, err := smbBuffer(raw, int(binary.LittleEndian.Uint16(body[44:])), int(binary.LittleEndian.Uint16(body[46:])))
+	if err != nil {
+		return "", err
+	}
+	return smbString(name), nil
+}
+
+// ParseSMB1NegotiateRequest returns the dialects offered by an SMB1 client,
+// the ones of SMB2 capable clients are included.
+func ParseSMB1NegotiateRequest(raw []byte) ([]string, error) {
+	if len(raw) < smb1HeaderSize+3 {
+		return nil, ErrSMBShort
+	} else if !IsSMB1(raw) || raw[4] != SMB1Negotiate || raw[9]&smb1FlagReply != 0 {
+		return nil, fmt.Errorf("not an smb1 negotiate request")
+	}
+
+	offset := smb1HeaderSize + 1 + 2*int(raw[smb1HeaderSize])
+	if len(raw) < offset+2 {
+		return nil, ErrSMBShort
+	}
+
+	size := int(binary.LittleEndian.Uint16(raw[offset:]))
+	data := raw[offset+2:]
+	if size < len(data) {
+		data = data[:size]
+	}
+
+	dialects := make([]string, 0)
+	for _, dialect := range bytes.Split(data, []byte{0x00}) {
+		// every dialect is prefixed by the 0x02 buffer format
+		if len(dialect) > 1 && dialect[0] == 0x02 {
+			dialects = append(dialects, string(dialect[1:]))
+		}
+	}
+	return dialects, nil
+}
+
+// SMB1Signing returns the signing status of an SMB1 NEGOTIATE response.
+func SMB1Signing(raw []byte) (string, error) {
+	if !SMB1NegotiateAccepted(raw) || raw[9]&smb1FlagReply == 0 {
+		return "", fmt.Errorf("not an smb1 negotiate response")
+	} else if len(raw) < smb1HeaderSize+4 {
+		return "", ErrSMBShort
+	}
+
+	mode := raw[smb1HeaderSize+3]
+	if mode&smb1SigningRequired != 0 {
+		return "required", nil
+	} else if mode&smb1SigningEnabled != 0 {
+		return "enabled", nil
+	}
+	return "disabled", nil
+}
+
+// SMBIncomplete returns true if the last NetBIOS frame of a chunk of an SMB
+// session is truncated.
+func SMBIncomplete(data []byte) bool {
+	for len(data) >= smbNetBIOSHeaderSize && data[0] == 0x00 {
+		size := int(binary.BigEndian.Uint32(data) & 0x00ffffff)
+		if size > len(data)-smbNetBIOSHeaderSize {
+			return true
+		}
+		data = data[smbNetBIOSHeaderSize+size:]
+	}
+	return len(data) > 0 && len(data) < smbNetBIOSHeaderSize && data[0] == 0x00
+}
+
+// ParseSMB2ReadRequest returns the identifier of the file a client is
+// reading and the offset it's reading from.
+func ParseSMB2ReadRequest(raw []byte) ([]byte, uint64, error) {
+	if len(raw) < SMB2HeaderSize+48 {
+		return nil, 0, ErrSMBShort
+	}
+
+	body := raw[SMB2HeaderSize:]
+	return body[16:32], binary.LittleEndian.Uint64(body[8:]), nil
+}
+
+// ParseSMB2CloseRequest returns the identifier of the file a client is
+// closing.
+func ParseSMB2CloseRequest(raw []byte) ([]byte, error) {
+	if len(raw) < SMB2HeaderSize+24 {
+		return nil, ErrSMBShort
+	}
+	return raw[SMB2HeaderSize+8 : SMB2HeaderSize+24], nil
+}
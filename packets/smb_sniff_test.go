@@ -0,0 +1,163 @@
+package packets
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestSMBMessages(t *testing.T) {
+	first := SMB2Header{Command: SMB2Negotiate}.Message(nil)
+	second := SMB1NegotiateRequest()
+
+	data := append(SMBFrame(first), SMBFrame(second)...)
+	// garbage and a truncated frame
+	data = append(data, SMBFrame([]byte("nope"))...)
+	data = append(data, SMBFrame(first)[:20]...)
+
+	msgs := SMBMessages(data)
+	if len(msgs) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(msgs))
+	} else if !IsSMB2(msgs[0]) || !IsSMB1(msgs[1]) || len(msgs[2]) != 16 {
+		t.Fatalf("unexpected messages %x", msgs)
+	}
+}
+
+func TestSMB2Compound(t *testing.T) {
+	first := SMB2Header{Command: SMB2Create, NextCommand: SMB2HeaderSize + 8}.Message(make([]byte, 8))
+	second := SMB2Header{Command: SMB2Close}.Message(make([]byte, 24))
+
+	msgs := SMB2Compound(append(first, second...))
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	} else if h, _ := ParseSMB2Header(msgs[1]); h.Command != SMB2Close {
+		t.Fatalf("unexpected command %d", h.Command)
+	}
+}
+
+func TestParseSMB2NegotiateRequest(t *testing.T) {
+	raw := SMB2Header{Command: SMB2Negotiate}.Message(SMB2NegotiateBody(SMB2Dialects))
+	mode, dialects, err := ParseSMB2NegotiateRequest(raw)
+	if err != nil {
+		t.Fatal(err)
+	} else if mode != SMB2SigningEnabled {
+		t.Fatalf("unexpected security mode %d", mode)
+	} else if !reflect.DeepEqual(dialects, SMB2Dialects) {
+		t.Fatalf("expected '%v', got '%v'", SMB2Dialects, dialects)
+	}
+
+	if _, _, err = ParseSMB2NegotiateRequest(raw[:SMB2HeaderSize+10]); err == nil {
+		t.Fatal("expected error for short message")
+	}
+}
+
+func TestParseSMB2NegotiateResponseCiphers(t *testing.T) {
+	body := make([]byte, 64+8+4)
+	binary.LittleEndian.PutUint16(body[0:], 65)
+	binary.LittleEndian.PutUint16(body[4:], SMB2Dialect311)
+	binary.LittleEndian.PutUint16(body[6:], 1)
+	binary.LittleEndian.PutUint32(body[60:], SMB2HeaderSize+64)
+	binary.LittleEndian.PutUint16(body[64:], smb2EncryptionContext)
+	binary.LittleEndian.PutUint16(body[66:], 4)
+	binary.LittleEndian.PutUint16(body[72:], 1)
+	binary.LittleEndian.PutUint16(body[74:], SMB2CipherAES128GCM)
+
+	res, err := ParseSMB2NegotiateResponse(SMB2Header{Command: SMB2Negotiate, Flags: SMB2FlagResponse}.Message(body))
+	if err != nil {
+		t.Fatal(err)
+	} else if res.Encryption() != "AES-128-GCM" {
+		t.Fatalf("unexpected encryption %s", res.Encryption())
+	}
+
+	res = &SMB2NegotiateResponse{Dialect: SMB2Dialect300, Capabilities: SMB2CapEncryption}
+	if res.Encryption() != "supported" {
+		t.Fatalf("unexpected encryption %s", res.Encryption())
+	} else if res.Capabilities = 0; res.Encryption() != "disabled" {
+		t.Fatalf("unexpected encryption %s", res.Encryption())
+	}
+}
+
+func TestParseSMB2TreeConnectRequest(t *testing.T) {
+	raw := SMB2Header{Command: SMB2TreeConnect}.Message(SMB2TreeConnectBody(`\\server\share`))
+	if path, err := ParseSMB2TreeConnectRequest(raw); err != nil {
+		t.Fatal(err)
+	} else if path != `\\server\share` {
+		t.Fatalf("unexpected path %s", path)
+	}
+}
+
+func TestParseSMB2CreateRequest(t *testing.T) {
+	raw := SMB2Header{Command: SMB2Create}.Message(SMB2CreatePipeBody("srvsvc"))
+	if name, err := ParseSMB2CreateRequest(raw); err != nil {
+		t.Fatal(err)
+	} else if name != "srvsvc" {
+		t.Fatalf("unexpected name %s", name)
+	}
+}
+
+func TestParseSMB1NegotiateRequest(t *testing.T) {
+	dialects, err := ParseSMB1NegotiateRequest(SMB1NegotiateRequest())
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(dialects, []string{"NT LM 0.12"}) {
+		t.Fatalf("unexpected dialects %v", dialects)
+	}
+}
+
+func TestSMB1Signing(t *testing.T) {
+	res := make([]byte, 36)
+	copy(res, SMB1NegotiateRequest()[:32])
+	res[9] |= smb1FlagReply
+	res[32] = 17
+	res[35] = smb1SigningEnabled | smb1SigningRequired
+
+	if signing, err := SMB1Signing(res); err != nil {
+		t.Fatal(err)
+	} else if signing != "required" {
+		t.Fatalf("unexpected signing %s", signing)
+	}
+
+	if _, err := SMB1Signing(SMB1NegotiateRequest()); err == nil {
+		t.Fatal("expected error for a request")
+	}
+}
+
+func TestSMBIncomplete(t *testing.T) {
+	frame := SMBFrame(SMB2Header{Command: SMB2Negotiate}.Message(nil))
+	data := append(append([]byte{}, frame...), frame...)
+
+	if SMBIncomplete(data) {
+		t.Fatal("unexpected incomplete frames")
+	} else if !SMBIncomplete(data[:len(data)-1]) {
+		t.Fatal("expected the last frame to be incomplete")
+	} else if !SMBIncomplete(data[:len(frame)+2]) {
+		t.Fatal("expected the last header to be incomplete")
+	}
+}
+
+func TestParseSMB2ReadRequest(t *testing.T) {
+	fileID := []byte("0123456789abcdef")
+	body := SMB2ReadBody(fileID, 1024)
+	binary.LittleEndian.PutUint64(body[8:], 4096)
+
+	raw := SMB2Header{Command: SMB2Read}.Message(body)
+	if id, offset, err := ParseSMB2ReadRequest(raw); err != nil {
+		t.Fatal(err)
+	} else if string(id) != string(fileID) || offset != 4096 {
+		t.Fatalf("unexpected file %x and offset %d", id, offset)
+	}
+
+	if _, _, err := ParseSMB2ReadRequest(raw[:SMB2HeaderSize+20]); err == nil {
+		t.Fatal("expected error for short message")
+	}
+}
+
+func TestParseSMB2CloseRequest(t *testing.T) {
+	fileID := []byte("0123456789abcdef")
+	raw := SMB2Header{Command: SMB2Close}.Message(SMB2CloseBody(fileID))
+	if id, err := ParseSMB2CloseRequest(raw); err != nil {
+		t.Fatal(err)
+	} else if string(id) != string(fileID) {
+		t.Fatalf("unexpected file %x", id)
+	}
+}
@@ -0,0 +1,259 @@
+package packets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSMB2Header(t *testing.T) {
+	h := SMB2Header{
+		Command:   SMB2TreeConnect,
+		Status:    SMB2StatusAccessDenied,
+		MessageID: 7,
+		TreeID:    3,
+		SessionID: 0x1122334455667788,
+	}
+
+	raw := h.Message([]byte{1, 2, 3})
+	if len(raw) != SMB2HeaderSize+3 {
+		t.Fatalf("unexpected message size %d", len(raw))
+	}
+
+	parsed, err := ParseSMB2Header(raw)
+	if err != nil {
+		t.Fatal(err)
+	} else if *parsed != h {
+		t.Fatalf("expected '%+v', got '%+v'", h, *parsed)
+	}
+
+	if _, err = ParseSMB2Header(raw[:10]); err == nil {
+		t.Fatal("expected error for short message")
+	}
+}
+
+func TestSMBFrame(t *testing.T) {
+	frame := SMBFrame([]byte{0xaa, 0xbb})
+	exp := []byte{0x00, 0x00, 0x00, 0x02, 0xaa, 0xbb}
+	if !bytes.Equal(frame, exp) {
+		t.Fatalf("expected '%x', got '%x'", exp, frame)
+	}
+}
+
+func TestSMB2NegotiateBody(t *testing.T) {
+	body := SMB2NegotiateBody([]uint16{SMB2Dialect202, SMB2Dialect210})
+	if len(body) != 40 {
+		t.Fatalf("unexpected body size %d", len(body))
+	} else if count := binary.LittleEndian.Uint16(body[2:]); count != 2 {
+		t.Fatalf("unexpected dialects count %d", count)
+	} else if offset := binary.LittleEndian.Uint32(body[28:]); offset != 0 {
+		t.Fatalf("unexpected contexts offset %d", offset)
+	}
+
+	body = SMB2NegotiateBody(SMB2Dialects)
+	offset := int(binary.LittleEndian.Uint32(body[28:]))
+	if offset%8 != 0 {
+		t.Fatalf("negotiate contexts at %d are not aligned", offset)
+	} else if count := binary.LittleEndian.Uint16(body[32:]); count != 1 {
+		t.Fatalf("unexpected contexts count %d", count)
+	} else if ctx := binary.LittleEndian.Uint16(body[offset-SMB2HeaderSize:]); ctx != smb2PreauthIntegrityContext {
+		t.Fatalf("unexpected context type %d", ctx)
+	}
+}
+
+func TestParseSMB2NegotiateResponse(t *testing.T) {
+	body := make([]byte, 64+4)
+	binary.LittleEndian.PutUint16(body[0:], 65)
+	binary.LittleEndian.PutUint16(body[2:], SMB2SigningEnabled|SMB2SigningRequired)
+	binary.LittleEndian.PutUint16(body[4:], SMB2Dialect302)
+	binary.LittleEndian.PutUint16(body[56:], SMB2HeaderSize+64)
+	binary.LittleEndian.PutUint16(body[58:], 4)
+	copy(body[64:], []byte{0xde, 0xad, 0xbe, 0xef})
+
+	raw := SMB2Header{Command: SMB2Negotiate, Flags: SMB2FlagResponse}.Message(body)
+	res, err := ParseSMB2NegotiateResponse(raw)
+	if err != nil {
+		t.Fatal(err)
+	} else if res.Dialect != SMB2Dialect302 {
+		t.Fatalf("unexpected dialect %s", SMBDialectName(res.Dialect))
+	} else if res.Signing() != "required" {
+		t.Fatalf("unexpected signing %s", res.Signing())
+	} else if !bytes.Equal(res.SecurityBlob, body[64:]) {
+		t.Fatalf("unexpected security blob %x", res.SecurityBlob)
+	}
+
+	// security buffer out of bounds
+	binary.LittleEndian.PutUint16(body[58:], 100)
+	if _, err = ParseSMB2NegotiateResponse(SMB2Header{}.Message(body)); err == nil {
+		t.Fatal("expected error for invalid security buffer")
+	}
+}
+
+func TestSMB1NegotiateAccepted(t *testing.T) {
+	req := SMB1NegotiateRequest()
+	if SMB1NegotiateAccepted(req) {
+		t.Fatal("a request with no words should not be accepted")
+	}
+
+	res := make([]byte, 35)
+	copy(res, req[:32])
+	res[32] = 17
+	if !SMB1NegotiateAccepted(res) {
+		t.Fatal("expected dialect to be accepted")
+	}
+
+	binary.LittleEndian.PutUint16(res[33:], 0xffff)
+	if SMB1NegotiateAccepted(res) {
+		t.Fatal("expected dialect to be rejected")
+	}
+}
+
+func buildNTLMChallenge() []byte {
+	avPair := func(id uint16, value string) []byte {
+		encoded := smbUTF16(value)
+		raw := make([]byte, 4)
+		binary.LittleEndian.PutUint16(raw[0:], id)
+		binary.LittleEndian.PutUint16(raw[2:], uint16(len(encoded)))
+		return append(raw, encoded...)
+	}
+
+	target := smbUTF16("CORP")
+	info := avPair(ntlmAvNbDomainName, "CORP")
+	info = append(info, avPair(ntlmAvNbComputerName, "FILESRV")...)
+	info = append(info, avPair(ntlmAvDnsDomainName, "corp.local")...)
+	info = append(info, 0, 0, 0, 0)
+
+	msg := make([]byte, 56)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 2)
+	binary.LittleEndian.PutUint16(msg[12:], uint16(len(target)))
+	binary.LittleEndian.PutUint32(msg[16:], 56)
+	binary.LittleEndian.PutUint32(msg[20:], NTLMNegotiateClientFlags)
+	binary.LittleEndian.PutUint16(msg[40:], uint16(len(info)))
+	binary.LittleEndian.PutUint32(msg[44:], uint32(56+len(target)))
+	msg[48] = 10
+	binary.LittleEndian.PutUint16(msg[50:], 19041)
+	msg = append(msg, target...)
+	return append(msg, info...)
+}
+
+func TestParseNTLMChallenge(t *testing.T) {
+	blob := SPNEGOResponse(buildNTLMChallenge())
+	c, err := ParseNTLMChallenge(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var units = []struct {
+		got string
+		exp string
+	}{
+		{c.TargetName, "CORP"},
+		{c.NbDomainName, "CORP"},
+		{c.NbComputerName, "FILESRV"},
+		{c.DnsDomain, "corp.local"},
+		{c.DnsComputer, ""},
+		{c.OS(), "Windows 10 / Server 2016 (build 19041)"},
+	}
+	for _, u := range units {
+		if u.got != u.exp {
+			t.Fatalf("expected '%s', got '%s'", u.exp, u.got)
+		}
+	}
+
+	if _, err = ParseNTLMChallenge(NTLMNegotiateMessage()); err == nil {
+		t.Fatal("expected error for a negotiate message")
+	}
+}
+
+func TestNTLMAnonymousMessage(t *testing.T) {
+	msg := NTLMAnonymousMessage()
+	if lm, err := ntlmField(msg, 12); err != nil || !bytes.Equal(lm, []byte{0}) {
+		t.Fatalf("unexpected lm response %x (%v)", lm, err)
+	}
+	for offset := 20; offset < 60; offset += 8 {
+		if field, err := ntlmField(msg, offset); err != nil || len(field) != 0 {
+			t.Fatalf("unexpected field at %d: %x (%v)", offset, field, err)
+		}
+	}
+	if flags := binary.LittleEndian.Uint32(msg[60:]); flags&NTLMNegotiateAnonymous == 0 {
+		t.Fatalf("anonymous flag not set: %08x", flags)
+	}
+}
+
+func TestDCERPCBind(t *testing.T) {
+	raw := NewDCERPCBind(1, SRVSVCSyntax)
+	h, err := ParseDCERPCHeader(raw)
+	if err != nil {
+		t.Fatal(err)
+	} else if h.Type != DCERPCBind || !h.Last() || int(h.FragLength) != len(raw) {
+		t.Fatalf("unexpected header %+v", h)
+	}
+
+	exp, _ := hex.DecodeString("c84f324b7016d30112785a47bf6ee18803000000")
+	if !bytes.Contains(raw, exp) {
+		t.Fatalf("srvsvc syntax not found in %x", raw)
+	}
+}
+
+func buildShareEnumStub(shares []SMBShare) []byte {
+	u32 := func(v uint32) []byte {
+		raw := make([]byte, 4)
+		binary.LittleEndian.PutUint32(raw, v)
+		return raw
+	}
+
+	stub := append(u32(1), u32(1)...)
+	stub = append(stub, u32(0x00020000)...)
+	stub = append(stub, u32(uint32(len(shares)))...)
+	stub = append(stub, u32(0x00020004)...)
+	stub = append(stub, u32(uint32(len(shares)))...)
+	for i, s := range shares {
+		stub = append(stub, u32(uint32(0x00020008+8*i))...)
+		stub = append(stub, u32(s.Type)...)
+		stub = append(stub, u32(uint32(0x0002000c+8*i))...)
+	}
+	for _, s := range shares {
+		stub = append(stub, ndrString(s.Name)...)
+		stub = append(stub, ndrString(s.Remark)...)
+	}
+	stub = append(stub, u32(uint32(len(shares)))...)
+	stub = append(stub, u32(0)...)
+	return append(stub, u32(0)...)
+}
+
+func TestParseSRVSVCNetShareEnumAll(t *testing.T) {
+	exp := []SMBShare{
+		{Name: "ADMIN$", Type: STypeDisk | STypeSpecial, Remark: "Remote Admin"},
+		{Name: "IPC$", Type: STypeIPC | STypeSpecial, Remark: "Remote IPC"},
+		{Name: "public", Type: STypeDisk, Remark: ""},
+	}
+
+	shares, err := ParseSRVSVCNetShareEnumAll(buildShareEnumStub(exp))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(shares) != len(exp) {
+		t.Fatalf("expected %d shares, got %d", len(exp), len(shares))
+	}
+
+	for i, s := range shares {
+		if s != exp[i] {
+			t.Fatalf("expected '%+v', got '%+v'", exp[i], s)
+		}
+	}
+
+	if !shares[2].IsDisk() || shares[1].TypeName() != "ipc" {
+		t.Fatalf("unexpected share types")
+	}
+
+	stub := buildShareEnumStub(exp)
+	binary.LittleEndian.PutUint32(stub[len(stub)-4:], 5)
+	if _, err = ParseSRVSVCNetShareEnumAll(stub); err == nil {
+		t.Fatal("expected error for access denied")
+	}
+
+	if _, err = ParseSRVSVCNetShareEnumAll(buildShareEnumStub(exp)[:40]); err == nil {
+		t.Fatal("expected error for truncated stub")
+	}
+}
@@ -0,0 +1,148 @@
+package packets
+
+import (
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	SNMPPort = 161
+
+	SNMPVersion1  = 0
+	SNMPVersion2c = 1
+
+	SNMPGetRequest     = 0xa0
+	SNMPGetNextRequest = 0xa1
+	SNMPGetResponse    = 0xa2
+)
+
+var (
+	SNMPOidSysDescr    = asn1.ObjectIdentifier{1, 3, 6, 1, 2, 1, 1, 1, 0}
+	SNMPOidSysObjectID = asn1.ObjectIdentifier{1, 3, 6, 1, 2, 1, 1, 2, 0}
+	SNMPOidSysContact  = asn1.ObjectIdentifier{1, 3, 6, 1, 2, 1, 1, 4, 0}
+	SNMPOidSysName     = asn1.ObjectIdentifier{1, 3, 6, 1, 2, 1, 1, 5, 0}
+	SNMPOidSysLocation = asn1.ObjectIdentifier{1, 3, 6, 1, 2, 1, 1, 6, 0}
+	SNMPOidIfDescr     = asn1.ObjectIdentifier{1, 3, 6, 1, 2, 1, 2, 2, 1, 2}
+)
+
+type SNMPVarBind struct {
+	Name  asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+type snmpPDU struct {
+	RequestID   int
+	ErrorStatus int
+	ErrorIndex  int
+	VarBinds    []SNMPVarBind
+}
+
+type snmpMessage struct {
+	Version   int
+	Community []byte
+	PDU       asn1.RawValue
+}
+
+type SNMPResponse struct {
+	Version     int
+	Community   string
+	RequestID   int
+	ErrorStatus int
+	VarBinds    []SNMPVarBind
+}
+
+// NewSNMPRequest builds a v1 or v2c GetRequest or GetNextRequest for the given oids.
+func NewSNMPRequest(version int, community string, pduType byte, requestID int, oids ...asn1.ObjectIdentifier) ([]byte, error) {
+	pdu := snmpPDU{
+		RequestID: requestID,
+		VarBinds:  make([]SNMPVarBind, 0, len(oids)),
+	}
+
+	for _, oid := range oids {
+		pdu.VarBinds = append(pdu.VarBinds, SNMPVarBind{
+			Name:  oid,
+			Value: asn1.NullRawValue,
+		})
+	}
+
+	rawPDU, err := asn1.Marshal(pdu)
+	if err != nil {
+		return nil, err
+	}
+	// PDUs are context specific constructed types, not plain sequences
+	rawPDU[0] = pduType
+
+	return asn1.Marshal(snmpMessage{
+		Version:   version,
+		Community: []byte(community),
+		PDU:       asn1.RawValue{FullBytes: rawPDU},
+	})
+}
+
+func ParseSNMPResponse(raw []byte) (*SNMPResponse, error) {
+	msg := snmpMessage{}
+	if _, err := asn1.Unmarshal(raw, &msg); err != nil {
+		return nil, err
+	} else if len(msg.PDU.FullBytes) == 0 || msg.PDU.FullBytes[0] != SNMPGetResponse {
+		return nil, fmt.Errorf("unexpected snmp pdu")
+	}
+
+	rawPDU := make([]byte, len(msg.PDU.FullBytes))
+	copy(rawPDU, msg.PDU.FullBytes)
+	rawPDU[0] = 0x30
+
+	pdu := snmpPDU{}
+	if _, err := asn1.Unmarshal(rawPDU, &pdu); err != nil {
+		return nil, err
+	}
+
+	return &SNMPResponse{
+		Version:     msg.Version,
+		Community:   string(msg.Community),
+		RequestID:   pdu.RequestID,
+		ErrorStatus: pdu.ErrorStatus,
+		VarBinds:    pdu.VarBinds,
+	}, nil
+}
+
+// IsEmpty returns true if the agent answered with a
+// noSuchObject, noSuchInstance, endOfMibView or null value.
+func (v SNMPVarBind) IsEmpty() bool {
+	return v.Value.Class == asn1.ClassContextSpecific ||
+		(v.Value.Class == asn1.ClassUniversal && v.Value.Tag == asn1.TagNull)
+}
+
+func (v SNMPVarBind) String() string {
+	switch v.Value.Class {
+	case asn1.ClassUniversal:
+		switch v.Value.Tag {
+		case asn1.TagOctetString:
+			return strings.TrimRight(string(v.Value.Bytes), "\x00")
+		case asn1.TagInteger:
+			var n int64
+			if _, err := asn1.Unmarshal(v.Value.FullBytes, &n); err == nil {
+				return fmt.Sprintf("%d", n)
+			}
+		case asn1.TagOID:
+			var oid asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(v.Value.FullBytes, &oid); err == nil {
+				return oid.String()
+			}
+		}
+	case asn1.ClassApplication:
+		// IpAddress
+		if v.Value.Tag == 0 && len(v.Value.Bytes) == 4 {
+			return net.IP(v.Value.Bytes).String()
+		}
+		// Counter32, Gauge32, TimeTicks, Counter64
+		if len(v.Value.Bytes) <= 8 {
+			buf := make([]byte, 8)
+			copy(buf[8-len(v.Value.Bytes):], v.Value.Bytes)
+			return fmt.Sprintf("%d", binary.BigEndian.Uint64(buf))
+		}
+	}
+	return fmt.Sprintf("%x", v.Value.Bytes)
+}
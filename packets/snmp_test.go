@@ -0,0 +1,71 @@
+package packets
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"testing"
+)
+
+func TestNewSNMPRequest(t *testing.T) {
+	raw, err := NewSNMPRequest(SNMPVersion2c, "public", SNMPGetRequest, 1, SNMPOidSysDescr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []byte{
+		0x30, 0x26,
+		0x02, 0x01, 0x01,
+		0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c',
+		0xa0, 0x19,
+		0x02, 0x01, 0x01,
+		0x02, 0x01, 0x00,
+		0x02, 0x01, 0x00,
+		0x30, 0x0e, 0x30, 0x0c,
+		0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00,
+		0x05, 0x00,
+	}
+
+	if !bytes.Equal(raw, exp) {
+		t.Fatalf("expected '%x', got '%x'", exp, raw)
+	}
+}
+
+func TestParseSNMPResponse(t *testing.T) {
+	pdu, err := asn1.Marshal(snmpPDU{
+		RequestID: 42,
+		VarBinds: []SNMPVarBind{
+			{
+				Name:  SNMPOidSysName,
+				Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagOctetString, Bytes: []byte("router")},
+			},
+			{
+				Name:  SNMPOidSysLocation,
+				Value: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdu[0] = SNMPGetResponse
+
+	raw, err := asn1.Marshal(snmpMessage{
+		Version:   SNMPVersion1,
+		Community: []byte("private"),
+		PDU:       asn1.RawValue{FullBytes: pdu},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ParseSNMPResponse(raw)
+	if err != nil {
+		t.Fatal(err)
+	} else if res.RequestID != 42 || res.Community != "private" || len(res.VarBinds) != 2 {
+		t.Fatalf("unexpected response %+v", res)
+	} else if got := res.VarBinds[0].String(); got != "router" {
+		t.Fatalf("expected 'router', got '%s'", got)
+	} else if !res.VarBinds[1].IsEmpty() {
+		t.Fatal("expected empty value")
+	}
+}
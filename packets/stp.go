@@ -0,0 +1,153 @@
+package packets
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	STPTypeConfig = 0x00
+	STPTypeTCN    = 0x80
+	STPTypeRST    = 0x02
+
+	STPFlagTopologyChange    = 0x01
+	STPFlagTopologyChangeAck = 0x80
+
+	// port role designated, learning and forwarding
+	STPFlagsRSTDesignated = 0x3c
+
+	stpConfigSize = 35
+)
+
+var (
+	STPDestMac = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x00}
+)
+
+// STPBridgeID identifies a bridge by its priority and MAC address, the
+// lowest one is elected root of the spanning tree.
+type STPBridgeID struct {
+	Priority uint16
+	HW       net.HardwareAddr
+}
+
+func (id STPBridgeID) String() string {
+	return fmt.Sprintf("%d.%s", id.Priority, id.HW)
+}
+
+// Less returns true if id would win the root election against other.
+func (id STPBridgeID) Less(other STPBridgeID) bool {
+	if id.Priority != other.Priority {
+		return id.Priority < other.Priority
+	}
+	for i := range id.HW {
+		if id.HW[i] != other.HW[i] {
+			return id.HW[i] < other.HW[i]
+		}
+	}
+	return false
+}
+
+func parseSTPBridgeID(data []byte) STPBridgeID {
+	return STPBridgeID{
+		Priority: binary.BigEndian.Uint16(data),
+		HW:       net.HardwareAddr(append([]byte{}, data[2:8]...)),
+	}
+}
+
+func (id STPBridgeID) put(data []byte) {
+	binary.BigEndian.PutUint16(data, id.Priority)
+	copy(data[2:8], id.HW)
+}
+
+// STPBPDU is a configuration or rapid spanning tree BPDU, timers are
+// expressed in 1/256 of second.
+type STPBPDU struct {
+	Version      uint8
+	Type         uint8
+	Flags        uint8
+	RootID       STPBridgeID
+	RootPathCost uint32
+	BridgeID     STPBridgeID
+	PortID       uint16
+	MessageAge   uint16
+	MaxAge       uint16
+	HelloTime    uint16
+	ForwardDelay uint16
+}
+
+// ParseSTPBPDU parses the payload of a STP frame, topology change
+// notifications are returned with only their type set.
+func ParseSTPBPDU(data []byte) (*STPBPDU, error) {
+	if len(data) < 4 || binary.BigEndian.Uint16(data) != 0x0000 {
+		return nil, fmt.Errorf("invalid BPDU")
+	}
+
+	bpdu := &STPBPDU{
+		Version: data[2],
+		Type:    data[3],
+	}
+
+	if bpdu.Type == STPTypeTCN {
+		return bpdu, nil
+	} else if bpdu.Type != STPTypeConfig && bpdu.Type != STPTypeRST {
+		return nil, fmt.Errorf("unsupported BPDU type 0x%02x", bpdu.Type)
+	} else if len(data) < stpConfigSize {
+		return nil, fmt.Errorf("BPDU too short (%d bytes)", len(data))
+	}
+
+	bpdu.Flags = data[4]
+	bpdu.RootID = parseSTPBridgeID(data[5:])
+	bpdu.RootPathCost = binary.BigEndian.Uint32(data[13:])
+	bpdu.BridgeID = parseSTPBridgeID(data[17:])
+	bpdu.PortID = binary.BigEndian.Uint16(data[25:])
+	bpdu.MessageAge = binary.BigEndian.Uint16(data[27:])
+	bpdu.MaxAge = binary.BigEndian.Uint16(data[29:])
+	bpdu.HelloTime = binary.BigEndian.Uint16(data[31:])
+	bpdu.ForwardDelay = binary.BigEndian.Uint16(data[33:])
+
+	return bpdu, nil
+}
+
+// Serialize returns the BPDU as a configuration BPDU, or as a RST one
+// with its version 1 length field.
+func (bpdu *STPBPDU) Serialize() []byte {
+	size := stpConfigSize
+	if bpdu.Type == STPTypeRST {
+		size++
+	}
+
+	data := make([]byte, size)
+	data[2] = bpdu.Version
+	data[3] = bpdu.Type
+	data[4] = bpdu.Flags
+	bpdu.RootID.put(data[5:])
+	binary.BigEndian.PutUint32(data[13:], bpdu.RootPathCost)
+	bpdu.BridgeID.put(data[17:])
+	binary.BigEndian.PutUint16(data[25:], bpdu.PortID)
+	binary.BigEndian.PutUint16(data[27:], bpdu.MessageAge)
+	binary.BigEndian.PutUint16(data[29:], bpdu.MaxAge)
+	binary.BigEndian.PutUint16(data[31:], bpdu.HelloTime)
+	binary.BigEndian.PutUint16(data[33:], bpdu.ForwardDelay)
+	return data
+}
+
+// NewSTPBPDU returns an 802.3 frame carrying bpdu, sent to the bridges
+// group address.
+func NewSTPBPDU(srcHW net.HardwareAddr, bpdu *STPBPDU) (error, []byte) {
+	eth := layers.Ethernet{
+		SrcMAC:       srcHW,
+		DstMAC:       STPDestMac,
+		EthernetType: layers.EthernetTypeLLC,
+	}
+	llc := layers.LLC{
+		DSAP:    0x42,
+		SSAP:    0x42,
+		Control: 0x03,
+	}
+
+	return Serialize(&eth, &llc, gopacket.Payload(bpdu.Serialize()))
+}
@@ -0,0 +1,85 @@
+package packets
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestSTPBridgeIDLess(t *testing.T) {
+	a := STPBridgeID{Priority: 32768, HW: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}}
+	b := STPBridgeID{Priority: 32768, HW: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x56}}
+	c := STPBridgeID{Priority: 4096, HW: net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}}
+
+	if !a.Less(b) || b.Less(a) {
+		t.Fatal("expected the lowest MAC address to win")
+	} else if !c.Less(a) || a.Less(c) {
+		t.Fatal("expected the lowest priority to win")
+	} else if a.Less(a) {
+		t.Fatal("a bridge can't win against itself")
+	} else if a.String() != "32768.00:11:22:33:44:55" {
+		t.Fatalf("unexpected string '%s'", a.String())
+	}
+}
+
+func TestSTPBPDU(t *testing.T) {
+	hw := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	bpdu := &STPBPDU{
+		Version:      0,
+		Type:         STPTypeConfig,
+		Flags:        STPFlagTopologyChange,
+		RootID:       STPBridgeID{Priority: 0, HW: hw},
+		RootPathCost: 4,
+		BridgeID:     STPBridgeID{Priority: 32768, HW: hw},
+		PortID:       0x8001,
+		MessageAge:   1 * 256,
+		MaxAge:       20 * 256,
+		HelloTime:    2 * 256,
+		ForwardDelay: 15 * 256,
+	}
+
+	err, raw := NewSTPBPDU(hw, bpdu)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+	if eth := pkt.Layer(layers.LayerTypeEthernet).(*layers.Ethernet); eth.DstMAC.String() != STPDestMac.String() {
+		t.Fatalf("unexpected destination %s", eth.DstMAC)
+	}
+
+	stp := pkt.Layer(layers.LayerTypeSTP)
+	if stp == nil {
+		t.Fatalf("no stp layer found in %x", raw)
+	}
+
+	parsed, err := ParseSTPBPDU(stp.LayerContents())
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(parsed, bpdu) {
+		t.Fatalf("expected %+v, got %+v", bpdu, parsed)
+	}
+
+	rst := *bpdu
+	rst.Version = 2
+	rst.Type = STPTypeRST
+	rst.Flags = STPFlagsRSTDesignated
+	if data := rst.Serialize(); len(data) != stpConfigSize+1 {
+		t.Fatalf("unexpected RST BPDU size %d", len(data))
+	} else if parsed, err = ParseSTPBPDU(data); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(*parsed, rst) {
+		t.Fatalf("expected %+v, got %+v", rst, *parsed)
+	}
+
+	if tcn, err := ParseSTPBPDU([]byte{0x00, 0x00, 0x00, STPTypeTCN}); err != nil {
+		t.Fatal(err)
+	} else if tcn.Type != STPTypeTCN {
+		t.Fatalf("unexpected type 0x%02x", tcn.Type)
+	} else if _, err = ParseSTPBPDU(raw[:20]); err == nil {
+		t.Fatal("expected error for truncated BPDU")
+	}
+}
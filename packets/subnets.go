@@ -0,0 +1,107 @@
+package packets
+
+import (
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	// RFC 3442 and its pre standard Microsoft counterpart
+	dhcpOptClasslessRoutes   = layers.DHCPOpt(121)
+	dhcpOptMSClasslessRoutes = layers.DHCPOpt(249)
+)
+
+const icmp6PrefixInfoSize = 30
+
+// DHCPParseClasslessRoutes parses the destinations of a DHCP classless
+// static routes option, default routes are skipped.
+func DHCPParseClasslessRoutes(data []byte) []*net.IPNet {
+	subnets := make([]*net.IPNet, 0)
+	for len(data) > 0 {
+		width := int(data[0])
+		if width > 32 {
+			break
+		}
+
+		significant := (width + 7) / 8
+		// descriptor + router address
+		if len(data) < 1+significant+4 {
+			break
+		}
+
+		if width > 0 {
+			ip := make(net.IP, 4)
+			copy(ip, data[1:1+significant])
+			mask := net.CIDRMask(width, 32)
+			subnets = append(subnets, &net.IPNet{
+				IP:   ip.Mask(mask),
+				Mask: mask,
+			})
+		}
+
+		data = data[1+significant+4:]
+	}
+	return subnets
+}
+
+// ICMP6ParsePrefixInfo parses the prefix of an ICMPv6 prefix information option.
+func ICMP6ParsePrefixInfo(data []byte) *net.IPNet {
+	if len(data) < icmp6PrefixInfoSize {
+		return nil
+	}
+
+	width := int(data[0])
+	if width == 0 || width > 128 {
+		return nil
+	}
+
+	ip := make(net.IP, 16)
+	copy(ip, data[14:30])
+	mask := net.CIDRMask(width, 128)
+
+	return &net.IPNet{
+		IP:   ip.Mask(mask),
+		Mask: mask,
+	}
+}
+
+func subnetsFromDHCP(pkt gopacket.Packet) []*net.IPNet {
+	if ldhcp := pkt.Layer(layers.LayerTypeDHCPv4); ldhcp != nil {
+		if dhcp := ldhcp.(*layers.DHCPv4); dhcp.Operation == layers.DHCPOpReply {
+			for _, opt := range dhcp.Options {
+				if opt.Type == dhcpOptClasslessRoutes || opt.Type == dhcpOptMSClasslessRoutes {
+					return DHCPParseClasslessRoutes(opt.Data)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func subnetsFromRA(pkt gopacket.Packet) []*net.IPNet {
+	if lra := pkt.Layer(layers.LayerTypeICMPv6RouterAdvertisement); lra != nil {
+		subnets := make([]*net.IPNet, 0)
+		for _, opt := range lra.(*layers.ICMPv6RouterAdvertisement).Options {
+			if opt.Type == layers.ICMPv6OptPrefixInfo {
+				if subnet := ICMP6ParsePrefixInfo(opt.Data); subnet != nil {
+					subnets = append(subnets, subnet)
+				}
+			}
+		}
+		return subnets
+	}
+	return nil
+}
+
+// SubnetsGetFromPacket returns the subnets advertised by routers through DHCP
+// classless static routes or IPv6 router advertisements, and their source.
+func SubnetsGetFromPacket(pkt gopacket.Packet) ([]*net.IPNet, string) {
+	if subnets := subnetsFromDHCP(pkt); len(subnets) > 0 {
+		return subnets, "dhcp"
+	} else if subnets = subnetsFromRA(pkt); len(subnets) > 0 {
+		return subnets, "ra"
+	}
+	return nil, ""
+}
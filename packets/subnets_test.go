@@ -0,0 +1,55 @@
+package packets
+
+import (
+	"testing"
+)
+
+func TestDHCPParseClasslessRoutes(t *testing.T) {
+	data := []byte{
+		// 10.0.0.0/8 via 192.168.1.1
+		8, 10, 192, 168, 1, 1,
+		// 172.16.32.0/20 via 192.168.1.254
+		20, 172, 16, 32, 192, 168, 1, 254,
+		// default route
+		0, 192, 168, 1, 1,
+		// 192.168.5.128/25 via 192.168.1.1
+		25, 192, 168, 5, 128, 192, 168, 1, 1,
+	}
+
+	subnets := DHCPParseClasslessRoutes(data)
+	expected := []string{"10.0.0.0/8", "172.16.32.0/20", "192.168.5.128/25"}
+	if len(subnets) != len(expected) {
+		t.Fatalf("expected %d subnets, got %d", len(expected), len(subnets))
+	}
+
+	for i, subnet := range subnets {
+		if subnet.String() != expected[i] {
+			t.Fatalf("expected '%s', got '%s'", expected[i], subnet)
+		}
+	}
+
+	if subnets = DHCPParseClasslessRoutes([]byte{24, 10, 0}); len(subnets) != 0 {
+		t.Fatalf("expected no subnets from truncated option, got %v", subnets)
+	}
+}
+
+func TestICMP6ParsePrefixInfo(t *testing.T) {
+	data := []byte{
+		64, 0xc0,
+		0x00, 0x27, 0x8d, 0x00,
+		0x00, 0x09, 0x3a, 0x80,
+		0x00, 0x00, 0x00, 0x00,
+		0x20, 0x01, 0x0d, 0xb8, 0x00, 0x01, 0x00, 0x02,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+	}
+
+	if subnet := ICMP6ParsePrefixInfo(data); subnet == nil {
+		t.Fatal("expected prefix")
+	} else if subnet.String() != "2001:db8:1:2::/64" {
+		t.Fatalf("unexpected prefix '%s'", subnet)
+	}
+
+	if subnet := ICMP6ParsePrefixInfo(data[:10]); subnet != nil {
+		t.Fatalf("expected nil prefix from truncated option, got %s", subnet)
+	}
+}
@@ -53,3 +53,46 @@ func NewTCPSyn(from net.IP, from_hw net.HardwareAddr, to net.IP, to_hw net.Hardw
 		return Serialize(&eth, &ip6, &tcp)
 	}
 }
+
+// NewTCPReset returns a RST packet for the connection from -> to, acking
+// ack when it's not zero.
+func NewTCPReset(from net.IP, fromHW net.HardwareAddr, to net.IP, toHW net.HardwareAddr, srcPort int, dstPort int, seq uint32, ack uint32) (error, []byte) {
+	eth := layers.Ethernet{
+		SrcMAC:       fromHW,
+		DstMAC:       toHW,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	tcp := layers.TCP{
+		SrcPort: layers.TCPPort(srcPort),
+		DstPort: layers.TCPPort(dstPort),
+		Seq:     seq,
+		Ack:     ack,
+		RST:     true,
+		ACK:     ack != 0,
+	}
+
+	if from.To4() != nil && to.To4() != nil {
+		ip4 := layers.IPv4{
+			Protocol: layers.IPProtocolTCP,
+			Version:  4,
+			TTL:      64,
+			SrcIP:    from,
+			DstIP:    to,
+		}
+		tcp.SetNetworkLayerForChecksum(&ip4)
+
+		return Serialize(&eth, &ip4, &tcp)
+	}
+
+	eth.EthernetType = layers.EthernetTypeIPv6
+	ip6 := layers.IPv6{
+		Version:    6,
+		NextHeader: layers.IPProtocolTCP,
+		HopLimit:   64,
+		SrcIP:      from,
+		DstIP:      to,
+	}
+	tcp.SetNetworkLayerForChecksum(&ip6)
+
+	return Serialize(&eth, &ip6, &tcp)
+}
@@ -0,0 +1,46 @@
+package packets
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestNewTCPReset(t *testing.T) {
+	hw := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+
+	var units = []struct {
+		from  string
+		to    string
+		ack   uint32
+		layer gopacket.LayerType
+	}{
+		{"192.168.1.1", "192.168.1.2", 1001, layers.LayerTypeIPv4},
+		{"fe80::1", "fe80::2", 0, layers.LayerTypeIPv6},
+	}
+
+	for _, u := range units {
+		err, raw := NewTCPReset(net.ParseIP(u.from), hw, net.ParseIP(u.to), hw, 853, 41000, 42, u.ack)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+		if pkt.Layer(u.layer) == nil {
+			t.Fatalf("expected %s layer", u.layer)
+		}
+
+		tcp, ok := pkt.Layer(layers.LayerTypeTCP).(*layers.TCP)
+		if !ok {
+			t.Fatal("expected TCP layer")
+		} else if !tcp.RST || tcp.SYN {
+			t.Fatal("expected a RST")
+		} else if tcp.SrcPort != 853 || tcp.DstPort != 41000 {
+			t.Fatalf("unexpected ports %d -> %d", tcp.SrcPort, tcp.DstPort)
+		} else if tcp.Seq != 42 || tcp.Ack != u.ack || tcp.ACK != (u.ack != 0) {
+			t.Fatalf("unexpected seq %d ack %d (%v)", tcp.Seq, tcp.Ack, tcp.ACK)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package packets
+
+import (
+	"crypto/x509"
+	"encoding/binary"
+)
+
+const (
+	tlsRecordHandshake      = 0x16
+	tlsHandshakeHello       = 0x01
+	tlsHandshakeServerHello = 0x02
+	tlsHandshakeCertificate = 0x0b
+	tlsExtServerName        = 0x0000
+	tlsExtALPN              = 0x0010
+	tlsServerNameHostName   = 0x00
+)
+
+type tlsExtension struct {
+	typ  uint16
+	data []byte
+}
+
+type tlsHello struct {
+	version    uint16
+	ciphers    []uint16
+	extensions []tlsExtension
+}
+
+// parseTLSHello parses the client or server hello in the first record of a
+// TLS handshake, returning false as second value if it's been truncated.
+func parseTLSHello(data []byte, handshakeType byte) (*tlsHello, bool) {
+	// record header, handshake header and version + random
+	if len(data) < 5+4+2+32 || data[0] != tlsRecordHandshake || data[1] != 0x03 || data[5] != handshakeType {
+		return nil, false
+	}
+
+	// the hello might span over multiple records, parse what we have
+	hello := data[9:]
+	recordSize := int(binary.BigEndian.Uint16(data[3:])) - 4
+	if recordSize >= 0 && recordSize < len(hello) {
+		hello = hello[:recordSize]
+	}
+	truncated := recordSize > len(hello)
+
+	h := &tlsHello{
+		version:    binary.BigEndian.Uint16(hello),
+		ciphers:    make([]uint16, 0),
+		extensions: make([]tlsExtension, 0),
+	}
+	hello = hello[2+32:]
+
+	// session id, cipher suites and compression methods, the server
+	// selects a single cipher and compression method
+	lenSizes := []int{1, 2, 1}
+	if handshakeType == tlsHandshakeServerHello {
+		lenSizes = []int{1, -2, -1}
+	}
+
+	for i, lenSize := range lenSizes {
+		size := 0
+		if lenSize < 0 {
+			size, lenSize = -lenSize, 0
+		} else if len(hello) < lenSize {
+			return h, false
+		} else if lenSize == 1 {
+			size = int(hello[0])
+		} else {
+			size = int(binary.BigEndian.Uint16(hello))
+		}
+
+		if len(hello) < lenSize+size {
+			return h, false
+		} else if i == 1 {
+			for list := hello[lenSize : lenSize+size]; len(list) >= 2; list = list[2:] {
+				h.ciphers = append(h.ciphers, binary.BigEndian.Uint16(list))
+			}
+		}
+		hello = hello[lenSize+size:]
+	}
+
+	if len(hello) < 2 {
+		// no extensions
+		return h, len(hello) == 0 && !truncated
+	}
+	exts := hello[2:]
+	size := int(binary.BigEndian.Uint16(hello))
+	if size < len(exts) {
+		exts = exts[:size]
+	}
+
+	for len(exts) >= 4 {
+		extType := binary.BigEndian.Uint16(exts[0:])
+		extSize := int(binary.BigEndian.Uint16(exts[2:]))
+		if len(exts) < 4+extSize {
+			return h, false
+		}
+		h.extensions = append(h.extensions, tlsExtension{
+			typ:  extType,
+			data: exts[4 : 4+extSize],
+		})
+		exts = exts[4+extSize:]
+	}
+
+	return h, len(exts) == 0 && size <= len(hello)-2 && !truncated
+}
+
+// tlsClientHelloExtension returns the body of the extension of the given
+// type sent by the client in the first record of a TLS handshake.
+func tlsClientHelloExtension(data []byte, wanted uint16) ([]byte, bool) {
+	if h, _ := parseTLSHello(data, tlsHandshakeHello); h != nil {
+		for _, ext := range h.extensions {
+			if ext.typ == wanted {
+				return ext.data, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// TLSClientHelloSNI returns the server name sent by the client in the first
+// record of a TLS handshake, if any.
+func TLSClientHelloSNI(data []byte) (string, bool) {
+	// server name list
+	names, found := tlsClientHelloExtension(data, tlsExtServerName)
+	if !found || len(names) < 2 {
+		return "", false
+	}
+
+	for names = names[2:]; len(names) >= 3; {
+		nameType := names[0]
+		nameSize := int(binary.BigEndian.Uint16(names[1:]))
+		if len(names) < 3+nameSize {
+			return "", false
+		} else if nameType == tlsServerNameHostName && nameSize > 0 {
+			return string(names[3 : 3+nameSize]), true
+		}
+		names = names[3+nameSize:]
+	}
+
+	return "", false
+}
+
+// TLSClientHelloCiphers returns the cipher suites offered by the client in
+// the first record of a TLS handshake.
+func TLSClientHelloCiphers(data []byte) ([]uint16, bool) {
+	if h, _ := parseTLSHello(data, tlsHandshakeHello); h != nil && len(h.ciphers) > 0 {
+		return h.ciphers, true
+	}
+	return nil, false
+}
+
+// TLSClientHelloALPN returns the application protocols offered by the client
+// in the first record of a TLS handshake, if any.
+func TLSClientHelloALPN(data []byte) ([]string, bool) {
+	// protocol name list
+	list, found := tlsClientHelloExtension(data, tlsExtALPN)
+	if !found || len(list) < 2 {
+		return nil, false
+	}
+
+	protos := make([]string, 0)
+	for list = list[2:]; len(list) >= 1; {
+		size := int(list[0])
+		if size == 0 || len(list) < 1+size {
+			return nil, false
+		}
+		protos = append(protos, string(list[1:1+size]))
+		list = list[1+size:]
+	}
+
+	return protos, len(protos) > 0
+}
+
+// TLSServerCertificate returns the leaf certificate sent by the server in
+// the handshake records of data, if they contain it whole. Only TLS 1.2 and
+// older versions send it in clear text.
+func TLSServerCertificate(data []byte) (*x509.Certificate, bool) {
+	// handshake messages can span over multiple records
+	handshake := make([]byte, 0)
+	for len(data) >= 5 && data[0] == tlsRecordHandshake && data[1] == 0x03 {
+		size := int(binary.BigEndian.Uint16(data[3:]))
+		if len(data) < 5+size {
+			handshake = append(handshake, data[5:]...)
+			break
+		}
+		handshake = append(handshake, data[5:5+size]...)
+		data = data[5+size:]
+	}
+
+	for len(handshake) >= 4 {
+		msgType := handshake[0]
+		size := int(handshake[1])<<16 | int(handshake[2])<<8 | int(handshake[3])
+		if len(handshake) < 4+size {
+			return nil, false
+		} else if msgType != tlsHandshakeCertificate {
+			handshake = handshake[4+size:]
+			continue
+		}
+
+		// certificates list size and the size of the first one
+		certs := handshake[4 : 4+size]
+		if len(certs) < 6 {
+			return nil, false
+		}
+		certSize := int(certs[3])<<16 | int(certs[4])<<8 | int(certs[5])
+		if len(certs) < 6+certSize {
+			return nil, false
+		}
+
+		cert, err := x509.ParseCertificate(certs[6 : 6+certSize])
+		return cert, err == nil
+	}
+
+	return nil, false
+}
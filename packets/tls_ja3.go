@@ -0,0 +1,87 @@
+package packets
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	tlsExtSupportedGroups = 0x000a
+	tlsExtPointFormats    = 0x000b
+)
+
+// GREASE values (RFC 8701) are random and must be ignored, or the same
+// client would get a different fingerprint every time.
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+func joinUint16(values []uint16) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		if !isGREASE(v) {
+			parts = append(parts, strconv.Itoa(int(v)))
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+func ja3Hash(ja3 string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(ja3)))
+}
+
+// TLSClientHelloJA3 returns the JA3 fingerprint of the client hello in the
+// first record of a TLS handshake and its MD5 hash.
+func TLSClientHelloJA3(data []byte) (string, string, bool) {
+	h, complete := parseTLSHello(data, tlsHandshakeHello)
+	if h == nil || !complete {
+		return "", "", false
+	}
+
+	extensions := make([]uint16, 0, len(h.extensions))
+	curves := make([]uint16, 0)
+	points := make([]uint16, 0)
+
+	for _, ext := range h.extensions {
+		extensions = append(extensions, ext.typ)
+		if ext.typ == tlsExtSupportedGroups && len(ext.data) >= 2 {
+			for list := ext.data[2:]; len(list) >= 2; list = list[2:] {
+				curves = append(curves, binary.BigEndian.Uint16(list))
+			}
+		} else if ext.typ == tlsExtPointFormats && len(ext.data) >= 1 {
+			for _, format := range ext.data[1:] {
+				points = append(points, uint16(format))
+			}
+		}
+	}
+
+	ja3 := fmt.Sprintf("%d,%s,%s,%s,%s",
+		h.version,
+		joinUint16(h.ciphers),
+		joinUint16(extensions),
+		joinUint16(curves),
+		joinUint16(points))
+
+	return ja3, ja3Hash(ja3), true
+}
+
+// TLSServerHelloJA3S returns the JA3S fingerprint of the server hello in the
+// first record of a TLS handshake and its MD5 hash.
+func TLSServerHelloJA3S(data []byte) (string, string, bool) {
+	h, complete := parseTLSHello(data, tlsHandshakeServerHello)
+	if h == nil || !complete || len(h.ciphers) != 1 {
+		return "", "", false
+	}
+
+	extensions := make([]uint16, 0, len(h.extensions))
+	for _, ext := range h.extensions {
+		extensions = append(extensions, ext.typ)
+	}
+
+	ja3s := fmt.Sprintf("%d,%d,%s", h.version, h.ciphers[0], joinUint16(extensions))
+
+	return ja3s, ja3Hash(ja3s), true
+}
@@ -0,0 +1,91 @@
+package packets
+
+import (
+	"testing"
+)
+
+func tlsHandshakeRecord(handshakeType byte, body []byte) []byte {
+	msg := append([]byte{handshakeType, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}, body...)
+	return append([]byte{tlsRecordHandshake, 0x03, 0x01, byte(len(msg) >> 8), byte(len(msg))}, msg...)
+}
+
+func tlsExtensions(exts map[uint16][]byte, order []uint16) []byte {
+	raw := make([]byte, 0)
+	for _, typ := range order {
+		data := exts[typ]
+		raw = append(raw, byte(typ>>8), byte(typ), byte(len(data)>>8), byte(len(data)))
+		raw = append(raw, data...)
+	}
+	return append([]byte{byte(len(raw) >> 8), byte(len(raw))}, raw...)
+}
+
+func TestTLSClientHelloJA3(t *testing.T) {
+	body := []byte{0x03, 0x03}
+	body = append(body, make([]byte, 32)...)
+	// no session id, a GREASE cipher and two real ones
+	body = append(body, 0x00, 0x00, 0x06, 0x0a, 0x0a, 0x13, 0x01, 0x13, 0x02)
+	// null compression
+	body = append(body, 0x01, 0x00)
+	body = append(body, tlsExtensions(map[uint16][]byte{
+		0x1a1a: {},
+		0x0000: {0x00, 0x05, 0x00, 0x00, 0x02, 'h', 'i'},
+		0x000a: {0x00, 0x06, 0x2a, 0x2a, 0x00, 0x1d, 0x00, 0x17},
+		0x000b: {0x01, 0x00},
+	}, []uint16{0x1a1a, 0x0000, 0x000a, 0x000b})...)
+
+	hello := tlsHandshakeRecord(tlsHandshakeHello, body)
+
+	expected := "771,4865-4866,0-10-11,29-23,0"
+	if ja3, hash, found := TLSClientHelloJA3(hello); !found {
+		t.Fatal("expected a fingerprint")
+	} else if ja3 != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, ja3)
+	} else if hash != ja3Hash(expected) || len(hash) != 32 {
+		t.Fatalf("unexpected hash %s", hash)
+	}
+
+	if name, found := TLSClientHelloSNI(hello); !found || name != "hi" {
+		t.Fatalf("expected 'hi', got '%s'", name)
+	}
+
+	// truncated hellos can't be fingerprinted
+	for i := 0; i < len(hello); i++ {
+		if _, _, found := TLSClientHelloJA3(hello[:i]); found {
+			t.Fatalf("unexpected fingerprint of %d bytes out of %d", i, len(hello))
+		}
+	}
+}
+
+func TestTLSClientHelloJA3Real(t *testing.T) {
+	a, _, found := TLSClientHelloJA3(clientHello(t, "dns.google"))
+	if !found {
+		t.Fatal("expected a fingerprint")
+	}
+	b, _, _ := TLSClientHelloJA3(clientHello(t, "example.com"))
+	if a != b {
+		t.Fatalf("same client, different fingerprints: %s vs %s", a, b)
+	}
+}
+
+func TestTLSServerHelloJA3S(t *testing.T) {
+	body := []byte{0x03, 0x03}
+	body = append(body, make([]byte, 32)...)
+	// no session id, the selected cipher and compression
+	body = append(body, 0x00, 0x13, 0x01, 0x00)
+	body = append(body, tlsExtensions(map[uint16][]byte{
+		43: {0x03, 0x04},
+		51: {0x00, 0x1d, 0x00, 0x00},
+	}, []uint16{43, 51})...)
+
+	hello := tlsHandshakeRecord(tlsHandshakeServerHello, body)
+
+	if ja3s, _, found := TLSServerHelloJA3S(hello); !found {
+		t.Fatal("expected a fingerprint")
+	} else if ja3s != "771,4865,43-51" {
+		t.Fatalf("expected '771,4865,43-51', got '%s'", ja3s)
+	}
+
+	if _, _, found := TLSClientHelloJA3(hello); found {
+		t.Fatal("a server hello is not a client hello")
+	}
+}
@@ -0,0 +1,186 @@
+package packets
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+func clientHello(t *testing.T, serverName string) []byte {
+	return clientHelloWithConfig(t, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+}
+
+func clientHelloWithConfig(t *testing.T, config *tls.Config) []byte {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go func() {
+		conn := tls.Client(client, config)
+		conn.Handshake()
+		client.Close()
+	}()
+
+	buf := make([]byte, 4096)
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[:n]
+}
+
+func TestTLSClientHelloSNI(t *testing.T) {
+	hello := clientHello(t, "dns.google")
+
+	if name, found := TLSClientHelloSNI(hello); !found {
+		t.Fatal("expected server name to be found")
+	} else if name != "dns.google" {
+		t.Fatalf("expected 'dns.google', got '%s'", name)
+	}
+
+	// truncated hellos must not be parsed nor cause panics
+	for i := 0; i < len(hello); i++ {
+		TLSClientHelloSNI(hello[:i])
+	}
+}
+
+func TestTLSClientHelloNoSNI(t *testing.T) {
+	// crypto/tls does not send the extension for addresses
+	hello := clientHello(t, "1.1.1.1")
+	if name, found := TLSClientHelloSNI(hello); found {
+		t.Fatalf("unexpected server name '%s'", name)
+	}
+
+	if _, found := TLSClientHelloSNI([]byte("GET / HTTP/1.1\r\n\r\n")); found {
+		t.Fatal("unexpected server name in non TLS data")
+	}
+}
+
+func TestTLSClientHelloALPN(t *testing.T) {
+	hello := clientHelloWithConfig(t, &tls.Config{
+		ServerName:         "dns.google",
+		NextProtos:         []string{"h2", "http/1.1"},
+		InsecureSkipVerify: true,
+	})
+
+	if protos, found := TLSClientHelloALPN(hello); !found {
+		t.Fatal("expected application protocols to be found")
+	} else if len(protos) != 2 || protos[0] != "h2" || protos[1] != "http/1.1" {
+		t.Fatalf("expected [h2 http/1.1], got %v", protos)
+	}
+
+	// the server name must still be parsed
+	if name, found := TLSClientHelloSNI(hello); !found || name != "dns.google" {
+		t.Fatalf("expected 'dns.google', got '%s'", name)
+	}
+
+	for i := 0; i < len(hello); i++ {
+		TLSClientHelloALPN(hello[:i])
+	}
+
+	if protos, found := TLSClientHelloALPN(clientHello(t, "dns.google")); found {
+		t.Fatalf("unexpected application protocols %v", protos)
+	}
+}
+
+func TestTLSClientHelloCiphers(t *testing.T) {
+	hello := clientHelloWithConfig(t, &tls.Config{
+		ServerName:         "dns.google",
+		CipherSuites:       []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		MaxVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true,
+	})
+
+	ciphers, found := TLSClientHelloCiphers(hello)
+	if !found {
+		t.Fatal("expected ciphers to be found")
+	}
+
+	offered := false
+	for _, cipher := range ciphers {
+		if cipher == tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+			offered = true
+		}
+	}
+	if !offered {
+		t.Fatalf("unexpected ciphers %v", ciphers)
+	}
+
+	if _, found = TLSClientHelloCiphers([]byte("GET / HTTP/1.1\r\n\r\n")); found {
+		t.Fatal("unexpected ciphers in non TLS data")
+	}
+}
+
+func TestTLSIsGREASE(t *testing.T) {
+	for _, v := range []uint16{0x0a0a, 0x2a2a, 0xfafa} {
+		if !isGREASE(v) {
+			t.Fatalf("expected %04x to be a GREASE value", v)
+		}
+	}
+	for _, v := range []uint16{0x0a1a, 0x0000, 0x1301} {
+		if isGREASE(v) {
+			t.Fatalf("unexpected GREASE value %04x", v)
+		}
+	}
+}
+
+func tlsTestCertificate(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "rdp.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestTLSServerCertificate(t *testing.T) {
+	der := tlsTestCertificate(t)
+
+	cert := make([]byte, 3+3+len(der))
+	cert[2] = byte((3 + len(der)) & 0xff)
+	cert[1] = byte((3 + len(der)) >> 8)
+	cert[5] = byte(len(der) & 0xff)
+	cert[4] = byte(len(der) >> 8)
+	copy(cert[6:], der)
+
+	hello := []byte{tlsHandshakeServerHello, 0, 0, 2, 0x03, 0x03}
+	handshake := append(hello, tlsHandshakeCertificate, 0, byte(len(cert)>>8), byte(len(cert)&0xff))
+	handshake = append(handshake, cert...)
+
+	// split over two records
+	half := len(handshake) / 2
+	data := []byte{tlsRecordHandshake, 0x03, 0x03, byte(half >> 8), byte(half & 0xff)}
+	data = append(data, handshake[:half]...)
+	rest := len(handshake) - half
+	data = append(data, tlsRecordHandshake, 0x03, 0x03, byte(rest>>8), byte(rest&0xff))
+	data = append(data, handshake[half:]...)
+
+	if parsed, found := TLSServerCertificate(data); !found {
+		t.Fatal("expected certificate")
+	} else if parsed.Subject.CommonName != "rdp.example.com" {
+		t.Fatalf("unexpected common name %s", parsed.Subject.CommonName)
+	}
+
+	if _, found := TLSServerCertificate(data[:len(data)-10]); found {
+		t.Fatal("unexpected certificate from truncated data")
+	}
+}
@@ -0,0 +1,161 @@
+package packets
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	TraceICMP = "icmp"
+	TraceUDP  = "udp"
+	TraceTCP  = "tcp"
+
+	// udp probes are sent to TraceBasePort + ttl like the classic traceroute
+	TraceBasePort = 33434
+	TraceMaxTTL   = 64
+)
+
+type TraceReply struct {
+	From    net.IP
+	TTL     int
+	Reached bool
+}
+
+// NewTraceProbe returns an IPv4 probe with the given ttl, which is also
+// encoded in the probe itself so that replies can be matched to it:
+// icmp probes use id as identifier and ttl as sequence, udp ones use id as
+// source port and TraceBasePort + ttl as destination port while tcp probes
+// are sent from port id + ttl to port.
+func NewTraceProbe(mode string, from net.IP, fromHW net.HardwareAddr, to net.IP, toHW net.HardwareAddr, id uint16, ttl int, port int) (error, []byte) {
+	if to.To4() == nil {
+		return fmt.Errorf("only IPv4 targets are supported"), nil
+	}
+
+	eth := layers.Ethernet{
+		SrcMAC:       fromHW,
+		DstMAC:       toHW,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{
+		Version: 4,
+		TTL:     uint8(ttl),
+		Id:      id + uint16(ttl),
+		SrcIP:   from,
+		DstIP:   to,
+	}
+
+	switch mode {
+	case TraceICMP:
+		ip4.Protocol = layers.IPProtocolICMPv4
+		icmp := layers.ICMPv4{
+			TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0),
+			Id:       id,
+			Seq:      uint16(ttl),
+		}
+		return Serialize(&eth, &ip4, &icmp, gopacket.Payload(make([]byte, 32)))
+
+	case TraceUDP:
+		ip4.Protocol = layers.IPProtocolUDP
+		udp := layers.UDP{
+			SrcPort: layers.UDPPort(id),
+			DstPort: layers.UDPPort(TraceBasePort + ttl),
+		}
+		udp.SetNetworkLayerForChecksum(&ip4)
+		return Serialize(&eth, &ip4, &udp, gopacket.Payload(make([]byte, 32)))
+
+	case TraceTCP:
+		ip4.Protocol = layers.IPProtocolTCP
+		tcp := layers.TCP{
+			SrcPort: layers.TCPPort(int(id) + ttl),
+			DstPort: layers.TCPPort(port),
+			Seq:     uint32(id),
+			SYN:     true,
+			Window:  1024,
+		}
+		tcp.SetNetworkLayerForChecksum(&ip4)
+		return Serialize(&eth, &ip4, &tcp)
+	}
+
+	return fmt.Errorf("unknown traceroute mode %s", mode), nil
+}
+
+func traceTTL(ttl int) (int, bool) {
+	return ttl, ttl > 0 && ttl <= TraceMaxTTL
+}
+
+// TraceMatchQuoted returns the ttl of one of our probes given the original
+// datagram quoted by an ICMP error, that is its IPv4 header and at least
+// the first 8 bytes of its payload.
+func TraceMatchQuoted(orig []byte, mode string, id uint16) (int, bool) {
+	if len(orig) < 20 || orig[0]>>4 != 4 {
+		return 0, false
+	}
+
+	ihl := int(orig[0]&0x0f) * 4
+	if ihl < 20 || len(orig) < ihl+8 {
+		return 0, false
+	}
+
+	l4 := orig[ihl:]
+	srcPort := binary.BigEndian.Uint16(l4[0:])
+	dstPort := binary.BigEndian.Uint16(l4[2:])
+
+	switch mode {
+	case TraceICMP:
+		if orig[9] == uint8(layers.IPProtocolICMPv4) && l4[0] == layers.ICMPv4TypeEchoRequest && binary.BigEndian.Uint16(l4[4:]) == id {
+			return traceTTL(int(binary.BigEndian.Uint16(l4[6:])))
+		}
+	case TraceUDP:
+		if orig[9] == uint8(layers.IPProtocolUDP) && srcPort == id {
+			return traceTTL(int(dstPort) - TraceBasePort)
+		}
+	case TraceTCP:
+		if orig[9] == uint8(layers.IPProtocolTCP) && binary.BigEndian.Uint32(l4[4:]) == uint32(id) {
+			return traceTTL(int(srcPort) - int(id))
+		}
+	}
+
+	return 0, false
+}
+
+// TraceParseReply checks if pkt is an answer to one of our probes, either
+// from an intermediate hop or from the target itself.
+func TraceParseReply(pkt gopacket.Packet, mode string, id uint16, target net.IP) (*TraceReply, bool) {
+	lip4 := pkt.Layer(layers.LayerTypeIPv4)
+	if lip4 == nil {
+		return nil, false
+	}
+	ip4 := lip4.(*layers.IPv4)
+
+	if licmp := pkt.Layer(layers.LayerTypeICMPv4); licmp != nil {
+		icmp := licmp.(*layers.ICMPv4)
+		switch icmp.TypeCode.Type() {
+		case layers.ICMPv4TypeTimeExceeded, layers.ICMPv4TypeDestinationUnreachable:
+			if ttl, ok := TraceMatchQuoted(icmp.Payload, mode, id); ok {
+				return &TraceReply{
+					From:    ip4.SrcIP,
+					TTL:     ttl,
+					Reached: ip4.SrcIP.Equal(target),
+				}, true
+			}
+		case layers.ICMPv4TypeEchoReply:
+			if mode == TraceICMP && icmp.Id == id && ip4.SrcIP.Equal(target) {
+				if ttl, ok := traceTTL(int(icmp.Seq)); ok {
+					return &TraceReply{From: ip4.SrcIP, TTL: ttl, Reached: true}, true
+				}
+			}
+		}
+	} else if ltcp := pkt.Layer(layers.LayerTypeTCP); ltcp != nil && mode == TraceTCP && ip4.SrcIP.Equal(target) {
+		// either a SYN+ACK or a RST, the target has been reached anyway
+		tcp := ltcp.(*layers.TCP)
+		if ttl, ok := traceTTL(int(tcp.DstPort) - int(id)); ok && (tcp.RST || (tcp.SYN && tcp.ACK)) {
+			return &TraceReply{From: ip4.SrcIP, TTL: ttl, Reached: true}, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,56 @@
+package packets
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func buildQuoted(proto byte, l4 []byte) []byte {
+	orig := make([]byte, 20)
+	orig[0] = 0x45
+	orig[9] = proto
+	return append(orig, l4...)
+}
+
+func TestTraceMatchQuoted(t *testing.T) {
+	id := uint16(40000)
+
+	echo := make([]byte, 8)
+	echo[0] = 8
+	binary.BigEndian.PutUint16(echo[4:], id)
+	binary.BigEndian.PutUint16(echo[6:], 5)
+
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[0:], id)
+	binary.BigEndian.PutUint16(udp[2:], TraceBasePort+7)
+
+	tcp := make([]byte, 8)
+	binary.BigEndian.PutUint16(tcp[0:], id+3)
+	binary.BigEndian.PutUint16(tcp[2:], 80)
+	binary.BigEndian.PutUint32(tcp[4:], uint32(id))
+
+	otherUDP := make([]byte, 8)
+	binary.BigEndian.PutUint16(otherUDP[0:], 53)
+	binary.BigEndian.PutUint16(otherUDP[2:], TraceBasePort+7)
+
+	var units = []struct {
+		orig  []byte
+		mode  string
+		ttl   int
+		found bool
+	}{
+		{buildQuoted(1, echo), TraceICMP, 5, true},
+		{buildQuoted(17, udp), TraceUDP, 7, true},
+		{buildQuoted(6, tcp), TraceTCP, 3, true},
+		{buildQuoted(17, udp), TraceICMP, 0, false},
+		{buildQuoted(17, otherUDP), TraceUDP, 0, false},
+		{buildQuoted(1, echo)[:24], TraceICMP, 0, false},
+	}
+
+	for i, u := range units {
+		ttl, found := TraceMatchQuoted(u.orig, u.mode, id)
+		if found != u.found || ttl != u.ttl {
+			t.Fatalf("unit %d: expected %d (%v), got %d (%v)", i, u.ttl, u.found, ttl, found)
+		}
+	}
+}
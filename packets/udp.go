@@ -1,8 +1,10 @@
 package packets
 
 import (
-	"github.com/google/gopacket/layers"
 	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
 )
 
 func NewUDPProbe(from net.IP, from_hw net.HardwareAddr, to net.IP, port int) (error, []byte) {
@@ -44,3 +46,43 @@ func NewUDPProbe(from net.IP, from_hw net.HardwareAddr, to net.IP, port int) (er
 		return Serialize(&eth, &ip4, &udp)
 	}
 }
+
+func NewUDPPacket(from net.IP, from_hw net.HardwareAddr, to net.IP, to_hw net.HardwareAddr, srcPort int, dstPort int, payload []byte) (error, []byte) {
+	eth := layers.Ethernet{
+		SrcMAC:       from_hw,
+		DstMAC:       to_hw,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+
+	udp := layers.UDP{
+		SrcPort: layers.UDPPort(srcPort),
+		DstPort: layers.UDPPort(dstPort),
+	}
+
+	if to.To4() == nil {
+		eth.EthernetType = layers.EthernetTypeIPv6
+		ip6 := layers.IPv6{
+			NextHeader: layers.IPProtocolUDP,
+			Version:    6,
+			SrcIP:      from,
+			DstIP:      to,
+			HopLimit:   64,
+		}
+
+		udp.SetNetworkLayerForChecksum(&ip6)
+
+		return Serialize(&eth, &ip6, &udp, gopacket.Payload(payload))
+	}
+
+	ip4 := layers.IPv4{
+		Protocol: layers.IPProtocolUDP,
+		Version:  4,
+		TTL:      64,
+		SrcIP:    from,
+		DstIP:    to,
+	}
+
+	udp.SetNetworkLayerForChecksum(&ip4)
+
+	return Serialize(&eth, &ip4, &udp, gopacket.Payload(payload))
+}
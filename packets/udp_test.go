@@ -0,0 +1,35 @@
+package packets
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestNewUDPPacket(t *testing.T) {
+	hw := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+
+	for _, to := range []string{"192.168.1.2", "fe80::2"} {
+		from := "192.168.1.1"
+		if net.ParseIP(to).To4() == nil {
+			from = "fe80::1"
+		}
+
+		err, raw := NewUDPPacket(net.ParseIP(from), hw, net.ParseIP(to), hw, 1234, 53, []byte("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+		udp, ok := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP)
+		if !ok {
+			t.Fatal("expected UDP layer")
+		} else if udp.SrcPort != 1234 || udp.DstPort != 53 {
+			t.Fatalf("unexpected ports %d -> %d", udp.SrcPort, udp.DstPort)
+		} else if string(udp.Payload) != "hello" {
+			t.Fatalf("unexpected payload %q", udp.Payload)
+		}
+	}
+}
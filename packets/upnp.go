@@ -3,9 +3,11 @@ package packets
 import (
 	"bufio"
 	"bytes"
+	"encoding/xml"
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/evilsocket/islazy/str"
@@ -50,3 +52,96 @@ func UPNPGetMeta(pkt gopacket.Packet) map[string]string {
 	}
 	return nil
 }
+
+type UPNPService struct {
+	ServiceType string `xml:"serviceType"`
+	ServiceId   string `xml:"serviceId"`
+	ControlURL  string `xml:"controlURL"`
+	EventSubURL string `xml:"eventSubURL"`
+	SCPDURL     string `xml:"SCPDURL"`
+}
+
+type UPNPDevice struct {
+	DeviceType       string        `xml:"deviceType"`
+	FriendlyName     string        `xml:"friendlyName"`
+	Manufacturer     string        `xml:"manufacturer"`
+	ModelName        string        `xml:"modelName"`
+	ModelNumber      string        `xml:"modelNumber"`
+	ModelDescription string        `xml:"modelDescription"`
+	SerialNumber     string        `xml:"serialNumber"`
+	Services         []UPNPService `xml:"serviceList>service"`
+	Devices          []UPNPDevice  `xml:"deviceList>device"`
+}
+
+type UPNPDescription struct {
+	URLBase string     `xml:"URLBase"`
+	Device  UPNPDevice `xml:"device"`
+}
+
+func UPNPParseDescription(raw []byte) (*UPNPDescription, error) {
+	desc := &UPNPDescription{}
+	if err := xml.Unmarshal(raw, desc); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+// Meta returns the device description as endpoint metadata, control
+// URLs are resolved against the description base URL or its location.
+func (d *UPNPDescription) Meta(location string) map[string]string {
+	meta := make(map[string]string)
+
+	base, _ := url.Parse(location)
+	if d.URLBase != "" {
+		if u, err := url.Parse(d.URLBase); err == nil {
+			base = u
+		}
+	}
+
+	fields := map[string]string{
+		"upnp:deviceType":       d.Device.DeviceType,
+		"upnp:friendlyName":     d.Device.FriendlyName,
+		"upnp:manufacturer":     d.Device.Manufacturer,
+		"upnp:modelName":        d.Device.ModelName,
+		"upnp:modelNumber":      d.Device.ModelNumber,
+		"upnp:modelDescription": d.Device.ModelDescription,
+		"upnp:serialNumber":     d.Device.SerialNumber,
+	}
+	for name, value := range fields {
+		if value = str.Trim(value); value != "" {
+			meta[name] = value
+		}
+	}
+
+	services := []string{}
+	controls := []string{}
+	devices := []UPNPDevice{d.Device}
+	for len(devices) > 0 {
+		dev := devices[0]
+		devices = append(devices[1:], dev.Devices...)
+
+		for _, svc := range dev.Services {
+			if svc.ServiceType != "" {
+				services = append(services, str.Trim(svc.ServiceType))
+			}
+			if svc.ControlURL != "" {
+				control := str.Trim(svc.ControlURL)
+				if base != nil {
+					if u, err := url.Parse(control); err == nil {
+						control = base.ResolveReference(u).String()
+					}
+				}
+				controls = append(controls, control)
+			}
+		}
+	}
+
+	if len(services) > 0 {
+		meta["upnp:services"] = strings.Join(services, ",")
+	}
+	if len(controls) > 0 {
+		meta["upnp:controls"] = strings.Join(controls, ",")
+	}
+
+	return meta
+}
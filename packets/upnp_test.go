@@ -0,0 +1,52 @@
+package packets
+
+import (
+	"testing"
+)
+
+var upnpDescription = []byte(`<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <friendlyName>Home Router</friendlyName>
+    <manufacturer>ACME</manufacturer>
+    <modelName>R1000</modelName>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <serviceList>
+          <service>
+            <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+            <controlURL>/ctl/IPConn</controlURL>
+          </service>
+        </serviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`)
+
+func TestUPNPParseDescription(t *testing.T) {
+	desc, err := UPNPParseDescription(upnpDescription)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := desc.Meta("http://192.168.1.1:5000/rootDesc.xml")
+
+	var units = []struct {
+		key   string
+		value string
+	}{
+		{"upnp:friendlyName", "Home Router"},
+		{"upnp:manufacturer", "ACME"},
+		{"upnp:modelName", "R1000"},
+		{"upnp:services", "urn:schemas-upnp-org:service:WANIPConnection:1"},
+		{"upnp:controls", "http://192.168.1.1:5000/ctl/IPConn"},
+	}
+
+	for _, u := range units {
+		if got := meta[u.key]; got != u.value {
+			t.Fatalf("expected '%s' for %s, got '%s'", u.value, u.key, got)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package packets
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// VLANGetIDs returns the 802.1Q tags of the packet, outermost first
+// in case of stacked (QinQ) tags.
+func VLANGetIDs(pkt gopacket.Packet) []uint16 {
+	ids := make([]uint16, 0)
+	for _, layer := range pkt.Layers() {
+		if dot1q, ok := layer.(*layers.Dot1Q); ok {
+			ids = append(ids, dot1q.VLANIdentifier)
+		}
+	}
+	return ids
+}
+
+func VLANGetMeta(pkt gopacket.Packet) map[string]string {
+	ids := VLANGetIDs(pkt)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	tags := make([]string, len(ids))
+	for i, id := range ids {
+		tags[i] = fmt.Sprintf("%d", id)
+	}
+
+	return map[string]string{
+		"vlan:ids": strings.Join(tags, "/"),
+	}
+}
+
+var vlanHopPayload = []byte("bettercap vlan.hop probe")
+
+// NewVLANHopProbe returns an ICMP echo request carrying two 802.1Q tags. A
+// switch receiving it on an access port of the native VLAN outer strips the
+// first tag and forwards the frame on its trunks, where the next switch reads
+// the inner tag and delivers it to dst in VLAN inner.
+func NewVLANHopProbe(srcHW net.HardwareAddr, srcIP net.IP, dstHW net.HardwareAddr, dstIP net.IP, outer, inner uint16, id, seq uint16) (error, []byte) {
+	eth := layers.Ethernet{
+		SrcMAC:       srcHW,
+		DstMAC:       dstHW,
+		EthernetType: layers.EthernetTypeDot1Q,
+	}
+	outerTag := layers.Dot1Q{
+		VLANIdentifier: outer,
+		Type:           layers.EthernetTypeDot1Q,
+	}
+	innerTag := layers.Dot1Q{
+		VLANIdentifier: inner,
+		Type:           layers.EthernetTypeIPv4,
+	}
+	ip4 := layers.IPv4{
+		Protocol: layers.IPProtocolICMPv4,
+		Version:  4,
+		TTL:      64,
+		SrcIP:    srcIP,
+		DstIP:    dstIP,
+	}
+	icmp := layers.ICMPv4{
+		TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0),
+		Id:       id,
+		Seq:      seq,
+	}
+
+	return Serialize(&eth, &outerTag, &innerTag, &ip4, &icmp, gopacket.Payload(vlanHopPayload))
+}
+
+// VLANHopParseReply returns true if pkt is an echo reply from target to one
+// of our probes, along with the VLAN it has been received on, which is zero
+// for untagged frames.
+func VLANHopParseReply(pkt gopacket.Packet, target net.IP, id uint16) (uint16, bool) {
+	ip4, ok := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !ok || !ip4.SrcIP.Equal(target) {
+		return 0, false
+	}
+
+	icmp, ok := pkt.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4)
+	if !ok || icmp.TypeCode.Type() != layers.ICMPv4TypeEchoReply || icmp.Id != id {
+		return 0, false
+	}
+
+	if ids := VLANGetIDs(pkt); len(ids) > 0 {
+		return ids[0], true
+	}
+	return 0, true
+}
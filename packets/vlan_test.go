@@ -0,0 +1,79 @@
+package packets
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestNewVLANHopProbe(t *testing.T) {
+	srcHW := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	srcIP := net.ParseIP("192.168.1.10").To4()
+	dstIP := net.ParseIP("10.0.20.5").To4()
+
+	err, raw := NewVLANHopProbe(srcHW, srcIP, macBroadcast, dstIP, 1, 20, 1234, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+	if tags := VLANGetIDs(pkt); len(tags) != 2 || tags[0] != 1 || tags[1] != 20 {
+		t.Fatalf("expected tags [1 20], got %v", tags)
+	}
+
+	ip4, ok := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !ok {
+		t.Fatal("expected an ipv4 layer")
+	} else if !ip4.DstIP.Equal(dstIP) {
+		t.Fatalf("expected destination %s, got %s", dstIP, ip4.DstIP)
+	}
+
+	icmp, ok := pkt.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4)
+	if !ok {
+		t.Fatal("expected an icmp layer")
+	} else if icmp.TypeCode.Type() != layers.ICMPv4TypeEchoRequest || icmp.Id != 1234 || icmp.Seq != 7 {
+		t.Fatalf("unexpected icmp header %v id=%d seq=%d", icmp.TypeCode, icmp.Id, icmp.Seq)
+	}
+}
+
+func TestVLANHopParseReply(t *testing.T) {
+	target := net.ParseIP("10.0.20.5").To4()
+	reply := func(tagged bool, id uint16) gopacket.Packet {
+		eth := layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb},
+			DstMAC:       net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+			EthernetType: layers.EthernetTypeIPv4,
+		}
+		ip4 := layers.IPv4{
+			Protocol: layers.IPProtocolICMPv4,
+			Version:  4,
+			TTL:      64,
+			SrcIP:    target,
+			DstIP:    net.ParseIP("192.168.1.10").To4(),
+		}
+		icmp := layers.ICMPv4{
+			TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoReply, 0),
+			Id:       id,
+		}
+
+		var raw []byte
+		if tagged {
+			eth.EthernetType = layers.EthernetTypeDot1Q
+			tag := layers.Dot1Q{VLANIdentifier: 20, Type: layers.EthernetTypeIPv4}
+			_, raw = Serialize(&eth, &tag, &ip4, &icmp)
+		} else {
+			_, raw = Serialize(&eth, &ip4, &icmp)
+		}
+		return gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+	}
+
+	if vlan, found := VLANHopParseReply(reply(false, 1234), target, 1234); !found || vlan != 0 {
+		t.Fatalf("expected untagged reply, got vlan=%d found=%v", vlan, found)
+	} else if vlan, found = VLANHopParseReply(reply(true, 1234), target, 1234); !found || vlan != 20 {
+		t.Fatalf("expected reply on vlan 20, got vlan=%d found=%v", vlan, found)
+	} else if _, found = VLANHopParseReply(reply(false, 4321), target, 1234); found {
+		t.Fatal("reply to another probe should be ignored")
+	}
+}
@@ -1,7 +1,15 @@
 package packets
 
 import (
+	"encoding/xml"
 	"net"
+	"net/url"
+	"strings"
+
+	"github.com/evilsocket/islazy/str"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
 )
 
 const (
@@ -24,5 +32,61 @@ var (
 		"<soap:Body>" +
 		"<wsd:Probe/>" +
 		"</soap:Body>" +
-		"</env:Envelope>")
+		"</soap:Envelope>")
 )
+
+type wsdProbeMatch struct {
+	Address string `xml:"EndpointReference>Address"`
+	Types   string `xml:"Types"`
+	Scopes  string `xml:"Scopes"`
+	XAddrs  string `xml:"XAddrs"`
+}
+
+type wsdEnvelope struct {
+	Matches []wsdProbeMatch `xml:"Body>ProbeMatches>ProbeMatch"`
+}
+
+// WSDParseProbeMatches parses a WS-Discovery ProbeMatches response.
+func WSDParseProbeMatches(raw []byte) map[string]string {
+	env := wsdEnvelope{}
+	if err := xml.Unmarshal(raw, &env); err != nil || len(env.Matches) == 0 {
+		return nil
+	}
+
+	meta := make(map[string]string)
+	for _, match := range env.Matches {
+		if addr := str.Trim(match.Address); addr != "" {
+			meta["wsd:uuid"] = strings.TrimPrefix(addr, "urn:uuid:")
+		}
+		if types := strings.Fields(match.Types); len(types) > 0 {
+			meta["wsd:types"] = strings.Join(types, ",")
+		}
+		if scopes := strings.Fields(match.Scopes); len(scopes) > 0 {
+			meta["wsd:scopes"] = strings.Join(scopes, ",")
+		}
+		if xaddrs := strings.Fields(match.XAddrs); len(xaddrs) > 0 {
+			meta["wsd:xaddrs"] = strings.Join(xaddrs, ",")
+			for _, xaddr := range xaddrs {
+				// devices often advertise themselves by name
+				if u, err := url.Parse(xaddr); err == nil && u.Hostname() != "" && net.ParseIP(u.Hostname()) == nil {
+					meta["wsd:hostname"] = u.Hostname()
+					break
+				}
+			}
+		}
+	}
+
+	if len(meta) > 0 {
+		return meta
+	}
+	return nil
+}
+
+func WSDGetMeta(pkt gopacket.Packet) map[string]string {
+	if ludp := pkt.Layer(layers.LayerTypeUDP); ludp != nil {
+		if udp := ludp.(*layers.UDP); udp != nil && udp.SrcPort == WSDPort && len(udp.Payload) > 0 {
+			return WSDParseProbeMatches(udp.Payload)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package packets
+
+import (
+	"testing"
+)
+
+var wsdProbeMatches = []byte(`<?xml version="1.0" encoding="utf-8"?>
+<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope" xmlns:wsa="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:wsd="http://schemas.xmlsoap.org/ws/2005/04/discovery" xmlns:pub="http://schemas.microsoft.com/windows/pub/2005/07">
+<soap:Header><wsa:Action>http://schemas.xmlsoap.org/ws/2005/04/discovery/ProbeMatches</wsa:Action></soap:Header>
+<soap:Body>
+<wsd:ProbeMatches>
+<wsd:ProbeMatch>
+<wsa:EndpointReference><wsa:Address>urn:uuid:5d3f1e58-5ad1-4a8d-9d1b-1a2b3c4d5e6f</wsa:Address></wsa:EndpointReference>
+<wsd:Types>wsdp:Device pub:Computer</wsd:Types>
+<wsd:XAddrs>http://DESKTOP-42:5357/5d3f1e58-5ad1-4a8d-9d1b-1a2b3c4d5e6f/</wsd:XAddrs>
+</wsd:ProbeMatch>
+</wsd:ProbeMatches>
+</soap:Body>
+</soap:Envelope>`)
+
+func TestWSDParseProbeMatches(t *testing.T) {
+	meta := WSDParseProbeMatches(wsdProbeMatches)
+	if meta == nil {
+		t.Fatal("expected meta, got nil")
+	}
+
+	var units = []struct {
+		key   string
+		value string
+	}{
+		{"wsd:uuid", "5d3f1e58-5ad1-4a8d-9d1b-1a2b3c4d5e6f"},
+		{"wsd:types", "wsdp:Device,pub:Computer"},
+		{"wsd:hostname", "DESKTOP-42"},
+	}
+
+	for _, u := range units {
+		if got := meta[u.key]; got != u.value {
+			t.Fatalf("expected '%s' for %s, got '%s'", u.value, u.key, got)
+		}
+	}
+}
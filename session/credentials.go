@@ -0,0 +1,129 @@
+package session
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// kinds of secrets a Credential can hold
+const (
+	CredentialPassword  = "password"
+	CredentialHash      = "hash"
+	CredentialCommunity = "community"
+)
+
+// Credential is a secret captured by any of the modules, like a password
+// sniffed from a plaintext protocol or a NTLM hash.
+type Credential struct {
+	Protocol  string    `json:"protocol"`
+	Type      string    `json:"type"`
+	Source    string    `json:"source"`
+	Client    string    `json:"client"`
+	Server    string    `json:"server"`
+	Username  string    `json:"username"`
+	Secret    string    `json:"secret"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Hits      int       `json:"hits"`
+}
+
+// the same secret of the same user for the same server is stored once
+func (c Credential) key() string {
+	return strings.Join([]string{c.Protocol, c.Server, c.Username, c.Secret}, "\x00")
+}
+
+// Matches returns true if any of the fields of the credential contains
+// filter, case insensitive.
+func (c Credential) Matches(filter string) bool {
+	if filter == "" {
+		return true
+	}
+
+	filter = strings.ToLower(filter)
+	for _, field := range []string{c.Protocol, c.Type, c.Source, c.Client, c.Server, c.Username, c.Secret} {
+		if strings.Contains(strings.ToLower(field), filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// Credentials collects the secrets captured during the session.
+type Credentials struct {
+	sync.RWMutex
+	list  []*Credential
+	index map[string]*Credential
+}
+
+func NewCredentials() *Credentials {
+	return &Credentials{
+		list:  make([]*Credential, 0),
+		index: make(map[string]*Credential),
+	}
+}
+
+// Add stores cred, returning true if it's never been seen before.
+func (c *Credentials) Add(cred Credential) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	now := time.Now()
+	if found, exists := c.index[cred.key()]; exists {
+		found.LastSeen = now
+		found.Hits++
+		if found.Client == "" {
+			found.Client = cred.Client
+		}
+		return false
+	}
+
+	cred.FirstSeen = now
+	cred.LastSeen = now
+	cred.Hits = 1
+
+	c.list = append(c.list, &cred)
+	c.index[cred.key()] = &cred
+	return true
+}
+
+// List returns a copy of the credentials matching filter, in the order
+// they've been captured.
+func (c *Credentials) List(filter string) []Credential {
+	c.RLock()
+	defer c.RUnlock()
+
+	list := make([]Credential, 0, len(c.list))
+	for _, cred := range c.list {
+		if cred.Matches(filter) {
+			list = append(list, *cred)
+		}
+	}
+	return list
+}
+
+func (c *Credentials) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.list)
+}
+
+func (c *Credentials) Clear() {
+	c.Lock()
+	defer c.Unlock()
+	c.list = make([]*Credential, 0)
+	c.index = make(map[string]*Credential)
+}
+
+// AddCredential stores cred, pushing a creds.new event the first time it's
+// seen, modules should use this instead of logging the secrets they capture.
+func (s *Session) AddCredential(cred Credential) {
+	if cred.Type == "" {
+		cred.Type = CredentialPassword
+	}
+
+	if s.Credentials.Add(cred) {
+		s.Events.Add("creds.new", cred)
+		s.Refresh()
+	}
+}
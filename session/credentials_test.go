@@ -0,0 +1,57 @@
+package session
+
+import (
+	"testing"
+)
+
+func TestCredentialsAdd(t *testing.T) {
+	creds := NewCredentials()
+	cred := Credential{
+		Protocol: "ftp",
+		Client:   "192.168.1.10",
+		Server:   "192.168.1.1",
+		Username: "admin",
+		Secret:   "hunter2",
+	}
+
+	if !creds.Add(cred) {
+		t.Fatal("expected the credential to be new")
+	} else if creds.Add(cred) {
+		t.Fatal("expected the credential to be a duplicate")
+	} else if creds.Len() != 1 {
+		t.Fatalf("expected 1 credential, got %d", creds.Len())
+	}
+
+	cred.Secret = "hunter3"
+	if !creds.Add(cred) {
+		t.Fatal("expected a different secret to be a new credential")
+	}
+
+	list := creds.List("")
+	if len(list) != 2 {
+		t.Fatalf("expected 2 credentials, got %d", len(list))
+	} else if list[0].Hits != 2 || list[1].Hits != 1 {
+		t.Fatalf("unexpected hits %d and %d", list[0].Hits, list[1].Hits)
+	} else if list[0].FirstSeen.IsZero() || list[0].LastSeen.Before(list[0].FirstSeen) {
+		t.Fatalf("unexpected times %v and %v", list[0].FirstSeen, list[0].LastSeen)
+	}
+
+	creds.Clear()
+	if creds.Len() != 0 {
+		t.Fatalf("expected no credentials, got %d", creds.Len())
+	}
+}
+
+func TestCredentialsFilter(t *testing.T) {
+	creds := NewCredentials()
+	creds.Add(Credential{Protocol: "ftp", Server: "10.0.0.1", Username: "root", Secret: "toor"})
+	creds.Add(Credential{Protocol: "snmp", Type: CredentialCommunity, Server: "10.0.0.2", Secret: "public"})
+
+	if list := creds.List("SNMP"); len(list) != 1 || list[0].Secret != "public" {
+		t.Fatalf("unexpected list %v", list)
+	} else if list = creds.List("10.0.0"); len(list) != 2 {
+		t.Fatalf("unexpected list %v", list)
+	} else if list = creds.List("nope"); len(list) != 0 {
+		t.Fatalf("unexpected list %v", list)
+	}
+}
@@ -0,0 +1,111 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/evilsocket/islazy/log"
+	"github.com/gobwas/glob"
+)
+
+var levelNames = map[string]log.Verbosity{
+	"debug":     log.DEBUG,
+	"info":      log.INFO,
+	"important": log.IMPORTANT,
+	"warning":   log.WARNING,
+	"error":     log.ERROR,
+	"fatal":     log.FATAL,
+}
+
+// ParseLevel returns the verbosity named name, like warning, or debug if
+// name is empty.
+func ParseLevel(name string) (log.Verbosity, error) {
+	if name == "" {
+		return log.DEBUG, nil
+	} else if level, found := levelNames[strings.ToLower(name)]; found {
+		return level, nil
+	}
+	return log.DEBUG, fmt.Errorf("unknown level '%s'", name)
+}
+
+// EventLevel returns the severity of e, the one of its message for sys.log
+// events and info for any other.
+func EventLevel(e Event) log.Verbosity {
+	if m, ok := e.Data.(LogMessage); ok {
+		return m.Level
+	}
+	return log.INFO
+}
+
+// EventFilter selects the events by tag pattern, like wifi.*, and minimum
+// severity, it's used by the sinks to only forward the events their users
+// care about.
+type EventFilter struct {
+	sync.RWMutex
+	patterns []glob.Glob
+	level    log.Verbosity
+}
+
+// NewEventFilter returns a filter for the events with a tag matching any of
+// the patterns, or any tag if there are none, and at least level.
+func NewEventFilter(patterns []string, level string) (*EventFilter, error) {
+	f := &EventFilter{
+		patterns: make([]glob.Glob, 0),
+		level:    log.DEBUG,
+	}
+	return f, f.Update(patterns, level)
+}
+
+// Update replaces the patterns and the level of the filter, leaving it
+// untouched if they're not valid.
+func (f *EventFilter) Update(patterns []string, level string) error {
+	verbosity, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	globs := make([]glob.Glob, 0)
+	for _, pattern := range patterns {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		} else if g, err := glob.Compile(pattern); err != nil {
+			return fmt.Errorf("'%s' is not a valid tag pattern: %v", pattern, err)
+		} else {
+			globs = append(globs, g)
+		}
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	f.patterns = globs
+	f.level = verbosity
+	return nil
+}
+
+func (f *EventFilter) Matches(e Event) bool {
+	f.RLock()
+	defer f.RUnlock()
+
+	if EventLevel(e) < f.level {
+		return false
+	} else if len(f.patterns) == 0 {
+		return true
+	}
+
+	for _, g := range f.patterns {
+		if g.Match(e.Tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoggedBy returns true if e is a sys.log event logged by the module name,
+// sinks use it not to forward the errors they log while forwarding.
+func LoggedBy(e Event, name string) bool {
+	if m, ok := e.Data.(LogMessage); ok {
+		return strings.HasPrefix(m.Message, AsTag(name))
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/evilsocket/islazy/log"
+)
+
+func TestEventFilter(t *testing.T) {
+	f, err := NewEventFilter([]string{"wifi.*", "creds.new"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for tag, expected := range map[string]bool{
+		"wifi.ap.new":           true,
+		"wifi.client.handshake": true,
+		"creds.new":             true,
+		"endpoint.new":          false,
+		"sys.log":               false,
+	} {
+		if got := f.Matches(NewEvent(tag, nil)); got != expected {
+			t.Fatalf("expected %s to match %v, got %v", tag, expected, got)
+		}
+	}
+}
+
+func TestEventFilterLevel(t *testing.T) {
+	f, err := NewEventFilter(nil, "warning")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Matches(NewEvent("sys.log", LogMessage{Level: log.INFO, Message: "info"})) {
+		t.Fatal("expected an info message to be filtered")
+	} else if !f.Matches(NewEvent("sys.log", LogMessage{Level: log.ERROR, Message: "error"})) {
+		t.Fatal("expected an error message to match")
+	} else if f.Matches(NewEvent("endpoint.new", nil)) {
+		t.Fatal("expected an info event to be filtered")
+	}
+
+	if err = f.Update([]string{"endpoint.*"}, "INFO"); err != nil {
+		t.Fatal(err)
+	} else if !f.Matches(NewEvent("endpoint.new", nil)) {
+		t.Fatal("expected endpoint.new to match")
+	}
+}
+
+func TestEventFilterInvalid(t *testing.T) {
+	if _, err := NewEventFilter(nil, "loud"); err == nil {
+		t.Fatal("expected an error for an unknown level")
+	} else if _, err = NewEventFilter([]string{"wifi.[ap"}, ""); err == nil {
+		t.Fatal("expected an error for an invalid pattern")
+	}
+
+	f, _ := NewEventFilter([]string{"wifi.*"}, "")
+	if err := f.Update([]string{"ble.*"}, "loud"); err == nil {
+		t.Fatal("expected an error for an unknown level")
+	} else if !f.Matches(NewEvent("wifi.ap.new", nil)) {
+		t.Fatal("expected the filter to be left untouched")
+	}
+}
+
+func TestLoggedBy(t *testing.T) {
+	e := NewEvent("sys.log", LogMessage{Level: log.WARNING, Message: AsTag("events.webhook") + "error"})
+	if !LoggedBy(e, "events.webhook") {
+		t.Fatal("expected the message to be logged by events.webhook")
+	} else if LoggedBy(e, "events.stream") {
+		t.Fatal("expected the message not to be logged by events.stream")
+	} else if LoggedBy(NewEvent("endpoint.new", nil), "events.webhook") {
+		t.Fatal("expected other events not to be logged by any module")
+	}
+}
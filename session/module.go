@@ -22,6 +22,7 @@ type Module interface {
 
 	Extra() map[string]interface{}
 	Required() []string
+	InterfaceIndependent() bool
 	Running() bool
 	Start() error
 	Stop() error
@@ -67,6 +68,8 @@ type SessionModule struct {
 	params   map[string]*ModuleParam
 	requires []string
 	tag      string
+	// if true the module keeps running while the interface is gone
+	ifaceIndependent bool
 }
 
 func AsTag(name string) string {
@@ -144,6 +147,16 @@ func (m *SessionModule) Required() []string {
 	return m.requires
 }
 
+// SetInterfaceIndependent marks a module that doesn't use the network
+// interface, so that it's not restarted when the interface changes.
+func (m *SessionModule) SetInterfaceIndependent(independent bool) {
+	m.ifaceIndependent = independent
+}
+
+func (m *SessionModule) InterfaceIndependent() bool {
+	return m.ifaceIndependent
+}
+
 func (m *SessionModule) Handlers() []ModuleHandler {
 	return m.handlers
 }
@@ -8,7 +8,7 @@ import (
 	"strings"
 
 	"github.com/bettercap/bettercap/caplets"
-	_ "github.com/bettercap/bettercap/js"
+	"github.com/bettercap/bettercap/js"
 
 	"github.com/evilsocket/islazy/fs"
 	"github.com/evilsocket/islazy/plugin"
@@ -85,6 +85,8 @@ func LoadScript(fileName string) (*Script, error) {
 		return nil, err
 	} else if p, err := plugin.Parse(code); err != nil {
 		return nil, err
+	} else if err = p.Set("http", js.NewHTTPPackage(p)); err != nil {
+		return nil, err
 	} else {
 		p.Path = fileName
 		p.Name = strings.Replace(basePath, ".js", "", -1)
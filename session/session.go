@@ -67,12 +67,17 @@ const AliasesFile = "~/bettercap.aliases"
 
 var aliasesFileName, _ = fs.Expand(AliasesFile)
 
+const TagsFile = "~/bettercap.tags"
+
+var tagsFileName, _ = fs.Expand(TagsFile)
+
 type Session struct {
 	Options   core.Options
 	Interface *network.Endpoint
 	Gateway   *network.Endpoint
 	Env       *Environment
 	Lan       *network.LAN
+	Subnets   *network.Subnets
 	WiFi      *network.WiFi
 	BLE       *network.BLE
 	HID       *network.HID
@@ -82,6 +87,9 @@ type Session struct {
 	GPS       GPS
 	Modules   ModuleList
 	Aliases   *data.UnsortedKV
+	Tags      *network.Tags
+	// secrets captured by the modules
+	Credentials *Credentials
 
 	Input            *readline.Instance
 	Prompt           Prompt
@@ -112,6 +120,8 @@ func New() (*Session, error) {
 		Active:  false,
 		Queue:   nil,
 
+		Credentials: NewCredentials(),
+
 		CoreHandlers:     make([]CommandHandler, 0),
 		Modules:          make([]Module, 0),
 		Events:           nil,
@@ -131,6 +141,17 @@ func New() (*Session, error) {
 		network.CAPTURE_DEFAULTS.Bufsize = bufSize
 	}
 
+	validBackend := false
+	for _, backend := range firewall.Backends {
+		if *s.Options.Firewall == backend {
+			validBackend = true
+			break
+		}
+	}
+	if !validBackend {
+		return nil, fmt.Errorf("unknown firewall backend '%s', use one of %s", *s.Options.Firewall, strings.Join(firewall.Backends, ", "))
+	}
+
 	if s.Env, err = NewEnvironment(*s.Options.EnvFile); err != nil {
 		return nil, err
 	}
@@ -139,6 +160,10 @@ func New() (*Session, error) {
 		return nil, err
 	}
 
+	if s.Tags, err = network.NewTags(tagsFileName); err != nil {
+		return nil, err
+	}
+
 	s.Events = NewEventPool(*s.Options.Debug, *s.Options.Silent)
 
 	s.registerCoreHandlers()
@@ -267,7 +292,7 @@ func (s *Session) Start() error {
 		go s.routeMon()
 	}
 
-	s.Firewall = firewall.Make(s.Interface)
+	s.Firewall = firewall.Make(s.Interface, *s.Options.Firewall)
 
 	s.HID = network.NewHID(s.Aliases, func(dev *network.HIDDevice) {
 		s.Events.Add("hid.device.new", dev)
@@ -287,12 +312,23 @@ func (s *Session) Start() error {
 		s.Events.Add("wifi.ap.lost", ap)
 	})
 
+	// PTR lookups are always performed, mDNS and NBNS are tried next
+	network.DefaultResolver.
+		AddLookup("mdns", packets.MDNSLookupAddr).
+		AddLookup("nbns", packets.NBNSLookupAddr)
+
 	s.Lan = network.NewLAN(s.Interface, s.Gateway, s.Aliases, func(e *network.Endpoint) {
 		s.Events.Add("endpoint.new", e)
 	}, func(e *network.Endpoint) {
 		s.Events.Add("endpoint.lost", e)
 	})
 
+	s.Subnets = network.NewSubnets(func(subnet *network.Subnet) {
+		s.Events.Add("net.subnet.new", subnet)
+	})
+	s.Lan.SetSubnets(s.Subnets)
+	s.Queue.SetSubnets(s.Subnets)
+
 	s.setupEnv()
 
 	if err := s.setupReadline(); err != nil {
@@ -306,6 +342,8 @@ func (s *Session) Start() error {
 
 	s.startNetMon()
 
+	go s.ifaceMon()
+
 	s.Events.Add("session.started", nil)
 
 	// register js functions here to avoid cyclic dependency between
@@ -377,6 +415,24 @@ func (s *Session) FindMAC(ip net.IP, probe bool) (net.HardwareAddr, error) {
 	return hw, nil
 }
 
+// IsRouted returns true if ip is not on the interface subnet and
+// packets for it have to be sent through the gateway.
+func (s *Session) IsRouted(ip net.IP) bool {
+	return ip.To4() != nil && s.Interface.Net != nil && !s.Interface.Net.Contains(ip)
+}
+
+// NextHopMAC returns the hardware address packets for ip have to be sent to,
+// either the one of ip itself if on the same subnet or the one of the gateway.
+func (s *Session) NextHopMAC(ip net.IP, probe bool) (net.HardwareAddr, error) {
+	if s.IsRouted(ip) {
+		if s.Gateway == nil || s.Gateway.HW == nil || s.Gateway == s.Interface {
+			return nil, fmt.Errorf("%s is not on the interface subnet and no gateway is available.", ip.String())
+		}
+		return s.Gateway.HW, nil
+	}
+	return s.FindMAC(ip, probe)
+}
+
 func (s *Session) IsOn(moduleName string) bool {
 	for _, m := range s.Modules {
 		if m.Name() == moduleName {
@@ -66,10 +66,17 @@ func (s *Session) EventsCompleter(prefix string) []string {
 		"session.started",
 		"session.closing",
 		"update.available",
+		"iface.down",
+		"iface.up",
+		"iface.change",
 		"mod.started",
 		"mod.stopped",
 		"endpoint.new",
 		"endpoint.lost",
+		"endpoint.port.new",
+		"endpoint.port.closed",
+		"endpoint.port.changed",
+		"net.subnet.new",
 		"wifi.client.lost",
 		"wifi.client.probe",
 		"wifi.client.new",
@@ -88,7 +95,31 @@ func (s *Session) EventsCompleter(prefix string) []string {
 		"http.spoofed-response",
 		"https.spoofed-request",
 		"https.spoofed-response",
+		"https.proxy.ja3",
+		"https.proxy.ja3s",
+		"http.proxy.stats",
+		"https.proxy.stats",
 		"syn.scan",
+		"syn.scan.progress",
+		"snmp.scan",
+		"smb.recon",
+		"ntlm.relay.message",
+		"ntlm.relay.hash",
+		"iot.scan",
+		"mqtt.proxy.credentials",
+		"creds.new",
+		"dhcp.spoof.lease",
+		"name.spoof.poisoned",
+		"spoof.stats.new",
+		"spoof.stats.update",
+		"arp.watch.conflict",
+		"arp.watch.gateway",
+		"arp.watch.flood",
+		"arp.watch.multiple_ips",
+		"arp.watch.mismatch",
+		"l2.recon.new",
+		"l2.recon.stp.root",
+		"vlan.hop",
 		"net.sniff.mdns",
 		"net.sniff.mdns",
 		"net.sniff.dot11",
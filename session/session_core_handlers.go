@@ -445,4 +445,21 @@ func (s *Session) registerCoreHandlers() {
 			return macs
 		})))
 
+	s.addHandler(NewCommandHandler("net.tag ADDRESS NAME=VALUE",
+		`^net\.tag\s+([^\s]+)\s+([^\s=]+)\s*=\s*(.*)$`,
+		"Attach a NAME=VALUE tag or note to the endpoint, access point or BLE device with the given MAC or IP ADDRESS.",
+		s.tagHandler),
+		readline.PcItem("net.tag", readline.PcItemDynamic(s.tagTargetCompleter("net.tag"))))
+
+	s.addHandler(NewCommandHandler("net.untag ADDRESS NAME?",
+		`^net\.untag\s+([^\s]+)\s*([^\s]*)$`,
+		"Remove the NAME tag from the given MAC or IP ADDRESS, or all of its tags if no NAME is given.",
+		s.untagHandler),
+		readline.PcItem("net.untag", readline.PcItemDynamic(s.tagTargetCompleter("net.untag"))))
+
+	s.addHandler(NewCommandHandler("net.tags",
+		`^net\.tags$`,
+		"Show every tagged address.",
+		s.tagsHandler),
+		readline.PcItem("net.tags"))
 }
@@ -0,0 +1,166 @@
+package session
+
+import (
+	"net"
+	"time"
+
+	"github.com/bettercap/bettercap/network"
+
+	"github.com/evilsocket/islazy/log"
+)
+
+const ifaceMonInterval = time.Duration(2) * time.Second
+
+type ifaceState struct {
+	MAC  string `json:"mac"`
+	IPv4 string `json:"ipv4"`
+	IPv6 string `json:"ipv6"`
+}
+
+func stateOf(e *network.Endpoint) ifaceState {
+	if e == nil {
+		return ifaceState{}
+	}
+	return ifaceState{
+		MAC:  e.HwAddress,
+		IPv4: e.CIDR(),
+		IPv6: e.Ip6Address,
+	}
+}
+
+type InterfaceChange struct {
+	Name    string     `json:"name"`
+	Prev    ifaceState `json:"prev"`
+	New     ifaceState `json:"new"`
+	Modules []string   `json:"modules"`
+}
+
+// the interface endpoint is referenced all over the place, so it is
+// updated in place instead of being replaced
+func updateEndpoint(dst, src *network.Endpoint) {
+	dst.Index = src.Index
+	dst.HW = src.HW
+	dst.HwAddress = src.HwAddress
+	dst.Vendor = src.Vendor
+	dst.SetIP(src.IpAddress)
+	dst.SetBits(src.SubnetBits)
+	dst.IPv6 = src.IPv6
+	dst.Ip6Address = src.Ip6Address
+	dst.Ip6LinkLocal = src.Ip6LinkLocal
+}
+
+func (s *Session) ifaceDependentModules() []string {
+	names := []string{}
+	for _, m := range s.Modules {
+		if m.Running() && !m.InterfaceIndependent() {
+			names = append(names, m.Name())
+		}
+	}
+	return names
+}
+
+func (s *Session) stopModules(names []string) {
+	for _, name := range names {
+		if err, m := s.Module(name); err == nil && m.Running() {
+			if err = m.Stop(); err != nil {
+				s.Events.Log(log.WARNING, "error stopping %s: %v", name, err)
+			}
+		}
+	}
+}
+
+func (s *Session) startModules(names []string) {
+	for _, name := range names {
+		if err, m := s.Module(name); err == nil && !m.Running() {
+			if err = m.Start(); err != nil {
+				s.Events.Log(log.ERROR, "error restarting %s: %v", name, err)
+			}
+		}
+	}
+}
+
+// refresh the gateway once the interface is configured again
+func (s *Session) refreshGateway() {
+	if s.Gateway == s.Interface {
+		return
+	}
+
+	if gw, err := network.FindGateway(s.Interface); err != nil {
+		s.Events.Log(log.DEBUG, "error refreshing gateway: %v", err)
+	} else if gw != nil && gw.IpAddress != s.Gateway.IpAddress {
+		updateEndpoint(s.Gateway, gw)
+	}
+}
+
+func ifaceIsUp(name string) bool {
+	iface, err := net.InterfaceByName(name)
+	return err == nil && iface.Flags&net.FlagUp != 0
+}
+
+// ifaceMon detects the interface going away or changing its addresses,
+// pausing the modules using it until it's back and configured again.
+func (s *Session) ifaceMon() {
+	name := s.Interface.Name()
+	paused := []string(nil)
+	prev := stateOf(s.Interface)
+
+	for s.Active {
+		time.Sleep(ifaceMonInterval)
+
+		var now *network.Endpoint
+		if ifaceIsUp(name) {
+			now, _ = network.FindInterface(name)
+		}
+
+		if now == nil {
+			if paused == nil {
+				paused = s.ifaceDependentModules()
+				s.Events.Add("iface.down", InterfaceChange{
+					Name:    name,
+					Prev:    prev,
+					Modules: paused,
+				})
+				s.stopModules(paused)
+			}
+			continue
+		}
+
+		state := stateOf(now)
+		if paused == nil && state == prev {
+			continue
+		}
+
+		updateEndpoint(s.Interface, now)
+		s.refreshGateway()
+		s.setupEnv()
+
+		if paused != nil {
+			if err := s.Queue.Reopen(); err != nil {
+				s.Events.Log(log.ERROR, "error reopening %s: %v", name, err)
+				continue
+			}
+
+			s.Events.Add("iface.up", InterfaceChange{
+				Name:    name,
+				Prev:    prev,
+				New:     state,
+				Modules: paused,
+			})
+			s.startModules(paused)
+			paused = nil
+		} else {
+			// same interface, new addresses (for instance a DHCP renew)
+			restart := s.ifaceDependentModules()
+			s.Events.Add("iface.change", InterfaceChange{
+				Name:    name,
+				Prev:    prev,
+				New:     state,
+				Modules: restart,
+			})
+			s.stopModules(restart)
+			s.startModules(restart)
+		}
+
+		prev = state
+	}
+}
@@ -56,9 +56,11 @@ type SessionJSON struct {
 	Gateway    *network.Endpoint `json:"gateway"`
 	Env        *Environment      `json:"env"`
 	Lan        *network.LAN      `json:"lan"`
+	Subnets    *network.Subnets  `json:"subnets"`
 	WiFi       *network.WiFi     `json:"wifi"`
 	BLE        *network.BLE      `json:"ble"`
 	HID        *network.HID      `json:"hid"`
+	Tags       *network.Tags     `json:"tags"`
 	Queue      *packets.Queue    `json:"packets"`
 	StartedAt  time.Time         `json:"started_at"`
 	PolledAt   time.Time         `json:"polled_at"`
@@ -92,9 +94,11 @@ func (s *Session) MarshalJSON() ([]byte, error) {
 		Gateway:    s.Gateway,
 		Env:        s.Env,
 		Lan:        s.Lan,
+		Subnets:    s.Subnets,
 		WiFi:       s.WiFi,
 		BLE:        s.BLE,
 		HID:        s.HID,
+		Tags:       s.Tags,
 		Queue:      s.Queue,
 		StartedAt:  s.StartedAt,
 		PolledAt:   time.Now(),
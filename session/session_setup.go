@@ -102,7 +102,8 @@ func (s *Session) startNetMon() {
 				if existing != nil {
 					existing.LastSeen = time.Now()
 				} else {
-					existing, _ = s.Lan.Get(mac)
+					// hosts behind the gateway share its mac
+					existing = s.Lan.GetByIp(addr)
 				}
 
 				if existing != nil && event.Meta != nil {
@@ -0,0 +1,103 @@
+package session
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/bettercap/bettercap/network"
+
+	"github.com/evilsocket/islazy/str"
+	"github.com/evilsocket/islazy/tui"
+)
+
+// TagTarget returns the MAC address tags should be attached to, address
+// can either be a MAC address or the IP address of a known endpoint.
+func (s *Session) TagTarget(address string) (string, error) {
+	address = str.Trim(address)
+	if hw, err := net.ParseMAC(address); err == nil {
+		return network.NormalizeMac(hw.String()), nil
+	} else if ip := net.ParseIP(address); ip == nil {
+		return "", fmt.Errorf("'%s' is not a valid MAC or IP address", address)
+	} else if e := s.Lan.GetByIp(ip.String()); e != nil {
+		return e.HwAddress, nil
+	}
+	return "", fmt.Errorf("could not find the MAC address of %s", address)
+}
+
+// name of whatever entity is using this mac address, if any
+func (s *Session) tagTargetName(mac string) string {
+	if e, found := s.Lan.Get(mac); found {
+		if e.Alias != "" {
+			return e.Alias
+		} else if e.Hostname != "" {
+			return e.Hostname
+		}
+		return e.IpAddress
+	} else if ap, found := s.WiFi.Get(mac); found {
+		return ap.ESSID()
+	} else if sta, found := s.WiFi.GetClient(mac); found {
+		return sta.Alias
+	} else if dev, found := s.BLE.Get(mac); found {
+		return dev.Alias
+	}
+	return ""
+}
+
+func (s *Session) tagHandler(args []string, sess *Session) error {
+	mac, err := s.TagTarget(args[0])
+	if err != nil {
+		return err
+	}
+
+	name := str.Trim(args[1])
+	value := str.Trim(args[2])
+	if value == "\"\"" || value == "''" {
+		value = ""
+	}
+
+	return s.Tags.Set(mac, name, value)
+}
+
+func (s *Session) untagHandler(args []string, sess *Session) error {
+	mac, err := s.TagTarget(args[0])
+	if err != nil {
+		return err
+	}
+	return s.Tags.Del(mac, str.Trim(args[1]))
+}
+
+func (s *Session) tagsHandler(args []string, sess *Session) error {
+	rows := [][]string{}
+	s.Tags.Each(func(mac string, tags map[string]string) {
+		rows = append(rows, []string{
+			mac,
+			tui.Yellow(s.tagTargetName(mac)),
+			s.Tags.String(mac),
+		})
+	})
+
+	if len(rows) == 0 {
+		s.Events.Printf("\nno tags\n\n")
+		return nil
+	}
+
+	s.Events.Printf("\n")
+	tui.Table(s.Events.Stdout, []string{"MAC", "Name", "Tags"}, rows)
+	s.Events.Printf("\n")
+	return nil
+}
+
+// readline completion for the addresses of the known endpoints
+func (s *Session) tagTargetCompleter(cmd string) func(string) []string {
+	return func(prefix string) []string {
+		prefix = str.Trim(strings.TrimPrefix(prefix, cmd))
+		addrs := []string{""}
+		s.Lan.EachHost(func(mac string, e *network.Endpoint) {
+			if prefix == "" || strings.HasPrefix(e.IpAddress, prefix) {
+				addrs = append(addrs, e.IpAddress)
+			}
+		})
+		return addrs
+	}
+}
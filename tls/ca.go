@@ -0,0 +1,178 @@
+package tls
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/youmark/pkcs8"
+)
+
+// IsPKCS12 returns true if the file name has one of the PKCS#12 extensions.
+func IsPKCS12(fileName string) bool {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	return ext == ".p12" || ext == ".pfx"
+}
+
+// LoadCA loads a certification authority from a PKCS#12 archive or from PEM
+// files, the key can be in the same file as the certificate, in which case
+// keyFile can be empty, and it can be encrypted with password.
+func LoadCA(certFile, keyFile, password string) (*tls.Certificate, error) {
+	var key crypto.PrivateKey
+	var certs []*x509.Certificate
+
+	raw, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if IsPKCS12(certFile) {
+		if key, certs, err = DecodePKCS12(raw, password); err != nil {
+			return nil, err
+		}
+	} else {
+		if keyFile != "" && keyFile != certFile {
+			rawKey, err := ioutil.ReadFile(keyFile)
+			if err != nil {
+				return nil, err
+			}
+			raw = append(append(raw, '\n'), rawKey...)
+		}
+		if key, certs, err = decodePEM(raw, password); err != nil {
+			return nil, err
+		}
+	}
+
+	leaf := certs[0]
+	if !leaf.IsCA {
+		return nil, fmt.Errorf("%s is not a certification authority", leaf.Subject.CommonName)
+	} else if !publicKeyMatches(leaf, key) {
+		return nil, errors.New("the private key does not match the certificate")
+	}
+
+	ca := &tls.Certificate{
+		PrivateKey: key,
+		Leaf:       leaf,
+	}
+	for _, cert := range certs {
+		ca.Certificate = append(ca.Certificate, cert.Raw)
+	}
+
+	return ca, nil
+}
+
+func decodePEM(data []byte, password string) (crypto.PrivateKey, []*x509.Certificate, error) {
+	var key crypto.PrivateKey
+	certs := make([]*x509.Certificate, 0)
+
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+
+		if block.Type == "CERTIFICATE" {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			certs = append(certs, cert)
+		} else if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			der := block.Bytes
+			// legacy encryption with Proc-Type and DEK-Info headers
+			if x509.IsEncryptedPEMBlock(block) {
+				var err error
+				if der, err = x509.DecryptPEMBlock(block, []byte(password)); err != nil {
+					return nil, nil, err
+				}
+			} else if block.Type == "ENCRYPTED PRIVATE KEY" {
+				var err error
+				if key, err = pkcs8.ParsePKCS8PrivateKey(der, []byte(password)); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+
+			var err error
+			if key, err = parsePrivateKey(der); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if key == nil {
+		return nil, nil, errors.New("no private key found")
+	} else if len(certs) == 0 {
+		return nil, nil, errors.New("no certificate found")
+	}
+
+	return key, certs, nil
+}
+
+func parsePrivateKey(der []byte) (crypto.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	} else if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, errors.New("unsupported private key format")
+	}
+
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported private key type %T", key)
+}
+
+// LoadCertificate loads a certificate chain and its key saved by
+// SaveCertificate.
+func LoadCertificate(fileName string) (*tls.Certificate, error) {
+	raw, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	key, certs, err := decodePEM(raw, "")
+	if err != nil {
+		return nil, err
+	}
+
+	cert := &tls.Certificate{
+		PrivateKey: key,
+		Leaf:       certs[0],
+	}
+	for _, c := range certs {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	return cert, nil
+}
+
+// SaveCertificate saves the certificate chain and its key as PEM blocks in
+// the same file.
+func SaveCertificate(cert *tls.Certificate, fileName string) error {
+	der, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	data := make([]byte, 0)
+	for _, raw := range cert.Certificate {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw})...)
+	}
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})...)
+
+	return ioutil.WriteFile(fileName, data, 0600)
+}
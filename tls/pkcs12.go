@@ -0,0 +1,29 @@
+package tls
+
+import (
+	"crypto"
+	"crypto/x509"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// DecodePKCS12 returns the private key and the certificates, leaf first, of
+// a PKCS#12 archive, verifying its integrity with password.
+func DecodePKCS12(data []byte, password string) (crypto.PrivateKey, []*x509.Certificate, error) {
+	key, leaf, chain, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certs := append([]*x509.Certificate{leaf}, chain...)
+	return key, certs, nil
+}
+
+func publicKeyMatches(cert *x509.Certificate, key crypto.PrivateKey) bool {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return false
+	}
+	pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	return ok && pub.Equal(cert.PublicKey)
+}
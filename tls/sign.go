@@ -1,6 +1,9 @@
 package tls
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
@@ -85,22 +88,70 @@ func SignCertificateForHost(ca *tls.Certificate, host string, port int) (cert *t
 			ExtKeyUsage:           srvCert.ExtKeyUsage,
 			IPAddresses:           srvCert.IPAddresses,
 			DNSNames:              srvCert.DNSNames,
+			EmailAddresses:        srvCert.EmailAddresses,
+			URIs:                  srvCert.URIs,
 			BasicConstraintsValid: true,
 		}
+
+		// make sure the name the client asked for is covered
+		if ip := net.ParseIP(host); ip != nil && !containsIP(template.IPAddresses, ip) {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if ip == nil && srvCert.VerifyHostname(host) != nil {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	// a leaf valid outside of the validity of its CA would be rejected
+	if template.NotBefore.Before(x509ca.NotBefore) {
+		template.NotBefore = x509ca.NotBefore
+	}
+	if template.NotAfter.After(x509ca.NotAfter) {
+		template.NotAfter = x509ca.NotAfter
+	}
+
+	var pub interface{}
+	if srvCert != nil {
+		pub = srvCert.PublicKey
 	}
 
-	var certpriv *rsa.PrivateKey
-	if certpriv, err = rsa.GenerateKey(rand.Reader, 1024); err != nil {
+	certpriv, err := generateKeyLike(pub)
+	if err != nil {
 		return
 	}
 
 	var derBytes []byte
-	if derBytes, err = x509.CreateCertificate(rand.Reader, &template, x509ca, &certpriv.PublicKey, ca.PrivateKey); err != nil {
+	if derBytes, err = x509.CreateCertificate(rand.Reader, &template, x509ca, certpriv.Public(), ca.PrivateKey); err != nil {
 		return
 	}
 
 	return &tls.Certificate{
-		Certificate: [][]byte{derBytes, ca.Certificate[0]},
+		Certificate: append([][]byte{derBytes}, ca.Certificate...),
 		PrivateKey:  certpriv,
 	}, nil
 }
+
+// generateKeyLike returns a new private key of the same type and size of
+// pub, so that the spoofed certificate looks like the original one.
+func generateKeyLike(pub interface{}) (crypto.Signer, error) {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.GenerateKey(k.Curve, rand.Reader)
+	case ed25519.PublicKey:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	case *rsa.PublicKey:
+		if bits := k.N.BitLen(); bits >= 2048 && bits <= 4096 {
+			return rsa.GenerateKey(rand.Reader, bits)
+		}
+	}
+	return rsa.GenerateKey(rand.Reader, 2048)
+}
+
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, i := range ips {
+		if i.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}